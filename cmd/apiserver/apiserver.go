@@ -22,13 +22,19 @@ import (
 	"flag"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/cloudprovider"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/master"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/etcd"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/event"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/ui"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	verflag "github.com/GoogleCloudPlatform/kubernetes/pkg/version/flag"
 	"github.com/golang/glog"
@@ -37,24 +43,44 @@ import (
 var (
 	port                        = flag.Uint("port", 8080, "The port to listen on.  Default 8080.")
 	address                     = flag.String("address", "127.0.0.1", "The address on the local server to listen to. Default 127.0.0.1")
-	apiPrefix                   = flag.String("api_prefix", "/api/v1beta1", "The prefix for API requests on the server. Default '/api/v1beta1'")
+	readOnlyPort                = flag.Uint("read_only_port", 7080, "The port on which to serve read-only, unauthenticated access to the API; 0 to disable. Default 7080.")
+	readOnlyAddress             = flag.String("read_only_address", "127.0.0.1", "The address on which to serve read-only access to the API. Default 127.0.0.1")
+	apiPrefix                   = flag.String("api_prefix", "/api/v1beta1", "The prefix for v1beta1 API requests on the server. Every API version, v1beta1 included, is also served beneath /api. Default '/api/v1beta1'")
 	cloudProvider               = flag.String("cloud_provider", "", "The provider for cloud services.  Empty string for no provider.")
 	minionRegexp                = flag.String("minion_regexp", "", "If non empty, and -cloud_provider is specified, a regular expression for matching minion VMs")
 	minionPort                  = flag.Uint("minion_port", 10250, "The port at which kubelet will be listening on the minions.")
 	healthCheckMinions          = flag.Bool("health_check_minions", true, "If true, health check minions and filter unhealthy ones. [default true]")
 	minionCacheTTL              = flag.Duration("minion_cache_ttl", 30*time.Second, "Duration of time to cache minion information. [default 30 seconds]")
+	minionHeartbeatTimeout      = flag.Duration("minion_heartbeat_timeout", 0, "If non-zero, minions which haven't registered themselves for this long are marked NotReady and get no new pods. Minions from -machines are NotReady until they register.")
+	minionAdmissionRegexp       = flag.String("minion_admission_regexp", "", "If non empty, a regular expression minion names must match to be allowed to register.")
+	enableUI                    = flag.Bool("enable_ui", false, "If true, serve a basic cluster dashboard at /ui/.")
+	operationTTL                = flag.Duration("operation_ttl", apiserver.DefaultOperationTTL, "Duration of time to keep the records of finished operations. [default 10 minutes]")
+	eventTTL                    = flag.Duration("event_ttl", event.DefaultTTL, "Duration of time to keep events. [default 48 hours]")
+	etcdCertFile                = flag.String("etcd_certfile", "", "If set, the client certificate presented to the etcd servers. Requires -etcd_keyfile.")
+	etcdKeyFile                 = flag.String("etcd_keyfile", "", "If set, the private key for -etcd_certfile.")
+	etcdCAFile                  = flag.String("etcd_cafile", "", "If set, the CA bundle used to verify the etcd servers' certificates.")
+	etcdUsername                = flag.String("etcd_username", "", "If set, the user name sent to the etcd servers with HTTP basic auth.")
+	etcdPasswordFile            = flag.String("etcd_password_file", "", "If set, a file holding the password sent to the etcd servers with HTTP basic auth.")
+	etcdEncryptionKeyFile       = flag.String("etcd_encryption_key_file", "", "If set, a file holding a base64 encoded 16, 24 or 32 byte AES key, which objects are encrypted with before they are stored in etcd. Container manifests, services and endpoints, which kubelets and proxies read from etcd, are not encrypted.")
+	janitorPodTTL               = flag.Duration("janitor_pod_ttl", 0, "If non-zero, pods assigned to a minion which no longer exists are removed after this long.")
+	janitorEndpointsTTL         = flag.Duration("janitor_endpoints_ttl", 0, "If non-zero, endpoints of a service which no longer exists are removed after this long.")
+	janitorOperationTTL         = flag.Duration("janitor_operation_ttl", 0, "If non-zero, operation records which haven't changed for this long are removed.")
+	janitorDryRun               = flag.Bool("janitor_dry_run", false, "If true, only log the orphaned registry entries the -janitor_*_ttl flags would remove.")
+	portalNet                   = flag.String("portal_net", "", "If set, a network in CIDR notation (e.g. 10.0.0.0/24) from which each service is given a portal IP.")
 	etcdServerList, machineList util.StringList
+	servicePortRange            util.PortRange
 )
 
 func init() {
-	flag.Var(&etcdServerList, "etcd_servers", "List of etcd servers to watch (http://ip:port), comma separated")
+	flag.Var(&etcdServerList, "etcd_servers", "List of etcd servers to watch (http://ip:port), comma separated. Requests fail over to the next server when one is unreachable.")
 	flag.Var(&machineList, "machines", "List of machines to schedule onto, comma separated.")
+	flag.Var(&servicePortRange, "service_port_range", "If set, the range of ports services without a portal IP may have (e.g. 30000-32767), kept clear of the daemons of the minions, since proxies listen on these ports for them.")
 }
 
 func verifyMinionFlags() {
 	if *cloudProvider == "" || *minionRegexp == "" {
 		if len(machineList) == 0 {
-			glog.Fatal("No machines specified!")
+			glog.Info("No machines specified, waiting for minions to register themselves.")
 		}
 		return
 	}
@@ -63,6 +89,16 @@ func verifyMinionFlags() {
 	}
 }
 
+// operationOwner names this apiserver in the records of its operations by its
+// host and port, so that apiservers on one host are told apart.
+func operationOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		glog.Fatalf("Unable to get the hostname: %v", err)
+	}
+	return net.JoinHostPort(hostname, strconv.Itoa(int(*port)))
+}
+
 func main() {
 	flag.Parse()
 	util.InitLogs()
@@ -74,6 +110,30 @@ func main() {
 	if len(etcdServerList) == 0 {
 		glog.Fatalf("-etcd_servers flag is required.")
 	}
+	etcdConfig := tools.EtcdClientConfig{
+		CertFile:     *etcdCertFile,
+		KeyFile:      *etcdKeyFile,
+		CAFile:       *etcdCAFile,
+		Username:     *etcdUsername,
+		PasswordFile: *etcdPasswordFile,
+	}
+	if err := tools.ValidateEtcdServers(etcdServerList, etcdConfig); err != nil {
+		glog.Fatalf("Invalid etcd configuration: %v", err)
+	}
+	etcdClientFactory, err := tools.NewEtcdClientFactory(etcdConfig)
+	if err != nil {
+		glog.Fatalf("Invalid etcd configuration: %v", err)
+	}
+	var etcdCodec tools.Codec
+	if *etcdEncryptionKeyFile != "" {
+		key, err := tools.LoadEncryptionKey(*etcdEncryptionKeyFile)
+		if err != nil {
+			glog.Fatalf("Unable to load the etcd encryption key: %v", err)
+		}
+		if etcdCodec, err = tools.NewEncryptingCodec(api.Codec, key); err != nil {
+			glog.Fatalf("Invalid etcd encryption key: %v", err)
+		}
+	}
 
 	cloud, err := cloudprovider.GetCloudProvider(*cloudProvider)
 	if err != nil {
@@ -92,23 +152,82 @@ func main() {
 		Port:   *minionPort,
 	}
 
+	var portals *net.IPNet
+	if len(*portalNet) > 0 {
+		_, portals, err = net.ParseCIDR(*portalNet)
+		if err != nil {
+			glog.Fatalf("Invalid -portal_net %q: %v", *portalNet, err)
+		}
+	}
+
 	client := client.New("http://"+net.JoinHostPort(*address, strconv.Itoa(int(*port))), nil)
 
-	m := master.New(&master.Config{
-		Client:             client,
-		Cloud:              cloud,
-		EtcdServers:        etcdServerList,
-		HealthCheckMinions: *healthCheckMinions,
-		Minions:            machineList,
-		MinionCacheTTL:     *minionCacheTTL,
-		MinionRegexp:       *minionRegexp,
-		PodInfoGetter:      podInfoGetter,
+	m, err := master.New(&master.Config{
+		Client:                 client,
+		Cloud:                  cloud,
+		EtcdServers:            etcdServerList,
+		EtcdClientFactory:      etcdClientFactory,
+		EtcdCodec:              etcdCodec,
+		HealthCheckMinions:     *healthCheckMinions,
+		Minions:                machineList,
+		MinionCacheTTL:         *minionCacheTTL,
+		MinionRegexp:           *minionRegexp,
+		MinionHeartbeatTimeout: *minionHeartbeatTimeout,
+		MinionAdmissionRegexp:  *minionAdmissionRegexp,
+		OperationTTL:           *operationTTL,
+		OperationOwner:         operationOwner(),
+		EventTTL:               *eventTTL,
+		PodInfoGetter:          podInfoGetter,
+		PortalNet:              portals,
+		ServicePortRange:       servicePortRange,
+		Janitor: etcd.JanitorConfig{
+			PodTTL:       *janitorPodTTL,
+			EndpointsTTL: *janitorEndpointsTTL,
+			OperationTTL: *janitorOperationTTL,
+			DryRun:       *janitorDryRun,
+		},
 	})
+	if err != nil {
+		glog.Fatalf("Unable to start the master: %v", err)
+	}
+
+	v1beta1Storage, v1beta1Codec := m.API_v1beta1()
+	v1beta2Storage, v1beta2Codec := m.API_v1beta2()
+	v1beta1 := apiserver.NewAPIGroupWithOperations(v1beta1Storage, v1beta1Codec, m.Operations())
+	var handler http.Handler = apiserver.HandleVersions(map[string]*apiserver.APIGroup{
+		"v1beta1": v1beta1,
+		"v1beta2": apiserver.NewAPIGroupWithOperations(v1beta2Storage, v1beta2Codec, m.Operations()),
+	}, "/api")
+	if *apiPrefix != "/api/v1beta1" {
+		// Keep serving v1beta1 where clients were told to find it.
+		mux := http.NewServeMux()
+		mux.Handle("/", handler)
+		v1beta1.InstallREST(mux, *apiPrefix)
+		handler = apiserver.RecoverPanics(mux)
+	}
+	if *enableUI {
+		mux := http.NewServeMux()
+		mux.Handle("/", handler)
+		ui.InstallHandler(mux, "/ui/", *apiPrefix)
+		handler = mux
+	}
+	if *readOnlyPort != 0 {
+		// Meant for monitoring and health checking agents on the local machine.
+		readOnly := &http.Server{
+			Addr:           net.JoinHostPort(*readOnlyAddress, strconv.Itoa(int(*readOnlyPort))),
+			Handler:        apiserver.ReadOnly(handler),
+			ReadTimeout:    5 * time.Minute,
+			WriteTimeout:   5 * time.Minute,
+			MaxHeaderBytes: 1 << 20,
+		}
+		go util.Forever(func() {
+			glog.Errorf("Unable to serve read-only API: %v", readOnly.ListenAndServe())
+		}, 15*time.Second)
+	}
 
-	storage, codec := m.API_v1beta1()
 	s := &http.Server{
 		Addr:           net.JoinHostPort(*address, strconv.Itoa(int(*port))),
-		Handler:        apiserver.Handle(storage, codec, *apiPrefix),
+		Handler:        handler,
 		ReadTimeout:    5 * time.Minute,
 		WriteTimeout:   5 * time.Minute,
 		MaxHeaderBytes: 1 << 20,
@@ -14,27 +14,49 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// The controller manager is responsible for monitoring replication
-// controllers, and creating corresponding pods to achieve the desired
+// The controller manager is responsible for monitoring replication and
+// daemon controllers, and creating corresponding pods to achieve the desired
 // state.  It uses the API to listen for new controllers and to create/delete
-// pods.
+// pods. Given the etcd servers of a skydns server, it also publishes the
+// services of the cluster as DNS records. Several replicas can run for
+// availability, electing the one which acts through etcd.
 package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/controller"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/dns"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/election"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	verflag "github.com/GoogleCloudPlatform/kubernetes/pkg/version/flag"
+	"github.com/coreos/go-etcd/etcd"
 	"github.com/golang/glog"
 )
 
 var (
-	master = flag.String("master", "", "The address of the Kubernetes API server")
+	master            = flag.String("master", "", "The address of the Kubernetes API server")
+	clusterDomain     = flag.String("cluster_domain", "kubernetes.local", "The domain the DNS records of services are published in")
+	scaleDownOrder    = flag.String("scale_down_order", string(controller.ScaleDownUnready), "The order in which controllers with too many replicas delete their pods: unready (unscheduled, pending and not ready pods first), youngest or oldest")
+	syncWorkers       = flag.Int("sync_workers", controller.DefaultSyncWorkers, "The number of replication controllers synced concurrently")
+	leaseDuration     = flag.Duration("lease_duration", 15*time.Second, "How long the lease of the elected controller manager lasts; another replica takes over at most this long, plus a third of it, after the leader dies")
+	etcdServerList    util.StringList
+	skyDNSEtcdServers util.StringList
 )
 
+// leasePath is the etcd key the controller manager replicas elect their leader
+// by.
+const leasePath = "/election/controller-manager"
+
+func init() {
+	flag.Var(&etcdServerList, "etcd_servers", "List of etcd servers to elect the acting controller manager among replicas with (http://ip:port), comma separated. If empty, this controller manager acts without an election")
+	flag.Var(&skyDNSEtcdServers, "skydns_etcd_servers", "List of etcd servers of the cluster's skydns server (http://ip:port), comma separated. If empty, no DNS records are published")
+}
+
 func main() {
 	flag.Parse()
 	util.InitLogs()
@@ -46,9 +68,50 @@ func main() {
 		glog.Fatal("usage: controller-manager -master <master>")
 	}
 
-	controllerManager := controller.NewReplicationManager(
-		client.New("http://"+*master, nil))
+	order, err := controller.ParseScaleDownOrder(*scaleDownOrder)
+	if err != nil {
+		glog.Fatalf("Invalid -scale_down_order: %v", err)
+	}
+	if *syncWorkers < 1 {
+		glog.Fatalf("Invalid -sync_workers: %d, must be at least 1", *syncWorkers)
+	}
+	if *leaseDuration < 3*time.Second {
+		glog.Fatalf("Invalid -lease_duration: %v, must be at least 3s", *leaseDuration)
+	}
+	etcd.SetLogger(util.NewLogger("etcd "))
 
-	controllerManager.Run(10 * time.Second)
+	if len(etcdServerList) > 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			glog.Fatalf("Couldn't get the host name: %v", err)
+		}
+		id := fmt.Sprintf("%s-%d", hostname, os.Getpid())
+		elector := election.NewEtcdMasterElector(etcd.NewClient(etcdServerList), *leaseDuration)
+		glog.Infof("Waiting to be elected as %s", id)
+		lost := election.Lead(elector, leasePath, id)
+		glog.Infof("Elected as %s", id)
+		go func() {
+			<-lost
+			// The managers can't be stopped, so leave acting to the new leader.
+			glog.Fatalf("No longer elected, exiting")
+		}()
+	}
+
+	kubeClient := client.New("http://"+*master, nil)
+	controllerManager := controller.NewReplicationManager(kubeClient)
+	controllerManager.SetScaleDownOrder(order)
+	controllerManager.SetSyncWorkers(*syncWorkers)
+	// Controllers are synced as soon as they or their pods change. Every period,
+	// controllers and pods are listed again and every controller is synced, which
+	// catches changes the watches missed.
+	controllerManager.Run(5 * time.Minute)
+
+	daemonManager := controller.NewDaemonManager(kubeClient)
+	daemonManager.Run(5 * time.Minute)
+
+	if len(skyDNSEtcdServers) > 0 {
+		dnsBridge := dns.NewSkyDNSBridge(kubeClient, etcd.NewClient(skyDNSEtcdServers), *clusterDomain)
+		dnsBridge.Run(10 * time.Second)
+	}
 	select {}
 }
@@ -15,7 +15,9 @@ limitations under the License.
 */
 
 // A basic integration test for the service.
-// Assumes that there is a pre-existing etcd server running on localhost.
+// Assumes that there is a pre-existing etcd server running on localhost. Only
+// the container manifests the kubelets read and operation records are kept
+// there; the other objects are kept in memory.
 package main
 
 import (
@@ -36,6 +38,9 @@ import (
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/kubelet/config"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/master"
+	etcdregistry "github.com/GoogleCloudPlatform/kubernetes/pkg/registry/etcd"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/storage"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/wait"
 	"github.com/coreos/go-etcd/etcd"
@@ -81,7 +86,7 @@ func (h *delegateHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
 }
 
-func startComponents(manifestURL string) (apiServerURL string) {
+func startComponents(manifestURL string, registryStorage *etcdregistry.RegistryStorage) (apiServerURL string) {
 	// Setup
 	servers := []string{"http://localhost:4001"}
 	glog.Infof("Creating etcd client pointing to %v", servers)
@@ -97,14 +102,22 @@ func startComponents(manifestURL string) (apiServerURL string) {
 	cl.Sync = true
 
 	// Master
-	m := master.New(&master.Config{
-		Client:        cl,
-		EtcdServers:   servers,
-		Minions:       machineList,
-		PodInfoGetter: fakePodInfoGetter{},
+	m, err := master.New(&master.Config{
+		Client:          cl,
+		EtcdServers:     servers,
+		Minions:         machineList,
+		PodInfoGetter:   fakePodInfoGetter{},
+		RegistryStorage: registryStorage,
 	})
-	storage, codec := m.API_v1beta1()
-	handler.delegate = apiserver.Handle(storage, codec, "/api/v1beta1")
+	if err != nil {
+		glog.Fatalf("Unable to start the master: %v", err)
+	}
+	v1beta1Storage, v1beta1Codec := m.API_v1beta1()
+	v1beta2Storage, v1beta2Codec := m.API_v1beta2()
+	handler.delegate = apiserver.HandleVersions(map[string]*apiserver.APIGroup{
+		"v1beta1": apiserver.NewAPIGroupWithOperations(v1beta1Storage, v1beta1Codec, m.Operations()),
+		"v1beta2": apiserver.NewAPIGroupWithOperations(v1beta2Storage, v1beta2Codec, m.Operations()),
+	}, "/api")
 
 	controllerManager := controller.NewReplicationManager(cl)
 
@@ -136,6 +149,19 @@ func startComponents(manifestURL string) (apiServerURL string) {
 	return apiServer.URL
 }
 
+// newRegistryStorage returns backends keeping objects in memory, except for
+// the container manifests, which the kubelets watch in etcd.
+func newRegistryStorage() etcdregistry.RegistryStorage {
+	s := etcdregistry.NewMemoryRegistryStorage()
+	helper := &tools.EtcdHelper{
+		Client:            etcd.NewClient([]string{"http://localhost:4001"}),
+		Codec:             api.Codec,
+		ResourceVersioner: api.ResourceVersioner,
+	}
+	s.Manifests = etcdregistry.NewEtcdRegistryStorage(helper).Manifests
+	return s
+}
+
 // podsOnMinions returns true when all of the selected pods exist on a minion.
 func podsOnMinions(c *client.Client, pods api.PodList) wait.ConditionFunc {
 	podInfo := fakePodInfoGetter{}
@@ -279,7 +305,8 @@ func main() {
 
 	manifestURL := ServeCachedManifestFile()
 
-	apiServerURL := startComponents(manifestURL)
+	registryStorage := newRegistryStorage()
+	apiServerURL := startComponents(manifestURL, &registryStorage)
 
 	// Ok. we're good to go.
 	glog.Infof("API Server started on %s", apiServerURL)
@@ -287,6 +314,9 @@ func main() {
 	time.Sleep(time.Second * 10)
 
 	kubeClient := client.New(apiServerURL, nil)
+	// Writing the pods of the replication controller has to get past
+	// concurrent writers.
+	registryStorage.Pods.(*storage.Memory).InjectConflicts(5)
 
 	// Run tests in parallel
 	testFuncs := []testFunc{
@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
+	"os/signal"
 	"reflect"
 	"sort"
 	"strconv"
@@ -60,8 +61,11 @@ var (
 var parser = kubecfg.NewParser(map[string]interface{}{
 	"pods":                   api.Pod{},
 	"services":               api.Service{},
+	"endpoints":              api.Endpoints{},
 	"replicationControllers": api.ReplicationController{},
+	"daemonControllers":      api.DaemonController{},
 	"minions":                api.Minion{},
+	"events":                 api.Event{},
 })
 
 func usage() {
@@ -72,6 +76,7 @@ func usage() {
 
   Manage replication controllers:
   kubecfg [OPTIONS] stop|rm|rollingupdate <controller>
+  kubecfg [OPTIONS] rollingupdate <controller> <new controller>
   kubecfg [OPTIONS] run <image> <replicas> <controller>
   kubecfg [OPTIONS] resize <controller> <replicas>
 
@@ -280,6 +285,11 @@ func executeAPIRequest(method string, s *kube_client.Client) bool {
 	result := r.Do()
 	obj, err := result.Get()
 	if err != nil {
+		if statusErr, ok := err.(*kube_client.StatusErr); ok && statusErr.Status.Details != nil {
+			for _, cause := range statusErr.Status.Details.Causes {
+				glog.Errorf("Invalid %s: %s", cause.Field, cause.Message)
+			}
+		}
 		glog.Fatalf("Got request error: %v\n", err)
 		return false
 	}
@@ -338,6 +348,18 @@ func executeControllerRequest(method string, c *kube_client.Client) bool {
 	case "rm":
 		err = kubecfg.DeleteController(parseController(), c)
 	case "rollingupdate":
+		if len(flag.Args()) == 3 {
+			// Abort on an interrupt; running the update again resumes it.
+			stop := make(chan struct{})
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			go func() {
+				<-interrupt
+				close(stop)
+			}()
+			err = kubecfg.RollingUpdate(flag.Arg(1), flag.Arg(2), c, *updatePeriod, stop)
+			break
+		}
 		err = kubecfg.Update(parseController(), c, *updatePeriod)
 	case "run":
 		if len(flag.Args()) != 4 {
@@ -22,19 +22,24 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/health"
 	_ "github.com/GoogleCloudPlatform/kubernetes/pkg/healthz"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/kubelet"
 	kconfig "github.com/GoogleCloudPlatform/kubernetes/pkg/kubelet/config"
-	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	verflag "github.com/GoogleCloudPlatform/kubernetes/pkg/version/flag"
 	"github.com/coreos/go-etcd/etcd"
@@ -46,22 +51,63 @@ import (
 const defaultRootDir = "/var/lib/kubelet"
 
 var (
-	config             = flag.String("config", "", "Path to the config file or directory of files")
-	syncFrequency      = flag.Duration("sync_frequency", 10*time.Second, "Max period between synchronizing running containers and config")
-	fileCheckFrequency = flag.Duration("file_check_frequency", 20*time.Second, "Duration between checking config files for new data")
-	httpCheckFrequency = flag.Duration("http_check_frequency", 20*time.Second, "Duration between checking http for new data")
-	manifestURL        = flag.String("manifest_url", "", "URL for accessing the container manifest")
-	enableServer       = flag.Bool("enable_server", true, "Enable the info server")
-	address            = flag.String("address", "127.0.0.1", "The address for the info server to serve on (set to 0.0.0.0 or \"\" for all interfaces)")
-	port               = flag.Uint("port", 10250, "The port for the info server to serve on")
-	hostnameOverride   = flag.String("hostname_override", "", "If non-empty, will use this string as identification instead of the actual hostname.")
-	dockerEndpoint     = flag.String("docker_endpoint", "", "If non-empty, use this for the docker endpoint to communicate with")
-	etcdServerList     util.StringList
-	rootDirectory      = flag.String("root_dir", defaultRootDir, "Directory path for managing kubelet files (volume mounts,etc).")
+	config                  = flag.String("config", "", "Path to the config file or directory of files")
+	syncFrequency           = flag.Duration("sync_frequency", 10*time.Second, "Max period between synchronizing running containers and config")
+	fileCheckFrequency      = flag.Duration("file_check_frequency", 20*time.Second, "Duration between checking config files for new data")
+	httpCheckFrequency      = flag.Duration("http_check_frequency", 20*time.Second, "Duration between checking http for new data")
+	apiserverCheckFrequency = flag.Duration("apiserver_check_frequency", 20*time.Second, "Duration between checking the apiserver for pods bound to this host. Only used with -api_servers and without -etcd_servers")
+	serviceCheckFrequency   = flag.Duration("service_check_frequency", 10*time.Second, "Duration between listing the services whose environment variables containers get. Only used with -api_servers")
+	manifestURL             = flag.String("manifest_url", "", "URL for accessing the container manifest")
+	enableServer            = flag.Bool("enable_server", true, "Enable the info server")
+	address                 = flag.String("address", "127.0.0.1", "The address for the info server to serve on (set to 0.0.0.0 or \"\" for all interfaces)")
+	port                    = flag.Uint("port", 10250, "The port for the info server to serve on")
+	readOnlyPort            = flag.Uint("read_only_port", 10255, "The port for the read-only info server to serve on, which only exposes /pods and /podInfo without container commands and environments, /stats, /spec and /healthz (set to 0 to disable)")
+	hostnameOverride        = flag.String("hostname_override", "", "If non-empty, will use this string as identification instead of the actual hostname.")
+	dockerEndpoint          = flag.String("docker_endpoint", "", "If non-empty, use this for the docker endpoint to communicate with")
+	heartbeatFrequency      = flag.Duration("heartbeat_frequency", 10*time.Second, "Duration between registrations with the master, which serve as heartbeats and report the status of the minion. Only used with -api_servers")
+	statusReportFrequency   = flag.Duration("status_report_frequency", 10*time.Second, "Duration between reports of the status of pods to the master. Only used with -api_servers")
+	etcdServerList          util.StringList
+	apiServerList           util.StringList
+	dockerExecBinary        = flag.String("docker_exec_binary", "", "If non-empty, the docker client used to run the commands of exec liveness probes with 'docker exec', which needs docker 1.3 or later. By default nsinit is used.")
+	rootDirectory           = flag.String("root_dir", defaultRootDir, "Directory path for managing kubelet files (volume mounts,etc). Kubelets on the same host need different ones.")
+	dockerRoot              = flag.String("docker_root", "/var/lib/docker", "Directory docker keeps its images in. The disk usage of its file system drives image garbage collection.")
+	imageGCHighThreshold    = flag.Int("image_gc_high_threshold", 90, "The percentage of disk usage above which unused images are removed.")
+	imageGCLowThreshold     = flag.Int("image_gc_low_threshold", 80, "The percentage of disk usage image garbage collection tries to get back to.")
+	imageGCFrequency        = flag.Duration("image_gc_frequency", 5*time.Minute, "Duration between checks of the disk usage of images.")
+	maxDeadContainersPerPod = flag.Int("max_dead_containers_per_pod", 5, "The number of exited containers kept for each pod, so their logs can be looked at.")
+	maxDeadPerDeletedPod    = flag.Int("max_dead_containers_per_deleted_pod", 0, "The number of exited containers kept for each pod which was deleted.")
+	containerGCFrequency    = flag.Duration("container_gc_frequency", time.Minute, "Duration between removals of exited containers beyond -max_dead_containers_per_pod and -max_dead_containers_per_deleted_pod.")
+	orphanCleanupFrequency  = flag.Duration("orphan_cleanup_frequency", time.Minute, "Duration between tear downs of the volumes and removals of the directories of pods which no longer run here.")
+	oomScoreAdj             = flag.Int("oom_score_adj", kubelet.KubeletOOMScoreAdj, "The oom_score_adj of the kubelet process, between -1000 and 1000. The lower, the later the kernel kills it when the machine runs out of memory.")
+	dockerOOMScoreAdj       = flag.Int("docker_oom_score_adj", kubelet.DockerOOMScoreAdj, "The oom_score_adj of the docker daemon, between -1000 and 1000.")
+	dockerPidFile           = flag.String("docker_pidfile", "/var/run/docker.pid", "The file docker writes its pid to. The oom_score_adj of docker is only set if it exists.")
+	dockerTimeout           = flag.Duration("docker_timeout", 2*time.Minute, "Duration after which docker operations are given up on. Image pulls and logs are not limited.")
+	clusterDNS              = flag.String("cluster_dns", "", "If non-empty, the IP of the DNS server containers use instead of the host's.")
+	clusterDomain           = flag.String("cluster_domain", "", "If non-empty, the domain of the cluster, which containers search before the host's search domains.")
+	minionLabels            = flag.String("minion_labels", "", "Labels to register this minion with, as comma separated key=value pairs, e.g. disk=ssd,zone=a. Pods whose nodeSelector asks for labels are only scheduled onto minions which have them.")
+	servicePortals          = flag.Bool("service_portals", true, "Whether containers find services at their portal IP. Set to false if the proxy on this minion runs without iptables, as it logs at start, since portal IPs don't reach it then; containers find services at this host instead.")
+	networkPlugin           = flag.String("network_plugin", "", "If non-empty, the executable which sets up and tears down the network of pods. It is run as '<network_plugin> setup|teardown <namespace> <name> <container ID> <netns path>'.")
 )
 
 func init() {
 	flag.Var(&etcdServerList, "etcd_servers", "List of etcd servers to watch (http://ip:port), comma separated")
+	flag.Var(&apiServerList, "api_servers", "List of Kubernetes API servers (http://ip:port) to register this minion with, comma separated. Only the first is used for now. Without -etcd_servers, the pods bound to this minion are read from it too. Containers get the environment variables of the services it lists.")
+}
+
+// parseMinionLabels parses comma separated key=value pairs.
+func parseMinionLabels(value string) (map[string]string, error) {
+	labels := map[string]string{}
+	if value == "" {
+		return labels, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%q is not a key=value pair", pair)
+		}
+		labels[parts[0]] = parts[1]
+	}
+	return labels, nil
 }
 
 func getDockerEndpoint() string {
@@ -92,6 +138,24 @@ func getHostname() string {
 	return strings.TrimSpace(string(hostname))
 }
 
+// applyDockerOOMScoreAdj sets the oom_score_adj of the docker daemon whose pid
+// is in -docker_pidfile.
+func applyDockerOOMScoreAdj() {
+	data, err := ioutil.ReadFile(*dockerPidFile)
+	if err != nil {
+		glog.V(1).Infof("Not setting oom_score_adj of docker: %v", err)
+		return
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		glog.Errorf("Invalid pid in %s: %v", *dockerPidFile, err)
+		return
+	}
+	if err := kubelet.ApplyOOMScoreAdj(pid, *dockerOOMScoreAdj); err != nil {
+		glog.Errorf("Failed to set oom_score_adj of docker: %v", err)
+	}
+}
+
 func main() {
 	flag.Parse()
 	util.InitLogs()
@@ -100,12 +164,19 @@ func main() {
 
 	verflag.PrintAndExitIfRequested()
 
+	if err := kubelet.ApplyOOMScoreAdj(0, *oomScoreAdj); err != nil {
+		glog.Warningf("Failed to set oom_score_adj of the kubelet: %v", err)
+	}
+	// docker gets a new pid when it restarts.
+	go util.Forever(applyDockerOOMScoreAdj, time.Minute)
+
 	etcd.SetLogger(util.NewLogger("etcd "))
 
-	dockerClient, err := docker.NewClient(getDockerEndpoint())
+	rawDockerClient, err := docker.NewClient(getDockerEndpoint())
 	if err != nil {
 		glog.Fatal("Couldn't connect to docker.")
 	}
+	dockerClient := kubelet.NewTimeoutDockerClient(rawDockerClient, *dockerTimeout)
 
 	cadvisorClient, err := cadvisor.NewClient("http://127.0.0.1:4194")
 	if err != nil {
@@ -118,7 +189,6 @@ func main() {
 		glog.Fatal("Invalid root directory path.")
 	}
 	*rootDirectory = path.Clean(*rootDirectory)
-	os.MkdirAll(*rootDirectory, 0750)
 
 	// source of all configuration
 	cfg := kconfig.NewPodConfig(kconfig.PodConfigNotificationSnapshotAndUpdates)
@@ -134,36 +204,136 @@ func main() {
 	}
 
 	// define etcd config source and initialize etcd client
-	var etcdClient tools.EtcdClient
 	if len(etcdServerList) > 0 {
 		glog.Infof("Watching for etcd configs at %v", etcdServerList)
-		etcdClient = etcd.NewClient(etcdServerList)
+		etcdClient := etcd.NewClient(etcdServerList)
+		if len(apiServerList) == 0 {
+			// Without an apiserver to report to, the master never learns that pods passed
+			// their readiness probes.
+			glog.Warningf("Refusing pods with readiness probes from etcd, since there is no -api_servers to report their readiness to.")
+			cfg.AddValidation("etcd", kubelet.ValidateUnreportedPod)
+		}
 		kconfig.NewSourceEtcd(kconfig.EtcdKeyForHost(hostname), etcdClient, cfg.Channel("etcd"))
+	} else if len(apiServerList) > 0 {
+		// without etcd, the pods bound to this host come from the apiserver. They go by the
+		// same namespace as pods from etcd, which the kubelet takes for the pods the master
+		// scheduled, e.g. when reporting their status.
+		kconfig.NewSourceApiserver(client.New(apiServerList[0], nil), hostname, *apiserverCheckFrequency, cfg.Channel("etcd"))
 	}
 
-	// TODO: block until all sources have delivered at least one update to the channel, or break the sync loop
-	// up into "per source" synchronizations
+	// the kubelet server is a source of its own, which has no pods until some are posted to it
+	serverUpdates := cfg.Channel("server")
+
+	// The kubelet kills no containers until every source has delivered its pods, so that
+	// pods of a slow source survive a restart of the kubelet.
+
+	var dnsIP net.IP
+	if *clusterDNS != "" {
+		if dnsIP = net.ParseIP(*clusterDNS); dnsIP == nil {
+			glog.Fatalf("Invalid -cluster_dns %q, must be an IP.", *clusterDNS)
+		}
+	}
 
+	var runner kubelet.ContainerCommandRunner
+	if len(*dockerExecBinary) > 0 {
+		runner = kubelet.NewDockerExecCommandRunner(*dockerExecBinary)
+	}
+	var netPlugin kubelet.NetworkPlugin
+	if len(*networkPlugin) > 0 {
+		netPlugin = kubelet.NewExecNetworkPlugin(*networkPlugin)
+	}
+	labels, err := parseMinionLabels(*minionLabels)
+	if err != nil {
+		glog.Fatalf("Invalid -minion_labels: %v", err)
+	}
+	// Containers get the environment variables of services from the kubelet only if there
+	// is an apiserver to list them from, and otherwise only those the master put in their
+	// manifests. The services are listed periodically rather than for every container started.
+	var serviceLister kubelet.ServiceLister
+	if len(apiServerList) > 0 {
+		serviceLister = kubelet.NewCachedServiceLister(client.New(apiServerList[0], nil), *serviceCheckFrequency)
+	}
+	// Events are always logged, and also sent to the apiserver if there is one.
+	record.StartLogging(glog.Infof)
 	k := kubelet.NewMainKubelet(
 		getHostname(),
 		dockerClient,
 		cadvisorClient,
-		etcdClient,
 		*rootDirectory,
-		*syncFrequency)
+		*syncFrequency,
+		runner,
+		cfg.SeenAllSources,
+		dnsIP,
+		*clusterDomain,
+		netPlugin,
+		labels,
+		serviceLister,
+		!*servicePortals)
+	if err := k.SetupDataDirs(); err != nil {
+		glog.Fatalf("Failed to set up the root directory: %v", err)
+	}
 
 	health.AddHealthChecker("exec", health.NewExecHealthChecker(k))
-	health.AddHealthChecker("http", health.NewHTTPHealthChecker(&http.Client{}))
+	health.AddHealthChecker("http", health.NewHTTPHealthChecker(&http.Client{Timeout: 10 * time.Second}))
 	health.AddHealthChecker("tcp", &health.TCPHealthChecker{})
 
 	// start the kubelet
 	go util.Forever(func() { k.Run(cfg.Updates()) }, 0)
 
+	imageGC, err := kubelet.NewImageGarbageCollector(dockerClient, *dockerRoot, kubelet.ImageGCPolicy{
+		HighThresholdPercent: *imageGCHighThreshold,
+		LowThresholdPercent:  *imageGCLowThreshold,
+	})
+	if err != nil {
+		glog.Fatalf("Invalid image garbage collection policy: %v", err)
+	}
+	go util.Forever(func() {
+		if err := imageGC.GarbageCollect(); err != nil {
+			glog.Errorf("Image garbage collection: %v", err)
+		}
+	}, *imageGCFrequency)
+
+	containerGC, err := kubelet.NewContainerGarbageCollector(dockerClient, kubelet.ContainerGCPolicy{
+		MaxDeadPerPod:        *maxDeadContainersPerPod,
+		MaxDeadPerDeletedPod: *maxDeadPerDeletedPod,
+	}, k.DesiredPods)
+	if err != nil {
+		glog.Fatalf("Invalid container garbage collection policy: %v", err)
+	}
+	go util.Forever(func() {
+		if err := containerGC.GarbageCollect(); err != nil {
+			glog.Errorf("Container garbage collection: %v", err)
+		}
+	}, *containerGCFrequency)
+
+	// Volumes and directories of pods deleted while the kubelet was down are
+	// cleaned up once every source has delivered its pods.
+	go util.Forever(func() {
+		if err := k.CleanupOrphans(); err != nil {
+			glog.Errorf("Orphan cleanup: %v", err)
+		}
+	}, *orphanCleanupFrequency)
+
+	// register with the master, and keep doing so as a heartbeat
+	if len(apiServerList) > 0 {
+		glog.Infof("Registering minion %s with %v", hostname, apiServerList)
+		apiClient := client.New(apiServerList[0], nil)
+		record.StartRecording(apiClient, fmt.Sprintf("kubelet %s", hostname))
+		go util.Forever(func() { k.RegisterMinion(apiClient) }, *heartbeatFrequency)
+		go util.Forever(func() { k.ReportPodStatus(apiClient) }, *statusReportFrequency)
+	}
+
 	// start the kubelet server
+	serverUpdates <- kubelet.PodUpdate{Op: kubelet.SET}
 	if *enableServer {
 		go util.Forever(func() {
-			kubelet.ListenAndServeKubeletServer(k, cfg.Channel("http"), *address, *port)
+			kubelet.ListenAndServeKubeletServer(k, serverUpdates, *address, *port)
 		}, 0)
+		if *readOnlyPort > 0 {
+			go util.Forever(func() {
+				kubelet.ListenAndServeKubeletReadOnlyServer(k, *address, *readOnlyPort)
+			}, 0)
+		}
 	}
 
 	// runs forever
@@ -18,22 +18,36 @@ package main
 
 import (
 	"flag"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/healthz"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/proxy"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/proxy/config"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/iptables"
 	verflag "github.com/GoogleCloudPlatform/kubernetes/pkg/version/flag"
 	"github.com/coreos/go-etcd/etcd"
 	"github.com/golang/glog"
 )
 
 var (
-	configFile     = flag.String("configfile", "/tmp/proxy_config", "Configuration file for the proxy")
-	etcdServerList util.StringList
+	configFile         = flag.String("configfile", "/tmp/proxy_config", "Configuration file for the proxy")
+	bindAddress        = flag.String("bind_address", "0.0.0.0", "The address for the proxy to listen on, besides the public IPs of services. 0.0.0.0 listens on all addresses")
+	drainPeriod        = flag.Duration("drain_period", 30*time.Second, "How long the connections to an endpoint which went away get to finish before they are closed")
+	healthCheckPeriod  = flag.Duration("health_check_period", 0, "How often to probe the endpoints of services with a TCP connect, leaving out the ones which fail until they pass again (set to 0 to disable)")
+	healthCheckTimeout = flag.Duration("health_check_timeout", time.Second, "How long an endpoint probe gets to connect")
+	metricsPort        = flag.Int("metrics_port", 10249, "The port on localhost to serve the metrics of services on, at /metrics (set to 0 to disable)")
+	etcdServerList     util.StringList
+	portalPortRange    util.PortRange
 )
 
 func init() {
 	flag.Var(&etcdServerList, "etcd_servers", "List of etcd servers to watch (http://ip:port), comma separated")
+	flag.Var(&portalPortRange, "portal_port_range", "If set, the range of ports (e.g. 40000-40999) to listen on for services with a portal IP, instead of any free port. Keep it apart from the -service_port_range of the apiserver")
 }
 
 func main() {
@@ -62,12 +76,37 @@ func main() {
 		serviceConfig.Channel("file"),
 		endpointsConfig.Channel("file"))
 
-	loadBalancer := proxy.NewLoadBalancerRR()
-	proxier := proxy.NewProxier(loadBalancer)
+	loadBalancer := proxy.NewBalancer()
+	drainer := proxy.NewDrainer(*drainPeriod)
+	var portRange *util.PortRange
+	if portalPortRange.Size > 0 {
+		portRange = &portalPortRange
+	}
+	proxier := proxy.NewProxier(loadBalancer, net.ParseIP(*bindAddress), iptables.New(), drainer, portRange)
 	// Wire proxier to handle changes to services
 	serviceConfig.RegisterHandler(proxier)
 	// And wire loadBalancer to handle changes to endpoints to services
 	endpointsConfig.RegisterHandler(loadBalancer)
+	// And drainer, to close the connections to endpoints which went away
+	endpointsConfig.RegisterHandler(drainer)
+
+	if *healthCheckPeriod != 0 {
+		healthChecker := proxy.NewHealthChecker(*healthCheckTimeout)
+		loadBalancer.SetHealthChecker(healthChecker)
+		endpointsConfig.RegisterHandler(healthChecker)
+		serviceConfig.RegisterHandler(healthChecker.ServiceHandler())
+		healthChecker.Run(*healthCheckPeriod)
+	}
+
+	if *metricsPort != 0 {
+		mux := http.NewServeMux()
+		healthz.InstallHandler(mux)
+		metrics.InstallHandler(mux)
+		go util.Forever(func() {
+			address := net.JoinHostPort("127.0.0.1", strconv.Itoa(*metricsPort))
+			glog.Errorf("Unable to serve metrics: %v", http.ListenAndServe(address, mux))
+		}, 5*time.Second)
+	}
 
 	// Just loop forever for now...
 	select {}
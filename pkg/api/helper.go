@@ -21,6 +21,7 @@ import (
 	"reflect"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/v1beta1"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/v1beta2"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/conversion"
 	"gopkg.in/v1/yaml"
 )
@@ -43,6 +44,9 @@ type resourceVersioner interface {
 var Codec codec
 var ResourceVersioner resourceVersioner
 
+// Versions lists the external API versions, oldest first.
+var Versions = []string{"v1beta1", "v1beta2"}
+
 var conversionScheme *conversion.Scheme
 
 func init() {
@@ -55,6 +59,8 @@ func init() {
 		Pod{},
 		ReplicationControllerList{},
 		ReplicationController{},
+		DaemonControllerList{},
+		DaemonController{},
 		ServiceList{},
 		Service{},
 		MinionList{},
@@ -64,13 +70,20 @@ func init() {
 		ServerOp{},
 		ContainerManifestList{},
 		Endpoints{},
+		EndpointsList{},
 		Binding{},
+		Resize{},
+		PodStatusReport{},
+		Event{},
+		EventList{},
 	)
 	AddKnownTypes("v1beta1",
 		v1beta1.PodList{},
 		v1beta1.Pod{},
 		v1beta1.ReplicationControllerList{},
 		v1beta1.ReplicationController{},
+		v1beta1.DaemonControllerList{},
+		v1beta1.DaemonController{},
 		v1beta1.ServiceList{},
 		v1beta1.Service{},
 		v1beta1.MinionList{},
@@ -80,7 +93,35 @@ func init() {
 		v1beta1.ServerOp{},
 		v1beta1.ContainerManifestList{},
 		v1beta1.Endpoints{},
+		v1beta1.EndpointsList{},
 		v1beta1.Binding{},
+		v1beta1.Resize{},
+		v1beta1.PodStatusReport{},
+		v1beta1.Event{},
+		v1beta1.EventList{},
+	)
+	AddKnownTypes("v1beta2",
+		v1beta2.PodList{},
+		v1beta2.Pod{},
+		v1beta2.ReplicationControllerList{},
+		v1beta2.ReplicationController{},
+		v1beta2.DaemonControllerList{},
+		v1beta2.DaemonController{},
+		v1beta2.ServiceList{},
+		v1beta2.Service{},
+		v1beta2.MinionList{},
+		v1beta2.Minion{},
+		v1beta2.Status{},
+		v1beta2.ServerOpList{},
+		v1beta2.ServerOp{},
+		v1beta2.ContainerManifestList{},
+		v1beta2.Endpoints{},
+		v1beta2.EndpointsList{},
+		v1beta2.Binding{},
+		v1beta2.Resize{},
+		v1beta2.PodStatusReport{},
+		v1beta2.Event{},
+		v1beta2.EventList{},
 	)
 
 	// TODO: when we get more of this stuff, move to its own file. This is not a
@@ -220,15 +261,44 @@ func EncodeOrDie(obj interface{}) string {
 // objects, whether they be in our storage layer (e.g., etcd), or in user's
 // config files.
 //
-// TODO/next steps: When we add our second versioned type, this package will
-// need a version of Encode that lets you choose the wire version. A configurable
-// default will be needed, to allow operating in clusters that haven't yet
-// upgraded.
-//
 func Encode(obj interface{}) (data []byte, err error) {
 	return conversionScheme.Encode(obj)
 }
 
+// EncodeToVersion is like Encode, but you may choose the wire version.
+func EncodeToVersion(obj interface{}, version string) (data []byte, err error) {
+	return conversionScheme.EncodeToVersion(obj, version)
+}
+
+// versionCodec is a codec which encodes objects in a fixed external version.
+// Decoding understands every registered version.
+type versionCodec struct {
+	version string
+}
+
+func (c versionCodec) Encode(obj interface{}) ([]byte, error) {
+	return conversionScheme.EncodeToVersion(obj, c.version)
+}
+
+func (c versionCodec) Decode(data []byte) (interface{}, error) {
+	return conversionScheme.Decode(data)
+}
+
+func (c versionCodec) DecodeInto(data []byte, obj interface{}) error {
+	return conversionScheme.DecodeInto(data, obj)
+}
+
+// CodecForVersion returns a codec which encodes objects in the given external
+// version, or an error if the version is unknown.
+func CodecForVersion(version string) (codec, error) {
+	for _, v := range Versions {
+		if v == version {
+			return versionCodec{version}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown API version %q", version)
+}
+
 // Ensures that obj is a pointer of some sort. Returns a reflect.Value of the
 // dereferenced pointer, ensuring that it is settable/addressable.
 // Returns an error if this is not possible.
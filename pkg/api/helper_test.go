@@ -102,7 +102,7 @@ func objDiff(a, b interface{}) string {
 	)
 }
 
-func runTest(t *testing.T, source interface{}) {
+func runTest(t *testing.T, version string, source interface{}) {
 	name := reflect.TypeOf(source).Elem().Name()
 	apiObjectFuzzer.Fuzz(source)
 	j, err := FindJSONBase(source)
@@ -112,7 +112,7 @@ func runTest(t *testing.T, source interface{}) {
 	j.SetKind("")
 	j.SetAPIVersion("")
 
-	data, err := Encode(source)
+	data, err := EncodeToVersion(source, version)
 	if err != nil {
 		t.Errorf("%v: %v (%#v)", name, err, source)
 		return
@@ -150,6 +150,8 @@ func TestTypes(t *testing.T) {
 		&Service{},
 		&ReplicationControllerList{},
 		&ReplicationController{},
+		&DaemonControllerList{},
+		&DaemonController{},
 		&MinionList{},
 		&Minion{},
 		&Status{},
@@ -158,12 +160,46 @@ func TestTypes(t *testing.T) {
 		&ContainerManifestList{},
 		&Endpoints{},
 		&Binding{},
+		&Resize{},
+	}
+	for _, version := range Versions {
+		for _, item := range table {
+			// Try a few times, since runTest uses random values.
+			for i := 0; i < *fuzzIters; i++ {
+				runTest(t, version, item)
+			}
+		}
+	}
+}
+
+func TestCodecForVersion(t *testing.T) {
+	pod := &Pod{
+		JSONBase:     JSONBase{ID: "foo"},
+		DesiredState: PodState{Manifest: ContainerManifest{Containers: []Container{{Env: []EnvVar{{Name: "a", Value: "b"}}}}}},
 	}
-	for _, item := range table {
-		// Try a few times, since runTest uses random values.
-		for i := 0; i < *fuzzIters; i++ {
-			runTest(t, item)
+	for _, version := range Versions {
+		codec, err := CodecForVersion(version)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
 		}
+		data, err := codec.Encode(pod)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		dataVersion, kind, err := VersionAndKind(data)
+		if err != nil || dataVersion != version || kind != "Pod" {
+			t.Errorf("expected %s Pod, got %s %s (%v)", version, dataVersion, kind, err)
+		}
+		obj, err := codec.Decode(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(pod, obj) {
+			t.Errorf("%s: diff: %v", version, objDiff(pod, obj))
+		}
+	}
+	if _, err := CodecForVersion("v0"); err == nil {
+		t.Errorf("unexpected non-error for an unknown version")
 	}
 }
 
@@ -56,6 +56,12 @@ type ContainerManifest struct {
 	ID         string      `yaml:"id" json:"id"`
 	Volumes    []Volume    `yaml:"volumes" json:"volumes"`
 	Containers []Container `yaml:"containers" json:"containers"`
+	// Optional: Defaults to "RestartAlways". The master fills this in from the
+	// RestartPolicy of the pod.
+	RestartPolicy RestartPolicy `yaml:"restartPolicy,omitempty" json:"restartPolicy,omitempty"`
+	// Optional: Seconds the containers of the pod get to shut down after being
+	// sent SIGTERM, before they are killed. Defaults to 10; 0 kills them at once.
+	TerminationGracePeriodSeconds *int64 `yaml:"terminationGracePeriodSeconds,omitempty" json:"terminationGracePeriodSeconds,omitempty"`
 }
 
 // ContainerManifestList is used to communicate container manifests to kubelet.
@@ -85,6 +91,11 @@ type VolumeSource struct {
 	HostDirectory *HostDirectory `yaml:"hostDir" json:"hostDir"`
 	// EmptyDirectory represents a temporary directory that shares a pod's lifetime.
 	EmptyDirectory *EmptyDirectory `yaml:"emptyDir" json:"emptyDir"`
+	// GCEPersistentDisk represents a GCE Disk resource that is attached to the
+	// kubelet's host machine and then exposed to the pod.
+	GCEPersistentDisk *GCEPersistentDisk `yaml:"persistentDisk" json:"persistentDisk"`
+	// NFS represents an NFS export mounted on the host and exposed to the pod.
+	NFS *NFSMount `yaml:"nfs" json:"nfs"`
 }
 
 // Bare host directory volume.
@@ -94,6 +105,46 @@ type HostDirectory struct {
 
 type EmptyDirectory struct{}
 
+// GCEPersistentDisk represents a Persistent Disk resource in Google Compute Engine.
+//
+// A GCE PD must exist before mounting to a container. A disk without a
+// filesystem is formatted with FSType when it is first mounted read/write.
+// The disk must also be in the same GCE project and zone as the kubelet.
+// A GCE PD can only be mounted as read/write once, but may be mounted
+// read-only by many pods at once.
+type GCEPersistentDisk struct {
+	// Unique name of the PD resource. Used to identify the disk in GCE.
+	// Must be a DNS label, as GCE disk names are.
+	PDName string `yaml:"pdName" json:"pdName"`
+	// Required: Filesystem type to mount.
+	// Must be a filesystem type supported by the host operating system.
+	// Ex. "ext4", "xfs", "ntfs"
+	// TODO: how do we prevent errors in the filesystem from compromising the machine
+	FSType string `yaml:"fsType,omitempty" json:"fsType,omitempty"`
+	// Optional: Partition on the disk to mount.
+	// If omitted, kubelet will attempt to mount the device name.
+	// Ex. For /dev/sda1, this field is "1", for /dev/sda, this field is 0 or empty.
+	Partition int `yaml:"partition,omitempty" json:"partition,omitempty"`
+	// Optional: Defaults to false (read/write). ReadOnly here will force
+	// the ReadOnly setting in VolumeMounts.
+	ReadOnly bool `yaml:"readOnly,omitempty" json:"readOnly,omitempty"`
+}
+
+// NFSMount represents an NFS export mounted for the lifetime of a pod.
+type NFSMount struct {
+	// Server is the hostname or IP address of the NFS server.
+	Server string `yaml:"server" json:"server"`
+	// Path is the path exported by the NFS server.
+	Path string `yaml:"path" json:"path"`
+	// Optional: Defaults to false (read/write). ReadOnly here will force
+	// the NFS export to be mounted with read-only permissions.
+	ReadOnly bool `yaml:"readOnly,omitempty" json:"readOnly,omitempty"`
+	// Optional: Options are further mount options, e.g. "vers=3" or "soft",
+	// passed to mount(8) as they are. Whether the export is read-only is set
+	// by ReadOnly alone, so "ro" and "rw" aren't allowed here.
+	Options []string `yaml:"options,omitempty" json:"options,omitempty"`
+}
+
 // Port represents a network port in a single container
 type Port struct {
 	// Optional: If specified, this must be a DNS_LABEL.  Each named port
@@ -170,6 +221,9 @@ type LivenessProbe struct {
 	Exec *ExecProbe `yaml:"exec,omitempty" json:"exec,omitempty"`
 	// Length of time before health checking is activated.  In seconds.
 	InitialDelaySeconds int64 `yaml:"initialDelaySeconds,omitempty" json:"initialDelaySeconds,omitempty"`
+	// Optional: Number of consecutive failed probes after which the container is
+	// restarted. Defaults to 1.
+	FailureThreshold int `yaml:"failureThreshold,omitempty" json:"failureThreshold,omitempty"`
 }
 
 // Container represents a single container that is expected to be run on the host.
@@ -191,16 +245,50 @@ type Container struct {
 	CPU           int            `yaml:"cpu,omitempty" json:"cpu,omitempty"`
 	VolumeMounts  []VolumeMount  `yaml:"volumeMounts,omitempty" json:"volumeMounts,omitempty"`
 	LivenessProbe *LivenessProbe `yaml:"livenessProbe,omitempty" json:"livenessProbe,omitempty"`
+	// Optional: a container with a readiness probe is only sent traffic once the probe
+	// succeeds. A failing readiness probe never restarts the container. The master
+	// only learns that the probe succeeded from kubelets which report the status of
+	// pods to the apiserver, and kubelets which don't refuse pods with such probes.
+	ReadinessProbe *LivenessProbe `yaml:"readinessProbe,omitempty" json:"readinessProbe,omitempty"`
+	// Optional: Defaults to "PullAlways" for images tagged "latest" or not tagged
+	// at all, and to "PullIfNotPresent" otherwise.
+	ImagePullPolicy PullPolicy `yaml:"imagePullPolicy,omitempty" json:"imagePullPolicy,omitempty"`
+	// Optional: actions the kubelet takes around the container's life.
+	Lifecycle *Lifecycle `yaml:"lifecycle,omitempty" json:"lifecycle,omitempty"`
+}
+
+// Handler describes an action taken by the kubelet. Exactly one of its fields
+// must be set.
+type Handler struct {
+	// Exec runs a command inside the container.
+	Exec *ExecProbe `yaml:"exec,omitempty" json:"exec,omitempty"`
+	// HTTPGet sends an HTTP GET request to the container.
+	HTTPGet *HTTPGetProbe `yaml:"httpGet,omitempty" json:"httpGet,omitempty"`
 }
 
-// Event is the representation of an event logged to etcd backends
-type Event struct {
-	Event     string             `json:"event,omitempty"`
-	Manifest  *ContainerManifest `json:"manifest,omitempty"`
-	Container *Container         `json:"container,omitempty"`
-	Timestamp int64              `json:"timestamp"`
+// Lifecycle describes the actions the kubelet takes in response to container
+// lifecycle events.
+type Lifecycle struct {
+	// Optional: PostStart is run right after the container is started. If it
+	// fails, the container is killed and restarted like any failed container.
+	PostStart *Handler `yaml:"postStart,omitempty" json:"postStart,omitempty"`
+	// Optional: PreStop is run before the container is sent SIGTERM. The container
+	// is stopped whether or not the handler succeeds.
+	PreStop *Handler `yaml:"preStop,omitempty" json:"preStop,omitempty"`
 }
 
+// PullPolicy describes when the kubelet pulls the image of a container.
+type PullPolicy string
+
+const (
+	// PullAlways means the image is pulled every time the container is started.
+	PullAlways PullPolicy = "PullAlways"
+	// PullNever means the image is never pulled, it has to be on the machine already.
+	PullNever PullPolicy = "PullNever"
+	// PullIfNotPresent means the image is only pulled if it isn't on the machine.
+	PullIfNotPresent PullPolicy = "PullIfNotPresent"
+)
+
 // The below types are used by kube_client and api_server.
 
 // JSONBase is shared by all objects sent to, or returned from the client
@@ -226,6 +314,16 @@ const (
 	PodTerminated PodStatus = "Terminated"
 )
 
+// PodCondition is a condition of a pod, as observed by the kubelet running it.
+type PodCondition string
+
+// These are the valid conditions of pods.
+const (
+	// PodReady means that every readiness probe of the pod succeeded and the pod
+	// can be sent traffic.
+	PodReady PodCondition = "Ready"
+)
+
 // PodInfo contains one entry for every container with available info.
 type PodInfo map[string]docker.Container
 
@@ -251,6 +349,14 @@ type PodState struct {
 	Host     string            `json:"host,omitempty" yaml:"host,omitempty"`
 	HostIP   string            `json:"hostIP,omitempty" yaml:"hostIP,omitempty"`
 	PodIP    string            `json:"podIP,omitempty" yaml:"podIP,omitempty"`
+	// Conditions are the conditions last reported by the kubelet running the pod.
+	Conditions []PodCondition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	// Terminations describe how the containers which are not running exited, keyed
+	// by the name of the container within the manifest.
+	Terminations map[string]ContainerTermination `json:"terminations,omitempty" yaml:"terminations,omitempty"`
+	// Waiting describes the containers the kubelet fails to start, keyed by the
+	// name of the container within the manifest.
+	Waiting map[string]ContainerWaiting `json:"waiting,omitempty" yaml:"waiting,omitempty"`
 
 	// The key of this map is the *name* of the container within the manifest; it has one
 	// entry per container in the manifest. The value of this map is currently the output
@@ -262,6 +368,28 @@ type PodState struct {
 	RestartPolicy RestartPolicy `json:"restartpolicy,omitempty" yaml:"restartpolicy,omitempty"`
 }
 
+// ContainerTermination describes how a container of a pod last exited.
+type ContainerTermination struct {
+	ExitCode int `json:"exitCode" yaml:"exitCode"`
+	// Reason is "Completed" if the container exited successfully and "Error" otherwise.
+	Reason     string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+	StartedAt  util.Time `json:"startedAt,omitempty" yaml:"startedAt,omitempty"`
+	FinishedAt util.Time `json:"finishedAt,omitempty" yaml:"finishedAt,omitempty"`
+}
+
+// ContainerWaiting describes why the kubelet fails to start a container, or
+// holds off restarting it.
+type ContainerWaiting struct {
+	// Reason is a short description of the last failure, such as "ImageNotFound",
+	// "DockerUnavailable" or "CrashLoopBackOff".
+	Reason  string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// Failures is the number of attempts in a row which failed.
+	Failures int `json:"failures,omitempty" yaml:"failures,omitempty"`
+	// NextAttempt is when the kubelet tries to start the container again.
+	NextAttempt util.Time `json:"nextAttempt,omitempty" yaml:"nextAttempt,omitempty"`
+}
+
 // PodList is a list of Pods.
 type PodList struct {
 	JSONBase `json:",inline" yaml:",inline"`
@@ -274,6 +402,12 @@ type Pod struct {
 	Labels       map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 	DesiredState PodState          `json:"desiredState,omitempty" yaml:"desiredState,omitempty"`
 	CurrentState PodState          `json:"currentState,omitempty" yaml:"currentState,omitempty"`
+	// Optional: the pod is only scheduled onto minions with all of these labels.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"`
+	// Optional: notes about the pod for tools and system components, which,
+	// unlike labels, don't select it. E.g. "scheduler" names the scheduler
+	// which is to place it.
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
 }
 
 // ReplicationControllerState is the state of a replication controller, either input (create, update) or as output (list, get)
@@ -294,12 +428,42 @@ type ReplicationController struct {
 	JSONBase     `json:",inline" yaml:",inline"`
 	DesiredState ReplicationControllerState `json:"desiredState,omitempty" yaml:"desiredState,omitempty"`
 	Labels       map[string]string          `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Optional: notes about the controller for tools, e.g. the progress of a
+	// rolling update.
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// DaemonControllerState is the state of a daemon controller, either input (create, update) or as output (list, get)
+type DaemonControllerState struct {
+	// ReplicaSelector selects the pods of the controller.
+	ReplicaSelector map[string]string `json:"replicaSelector,omitempty" yaml:"replicaSelector,omitempty"`
+	// PodTemplate is what the pods are made from. One runs on every minion whose
+	// labels match PodTemplate.NodeSelector, or on every minion if it is empty.
+	PodTemplate PodTemplate `json:"podTemplate,omitempty" yaml:"podTemplate,omitempty"`
+}
+
+// DaemonControllerList is a collection of daemon controllers.
+type DaemonControllerList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []DaemonController `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+// DaemonController runs one pod from a template on every minion, e.g. a log
+// shipper or a monitoring agent, adding and removing pods as minions come and go.
+type DaemonController struct {
+	JSONBase     `json:",inline" yaml:",inline"`
+	DesiredState DaemonControllerState `json:"desiredState,omitempty" yaml:"desiredState,omitempty"`
+	Labels       map[string]string     `json:"labels,omitempty" yaml:"labels,omitempty"`
 }
 
 // PodTemplate holds the information used for creating pods
 type PodTemplate struct {
 	DesiredState PodState          `json:"desiredState,omitempty" yaml:"desiredState,omitempty"`
 	Labels       map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Optional: the NodeSelector of the pods made from this template.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"`
+	// Optional: the Annotations of the pods made from this template.
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
 }
 
 // ServiceList holds a list of services
@@ -319,14 +483,92 @@ type Service struct {
 	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 
 	// This service will route traffic to pods having labels matching this selector.
+	// Without a selector, the endpoints of the service are set through the API,
+	// e.g. to address a database outside the cluster.
 	Selector                   map[string]string `json:"selector,omitempty" yaml:"selector,omitempty"`
 	CreateExternalLoadBalancer bool              `json:"createExternalLoadBalancer,omitempty" yaml:"createExternalLoadBalancer,omitempty"`
 
-	// ContainerPort is the name of the port on the container to direct traffic to.
+	// PublicIPs are the IPs, reachable from outside the cluster, which the
+	// service is exposed on. Proxies listen on those IPs, and an external load
+	// balancer forwards those IPs. If an external load balancer is created
+	// without PublicIPs, the IP the cloud provider gave it is recorded here.
+	PublicIPs []string `json:"publicIPs,omitempty" yaml:"publicIPs,omitempty"`
+
+	// TargetPort is the number or name of the port on the container to direct
+	// traffic to. A name is looked up among the ports of every pod, so the
+	// service keeps working when the number of a named container port changes.
 	// Optional, if unspecified use the first port on the container.
+	TargetPort util.IntOrString `json:"targetPort,omitempty" yaml:"targetPort,omitempty"`
+
+	// ContainerPort is deprecated in favor of TargetPort, and is kept equal to it.
 	ContainerPort util.IntOrString `json:"containerPort,omitempty" yaml:"containerPort,omitempty"`
+
+	// PortalIP is the virtual IP the service is reachable at. It is allocated
+	// by the master from the portal network and can't be changed afterwards.
+	// PortalIPNone makes a headless service, which gets no portal IP and isn't
+	// proxied; its name resolves to the IPs of its endpoints instead.
+	PortalIP string `json:"portalIP,omitempty" yaml:"portalIP,omitempty"`
+
+	// Protocol is the protocol the service port is proxied with, "TCP" or "UDP".
+	// Optional, defaults to "TCP".
+	Protocol string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+
+	// SessionAffinity is how the proxy picks the endpoints of repeated clients.
+	// Optional, defaults to "None".
+	SessionAffinity AffinityType `json:"sessionAffinity,omitempty" yaml:"sessionAffinity,omitempty"`
+
+	// BalancingPolicy is how the proxy spreads new connections over the endpoints.
+	// Optional, defaults to "RoundRobin".
+	BalancingPolicy BalancingPolicyType `json:"balancingPolicy,omitempty" yaml:"balancingPolicy,omitempty"`
+
+	// ClientSourceIP is how the endpoints learn the IP of the client of a
+	// proxied connection, which otherwise comes from the proxy.
+	// Optional, defaults to "None".
+	ClientSourceIP ClientSourceIPType `json:"clientSourceIP,omitempty" yaml:"clientSourceIP,omitempty"`
 }
 
+// PortalIPNone is the PortalIP of headless services.
+const PortalIPNone = "None"
+
+// AffinityType is the session affinity of a service.
+type AffinityType string
+
+// These are the valid session affinities of a service.
+const (
+	// AffinityTypeClientIP sends the connections of a client IP to the same
+	// endpoint, for endpoints which keep per-client state.
+	AffinityTypeClientIP AffinityType = "ClientIP"
+	// AffinityTypeNone spreads the connections of every client over all endpoints.
+	AffinityTypeNone AffinityType = "None"
+)
+
+// ClientSourceIPType is how the endpoints of a service learn client IPs.
+type ClientSourceIPType string
+
+// These are the valid ways of passing client IPs to the endpoints of a service.
+const (
+	// ClientSourceIPProxyProtocol starts every TCP connection to an endpoint
+	// with a PROXY protocol (version 1) header naming the client, for endpoints
+	// which understand it, e.g. haproxy or nginx.
+	ClientSourceIPProxyProtocol ClientSourceIPType = "ProxyProtocol"
+	// ClientSourceIPNone leaves the proxy as the client the endpoints see.
+	ClientSourceIPNone ClientSourceIPType = "None"
+)
+
+// BalancingPolicyType is how the proxy spreads the connections to a service.
+type BalancingPolicyType string
+
+// These are the valid balancing policies of a service.
+const (
+	// BalancingPolicyRoundRobin gives the endpoints new connections in turn.
+	BalancingPolicyRoundRobin BalancingPolicyType = "RoundRobin"
+	// BalancingPolicyLeastConnections gives new connections to the endpoint
+	// with the fewest open connections through the proxy.
+	BalancingPolicyLeastConnections BalancingPolicyType = "LeastConnections"
+	// BalancingPolicyRandom gives new connections to a random endpoint.
+	BalancingPolicyRandom BalancingPolicyType = "Random"
+)
+
 // Endpoints is a collection of endpoints that implement the actual service, for example:
 // Name: "mysql", Endpoints: ["10.10.1.1:1909", "10.10.2.2:8834"]
 type Endpoints struct {
@@ -334,12 +576,69 @@ type Endpoints struct {
 	Endpoints []string `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
 }
 
+// EndpointsList is a list of endpoints.
+type EndpointsList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []Endpoints `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
 // Minion is a worker node in Kubernetenes.
 // The name of the minion according to etcd is in JSONBase.ID.
 type Minion struct {
 	JSONBase `json:",inline" yaml:",inline"`
+	// Labels describe the minion, e.g. its hardware or zone, for pods to select
+	// it by. The kubelet reports them when registering.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 	// Queried from cloud provider, if available.
 	HostIP string `json:"hostIP,omitempty" yaml:"hostIP,omitempty"`
+	// NodeResources are the resources of the minion, as reported by its kubelet.
+	NodeResources NodeResources `json:"resources,omitempty" yaml:"resources,omitempty"`
+	// Status is the minion's health. The kubelet reports its own view of it when
+	// registering, which the master combines with whether heartbeats arrive.
+	Status MinionStatus `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// ResourceName is the name of a resource of a minion.
+type ResourceName string
+
+const (
+	// ResourceCPU is the CPU of a minion, in millicores.
+	ResourceCPU ResourceName = "cpu"
+	// ResourceMemory is the memory of a minion, in bytes.
+	ResourceMemory ResourceName = "memory"
+)
+
+// ResourceList is a set of resources and their quantities.
+type ResourceList map[ResourceName]int64
+
+// NodeResources describe the resources of a minion.
+type NodeResources struct {
+	// Capacity is the total amount of each resource the minion has.
+	Capacity ResourceList `json:"capacity,omitempty" yaml:"capacity,omitempty"`
+}
+
+// MinionCondition describes whether a minion can be given pods.
+type MinionCondition string
+
+// These are the valid conditions of a minion.
+const (
+	// MinionReady means the minion has sent a heartbeat recently, or the master
+	// doesn't track heartbeats.
+	MinionReady MinionCondition = "Ready"
+	// MinionNotReady means the minion has missed its heartbeats, and won't be
+	// given new pods until it is heard from again.
+	MinionNotReady MinionCondition = "NotReady"
+)
+
+// MinionStatus is the master's view of a minion's health.
+type MinionStatus struct {
+	Condition MinionCondition `json:"condition,omitempty" yaml:"condition,omitempty"`
+	// LastHeartbeatTime is when the minion last registered itself, if ever.
+	LastHeartbeatTime util.Time `json:"lastHeartbeatTime,omitempty" yaml:"lastHeartbeatTime,omitempty"`
+	// Addresses are the IP addresses the kubelet found on the minion.
+	Addresses []string `json:"addresses,omitempty" yaml:"addresses,omitempty"`
+	// KubeletVersion is the version of the kubelet running on the minion.
+	KubeletVersion string `json:"kubeletVersion,omitempty" yaml:"kubeletVersion,omitempty"`
 }
 
 // MinionList is a list of minions.
@@ -355,6 +654,62 @@ type Binding struct {
 	Host     string `json:"host" yaml:"host"`
 }
 
+// Resize is written to change the number of replicas of a replication
+// controller, leaving the rest of the controller as it is.
+type Resize struct {
+	JSONBase     `json:",inline" yaml:",inline"`
+	ControllerID string `json:"controllerID" yaml:"controllerID"`
+	Replicas     int    `json:"replicas" yaml:"replicas"`
+}
+
+// PodStatusReport is sent by a kubelet to tell the master about the containers
+// of one of its pods. The name of the pod is in JSONBase.ID.
+type PodStatusReport struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Host     string  `json:"host" yaml:"host"`
+	Info     PodInfo `json:"info,omitempty" yaml:"info,omitempty"`
+	// Conditions are the conditions the kubelet observed for the pod.
+	Conditions []PodCondition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	// Waiting describes the containers the kubelet fails to start.
+	Waiting map[string]ContainerWaiting `json:"waiting,omitempty" yaml:"waiting,omitempty"`
+	// PodIP is the IP address of the pod's network container, which all its
+	// containers share. Empty if the pod has none yet.
+	PodIP string `json:"podIP,omitempty" yaml:"podIP,omitempty"`
+}
+
+// ObjectReference points to an object, or to a part of one.
+type ObjectReference struct {
+	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	ID   string `json:"id,omitempty" yaml:"id,omitempty"`
+	// FieldPath names the part of the object meant, if not all of it, e.g.
+	// "desiredState.manifest.containers[web]".
+	FieldPath string `json:"fieldPath,omitempty" yaml:"fieldPath,omitempty"`
+}
+
+// Event is a report of something which happened to an object, such as one of
+// a pod's containers being started or failing its liveness probe. Events are
+// only kept for a limited time.
+type Event struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	// InvolvedObject is the object the event happened to.
+	InvolvedObject ObjectReference `json:"involvedObject,omitempty" yaml:"involvedObject,omitempty"`
+	// Reason is a short, machine understandable description of what happened,
+	// e.g. "started".
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	// Message is a human readable description of what happened.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// Source names the component which reported the event, e.g. "kubelet minion-1".
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	// Timestamp is when the event happened.
+	Timestamp util.Time `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
+}
+
+// EventList is a list of events.
+type EventList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []Event `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
 // Status is a return value for calls that don't return other objects.
 // TODO: this could go in apiserver, but I'm including it here so clients needn't
 // import both.
@@ -391,6 +746,11 @@ type StatusDetails struct {
 	// The kind attribute of the resource associated with the status ReasonType.
 	// On some operations may differ from the requested resource Kind.
 	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	// The Causes array includes more details associated with the ReasonTypeInvalid
+	// failure. Not all ReasonTypes may provide detailed causes.
+	Causes []StatusCause `json:"causes,omitempty" yaml:"causes,omitempty"`
+	// If specified, the time in seconds before the operation should be retried.
+	RetryAfter int `json:"retryAfter,omitempty" yaml:"retryAfter,omitempty"`
 }
 
 // Values of Status.Status
@@ -447,11 +807,82 @@ const (
 	// conflict.
 	// Status code 409
 	ReasonTypeConflict ReasonType = "conflict"
+
+	// ReasonTypeInvalid means the requested create or update operation cannot be
+	// completed due to invalid data provided as part of the request. The client may
+	// need to alter the request.
+	// Details (optional):
+	//   "kind"   string - the kind attribute of the invalid resource
+	//   "id"     string - the identifier of the invalid resource
+	//   "causes"        - one or more StatusCause entries indicating the data in the
+	//                     provided resource that was invalid.
+	// Status code 422
+	ReasonTypeInvalid ReasonType = "invalid"
+
+	// ReasonTypeServerTimeout means the server can be reached and understood the
+	// request, but cannot complete the action in a reasonable time. The client
+	// should retry the request. This may be due to temporary server load or a
+	// transient communication issue with another server.
+	// Details (optional):
+	//   "kind"       string - the kind attribute of the resource being acted on.
+	//   "id"         string - the operation that is being attempted.
+	//   "retryAfter" int    - the number of seconds before the client should retry.
+	// Status code 500
+	ReasonTypeServerTimeout ReasonType = "server_timeout"
+)
+
+// StatusCause provides more information about an api.Status failure, including
+// cases when multiple errors are encountered.
+type StatusCause struct {
+	// A machine-readable description of the cause of the error. If this value is
+	// empty there is no information available.
+	Type CauseType `json:"reason,omitempty" yaml:"reason,omitempty"`
+	// A human-readable description of the cause of the error.  This field may be
+	// presented as-is to a reader.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// The field of the resource that has caused this error, as named by its JSON
+	// serialization. May include dot and postfix notation for nested attributes.
+	// Arrays are zero-indexed.  Fields may appear more than once in an array of
+	// causes due to fields having multiple errors.
+	// Examples:
+	//   "name" - the field "name" on the current resource
+	//   "items[0].name" - the field "name" on the first array entry in "items"
+	Field string `json:"field,omitempty" yaml:"field,omitempty"`
+}
+
+// CauseType is a machine readable value providing more detail about what
+// occured in a status response. An operation may have multiple causes for a
+// status (whether failure, success, or working).
+type CauseType string
+
+const (
+	// CauseTypeFieldValueNotFound is used to report failure to find a requested value
+	// (e.g. looking up an ID).
+	CauseTypeFieldValueNotFound CauseType = "field_value_not_found"
+	// CauseTypeFieldValueInvalid is used to report malformed values (e.g. failed regex
+	// match) or missing "required" fields.
+	CauseTypeFieldValueInvalid CauseType = "field_value_invalid"
+	// CauseTypeFieldValueNotSupported is used to report valid (as per formatting rules)
+	// values that can not be handled (e.g. an enumerated string).
+	CauseTypeFieldValueNotSupported CauseType = "field_value_not_supported"
+	// CauseTypeFieldValueDuplicate is used to report collisions of values that must be
+	// unique (e.g. unique IDs).
+	CauseTypeFieldValueDuplicate CauseType = "field_value_duplicate"
 )
 
 // ServerOp is an operation delivered to API clients.
 type ServerOp struct {
 	JSONBase `yaml:",inline" json:",inline"`
+	// The resource the operation acts on, e.g. "pods".
+	Resource string `yaml:"resource,omitempty" json:"resource,omitempty"`
+	// The apiserver which runs the operation.
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
+	// One of: "success", "failure", "working" (for operations not yet completed)
+	Status string `yaml:"status,omitempty" json:"status,omitempty"`
+	// For failed operations, a human readable description of the failure.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+	// For finished operations, the HTTP return code of the result, 0 if not set.
+	Code int `yaml:"code,omitempty" json:"code,omitempty"`
 }
 
 // ServerOpList is a list of operations, as delivered to API clients.
@@ -460,6 +891,12 @@ type ServerOpList struct {
 	Items    []ServerOp `yaml:"items,omitempty" json:"items,omitempty"`
 }
 
+// APIVersions lists the API versions a server supports, so that clients can
+// pick one they understand. It is not itself a versioned API object.
+type APIVersions struct {
+	Versions []string `yaml:"versions" json:"versions"`
+}
+
 // WatchEvent objects are streamed from the api server in response to a watch request.
 type WatchEvent struct {
 	// The type of the watch event; added, modified, or deleted.
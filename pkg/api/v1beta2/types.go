@@ -0,0 +1,912 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+	"github.com/GoogleCloudPlatform/kubernetes/third_party/docker-api-structs"
+)
+
+// Common string formats
+// ---------------------
+// Many fields in this API have formatting requirements.  The commonly used
+// formats are defined here.
+//
+// C_IDENTIFIER:  This is a string that conforms the definition of an "identifier"
+//     in the C language.  This is captured by the following regex:
+//         [A-Za-z_][A-Za-z0-9_]*
+//     This defines the format, but not the length restriction, which should be
+//     specified at the definition of any field of this type.
+//
+// DNS_LABEL:  This is a string, no more than 63 characters long, that conforms
+//     to the definition of a "label" in RFCs 1035 and 1123.  This is captured
+//     by the following regex:
+//         [a-z0-9]([-a-z0-9]*[a-z0-9])?
+//
+// DNS_SUBDOMAIN:  This is a string, no more than 253 characters long, that conforms
+//      to the definition of a "subdomain" in RFCs 1035 and 1123.  This is captured
+//      by the following regex:
+//         [a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*
+//     or more simply:
+//         DNS_LABEL(\.DNS_LABEL)*
+
+// ContainerManifest corresponds to the Container Manifest format, documented at:
+// https://developers.google.com/compute/docs/containers/container_vms#container_manifest
+// This is used as the representation of Kubernetes workloads.
+type ContainerManifest struct {
+	// Required: This must be a supported version string, such as "v1beta1".
+	Version string `yaml:"version" json:"version"`
+	// Required: This must be a DNS_SUBDOMAIN.
+	// TODO: ID on Manifest is deprecated and will be removed in the future.
+	ID         string      `yaml:"id" json:"id"`
+	Volumes    []Volume    `yaml:"volumes" json:"volumes"`
+	Containers []Container `yaml:"containers" json:"containers"`
+	// Optional: Defaults to "RestartAlways". The master fills this in from the
+	// RestartPolicy of the pod.
+	RestartPolicy RestartPolicy `yaml:"restartPolicy,omitempty" json:"restartPolicy,omitempty"`
+	// Optional: Seconds the containers of the pod get to shut down after being
+	// sent SIGTERM, before they are killed. Defaults to 10; 0 kills them at once.
+	TerminationGracePeriodSeconds *int64 `yaml:"terminationGracePeriodSeconds,omitempty" json:"terminationGracePeriodSeconds,omitempty"`
+}
+
+// ContainerManifestList is used to communicate container manifests to kubelet.
+type ContainerManifestList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []ContainerManifest `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+// Volume represents a named volume in a pod that may be accessed by any containers in the pod.
+type Volume struct {
+	// Required: This must be a DNS_LABEL.  Each volume in a pod must have
+	// a unique name.
+	Name string `yaml:"name" json:"name"`
+	// Source represents the location and type of a volume to mount.
+	// This is optional for now. If not specified, the Volume is implied to be an EmptyDir.
+	// This implied behavior is deprecated and will be removed in a future version.
+	Source *VolumeSource `yaml:"source" json:"source"`
+}
+
+type VolumeSource struct {
+	// Only one of the following sources may be specified
+	// HostDirectory represents a pre-existing directory on the host machine that is directly
+	// exposed to the container. This is generally used for system agents or other privileged
+	// things that are allowed to see the host machine. Most containers will NOT need this.
+	// TODO(jonesdl) We need to restrict who can use host directory mounts and
+	// who can/can not mount host directories as read/write.
+	HostDirectory *HostDirectory `yaml:"hostDir" json:"hostDir"`
+	// EmptyDirectory represents a temporary directory that shares a pod's lifetime.
+	EmptyDirectory *EmptyDirectory `yaml:"emptyDir" json:"emptyDir"`
+	// GCEPersistentDisk represents a GCE Disk resource that is attached to the
+	// kubelet's host machine and then exposed to the pod.
+	GCEPersistentDisk *GCEPersistentDisk `yaml:"persistentDisk" json:"persistentDisk"`
+	// NFS represents an NFS export mounted on the host and exposed to the pod.
+	NFS *NFSMount `yaml:"nfs" json:"nfs"`
+}
+
+// Bare host directory volume.
+type HostDirectory struct {
+	Path string `yaml:"path" json:"path"`
+}
+
+type EmptyDirectory struct{}
+
+// GCEPersistentDisk represents a Persistent Disk resource in Google Compute Engine.
+//
+// A GCE PD must exist before mounting to a container. A disk without a
+// filesystem is formatted with FSType when it is first mounted read/write.
+// The disk must also be in the same GCE project and zone as the kubelet.
+// A GCE PD can only be mounted as read/write once, but may be mounted
+// read-only by many pods at once.
+type GCEPersistentDisk struct {
+	// Unique name of the PD resource. Used to identify the disk in GCE.
+	// Must be a DNS label, as GCE disk names are.
+	PDName string `yaml:"pdName" json:"pdName"`
+	// Required: Filesystem type to mount.
+	// Must be a filesystem type supported by the host operating system.
+	// Ex. "ext4", "xfs", "ntfs"
+	// TODO: how do we prevent errors in the filesystem from compromising the machine
+	FSType string `yaml:"fsType,omitempty" json:"fsType,omitempty"`
+	// Optional: Partition on the disk to mount.
+	// If omitted, kubelet will attempt to mount the device name.
+	// Ex. For /dev/sda1, this field is "1", for /dev/sda, this field is 0 or empty.
+	Partition int `yaml:"partition,omitempty" json:"partition,omitempty"`
+	// Optional: Defaults to false (read/write). ReadOnly here will force
+	// the ReadOnly setting in VolumeMounts.
+	ReadOnly bool `yaml:"readOnly,omitempty" json:"readOnly,omitempty"`
+}
+
+// NFSMount represents an NFS export mounted for the lifetime of a pod.
+type NFSMount struct {
+	// Server is the hostname or IP address of the NFS server.
+	Server string `yaml:"server" json:"server"`
+	// Path is the path exported by the NFS server.
+	Path string `yaml:"path" json:"path"`
+	// Optional: Defaults to false (read/write). ReadOnly here will force
+	// the NFS export to be mounted with read-only permissions.
+	ReadOnly bool `yaml:"readOnly,omitempty" json:"readOnly,omitempty"`
+	// Optional: Options are further mount options, e.g. "vers=3" or "soft",
+	// passed to mount(8) as they are. Whether the export is read-only is set
+	// by ReadOnly alone, so "ro" and "rw" aren't allowed here.
+	Options []string `yaml:"options,omitempty" json:"options,omitempty"`
+}
+
+// Port represents a network port in a single container
+type Port struct {
+	// Optional: If specified, this must be a DNS_LABEL.  Each named port
+	// in a pod must have a unique name.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Optional: If specified, this must be a valid port number, 0 < x < 65536.
+	HostPort int `yaml:"hostPort,omitempty" json:"hostPort,omitempty"`
+	// Required: This must be a valid port number, 0 < x < 65536.
+	ContainerPort int `yaml:"containerPort" json:"containerPort"`
+	// Optional: Defaults to "TCP".
+	Protocol string `yaml:"protocol,omitempty" json:"protocol,omitempty"`
+	// Optional: What host IP to bind the external port to.
+	HostIP string `yaml:"hostIP,omitempty" json:"hostIP,omitempty"`
+}
+
+// VolumeMount describes a mounting of a Volume within a container
+type VolumeMount struct {
+	// Required: This must match the Name of a Volume [above].
+	Name string `yaml:"name" json:"name"`
+	// Optional: Defaults to false (read-write).
+	ReadOnly bool `yaml:"readOnly,omitempty" json:"readOnly,omitempty"`
+	// Required.
+	// Exactly one of the following must be set.  If both are set, prefer MountPath.
+	// DEPRECATED: Path will be removed in a future version of the API.
+	MountPath string `yaml:"mountPath,omitempty" json:"mountPath,omitempty"`
+	Path      string `yaml:"path,omitempty" json:"path,omitempty"`
+	// One of: "LOCAL" (local volume) or "HOST" (external mount from the host). Default: LOCAL.
+	// DEPRECATED: MountType will be removed in a future version of the API.
+	MountType string `yaml:"mountType,omitempty" json:"mountType,omitempty"`
+}
+
+// EnvVar represents an environment variable present in a Container
+type EnvVar struct {
+	// Required: This must be a C_IDENTIFIER.
+	Name string `yaml:"name" json:"name"`
+	// Optional: defaults to "".
+	Value string `yaml:"value,omitempty" json:"value,omitempty"`
+}
+
+// HTTPGetProbe describes a liveness probe based on HTTP Get requests.
+type HTTPGetProbe struct {
+	// Optional: Path to access on the HTTP server.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	// Required: Name or number of the port to access on the container.
+	Port util.IntOrString `yaml:"port,omitempty" json:"port,omitempty"`
+	// Optional: Host name to connect to, defaults to the pod IP.
+	Host string `yaml:"host,omitempty" json:"host,omitempty"`
+}
+
+// TCPSocketProbe describes a liveness probe based on opening a socket
+type TCPSocketProbe struct {
+	// Required: Port to connect to.
+	Port util.IntOrString `yaml:"port,omitempty" json:"port,omitempty"`
+}
+
+// ExecProbe describes a "run in container" health probe.
+type ExecProbe struct {
+	// Command is the command line to execute inside the container, the working directory for the
+	// command  is root ('/') in the container's filesystem.  The command is simply exec'd, it is
+	// not run inside a shell, so traditional shell instructions ('|', etc) won't work.  To use
+	// a shell, you need to explicitly call out to that shell.
+	// A return code of zero is treated as 'Healthy', non-zero is 'Unhealthy'
+	Command []string `yaml:"command,omitempty" json:"command,omitempty"`
+}
+
+// LivenessProbe describes a liveness probe to be examined to the container.
+type LivenessProbe struct {
+	// Type of liveness probe.  Current legal values "http", "tcp"
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+	// HTTPGetProbe parameters, required if Type == 'http'
+	HTTPGet *HTTPGetProbe `yaml:"httpGet,omitempty" json:"httpGet,omitempty"`
+	// TCPSocketProbe parameter, required if Type == 'tcp'
+	TCPSocket *TCPSocketProbe `yaml:"tcpSocket,omitempty" json:"tcpSocket,omitempty"`
+	// ExecProbe parameter, required if Type == 'exec'
+	Exec *ExecProbe `yaml:"exec,omitempty" json:"exec,omitempty"`
+	// Length of time before health checking is activated.  In seconds.
+	InitialDelaySeconds int64 `yaml:"initialDelaySeconds,omitempty" json:"initialDelaySeconds,omitempty"`
+	// Optional: Number of consecutive failed probes after which the container is
+	// restarted. Defaults to 1.
+	FailureThreshold int `yaml:"failureThreshold,omitempty" json:"failureThreshold,omitempty"`
+}
+
+// Container represents a single container that is expected to be run on the host.
+type Container struct {
+	// Required: This must be a DNS_LABEL.  Each container in a pod must
+	// have a unique name.
+	Name string `yaml:"name" json:"name"`
+	// Required.
+	Image string `yaml:"image" json:"image"`
+	// Optional: Defaults to whatever is defined in the image.
+	Command []string `yaml:"command,omitempty" json:"command,omitempty"`
+	// Optional: Defaults to Docker's default.
+	WorkingDir string   `yaml:"workingDir,omitempty" json:"workingDir,omitempty"`
+	Ports      []Port   `yaml:"ports,omitempty" json:"ports,omitempty"`
+	Env        []EnvVar `yaml:"env,omitempty" json:"env,omitempty"`
+	// Optional: Defaults to unlimited.
+	Memory int `yaml:"memory,omitempty" json:"memory,omitempty"`
+	// Optional: Defaults to unlimited.
+	CPU           int            `yaml:"cpu,omitempty" json:"cpu,omitempty"`
+	VolumeMounts  []VolumeMount  `yaml:"volumeMounts,omitempty" json:"volumeMounts,omitempty"`
+	LivenessProbe *LivenessProbe `yaml:"livenessProbe,omitempty" json:"livenessProbe,omitempty"`
+	// Optional: a container with a readiness probe is only sent traffic once the probe
+	// succeeds. A failing readiness probe never restarts the container. The master
+	// only learns that the probe succeeded from kubelets which report the status of
+	// pods to the apiserver, and kubelets which don't refuse pods with such probes.
+	ReadinessProbe *LivenessProbe `yaml:"readinessProbe,omitempty" json:"readinessProbe,omitempty"`
+	// Optional: Defaults to "PullAlways" for images tagged "latest" or not tagged
+	// at all, and to "PullIfNotPresent" otherwise.
+	ImagePullPolicy PullPolicy `yaml:"imagePullPolicy,omitempty" json:"imagePullPolicy,omitempty"`
+	// Optional: actions the kubelet takes around the container's life.
+	Lifecycle *Lifecycle `yaml:"lifecycle,omitempty" json:"lifecycle,omitempty"`
+}
+
+// Handler describes an action taken by the kubelet. Exactly one of its fields
+// must be set.
+type Handler struct {
+	// Exec runs a command inside the container.
+	Exec *ExecProbe `yaml:"exec,omitempty" json:"exec,omitempty"`
+	// HTTPGet sends an HTTP GET request to the container.
+	HTTPGet *HTTPGetProbe `yaml:"httpGet,omitempty" json:"httpGet,omitempty"`
+}
+
+// Lifecycle describes the actions the kubelet takes in response to container
+// lifecycle events.
+type Lifecycle struct {
+	// Optional: PostStart is run right after the container is started. If it
+	// fails, the container is killed and restarted like any failed container.
+	PostStart *Handler `yaml:"postStart,omitempty" json:"postStart,omitempty"`
+	// Optional: PreStop is run before the container is sent SIGTERM. The container
+	// is stopped whether or not the handler succeeds.
+	PreStop *Handler `yaml:"preStop,omitempty" json:"preStop,omitempty"`
+}
+
+// PullPolicy describes when the kubelet pulls the image of a container.
+type PullPolicy string
+
+const (
+	// PullAlways means the image is pulled every time the container is started.
+	PullAlways PullPolicy = "PullAlways"
+	// PullNever means the image is never pulled, it has to be on the machine already.
+	PullNever PullPolicy = "PullNever"
+	// PullIfNotPresent means the image is only pulled if it isn't on the machine.
+	PullIfNotPresent PullPolicy = "PullIfNotPresent"
+)
+
+// The below types are used by kube_client and api_server.
+
+// JSONBase is shared by all objects sent to, or returned from the client
+type JSONBase struct {
+	Kind              string    `json:"kind,omitempty" yaml:"kind,omitempty"`
+	ID                string    `json:"id,omitempty" yaml:"id,omitempty"`
+	CreationTimestamp util.Time `json:"creationTimestamp,omitempty" yaml:"creationTimestamp,omitempty"`
+	SelfLink          string    `json:"selfLink,omitempty" yaml:"selfLink,omitempty"`
+	ResourceVersion   uint64    `json:"resourceVersion,omitempty" yaml:"resourceVersion,omitempty"`
+	APIVersion        string    `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+}
+
+// PodStatus represents a status of a pod.
+type PodStatus string
+
+// These are the valid statuses of pods.
+const (
+	// PodWaiting means that we're waiting for the pod to begin running.
+	PodWaiting PodStatus = "Waiting"
+	// PodRunning means that the pod is up and running.
+	PodRunning PodStatus = "Running"
+	// PodTerminated means that the pod has stopped.
+	PodTerminated PodStatus = "Terminated"
+)
+
+// PodCondition is a condition of a pod, as observed by the kubelet running it.
+type PodCondition string
+
+// These are the valid conditions of pods.
+const (
+	// PodReady means that every readiness probe of the pod succeeded and the pod
+	// can be sent traffic.
+	PodReady PodCondition = "Ready"
+)
+
+// PodInfo contains one entry for every container with available info.
+type PodInfo map[string]docker.Container
+
+// RestartPolicyType represents a restart policy for a pod.
+type RestartPolicyType string
+
+// Valid restart policies defined for a PodState.RestartPolicy.
+const (
+	RestartAlways    RestartPolicyType = "RestartAlways"
+	RestartOnFailure RestartPolicyType = "RestartOnFailure"
+	RestartNever     RestartPolicyType = "RestartNever"
+)
+
+type RestartPolicy struct {
+	// Optional: Defaults to "RestartAlways".
+	Type RestartPolicyType `yaml:"type,omitempty" json:"type,omitempty"`
+}
+
+// PodState is the state of a pod, used as either input (desired state) or output (current state)
+type PodState struct {
+	Manifest ContainerManifest `json:"manifest,omitempty" yaml:"manifest,omitempty"`
+	Status   PodStatus         `json:"status,omitempty" yaml:"status,omitempty"`
+	Host     string            `json:"host,omitempty" yaml:"host,omitempty"`
+	HostIP   string            `json:"hostIP,omitempty" yaml:"hostIP,omitempty"`
+	PodIP    string            `json:"podIP,omitempty" yaml:"podIP,omitempty"`
+	// Conditions are the conditions last reported by the kubelet running the pod.
+	Conditions []PodCondition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	// Terminations describe how the containers which are not running exited, keyed
+	// by the name of the container within the manifest.
+	Terminations map[string]ContainerTermination `json:"terminations,omitempty" yaml:"terminations,omitempty"`
+	// Waiting describes the containers the kubelet fails to start, keyed by the
+	// name of the container within the manifest.
+	Waiting map[string]ContainerWaiting `json:"waiting,omitempty" yaml:"waiting,omitempty"`
+
+	// The key of this map is the *name* of the container within the manifest; it has one
+	// entry per container in the manifest. The value of this map is currently the output
+	// of `docker inspect`. This output format is *not* final and should not be relied
+	// upon. To allow marshalling/unmarshalling, we copied the client's structs and added
+	// json/yaml tags.
+	// TODO: Make real decisions about what our info should look like.
+	Info          PodInfo       `json:"info,omitempty" yaml:"info,omitempty"`
+	RestartPolicy RestartPolicy `json:"restartpolicy,omitempty" yaml:"restartpolicy,omitempty"`
+}
+
+// ContainerTermination describes how a container of a pod last exited.
+type ContainerTermination struct {
+	ExitCode int `json:"exitCode" yaml:"exitCode"`
+	// Reason is "Completed" if the container exited successfully and "Error" otherwise.
+	Reason     string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+	StartedAt  util.Time `json:"startedAt,omitempty" yaml:"startedAt,omitempty"`
+	FinishedAt util.Time `json:"finishedAt,omitempty" yaml:"finishedAt,omitempty"`
+}
+
+// ContainerWaiting describes why the kubelet fails to start a container, or
+// holds off restarting it.
+type ContainerWaiting struct {
+	// Reason is a short description of the last failure, such as "ImageNotFound",
+	// "DockerUnavailable" or "CrashLoopBackOff".
+	Reason  string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// Failures is the number of attempts in a row which failed.
+	Failures int `json:"failures,omitempty" yaml:"failures,omitempty"`
+	// NextAttempt is when the kubelet tries to start the container again.
+	NextAttempt util.Time `json:"nextAttempt,omitempty" yaml:"nextAttempt,omitempty"`
+}
+
+// PodList is a list of Pods.
+type PodList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []Pod `json:"items" yaml:"items,omitempty"`
+}
+
+// Pod is a collection of containers, used as either input (create, update) or as output (list, get)
+type Pod struct {
+	JSONBase     `json:",inline" yaml:",inline"`
+	Labels       map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	DesiredState PodState          `json:"desiredState,omitempty" yaml:"desiredState,omitempty"`
+	CurrentState PodState          `json:"currentState,omitempty" yaml:"currentState,omitempty"`
+	// Optional: the pod is only scheduled onto minions with all of these labels.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"`
+	// Optional: notes about the pod for tools and system components, which,
+	// unlike labels, don't select it. E.g. "scheduler" names the scheduler
+	// which is to place it.
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// ReplicationControllerState is the state of a replication controller, either input (create, update) or as output (list, get)
+type ReplicationControllerState struct {
+	Replicas        int               `json:"replicas" yaml:"replicas"`
+	ReplicaSelector map[string]string `json:"replicaSelector,omitempty" yaml:"replicaSelector,omitempty"`
+	PodTemplate     PodTemplate       `json:"podTemplate,omitempty" yaml:"podTemplate,omitempty"`
+}
+
+// ReplicationControllerList is a collection of replication controllers.
+type ReplicationControllerList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []ReplicationController `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+// ReplicationController represents the configuration of a replication controller
+type ReplicationController struct {
+	JSONBase     `json:",inline" yaml:",inline"`
+	DesiredState ReplicationControllerState `json:"desiredState,omitempty" yaml:"desiredState,omitempty"`
+	Labels       map[string]string          `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Optional: notes about the controller for tools, e.g. the progress of a
+	// rolling update.
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// DaemonControllerState is the state of a daemon controller, either input (create, update) or as output (list, get)
+type DaemonControllerState struct {
+	// ReplicaSelector selects the pods of the controller.
+	ReplicaSelector map[string]string `json:"replicaSelector,omitempty" yaml:"replicaSelector,omitempty"`
+	// PodTemplate is what the pods are made from. One runs on every minion whose
+	// labels match PodTemplate.NodeSelector, or on every minion if it is empty.
+	PodTemplate PodTemplate `json:"podTemplate,omitempty" yaml:"podTemplate,omitempty"`
+}
+
+// DaemonControllerList is a collection of daemon controllers.
+type DaemonControllerList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []DaemonController `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+// DaemonController runs one pod from a template on every minion, e.g. a log
+// shipper or a monitoring agent, adding and removing pods as minions come and go.
+type DaemonController struct {
+	JSONBase     `json:",inline" yaml:",inline"`
+	DesiredState DaemonControllerState `json:"desiredState,omitempty" yaml:"desiredState,omitempty"`
+	Labels       map[string]string     `json:"labels,omitempty" yaml:"labels,omitempty"`
+}
+
+// PodTemplate holds the information used for creating pods
+type PodTemplate struct {
+	DesiredState PodState          `json:"desiredState,omitempty" yaml:"desiredState,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Optional: the NodeSelector of the pods made from this template.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty" yaml:"nodeSelector,omitempty"`
+	// Optional: the Annotations of the pods made from this template.
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// ServiceList holds a list of services
+type ServiceList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []Service `json:"items" yaml:"items"`
+}
+
+// Service is a named abstraction of software service (for example, mysql) consisting of local port
+// (for example 3306) that the proxy listens on, and the selector that determines which pods
+// will answer requests sent through the proxy.
+type Service struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Port     int `json:"port,omitempty" yaml:"port,omitempty"`
+
+	// This service's labels.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+
+	// This service will route traffic to pods having labels matching this selector.
+	// Without a selector, the endpoints of the service are set through the API,
+	// e.g. to address a database outside the cluster.
+	Selector                   map[string]string `json:"selector,omitempty" yaml:"selector,omitempty"`
+	CreateExternalLoadBalancer bool              `json:"createExternalLoadBalancer,omitempty" yaml:"createExternalLoadBalancer,omitempty"`
+
+	// PublicIPs are the IPs, reachable from outside the cluster, which the
+	// service is exposed on. Proxies listen on those IPs, and an external load
+	// balancer forwards those IPs. If an external load balancer is created
+	// without PublicIPs, the IP the cloud provider gave it is recorded here.
+	PublicIPs []string `json:"publicIPs,omitempty" yaml:"publicIPs,omitempty"`
+
+	// TargetPort is the number or name of the port on the container to direct
+	// traffic to. A name is looked up among the ports of every pod, so the
+	// service keeps working when the number of a named container port changes.
+	// Optional, if unspecified use the first port on the container.
+	TargetPort util.IntOrString `json:"targetPort,omitempty" yaml:"targetPort,omitempty"`
+
+	// ContainerPort is deprecated in favor of TargetPort, and is kept equal to it.
+	ContainerPort util.IntOrString `json:"containerPort,omitempty" yaml:"containerPort,omitempty"`
+
+	// PortalIP is the virtual IP the service is reachable at. It is allocated
+	// by the master from the portal network and can't be changed afterwards.
+	// PortalIPNone makes a headless service, which gets no portal IP and isn't
+	// proxied; its name resolves to the IPs of its endpoints instead.
+	PortalIP string `json:"portalIP,omitempty" yaml:"portalIP,omitempty"`
+
+	// Protocol is the protocol the service port is proxied with, "TCP" or "UDP".
+	// Optional, defaults to "TCP".
+	Protocol string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+
+	// SessionAffinity is how the proxy picks the endpoints of repeated clients.
+	// Optional, defaults to "None".
+	SessionAffinity AffinityType `json:"sessionAffinity,omitempty" yaml:"sessionAffinity,omitempty"`
+
+	// BalancingPolicy is how the proxy spreads new connections over the endpoints.
+	// Optional, defaults to "RoundRobin".
+	BalancingPolicy BalancingPolicyType `json:"balancingPolicy,omitempty" yaml:"balancingPolicy,omitempty"`
+
+	// ClientSourceIP is how the endpoints learn the IP of the client of a
+	// proxied connection, which otherwise comes from the proxy.
+	// Optional, defaults to "None".
+	ClientSourceIP ClientSourceIPType `json:"clientSourceIP,omitempty" yaml:"clientSourceIP,omitempty"`
+}
+
+// PortalIPNone is the PortalIP of headless services.
+const PortalIPNone = "None"
+
+// AffinityType is the session affinity of a service.
+type AffinityType string
+
+// These are the valid session affinities of a service.
+const (
+	// AffinityTypeClientIP sends the connections of a client IP to the same
+	// endpoint, for endpoints which keep per-client state.
+	AffinityTypeClientIP AffinityType = "ClientIP"
+	// AffinityTypeNone spreads the connections of every client over all endpoints.
+	AffinityTypeNone AffinityType = "None"
+)
+
+// ClientSourceIPType is how the endpoints of a service learn client IPs.
+type ClientSourceIPType string
+
+// These are the valid ways of passing client IPs to the endpoints of a service.
+const (
+	// ClientSourceIPProxyProtocol starts every TCP connection to an endpoint
+	// with a PROXY protocol (version 1) header naming the client, for endpoints
+	// which understand it, e.g. haproxy or nginx.
+	ClientSourceIPProxyProtocol ClientSourceIPType = "ProxyProtocol"
+	// ClientSourceIPNone leaves the proxy as the client the endpoints see.
+	ClientSourceIPNone ClientSourceIPType = "None"
+)
+
+// BalancingPolicyType is how the proxy spreads the connections to a service.
+type BalancingPolicyType string
+
+// These are the valid balancing policies of a service.
+const (
+	// BalancingPolicyRoundRobin gives the endpoints new connections in turn.
+	BalancingPolicyRoundRobin BalancingPolicyType = "RoundRobin"
+	// BalancingPolicyLeastConnections gives new connections to the endpoint
+	// with the fewest open connections through the proxy.
+	BalancingPolicyLeastConnections BalancingPolicyType = "LeastConnections"
+	// BalancingPolicyRandom gives new connections to a random endpoint.
+	BalancingPolicyRandom BalancingPolicyType = "Random"
+)
+
+// Endpoints is a collection of endpoints that implement the actual service, for example:
+// Name: "mysql", Endpoints: ["10.10.1.1:1909", "10.10.2.2:8834"]
+type Endpoints struct {
+	JSONBase  `json:",inline" yaml:",inline"`
+	Endpoints []string `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+}
+
+// EndpointsList is a list of endpoints.
+type EndpointsList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []Endpoints `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+// Minion is a worker node in Kubernetenes.
+// The name of the minion according to etcd is in JSONBase.ID.
+type Minion struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	// Labels describe the minion, e.g. its hardware or zone, for pods to select
+	// it by. The kubelet reports them when registering.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Queried from cloud provider, if available.
+	HostIP string `json:"hostIP,omitempty" yaml:"hostIP,omitempty"`
+	// NodeResources are the resources of the minion, as reported by its kubelet.
+	NodeResources NodeResources `json:"resources,omitempty" yaml:"resources,omitempty"`
+	// Status is the minion's health. The kubelet reports its own view of it when
+	// registering, which the master combines with whether heartbeats arrive.
+	Status MinionStatus `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// ResourceName is the name of a resource of a minion.
+type ResourceName string
+
+const (
+	// ResourceCPU is the CPU of a minion, in millicores.
+	ResourceCPU ResourceName = "cpu"
+	// ResourceMemory is the memory of a minion, in bytes.
+	ResourceMemory ResourceName = "memory"
+)
+
+// ResourceList is a set of resources and their quantities.
+type ResourceList map[ResourceName]int64
+
+// NodeResources describe the resources of a minion.
+type NodeResources struct {
+	// Capacity is the total amount of each resource the minion has.
+	Capacity ResourceList `json:"capacity,omitempty" yaml:"capacity,omitempty"`
+}
+
+// MinionCondition describes whether a minion can be given pods.
+type MinionCondition string
+
+// These are the valid conditions of a minion.
+const (
+	// MinionReady means the minion has sent a heartbeat recently, or the master
+	// doesn't track heartbeats.
+	MinionReady MinionCondition = "Ready"
+	// MinionNotReady means the minion has missed its heartbeats, and won't be
+	// given new pods until it is heard from again.
+	MinionNotReady MinionCondition = "NotReady"
+)
+
+// MinionStatus is the master's view of a minion's health.
+type MinionStatus struct {
+	Condition MinionCondition `json:"condition,omitempty" yaml:"condition,omitempty"`
+	// LastHeartbeatTime is when the minion last registered itself, if ever.
+	LastHeartbeatTime util.Time `json:"lastHeartbeatTime,omitempty" yaml:"lastHeartbeatTime,omitempty"`
+	// Addresses are the IP addresses the kubelet found on the minion.
+	Addresses []string `json:"addresses,omitempty" yaml:"addresses,omitempty"`
+	// KubeletVersion is the version of the kubelet running on the minion.
+	KubeletVersion string `json:"kubeletVersion,omitempty" yaml:"kubeletVersion,omitempty"`
+}
+
+// MinionList is a list of minions.
+type MinionList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []Minion `json:"minions,omitempty" yaml:"minions,omitempty"`
+}
+
+// Binding is written by a scheduler to cause a pod to be bound to a host.
+type Binding struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	PodID    string `json:"podID" yaml:"podID"`
+	Host     string `json:"host" yaml:"host"`
+}
+
+// Resize is written to change the number of replicas of a replication
+// controller, leaving the rest of the controller as it is.
+type Resize struct {
+	JSONBase     `json:",inline" yaml:",inline"`
+	ControllerID string `json:"controllerID" yaml:"controllerID"`
+	Replicas     int    `json:"replicas" yaml:"replicas"`
+}
+
+// PodStatusReport is sent by a kubelet to tell the master about the containers
+// of one of its pods. The name of the pod is in JSONBase.ID.
+type PodStatusReport struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Host     string  `json:"host" yaml:"host"`
+	Info     PodInfo `json:"info,omitempty" yaml:"info,omitempty"`
+	// Conditions are the conditions the kubelet observed for the pod.
+	Conditions []PodCondition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	// Waiting describes the containers the kubelet fails to start.
+	Waiting map[string]ContainerWaiting `json:"waiting,omitempty" yaml:"waiting,omitempty"`
+	// PodIP is the IP address of the pod's network container, which all its
+	// containers share. Empty if the pod has none yet.
+	PodIP string `json:"podIP,omitempty" yaml:"podIP,omitempty"`
+}
+
+// ObjectReference points to an object, or to a part of one.
+type ObjectReference struct {
+	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	ID   string `json:"id,omitempty" yaml:"id,omitempty"`
+	// FieldPath names the part of the object meant, if not all of it, e.g.
+	// "desiredState.manifest.containers[web]".
+	FieldPath string `json:"fieldPath,omitempty" yaml:"fieldPath,omitempty"`
+}
+
+// Event is a report of something which happened to an object, such as one of
+// a pod's containers being started or failing its liveness probe. Events are
+// only kept for a limited time.
+type Event struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	// InvolvedObject is the object the event happened to.
+	InvolvedObject ObjectReference `json:"involvedObject,omitempty" yaml:"involvedObject,omitempty"`
+	// Reason is a short, machine understandable description of what happened,
+	// e.g. "started".
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	// Message is a human readable description of what happened.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// Source names the component which reported the event, e.g. "kubelet minion-1".
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	// Timestamp is when the event happened.
+	Timestamp util.Time `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
+}
+
+// EventList is a list of events.
+type EventList struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	Items    []Event `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+// Status is a return value for calls that don't return other objects.
+// TODO: this could go in apiserver, but I'm including it here so clients needn't
+// import both.
+type Status struct {
+	JSONBase `json:",inline" yaml:",inline"`
+	// One of: "success", "failure", "working" (for operations not yet completed)
+	Status string `json:"status,omitempty" yaml:"status,omitempty"`
+	// A human readable description of the status of this operation.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// A machine readable description of why this operation is in the
+	// "failure" or "working" status. If this value is empty there
+	// is no information available. A Reason clarifies an HTTP status
+	// code but does not override it.
+	Reason ReasonType `json:"reason,omitempty" yaml:"reason,omitempty"`
+	// Extended data associated with the reason.  Each reason may define its
+	// own extended details. This field is optional and the data returned
+	// is not guaranteed to conform to any schema except that defined by
+	// the reason type.
+	Details *StatusDetails `json:"details,omitempty" yaml:"details,omitempty"`
+	// Suggested HTTP return code for this status, 0 if not set.
+	Code int `json:"code,omitempty" yaml:"code,omitempty"`
+}
+
+// StatusDetails is a set of additional properties that MAY be set by the
+// server to provide additional information about a response. The Reason
+// field of a Status object defines what attributes will be set. Clients
+// must ignore fields that do not match the defined type of each attribute,
+// and should assume that any attribute may be empty, invalid, or under
+// defined.
+type StatusDetails struct {
+	// The ID attribute of the resource associated with the status ReasonType
+	// (when there is a single ID which can be described).
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// The kind attribute of the resource associated with the status ReasonType.
+	// On some operations may differ from the requested resource Kind.
+	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	// The Causes array includes more details associated with the ReasonTypeInvalid
+	// failure. Not all ReasonTypes may provide detailed causes.
+	Causes []StatusCause `json:"causes,omitempty" yaml:"causes,omitempty"`
+	// If specified, the time in seconds before the operation should be retried.
+	RetryAfter int `json:"retryAfter,omitempty" yaml:"retryAfter,omitempty"`
+}
+
+// Values of Status.Status
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusWorking = "working"
+)
+
+// ReasonType is an enumeration of possible failure causes.  Each ReasonType
+// must map to a single HTTP status code, but multiple reasons may map
+// to the same HTTP status code.
+// TODO: move to apiserver
+type ReasonType string
+
+const (
+	// ReasonTypeUnknown means the server has declined to indicate a specific reason.
+	// The details field may contain other information about this error.
+	// Status code 500.
+	ReasonTypeUnknown ReasonType = ""
+
+	// ReasonTypeWorking means the server is processing this request and will complete
+	// at a future time.
+	// Details (optional):
+	//   "kind" string - the name of the resource being referenced ("operation" today)
+	//   "id"   string - the identifier of the Operation resource where updates
+	//                   will be returned
+	// Headers (optional):
+	//   "Location" - HTTP header populated with a URL that can retrieved the final
+	//                status of this operation.
+	// Status code 202
+	ReasonTypeWorking ReasonType = "working"
+
+	// ResourceTypeNotFound means one or more resources required for this operation
+	// could not be found.
+	// Details (optional):
+	//   "kind" string - the kind attribute of the missing resource
+	//                   on some operations may differ from the requested
+	//                   resource.
+	//   "id"   string - the identifier of the missing resource
+	// Status code 404
+	ReasonTypeNotFound ReasonType = "not_found"
+
+	// ReasonTypeAlreadyExists means the resource you are creating already exists.
+	// Details (optional):
+	//   "kind" string - the kind attribute of the conflicting resource
+	//   "id"   string - the identifier of the conflicting resource
+	// Status code 409
+	ReasonTypeAlreadyExists ReasonType = "already_exists"
+
+	// ResourceTypeConflict means the requested update operation cannot be completed
+	// due to a conflict in the operation. The client may need to alter the request.
+	// Each resource may define custom details that indicate the nature of the
+	// conflict.
+	// Status code 409
+	ReasonTypeConflict ReasonType = "conflict"
+
+	// ReasonTypeInvalid means the requested create or update operation cannot be
+	// completed due to invalid data provided as part of the request. The client may
+	// need to alter the request.
+	// Details (optional):
+	//   "kind"   string - the kind attribute of the invalid resource
+	//   "id"     string - the identifier of the invalid resource
+	//   "causes"        - one or more StatusCause entries indicating the data in the
+	//                     provided resource that was invalid.
+	// Status code 422
+	ReasonTypeInvalid ReasonType = "invalid"
+
+	// ReasonTypeServerTimeout means the server can be reached and understood the
+	// request, but cannot complete the action in a reasonable time. The client
+	// should retry the request. This may be due to temporary server load or a
+	// transient communication issue with another server.
+	// Details (optional):
+	//   "kind"       string - the kind attribute of the resource being acted on.
+	//   "id"         string - the operation that is being attempted.
+	//   "retryAfter" int    - the number of seconds before the client should retry.
+	// Status code 500
+	ReasonTypeServerTimeout ReasonType = "server_timeout"
+)
+
+// StatusCause provides more information about an api.Status failure, including
+// cases when multiple errors are encountered.
+type StatusCause struct {
+	// A machine-readable description of the cause of the error. If this value is
+	// empty there is no information available.
+	Type CauseType `json:"reason,omitempty" yaml:"reason,omitempty"`
+	// A human-readable description of the cause of the error.  This field may be
+	// presented as-is to a reader.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// The field of the resource that has caused this error, as named by its JSON
+	// serialization. May include dot and postfix notation for nested attributes.
+	// Arrays are zero-indexed.  Fields may appear more than once in an array of
+	// causes due to fields having multiple errors.
+	// Examples:
+	//   "name" - the field "name" on the current resource
+	//   "items[0].name" - the field "name" on the first array entry in "items"
+	Field string `json:"field,omitempty" yaml:"field,omitempty"`
+}
+
+// CauseType is a machine readable value providing more detail about what
+// occured in a status response. An operation may have multiple causes for a
+// status (whether failure, success, or working).
+type CauseType string
+
+const (
+	// CauseTypeFieldValueNotFound is used to report failure to find a requested value
+	// (e.g. looking up an ID).
+	CauseTypeFieldValueNotFound CauseType = "field_value_not_found"
+	// CauseTypeFieldValueInvalid is used to report malformed values (e.g. failed regex
+	// match) or missing "required" fields.
+	CauseTypeFieldValueInvalid CauseType = "field_value_invalid"
+	// CauseTypeFieldValueNotSupported is used to report valid (as per formatting rules)
+	// values that can not be handled (e.g. an enumerated string).
+	CauseTypeFieldValueNotSupported CauseType = "field_value_not_supported"
+	// CauseTypeFieldValueDuplicate is used to report collisions of values that must be
+	// unique (e.g. unique IDs).
+	CauseTypeFieldValueDuplicate CauseType = "field_value_duplicate"
+)
+
+// ServerOp is an operation delivered to API clients.
+type ServerOp struct {
+	JSONBase `yaml:",inline" json:",inline"`
+	// The resource the operation acts on, e.g. "pods".
+	Resource string `yaml:"resource,omitempty" json:"resource,omitempty"`
+	// The apiserver which runs the operation.
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
+	// One of: "success", "failure", "working" (for operations not yet completed)
+	Status string `yaml:"status,omitempty" json:"status,omitempty"`
+	// For failed operations, a human readable description of the failure.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+	// For finished operations, the HTTP return code of the result, 0 if not set.
+	Code int `yaml:"code,omitempty" json:"code,omitempty"`
+}
+
+// ServerOpList is a list of operations, as delivered to API clients.
+type ServerOpList struct {
+	JSONBase `yaml:",inline" json:",inline"`
+	Items    []ServerOp `yaml:"items,omitempty" json:"items,omitempty"`
+}
+
+// WatchEvent objects are streamed from the api server in response to a watch request.
+type WatchEvent struct {
+	// The type of the watch event; added, modified, or deleted.
+	Type watch.EventType
+
+	// For added or modified objects, this is the new object; for deleted objects,
+	// it's the state of the object immediately prior to its deletion.
+	Object APIObject
+}
+
+// APIObject has appropriate encoder and decoder functions, such that on the wire, it's
+// stored as a []byte, but in memory, the contained object is accessable as an interface{}
+// via the Get() function. Only objects having a JSONBase may be stored via APIObject.
+// The purpose of this is to allow an API object of type known only at runtime to be
+// embedded within other API objects.
+type APIObject struct {
+	Object interface{}
+}
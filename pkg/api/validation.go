@@ -17,6 +17,9 @@ limitations under the License.
 package api
 
 import (
+	"net"
+	"path"
+	"strconv"
 	"strings"
 
 	errs "github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
@@ -61,6 +64,14 @@ func validateSource(source *VolumeSource) errs.ErrorList {
 		numVolumes++
 		//EmptyDirs have nothing to validate
 	}
+	if source.GCEPersistentDisk != nil {
+		numVolumes++
+		allErrs = append(allErrs, validateGCEPersistentDisk(source.GCEPersistentDisk)...)
+	}
+	if source.NFS != nil {
+		numVolumes++
+		allErrs = append(allErrs, validateNFS(source.NFS)...)
+	}
 	if numVolumes != 1 {
 		allErrs = append(allErrs, errs.NewInvalid("Volume.Source", source))
 	}
@@ -75,6 +86,40 @@ func validateHostDir(hostDir *HostDirectory) errs.ErrorList {
 	return allErrs
 }
 
+func validateGCEPersistentDisk(PD *GCEPersistentDisk) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if PD.PDName == "" {
+		allErrs = append(allErrs, errs.NewNotFound("PD.PDName", PD.PDName))
+	} else if !util.IsDNSLabel(PD.PDName) {
+		allErrs = append(allErrs, errs.NewInvalid("PD.PDName", PD.PDName))
+	}
+	if PD.FSType == "" {
+		allErrs = append(allErrs, errs.NewNotFound("PD.FSType", PD.FSType))
+	}
+	if PD.Partition < 0 || PD.Partition > 255 {
+		allErrs = append(allErrs, errs.NewInvalid("PD.Partition", PD.Partition))
+	}
+	return allErrs
+}
+
+func validateNFS(nfs *NFSMount) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if nfs.Server == "" {
+		allErrs = append(allErrs, errs.NewNotFound("NFS.Server", nfs.Server))
+	}
+	if nfs.Path == "" {
+		allErrs = append(allErrs, errs.NewNotFound("NFS.Path", nfs.Path))
+	} else if !path.IsAbs(nfs.Path) {
+		allErrs = append(allErrs, errs.NewInvalid("NFS.Path", nfs.Path))
+	}
+	for _, option := range nfs.Options {
+		if option == "" || option == "ro" || option == "rw" || strings.ContainsAny(option, ", \t\n") {
+			allErrs = append(allErrs, errs.NewInvalid("NFS.Options", option))
+		}
+	}
+	return allErrs
+}
+
 var supportedPortProtocols = util.NewStringSet("TCP", "UDP")
 
 func validatePorts(ports []Port) errs.ErrorList {
@@ -196,6 +241,20 @@ func validateContainers(containers []Container, volumes util.StringSet) errs.Err
 		allErrs = append(allErrs, validatePorts(ctr.Ports)...)
 		allErrs = append(allErrs, validateEnv(ctr.Env)...)
 		allErrs = append(allErrs, validateVolumeMounts(ctr.VolumeMounts, volumes)...)
+		switch ctr.ImagePullPolicy {
+		case "", PullAlways, PullNever, PullIfNotPresent:
+		default:
+			allErrs = append(allErrs, errs.NewNotSupported("Container.ImagePullPolicy", ctr.ImagePullPolicy))
+		}
+		if ctr.Lifecycle != nil {
+			allErrs = append(allErrs, validateLifecycle(ctr.Lifecycle)...)
+		}
+		if ctr.LivenessProbe != nil {
+			allErrs = append(allErrs, validateProbe("Container.LivenessProbe", ctr.LivenessProbe)...)
+		}
+		if ctr.ReadinessProbe != nil {
+			allErrs = append(allErrs, validateProbe("Container.ReadinessProbe", ctr.ReadinessProbe)...)
+		}
 	}
 	// Check for colliding ports across all containers.
 	// TODO(thockin): This really is dependent on the network config of the host (IP per pod?)
@@ -207,6 +266,53 @@ func validateContainers(containers []Container, volumes util.StringSet) errs.Err
 	return allErrs
 }
 
+func validateLifecycle(lifecycle *Lifecycle) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if lifecycle.PostStart != nil {
+		allErrs = append(allErrs, validateHandler("Container.Lifecycle.PostStart", lifecycle.PostStart)...)
+	}
+	if lifecycle.PreStop != nil {
+		allErrs = append(allErrs, validateHandler("Container.Lifecycle.PreStop", lifecycle.PreStop)...)
+	}
+	return allErrs
+}
+
+// validateHandler checks that handler has exactly one action, and that the action
+// is complete.
+func validateHandler(field string, handler *Handler) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	actions := 0
+	if handler.Exec != nil {
+		actions++
+		if len(handler.Exec.Command) == 0 {
+			allErrs = append(allErrs, errs.NewInvalid(field+".Exec.Command", handler.Exec.Command))
+		}
+	}
+	if handler.HTTPGet != nil {
+		actions++
+		if handler.HTTPGet.Port.Kind == util.IntstrInt && handler.HTTPGet.Port.IntVal <= 0 ||
+			handler.HTTPGet.Port.Kind == util.IntstrString && handler.HTTPGet.Port.StrVal == "" {
+			allErrs = append(allErrs, errs.NewInvalid(field+".HTTPGet.Port", handler.HTTPGet.Port))
+		}
+	}
+	if actions != 1 {
+		allErrs = append(allErrs, errs.NewInvalid(field, handler))
+	}
+	return allErrs
+}
+
+// validateProbe checks the thresholds of a liveness or readiness probe. A probe
+// without a FailureThreshold fails the container on its first failure.
+func validateProbe(field string, probe *LivenessProbe) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = 1
+	} else if probe.FailureThreshold < 0 {
+		allErrs = append(allErrs, errs.NewInvalid(field+".FailureThreshold", probe.FailureThreshold))
+	}
+	return allErrs
+}
+
 var supportedManifestVersions = util.NewStringSet("v1beta1", "v1beta2")
 
 // ValidateManifest tests that the specified ContainerManifest has valid data.
@@ -221,6 +327,13 @@ func ValidateManifest(manifest *ContainerManifest) errs.ErrorList {
 	} else if !supportedManifestVersions.Has(strings.ToLower(manifest.Version)) {
 		allErrs = append(allErrs, errs.NewNotSupported("ContainerManifest.Version", manifest.Version))
 	}
+	// An empty policy is left for the kubelet to treat as RestartAlways.
+	if manifest.RestartPolicy.Type != "" {
+		allErrs = append(allErrs, validateRestartPolicy("ContainerManifest.RestartPolicy.Type", manifest.RestartPolicy)...)
+	}
+	if manifest.TerminationGracePeriodSeconds != nil && *manifest.TerminationGracePeriodSeconds < 0 {
+		allErrs = append(allErrs, errs.NewInvalid("ContainerManifest.TerminationGracePeriodSeconds", *manifest.TerminationGracePeriodSeconds))
+	}
 	allVolumes, errs := validateVolumes(manifest.Volumes)
 	if len(errs) != 0 {
 		allErrs = append(allErrs, errs...)
@@ -233,15 +346,20 @@ func ValidatePodState(podState *PodState) errs.ErrorList {
 	allErrs := errs.ErrorList(ValidateManifest(&podState.Manifest))
 	if podState.RestartPolicy.Type == "" {
 		podState.RestartPolicy.Type = RestartAlways
-	} else if podState.RestartPolicy.Type != RestartAlways &&
-		podState.RestartPolicy.Type != RestartOnFailure &&
-		podState.RestartPolicy.Type != RestartNever {
-		allErrs = append(allErrs, errs.NewNotSupported("PodState.RestartPolicy.Type", podState.RestartPolicy.Type))
 	}
+	allErrs = append(allErrs, validateRestartPolicy("PodState.RestartPolicy.Type", podState.RestartPolicy)...)
 
 	return allErrs
 }
 
+func validateRestartPolicy(field string, policy RestartPolicy) errs.ErrorList {
+	switch policy.Type {
+	case RestartAlways, RestartOnFailure, RestartNever:
+		return nil
+	}
+	return errs.ErrorList{errs.NewNotSupported(field, policy.Type)}
+}
+
 // Pod tests if required fields in the pod are set.
 func ValidatePod(pod *Pod) errs.ErrorList {
 	allErrs := errs.ErrorList{}
@@ -252,6 +370,128 @@ func ValidatePod(pod *Pod) errs.ErrorList {
 	return allErrs
 }
 
+// ValidateMinion tests if required fields in the minion are set.
+func ValidateMinion(minion *Minion) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if minion.ID == "" {
+		allErrs = append(allErrs, errs.NewInvalid("Minion.ID", minion.ID))
+	}
+	switch minion.Status.Condition {
+	case "", MinionReady, MinionNotReady:
+	default:
+		allErrs = append(allErrs, errs.NewNotSupported("Minion.Status.Condition", minion.Status.Condition))
+	}
+	for name, value := range minion.NodeResources.Capacity {
+		if name != ResourceCPU && name != ResourceMemory {
+			allErrs = append(allErrs, errs.NewNotSupported("Minion.NodeResources.Capacity", name))
+		} else if value < 0 {
+			allErrs = append(allErrs, errs.NewInvalid("Minion.NodeResources.Capacity."+string(name), value))
+		}
+	}
+	return allErrs
+}
+
+// ValidateBinding tests if required fields in the binding are set.
+func ValidateBinding(binding *Binding) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if binding.PodID == "" {
+		allErrs = append(allErrs, errs.NewInvalid("Binding.PodID", binding.PodID))
+	}
+	if binding.Host == "" {
+		allErrs = append(allErrs, errs.NewInvalid("Binding.Host", binding.Host))
+	}
+	return allErrs
+}
+
+// ValidateResize tests if required fields in the resize are set.
+func ValidateResize(resize *Resize) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if resize.ControllerID == "" {
+		allErrs = append(allErrs, errs.NewInvalid("Resize.ControllerID", resize.ControllerID))
+	}
+	if resize.Replicas < 0 {
+		allErrs = append(allErrs, errs.NewInvalid("Resize.Replicas", resize.Replicas))
+	}
+	return allErrs
+}
+
+// ValidatePodStatusReport tests if required fields in the report are set.
+func ValidatePodStatusReport(report *PodStatusReport) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if report.ID == "" {
+		allErrs = append(allErrs, errs.NewInvalid("PodStatusReport.ID", report.ID))
+	}
+	if report.Host == "" {
+		allErrs = append(allErrs, errs.NewInvalid("PodStatusReport.Host", report.Host))
+	}
+	for _, condition := range report.Conditions {
+		if condition != PodReady {
+			allErrs = append(allErrs, errs.NewNotSupported("PodStatusReport.Conditions", condition))
+		}
+	}
+	return allErrs
+}
+
+// ValidateEndpoints tests if the endpoints are named like a service, and are
+// all a host and a valid port.
+func ValidateEndpoints(endpoints *Endpoints) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if !util.IsDNS952Label(endpoints.ID) {
+		allErrs = append(allErrs, errs.NewInvalid("Endpoints.ID", endpoints.ID))
+	}
+	for _, endpoint := range endpoints.Endpoints {
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil || host == "" {
+			allErrs = append(allErrs, errs.NewInvalid("Endpoints.Endpoints", endpoint))
+			continue
+		}
+		if portNum, err := strconv.Atoi(port); err != nil || !util.IsValidPortNum(portNum) {
+			allErrs = append(allErrs, errs.NewInvalid("Endpoints.Endpoints", endpoint))
+		}
+	}
+	return allErrs
+}
+
+// ValidateEvent tests if required fields in the event are set.
+func ValidateEvent(event *Event) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if event.InvolvedObject.Kind == "" {
+		allErrs = append(allErrs, errs.NewInvalid("Event.InvolvedObject.Kind", event.InvolvedObject.Kind))
+	}
+	if event.InvolvedObject.ID == "" {
+		allErrs = append(allErrs, errs.NewInvalid("Event.InvolvedObject.ID", event.InvolvedObject.ID))
+	}
+	if event.Reason == "" {
+		allErrs = append(allErrs, errs.NewInvalid("Event.Reason", event.Reason))
+	}
+	return allErrs
+}
+
+var supportedSessionAffinityTypes = util.NewStringSet(string(AffinityTypeClientIP), string(AffinityTypeNone))
+
+var supportedBalancingPolicies = util.NewStringSet(
+	string(BalancingPolicyRoundRobin), string(BalancingPolicyLeastConnections), string(BalancingPolicyRandom))
+
+var supportedClientSourceIPTypes = util.NewStringSet(string(ClientSourceIPProxyProtocol), string(ClientSourceIPNone))
+
+// isEmptyPort returns true if port is neither a port number nor a port name.
+func isEmptyPort(port util.IntOrString) bool {
+	if port.Kind == util.IntstrString {
+		return len(port.StrVal) == 0
+	}
+	return port.IntVal == 0
+}
+
+// ServiceTargetPort returns the container port service sends traffic to.
+// Services stored before TargetPort existed only have the deprecated
+// ContainerPort.
+func ServiceTargetPort(service *Service) util.IntOrString {
+	if isEmptyPort(service.TargetPort) {
+		return service.ContainerPort
+	}
+	return service.TargetPort
+}
+
 // ValidateService tests if required fields in the service are set.
 func ValidateService(service *Service) errs.ErrorList {
 	allErrs := errs.ErrorList{}
@@ -260,8 +500,54 @@ func ValidateService(service *Service) errs.ErrorList {
 	} else if !util.IsDNS952Label(service.ID) {
 		allErrs = append(allErrs, errs.NewInvalid("Service.ID", service.ID))
 	}
-	if labels.Set(service.Selector).AsSelector().Empty() {
-		allErrs = append(allErrs, errs.NewInvalid("Service.Selector", service.Selector))
+	// Keep the deprecated ContainerPort for the clients which still read it.
+	service.TargetPort = ServiceTargetPort(service)
+	service.ContainerPort = service.TargetPort
+	if !isEmptyPort(service.TargetPort) {
+		if service.TargetPort.Kind == util.IntstrInt && !util.IsValidPortNum(service.TargetPort.IntVal) {
+			allErrs = append(allErrs, errs.NewInvalid("Service.TargetPort", service.TargetPort))
+		} else if service.TargetPort.Kind == util.IntstrString && !util.IsDNSLabel(service.TargetPort.StrVal) {
+			allErrs = append(allErrs, errs.NewInvalid("Service.TargetPort", service.TargetPort))
+		}
+	}
+	for _, ip := range service.PublicIPs {
+		if net.ParseIP(ip) == nil {
+			allErrs = append(allErrs, errs.NewInvalid("Service.PublicIPs", ip))
+		}
+	}
+	if service.PortalIP == PortalIPNone {
+		// Nothing proxies headless services, so there is nothing to expose.
+		if len(service.PublicIPs) > 0 {
+			allErrs = append(allErrs, errs.NewInvalid("Service.PublicIPs", service.PublicIPs))
+		}
+		if service.CreateExternalLoadBalancer {
+			allErrs = append(allErrs, errs.NewInvalid("Service.CreateExternalLoadBalancer", service.CreateExternalLoadBalancer))
+		}
+	} else if service.PortalIP != "" && net.ParseIP(service.PortalIP) == nil {
+		allErrs = append(allErrs, errs.NewInvalid("Service.PortalIP", service.PortalIP))
+	}
+	if len(service.Protocol) == 0 {
+		service.Protocol = "TCP"
+	} else if !supportedPortProtocols.Has(strings.ToUpper(service.Protocol)) {
+		allErrs = append(allErrs, errs.NewNotSupported("Service.Protocol", service.Protocol))
+	}
+	if len(service.SessionAffinity) == 0 {
+		service.SessionAffinity = AffinityTypeNone
+	} else if !supportedSessionAffinityTypes.Has(string(service.SessionAffinity)) {
+		allErrs = append(allErrs, errs.NewNotSupported("Service.SessionAffinity", service.SessionAffinity))
+	}
+	if len(service.BalancingPolicy) == 0 {
+		service.BalancingPolicy = BalancingPolicyRoundRobin
+	} else if !supportedBalancingPolicies.Has(string(service.BalancingPolicy)) {
+		allErrs = append(allErrs, errs.NewNotSupported("Service.BalancingPolicy", service.BalancingPolicy))
+	}
+	if len(service.ClientSourceIP) == 0 {
+		service.ClientSourceIP = ClientSourceIPNone
+	} else if !supportedClientSourceIPTypes.Has(string(service.ClientSourceIP)) {
+		allErrs = append(allErrs, errs.NewNotSupported("Service.ClientSourceIP", service.ClientSourceIP))
+	} else if service.ClientSourceIP == ClientSourceIPProxyProtocol && strings.ToUpper(service.Protocol) != "TCP" {
+		// The PROXY protocol only has a header for streams.
+		allErrs = append(allErrs, errs.NewInvalid("Service.ClientSourceIP", service.ClientSourceIP))
 	}
 	return allErrs
 }
@@ -286,3 +572,20 @@ func ValidateReplicationController(controller *ReplicationController) errs.Error
 	allErrs = append(allErrs, ValidateManifest(&controller.DesiredState.PodTemplate.DesiredState.Manifest)...)
 	return allErrs
 }
+
+// ValidateDaemonController tests if required fields in the daemon controller are set.
+func ValidateDaemonController(controller *DaemonController) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if controller.ID == "" {
+		allErrs = append(allErrs, errs.NewInvalid("DaemonController.ID", controller.ID))
+	}
+	selector := labels.Set(controller.DesiredState.ReplicaSelector).AsSelector()
+	if selector.Empty() {
+		allErrs = append(allErrs, errs.NewInvalid("DaemonController.ReplicaSelector", controller.DesiredState.ReplicaSelector))
+	}
+	if !selector.Matches(labels.Set(controller.DesiredState.PodTemplate.Labels)) {
+		allErrs = append(allErrs, errs.NewInvalid("DaemonController.DesiredState.PodTemplate.Labels", controller.DesiredState.PodTemplate))
+	}
+	allErrs = append(allErrs, ValidateManifest(&controller.DesiredState.PodTemplate.DesiredState.Manifest)...)
+	return allErrs
+}
@@ -31,12 +31,14 @@ func TestValidateVolumes(t *testing.T) {
 		{Name: "123", Source: &VolumeSource{HostDirectory: &HostDirectory{"/mnt/path2"}}},
 		{Name: "abc-123", Source: &VolumeSource{HostDirectory: &HostDirectory{"/mnt/path3"}}},
 		{Name: "empty", Source: &VolumeSource{EmptyDirectory: &EmptyDirectory{}}},
+		{Name: "gcepd", Source: &VolumeSource{GCEPersistentDisk: &GCEPersistentDisk{"my-pd", "ext4", 1, false}}},
+		{Name: "nfs", Source: &VolumeSource{NFS: &NFSMount{"nfs.example.com", "/exports/data", true, []string{"vers=3", "soft"}}}},
 	}
 	names, errs := validateVolumes(successCase)
 	if len(errs) != 0 {
 		t.Errorf("expected success: %v", errs)
 	}
-	if len(names) != 4 || !names.HasAll("abc", "123", "abc-123", "empty") {
+	if len(names) != 6 || !names.HasAll("abc", "123", "abc-123", "empty", "gcepd", "nfs") {
 		t.Errorf("wrong names result: %v", names)
 	}
 
@@ -45,6 +47,18 @@ func TestValidateVolumes(t *testing.T) {
 		"name > 63 characters": {{Name: strings.Repeat("a", 64)}},
 		"name not a DNS label": {{Name: "a.b.c"}},
 		"name not unique":      {{Name: "abc"}, {Name: "abc"}},
+		"pd without name":      {{Name: "pd", Source: &VolumeSource{GCEPersistentDisk: &GCEPersistentDisk{FSType: "ext4"}}}},
+		"pd bad name":          {{Name: "pd", Source: &VolumeSource{GCEPersistentDisk: &GCEPersistentDisk{"../my-pd", "ext4", 0, false}}}},
+		"pd without fstype":    {{Name: "pd", Source: &VolumeSource{GCEPersistentDisk: &GCEPersistentDisk{PDName: "my-pd"}}}},
+		"pd bad partition":     {{Name: "pd", Source: &VolumeSource{GCEPersistentDisk: &GCEPersistentDisk{"my-pd", "ext4", -1, false}}}},
+		"nfs without server":   {{Name: "nfs", Source: &VolumeSource{NFS: &NFSMount{Path: "/exports"}}}},
+		"nfs relative path":    {{Name: "nfs", Source: &VolumeSource{NFS: &NFSMount{Server: "nfs", Path: "exports"}}}},
+		"nfs rw option":        {{Name: "nfs", Source: &VolumeSource{NFS: &NFSMount{Server: "nfs", Path: "/exports", Options: []string{"rw"}}}}},
+		"nfs joined options":   {{Name: "nfs", Source: &VolumeSource{NFS: &NFSMount{Server: "nfs", Path: "/exports", Options: []string{"soft,intr"}}}}},
+		"two sources": {{Name: "pd", Source: &VolumeSource{
+			EmptyDirectory:    &EmptyDirectory{},
+			GCEPersistentDisk: &GCEPersistentDisk{"my-pd", "ext4", 0, false},
+		}}},
 	}
 	for k, v := range errorCases {
 		if _, errs := validateVolumes(v); len(errs) == 0 {
@@ -195,10 +209,19 @@ func TestValidateContainers(t *testing.T) {
 		{Name: "abc", Image: "image"},
 		{Name: "123", Image: "image"},
 		{Name: "abc-123", Image: "image"},
+		{Name: "pull", Image: "image", ImagePullPolicy: PullIfNotPresent},
+		{Name: "exec-hook", Image: "image", Lifecycle: &Lifecycle{PreStop: &Handler{Exec: &ExecProbe{Command: []string{"ls", "-l"}}}}},
+		{Name: "http-hook", Image: "image", Lifecycle: &Lifecycle{PreStop: &Handler{HTTPGet: &HTTPGetProbe{Path: "/quit", Port: util.NewIntOrStringFromInt(80)}}}},
+		{Name: "post-start", Image: "image", Lifecycle: &Lifecycle{PostStart: &Handler{Exec: &ExecProbe{Command: []string{"warmup"}}}}},
+		{Name: "liveness", Image: "image", LivenessProbe: &LivenessProbe{Type: "exec", Exec: &ExecProbe{Command: []string{"true"}}, FailureThreshold: 3}},
+		{Name: "readiness", Image: "image", ReadinessProbe: &LivenessProbe{Type: "exec", Exec: &ExecProbe{Command: []string{"true"}}}},
 	}
 	if errs := validateContainers(successCase, volumes); len(errs) != 0 {
 		t.Errorf("expected success: %v", errs)
 	}
+	if successCase[7].LivenessProbe.FailureThreshold != 3 || successCase[8].ReadinessProbe.FailureThreshold != 1 {
+		t.Errorf("unexpected failure thresholds: %d, %d", successCase[7].LivenessProbe.FailureThreshold, successCase[8].ReadinessProbe.FailureThreshold)
+	}
 
 	errorCases := map[string][]Container{
 		"zero-length name":     {{Name: "", Image: "image"}},
@@ -219,6 +242,30 @@ func TestValidateContainers(t *testing.T) {
 		"unknown volume name": {
 			{Name: "abc", Image: "image", VolumeMounts: []VolumeMount{{Name: "anything", MountPath: "/foo"}}},
 		},
+		"invalid image pull policy": {
+			{Name: "abc", Image: "image", ImagePullPolicy: "Sometimes"},
+		},
+		"pre-stop hook without an action": {
+			{Name: "abc", Image: "image", Lifecycle: &Lifecycle{PreStop: &Handler{}}},
+		},
+		"pre-stop hook with two actions": {
+			{Name: "abc", Image: "image", Lifecycle: &Lifecycle{PreStop: &Handler{
+				Exec:    &ExecProbe{Command: []string{"ls"}},
+				HTTPGet: &HTTPGetProbe{Port: util.NewIntOrStringFromInt(80)},
+			}}},
+		},
+		"pre-stop hook with an empty command": {
+			{Name: "abc", Image: "image", Lifecycle: &Lifecycle{PreStop: &Handler{Exec: &ExecProbe{}}}},
+		},
+		"post-start hook without an action": {
+			{Name: "abc", Image: "image", Lifecycle: &Lifecycle{PostStart: &Handler{}}},
+		},
+		"pre-stop hook without a port": {
+			{Name: "abc", Image: "image", Lifecycle: &Lifecycle{PreStop: &Handler{HTTPGet: &HTTPGetProbe{Path: "/quit"}}}},
+		},
+		"negative failure threshold": {
+			{Name: "abc", Image: "image", LivenessProbe: &LivenessProbe{Type: "exec", Exec: &ExecProbe{Command: []string{"true"}}, FailureThreshold: -1}},
+		},
 	}
 	for k, v := range errorCases {
 		if errs := validateContainers(v, volumes); len(errs) == 0 {
@@ -227,11 +274,17 @@ func TestValidateContainers(t *testing.T) {
 	}
 }
 
+func newInt64(i int64) *int64 {
+	return &i
+}
+
 func TestValidateManifest(t *testing.T) {
 	successCases := []ContainerManifest{
 		{Version: "v1beta1", ID: "abc"},
 		{Version: "v1beta2", ID: "123"},
 		{Version: "V1BETA1", ID: "abc.123.do-re-mi"},
+		{Version: "v1beta1", ID: "abc", RestartPolicy: RestartPolicy{Type: RestartNever}},
+		{Version: "v1beta1", ID: "abc", TerminationGracePeriodSeconds: newInt64(30)},
 		{
 			Version: "v1beta1",
 			ID:      "abc",
@@ -272,6 +325,16 @@ func TestValidateManifest(t *testing.T) {
 	errorCases := map[string]ContainerManifest{
 		"empty version":   {Version: "", ID: "abc"},
 		"invalid version": {Version: "bogus", ID: "abc"},
+		"invalid restart policy": {
+			Version:       "v1beta1",
+			ID:            "abc",
+			RestartPolicy: RestartPolicy{Type: "WhatEver"},
+		},
+		"negative termination grace period": {
+			Version:                       "v1beta1",
+			ID:                            "abc",
+			TerminationGracePeriodSeconds: newInt64(-1),
+		},
 		"invalid volume name": {
 			Version: "v1beta1",
 			ID:      "abc",
@@ -352,17 +415,304 @@ func TestValidateService(t *testing.T) {
 		t.Errorf("Unexpected error list: %#v", errs)
 	}
 
+	// Services without a selector have their endpoints set through the API.
 	errs = ValidateService(&Service{
 		JSONBase: JSONBase{ID: "foo"},
 	})
-	if len(errs) != 1 {
+	if len(errs) != 0 {
 		t.Errorf("Unexpected error list: %#v", errs)
 	}
 
 	errs = ValidateService(&Service{})
+	if len(errs) != 1 {
+		t.Errorf("Unexpected error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase: JSONBase{ID: "foo"},
+		Selector: map[string]string{"foo": "bar"},
+		PortalIP: "10.0.0.1",
+	})
+	if len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase: JSONBase{ID: "foo"},
+		Selector: map[string]string{"foo": "bar"},
+		PortalIP: "10.0.0",
+	})
+	if len(errs) != 1 {
+		t.Errorf("Unexpected error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase: JSONBase{ID: "foo"},
+		Selector: map[string]string{"foo": "bar"},
+		PortalIP: PortalIPNone,
+	})
+	if len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase:                   JSONBase{ID: "foo"},
+		Selector:                   map[string]string{"foo": "bar"},
+		PortalIP:                   PortalIPNone,
+		PublicIPs:                  []string{"1.2.3.4"},
+		CreateExternalLoadBalancer: true,
+	})
 	if len(errs) != 2 {
 		t.Errorf("Unexpected error list: %#v", errs)
 	}
+
+	service := Service{
+		JSONBase: JSONBase{ID: "foo"},
+		Selector: map[string]string{"foo": "bar"},
+	}
+	if errs := ValidateService(&service); len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+	if service.Protocol != "TCP" {
+		t.Errorf("Expected default protocol TCP, got %q", service.Protocol)
+	}
+	if service.SessionAffinity != AffinityTypeNone {
+		t.Errorf("Expected default session affinity None, got %q", service.SessionAffinity)
+	}
+	if service.BalancingPolicy != BalancingPolicyRoundRobin {
+		t.Errorf("Expected default balancing policy RoundRobin, got %q", service.BalancingPolicy)
+	}
+	if service.ClientSourceIP != ClientSourceIPNone {
+		t.Errorf("Expected default client source IP None, got %q", service.ClientSourceIP)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase:       JSONBase{ID: "foo"},
+		Selector:       map[string]string{"foo": "bar"},
+		ClientSourceIP: ClientSourceIPProxyProtocol,
+	})
+	if len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase:       JSONBase{ID: "foo"},
+		Selector:       map[string]string{"foo": "bar"},
+		Protocol:       "UDP",
+		ClientSourceIP: ClientSourceIPProxyProtocol,
+	})
+	if len(errs) != 1 {
+		t.Errorf("Unexpected error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase:       JSONBase{ID: "foo"},
+		Selector:       map[string]string{"foo": "bar"},
+		ClientSourceIP: "Transparent",
+	})
+	if len(errs) != 1 {
+		t.Errorf("Unexpected error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase: JSONBase{ID: "foo"},
+		Selector: map[string]string{"foo": "bar"},
+		Protocol: "UDP",
+	})
+	if len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase: JSONBase{ID: "foo"},
+		Selector: map[string]string{"foo": "bar"},
+		Protocol: "SCTP",
+	})
+	if len(errs) != 1 {
+		t.Errorf("Unexpected error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase:        JSONBase{ID: "foo"},
+		Selector:        map[string]string{"foo": "bar"},
+		SessionAffinity: AffinityTypeClientIP,
+	})
+	if len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase:        JSONBase{ID: "foo"},
+		Selector:        map[string]string{"foo": "bar"},
+		SessionAffinity: "Cookie",
+	})
+	if len(errs) != 1 {
+		t.Errorf("Unexpected error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase:        JSONBase{ID: "foo"},
+		Selector:        map[string]string{"foo": "bar"},
+		BalancingPolicy: BalancingPolicyLeastConnections,
+	})
+	if len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase:        JSONBase{ID: "foo"},
+		Selector:        map[string]string{"foo": "bar"},
+		BalancingPolicy: "WeightedRoundRobin",
+	})
+	if len(errs) != 1 {
+		t.Errorf("Unexpected error list: %#v", errs)
+	}
+
+	service = Service{
+		JSONBase:   JSONBase{ID: "foo"},
+		Selector:   map[string]string{"foo": "bar"},
+		TargetPort: util.NewIntOrStringFromString("http"),
+	}
+	if errs := ValidateService(&service); len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+	if service.ContainerPort != service.TargetPort {
+		t.Errorf("Expected ContainerPort to follow TargetPort, got %#v", service.ContainerPort)
+	}
+
+	// Old clients only set ContainerPort.
+	service = Service{
+		JSONBase:      JSONBase{ID: "foo"},
+		Selector:      map[string]string{"foo": "bar"},
+		ContainerPort: util.NewIntOrStringFromInt(8080),
+	}
+	if errs := ValidateService(&service); len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+	if service.TargetPort != util.NewIntOrStringFromInt(8080) {
+		t.Errorf("Expected TargetPort 8080, got %#v", service.TargetPort)
+	}
+
+	errs = ValidateService(&Service{
+		JSONBase:  JSONBase{ID: "foo"},
+		Selector:  map[string]string{"foo": "bar"},
+		PublicIPs: []string{"1.2.3.4", "1.2.3"},
+	})
+	if len(errs) != 1 {
+		t.Errorf("Unexpected error list: %#v", errs)
+	}
+
+	for _, port := range []util.IntOrString{util.NewIntOrStringFromInt(70000), util.NewIntOrStringFromString("not_a_name")} {
+		errs = ValidateService(&Service{
+			JSONBase:   JSONBase{ID: "foo"},
+			Selector:   map[string]string{"foo": "bar"},
+			TargetPort: port,
+		})
+		if len(errs) != 1 {
+			t.Errorf("Unexpected error list for %#v: %#v", port, errs)
+		}
+	}
+}
+
+func TestValidateMinion(t *testing.T) {
+	if errs := ValidateMinion(&Minion{JSONBase: JSONBase{ID: "foo"}}); len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+	if errs := ValidateMinion(&Minion{}); len(errs) != 1 {
+		t.Errorf("Unexpected error list: %#v", errs)
+	}
+	reported := Minion{
+		JSONBase:      JSONBase{ID: "foo"},
+		NodeResources: NodeResources{Capacity: ResourceList{ResourceCPU: 2000, ResourceMemory: 1024}},
+		Status:        MinionStatus{Condition: MinionNotReady, Addresses: []string{"10.0.0.1"}, KubeletVersion: "v0.4"},
+	}
+	if errs := ValidateMinion(&reported); len(errs) != 0 {
+		t.Errorf("Unexpected non-zero error list: %#v", errs)
+	}
+	errorCases := map[string]Minion{
+		"bad condition":     {JSONBase: JSONBase{ID: "foo"}, Status: MinionStatus{Condition: "Sleepy"}},
+		"unknown resource":  {JSONBase: JSONBase{ID: "foo"}, NodeResources: NodeResources{Capacity: ResourceList{"disk": 1}}},
+		"negative capacity": {JSONBase: JSONBase{ID: "foo"}, NodeResources: NodeResources{Capacity: ResourceList{ResourceMemory: -1}}},
+	}
+	for k, v := range errorCases {
+		if errs := ValidateMinion(&v); len(errs) != 1 {
+			t.Errorf("%s: expected one error, got %#v", k, errs)
+		}
+	}
+}
+
+func TestValidateBinding(t *testing.T) {
+	table := []struct {
+		binding Binding
+		errs    int
+	}{
+		{Binding{PodID: "foo", Host: "bar"}, 0},
+		{Binding{PodID: "foo"}, 1},
+		{Binding{Host: "bar"}, 1},
+		{Binding{}, 2},
+	}
+	for _, item := range table {
+		if errs := ValidateBinding(&item.binding); len(errs) != item.errs {
+			t.Errorf("%#v: expected %d errors, got %#v", item.binding, item.errs, errs)
+		}
+	}
+}
+
+func TestValidateResize(t *testing.T) {
+	table := []struct {
+		resize Resize
+		errs   int
+	}{
+		{Resize{ControllerID: "foo", Replicas: 3}, 0},
+		{Resize{ControllerID: "foo"}, 0},
+		{Resize{ControllerID: "foo", Replicas: -1}, 1},
+		{Resize{Replicas: 3}, 1},
+		{Resize{Replicas: -1}, 2},
+	}
+	for _, item := range table {
+		if errs := ValidateResize(&item.resize); len(errs) != item.errs {
+			t.Errorf("%#v: expected %d errors, got %#v", item.resize, item.errs, errs)
+		}
+	}
+}
+
+func TestValidatePodStatusReport(t *testing.T) {
+	table := []struct {
+		report PodStatusReport
+		errs   int
+	}{
+		{PodStatusReport{JSONBase: JSONBase{ID: "foo"}, Host: "bar"}, 0},
+		{PodStatusReport{JSONBase: JSONBase{ID: "foo"}}, 1},
+		{PodStatusReport{Host: "bar"}, 1},
+		{PodStatusReport{}, 2},
+		{PodStatusReport{JSONBase: JSONBase{ID: "foo"}, Host: "bar", Conditions: []PodCondition{PodReady}}, 0},
+		{PodStatusReport{JSONBase: JSONBase{ID: "foo"}, Host: "bar", Conditions: []PodCondition{"Happy"}}, 1},
+	}
+	for _, item := range table {
+		if errs := ValidatePodStatusReport(&item.report); len(errs) != item.errs {
+			t.Errorf("%#v: expected %d errors, got %#v", item.report, item.errs, errs)
+		}
+	}
+}
+
+func TestValidateEvent(t *testing.T) {
+	pod := ObjectReference{Kind: "Pod", ID: "foo"}
+	table := []struct {
+		event Event
+		errs  int
+	}{
+		{Event{InvolvedObject: pod, Reason: "started"}, 0},
+		{Event{InvolvedObject: ObjectReference{Kind: "Pod", ID: "foo", FieldPath: "desiredState.manifest.containers[bar]"}, Reason: "started"}, 0},
+		{Event{InvolvedObject: ObjectReference{ID: "foo"}, Reason: "started"}, 1},
+		{Event{InvolvedObject: ObjectReference{Kind: "Pod"}, Reason: "started"}, 1},
+		{Event{InvolvedObject: pod}, 1},
+		{Event{}, 3},
+	}
+	for _, item := range table {
+		if errs := ValidateEvent(&item.event); len(errs) != item.errs {
+			t.Errorf("%#v: expected %d errors, got %#v", item.event, item.errs, errs)
+		}
+	}
 }
 
 func TestValidateReplicationController(t *testing.T) {
@@ -439,3 +789,86 @@ func TestValidateReplicationController(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateDaemonController(t *testing.T) {
+	validSelector := map[string]string{"a": "b"}
+	validPodTemplate := PodTemplate{
+		DesiredState: PodState{
+			Manifest: ContainerManifest{
+				Version: "v1beta1",
+			},
+		},
+		Labels:       validSelector,
+		NodeSelector: map[string]string{"logs": "shipped"},
+	}
+
+	successCase := DaemonController{
+		JSONBase: JSONBase{ID: "abc"},
+		DesiredState: DaemonControllerState{
+			ReplicaSelector: validSelector,
+			PodTemplate:     validPodTemplate,
+		},
+	}
+	if errs := ValidateDaemonController(&successCase); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+
+	errorCases := map[string]DaemonController{
+		"zero-length ID": {
+			DesiredState: DaemonControllerState{
+				ReplicaSelector: validSelector,
+				PodTemplate:     validPodTemplate,
+			},
+		},
+		"empty selector": {
+			JSONBase: JSONBase{ID: "abc"},
+			DesiredState: DaemonControllerState{
+				PodTemplate: validPodTemplate,
+			},
+		},
+		"selector_doesnt_match": {
+			JSONBase: JSONBase{ID: "abc"},
+			DesiredState: DaemonControllerState{
+				ReplicaSelector: map[string]string{"foo": "bar"},
+				PodTemplate:     validPodTemplate,
+			},
+		},
+		"invalid manifest": {
+			JSONBase: JSONBase{ID: "abc"},
+			DesiredState: DaemonControllerState{
+				ReplicaSelector: validSelector,
+			},
+		},
+	}
+	for k, v := range errorCases {
+		if errs := ValidateDaemonController(&v); len(errs) == 0 {
+			t.Errorf("expected failure for %s", k)
+		}
+	}
+}
+
+func TestValidateEndpoints(t *testing.T) {
+	successCases := []Endpoints{
+		{JSONBase: JSONBase{ID: "foo"}},
+		{JSONBase: JSONBase{ID: "foo"}, Endpoints: []string{"10.1.2.3:3306", "db.example.com:5432", "[::1]:80"}},
+	}
+	for _, endpoints := range successCases {
+		if errs := ValidateEndpoints(&endpoints); len(errs) != 0 {
+			t.Errorf("expected success for %v: %v", endpoints, errs)
+		}
+	}
+
+	errorCases := map[string]Endpoints{
+		"no ID":    {Endpoints: []string{"10.1.2.3:3306"}},
+		"no port":  {JSONBase: JSONBase{ID: "foo"}, Endpoints: []string{"10.1.2.3"}},
+		"no host":  {JSONBase: JSONBase{ID: "foo"}, Endpoints: []string{":3306"}},
+		"bad port": {JSONBase: JSONBase{ID: "foo"}, Endpoints: []string{"10.1.2.3:mysql"}},
+		"port 0":   {JSONBase: JSONBase{ID: "foo"}, Endpoints: []string{"10.1.2.3:0"}},
+		"big port": {JSONBase: JSONBase{ID: "foo"}, Endpoints: []string{"10.1.2.3:65536"}},
+	}
+	for k, endpoints := range errorCases {
+		if errs := ValidateEndpoints(&endpoints); len(errs) != 1 {
+			t.Errorf("%s: expected one failure, got %v", k, errs)
+		}
+	}
+}
@@ -22,13 +22,18 @@ import (
 	"io/ioutil"
 	"net/http"
 	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/healthz"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/httplog"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/version"
 	"github.com/golang/glog"
+	"gopkg.in/v1/yaml"
 )
 
 // Codec defines methods for serializing and deserializing API
@@ -55,7 +60,12 @@ type defaultAPIServer struct {
 // as RESTful resources at prefix, serialized by codec, and also includes the support
 // http resources.
 func Handle(storage map[string]RESTStorage, codec Codec, prefix string) http.Handler {
-	group := NewAPIGroup(storage, codec)
+	return HandleWithOperations(storage, codec, prefix, NewOperations())
+}
+
+// HandleWithOperations is like Handle, but tracks asynchronous operations in ops.
+func HandleWithOperations(storage map[string]RESTStorage, codec Codec, prefix string, ops *Operations) http.Handler {
+	group := NewAPIGroupWithOperations(storage, codec, ops)
 
 	mux := http.NewServeMux()
 	group.InstallREST(mux, prefix)
@@ -64,6 +74,33 @@ func Handle(storage map[string]RESTStorage, codec Codec, prefix string) http.Han
 	return &defaultAPIServer{RecoverPanics(mux), group}
 }
 
+// HandleVersions returns a Handler which serves each of the given API groups at
+// prefix/<version>, an index of the versions at prefix, and the support http resources.
+func HandleVersions(groups map[string]*APIGroup, prefix string) http.Handler {
+	prefix = strings.TrimRight(prefix, "/")
+	mux := http.NewServeMux()
+	versions := []string{}
+	for version, group := range groups {
+		group.InstallREST(mux, prefix+"/"+version)
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	mux.Handle(prefix, APIVersionHandler{versions})
+	InstallSupport(mux)
+
+	return RecoverPanics(mux)
+}
+
+// APIVersionHandler serves the list of API versions the server supports.
+type APIVersionHandler struct {
+	versions []string
+}
+
+// ServeHTTP implements http.Handler.
+func (v APIVersionHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	writeRawJSON(http.StatusOK, api.APIVersions{Versions: v.versions}, w)
+}
+
 // APIGroup is a http.Handler that exposes multiple RESTStorage objects
 // It handles URLs of the form:
 // /${storage_key}[/${object_name}]
@@ -80,10 +117,16 @@ type APIGroup struct {
 // prefixes onto a server.
 // TODO: add multitype codec serialization
 func NewAPIGroup(storage map[string]RESTStorage, codec Codec) *APIGroup {
+	return NewAPIGroupWithOperations(storage, codec, NewOperations())
+}
+
+// NewAPIGroupWithOperations is like NewAPIGroup, but tracks asynchronous operations
+// in ops, which may be shared with other groups.
+func NewAPIGroupWithOperations(storage map[string]RESTStorage, codec Codec, ops *Operations) *APIGroup {
 	return &APIGroup{RESTHandler{
 		storage: storage,
 		codec:   codec,
-		ops:     NewOperations(),
+		ops:     ops,
 		// Delay just long enough to handle most simple write operations
 		asyncOpWait: time.Millisecond * 25,
 	}}
@@ -109,6 +152,7 @@ func (g *APIGroup) InstallREST(mux mux, paths ...string) {
 // InstallSupport registers the APIServer support functions into a mux.
 func InstallSupport(mux mux) {
 	healthz.InstallHandler(mux)
+	metrics.InstallHandler(mux)
 	mux.Handle("/logs/", http.StripPrefix("/logs/", http.FileServer(http.Dir("/var/log/"))))
 	mux.Handle("/proxy/minion/", http.StripPrefix("/proxy/minion", http.HandlerFunc(handleProxyMinion)))
 	mux.HandleFunc("/version", handleVersion)
@@ -131,6 +175,7 @@ func RecoverPanics(handler http.Handler) http.Handler {
 				http.StatusAccepted,
 				http.StatusConflict,
 				http.StatusNotFound,
+				StatusUnprocessableEntity,
 			),
 		).Log()
 
@@ -139,6 +184,21 @@ func RecoverPanics(handler http.Handler) http.Handler {
 	})
 }
 
+// ReadOnly wraps an http Handler so that only GET requests, which include gets, lists
+// and watches, and HEAD requests reach it. Other requests are refused with 405 Method
+// Not Allowed.
+func ReadOnly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			fmt.Fprintf(w, "This is a read-only endpoint; %s is not allowed.", req.Method)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
 // handleVersionReq writes the server's version information.
 func handleVersion(w http.ResponseWriter, req *http.Request) {
 	writeRawJSON(http.StatusOK, version.Get(), w)
@@ -146,20 +206,81 @@ func handleVersion(w http.ResponseWriter, req *http.Request) {
 
 // writeJSON renders an object as JSON to the response
 func writeJSON(statusCode int, codec Codec, object interface{}, w http.ResponseWriter) {
-	output, err := codec.Encode(object)
+	writeEncoded(statusCode, codec, object, w, false)
+}
+
+// errorJSON renders an error to the response
+func errorJSON(err error, codec Codec, w http.ResponseWriter) {
+	status := errToAPIStatus(err)
+	writeJSON(status.Code, codec, status, w)
+}
+
+// wantsYAML returns true if the client asked for a YAML response, either with
+// an Accept header or with the query parameter output=yaml.
+func wantsYAML(req *http.Request) bool {
+	if req.URL.Query().Get("output") == "yaml" {
+		return true
+	}
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.Split(accept, ";")[0])
+		switch mediaType {
+		case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+			return true
+		}
+	}
+	return false
+}
+
+// writeObject renders an object to the response, as YAML if req asks for it and
+// as JSON otherwise.
+func writeObject(statusCode int, codec Codec, object interface{}, w http.ResponseWriter, req *http.Request) {
+	writeEncoded(statusCode, codec, object, w, wantsYAML(req))
+}
+
+// writeEncoded writes object as JSON or YAML. If object can't be encoded, the
+// error is written in its place; if that fails too, a plain text 500 is.
+func writeEncoded(statusCode int, codec Codec, object interface{}, w http.ResponseWriter, asYAML bool) {
+	output, contentType, err := encodeObject(codec, object, asYAML)
 	if err != nil {
-		errorJSON(err, codec, w)
-		return
+		status := errToAPIStatus(err)
+		if output, contentType, err = encodeObject(codec, status, asYAML); err != nil {
+			http.Error(w, fmt.Sprintf("unable to encode response: %v", err), http.StatusInternalServerError)
+			return
+		}
+		statusCode = status.Code
 	}
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(statusCode)
 	w.Write(output)
 }
 
-// errorJSON renders an error to the response
-func errorJSON(err error, codec Codec, w http.ResponseWriter) {
+// encodeObject returns object encoded as JSON or YAML, and its content type.
+func encodeObject(codec Codec, object interface{}, asYAML bool) ([]byte, string, error) {
+	output, err := codec.Encode(object)
+	if err != nil {
+		return nil, "", err
+	}
+	if !asYAML {
+		return output, "application/json", nil
+	}
+	// Go through a generic value, so the YAML uses the same field names as the JSON.
+	var generic interface{}
+	if err := json.Unmarshal(output, &generic); err != nil {
+		return nil, "", err
+	}
+	if output, err = yaml.Marshal(generic); err != nil {
+		return nil, "", err
+	}
+	return output, "application/yaml", nil
+}
+
+// writeError renders an error to the response, in the format req asks for.
+func writeError(err error, codec Codec, w http.ResponseWriter, req *http.Request) {
 	status := errToAPIStatus(err)
-	writeJSON(status.Code, codec, status, w)
+	if status.Details != nil && status.Details.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(status.Details.RetryAfter))
+	}
+	writeObject(status.Code, codec, status, w, req)
 }
 
 // writeRawJSON writes a non-API object in JSON.
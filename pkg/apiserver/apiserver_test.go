@@ -32,8 +32,11 @@ import (
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/version"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+
+	"github.com/coreos/go-etcd/etcd"
 )
 
 func convert(obj interface{}) (interface{}, error) {
@@ -45,6 +48,7 @@ var codec = api.Codec
 func init() {
 	api.AddKnownTypes("", Simple{}, SimpleList{})
 	api.AddKnownTypes("v1beta1", Simple{}, SimpleList{})
+	api.AddKnownTypes("v1beta2", Simple{}, SimpleList{})
 }
 
 type Simple struct {
@@ -254,6 +258,118 @@ func TestErrorList(t *testing.T) {
 	}
 }
 
+// fieldListingStorage is a SimpleRESTStorage which can select on fields.
+type fieldListingStorage struct {
+	SimpleRESTStorage
+}
+
+func (storage *fieldListingStorage) ListWithFields(label, field labels.Selector) (interface{}, error) {
+	storage.requestedLabelSelector = label
+	storage.requestedFieldSelector = field
+	return storage.List(label)
+}
+
+func TestListWithFields(t *testing.T) {
+	fieldStorage := &fieldListingStorage{}
+	handler := Handle(map[string]RESTStorage{"simple": &SimpleRESTStorage{}, "fields": fieldStorage}, codec, "/prefix/version")
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/prefix/version/fields?labels=a%3Db&fields=Host%3Dmachine")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status: %d, Expected: %d", resp.StatusCode, http.StatusOK)
+	}
+	if e, a := "a=b", fieldStorage.requestedLabelSelector.String(); e != a {
+		t.Errorf("Expected label selector %v, got %v", e, a)
+	}
+	if e, a := "Host=machine", fieldStorage.requestedFieldSelector.String(); e != a {
+		t.Errorf("Expected field selector %v, got %v", e, a)
+	}
+
+	// Storage which can't select on fields refuses to, rather than listing everything.
+	resp, err = http.Get(server.URL + "/prefix/version/simple?fields=Host%3Dmachine")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != 422 {
+		t.Errorf("Unexpected status: %d, Expected: %d", resp.StatusCode, 422)
+	}
+}
+
+// catchingUpStorage is a SimpleRESTStorage whose lists get one version newer
+// with every read.
+type catchingUpStorage struct {
+	SimpleRESTStorage
+	version uint64
+	reads   int
+}
+
+func (storage *catchingUpStorage) List(labels.Selector) (interface{}, error) {
+	storage.reads++
+	storage.version++
+	return &SimpleList{JSONBase: api.JSONBase{ResourceVersion: storage.version}}, nil
+}
+
+func TestListBadResourceVersion(t *testing.T) {
+	handler := Handle(map[string]RESTStorage{"simple": &SimpleRESTStorage{}}, codec, "/prefix/version")
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/prefix/version/simple?resourceVersion=abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestListNotOlderThan(t *testing.T) {
+	listRetryPeriod = time.Millisecond
+	table := []struct {
+		minVersion uint64
+		timeout    string
+		status     int
+		reads      int
+	}{
+		{0, "", http.StatusOK, 1},
+		{1, "", http.StatusOK, 1},
+		{3, "", http.StatusOK, 3},
+		{1000, "20ms", http.StatusInternalServerError, 0},
+	}
+	for _, item := range table {
+		storage := &catchingUpStorage{}
+		handler := Handle(map[string]RESTStorage{"simple": storage}, codec, "/prefix/version")
+		server := httptest.NewServer(handler)
+		resp, err := http.Get(fmt.Sprintf("%s/prefix/version/simple?resourceVersion=%d&timeout=%s", server.URL, item.minVersion, item.timeout))
+		server.Close()
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		if resp.StatusCode != item.status {
+			t.Errorf("%d: expected status %d, got %d", item.minVersion, item.status, resp.StatusCode)
+		}
+		if item.reads != 0 && storage.reads != item.reads {
+			t.Errorf("%d: expected %d reads, got %d", item.minVersion, item.reads, storage.reads)
+		}
+		if item.status != http.StatusOK {
+			continue
+		}
+		var list SimpleList
+		body, _ := ioutil.ReadAll(resp.Body)
+		if err := codec.DecodeInto(body, &list); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if list.ResourceVersion < item.minVersion {
+			t.Errorf("%d: got a list at version %d", item.minVersion, list.ResourceVersion)
+		}
+	}
+}
+
 func TestNonEmptyList(t *testing.T) {
 	storage := map[string]RESTStorage{}
 	simpleStorage := SimpleRESTStorage{
@@ -315,6 +431,194 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestGetYAML(t *testing.T) {
+	storage := map[string]RESTStorage{}
+	simpleStorage := SimpleRESTStorage{
+		item: Simple{
+			Name: "foo",
+		},
+		errors: map[string]error{"list": NewNotFoundErr("simple", "list")},
+	}
+	storage["simple"] = &simpleStorage
+	handler := Handle(storage, codec, "/prefix/version")
+	server := httptest.NewServer(handler)
+	client := http.Client{}
+
+	table := []struct {
+		path   string
+		accept string
+		code   int
+		yaml   bool
+	}{
+		{"/prefix/version/simple/id", "", http.StatusOK, false},
+		{"/prefix/version/simple/id", "application/yaml", http.StatusOK, true},
+		{"/prefix/version/simple/id", "text/html, application/x-yaml;q=0.9", http.StatusOK, true},
+		{"/prefix/version/simple/id?output=yaml", "", http.StatusOK, true},
+		{"/prefix/version/simple?output=yaml", "", http.StatusNotFound, true},
+	}
+	for _, item := range table {
+		request, err := http.NewRequest("GET", server.URL+item.path, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if item.accept != "" {
+			request.Header.Set("Accept", item.accept)
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.StatusCode != item.code {
+			t.Errorf("%s: expected %d, got %d", item.path, item.code, response.StatusCode)
+		}
+		contentType := "application/json"
+		if item.yaml {
+			contentType = "application/yaml"
+		}
+		if e, a := contentType, response.Header.Get("Content-Type"); e != a {
+			t.Errorf("%s (%s): expected %s, got %s", item.path, item.accept, e, a)
+		}
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if isJSON := bytes.HasPrefix(body, []byte("{")); isJSON == item.yaml {
+			t.Errorf("%s (%s): unexpected body %s", item.path, item.accept, string(body))
+		}
+		if item.code != http.StatusOK {
+			continue
+		}
+		var itemOut Simple
+		if err := codec.DecodeInto(body, &itemOut); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if itemOut.Name != simpleStorage.item.Name {
+			t.Errorf("Unexpected data: %#v, expected %#v (%s)", itemOut, simpleStorage.item, string(body))
+		}
+	}
+}
+
+func TestHandleVersions(t *testing.T) {
+	simpleStorage := &SimpleRESTStorage{
+		item: Simple{
+			Name: "foo",
+		},
+	}
+	storage := map[string]RESTStorage{"simple": simpleStorage}
+	ops := NewOperations()
+	groups := map[string]*APIGroup{}
+	for _, version := range api.Versions {
+		versionCodec, err := api.CodecForVersion(version)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		groups[version] = NewAPIGroupWithOperations(storage, versionCodec, ops)
+	}
+	server := httptest.NewServer(HandleVersions(groups, "/prefix"))
+
+	resp, err := http.Get(server.URL + "/prefix")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var versions api.APIVersions
+	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if !reflect.DeepEqual(api.Versions, versions.Versions) {
+		t.Errorf("expected versions %v, got %v", api.Versions, versions.Versions)
+	}
+
+	for _, version := range api.Versions {
+		resp, err := http.Get(server.URL + "/prefix/" + version + "/simple/id")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var itemOut Simple
+		body, err := extractBody(resp, &itemOut)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if itemOut.Name != simpleStorage.item.Name {
+			t.Errorf("Unexpected data: %#v, expected %#v (%s)", itemOut, simpleStorage.item, body)
+		}
+		if dataVersion, _, err := api.VersionAndKind([]byte(body)); err != nil || dataVersion != version {
+			t.Errorf("expected a %s object, got %s (%v)", version, body, err)
+		}
+	}
+}
+
+func TestGetRetryAfter(t *testing.T) {
+	storage := map[string]RESTStorage{}
+	simpleStorage := SimpleRESTStorage{
+		errors: map[string]error{"get": NewServerTimeoutErr("simple", "get", 5)},
+	}
+	storage["simple"] = &simpleStorage
+	handler := Handle(storage, codec, "/prefix/version")
+	server := httptest.NewServer(handler)
+
+	resp, err := http.Get(server.URL + "/prefix/version/simple/id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Unexpected response %#v", resp)
+	}
+	if e, a := "5", resp.Header.Get("Retry-After"); e != a {
+		t.Errorf("expected Retry-After %s, got %s", e, a)
+	}
+	var status api.Status
+	if _, err := extractBody(resp, &status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Reason != api.ReasonTypeServerTimeout || status.Details == nil || status.Details.RetryAfter != 5 {
+		t.Errorf("unexpected status: %#v", status)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	storage := map[string]RESTStorage{}
+	simpleStorage := SimpleRESTStorage{
+		item: Simple{
+			Name: "foo",
+		},
+	}
+	storage["simple"] = &simpleStorage
+	server := httptest.NewServer(ReadOnly(Handle(storage, codec, "/prefix/version")))
+	client := http.Client{}
+
+	table := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{"GET", "/prefix/version/simple", http.StatusOK},
+		{"GET", "/prefix/version/simple/id", http.StatusOK},
+		{"HEAD", "/prefix/version/simple/id", http.StatusOK},
+		{"POST", "/prefix/version/simple", http.StatusMethodNotAllowed},
+		{"PUT", "/prefix/version/simple/id", http.StatusMethodNotAllowed},
+		{"DELETE", "/prefix/version/simple/id", http.StatusMethodNotAllowed},
+	}
+	for _, item := range table {
+		request, err := http.NewRequest(item.method, server.URL+item.path, bytes.NewBufferString("{}"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != item.code {
+			t.Errorf("%s %s: expected %d, got %d", item.method, item.path, item.code, response.StatusCode)
+		}
+	}
+	if simpleStorage.deleted != "" || simpleStorage.created != nil || simpleStorage.updated != nil {
+		t.Errorf("unexpected change to storage: %#v", simpleStorage)
+	}
+}
+
 func TestGetMissing(t *testing.T) {
 	storage := map[string]RESTStorage{}
 	simpleStorage := SimpleRESTStorage{
@@ -584,6 +888,10 @@ func expectApiStatus(t *testing.T, method, url string, data []byte, code int) *a
 	return &status
 }
 
+var etcdNotReachable = &etcd.EtcdError{ErrorCode: etcd.ErrCodeEtcdNotReachable, Message: "All the given peers are not reachable"}
+
+var etcdTransportError = &tools.EtcdTransportError{Err: errors.New("connection refused")}
+
 func TestErrorsToAPIStatus(t *testing.T) {
 	cases := map[error]api.Status{
 		NewAlreadyExistsErr("foo", "bar"): {
@@ -606,6 +914,59 @@ func TestErrorsToAPIStatus(t *testing.T) {
 				ID:   "bar",
 			},
 		},
+		NewServerTimeoutErr("foo", "create", 2): {
+			Status:  api.StatusFailure,
+			Code:    http.StatusInternalServerError,
+			Reason:  "server_timeout",
+			Message: "The create operation against foo could not be completed at this time, please try again.",
+			Details: &api.StatusDetails{
+				Kind:       "foo",
+				ID:         "create",
+				RetryAfter: 2,
+			},
+		},
+		tools.EtcdErrorNotFound: {
+			Status:  api.StatusFailure,
+			Code:    http.StatusNotFound,
+			Reason:  "not_found",
+			Message: tools.EtcdErrorNotFound.Error(),
+		},
+		tools.EtcdErrorNodeExist: {
+			Status:  api.StatusFailure,
+			Code:    http.StatusConflict,
+			Reason:  "already_exists",
+			Message: tools.EtcdErrorNodeExist.Error(),
+		},
+		tools.EtcdErrorTestFailed: {
+			Status:  api.StatusFailure,
+			Code:    http.StatusConflict,
+			Reason:  "conflict",
+			Message: tools.EtcdErrorTestFailed.Error(),
+		},
+		etcdNotReachable: {
+			Status:  api.StatusFailure,
+			Code:    http.StatusInternalServerError,
+			Reason:  "server_timeout",
+			Message: etcdNotReachable.Error(),
+			Details: &api.StatusDetails{
+				RetryAfter: 1,
+			},
+		},
+		etcdTransportError: {
+			Status:  api.StatusFailure,
+			Code:    http.StatusInternalServerError,
+			Reason:  "server_timeout",
+			Message: etcdTransportError.Error(),
+			Details: &api.StatusDetails{
+				RetryAfter: 1,
+			},
+		},
+		errors.New("boom"): {
+			Status:  api.StatusFailure,
+			Code:    http.StatusInternalServerError,
+			Reason:  "",
+			Message: "boom",
+		},
 	}
 	for k, v := range cases {
 		actual := errToAPIStatus(k)
@@ -695,6 +1056,37 @@ func TestWriteJSONDecodeError(t *testing.T) {
 	}
 }
 
+type failingCodec struct {
+	Codec
+}
+
+func (failingCodec) Encode(interface{}) ([]byte, error) {
+	return nil, errors.New("broken codec")
+}
+
+func TestWriteObjectEncodeErrorTwice(t *testing.T) {
+	for _, accept := range []string{"application/json", "application/yaml"} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			writeObject(http.StatusOK, failingCodec{codec}, &Simple{Name: "foo"}, w, req)
+		}))
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		server.Close()
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+		if !strings.Contains(string(body), "broken codec") {
+			t.Errorf("unexpected body %q", body)
+		}
+	}
+}
+
 type marshalError struct {
 	err error
 }
@@ -718,6 +1110,32 @@ func TestWriteRAWJSONMarshalError(t *testing.T) {
 	}
 }
 
+func TestCreateYAML(t *testing.T) {
+	storage := SimpleRESTStorage{}
+	handler := Handle(map[string]RESTStorage{
+		"foo": &storage,
+	}, codec, "/prefix/version")
+	server := httptest.NewServer(handler)
+	client := http.Client{}
+
+	data := []byte("kind: Simple\napiVersion: v1beta1\nname: foo\n")
+	request, err := http.NewRequest("POST", server.URL+"/prefix/version/foo?sync=true", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/yaml")
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status: %d, Expected: %d, %#v", response.StatusCode, http.StatusOK, response)
+	}
+	if storage.created == nil || storage.created.Name != "foo" {
+		t.Errorf("Unexpected created object: %#v", storage.created)
+	}
+}
+
 func TestSyncCreateTimeout(t *testing.T) {
 	testOver := make(chan struct{})
 	defer close(testOver)
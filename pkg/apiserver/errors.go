@@ -21,9 +21,14 @@ import (
 	"net/http"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
 )
 
+// StatusUnprocessableEntity is the HTTP status code for a request whose body
+// is well formed but invalid.
+const StatusUnprocessableEntity = 422
+
 // apiServerError is an error intended for consumption by a REST API server
 type apiServerError struct {
 	api.Status
@@ -76,6 +81,68 @@ func NewConflictErr(kind, name string, err error) error {
 	}}
 }
 
+// NewInvalidErr returns an error indicating the item is invalid and cannot be processed.
+// Each error in errs is reported as one of the causes of the failure.
+func NewInvalidErr(kind, name string, errs errors.ErrorList) error {
+	causes := make([]api.StatusCause, 0, len(errs))
+	for i := range errs {
+		if err, ok := errs[i].(errors.ValidationError); ok {
+			causes = append(causes, api.StatusCause{
+				Type:    causeTypes[err.Type],
+				Message: err.Error(),
+				Field:   err.Field,
+			})
+		} else {
+			causes = append(causes, api.StatusCause{Message: errs[i].Error()})
+		}
+	}
+	return &apiServerError{api.Status{
+		Status: api.StatusFailure,
+		Code:   StatusUnprocessableEntity,
+		Reason: api.ReasonTypeInvalid,
+		Details: &api.StatusDetails{
+			Kind:   kind,
+			ID:     name,
+			Causes: causes,
+		},
+		Message: fmt.Sprintf("%s %q is invalid: %v", kind, name, errs.ToError()),
+	}}
+}
+
+// NewBadRequestErr returns an error indicating the request itself is malformed,
+// e.g. one of its query parameters.
+func NewBadRequestErr(err error) error {
+	return &apiServerError{api.Status{
+		Status:  api.StatusFailure,
+		Code:    http.StatusBadRequest,
+		Message: err.Error(),
+	}}
+}
+
+// NewServerTimeoutErr returns an error indicating the requested action could not be
+// completed in time, and that the client should try again after retryAfter seconds.
+func NewServerTimeoutErr(kind, operation string, retryAfter int) error {
+	return &apiServerError{api.Status{
+		Status: api.StatusFailure,
+		Code:   http.StatusInternalServerError,
+		Reason: api.ReasonTypeServerTimeout,
+		Details: &api.StatusDetails{
+			Kind:       kind,
+			ID:         operation,
+			RetryAfter: retryAfter,
+		},
+		Message: fmt.Sprintf("The %s operation against %s could not be completed at this time, please try again.", operation, kind),
+	}}
+}
+
+// causeTypes maps validation errors to the cause reported to API clients.
+var causeTypes = map[errors.ValidationErrorEnum]api.CauseType{
+	errors.Invalid:      api.CauseTypeFieldValueInvalid,
+	errors.NotSupported: api.CauseTypeFieldValueNotSupported,
+	errors.Duplicate:    api.CauseTypeFieldValueDuplicate,
+	errors.NotFound:     api.CauseTypeFieldValueNotFound,
+}
+
 // IsNotFound returns true if the specified error was created by NewNotFoundErr
 func IsNotFound(err error) bool {
 	return reasonForError(err) == api.ReasonTypeNotFound
@@ -86,6 +153,17 @@ func IsAlreadyExists(err error) bool {
 	return reasonForError(err) == api.ReasonTypeAlreadyExists
 }
 
+// IsInvalid determines if the err is an error which indicates the provided resource is not valid
+func IsInvalid(err error) bool {
+	return reasonForError(err) == api.ReasonTypeInvalid
+}
+
+// IsServerTimeout determines if err is an error which indicates that the request needs to be retried
+// by the client.
+func IsServerTimeout(err error) bool {
+	return reasonForError(err) == api.ReasonTypeServerTimeout
+}
+
 // IsConflict determines if the err is an error which indicates the provided update conflicts
 func IsConflict(err error) bool {
 	return reasonForError(err) == api.ReasonTypeConflict
@@ -109,20 +187,35 @@ func errToAPIStatus(err error) *api.Status {
 		return &status
 	default:
 		status := http.StatusInternalServerError
+		reason := api.ReasonTypeUnknown
+		var details *api.StatusDetails
+		// Storage errors which escaped the registries still get a reason, but
+		// we don't know which resource they were about.
 		switch {
-		//TODO: replace me with NewUpdateConflictErr
+		case tools.IsEtcdNotFound(err):
+			status, reason = http.StatusNotFound, api.ReasonTypeNotFound
+		case tools.IsEtcdNodeExist(err):
+			status, reason = http.StatusConflict, api.ReasonTypeAlreadyExists
 		case tools.IsEtcdTestFailed(err):
-			status = http.StatusConflict
+			status, reason = http.StatusConflict, api.ReasonTypeConflict
+		case tools.IsEtcdNotReachable(err):
+			reason = api.ReasonTypeServerTimeout
+			details = &api.StatusDetails{RetryAfter: storageRetryAfter}
 		}
 		return &api.Status{
 			Status:  api.StatusFailure,
 			Code:    status,
-			Reason:  api.ReasonTypeUnknown,
+			Reason:  reason,
+			Details: details,
 			Message: err.Error(),
 		}
 	}
 }
 
+// storageRetryAfter is how many seconds clients are asked to wait before retrying
+// a request which failed because storage couldn't be reached.
+const storageRetryAfter = 1
+
 // notFound renders a simple not found error
 func notFound(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
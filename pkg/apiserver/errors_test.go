@@ -18,7 +18,11 @@ package apiserver
 
 import (
 	"errors"
+	"reflect"
 	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	apierrors "github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
 )
 
 func TestErrorNew(t *testing.T) {
@@ -39,4 +43,77 @@ func TestErrorNew(t *testing.T) {
 	if !IsNotFound(NewNotFoundErr("test", "3")) {
 		t.Errorf("expected to be not found")
 	}
+	if !IsInvalid(NewInvalidErr("test", "4", apierrors.ErrorList{})) {
+		t.Errorf("expected to be invalid")
+	}
+	if !IsServerTimeout(NewServerTimeoutErr("test", "create", 0)) {
+		t.Errorf("expected to be a server timeout")
+	}
+}
+
+func TestNewInvalidErr(t *testing.T) {
+	testCases := []struct {
+		Err     apierrors.ValidationError
+		Details *api.StatusDetails
+	}{
+		{
+			apierrors.NewDuplicate("field[0].name", "bar"),
+			&api.StatusDetails{
+				Kind: "kind",
+				ID:   "name",
+				Causes: []api.StatusCause{{
+					Type:    api.CauseTypeFieldValueDuplicate,
+					Message: "field[0].name: duplicate value 'bar'",
+					Field:   "field[0].name",
+				}},
+			},
+		},
+		{
+			apierrors.NewInvalid("field[0].name", "bar"),
+			&api.StatusDetails{
+				Kind: "kind",
+				ID:   "name",
+				Causes: []api.StatusCause{{
+					Type:    api.CauseTypeFieldValueInvalid,
+					Message: "field[0].name: invalid value 'bar'",
+					Field:   "field[0].name",
+				}},
+			},
+		},
+		{
+			apierrors.NewNotFound("field[0].name", "bar"),
+			&api.StatusDetails{
+				Kind: "kind",
+				ID:   "name",
+				Causes: []api.StatusCause{{
+					Type:    api.CauseTypeFieldValueNotFound,
+					Message: "field[0].name: not found 'bar'",
+					Field:   "field[0].name",
+				}},
+			},
+		},
+		{
+			apierrors.NewNotSupported("field[0].name", "bar"),
+			&api.StatusDetails{
+				Kind: "kind",
+				ID:   "name",
+				Causes: []api.StatusCause{{
+					Type:    api.CauseTypeFieldValueNotSupported,
+					Message: "field[0].name: unsupported value 'bar'",
+					Field:   "field[0].name",
+				}},
+			},
+		},
+	}
+	for i, testCase := range testCases {
+		vErr, expected := testCase.Err, testCase.Details
+		err := NewInvalidErr("kind", "name", apierrors.ErrorList{vErr})
+		status := errToAPIStatus(err)
+		if status.Code != 422 || status.Reason != api.ReasonTypeInvalid {
+			t.Errorf("%d: unexpected status: %#v", i, status)
+		}
+		if !reflect.DeepEqual(expected, status.Details) {
+			t.Errorf("%d: expected %#v, got %#v", i, expected, status.Details)
+		}
+	}
 }
@@ -28,8 +28,8 @@ type RESTStorage interface {
 	// This object must be a pointer type for use with Codec.DecodeInto([]byte, interface{})
 	New() interface{}
 
-	// List selects resources in the storage which match to the selector.
-	// TODO: add field selector in addition to label selector.
+	// List selects resources in the storage which match to the selector. Storage
+	// which can also select on fields implements ResourceFieldLister.
 	List(labels.Selector) (interface{}, error)
 
 	// Get finds a resource in the storage by id and returns it.
@@ -46,6 +46,14 @@ type RESTStorage interface {
 	Update(interface{}) (<-chan interface{}, error)
 }
 
+// ResourceFieldLister may be implemented by RESTStorage objects whose lists can
+// also be selected on fields, like their watches.
+type ResourceFieldLister interface {
+	// ListWithFields is like List, but also selects on the object's fields with
+	// 'field'. Not all fields need be supported.
+	ListWithFields(label, field labels.Selector) (interface{}, error)
+}
+
 // ResourceWatcher should be implemented by all RESTStorage objects that
 // want to offer the ability to watch for changes through the watch api.
 type ResourceWatcher interface {
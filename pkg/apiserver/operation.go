@@ -17,6 +17,7 @@ limitations under the License.
 package apiserver
 
 import (
+	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
@@ -26,6 +27,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/golang/glog"
 )
 
 type OperationHandler struct {
@@ -33,6 +35,9 @@ type OperationHandler struct {
 	codec Codec
 }
 
+// ServeHTTP serves the operations list, which may be filtered with the query
+// parameters resource=<resource> and status=<working|success|failure>, and
+// individual operations.
 func (h *OperationHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	parts := splitPath(req.URL.Path)
 	if len(parts) > 1 || req.Method != "GET" {
@@ -41,28 +46,39 @@ func (h *OperationHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 	if len(parts) == 0 {
 		// List outstanding operations.
-		list := h.ops.List()
-		writeJSON(http.StatusOK, h.codec, list, w)
+		query := req.URL.Query()
+		list := h.ops.List(query.Get("resource"), query.Get("status"))
+		writeObject(http.StatusOK, h.codec, list, w, req)
 		return
 	}
 
 	op := h.ops.Get(parts[0])
 	if op == nil {
-		notFound(w, req)
+		// The operation may have been started by an earlier incarnation of this server.
+		record := h.ops.getRecord(parts[0])
+		if record == nil {
+			writeError(NewNotFoundErr("operation", parts[0]), h.codec, w, req)
+			return
+		}
+		status, code := recordStatus(record)
+		writeObject(code, h.codec, status, w, req)
 		return
 	}
 
 	obj, complete := op.StatusOrResult()
 	if complete {
-		writeJSON(http.StatusOK, h.codec, obj, w)
+		writeObject(http.StatusOK, h.codec, obj, w, req)
 	} else {
-		writeJSON(http.StatusAccepted, h.codec, obj, w)
+		writeObject(http.StatusAccepted, h.codec, obj, w, req)
 	}
 }
 
 // Operation represents an ongoing action which the server is performing.
 type Operation struct {
 	ID       string
+	resource string
+	owner    string
+	created  util.Time
 	result   interface{}
 	awaiting <-chan interface{}
 	finished *time.Time
@@ -70,6 +86,29 @@ type Operation struct {
 	notify   chan struct{}
 }
 
+// OperationRegistry stores records of operations, so that they can be listed and
+// looked up after they finish, including from a later incarnation of the server.
+type OperationRegistry interface {
+	// ListOperations returns all the operation records.
+	ListOperations() ([]api.ServerOp, error)
+	// GetOperation returns the record of the operation with the given ID.
+	GetOperation(id string) (*api.ServerOp, error)
+	// SaveOperation stores op, replacing any earlier record with the same ID. If ttl
+	// is nonzero, the record is removed once it is older than ttl.
+	SaveOperation(op *api.ServerOp, ttl time.Duration) error
+}
+
+// DefaultOperationTTL is how long finished operations are kept by default.
+const DefaultOperationTTL = 10 * time.Minute
+
+// listOperationsAttempts is how many times the recorded operations are listed
+// at startup before giving up, and listOperationsDelay is how long to wait
+// after the first failure. The delay doubles after each failure.
+var (
+	listOperationsAttempts = 5
+	listOperationsDelay    = time.Second
+)
+
 // Operations tracks all the ongoing operations.
 type Operations struct {
 	// Access only using functions from atomic.
@@ -78,27 +117,100 @@ type Operations struct {
 	// 'lock' guards the ops map.
 	lock sync.Mutex
 	ops  map[string]*Operation
+
+	// registry, if not nil, is sent a record of each operation as it starts and
+	// as it finishes.
+	registry OperationRegistry
+	// owner names this server in the records of its operations.
+	owner string
+	// ttl is how long an operation is kept after it finishes.
+	ttl time.Duration
 }
 
-// NewOperations returns a new Operations repository.
+// NewOperations returns a new Operations repository, which keeps operations in
+// memory only.
 func NewOperations() *Operations {
+	// Without a registry there's nothing to fail.
+	ops, _ := NewOperationsWithRegistry(nil, "", DefaultOperationTTL)
+	return ops
+}
+
+// NewOperationsWithRegistry returns a new Operations repository which also records
+// operations in registry, so that they survive server restarts. Finished operations
+// are forgotten after ttl. New operation IDs continue on from the highest ID found
+// in registry, so it is an error if registry can't be listed. The records name owner
+// as the server running the operations. Operations of owner which were still
+// working when it last stopped are recorded as failed; those of other servers
+// sharing registry are left to them.
+func NewOperationsWithRegistry(registry OperationRegistry, owner string, ttl time.Duration) (*Operations, error) {
+	if ttl <= 0 {
+		ttl = DefaultOperationTTL
+	}
 	ops := &Operations{
-		ops: map[string]*Operation{},
+		ops:      map[string]*Operation{},
+		registry: registry,
+		owner:    owner,
+		ttl:      ttl,
 	}
-	go util.Forever(func() { ops.expire(10 * time.Minute) }, 5*time.Minute)
-	return ops
+	if registry != nil {
+		records, err := listOperations(registry)
+		if err != nil {
+			return nil, err
+		}
+		for i := range records {
+			record := &records[i]
+			if id, err := strconv.ParseInt(record.ID, 10, 64); err == nil && id > ops.lastID {
+				ops.lastID = id
+			}
+			if record.Status != api.StatusWorking || record.Owner != owner {
+				continue
+			}
+			// Nothing is left to finish this operation.
+			record.Status = api.StatusFailure
+			record.Message = "the server restarted before the operation finished"
+			record.Code = http.StatusInternalServerError
+			if err := registry.SaveOperation(record, ttl); err != nil {
+				glog.Errorf("Unable to record orphaned operation %s as failed: %v", record.ID, err)
+			}
+		}
+	}
+	go util.Forever(func() { ops.expire(ttl) }, ttl/2)
+	return ops, nil
 }
 
-// NewOperation adds a new operation. It is lock-free.
-func (ops *Operations) NewOperation(from <-chan interface{}) *Operation {
+// listOperations lists the operations in registry, retrying failures.
+func listOperations(registry OperationRegistry) ([]api.ServerOp, error) {
+	delay := listOperationsDelay
+	for attempt := 1; ; attempt++ {
+		records, err := registry.ListOperations()
+		if err == nil {
+			return records, nil
+		}
+		if attempt == listOperationsAttempts {
+			return nil, fmt.Errorf("unable to list recorded operations: %v", err)
+		}
+		glog.Warningf("Unable to list recorded operations, retrying in %v: %v", delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// NewOperation adds a new operation acting on resource. It is lock-free.
+func (ops *Operations) NewOperation(from <-chan interface{}, resource string) *Operation {
 	id := atomic.AddInt64(&ops.lastID, 1)
 	op := &Operation{
 		ID:       strconv.FormatInt(id, 10),
+		resource: resource,
+		owner:    ops.owner,
+		created:  util.Now(),
 		awaiting: from,
 		notify:   make(chan struct{}),
 	}
 	go op.wait()
 	go ops.insert(op)
+	if ops.registry != nil {
+		go ops.track(op)
+	}
 	return op
 }
 
@@ -109,23 +221,100 @@ func (ops *Operations) insert(op *Operation) {
 	ops.ops[op.ID] = op
 }
 
-// List operations for an API client.
-func (ops *Operations) List() api.ServerOpList {
+// track records op in the registry when it starts, and again when it finishes.
+// While op works, its record is saved again every half ttl, so that it doesn't
+// expire first. The writes are made in order from a single goroutine, so the
+// finished record always wins.
+func (ops *Operations) track(op *Operation) {
+	defer util.HandleCrash()
+	ops.save(op)
+	refresh := time.NewTicker(ops.ttl / 2)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-op.notify:
+			ops.save(op)
+			return
+		case <-refresh.C:
+			ops.save(op)
+		}
+	}
+}
+
+func (ops *Operations) save(op *Operation) {
+	record := op.record()
+	if err := ops.registry.SaveOperation(&record, ops.ttl); err != nil {
+		glog.Errorf("Unable to record operation %s: %v", op.ID, err)
+	}
+}
+
+// getRecord returns the registry's record of the operation with the given ID, or
+// nil if there is none.
+func (ops *Operations) getRecord(id string) *api.ServerOp {
+	if ops.registry == nil {
+		return nil
+	}
+	record, err := ops.registry.GetOperation(id)
+	if err != nil {
+		if !IsNotFound(err) {
+			glog.Errorf("Unable to get recorded operation %s: %v", id, err)
+		}
+		return nil
+	}
+	return record
+}
+
+// List operations for an API client. If resource or status is not empty, only
+// operations on that resource, or in that status, are listed.
+func (ops *Operations) List(resource, status string) api.ServerOpList {
+	records := map[string]api.ServerOp{}
+	if ops.registry != nil {
+		list, err := ops.registry.ListOperations()
+		if err != nil {
+			glog.Errorf("Unable to list recorded operations: %v", err)
+		}
+		for _, record := range list {
+			records[record.ID] = record
+		}
+	}
+
 	ops.lock.Lock()
-	defer ops.lock.Unlock()
+	for id, op := range ops.ops {
+		records[id] = op.record()
+	}
+	ops.lock.Unlock()
 
 	ids := []string{}
-	for id := range ops.ops {
+	for id := range records {
 		ids = append(ids, id)
 	}
-	sort.StringSlice(ids).Sort()
+	sort.Sort(byOperationID(ids))
 	ol := api.ServerOpList{}
 	for _, id := range ids {
-		ol.Items = append(ol.Items, api.ServerOp{JSONBase: api.JSONBase{ID: id}})
+		record := records[id]
+		if resource != "" && record.Resource != resource {
+			continue
+		}
+		if status != "" && record.Status != status {
+			continue
+		}
+		ol.Items = append(ol.Items, record)
 	}
 	return ol
 }
 
+// byOperationID sorts operation IDs numerically where possible.
+type byOperationID []string
+
+func (s byOperationID) Len() int      { return len(s) }
+func (s byOperationID) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byOperationID) Less(i, j int) bool {
+	if len(s[i]) != len(s[j]) {
+		return len(s[i]) < len(s[j])
+	}
+	return s[i] < s[j]
+}
+
 // Get returns the operation with the given ID, or nil
 func (ops *Operations) Get(id string) *Operation {
 	ops.lock.Lock()
@@ -183,6 +372,63 @@ func (op *Operation) expired(limitTime time.Time) bool {
 	return op.finished.Before(limitTime)
 }
 
+// record describes op for listing and for the operation registry.
+func (op *Operation) record() api.ServerOp {
+	op.lock.Lock()
+	defer op.lock.Unlock()
+
+	record := api.ServerOp{
+		JSONBase: api.JSONBase{ID: op.ID, CreationTimestamp: op.created},
+		Resource: op.resource,
+		Owner:    op.owner,
+		Status:   api.StatusWorking,
+	}
+	if op.finished == nil {
+		return record
+	}
+	record.Status = api.StatusSuccess
+	record.Code = http.StatusOK
+	var status *api.Status
+	switch result := op.result.(type) {
+	case *api.Status:
+		status = result
+	case api.Status:
+		status = &result
+	}
+	if status != nil {
+		if status.Status == api.StatusFailure {
+			record.Status = api.StatusFailure
+			record.Message = status.Message
+		}
+		if status.Code != 0 {
+			record.Code = status.Code
+		}
+	}
+	return record
+}
+
+// recordStatus describes an operation known only from its record, returning the
+// status and the HTTP code to serve it with.
+func recordStatus(record *api.ServerOp) (*api.Status, int) {
+	status := &api.Status{
+		Status:  record.Status,
+		Message: record.Message,
+		Code:    record.Code,
+		Details: &api.StatusDetails{ID: record.ID, Kind: "operation"},
+	}
+	switch record.Status {
+	case api.StatusWorking:
+		status.Reason = api.ReasonTypeWorking
+		return status, http.StatusAccepted
+	case api.StatusFailure:
+		if record.Code == 0 {
+			return status, http.StatusInternalServerError
+		}
+		return status, record.Code
+	}
+	return status, http.StatusOK
+}
+
 // StatusOrResult returns status information or the result of the operation if it is complete,
 // with a bool indicating true in the latter case.
 func (op *Operation) StatusOrResult() (description interface{}, finished bool) {
@@ -18,9 +18,12 @@ package apiserver
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -32,7 +35,7 @@ func TestOperation(t *testing.T) {
 	ops := NewOperations()
 
 	c := make(chan interface{})
-	op := ops.NewOperation(c)
+	op := ops.NewOperation(c, "foo")
 	// Allow context switch, so that op's ID can get added to the map and Get will work.
 	// This is just so we can test Get. Ordinary users have no need to call Get immediately
 	// after calling NewOperation, because it returns the operation directly.
@@ -203,3 +206,211 @@ func TestOpGet(t *testing.T) {
 		t.Errorf("Unexpected response %#v", response)
 	}
 }
+
+type fakeOperationRegistry struct {
+	lock    sync.Mutex
+	records map[string]api.ServerOp
+	saved   chan api.ServerOp
+	// listErrs are returned by successive calls to ListOperations.
+	listErrs []error
+}
+
+func newFakeOperationRegistry(records ...api.ServerOp) *fakeOperationRegistry {
+	r := &fakeOperationRegistry{
+		records: map[string]api.ServerOp{},
+		saved:   make(chan api.ServerOp, 10),
+	}
+	for _, record := range records {
+		r.records[record.ID] = record
+	}
+	return r
+}
+
+func (r *fakeOperationRegistry) ListOperations() ([]api.ServerOp, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.listErrs) > 0 {
+		err := r.listErrs[0]
+		r.listErrs = r.listErrs[1:]
+		return nil, err
+	}
+	list := []api.ServerOp{}
+	for _, record := range r.records {
+		list = append(list, record)
+	}
+	return list, nil
+}
+
+func (r *fakeOperationRegistry) GetOperation(id string) (*api.ServerOp, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	record, ok := r.records[id]
+	if !ok {
+		return nil, NewNotFoundErr("operation", id)
+	}
+	return &record, nil
+}
+
+func (r *fakeOperationRegistry) SaveOperation(op *api.ServerOp, ttl time.Duration) error {
+	r.lock.Lock()
+	r.records[op.ID] = *op
+	r.lock.Unlock()
+	r.saved <- *op
+	return nil
+}
+
+func TestOperationsWithRegistry(t *testing.T) {
+	registry := newFakeOperationRegistry(
+		api.ServerOp{JSONBase: api.JSONBase{ID: "9"}, Resource: "bar", Status: api.StatusFailure, Code: http.StatusConflict},
+	)
+	ops, err := NewOperationsWithRegistry(registry, "me", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := make(chan interface{})
+	op := ops.NewOperation(c, "foo")
+	if op.ID != "10" {
+		t.Errorf("expected ID to continue on from the registry, got %v", op.ID)
+	}
+	if record := <-registry.saved; record.ID != "10" || record.Status != api.StatusWorking || record.Resource != "foo" {
+		t.Errorf("unexpected record: %#v", record)
+	}
+	c <- &api.Status{Status: api.StatusFailure, Message: "boom", Code: http.StatusBadRequest}
+	record := <-registry.saved
+	if record.Status != api.StatusFailure || record.Code != http.StatusBadRequest || record.Message != "boom" {
+		t.Errorf("unexpected record: %#v", record)
+	}
+
+	table := []struct {
+		resource, status string
+		ids              []string
+	}{
+		{"", "", []string{"9", "10"}},
+		{"foo", "", []string{"10"}},
+		{"bar", "", []string{"9"}},
+		{"", api.StatusFailure, []string{"9", "10"}},
+		{"", api.StatusSuccess, []string{}},
+	}
+	for _, item := range table {
+		ids := []string{}
+		for _, record := range ops.List(item.resource, item.status).Items {
+			ids = append(ids, record.ID)
+		}
+		if !reflect.DeepEqual(item.ids, ids) {
+			t.Errorf("%q/%q: expected %v, got %v", item.resource, item.status, item.ids, ids)
+		}
+	}
+}
+
+func TestOperationsWithRegistryFailsOrphans(t *testing.T) {
+	registry := newFakeOperationRegistry(
+		api.ServerOp{JSONBase: api.JSONBase{ID: "3"}, Resource: "foo", Owner: "me", Status: api.StatusWorking},
+		api.ServerOp{JSONBase: api.JSONBase{ID: "4"}, Resource: "foo", Owner: "other", Status: api.StatusWorking},
+	)
+	if _, err := NewOperationsWithRegistry(registry, "me", time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	record := <-registry.saved
+	if record.ID != "3" || record.Status != api.StatusFailure || record.Code != http.StatusInternalServerError {
+		t.Errorf("expected the orphaned operation to fail, got %#v", record)
+	}
+	// The other server may still be running its operation.
+	select {
+	case record := <-registry.saved:
+		t.Errorf("unexpected record saved: %#v", record)
+	default:
+	}
+	if record, _ := registry.GetOperation("4"); record.Status != api.StatusWorking {
+		t.Errorf("expected the operation of the other server to be left alone, got %#v", record)
+	}
+}
+
+func TestOperationsWithRegistryRefreshesWorkingRecords(t *testing.T) {
+	registry := newFakeOperationRegistry()
+	ops, err := NewOperationsWithRegistry(registry, "me", 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := make(chan interface{})
+	ops.NewOperation(c, "foo")
+	// The first record, and at least one refresh before the operation finishes.
+	for i := 0; i < 2; i++ {
+		if record := <-registry.saved; record.Status != api.StatusWorking || record.Owner != "me" {
+			t.Errorf("unexpected record: %#v", record)
+		}
+	}
+	c <- &api.Status{Status: api.StatusSuccess}
+	for record := range registry.saved {
+		if record.Status == api.StatusSuccess {
+			break
+		}
+	}
+}
+
+func TestOperationsWithRegistryListErrors(t *testing.T) {
+	defer func(delay time.Duration) { listOperationsDelay = delay }(listOperationsDelay)
+	listOperationsDelay = time.Millisecond
+
+	registry := newFakeOperationRegistry(api.ServerOp{JSONBase: api.JSONBase{ID: "9"}, Status: api.StatusSuccess})
+	registry.listErrs = []error{errors.New("unreachable"), errors.New("unreachable")}
+	ops, err := NewOperationsWithRegistry(registry, "me", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if op := ops.NewOperation(make(chan interface{}), "foo"); op.ID != "10" {
+		t.Errorf("expected ID to continue on from the registry, got %v", op.ID)
+	}
+
+	for i := 0; i < listOperationsAttempts; i++ {
+		registry.listErrs = append(registry.listErrs, errors.New("unreachable"))
+	}
+	if _, err := NewOperationsWithRegistry(registry, "me", time.Minute); err == nil {
+		t.Errorf("expected an error when the registry can't be listed")
+	}
+}
+
+func TestOpGetFromRegistry(t *testing.T) {
+	registry := newFakeOperationRegistry(
+		api.ServerOp{JSONBase: api.JSONBase{ID: "3"}, Resource: "foo", Status: api.StatusFailure, Message: "boom", Code: http.StatusConflict},
+		api.ServerOp{JSONBase: api.JSONBase{ID: "4"}, Resource: "foo", Status: api.StatusSuccess, Code: http.StatusOK},
+	)
+	ops, err := NewOperationsWithRegistry(registry, "me", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := HandleWithOperations(map[string]RESTStorage{
+		"foo": &SimpleRESTStorage{},
+	}, codec, "/prefix/version", ops)
+	server := httptest.NewServer(handler)
+	client := http.Client{}
+
+	table := []struct {
+		id     string
+		code   int
+		status string
+	}{
+		{"3", http.StatusConflict, api.StatusFailure},
+		{"4", http.StatusOK, api.StatusSuccess},
+		{"5", http.StatusNotFound, ""},
+	}
+	for _, item := range table {
+		response, err := client.Get(server.URL + "/prefix/version/operations/" + item.id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.StatusCode != item.code {
+			t.Errorf("%s: expected %v, got %v", item.id, item.code, response.StatusCode)
+		}
+		if item.status == "" {
+			continue
+		}
+		var status api.Status
+		if _, err := extractBody(response, &status); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if status.Status != item.status || status.Details == nil || status.Details.ID != item.id {
+			t.Errorf("%s: unexpected status %#v", item.id, status)
+		}
+	}
+}
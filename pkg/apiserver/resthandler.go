@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/httplog"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 )
@@ -57,36 +58,50 @@ func (h *RESTHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 //   POST       /foo          create
 //   PUT        /foo/bar      update 'bar'
 //   DELETE     /foo/bar      delete 'bar'
+// HEAD is served like GET, without the body.
 // Returns 404 if the method/pattern doesn't match one of these entries
 // The s accepts several query parameters:
 //    sync=[false|true] Synchronous request (only applies to create, update, delete operations)
 //    timeout=<duration> Timeout for synchronous requests, only applies if sync=true
 //    labels=<label-selector> Used for filtering list operations
+//    fields=<field-selector> Used for filtering list operations of storage implementing ResourceFieldLister
+//    resourceVersion=<version> Asks list operations for a list not older than version, waiting up to timeout for one
+//    output=yaml Respond in YAML rather than JSON, as does the header Accept: application/yaml
 func (h *RESTHandler) handleRESTStorage(parts []string, req *http.Request, w http.ResponseWriter, storage RESTStorage) {
 	sync := req.URL.Query().Get("sync") == "true"
 	timeout := parseTimeout(req.URL.Query().Get("timeout"))
 	switch req.Method {
-	case "GET":
+	case "GET", "HEAD":
 		switch len(parts) {
 		case 1:
 			selector, err := labels.ParseSelector(req.URL.Query().Get("labels"))
 			if err != nil {
-				errorJSON(err, h.codec, w)
+				writeError(NewBadRequestErr(err), h.codec, w, req)
 				return
 			}
-			list, err := storage.List(selector)
+			field, err := labels.ParseSelector(req.URL.Query().Get("fields"))
 			if err != nil {
-				errorJSON(err, h.codec, w)
+				writeError(NewBadRequestErr(err), h.codec, w, req)
 				return
 			}
-			writeJSON(http.StatusOK, h.codec, list, w)
+			minVersion, err := parseResourceVersion(req.URL.Query().Get("resourceVersion"))
+			if err != nil {
+				writeError(err, h.codec, w, req)
+				return
+			}
+			list, err := listNotOlderThan(storage, selector, field, minVersion, parts[0], timeout)
+			if err != nil {
+				writeError(err, h.codec, w, req)
+				return
+			}
+			writeObject(http.StatusOK, h.codec, list, w, req)
 		case 2:
 			item, err := storage.Get(parts[1])
 			if err != nil {
-				errorJSON(err, h.codec, w)
+				writeError(err, h.codec, w, req)
 				return
 			}
-			writeJSON(http.StatusOK, h.codec, item, w)
+			writeObject(http.StatusOK, h.codec, item, w, req)
 		default:
 			notFound(w, req)
 		}
@@ -98,22 +113,22 @@ func (h *RESTHandler) handleRESTStorage(parts []string, req *http.Request, w htt
 		}
 		body, err := readBody(req)
 		if err != nil {
-			errorJSON(err, h.codec, w)
+			writeError(err, h.codec, w, req)
 			return
 		}
 		obj := storage.New()
 		err = h.codec.DecodeInto(body, obj)
 		if err != nil {
-			errorJSON(err, h.codec, w)
+			writeError(err, h.codec, w, req)
 			return
 		}
 		out, err := storage.Create(obj)
 		if err != nil {
-			errorJSON(err, h.codec, w)
+			writeError(err, h.codec, w, req)
 			return
 		}
-		op := h.createOperation(out, sync, timeout)
-		h.finishReq(op, w)
+		op := h.createOperation(out, parts[0], sync, timeout)
+		h.finishReq(op, w, req)
 
 	case "DELETE":
 		if len(parts) != 2 {
@@ -122,11 +137,11 @@ func (h *RESTHandler) handleRESTStorage(parts []string, req *http.Request, w htt
 		}
 		out, err := storage.Delete(parts[1])
 		if err != nil {
-			errorJSON(err, h.codec, w)
+			writeError(err, h.codec, w, req)
 			return
 		}
-		op := h.createOperation(out, sync, timeout)
-		h.finishReq(op, w)
+		op := h.createOperation(out, parts[0], sync, timeout)
+		h.finishReq(op, w, req)
 
 	case "PUT":
 		if len(parts) != 2 {
@@ -135,31 +150,31 @@ func (h *RESTHandler) handleRESTStorage(parts []string, req *http.Request, w htt
 		}
 		body, err := readBody(req)
 		if err != nil {
-			errorJSON(err, h.codec, w)
+			writeError(err, h.codec, w, req)
 			return
 		}
 		obj := storage.New()
 		err = h.codec.DecodeInto(body, obj)
 		if err != nil {
-			errorJSON(err, h.codec, w)
+			writeError(err, h.codec, w, req)
 			return
 		}
 		out, err := storage.Update(obj)
 		if err != nil {
-			errorJSON(err, h.codec, w)
+			writeError(err, h.codec, w, req)
 			return
 		}
-		op := h.createOperation(out, sync, timeout)
-		h.finishReq(op, w)
+		op := h.createOperation(out, parts[0], sync, timeout)
+		h.finishReq(op, w, req)
 
 	default:
 		notFound(w, req)
 	}
 }
 
-// createOperation creates an operation to process a channel response
-func (h *RESTHandler) createOperation(out <-chan interface{}, sync bool, timeout time.Duration) *Operation {
-	op := h.ops.NewOperation(out)
+// createOperation creates an operation on resource to process a channel response
+func (h *RESTHandler) createOperation(out <-chan interface{}, resource string, sync bool, timeout time.Duration) *Operation {
+	op := h.ops.NewOperation(out, resource)
 	if sync {
 		op.WaitFor(timeout)
 	} else if h.asyncOpWait != 0 {
@@ -170,7 +185,7 @@ func (h *RESTHandler) createOperation(out <-chan interface{}, sync bool, timeout
 
 // finishReq finishes up a request, waiting until the operation finishes or, after a timeout, creating an
 // Operation to receive the result and returning its ID down the writer.
-func (h *RESTHandler) finishReq(op *Operation, w http.ResponseWriter) {
+func (h *RESTHandler) finishReq(op *Operation, w http.ResponseWriter, req *http.Request) {
 	obj, complete := op.StatusOrResult()
 	if complete {
 		status := http.StatusOK
@@ -185,8 +200,46 @@ func (h *RESTHandler) finishReq(op *Operation, w http.ResponseWriter) {
 				status = stat.Code
 			}
 		}
-		writeJSON(status, h.codec, obj, w)
+		writeObject(status, h.codec, obj, w, req)
 	} else {
-		writeJSON(http.StatusAccepted, h.codec, obj, w)
+		writeObject(http.StatusAccepted, h.codec, obj, w, req)
+	}
+}
+
+// listRetryPeriod is how long listNotOlderThan waits between reads.
+var listRetryPeriod = 100 * time.Millisecond
+
+// listSelected lists the objects of storage matching selector and field. Storage
+// which doesn't implement ResourceFieldLister can't select on fields.
+func listSelected(storage RESTStorage, selector, field labels.Selector, kind string) (interface{}, error) {
+	if field.Empty() {
+		return storage.List(selector)
+	}
+	lister, ok := storage.(ResourceFieldLister)
+	if !ok {
+		return nil, NewInvalidErr(kind, "", errors.ErrorList{errors.NewNotSupported("fields", field.String())})
+	}
+	return lister.ListWithFields(selector, field)
+}
+
+// listNotOlderThan lists storage until the list's resourceVersion is at least
+// resourceVersion, so a client which has seen that version never gets an older
+// list, e.g. from an etcd member which is behind. Gives up with a server timeout
+// error after timeout. Lists without a resourceVersion are returned as they are.
+func listNotOlderThan(storage RESTStorage, selector, field labels.Selector, resourceVersion uint64, kind string, timeout time.Duration) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		list, err := listSelected(storage, selector, field, kind)
+		if err != nil || resourceVersion == 0 {
+			return list, err
+		}
+		jsonBase, err := api.FindJSONBaseRO(list)
+		if err != nil || jsonBase.ResourceVersion == 0 || jsonBase.ResourceVersion >= resourceVersion {
+			return list, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, NewServerTimeoutErr(kind, "list", 1)
+		}
+		time.Sleep(listRetryPeriod)
 	}
 }
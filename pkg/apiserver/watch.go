@@ -18,6 +18,7 @@ package apiserver
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -34,21 +35,32 @@ type WatchHandler struct {
 	codec   Codec
 }
 
-func getWatchParams(query url.Values) (label, field labels.Selector, resourceVersion uint64) {
-	if s, err := labels.ParseSelector(query.Get("labels")); err != nil {
-		label = labels.Everything()
-	} else {
-		label = s
+// getWatchParams returns the selectors and the resourceVersion a watch asks
+// for, or a bad request error if any of them doesn't parse.
+func getWatchParams(query url.Values) (label, field labels.Selector, resourceVersion uint64, err error) {
+	if label, err = labels.ParseSelector(query.Get("labels")); err != nil {
+		return nil, nil, 0, NewBadRequestErr(err)
 	}
-	if s, err := labels.ParseSelector(query.Get("fields")); err != nil {
-		field = labels.Everything()
-	} else {
-		field = s
+	if field, err = labels.ParseSelector(query.Get("fields")); err != nil {
+		return nil, nil, 0, NewBadRequestErr(err)
 	}
-	if rv, err := strconv.ParseUint(query.Get("resourceVersion"), 10, 64); err == nil {
-		resourceVersion = rv
+	if resourceVersion, err = parseResourceVersion(query.Get("resourceVersion")); err != nil {
+		return nil, nil, 0, err
 	}
-	return label, field, resourceVersion
+	return label, field, resourceVersion, nil
+}
+
+// parseResourceVersion parses the resourceVersion query parameter, which is 0
+// if omitted, or returns a bad request error.
+func parseResourceVersion(value string) (uint64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, NewBadRequestErr(fmt.Errorf("invalid resourceVersion %q", value))
+	}
+	return version, nil
 }
 
 // handleWatch processes a watch request
@@ -64,7 +76,11 @@ func (h *WatchHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	if watcher, ok := storage.(ResourceWatcher); ok {
-		label, field, resourceVersion := getWatchParams(req.URL.Query())
+		label, field, resourceVersion, err := getWatchParams(req.URL.Query())
+		if err != nil {
+			errorJSON(err, h.codec, w)
+			return
+		}
 		watching, err := watcher.Watch(label, field, resourceVersion)
 		if err != nil {
 			errorJSON(err, h.codec, w)
@@ -142,6 +142,27 @@ func TestWatchHTTP(t *testing.T) {
 	}
 }
 
+func TestWatchBadParams(t *testing.T) {
+	simpleStorage := &SimpleRESTStorage{}
+	handler := Handle(map[string]RESTStorage{
+		"foo": simpleStorage,
+	}, codec, "/prefix/version")
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	for _, rawQuery := range []string{"resourceVersion=abc", "resourceVersion=-1", "labels=a%3Db%3Dc"} {
+		resp, err := http.Get(server.URL + "/prefix/version/watch/foo?" + rawQuery)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", rawQuery, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%v: expected status %d, got %d", rawQuery, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
 func TestWatchParamParsing(t *testing.T) {
 	simpleStorage := &SimpleRESTStorage{}
 	handler := Handle(map[string]RESTStorage{
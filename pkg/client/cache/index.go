@@ -0,0 +1,156 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"sync"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// IndexFunc returns the values under which an object should be indexed, e.g.
+// "key=value" for each of a pod's labels.
+type IndexFunc func(obj interface{}) []string
+
+// Indexer is a Store which also keeps indices over its items, so lookups by
+// index value cost time proportional to the number of matching items, not the
+// number stored.
+type Indexer interface {
+	Store
+	// Index returns the items which indexName's IndexFunc mapped to value.
+	// Unknown index names return nothing.
+	Index(indexName, value string) []interface{}
+}
+
+type indexer struct {
+	lock  sync.RWMutex
+	items map[string]interface{}
+	funcs map[string]IndexFunc
+	// indices maps index name -> index value -> IDs of the items indexed there.
+	indices map[string]map[string]util.StringSet
+}
+
+// NewIndexer returns an Indexer maintaining one index per entry of funcs.
+func NewIndexer(funcs map[string]IndexFunc) Indexer {
+	indices := map[string]map[string]util.StringSet{}
+	for name := range funcs {
+		indices[name] = map[string]util.StringSet{}
+	}
+	return &indexer{
+		items:   map[string]interface{}{},
+		funcs:   funcs,
+		indices: indices,
+	}
+}
+
+// addToIndices indexes obj under id. Must be called with the lock held.
+func (i *indexer) addToIndices(id string, obj interface{}) {
+	for name, fn := range i.funcs {
+		index := i.indices[name]
+		for _, value := range fn(obj) {
+			set, ok := index[value]
+			if !ok {
+				set = util.StringSet{}
+				index[value] = set
+			}
+			set.Insert(id)
+		}
+	}
+}
+
+// removeFromIndices drops the stored item with id, if any, from the indices.
+// Must be called with the lock held.
+func (i *indexer) removeFromIndices(id string) {
+	old, ok := i.items[id]
+	if !ok {
+		return
+	}
+	for name, fn := range i.funcs {
+		index := i.indices[name]
+		for _, value := range fn(old) {
+			set := index[value]
+			delete(set, id)
+			if len(set) == 0 {
+				delete(index, value)
+			}
+		}
+	}
+}
+
+// Add inserts an item into the indexer.
+func (i *indexer) Add(id string, obj interface{}) {
+	i.Update(id, obj)
+}
+
+// Update sets an item in the indexer to its updated state, reindexing it.
+func (i *indexer) Update(id string, obj interface{}) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.removeFromIndices(id)
+	i.items[id] = obj
+	i.addToIndices(id, obj)
+}
+
+// Delete removes an item from the indexer.
+func (i *indexer) Delete(id string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.removeFromIndices(id)
+	delete(i.items, id)
+}
+
+// List returns a list of all the items.
+func (i *indexer) List() []interface{} {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	list := make([]interface{}, 0, len(i.items))
+	for _, item := range i.items {
+		list = append(list, item)
+	}
+	return list
+}
+
+// Contains returns a util.StringSet containing all IDs of the stored items.
+func (i *indexer) Contains() util.StringSet {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	set := util.StringSet{}
+	for id := range i.items {
+		set.Insert(id)
+	}
+	return set
+}
+
+// Get returns the requested item, or sets exists=false.
+func (i *indexer) Get(id string) (item interface{}, exists bool) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	item, exists = i.items[id]
+	return item, exists
+}
+
+// Index returns the items indexed under value by the named index.
+func (i *indexer) Index(indexName, value string) []interface{} {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	set := i.indices[indexName][value]
+	list := make([]interface{}, 0, len(set))
+	for id := range set {
+		list = append(list, i.items[id])
+	}
+	return list
+}
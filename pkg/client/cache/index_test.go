@@ -0,0 +1,70 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// byLetter indexes strings by each of their letters.
+func byLetter(obj interface{}) []string {
+	letters := []string{}
+	for _, r := range obj.(string) {
+		letters = append(letters, string(r))
+	}
+	return letters
+}
+
+func TestIndexerStore(t *testing.T) {
+	doTestStore(t, NewIndexer(map[string]IndexFunc{"letter": byLetter}))
+}
+
+func TestIndexerIndex(t *testing.T) {
+	expectIndex := func(indexer Indexer, value string, expected ...string) {
+		found := util.StringSet{}
+		items := indexer.Index("letter", value)
+		for _, item := range items {
+			found.Insert(item.(string))
+		}
+		if len(items) != len(expected) || !found.HasAll(expected...) {
+			t.Errorf("%v: expected %v, got %v", value, expected, items)
+		}
+	}
+
+	indexer := NewIndexer(map[string]IndexFunc{"letter": byLetter})
+	indexer.Add("1", "ab")
+	indexer.Add("2", "bc")
+	indexer.Add("3", "cd")
+	expectIndex(indexer, "a", "ab")
+	expectIndex(indexer, "b", "ab", "bc")
+	expectIndex(indexer, "z")
+
+	indexer.Update("2", "xy")
+	expectIndex(indexer, "b", "ab")
+	expectIndex(indexer, "c", "cd")
+	expectIndex(indexer, "x", "xy")
+
+	indexer.Delete("1")
+	expectIndex(indexer, "a")
+	expectIndex(indexer, "b")
+
+	if items := indexer.Index("unknown", "c"); len(items) != 0 {
+		t.Errorf("expected nothing from an unknown index, got %v", items)
+	}
+}
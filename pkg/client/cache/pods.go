@@ -0,0 +1,82 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+)
+
+const (
+	// PodLabelIndex indexes pods by "key=value" for each of their labels.
+	PodLabelIndex = "label"
+	// PodHostIndex indexes pods by DesiredState.Host.
+	PodHostIndex = "host"
+)
+
+// NewPodIndexer returns an Indexer of pods suitable for a StoreToPodLister.
+func NewPodIndexer() Indexer {
+	return NewIndexer(map[string]IndexFunc{
+		PodLabelIndex: func(obj interface{}) []string {
+			pod := obj.(*api.Pod)
+			values := make([]string, 0, len(pod.Labels))
+			for key, value := range pod.Labels {
+				values = append(values, key+"="+value)
+			}
+			return values
+		},
+		PodHostIndex: func(obj interface{}) []string {
+			return []string{obj.(*api.Pod).DesiredState.Host}
+		},
+	})
+}
+
+// StoreToPodLister turns an Indexer made by NewPodIndexer into a pod lister.
+type StoreToPodLister struct {
+	Indexer
+}
+
+// ListPods returns the pods matching selector. Only the pods carrying the
+// least common of the labels selector requires are examined.
+func (s *StoreToPodLister) ListPods(selector labels.Selector) (pods []api.Pod, err error) {
+	var candidates []interface{}
+	required := labels.RequiredLabels(selector)
+	if len(required) == 0 {
+		candidates = s.List()
+	}
+	for key, value := range required {
+		items := s.Index(PodLabelIndex, key+"="+value)
+		if candidates == nil || len(items) < len(candidates) {
+			candidates = items
+		}
+	}
+	for _, obj := range candidates {
+		pod := obj.(*api.Pod)
+		if selector.Matches(labels.Set(pod.Labels)) {
+			pods = append(pods, *pod)
+		}
+	}
+	return pods, nil
+}
+
+// ListPodsOnHost returns the pods assigned to host.
+func (s *StoreToPodLister) ListPodsOnHost(host string) (pods []api.Pod, err error) {
+	for _, obj := range s.Index(PodHostIndex, host) {
+		pods = append(pods, *obj.(*api.Pod))
+	}
+	return pods, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+)
+
+func TestStoreToPodLister(t *testing.T) {
+	store := NewPodIndexer()
+	ids := []string{"foo", "bar", "baz"}
+	for _, id := range ids {
+		store.Add(id, &api.Pod{
+			JSONBase:     api.JSONBase{ID: id},
+			Labels:       map[string]string{"name": id, "tier": "backend"},
+			DesiredState: api.PodState{Host: "host-" + id},
+		})
+	}
+	spl := StoreToPodLister{store}
+
+	for _, id := range ids {
+		got, err := spl.ListPods(labels.Set{"name": id}.AsSelector())
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			continue
+		}
+		if e, a := 1, len(got); e != a {
+			t.Errorf("Expected %v, got %v", e, a)
+			continue
+		}
+		if e, a := id, got[0].ID; e != a {
+			t.Errorf("Expected %v, got %v", e, a)
+			continue
+		}
+	}
+
+	table := []struct {
+		selector string
+		expected int
+	}{
+		{"", 3},
+		{"tier=backend", 3},
+		{"tier=backend,name!=foo", 2},
+		{"tier=frontend", 0},
+		{"name!=foo", 2},
+	}
+	for _, item := range table {
+		selector, err := labels.ParseSelector(item.selector)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		got, err := spl.ListPods(selector)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			continue
+		}
+		if e, a := item.expected, len(got); e != a {
+			t.Errorf("%v: expected %v, got %v", item.selector, e, a)
+		}
+	}
+
+	store.Update("foo", &api.Pod{
+		JSONBase:     api.JSONBase{ID: "foo"},
+		Labels:       map[string]string{"name": "foo"},
+		DesiredState: api.PodState{Host: "host-bar"},
+	})
+	if got, _ := spl.ListPods(labels.Set{"tier": "backend"}.AsSelector()); len(got) != 2 {
+		t.Errorf("Expected 2 pods after relabeling, got %v", got)
+	}
+	got, err := spl.ListPodsOnHost("host-bar")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("Expected 2 pods on host-bar, got %v", got)
+	}
+	if got, _ := spl.ListPodsOnHost("host-foo"); len(got) != 0 {
+		t.Errorf("Expected no pods on host-foo, got %v", got)
+	}
+}
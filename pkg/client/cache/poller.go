@@ -17,6 +17,7 @@ limitations under the License.
 package cache
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
@@ -40,6 +41,8 @@ type Poller struct {
 	getFunc GetFunc
 	period  time.Duration
 	store   Store
+	// listed is set to 1 once the store was first synced.
+	listed int32
 }
 
 // NewPoller constructs a new poller. Note that polling probably doesn't make much
@@ -67,6 +70,12 @@ func (p *Poller) Run() {
 	}, p.period)
 }
 
+// HasListed returns true once the store holds everything getFunc returned,
+// i.e. consumers of the store can tell an object doesn't exist by not finding it.
+func (p *Poller) HasListed() bool {
+	return atomic.LoadInt32(&p.listed) == 1
+}
+
 func (p *Poller) sync(e Enumerator) {
 	current := p.store.Contains()
 	for i := 0; i < e.Len(); i++ {
@@ -78,4 +87,5 @@ func (p *Poller) sync(e Enumerator) {
 	for id := range current {
 		p.store.Delete(id)
 	}
+	atomic.StoreInt32(&p.listed, 1)
 }
@@ -67,8 +67,14 @@ func TestPoller_sync(t *testing.T) {
 		s := NewStore()
 		// This is a unit test for the sync function, hence the nil getFunc.
 		p := NewPoller(nil, 0, s)
+		if p.HasListed() {
+			t.Errorf("%v: expected the poller not to have listed yet", testCase)
+		}
 		for line, pairs := range item.steps {
 			p.sync(testEnumerator(pairs))
+			if !p.HasListed() {
+				t.Errorf("%v, %v: expected the poller to have listed", testCase, line)
+			}
 
 			ids := s.Contains()
 			for _, pair := range pairs {
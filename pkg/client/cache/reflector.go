@@ -17,7 +17,9 @@ limitations under the License.
 package cache
 
 import (
+	"fmt"
 	"reflect"
+	"sync/atomic"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
@@ -32,16 +34,28 @@ type Reflector struct {
 	expectedType reflect.Type
 	// The destination to sync up with the watch source
 	store Store
+	// listFunc, if set, is called to fill the store before watching.
+	listFunc ListFunc
 	// watchFactory is called to initiate watches.
 	watchFactory WatchFactory
 	// period controls timing between one watch ending and
 	// the beginning of the next one.
 	period time.Duration
+	// resyncPeriod, if nonzero, is how often a listing Reflector stops watching
+	// to list everything again.
+	resyncPeriod time.Duration
+	// listed is set to 1 once listFunc filled the store.
+	listed int32
 }
 
 // WatchFactory should begin a watch at the specified version.
 type WatchFactory func(resourceVersion uint64) (watch.Interface, error)
 
+// ListFunc should list every object of the kind being watched. It must return
+// an API list type, i.e. a struct with a JSONBase and an Items slice, whose
+// resourceVersion is the version the list was read at.
+type ListFunc func() (interface{}, error)
+
 // NewReflector makes a new Reflector object which will keep the given store up to
 // date with the server's contents for the given resource. Reflector promises to
 // only put things in the store that have the type of expectedType.
@@ -55,24 +69,115 @@ func NewReflector(watchFactory WatchFactory, expectedType interface{}, store Sto
 	return gc
 }
 
+// NewListWatchReflector is like NewReflector, but the Reflector lists everything
+// with listFunc before it starts watching, replacing the contents of the store,
+// and then watches from just after the list's resourceVersion. No change made
+// between the list and the watch is missed.
+func NewListWatchReflector(listFunc ListFunc, watchFactory WatchFactory, expectedType interface{}, store Store) *Reflector {
+	gc := NewReflector(watchFactory, expectedType, store)
+	gc.listFunc = listFunc
+	return gc
+}
+
+// SetResyncPeriod makes a Reflector made by NewListWatchReflector list everything
+// again every period, even if its watch is fine, so that the store catches up
+// with changes the watch missed. Every object listed is updated in the store. Must
+// be called before Run.
+func (gc *Reflector) SetResyncPeriod(period time.Duration) {
+	gc.resyncPeriod = period
+}
+
 // Run starts a watch and handles watch events. Will restart the watch if it is closed.
-// Run starts a goroutine and returns immediately.
+// Run starts a goroutine and returns immediately. A Reflector made by
+// NewListWatchReflector lists everything again before each new watch, since the
+// watch may have failed because the version it was at is gone from the server.
 func (gc *Reflector) Run() {
 	var resourceVersion uint64
 	go util.Forever(func() {
+		if gc.listFunc != nil {
+			if err := gc.list(&resourceVersion); err != nil {
+				glog.Errorf("failed to list %v: %v", gc.expectedType, err)
+				return
+			}
+		}
 		w, err := gc.watchFactory(resourceVersion)
 		if err != nil {
 			glog.Errorf("failed to watch %v: %v", gc.expectedType, err)
 			return
 		}
-		gc.watchHandler(w, &resourceVersion)
+		var resync <-chan time.Time
+		if gc.listFunc != nil && gc.resyncPeriod > 0 {
+			resync = time.After(gc.resyncPeriod)
+		}
+		gc.watchHandler(w, &resourceVersion, resync)
 	}, gc.period)
 }
 
-// watchHandler watches w and keeps *resourceVersion up to date.
-func (gc *Reflector) watchHandler(w watch.Interface, resourceVersion *uint64) {
+// HasListed returns true once the store holds everything listFunc listed, i.e.
+// consumers of the store can tell an object doesn't exist by not finding it.
+func (gc *Reflector) HasListed() bool {
+	return atomic.LoadInt32(&gc.listed) == 1
+}
+
+// list replaces the contents of the store with what listFunc returns, and sets
+// *resourceVersion to the version to watch from.
+func (gc *Reflector) list(resourceVersion *uint64) error {
+	list, err := gc.listFunc()
+	if err != nil {
+		return err
+	}
+	jsonBase, err := api.FindJSONBaseRO(list)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(list)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	items := v.FieldByName("Items")
+	if items.Kind() != reflect.Slice {
+		return fmt.Errorf("expected a list with items, got %#v", list)
+	}
+	found := util.StringSet{}
+	for i := 0; i < items.Len(); i++ {
+		obj := reflect.New(items.Type().Elem())
+		obj.Elem().Set(items.Index(i))
+		if e, a := gc.expectedType, obj.Type(); e != a {
+			return fmt.Errorf("expected type %v, but list had items of type %v", e, a)
+		}
+		item, err := api.FindJSONBase(obj.Interface())
+		if err != nil {
+			return err
+		}
+		found.Insert(item.ID())
+		if _, exists := gc.store.Get(item.ID()); exists {
+			gc.store.Update(item.ID(), obj.Interface())
+		} else {
+			gc.store.Add(item.ID(), obj.Interface())
+		}
+	}
+	for id := range gc.store.Contains() {
+		if !found.Has(id) {
+			gc.store.Delete(id)
+		}
+	}
+	*resourceVersion = jsonBase.ResourceVersion + 1
+	atomic.StoreInt32(&gc.listed, 1)
+	return nil
+}
+
+// watchHandler watches w and keeps *resourceVersion up to date, until w is
+// closed or resync fires.
+func (gc *Reflector) watchHandler(w watch.Interface, resourceVersion *uint64, resync <-chan time.Time) {
 	for {
-		event, ok := <-w.ResultChan()
+		var event watch.Event
+		var ok bool
+		select {
+		case <-resync:
+			w.Stop()
+			return
+		case event, ok = <-w.ResultChan():
+		}
 		if !ok {
 			glog.Errorf("unexpected watch close")
 			return
@@ -17,7 +17,9 @@ limitations under the License.
 package cache
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
@@ -37,7 +39,7 @@ func TestReflector_watchHandler(t *testing.T) {
 		fw.Stop()
 	}()
 	var resumeRV uint64
-	g.watchHandler(fw, &resumeRV)
+	g.watchHandler(fw, &resumeRV, nil)
 
 	table := []struct {
 		ID     string
@@ -120,3 +122,113 @@ func TestReflector_Run(t *testing.T) {
 		t.Error("called watchStarter an unexpected number of times")
 	}
 }
+
+func TestReflector_list(t *testing.T) {
+	s := NewStore()
+	s.Add("foo", &api.Pod{JSONBase: api.JSONBase{ID: "foo"}})
+	s.Add("gone", &api.Pod{JSONBase: api.JSONBase{ID: "gone"}})
+	list := &api.PodList{
+		JSONBase: api.JSONBase{ResourceVersion: 10},
+		Items: []api.Pod{
+			{JSONBase: api.JSONBase{ID: "foo", ResourceVersion: 7}},
+			{JSONBase: api.JSONBase{ID: "bar", ResourceVersion: 9}},
+		},
+	}
+	g := NewListWatchReflector(func() (interface{}, error) { return list, nil }, nil, &api.Pod{}, s)
+	if g.HasListed() {
+		t.Errorf("expected the reflector not to have listed yet")
+	}
+	var resumeRV uint64
+	if err := g.list(&resumeRV); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !g.HasListed() {
+		t.Errorf("expected the reflector to have listed")
+	}
+	if e, a := uint64(11), resumeRV; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if ids := s.Contains(); len(ids) != 2 || !ids.HasAll("foo", "bar") {
+		t.Errorf("unexpected store contents %v", ids)
+	}
+	if item, _ := s.Get("foo"); item.(*api.Pod).ResourceVersion != 7 {
+		t.Errorf("expected foo to be updated, got %#v", item)
+	}
+
+	g = NewListWatchReflector(func() (interface{}, error) { return &api.ServiceList{Items: []api.Service{{}}}, nil }, nil, &api.Pod{}, s)
+	if err := g.list(&resumeRV); err == nil {
+		t.Errorf("expected an error for a list of the wrong type")
+	}
+}
+
+func TestReflector_RunRelistsAfterWatchFailure(t *testing.T) {
+	lists := make(chan bool)
+	lister := func() (interface{}, error) {
+		lists <- true
+		return &api.PodList{JSONBase: api.JSONBase{ResourceVersion: 41}}, nil
+	}
+	watchStarter := func(rv uint64) (watch.Interface, error) {
+		// E.g. the version is too old to watch from.
+		return nil, errors.New("injected error")
+	}
+	r := NewListWatchReflector(lister, watchStarter, &api.Pod{}, NewStore())
+	r.period = 0
+	r.Run()
+	for i := 0; i < 3; i++ {
+		<-lists
+	}
+}
+
+func TestReflector_RunResyncs(t *testing.T) {
+	watchRVs := make(chan uint64)
+	watchStarter := func(rv uint64) (watch.Interface, error) {
+		go func() { watchRVs <- rv }()
+		return watch.NewFake(), nil
+	}
+	var version uint64
+	lister := func() (interface{}, error) {
+		version++
+		return &api.PodList{
+			JSONBase: api.JSONBase{ResourceVersion: version},
+			Items:    []api.Pod{{JSONBase: api.JSONBase{ID: "foo", ResourceVersion: version}}},
+		}, nil
+	}
+	s := NewFIFO()
+	r := NewListWatchReflector(lister, watchStarter, &api.Pod{}, s)
+	r.period = 0
+	r.SetResyncPeriod(time.Millisecond)
+	r.Run()
+	// The watches never end on their own, but everything is listed again.
+	for i := uint64(1); i <= 3; i++ {
+		if e, a := i+1, <-watchRVs; e != a {
+			t.Errorf("expected the watch to start at %v, got %v", e, a)
+		}
+		if pod := s.Pop().(*api.Pod); pod.ResourceVersion != i {
+			t.Errorf("expected foo at version %v, got %#v", i, pod)
+		}
+	}
+}
+
+func TestReflector_RunListsFirst(t *testing.T) {
+	watchRVs := make(chan uint64)
+	watchStarter := func(rv uint64) (watch.Interface, error) {
+		go func() { watchRVs <- rv }()
+		return watch.NewFake(), nil
+	}
+	lister := func() (interface{}, error) {
+		return &api.PodList{
+			JSONBase: api.JSONBase{ResourceVersion: 41},
+			Items:    []api.Pod{{JSONBase: api.JSONBase{ID: "foo", ResourceVersion: 40}}},
+		}, nil
+	}
+	s := NewStore()
+	r := NewListWatchReflector(lister, watchStarter, &api.Pod{}, s)
+	r.period = 0
+	r.Run()
+	if e, a := uint64(42), <-watchRVs; e != a {
+		t.Errorf("expected the watch to start at %v, got %v", e, a)
+	}
+	if _, exists := s.Get("foo"); !exists {
+		t.Errorf("expected the listed pod in the store")
+	}
+}
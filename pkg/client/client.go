@@ -38,7 +38,11 @@ import (
 type Interface interface {
 	PodInterface
 	ReplicationControllerInterface
+	DaemonControllerInterface
 	ServiceInterface
+	EndpointsInterface
+	MinionInterface
+	EventInterface
 	VersionInterface
 }
 
@@ -49,6 +53,9 @@ type PodInterface interface {
 	DeletePod(name string) error
 	CreatePod(api.Pod) (api.Pod, error)
 	UpdatePod(api.Pod) (api.Pod, error)
+	ReportPodStatus(api.PodStatusReport) error
+	BindPod(podID, host string) error
+	WatchPods(label, field labels.Selector, resourceVersion uint64) (watch.Interface, error)
 }
 
 // ReplicationControllerInterface has methods to work with ReplicationController resources
@@ -57,18 +64,47 @@ type ReplicationControllerInterface interface {
 	GetReplicationController(name string) (api.ReplicationController, error)
 	CreateReplicationController(api.ReplicationController) (api.ReplicationController, error)
 	UpdateReplicationController(api.ReplicationController) (api.ReplicationController, error)
+	ResizeReplicationController(name string, replicas int) (api.ReplicationController, error)
 	DeleteReplicationController(string) error
 	WatchReplicationControllers(label, field labels.Selector, resourceVersion uint64) (watch.Interface, error)
 }
 
+// DaemonControllerInterface has methods to work with DaemonController resources
+type DaemonControllerInterface interface {
+	ListDaemonControllers(selector labels.Selector) (api.DaemonControllerList, error)
+	GetDaemonController(name string) (api.DaemonController, error)
+	CreateDaemonController(api.DaemonController) (api.DaemonController, error)
+	UpdateDaemonController(api.DaemonController) (api.DaemonController, error)
+	DeleteDaemonController(string) error
+	WatchDaemonControllers(label, field labels.Selector, resourceVersion uint64) (watch.Interface, error)
+}
+
 // ServiceInterface has methods to work with Service resources
 type ServiceInterface interface {
+	ListServices(selector labels.Selector) (api.ServiceList, error)
 	GetService(name string) (api.Service, error)
 	CreateService(api.Service) (api.Service, error)
 	UpdateService(api.Service) (api.Service, error)
 	DeleteService(string) error
 }
 
+// EndpointsInterface has methods to work with the Endpoints of services
+type EndpointsInterface interface {
+	ListEndpoints(selector labels.Selector) (api.EndpointsList, error)
+}
+
+// MinionInterface has methods to work with Minion resources
+type MinionInterface interface {
+	ListMinions() (api.MinionList, error)
+	CreateMinion(api.Minion) (api.Minion, error)
+}
+
+// EventInterface has methods to work with Event resources
+type EventInterface interface {
+	CreateEvent(api.Event) (api.Event, error)
+	ListEvents(selector labels.Selector) (api.EventList, error)
+}
+
 // VersionInterface has a method to retrieve the server version
 type VersionInterface interface {
 	ServerVersion() (*version.Info, error)
@@ -79,8 +115,10 @@ type Client struct {
 	*RESTClient
 }
 
-// StatusErr might get returned from an api call if your request is still being processed
-// and hence the expected return data is not available yet.
+// StatusErr is returned from an api call when the server answers with a Status other
+// than success: either the request is still being processed, and hence the expected
+// return data is not available yet, or it failed, in which case the Status' Reason and
+// Details describe why.
 type StatusErr struct {
 	Status api.Status
 }
@@ -157,14 +195,12 @@ func (c *RESTClient) doRequest(request *http.Request) ([]byte, error) {
 		isStatusResponse = true
 	}
 
-	switch {
-	case response.StatusCode == http.StatusConflict:
-		// Return error given by server, if there was one.
+	if response.StatusCode < http.StatusOK || response.StatusCode > http.StatusPartialContent {
+		// Return error given by server, if there was one. Its Reason and Details
+		// say what went wrong.
 		if isStatusResponse {
 			return nil, &StatusErr{status}
 		}
-		fallthrough
-	case response.StatusCode < http.StatusOK || response.StatusCode > http.StatusPartialContent:
 		return nil, fmt.Errorf("request [%#v] failed (%d) %s: %s", request, response.StatusCode, response.Status, string(body))
 	}
 
@@ -211,6 +247,13 @@ func (c *Client) ListPods(selector labels.Selector) (result api.PodList, err err
 	return
 }
 
+// ListPodsWithFields returns the pods matching both the label and the field selector,
+// e.g. "DesiredState.Host=machine".
+func (c *Client) ListPodsWithFields(label, field labels.Selector) (result api.PodList, err error) {
+	err = c.Get().Path("pods").SelectorParam("labels", label).SelectorParam("fields", field).Do().Into(&result)
+	return
+}
+
 // GetPod takes the name of the pod, and returns the corresponding Pod object, and an error if it occurs
 func (c *Client) GetPod(name string) (result api.Pod, err error) {
 	err = c.Get().Path("pods").Path(name).Do().Into(&result)
@@ -238,6 +281,29 @@ func (c *Client) UpdatePod(pod api.Pod) (result api.Pod, err error) {
 	return
 }
 
+// ReportPodStatus tells the master about the containers of a pod.
+func (c *Client) ReportPodStatus(report api.PodStatusReport) error {
+	return c.Post().Path("podStatusReports").Body(report).Do().Error()
+}
+
+// BindPod places the pod named podID, which is left to a scheduler other than
+// the default one, on host.
+func (c *Client) BindPod(podID, host string) error {
+	binding := api.Binding{PodID: podID, Host: host}
+	return c.Post().Path("bindings").Body(binding).Do().Error()
+}
+
+// WatchPods returns a watch.Interface that watches the requested pods.
+func (c *Client) WatchPods(label, field labels.Selector, resourceVersion uint64) (watch.Interface, error) {
+	return c.Get().
+		Path("watch").
+		Path("pods").
+		UintParam("resourceVersion", resourceVersion).
+		SelectorParam("labels", label).
+		SelectorParam("fields", field).
+		Watch()
+}
+
 // ListReplicationControllers takes a selector, and returns the list of replication controllers that match that selector
 func (c *Client) ListReplicationControllers(selector labels.Selector) (result api.ReplicationControllerList, err error) {
 	err = c.Get().Path("replicationControllers").SelectorParam("labels", selector).Do().Into(&result)
@@ -266,6 +332,14 @@ func (c *Client) UpdateReplicationController(controller api.ReplicationControlle
 	return
 }
 
+// ResizeReplicationController sets the replicas of an existing replication
+// controller, and nothing else, so it doesn't need the resource version.
+func (c *Client) ResizeReplicationController(name string, replicas int) (result api.ReplicationController, err error) {
+	resize := api.Resize{ControllerID: name, Replicas: replicas}
+	err = c.Post().Path("resizes").Body(resize).Do().Into(&result)
+	return
+}
+
 // DeleteReplicationController deletes an existing replication controller.
 func (c *Client) DeleteReplicationController(name string) error {
 	return c.Delete().Path("replicationControllers").Path(name).Do().Error()
@@ -282,6 +356,56 @@ func (c *Client) WatchReplicationControllers(label, field labels.Selector, resou
 		Watch()
 }
 
+// ListDaemonControllers takes a selector, and returns the list of daemon controllers that match that selector
+func (c *Client) ListDaemonControllers(selector labels.Selector) (result api.DaemonControllerList, err error) {
+	err = c.Get().Path("daemonControllers").SelectorParam("labels", selector).Do().Into(&result)
+	return
+}
+
+// GetDaemonController returns information about a particular daemon controller
+func (c *Client) GetDaemonController(name string) (result api.DaemonController, err error) {
+	err = c.Get().Path("daemonControllers").Path(name).Do().Into(&result)
+	return
+}
+
+// CreateDaemonController creates a new daemon controller
+func (c *Client) CreateDaemonController(daemon api.DaemonController) (result api.DaemonController, err error) {
+	err = c.Post().Path("daemonControllers").Body(daemon).Do().Into(&result)
+	return
+}
+
+// UpdateDaemonController updates an existing daemon controller
+func (c *Client) UpdateDaemonController(daemon api.DaemonController) (result api.DaemonController, err error) {
+	if daemon.ResourceVersion == 0 {
+		err = fmt.Errorf("invalid update object, missing resource version: %v", daemon)
+		return
+	}
+	err = c.Put().Path("daemonControllers").Path(daemon.ID).Body(daemon).Do().Into(&result)
+	return
+}
+
+// DeleteDaemonController deletes an existing daemon controller.
+func (c *Client) DeleteDaemonController(name string) error {
+	return c.Delete().Path("daemonControllers").Path(name).Do().Error()
+}
+
+// WatchDaemonControllers returns a watch.Interface that watches the requested daemon controllers.
+func (c *Client) WatchDaemonControllers(label, field labels.Selector, resourceVersion uint64) (watch.Interface, error) {
+	return c.Get().
+		Path("watch").
+		Path("daemonControllers").
+		UintParam("resourceVersion", resourceVersion).
+		SelectorParam("labels", label).
+		SelectorParam("fields", field).
+		Watch()
+}
+
+// ListServices takes a selector, and returns the list of services that match that selector.
+func (c *Client) ListServices(selector labels.Selector) (result api.ServiceList, err error) {
+	err = c.Get().Path("services").SelectorParam("labels", selector).Do().Into(&result)
+	return
+}
+
 // GetService returns information about a particular service.
 func (c *Client) GetService(name string) (result api.Service, err error) {
 	err = c.Get().Path("services").Path(name).Do().Into(&result)
@@ -309,6 +433,51 @@ func (c *Client) DeleteService(name string) error {
 	return c.Delete().Path("services").Path(name).Do().Error()
 }
 
+// ListEndpoints takes a selector, e.g. "ID=foo", and returns the matching endpoints.
+func (c *Client) ListEndpoints(selector labels.Selector) (result api.EndpointsList, err error) {
+	err = c.Get().Path("endpoints").SelectorParam("labels", selector).Do().Into(&result)
+	return
+}
+
+// ListMinions lists all the minions registered with the master.
+func (c *Client) ListMinions() (result api.MinionList, err error) {
+	err = c.Get().Path("minions").Do().Into(&result)
+	return
+}
+
+// CreateMinion registers a minion. Registering a minion again counts as a
+// heartbeat from it.
+func (c *Client) CreateMinion(minion api.Minion) (result api.Minion, err error) {
+	err = c.Post().Path("minions").Body(minion).Do().Into(&result)
+	return
+}
+
+// CreateEvent records an event.
+func (c *Client) CreateEvent(event api.Event) (result api.Event, err error) {
+	err = c.Post().Path("events").Body(event).Do().Into(&result)
+	return
+}
+
+// ListEvents returns the events whose fields match selector, e.g. "InvolvedObject.ID=foo".
+func (c *Client) ListEvents(selector labels.Selector) (result api.EventList, err error) {
+	err = c.Get().Path("events").SelectorParam("labels", selector).Do().Into(&result)
+	return
+}
+
+// ServerAPIVersions retrieves and parses the list of API versions the server supports.
+func (c *Client) ServerAPIVersions() (*api.APIVersions, error) {
+	body, err := c.Get().AbsPath("/api").Do().Raw()
+	if err != nil {
+		return nil, err
+	}
+	var v api.APIVersions
+	err = json.Unmarshal(body, &v)
+	if err != nil {
+		return nil, fmt.Errorf("Got '%s': %v", string(body), err)
+	}
+	return &v, nil
+}
+
 // ServerVersion retrieves and parses the server's version.
 func (c *Client) ServerVersion() (*version.Info, error) {
 	body, err := c.Get().AbsPath("/version").Do().Raw()
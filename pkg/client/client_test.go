@@ -102,6 +102,25 @@ func TestListPodsLabels(t *testing.T) {
 	c.Validate(t, receivedPodList, err)
 }
 
+func TestListPodsWithFields(t *testing.T) {
+	c := &testClient{
+		Request: testRequest{Method: "GET", Path: "/pods", Query: url.Values{"labels": []string{""}, "fields": []string{"DesiredState.Host=machine"}}},
+		Response: Response{
+			StatusCode: 200,
+			Body: api.PodList{
+				Items: []api.Pod{
+					{DesiredState: api.PodState{Host: "machine"}},
+				},
+			},
+		},
+	}
+	c.Setup()
+	c.QueryValidator["fields"] = validateLabels
+	selector := labels.Set{"DesiredState.Host": "machine"}.AsSelector()
+	receivedPodList, err := c.ListPodsWithFields(labels.Everything(), selector)
+	c.Validate(t, receivedPodList, err)
+}
+
 func TestGetPod(t *testing.T) {
 	c := &testClient{
 		Request: testRequest{Method: "GET", Path: "/pods/foo"},
@@ -241,6 +260,21 @@ func TestUpdateController(t *testing.T) {
 	c.Validate(t, receivedController, err)
 }
 
+func TestResizeController(t *testing.T) {
+	c := &testClient{
+		Request: testRequest{Method: "POST", Path: "/resizes", Body: &api.Resize{ControllerID: "foo", Replicas: 3}},
+		Response: Response{
+			StatusCode: 200,
+			Body: &api.ReplicationController{
+				JSONBase:     api.JSONBase{ID: "foo"},
+				DesiredState: api.ReplicationControllerState{Replicas: 3},
+			},
+		},
+	}
+	receivedController, err := c.Setup().ResizeReplicationController("foo", 3)
+	c.Validate(t, &receivedController, err)
+}
+
 func TestDeleteController(t *testing.T) {
 	c := &testClient{
 		Request:  testRequest{Method: "DELETE", Path: "/replicationControllers/foo"},
@@ -274,6 +308,57 @@ func TestCreateController(t *testing.T) {
 	c.Validate(t, receivedController, err)
 }
 
+func TestCreateDaemonController(t *testing.T) {
+	requestDaemon := api.DaemonController{
+		JSONBase: api.JSONBase{ID: "foo"},
+	}
+	c := &testClient{
+		Request: testRequest{Method: "POST", Path: "/daemonControllers", Body: requestDaemon},
+		Response: Response{
+			StatusCode: 200,
+			Body: api.DaemonController{
+				JSONBase: api.JSONBase{ID: "foo"},
+				DesiredState: api.DaemonControllerState{
+					ReplicaSelector: map[string]string{"name": "logs"},
+				},
+			},
+		},
+	}
+	receivedDaemon, err := c.Setup().CreateDaemonController(requestDaemon)
+	c.Validate(t, receivedDaemon, err)
+}
+
+func TestListDaemonControllers(t *testing.T) {
+	c := &testClient{
+		Request: testRequest{Method: "GET", Path: "/daemonControllers"},
+		Response: Response{StatusCode: 200,
+			Body: api.DaemonControllerList{
+				Items: []api.DaemonController{{JSONBase: api.JSONBase{ID: "foo"}}},
+			},
+		},
+	}
+	receivedDaemonList, err := c.Setup().ListDaemonControllers(labels.Everything())
+	c.Validate(t, receivedDaemonList, err)
+}
+
+func TestDeleteDaemonController(t *testing.T) {
+	c := &testClient{
+		Request:  testRequest{Method: "DELETE", Path: "/daemonControllers/foo"},
+		Response: Response{StatusCode: 200},
+	}
+	err := c.Setup().DeleteDaemonController("foo")
+	c.Validate(t, nil, err)
+}
+
+func TestBindPod(t *testing.T) {
+	c := &testClient{
+		Request:  testRequest{Method: "POST", Path: "/bindings", Body: api.Binding{PodID: "foo", Host: "machine"}},
+		Response: Response{StatusCode: 200},
+	}
+	err := c.Setup().BindPod("foo", "machine")
+	c.Validate(t, nil, err)
+}
+
 func body(obj interface{}, raw *string) *string {
 	if obj != nil {
 		bs, _ := api.Encode(obj)
@@ -373,6 +458,42 @@ func (c *testClient) Validate(t *testing.T, received interface{}, err error) {
 	}
 }
 
+func TestListServices(t *testing.T) {
+	c := &testClient{
+		Request: testRequest{Method: "GET", Path: "/services"},
+		Response: Response{StatusCode: 200,
+			Body: api.ServiceList{
+				Items: []api.Service{
+					{
+						JSONBase: api.JSONBase{ID: "service-1"},
+						Port:     8080,
+					},
+				},
+			},
+		},
+	}
+	receivedServiceList, err := c.Setup().ListServices(labels.Everything())
+	c.Validate(t, receivedServiceList, err)
+}
+
+func TestListEndpoints(t *testing.T) {
+	c := &testClient{
+		Request: testRequest{Method: "GET", Path: "/endpoints"},
+		Response: Response{StatusCode: 200,
+			Body: api.EndpointsList{
+				Items: []api.Endpoints{
+					{
+						JSONBase:  api.JSONBase{ID: "service-1"},
+						Endpoints: []string{"10.245.1.2:8080", "10.245.1.3:8080"},
+					},
+				},
+			},
+		},
+	}
+	receivedEndpointsList, err := c.Setup().ListEndpoints(labels.Everything())
+	c.Validate(t, receivedEndpointsList, err)
+}
+
 func TestGetService(t *testing.T) {
 	c := &testClient{
 		Request:  testRequest{Method: "GET", Path: "/services/1"},
@@ -391,6 +512,62 @@ func TestCreateService(t *testing.T) {
 	c.Validate(t, &response, err)
 }
 
+func TestReportPodStatus(t *testing.T) {
+	report := api.PodStatusReport{JSONBase: api.JSONBase{ID: "foo"}, Host: "machine"}
+	c := &testClient{
+		Request:  testRequest{Method: "POST", Path: "/podStatusReports", Body: &report},
+		Response: Response{StatusCode: 200},
+	}
+	err := c.Setup().ReportPodStatus(report)
+	c.Validate(t, nil, err)
+}
+
+func TestCreateMinion(t *testing.T) {
+	minion := api.Minion{JSONBase: api.JSONBase{ID: "minion-1"}}
+	c := &testClient{
+		Request:  testRequest{Method: "POST", Path: "/minions", Body: &minion},
+		Response: Response{StatusCode: 200, Body: &minion},
+	}
+	response, err := c.Setup().CreateMinion(minion)
+	c.Validate(t, &response, err)
+}
+
+func TestListMinions(t *testing.T) {
+	c := &testClient{
+		Request:  testRequest{Method: "GET", Path: "/minions"},
+		Response: Response{StatusCode: 200, Body: &api.MinionList{Items: []api.Minion{{JSONBase: api.JSONBase{ID: "minion-1"}}}}},
+	}
+	response, err := c.Setup().ListMinions()
+	c.Validate(t, &response, err)
+}
+
+func TestCreateEvent(t *testing.T) {
+	event := api.Event{
+		InvolvedObject: api.ObjectReference{Kind: "Pod", ID: "foo"},
+		Reason:         "started",
+	}
+	c := &testClient{
+		Request:  testRequest{Method: "POST", Path: "/events", Body: &event},
+		Response: Response{StatusCode: 200, Body: &event},
+	}
+	response, err := c.Setup().CreateEvent(event)
+	c.Validate(t, &response, err)
+}
+
+func TestListEvents(t *testing.T) {
+	c := &testClient{
+		Request: testRequest{Method: "GET", Path: "/events", Query: url.Values{"labels": []string{"InvolvedObject.ID=foo"}}},
+		Response: Response{
+			StatusCode: 200,
+			Body:       &api.EventList{Items: []api.Event{{JSONBase: api.JSONBase{ID: "1"}, Reason: "started"}}},
+		},
+	}
+	c.Setup()
+	c.QueryValidator["labels"] = validateLabels
+	response, err := c.ListEvents(labels.Set{"InvolvedObject.ID": "foo"}.AsSelector())
+	c.Validate(t, &response, err)
+}
+
 func TestUpdateService(t *testing.T) {
 	svc := api.Service{JSONBase: api.JSONBase{ID: "service-1", ResourceVersion: 1}}
 	c := &testClient{
@@ -460,6 +637,64 @@ func TestDoRequestAccepted(t *testing.T) {
 	fakeHandler.ValidateRequest(t, "/foo/bar", "GET", nil)
 }
 
+func TestDoRequestInvalid(t *testing.T) {
+	status := api.Status{
+		Status: api.StatusFailure,
+		Code:   422,
+		Reason: api.ReasonTypeInvalid,
+		Details: &api.StatusDetails{
+			Kind: "pod",
+			ID:   "foo",
+			Causes: []api.StatusCause{
+				{Type: api.CauseTypeFieldValueInvalid, Field: "Pod.ID"},
+			},
+		},
+	}
+	expectedBody, _ := api.Encode(status)
+	fakeHandler := util.FakeHandler{
+		StatusCode:   422,
+		ResponseBody: string(expectedBody),
+		T:            t,
+	}
+	testServer := httptest.NewServer(&fakeHandler)
+	request, _ := http.NewRequest("POST", testServer.URL+"/foo/bar", nil)
+	c := New(testServer.URL, nil)
+	_, err := c.doRequest(request)
+	se, ok := err.(*StatusErr)
+	if !ok {
+		t.Fatalf("Unexpected kind of error: %#v", err)
+	}
+	if !reflect.DeepEqual(se.Status, status) {
+		t.Errorf("Unexpected status: %#v", se.Status)
+	}
+}
+
+func TestDoRequestNotFound(t *testing.T) {
+	status := api.Status{
+		Status:  api.StatusFailure,
+		Code:    http.StatusNotFound,
+		Reason:  api.ReasonTypeNotFound,
+		Details: &api.StatusDetails{Kind: "pod", ID: "foo"},
+	}
+	expectedBody, _ := api.Encode(status)
+	fakeHandler := util.FakeHandler{
+		StatusCode:   http.StatusNotFound,
+		ResponseBody: string(expectedBody),
+		T:            t,
+	}
+	testServer := httptest.NewServer(&fakeHandler)
+	request, _ := http.NewRequest("GET", testServer.URL+"/foo/bar", nil)
+	c := New(testServer.URL, nil)
+	_, err := c.doRequest(request)
+	se, ok := err.(*StatusErr)
+	if !ok {
+		t.Fatalf("Unexpected kind of error: %#v", err)
+	}
+	if !reflect.DeepEqual(se.Status, status) {
+		t.Errorf("Unexpected status: %#v", se.Status)
+	}
+}
+
 func TestDoRequestAcceptedSuccess(t *testing.T) {
 	status := api.Status{Status: api.StatusSuccess}
 	expectedBody, _ := api.Encode(status)
@@ -515,3 +750,29 @@ func TestGetServerVersion(t *testing.T) {
 		t.Errorf("expected %v, got %v", e, a)
 	}
 }
+
+func TestGetServerAPIVersions(t *testing.T) {
+	expect := api.APIVersions{Versions: []string{"v1beta1", "v1beta2"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		output, err := json.Marshal(expect)
+		if err != nil {
+			t.Errorf("unexpected encoding error: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(output)
+	}))
+	client := New(server.URL, nil)
+
+	got, err := client.ServerAPIVersions()
+	if err != nil {
+		t.Fatalf("unexpected encoding error: %v", err)
+	}
+	if e, a := expect, *got; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
@@ -35,6 +35,10 @@ type Fake struct {
 	Actions []FakeAction
 	Pods    api.PodList
 	Ctrl    api.ReplicationController
+	// What ListEndpoints returns.
+	EndpointsList api.EndpointsList
+	// What WatchPods returns; nil means a new watch.FakeWatcher.
+	PodWatch watch.Interface
 }
 
 func (c *Fake) ListPods(selector labels.Selector) (api.PodList, error) {
@@ -42,6 +46,11 @@ func (c *Fake) ListPods(selector labels.Selector) (api.PodList, error) {
 	return c.Pods, nil
 }
 
+func (c *Fake) ListPodsWithFields(label, field labels.Selector) (api.PodList, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "list-pods", Value: field.String()})
+	return c.Pods, nil
+}
+
 func (c *Fake) GetPod(name string) (api.Pod, error) {
 	c.Actions = append(c.Actions, FakeAction{Action: "get-pod", Value: name})
 	return api.Pod{}, nil
@@ -62,6 +71,24 @@ func (c *Fake) UpdatePod(pod api.Pod) (api.Pod, error) {
 	return api.Pod{}, nil
 }
 
+func (c *Fake) ReportPodStatus(report api.PodStatusReport) error {
+	c.Actions = append(c.Actions, FakeAction{Action: "report-pod-status", Value: report})
+	return nil
+}
+
+func (c *Fake) BindPod(podID, host string) error {
+	c.Actions = append(c.Actions, FakeAction{Action: "bind-pod", Value: api.Binding{PodID: podID, Host: host}})
+	return nil
+}
+
+func (c *Fake) WatchPods(label, field labels.Selector, resourceVersion uint64) (watch.Interface, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "watch-pods", Value: resourceVersion})
+	if c.PodWatch != nil {
+		return c.PodWatch, nil
+	}
+	return watch.NewFake(), nil
+}
+
 func (c *Fake) ListReplicationControllers(selector labels.Selector) (api.ReplicationControllerList, error) {
 	c.Actions = append(c.Actions, FakeAction{Action: "list-controllers"})
 	return api.ReplicationControllerList{}, nil
@@ -82,6 +109,13 @@ func (c *Fake) UpdateReplicationController(controller api.ReplicationController)
 	return api.ReplicationController{}, nil
 }
 
+func (c *Fake) ResizeReplicationController(name string, replicas int) (api.ReplicationController, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "resize-controller", Value: api.Resize{ControllerID: name, Replicas: replicas}})
+	controller := c.Ctrl
+	controller.DesiredState.Replicas = replicas
+	return controller, nil
+}
+
 func (c *Fake) DeleteReplicationController(controller string) error {
 	c.Actions = append(c.Actions, FakeAction{Action: "delete-controller", Value: controller})
 	return nil
@@ -92,6 +126,41 @@ func (c *Fake) WatchReplicationControllers(label, field labels.Selector, resourc
 	return watch.NewFake(), nil
 }
 
+func (c *Fake) ListDaemonControllers(selector labels.Selector) (api.DaemonControllerList, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "list-daemons"})
+	return api.DaemonControllerList{}, nil
+}
+
+func (c *Fake) GetDaemonController(name string) (api.DaemonController, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "get-daemon", Value: name})
+	return api.DaemonController{}, nil
+}
+
+func (c *Fake) CreateDaemonController(daemon api.DaemonController) (api.DaemonController, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "create-daemon", Value: daemon})
+	return api.DaemonController{}, nil
+}
+
+func (c *Fake) UpdateDaemonController(daemon api.DaemonController) (api.DaemonController, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "update-daemon", Value: daemon})
+	return api.DaemonController{}, nil
+}
+
+func (c *Fake) DeleteDaemonController(daemon string) error {
+	c.Actions = append(c.Actions, FakeAction{Action: "delete-daemon", Value: daemon})
+	return nil
+}
+
+func (c *Fake) WatchDaemonControllers(label, field labels.Selector, resourceVersion uint64) (watch.Interface, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "watch-daemons"})
+	return watch.NewFake(), nil
+}
+
+func (c *Fake) ListServices(selector labels.Selector) (api.ServiceList, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "list-services"})
+	return api.ServiceList{}, nil
+}
+
 func (c *Fake) GetService(name string) (api.Service, error) {
 	c.Actions = append(c.Actions, FakeAction{Action: "get-service", Value: name})
 	return api.Service{}, nil
@@ -112,6 +181,31 @@ func (c *Fake) DeleteService(service string) error {
 	return nil
 }
 
+func (c *Fake) ListEndpoints(selector labels.Selector) (api.EndpointsList, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "list-endpoints"})
+	return c.EndpointsList, nil
+}
+
+func (c *Fake) ListMinions() (api.MinionList, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "list-minions"})
+	return api.MinionList{}, nil
+}
+
+func (c *Fake) CreateMinion(minion api.Minion) (api.Minion, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "create-minion", Value: minion})
+	return api.Minion{}, nil
+}
+
+func (c *Fake) CreateEvent(event api.Event) (api.Event, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "create-event", Value: event})
+	return api.Event{}, nil
+}
+
+func (c *Fake) ListEvents(selector labels.Selector) (api.EventList, error) {
+	c.Actions = append(c.Actions, FakeAction{Action: "list-events"})
+	return api.EventList{}, nil
+}
+
 func (c *Fake) ServerVersion() (*version.Info, error) {
 	c.Actions = append(c.Actions, FakeAction{Action: "get-version", Value: nil})
 	versionInfo := version.Get()
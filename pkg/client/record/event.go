@@ -0,0 +1,120 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package record
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+	"github.com/golang/glog"
+)
+
+// queueLen is how many events may be waiting to be handed out, or to be sent by
+// StartRecording, before further ones are dropped. Recording an event never
+// blocks, so a slow or unreachable apiserver can't hold up the recorder.
+const queueLen = 1000
+
+// events hands every recorded event to everyone watching it.
+var events = watch.NewMux(queueLen)
+
+// dropped counts the events dropped because a queue was full.
+var dropped = metrics.Default.Counter("events.dropped")
+
+// EventRecorder knows how to store events. client.Client implements it.
+type EventRecorder interface {
+	CreateEvent(event api.Event) (api.Event, error)
+}
+
+// StartRecording sends the events recorded from now on to recorder, with their
+// Source set to source. Events are sent from a goroutine of their own; while
+// queueLen of them are waiting to be sent, further ones are dropped. Stop the
+// returned watch to stop sending them.
+func StartRecording(recorder EventRecorder, source string) watch.Interface {
+	pending := make(chan api.Event, queueLen)
+	go func() {
+		defer util.HandleCrash()
+		for event := range pending {
+			if _, err := recorder.CreateEvent(event); err != nil {
+				glog.Errorf("Unable to write event %#v: %v", event, err)
+			}
+		}
+	}()
+	return watchEvents(func(event api.Event) {
+		event.Source = source
+		select {
+		case pending <- event:
+		default:
+			dropped.Inc()
+			glog.V(2).Infof("Dropping event %#v, too many are waiting to be written", event)
+		}
+	}, func() { close(pending) })
+}
+
+// StartLogging logs the events recorded from now on with logf, e.g. glog.Infof.
+// Stop the returned watch to stop logging them.
+func StartLogging(logf func(format string, args ...interface{})) watch.Interface {
+	return GetEvents(func(event api.Event) {
+		logf("Event(%#v): reason: '%v' %v", event.InvolvedObject, event.Reason, event.Message)
+	})
+}
+
+// GetEvents calls handler with each event recorded from now on, one at a time.
+// Stop the returned watch to stop calling it.
+func GetEvents(handler func(api.Event)) watch.Interface {
+	return watchEvents(handler, func() {})
+}
+
+// watchEvents is like GetEvents, but also calls done once the watch is stopped.
+func watchEvents(handler func(api.Event), done func()) watch.Interface {
+	w := events.Watch()
+	go func() {
+		defer util.HandleCrash()
+		defer done()
+		for watchEvent := range w.ResultChan() {
+			event, ok := watchEvent.Object.(*api.Event)
+			if !ok {
+				continue
+			}
+			handler(*event)
+		}
+	}()
+	return w
+}
+
+// Event records that reason happened to object, e.g. that a container was
+// "started". message describes what happened for humans. If too many events are
+// waiting to be handed out, the event is dropped.
+func Event(object api.ObjectReference, reason, message string) {
+	event := &api.Event{
+		InvolvedObject: object,
+		Reason:         reason,
+		Message:        message,
+		Timestamp:      util.Now(),
+	}
+	if !events.TryAction(watch.Added, event) {
+		dropped.Inc()
+		glog.V(2).Infof("Dropping event %#v, too many are waiting to be handed out", event)
+	}
+}
+
+// Eventf is like Event, but formats the message with fmt.Sprintf.
+func Eventf(object api.ObjectReference, reason, messageFmt string, args ...interface{}) {
+	Event(object, reason, fmt.Sprintf(messageFmt, args...))
+}
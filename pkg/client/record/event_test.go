@@ -0,0 +1,91 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package record
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+type testEventRecorder struct {
+	events chan api.Event
+	err    error
+}
+
+func (r *testEventRecorder) CreateEvent(event api.Event) (api.Event, error) {
+	r.events <- event
+	return event, r.err
+}
+
+func TestEventf(t *testing.T) {
+	recorder := &testEventRecorder{events: make(chan api.Event, 1)}
+	recording := StartRecording(recorder, "kubelet machine")
+	defer recording.Stop()
+	logged := make(chan string, 1)
+	logging := StartLogging(func(format string, args ...interface{}) {
+		logged <- fmt.Sprintf(format, args...)
+	})
+	defer logging.Stop()
+
+	object := api.ObjectReference{Kind: "Pod", ID: "foo", FieldPath: "desiredState.manifest.containers[bar]"}
+	Eventf(object, "started", "Started container %s", "1234")
+
+	event := <-recorder.events
+	if event.InvolvedObject != object || event.Reason != "started" || event.Message != "Started container 1234" {
+		t.Errorf("unexpected event: %#v", event)
+	}
+	if event.Source != "kubelet machine" || event.Timestamp.IsZero() {
+		t.Errorf("expected the source and time to be set, got %#v", event)
+	}
+	if e, a := fmt.Sprintf("Event(%#v): reason: 'started' Started container 1234", object), <-logged; e != a {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+}
+
+func TestRecordingSurvivesErrors(t *testing.T) {
+	recorder := &testEventRecorder{events: make(chan api.Event, 2), err: fmt.Errorf("apiserver is down")}
+	recording := StartRecording(recorder, "kubelet machine")
+	defer recording.Stop()
+
+	object := api.ObjectReference{Kind: "Pod", ID: "foo"}
+	Event(object, "pulled", "")
+	Event(object, "created", "")
+	for _, reason := range []string{"pulled", "created"} {
+		if event := <-recorder.events; event.Reason != reason {
+			t.Errorf("expected %s, got %#v", reason, event)
+		}
+	}
+}
+
+func TestRecordingDoesntBlock(t *testing.T) {
+	recorder := &testEventRecorder{events: make(chan api.Event)}
+	recording := StartRecording(recorder, "kubelet machine")
+	defer recording.Stop()
+
+	// Nothing reads recorder.events, so the first event being written blocks
+	// forever; the others pile up until they're dropped.
+	before := dropped.Value()
+	object := api.ObjectReference{Kind: "Pod", ID: "foo"}
+	for i := 0; i < 3*queueLen; i++ {
+		Event(object, "pulled", "")
+	}
+	if dropped.Value() == before {
+		t.Errorf("expected events to be dropped")
+	}
+}
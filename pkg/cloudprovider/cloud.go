@@ -35,8 +35,10 @@ type TCPLoadBalancer interface {
 	// TCPLoadBalancerExists returns whether the specified load balancer exists.
 	// TODO: Break this up into different interfaces (LB, etc) when we have more than one type of service
 	TCPLoadBalancerExists(name, region string) (bool, error)
-	// CreateTCPLoadBalancer creates a new tcp load balancer.
-	CreateTCPLoadBalancer(name, region string, port int, hosts []string) error
+	// CreateTCPLoadBalancer creates a new tcp load balancer forwarding port on
+	// externalIP to hosts, and returns the IP it listens on. If externalIP is
+	// nil, the cloud provider picks one.
+	CreateTCPLoadBalancer(name, region string, externalIP net.IP, port int, hosts []string) (net.IP, error)
 	// UpdateTCPLoadBalancer updates hosts under the specified load balancer.
 	UpdateTCPLoadBalancer(name, region string, hosts []string) error
 	// DeleteTCPLoadBalancer deletes a specified load balancer.
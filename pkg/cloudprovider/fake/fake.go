@@ -30,6 +30,8 @@ type FakeCloud struct {
 	Calls    []string
 	IP       net.IP
 	Machines []string
+	// What CreateTCPLoadBalancer returns when it isn't given an IP.
+	ExternalIP net.IP
 	cloudprovider.Zone
 }
 
@@ -67,9 +69,13 @@ func (f *FakeCloud) TCPLoadBalancerExists(name, region string) (bool, error) {
 
 // CreateTCPLoadBalancer is a test-spy implementation of TCPLoadBalancer.CreateTCPLoadBalancer.
 // It adds an entry "create" into the internal method call record.
-func (f *FakeCloud) CreateTCPLoadBalancer(name, region string, port int, hosts []string) error {
+// The load balancer gets externalIP, or ExternalIP if externalIP is nil.
+func (f *FakeCloud) CreateTCPLoadBalancer(name, region string, externalIP net.IP, port int, hosts []string) (net.IP, error) {
 	f.addCall("create")
-	return f.Err
+	if externalIP == nil {
+		externalIP = f.ExternalIP
+	}
+	return externalIP, f.Err
 }
 
 // UpdateTCPLoadBalancer is a test-spy implementation of TCPLoadBalancer.UpdateTCPLoadBalancer.
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -142,6 +143,22 @@ func (gce *GCECloud) waitForRegionOp(op *compute.Operation, region string) error
 	return nil
 }
 
+func (gce *GCECloud) waitForZoneOp(op *compute.Operation) error {
+	pollOp := op
+	for pollOp.Status != "DONE" {
+		var err error
+		time.Sleep(time.Second)
+		pollOp, err = gce.service.ZoneOperations.Get(gce.projectID, gce.zone, op.Name).Do()
+		if err != nil {
+			return err
+		}
+	}
+	if pollOp.Error != nil && len(pollOp.Error.Errors) > 0 {
+		return fmt.Errorf("operation %s failed: %s", op.Name, pollOp.Error.Errors[0].Message)
+	}
+	return nil
+}
+
 // TCPLoadBalancerExists is an implementation of TCPLoadBalancer.TCPLoadBalancerExists.
 func (gce *GCECloud) TCPLoadBalancerExists(name, region string) (bool, error) {
 	_, err := gce.service.ForwardingRules.Get(gce.projectID, region, name).Do()
@@ -149,10 +166,10 @@ func (gce *GCECloud) TCPLoadBalancerExists(name, region string) (bool, error) {
 }
 
 // CreateTCPLoadBalancer is an implementation of TCPLoadBalancer.CreateTCPLoadBalancer.
-func (gce *GCECloud) CreateTCPLoadBalancer(name, region string, port int, hosts []string) error {
+func (gce *GCECloud) CreateTCPLoadBalancer(name, region string, externalIP net.IP, port int, hosts []string) (net.IP, error) {
 	pool, err := gce.makeTargetPool(name, region, hosts)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req := &compute.ForwardingRule{
 		Name:       name,
@@ -160,8 +177,21 @@ func (gce *GCECloud) CreateTCPLoadBalancer(name, region string, port int, hosts
 		PortRange:  strconv.Itoa(port),
 		Target:     pool,
 	}
-	_, err = gce.service.ForwardingRules.Insert(gce.projectID, region, req).Do()
-	return err
+	if externalIP != nil {
+		req.IPAddress = externalIP.String()
+	}
+	op, err := gce.service.ForwardingRules.Insert(gce.projectID, region, req).Do()
+	if err != nil {
+		return nil, err
+	}
+	if err := gce.waitForRegionOp(op, region); err != nil {
+		return nil, err
+	}
+	rule, err := gce.service.ForwardingRules.Get(gce.projectID, region, name).Do()
+	if err != nil {
+		return nil, err
+	}
+	return net.ParseIP(rule.IPAddress), nil
 }
 
 // UpdateTCPLoadBalancer is an implementation of TCPLoadBalancer.UpdateTCPLoadBalancer.
@@ -263,3 +293,65 @@ func getGceRegion(zone string) (string, error) {
 	}
 	return zone[:ix], nil
 }
+
+// localInstanceName returns the name of the instance this process runs on.
+func localInstanceName() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	if ix := strings.Index(hostname, "."); ix != -1 {
+		hostname = hostname[:ix]
+	}
+	return hostname, nil
+}
+
+// AttachDisk attaches the persistent disk named diskName to the instance this
+// process runs on, using the disk name as the device name. A disk attached
+// read-only may be attached to many instances at once. Attaching a disk that
+// is already attached to this instance is a no-op.
+func (gce *GCECloud) AttachDisk(diskName string, readOnly bool) error {
+	instance, err := localInstanceName()
+	if err != nil {
+		return err
+	}
+	res, err := gce.service.Instances.Get(gce.projectID, gce.zone, instance).Do()
+	if err != nil {
+		return err
+	}
+	for _, disk := range res.Disks {
+		if disk.DeviceName == diskName {
+			return nil
+		}
+	}
+	mode := "READ_WRITE"
+	if readOnly {
+		mode = "READ_ONLY"
+	}
+	attachedDisk := &compute.AttachedDisk{
+		DeviceName: diskName,
+		Kind:       "compute#attachedDisk",
+		Mode:       mode,
+		Source:     fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/disks/%s", gce.projectID, gce.zone, diskName),
+		Type:       "PERSISTENT",
+	}
+	op, err := gce.service.Instances.AttachDisk(gce.projectID, gce.zone, instance, attachedDisk).Do()
+	if err != nil {
+		return err
+	}
+	return gce.waitForZoneOp(op)
+}
+
+// DetachDisk detaches the persistent disk attached with device name diskName
+// from the instance this process runs on.
+func (gce *GCECloud) DetachDisk(diskName string) error {
+	instance, err := localInstanceName()
+	if err != nil {
+		return err
+	}
+	op, err := gce.service.Instances.DetachDisk(gce.projectID, gce.zone, instance, diskName).Do()
+	if err != nil {
+		return err
+	}
+	return gce.waitForZoneOp(op)
+}
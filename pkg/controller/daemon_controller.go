@@ -0,0 +1,386 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+	"github.com/golang/glog"
+)
+
+const (
+	// DaemonSchedulerName is the scheduler named by the pods of daemon
+	// controllers. No scheduler goes by it; the daemon manager binds the pods
+	// itself.
+	DaemonSchedulerName = "daemon"
+	// DaemonHostAnnotation is the annotation of daemon pods naming the minion
+	// they were created for, which tells where a pod belongs before it is bound.
+	DaemonHostAnnotation = "daemonHost"
+	// daemonControllerLabel is the label of daemon pods naming their controller,
+	// by which they are found once the controller is deleted.
+	daemonControllerLabel = "daemonController"
+)
+
+// minionPollPeriod is how often the daemon manager lists minions, which can't be
+// watched.
+const minionPollPeriod = 10 * time.Second
+
+// DaemonManager is responsible for synchronizing DaemonController objects stored
+// in the system with the pods running on every minion. It watches daemon
+// controllers and pods and polls minions, and syncs a daemon controller as soon
+// as it, one of its pods or the set of minions changes.
+type DaemonManager struct {
+	kubeClient client.Interface
+	podControl PodControlInterface
+
+	// daemons and the pods are kept up to date by watches, minions by polling.
+	daemons cache.Store
+	controlledPods
+	minions cache.Store
+	// queue holds the IDs of the daemon controllers waiting to be synced.
+	queue *workQueue
+	// unbound maps the IDs of pods which were created but could neither be
+	// bound nor deleted again to the IDs of their daemon controllers, whose
+	// syncs keep trying to delete them. Only the worker uses it.
+	unbound map[string]string
+
+	// To allow injection of syncDaemonController for testing.
+	syncHandler func(daemon api.DaemonController) error
+}
+
+// NewDaemonManager creates a new DaemonManager.
+func NewDaemonManager(kubeClient client.Interface) *DaemonManager {
+	dm := &DaemonManager{
+		kubeClient: kubeClient,
+		podControl: RealPodControl{
+			kubeClient: kubeClient,
+		},
+		daemons: cache.NewStore(),
+		minions: cache.NewStore(),
+		queue:   newWorkQueue(),
+		unbound: map[string]string{},
+	}
+	dm.controlledPods = newControlledPods(dm.daemonsOf, dm.enqueue)
+	dm.syncHandler = dm.syncDaemonController
+	return dm
+}
+
+// Run begins watching, polling and syncing. Every period, daemon controllers and
+// pods are also listed again and every daemon controller is synced, in case a
+// change was missed.
+func (dm *DaemonManager) Run(period time.Duration) {
+	daemonReflector := cache.NewListWatchReflector(
+		dm.listDaemons,
+		dm.watchDaemons,
+		&api.DaemonController{},
+		daemonNotifier{controllerNotifier{dm.daemons, dm.enqueue}},
+	)
+	daemonReflector.SetResyncPeriod(period)
+	daemonReflector.Run()
+	podReflector := cache.NewListWatchReflector(
+		dm.listPods,
+		dm.watchPods,
+		&api.Pod{},
+		podNotifier{dm.pods.Indexer, dm.podChanged},
+	)
+	podReflector.SetResyncPeriod(period)
+	podReflector.Run()
+	minionPoller := cache.NewPoller(dm.pollMinions, minionPollPeriod, minionNotifier{dm.minions, dm.synchronize})
+	minionPoller.Run()
+	go func() {
+		// Until every pod and minion is known, pods would be created on minions
+		// which have one already.
+		for !podReflector.HasListed() || !minionPoller.HasListed() {
+			time.Sleep(100 * time.Millisecond)
+		}
+		go util.Forever(dm.worker, time.Second)
+	}()
+}
+
+func (dm *DaemonManager) listDaemons() (interface{}, error) {
+	return dm.kubeClient.ListDaemonControllers(labels.Everything())
+}
+
+func (dm *DaemonManager) watchDaemons(resourceVersion uint64) (watch.Interface, error) {
+	return dm.kubeClient.WatchDaemonControllers(labels.Everything(), labels.Everything(), resourceVersion)
+}
+
+func (dm *DaemonManager) listPods() (interface{}, error) {
+	return dm.kubeClient.ListPods(labels.Everything())
+}
+
+func (dm *DaemonManager) watchPods(resourceVersion uint64) (watch.Interface, error) {
+	return dm.kubeClient.WatchPods(labels.Everything(), labels.Everything(), resourceVersion)
+}
+
+// pollMinions lists all minions and returns an enumerator for cache.Poller.
+func (dm *DaemonManager) pollMinions() (cache.Enumerator, error) {
+	list, err := dm.kubeClient.ListMinions()
+	if err != nil {
+		return nil, err
+	}
+	return minionEnumerator{list.Items}, nil
+}
+
+// enqueue queues the daemon controller with id to be synced.
+func (dm *DaemonManager) enqueue(id string) {
+	dm.queue.add(id)
+}
+
+// daemonsOf returns the IDs of the daemon controllers which select pod.
+func (dm *DaemonManager) daemonsOf(pod *api.Pod) []string {
+	var ids []string
+	for _, obj := range dm.daemons.List() {
+		daemon := obj.(*api.DaemonController)
+		s := labels.Set(daemon.DesiredState.ReplicaSelector).AsSelector()
+		if s.Matches(labels.Set(pod.Labels)) {
+			ids = append(ids, daemon.ID)
+		}
+	}
+	return ids
+}
+
+// worker syncs the daemon controllers in the queue, one at a time.
+func (dm *DaemonManager) worker() {
+	for {
+		dm.syncNext()
+	}
+}
+
+// syncNext syncs the next daemon controller in the queue, or deletes the pods
+// of a deleted one.
+func (dm *DaemonManager) syncNext() {
+	id := dm.queue.get()
+	defer dm.queue.done(id)
+	var err error
+	if obj, exists := dm.daemons.Get(id); exists {
+		err = dm.syncHandler(*obj.(*api.DaemonController))
+	} else {
+		dm.expectations.forget(id)
+		err = dm.deleteOrphans(id)
+	}
+	if err != nil {
+		glog.Errorf("Error synchronizing daemon %s: %v", id, err)
+		dm.queue.retry(id)
+		return
+	}
+	dm.queue.forgetRetries(id)
+}
+
+// deleteOrphans deletes the pods of the deleted daemon controller with id. It
+// returns an error if any deletion failed.
+func (dm *DaemonManager) deleteOrphans(id string) error {
+	failures := 0
+	for _, obj := range dm.pods.Index(cache.PodLabelIndex, daemonControllerLabel+"="+id) {
+		pod := obj.(*api.Pod)
+		if err := dm.podControl.deletePod(pod.ID); err != nil {
+			glog.Errorf("Failed to delete %s of deleted daemon %s: %v", pod.ID, id, err)
+			failures++
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d pods of deleted daemon %s couldn't be deleted", failures, id)
+	}
+	for podID, daemonID := range dm.unbound {
+		if daemonID == id {
+			delete(dm.unbound, podID)
+		}
+	}
+	return nil
+}
+
+// daemonHost returns the minion pod runs on, or was created for if it isn't
+// bound yet.
+func daemonHost(pod api.Pod) string {
+	if pod.DesiredState.Host != "" {
+		return pod.DesiredState.Host
+	}
+	return pod.Annotations[DaemonHostAnnotation]
+}
+
+// syncDaemonController creates or deletes pods until every minion selected by
+// daemon runs one of its pods. It returns an error if any creation or deletion
+// failed, so that daemon is synced again later.
+func (dm *DaemonManager) syncDaemonController(daemon api.DaemonController) error {
+	if !dm.expectations.satisfied(daemon.ID) {
+		glog.V(4).Infof("Waiting for the pods of daemon %s to be created or deleted", daemon.ID)
+		return nil
+	}
+	// The creations are seen by now, so unbound pods which aren't known any
+	// more were deleted by someone else.
+	for podID, id := range dm.unbound {
+		if _, exists := dm.pods.Indexer.Get(podID); id == daemon.ID && !exists {
+			delete(dm.unbound, podID)
+		}
+	}
+	nodeSelector := labels.Set(daemon.DesiredState.PodTemplate.NodeSelector).AsSelector()
+	wanted := util.StringSet{}
+	for _, obj := range dm.minions.List() {
+		minion := obj.(*api.Minion)
+		if nodeSelector.Matches(labels.Set(minion.Labels)) {
+			wanted.Insert(minion.ID)
+		}
+	}
+
+	s := labels.Set(daemon.DesiredState.ReplicaSelector).AsSelector()
+	podsByHost := map[string][]api.Pod{}
+	var deletions []api.Pod
+	selected, _ := dm.pods.ListPods(s)
+	for _, pod := range selected {
+		if _, ok := dm.unbound[pod.ID]; ok {
+			deletions = append(deletions, pod)
+			continue
+		}
+		if pod.CurrentState.Status != api.PodTerminated {
+			host := daemonHost(pod)
+			podsByHost[host] = append(podsByHost[host], pod)
+		}
+	}
+
+	var creations []string
+	for host := range wanted {
+		if len(podsByHost[host]) == 0 {
+			creations = append(creations, host)
+		}
+	}
+	for host, pods := range podsByHost {
+		if !wanted.Has(host) {
+			deletions = append(deletions, pods...)
+			continue
+		}
+		// Only the pod furthest along is kept.
+		sortForScaleDown(pods, ScaleDownUnready)
+		deletions = append(deletions, pods[:len(pods)-1]...)
+	}
+	if len(creations) == 0 && len(deletions) == 0 {
+		return nil
+	}
+
+	glog.Infof("Daemon %s is missing from %d minions and has %d surplus pods", daemon.ID, len(creations), len(deletions))
+	dm.expectations.expect(daemon.ID, len(creations), len(deletions))
+	wait := sync.WaitGroup{}
+	wait.Add(len(creations) + len(deletions))
+	// lock guards failures and unbound while the pods are created and deleted.
+	var lock sync.Mutex
+	failures := 0
+	for _, host := range creations {
+		go func(host string) {
+			defer wait.Done()
+			err := dm.podControl.createDaemonPod(daemon, host)
+			if err == nil {
+				return
+			}
+			glog.Errorf("Failed to create a pod of daemon %s on %s: %v", daemon.ID, host, err)
+			lock.Lock()
+			defer lock.Unlock()
+			failures++
+			// A pod which was created, but couldn't be bound, is still seen by
+			// the pod watch.
+			bindErr, created := err.(*bindError)
+			if !created {
+				dm.expectations.creationObserved(daemon.ID)
+			} else if bindErr.deleteErr != nil {
+				dm.unbound[bindErr.podID] = daemon.ID
+			}
+		}(host)
+	}
+	for _, pod := range deletions {
+		go func(podID string) {
+			defer wait.Done()
+			err := dm.podControl.deletePod(podID)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				glog.Errorf("Failed to delete %s of daemon %s: %v", podID, daemon.ID, err)
+				dm.expectations.deletionObserved(daemon.ID)
+				failures++
+				return
+			}
+			delete(dm.unbound, podID)
+		}(pod.ID)
+	}
+	wait.Wait()
+	if failures > 0 {
+		return fmt.Errorf("%d of %d pod creations or deletions failed", failures, len(creations)+len(deletions))
+	}
+	return nil
+}
+
+// synchronize queues every daemon controller to be synced, e.g. since minions
+// changed.
+func (dm *DaemonManager) synchronize() {
+	for id := range dm.daemons.Contains() {
+		dm.enqueue(id)
+	}
+}
+
+// daemonNotifier is a controllerNotifier which also queues deleted daemon
+// controllers, for their pods to be deleted.
+type daemonNotifier struct {
+	controllerNotifier
+}
+
+func (n daemonNotifier) Delete(id string) {
+	n.Store.Delete(id)
+	n.enqueue(id)
+}
+
+// minionNotifier calls changed whenever a minion joins or leaves, or its labels
+// change, since any of these can change where daemons run.
+type minionNotifier struct {
+	cache.Store
+	changed func()
+}
+
+func (n minionNotifier) Add(id string, obj interface{}) {
+	n.Update(id, obj)
+}
+
+func (n minionNotifier) Update(id string, obj interface{}) {
+	old, exists := n.Store.Get(id)
+	n.Store.Update(id, obj)
+	if !exists || !reflect.DeepEqual(old.(*api.Minion).Labels, obj.(*api.Minion).Labels) {
+		n.changed()
+	}
+}
+
+func (n minionNotifier) Delete(id string) {
+	n.Store.Delete(id)
+	n.changed()
+}
+
+// minionEnumerator allows a cache.Poller to enumerate minions.
+type minionEnumerator struct {
+	items []api.Minion
+}
+
+func (e minionEnumerator) Len() int {
+	return len(e.items)
+}
+
+func (e minionEnumerator) Get(index int) (string, interface{}) {
+	return e.items[index].ID, &e.items[index]
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+)
+
+func newDaemonController() api.DaemonController {
+	return api.DaemonController{
+		JSONBase: api.JSONBase{ID: "logger"},
+		DesiredState: api.DaemonControllerState{
+			ReplicaSelector: map[string]string{"name": "logger"},
+			PodTemplate: api.PodTemplate{
+				DesiredState: api.PodState{
+					Manifest: api.ContainerManifest{
+						Containers: []api.Container{{Image: "foo/logger"}},
+					},
+				},
+				Labels: map[string]string{"name": "logger"},
+			},
+		},
+	}
+}
+
+func newDaemonManager(minions ...api.Minion) (*DaemonManager, *FakePodControl) {
+	fakePodControl := &FakePodControl{}
+	manager := NewDaemonManager(&client.Fake{})
+	manager.podControl = fakePodControl
+	for i := range minions {
+		manager.minions.Add(minions[i].ID, &minions[i])
+	}
+	return manager, fakePodControl
+}
+
+func newDaemonPod(id, host string, status api.PodStatus) *api.Pod {
+	return &api.Pod{
+		JSONBase:     api.JSONBase{ID: id},
+		Labels:       map[string]string{"name": "logger", daemonControllerLabel: "logger"},
+		DesiredState: api.PodState{Host: host},
+		CurrentState: api.PodState{Status: status},
+	}
+}
+
+func validateSyncDaemon(t *testing.T, fakePodControl *FakePodControl, expectedHosts, expectedDeletes []string) {
+	sort.Strings(fakePodControl.daemonHosts)
+	sort.Strings(fakePodControl.deletePodID)
+	if !reflect.DeepEqual(fakePodControl.daemonHosts, expectedHosts) {
+		t.Errorf("Expected pods created on %v, got %v", expectedHosts, fakePodControl.daemonHosts)
+	}
+	if !reflect.DeepEqual(fakePodControl.deletePodID, expectedDeletes) {
+		t.Errorf("Expected %v deleted, got %v", expectedDeletes, fakePodControl.deletePodID)
+	}
+}
+
+func TestSyncDaemonControllerCreatesOnEveryMinion(t *testing.T) {
+	manager, fakePodControl := newDaemonManager(
+		api.Minion{JSONBase: api.JSONBase{ID: "a"}},
+		api.Minion{JSONBase: api.JSONBase{ID: "b"}},
+		api.Minion{JSONBase: api.JSONBase{ID: "c"}},
+	)
+	manager.pods.Add("pod-a", newDaemonPod("pod-a", "a", api.PodRunning))
+	// A terminated pod doesn't count.
+	manager.pods.Add("pod-b", newDaemonPod("pod-b", "b", api.PodTerminated))
+
+	manager.syncDaemonController(newDaemonController())
+	validateSyncDaemon(t, fakePodControl, []string{"b", "c"}, nil)
+}
+
+func TestSyncDaemonControllerSelectsMinions(t *testing.T) {
+	manager, fakePodControl := newDaemonManager(
+		api.Minion{JSONBase: api.JSONBase{ID: "a"}, Labels: map[string]string{"disk": "ssd"}},
+		api.Minion{JSONBase: api.JSONBase{ID: "b"}},
+		api.Minion{JSONBase: api.JSONBase{ID: "c"}, Labels: map[string]string{"disk": "ssd"}},
+	)
+	manager.pods.Add("pod-b", newDaemonPod("pod-b", "b", api.PodRunning))
+	daemon := newDaemonController()
+	daemon.DesiredState.PodTemplate.NodeSelector = map[string]string{"disk": "ssd"}
+
+	manager.syncDaemonController(daemon)
+	validateSyncDaemon(t, fakePodControl, []string{"a", "c"}, []string{"pod-b"})
+}
+
+func TestSyncDaemonControllerDeletes(t *testing.T) {
+	manager, fakePodControl := newDaemonManager(
+		api.Minion{JSONBase: api.JSONBase{ID: "a"}},
+	)
+	manager.pods.Add("pod-a1", newDaemonPod("pod-a1", "a", api.PodRunning))
+	manager.pods.Add("pod-a2", newDaemonPod("pod-a2", "a", api.PodWaiting))
+	// The minion is gone.
+	manager.pods.Add("pod-gone", newDaemonPod("pod-gone", "gone", api.PodRunning))
+	// Not yet bound, but created for the minion.
+	unbound := newDaemonPod("pod-a3", "", api.PodWaiting)
+	unbound.Annotations = map[string]string{DaemonHostAnnotation: "a"}
+	manager.pods.Add("pod-a3", unbound)
+
+	manager.syncDaemonController(newDaemonController())
+	validateSyncDaemon(t, fakePodControl, nil, []string{"pod-a2", "pod-a3", "pod-gone"})
+}
+
+func TestSyncDaemonControllerExpectations(t *testing.T) {
+	manager, fakePodControl := newDaemonManager(
+		api.Minion{JSONBase: api.JSONBase{ID: "a"}},
+		api.Minion{JSONBase: api.JSONBase{ID: "b"}},
+	)
+	daemon := newDaemonController()
+	manager.daemons.Add(daemon.ID, &daemon)
+	store := podNotifier{manager.pods.Indexer, manager.podChanged}
+
+	manager.syncDaemonController(daemon)
+	validateSyncDaemon(t, fakePodControl, []string{"a", "b"}, nil)
+
+	// Until both pods are seen, the minions seem to be missing them.
+	store.Add("pod-a", newDaemonPod("pod-a", "a", api.PodWaiting))
+	manager.syncDaemonController(daemon)
+	validateSyncDaemon(t, fakePodControl, []string{"a", "b"}, nil)
+	store.Add("pod-b", newDaemonPod("pod-b", "b", api.PodWaiting))
+	manager.syncDaemonController(daemon)
+	validateSyncDaemon(t, fakePodControl, []string{"a", "b"}, nil)
+}
+
+func TestSyncDaemonControllerFailedCreations(t *testing.T) {
+	manager, fakePodControl := newDaemonManager(
+		api.Minion{JSONBase: api.JSONBase{ID: "a"}},
+	)
+	fakePodControl.err = fmt.Errorf("failed")
+
+	// Failed creations are retried by the next sync, rather than waited for.
+	if err := manager.syncDaemonController(newDaemonController()); err == nil {
+		t.Errorf("Expected the failed creation to be reported")
+	}
+	manager.syncDaemonController(newDaemonController())
+	validateSyncDaemon(t, fakePodControl, []string{"a", "a"}, nil)
+}
+
+func TestFailedDaemonSyncRetries(t *testing.T) {
+	defer func(delay time.Duration) { minRetryDelay = delay }(minRetryDelay)
+	minRetryDelay = time.Millisecond
+
+	manager, fakePodControl := newDaemonManager(
+		api.Minion{JSONBase: api.JSONBase{ID: "a"}},
+	)
+	fakePodControl.err = fmt.Errorf("failed")
+	daemon := newDaemonController()
+	manager.daemons.Add(daemon.ID, &daemon)
+
+	// No pod shows up to queue the daemon, so it is retried.
+	manager.enqueue(daemon.ID)
+	manager.syncNext()
+	done := make(chan struct{})
+	go func() {
+		manager.syncNext()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the daemon to be synced again")
+	}
+	validateSyncDaemon(t, fakePodControl, []string{"a", "a"}, nil)
+}
+
+func TestSyncDaemonControllerFailedBinding(t *testing.T) {
+	manager, fakePodControl := newDaemonManager(
+		api.Minion{JSONBase: api.JSONBase{ID: "a"}},
+	)
+	daemon := newDaemonController()
+	manager.daemons.Add(daemon.ID, &daemon)
+	store := podNotifier{manager.pods.Indexer, manager.podChanged}
+	fakePodControl.err = &bindError{podID: "pod-a", err: fmt.Errorf("failed")}
+
+	// The pod was created and deleted again, which the watch sees both of.
+	manager.syncDaemonController(daemon)
+	if manager.expectations.satisfied(daemon.ID) {
+		t.Errorf("Expected the creation of the unbound pod to be waited for")
+	}
+	store.Add("pod-a", newDaemonPod("pod-a", "", api.PodWaiting))
+	store.Delete("pod-a")
+	if !manager.expectations.satisfied(daemon.ID) {
+		t.Errorf("Expected the creation to be observed")
+	}
+	manager.syncDaemonController(daemon)
+	validateSyncDaemon(t, fakePodControl, []string{"a", "a"}, nil)
+}
+
+func TestSyncDaemonControllerUndeletedUnboundPod(t *testing.T) {
+	manager, fakePodControl := newDaemonManager(
+		api.Minion{JSONBase: api.JSONBase{ID: "a"}},
+	)
+	daemon := newDaemonController()
+	manager.daemons.Add(daemon.ID, &daemon)
+	store := podNotifier{manager.pods.Indexer, manager.podChanged}
+	fakePodControl.err = &bindError{podID: "pod-a", err: fmt.Errorf("failed"), deleteErr: fmt.Errorf("failed")}
+
+	if err := manager.syncDaemonController(daemon); err == nil {
+		t.Errorf("Expected the failed binding to be reported")
+	}
+	pod := newDaemonPod("pod-a", "", api.PodWaiting)
+	pod.Annotations = map[string]string{DaemonHostAnnotation: "a"}
+	store.Add("pod-a", pod)
+
+	// The unbound pod doesn't count for its minion, and is deleted again.
+	fakePodControl.err = nil
+	if err := manager.syncDaemonController(daemon); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	validateSyncDaemon(t, fakePodControl, []string{"a", "a"}, []string{"pod-a"})
+	if len(manager.unbound) != 0 {
+		t.Errorf("Expected the deleted pod to be forgotten, got %v", manager.unbound)
+	}
+}
+
+func TestDeletedDaemonControllerDeletesPods(t *testing.T) {
+	manager, fakePodControl := newDaemonManager()
+	manager.pods.Add("pod-a", newDaemonPod("pod-a", "a", api.PodRunning))
+	other := newDaemonPod("other", "a", api.PodRunning)
+	other.Labels[daemonControllerLabel] = "other"
+	manager.pods.Add("other", other)
+
+	daemon := newDaemonController()
+	notifier := daemonNotifier{controllerNotifier{manager.daemons, manager.enqueue}}
+	notifier.Add(daemon.ID, &daemon)
+	notifier.Delete(daemon.ID)
+	if id := manager.queue.get(); id != daemon.ID {
+		t.Errorf("Expected %s to be queued, got %s", daemon.ID, id)
+	}
+
+	manager.deleteOrphans(daemon.ID)
+	validateSyncDaemon(t, fakePodControl, nil, []string{"pod-a"})
+}
+
+func TestMinionChangesQueueDaemons(t *testing.T) {
+	manager, _ := newDaemonManager()
+	daemon := newDaemonController()
+	manager.daemons.Add(daemon.ID, &daemon)
+	notifier := minionNotifier{manager.minions, manager.synchronize}
+	queued := func() bool {
+		if manager.queue.len() == 0 {
+			return false
+		}
+		manager.queue.done(manager.queue.get())
+		return true
+	}
+
+	notifier.Add("a", &api.Minion{JSONBase: api.JSONBase{ID: "a"}})
+	if !queued() {
+		t.Errorf("Expected a new minion to queue daemons")
+	}
+	notifier.Update("a", &api.Minion{JSONBase: api.JSONBase{ID: "a"}})
+	if queued() {
+		t.Errorf("Expected an unchanged minion not to queue daemons")
+	}
+	notifier.Update("a", &api.Minion{JSONBase: api.JSONBase{ID: "a"}, Labels: map[string]string{"disk": "ssd"}})
+	if !queued() {
+		t.Errorf("Expected relabeling a minion to queue daemons")
+	}
+	notifier.Delete("a")
+	if !queued() {
+		t.Errorf("Expected a deleted minion to queue daemons")
+	}
+}
+
+// fakeDaemonClient returns the pods it creates with an ID, and can fail
+// bindings.
+type fakeDaemonClient struct {
+	client.Fake
+	created []api.Pod
+	bindErr error
+}
+
+func (c *fakeDaemonClient) CreatePod(pod api.Pod) (api.Pod, error) {
+	c.Fake.CreatePod(pod)
+	c.created = append(c.created, pod)
+	pod.ID = "pod1"
+	return pod, nil
+}
+
+func (c *fakeDaemonClient) BindPod(podID, host string) error {
+	c.Fake.BindPod(podID, host)
+	return c.bindErr
+}
+
+func TestCreateDaemonPod(t *testing.T) {
+	fakeClient := &fakeDaemonClient{}
+	podControl := RealPodControl{kubeClient: fakeClient}
+	daemon := newDaemonController()
+	daemon.DesiredState.PodTemplate.NodeSelector = map[string]string{"disk": "ssd"}
+	daemon.DesiredState.PodTemplate.Annotations = map[string]string{"owner": "ops"}
+
+	if err := podControl.createDaemonPod(daemon, "a"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedPod := api.Pod{
+		DesiredState: daemon.DesiredState.PodTemplate.DesiredState,
+		Labels:       map[string]string{"name": "logger", "daemonController": "logger"},
+		NodeSelector: map[string]string{"disk": "ssd"},
+		Annotations:  map[string]string{"owner": "ops", "scheduler": "daemon", "daemonHost": "a"},
+	}
+	if len(fakeClient.created) != 1 || !reflect.DeepEqual(fakeClient.created[0], expectedPod) {
+		t.Errorf("Expected %#v created, got %#v", expectedPod, fakeClient.created)
+	}
+	// The template is left alone.
+	if len(daemon.DesiredState.PodTemplate.Labels) != 1 || len(daemon.DesiredState.PodTemplate.Annotations) != 1 {
+		t.Errorf("Unexpected change of the template: %#v", daemon.DesiredState.PodTemplate)
+	}
+	expectedActions := []client.FakeAction{
+		{Action: "create-pod"},
+		{Action: "bind-pod", Value: api.Binding{PodID: "pod1", Host: "a"}},
+	}
+	if !reflect.DeepEqual(fakeClient.Actions, expectedActions) {
+		t.Errorf("Expected %#v, got %#v", expectedActions, fakeClient.Actions)
+	}
+}
+
+func TestCreateDaemonPodFailedBinding(t *testing.T) {
+	fakeClient := &fakeDaemonClient{bindErr: fmt.Errorf("failed")}
+	podControl := RealPodControl{kubeClient: fakeClient}
+
+	if _, ok := podControl.createDaemonPod(newDaemonController(), "a").(*bindError); !ok {
+		t.Errorf("Expected a binding error")
+	}
+	expectedActions := []client.FakeAction{
+		{Action: "create-pod"},
+		{Action: "bind-pod", Value: api.Binding{PodID: "pod1", Host: "a"}},
+		{Action: "delete-pod", Value: "pod1"},
+	}
+	if !reflect.DeepEqual(fakeClient.Actions, expectedActions) {
+		t.Errorf("Expected %#v, got %#v", expectedActions, fakeClient.Actions)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// expectationsTTL is how long the manager waits to observe the pods it created
+// or deleted. After it, a controller is synced again, in case an event was
+// missed.
+const expectationsTTL = 5 * time.Minute
+
+// expectations counts, for every controller, the pod creations and deletions the
+// manager asked for but hasn't yet seen in its pod watch. Until they are all
+// seen, the pods in the cache don't tell how many replicas a controller has, so
+// it isn't synced.
+type expectations struct {
+	lock  sync.Mutex
+	items map[string]*expectation
+	// To allow injection of time.Now for testing.
+	now func() time.Time
+}
+
+type expectation struct {
+	adds, deletes int
+	timestamp     time.Time
+}
+
+func newExpectations() *expectations {
+	return &expectations{
+		items: map[string]*expectation{},
+		now:   time.Now,
+	}
+}
+
+// expect records that adds pods are being created and deletes deleted for the
+// controller with id, replacing what was expected before.
+func (e *expectations) expect(id string, adds, deletes int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.items[id] = &expectation{adds: adds, deletes: deletes, timestamp: e.now()}
+}
+
+// creationObserved records that one of the pods created for the controller with
+// id was seen, or won't be since its creation failed.
+func (e *expectations) creationObserved(id string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if item, ok := e.items[id]; ok && item.adds > 0 {
+		item.adds--
+	}
+}
+
+// deletionObserved records that one of the pods deleted for the controller with
+// id was seen gone, or won't be since its deletion failed.
+func (e *expectations) deletionObserved(id string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if item, ok := e.items[id]; ok && item.deletes > 0 {
+		item.deletes--
+	}
+}
+
+// satisfied returns true if the controller with id can be synced: every pod
+// creation and deletion expected for it was observed, or they expired.
+func (e *expectations) satisfied(id string) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	item, ok := e.items[id]
+	if !ok {
+		return true
+	}
+	return item.adds <= 0 && item.deletes <= 0 || e.now().Sub(item.timestamp) > expectationsTTL
+}
+
+// forget drops the expectations of the controller with id, e.g. once it is
+// deleted.
+func (e *expectations) forget(id string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.items, id)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpectations(t *testing.T) {
+	e := newExpectations()
+	now := time.Unix(1000, 0)
+	e.now = func() time.Time { return now }
+
+	if !e.satisfied("foo") {
+		t.Errorf("expected a controller without expectations to be satisfied")
+	}
+	e.expect("foo", 2, 1)
+	for _, observe := range []func(string){e.creationObserved, e.deletionObserved, e.creationObserved} {
+		if e.satisfied("foo") {
+			t.Errorf("expected pending expectations not to be satisfied")
+		}
+		observe("foo")
+	}
+	if !e.satisfied("foo") {
+		t.Errorf("expected observed expectations to be satisfied")
+	}
+	// More events than expected, e.g. pods created by someone else, don't
+	// count towards the next expectations.
+	e.creationObserved("foo")
+	e.expect("foo", 1, 0)
+	if e.satisfied("foo") {
+		t.Errorf("expected pending expectations not to be satisfied")
+	}
+
+	// Other controllers are unaffected.
+	e.creationObserved("bar")
+	if !e.satisfied("bar") {
+		t.Errorf("expected a controller without expectations to be satisfied")
+	}
+
+	now = now.Add(expectationsTTL + time.Second)
+	if !e.satisfied("foo") {
+		t.Errorf("expected expired expectations to be satisfied")
+	}
+
+	e.expect("foo", 1, 0)
+	e.forget("foo")
+	if !e.satisfied("foo") {
+		t.Errorf("expected forgotten expectations to be satisfied")
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
+)
+
+// podNotifier passes every pod which is added, updated or deleted to changed,
+// along with the pod it replaced.
+type podNotifier struct {
+	cache.Indexer
+	changed func(old, pod *api.Pod)
+}
+
+func (n podNotifier) Add(id string, obj interface{}) {
+	n.Update(id, obj)
+}
+
+func (n podNotifier) Update(id string, obj interface{}) {
+	var oldPod *api.Pod
+	if old, exists := n.Indexer.Get(id); exists {
+		oldPod = old.(*api.Pod)
+	}
+	n.Indexer.Update(id, obj)
+	n.changed(oldPod, obj.(*api.Pod))
+}
+
+func (n podNotifier) Delete(id string) {
+	old, exists := n.Indexer.Get(id)
+	n.Indexer.Delete(id)
+	if exists {
+		n.changed(old.(*api.Pod), nil)
+	}
+}
+
+// controlledPods is what a manager knows of the pods of its controllers, which
+// ReplicationManager and DaemonManager embed.
+type controlledPods struct {
+	// pods is kept up to date by a podNotifier calling podChanged.
+	pods cache.StoreToPodLister
+	// expectations holds the pod creations and deletions not yet seen in pods.
+	expectations *expectations
+	// owners returns the IDs of the controllers which select a pod.
+	owners func(pod *api.Pod) []string
+	// sync queues the controller with an ID to be synced.
+	sync func(id string)
+}
+
+func newControlledPods(owners func(pod *api.Pod) []string, sync func(id string)) controlledPods {
+	return controlledPods{
+		pods:         cache.StoreToPodLister{Indexer: cache.NewPodIndexer()},
+		expectations: newExpectations(),
+		owners:       owners,
+		sync:         sync,
+	}
+}
+
+// podChanged records a pod creation or deletion as observed, and queues the
+// controllers selecting the pod before and after the change. old is nil for new
+// pods, and pod is nil for deleted ones.
+func (c *controlledPods) podChanged(old, pod *api.Pod) {
+	if old != nil {
+		for _, id := range c.owners(old) {
+			if pod == nil {
+				c.expectations.deletionObserved(id)
+			}
+			c.sync(id)
+		}
+	}
+	if pod != nil {
+		for _, id := range c.owners(pod) {
+			if old == nil {
+				c.expectations.creationObserved(id)
+			}
+			c.sync(id)
+		}
+	}
+}
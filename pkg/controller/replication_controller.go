@@ -17,34 +17,63 @@ limitations under the License.
 package controller
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
 	"github.com/golang/glog"
 )
 
 // ReplicationManager is responsible for synchronizing ReplicationController objects stored
-// in the system with actual running pods.
+// in the system with actual running pods. It watches controllers and pods, and syncs a
+// controller as soon as it or one of its pods changes.
 type ReplicationManager struct {
 	kubeClient client.Interface
 	podControl PodControlInterface
-	syncTime   <-chan time.Time
+
+	// controllers and the pods are kept up to date by watches.
+	controllers cache.Store
+	controlledPods
+	// queue holds the IDs of the controllers waiting to be synced. A controller
+	// is synced once, however often it changes while it waits, and by one
+	// worker at a time.
+	queue *workQueue
+	// workers is the number of controllers synced concurrently.
+	workers int
+	// scaleDownOrder is the order in which surplus replicas are deleted.
+	scaleDownOrder ScaleDownOrder
 
 	// To allow injection of syncReplicationController for testing.
 	syncHandler func(controllerSpec api.ReplicationController) error
 }
 
+// controllerLabel is the label of pods naming the replication controller which
+// owns them. A controller only counts the pods it owns, so that controllers
+// whose selectors overlap don't fight over pods.
+const controllerLabel = "replicationController"
+
 // PodControlInterface is an interface that knows how to add or delete pods
 // created as an interface to allow testing.
 type PodControlInterface interface {
 	// createReplica creates new replicated pods according to the spec.
-	createReplica(controllerSpec api.ReplicationController)
+	createReplica(controllerSpec api.ReplicationController) error
+	// createDaemonPod creates a pod of daemon and binds it to host. If the pod
+	// was created but couldn't be bound, it is deleted again and a *bindError
+	// returned, which tells whether the deletion failed too.
+	createDaemonPod(daemon api.DaemonController, host string) error
 	// deletePod deletes the pod identified by podID.
 	deletePod(podID string) error
+	// setPodOwner labels pod as owned by the controller with ID owner, or by
+	// none if owner is empty. It fails if pod changed since it was read.
+	setPodOwner(pod api.Pod, owner string) error
 }
 
 // RealPodControl is the default implementation of PodControllerInterface.
@@ -52,26 +81,88 @@ type RealPodControl struct {
 	kubeClient client.Interface
 }
 
-func (r RealPodControl) createReplica(controllerSpec api.ReplicationController) {
+func (r RealPodControl) createReplica(controllerSpec api.ReplicationController) error {
 	labels := controllerSpec.DesiredState.PodTemplate.Labels
 	// TODO: don't fail to set this label just because the map isn't created.
 	if labels != nil {
-		labels["replicationController"] = controllerSpec.ID
+		labels[controllerLabel] = controllerSpec.ID
 	}
 	pod := api.Pod{
 		DesiredState: controllerSpec.DesiredState.PodTemplate.DesiredState,
 		Labels:       controllerSpec.DesiredState.PodTemplate.Labels,
+		NodeSelector: controllerSpec.DesiredState.PodTemplate.NodeSelector,
+		Annotations:  controllerSpec.DesiredState.PodTemplate.Annotations,
 	}
 	_, err := r.kubeClient.CreatePod(pod)
+	return err
+}
+
+// bindError is returned by createDaemonPod for a pod which was created but
+// couldn't be bound.
+type bindError struct {
+	podID string
+	err   error
+	// deleteErr is the error deleting the unbound pod again, if it failed.
+	deleteErr error
+}
+
+func (e *bindError) Error() string {
+	return fmt.Sprintf("failed to bind %s: %v", e.podID, e.err)
+}
+
+func (r RealPodControl) createDaemonPod(daemon api.DaemonController, host string) error {
+	template := daemon.DesiredState.PodTemplate
+	podLabels := map[string]string{}
+	for key, value := range template.Labels {
+		podLabels[key] = value
+	}
+	podLabels[daemonControllerLabel] = daemon.ID
+	annotations := map[string]string{}
+	for key, value := range template.Annotations {
+		annotations[key] = value
+	}
+	annotations[scheduler.SchedulerAnnotation] = DaemonSchedulerName
+	annotations[DaemonHostAnnotation] = host
+	pod, err := r.kubeClient.CreatePod(api.Pod{
+		DesiredState: template.DesiredState,
+		Labels:       podLabels,
+		NodeSelector: template.NodeSelector,
+		Annotations:  annotations,
+	})
 	if err != nil {
-		glog.Errorf("%#v\n", err)
+		return err
+	}
+	if err := r.kubeClient.BindPod(pod.ID, host); err != nil {
+		// An unbound pod would never run, and would keep another from being
+		// created on host.
+		deleteErr := r.kubeClient.DeletePod(pod.ID)
+		if deleteErr != nil {
+			glog.Errorf("Failed to delete unbound pod %s: %v", pod.ID, deleteErr)
+		}
+		return &bindError{pod.ID, err, deleteErr}
 	}
+	return nil
 }
 
 func (r RealPodControl) deletePod(podID string) error {
 	return r.kubeClient.DeletePod(podID)
 }
 
+func (r RealPodControl) setPodOwner(pod api.Pod, owner string) error {
+	podLabels := map[string]string{}
+	for key, value := range pod.Labels {
+		podLabels[key] = value
+	}
+	if owner == "" {
+		delete(podLabels, controllerLabel)
+	} else {
+		podLabels[controllerLabel] = owner
+	}
+	pod.Labels = podLabels
+	_, err := r.kubeClient.UpdatePod(pod)
+	return err
+}
+
 // NewReplicationManager creates a new ReplicationManager.
 func NewReplicationManager(kubeClient client.Interface) *ReplicationManager {
 	rm := &ReplicationManager{
@@ -79,58 +170,141 @@ func NewReplicationManager(kubeClient client.Interface) *ReplicationManager {
 		podControl: RealPodControl{
 			kubeClient: kubeClient,
 		},
+		controllers:    cache.NewStore(),
+		queue:          newWorkQueue(),
+		workers:        DefaultSyncWorkers,
+		scaleDownOrder: ScaleDownUnready,
 	}
+	rm.controlledPods = newControlledPods(rm.controllersOf, rm.enqueue)
 	rm.syncHandler = rm.syncReplicationController
 	return rm
 }
 
-// Run begins watching and syncing.
-func (rm *ReplicationManager) Run(period time.Duration) {
-	rm.syncTime = time.Tick(period)
-	resourceVersion := uint64(0)
-	go util.Forever(func() { rm.watchControllers(&resourceVersion) }, period)
+// DefaultSyncWorkers is the default number of controllers a ReplicationManager
+// syncs concurrently.
+const DefaultSyncWorkers = 5
+
+// SetSyncWorkers sets how many controllers are synced concurrently, so that one
+// slow to create or delete its pods doesn't hold up the others. Must be called
+// before Run.
+func (rm *ReplicationManager) SetSyncWorkers(workers int) {
+	rm.workers = workers
 }
 
-// resourceVersion is a pointer to the resource version to use/update.
-func (rm *ReplicationManager) watchControllers(resourceVersion *uint64) {
-	watching, err := rm.kubeClient.WatchReplicationControllers(
-		labels.Everything(),
-		labels.Everything(),
-		*resourceVersion,
+// SetScaleDownOrder sets the order in which controllers with too many replicas
+// delete their pods. Defaults to ScaleDownUnready.
+func (rm *ReplicationManager) SetScaleDownOrder(order ScaleDownOrder) {
+	rm.scaleDownOrder = order
+}
+
+// Run begins watching and syncing. Every period, controllers and pods are also
+// listed again and every controller is synced, in case a change was missed.
+func (rm *ReplicationManager) Run(period time.Duration) {
+	controllerReflector := cache.NewListWatchReflector(
+		rm.listControllers,
+		rm.watchControllers,
+		&api.ReplicationController{},
+		controllerNotifier{rm.controllers, rm.enqueue},
 	)
-	if err != nil {
-		glog.Errorf("Unexpected failure to watch: %v", err)
-		time.Sleep(5 * time.Second)
-		return
+	controllerReflector.SetResyncPeriod(period)
+	controllerReflector.Run()
+	podReflector := cache.NewListWatchReflector(
+		rm.listPods,
+		rm.watchPods,
+		&api.Pod{},
+		podNotifier{rm.pods.Indexer, rm.podChanged},
+	)
+	podReflector.SetResyncPeriod(period)
+	podReflector.Run()
+	go func() {
+		// Until every pod is known, controllers would be synced against too few
+		// replicas.
+		for !podReflector.HasListed() {
+			time.Sleep(100 * time.Millisecond)
+		}
+		for i := 0; i < rm.workers; i++ {
+			go util.Forever(rm.worker, time.Second)
+		}
+	}()
+}
+
+func (rm *ReplicationManager) listControllers() (interface{}, error) {
+	return rm.kubeClient.ListReplicationControllers(labels.Everything())
+}
+
+func (rm *ReplicationManager) watchControllers(resourceVersion uint64) (watch.Interface, error) {
+	return rm.kubeClient.WatchReplicationControllers(labels.Everything(), labels.Everything(), resourceVersion)
+}
+
+func (rm *ReplicationManager) listPods() (interface{}, error) {
+	return rm.kubeClient.ListPods(labels.Everything())
+}
+
+func (rm *ReplicationManager) watchPods(resourceVersion uint64) (watch.Interface, error) {
+	return rm.kubeClient.WatchPods(labels.Everything(), labels.Everything(), resourceVersion)
+}
+
+// enqueue queues the controller with id to be synced.
+func (rm *ReplicationManager) enqueue(id string) {
+	rm.queue.add(id)
+}
+
+// controllersOf returns the IDs of the controllers which select pod.
+func (rm *ReplicationManager) controllersOf(pod *api.Pod) []string {
+	var ids []string
+	for _, obj := range rm.controllers.List() {
+		controller := obj.(*api.ReplicationController)
+		s := labels.Set(controller.DesiredState.ReplicaSelector).AsSelector()
+		if s.Matches(labels.Set(pod.Labels)) {
+			ids = append(ids, controller.ID)
+		}
 	}
+	return ids
+}
 
+// worker syncs controllers from the queue, one at a time. Run starts several
+// workers, which never sync the same controller at once.
+func (rm *ReplicationManager) worker() {
 	for {
-		select {
-		case <-rm.syncTime:
-			rm.synchronize()
-		case event, open := <-watching.ResultChan():
-			if !open {
-				// watchChannel has been closed, or something else went
-				// wrong with our etcd watch call. Let the util.Forever()
-				// that called us call us again.
-				return
-			}
-			glog.Infof("Got watch: %#v", event)
-			rc, ok := event.Object.(*api.ReplicationController)
-			if !ok {
-				glog.Errorf("unexpected object: %#v", event.Object)
-				continue
-			}
-			// If we get disconnected, start where we left off.
-			*resourceVersion = rc.ResourceVersion + 1
-			// Sync even if this is a deletion event, to ensure that we leave
-			// it in the desired state.
-			glog.Infof("About to sync from watch: %v", rc.ID)
-			rm.syncHandler(*rc)
-		}
+		rm.syncNext()
 	}
 }
 
+// syncNext syncs the next controller in the queue.
+func (rm *ReplicationManager) syncNext() {
+	id := rm.queue.get()
+	defer rm.queue.done(id)
+	obj, exists := rm.controllers.Get(id)
+	if !exists {
+		// Deleted while it waited; its pods are left alone.
+		rm.expectations.forget(id)
+		rm.queue.forgetRetries(id)
+		return
+	}
+	if err := rm.syncHandler(*obj.(*api.ReplicationController)); err != nil {
+		glog.Errorf("Error synchronizing %s: %v", id, err)
+		rm.queue.retry(id)
+		return
+	}
+	rm.queue.forgetRetries(id)
+}
+
+// controllerNotifier queues every controller which is added or updated.
+type controllerNotifier struct {
+	cache.Store
+	enqueue func(id string)
+}
+
+func (n controllerNotifier) Add(id string, obj interface{}) {
+	n.Store.Add(id, obj)
+	n.enqueue(id)
+}
+
+func (n controllerNotifier) Update(id string, obj interface{}) {
+	n.Store.Update(id, obj)
+	n.enqueue(id)
+}
+
 func (rm *ReplicationManager) filterActivePods(pods []api.Pod) []api.Pod {
 	var result []api.Pod
 	for _, value := range pods {
@@ -141,62 +315,112 @@ func (rm *ReplicationManager) filterActivePods(pods []api.Pod) []api.Pod {
 	return result
 }
 
+// syncReplicationController creates or deletes pods until the controller has as
+// many as it wants. It returns an error if any creation or deletion failed, so
+// that the controller is synced again later: no pod event may queue it.
 func (rm *ReplicationManager) syncReplicationController(controllerSpec api.ReplicationController) error {
-	s := labels.Set(controllerSpec.DesiredState.ReplicaSelector).AsSelector()
-	podList, err := rm.kubeClient.ListPods(s)
-	if err != nil {
-		return err
+	if !rm.expectations.satisfied(controllerSpec.ID) {
+		// Failed creations and deletions aren't expected, so what is expected
+		// shows up in the pod watch, which queues the controller again.
+		glog.V(4).Infof("Waiting for the pods of %s to be created or deleted", controllerSpec.ID)
+		return nil
 	}
-	filteredList := rm.filterActivePods(podList.Items)
+	s := labels.Set(controllerSpec.DesiredState.ReplicaSelector).AsSelector()
+	rm.releasePods(controllerSpec.ID, s)
+	selected, _ := rm.pods.ListPods(s)
+	filteredList := rm.filterActivePods(rm.claimPods(controllerSpec.ID, selected))
 	diff := len(filteredList) - controllerSpec.DesiredState.Replicas
+	var failures int32
 	if diff < 0 {
 		diff *= -1
+		rm.expectations.expect(controllerSpec.ID, diff, 0)
 		wait := sync.WaitGroup{}
 		wait.Add(diff)
 		glog.Infof("Too few replicas, creating %d\n", diff)
 		for i := 0; i < diff; i++ {
 			go func() {
 				defer wait.Done()
-				rm.podControl.createReplica(controllerSpec)
+				if err := rm.podControl.createReplica(controllerSpec); err != nil {
+					glog.Errorf("Failed to create a replica of %s: %v", controllerSpec.ID, err)
+					rm.expectations.creationObserved(controllerSpec.ID)
+					atomic.AddInt32(&failures, 1)
+				}
 			}()
 		}
 		wait.Wait()
 	} else if diff > 0 {
 		glog.Infof("Too many replicas, deleting %d\n", diff)
+		sortForScaleDown(filteredList, rm.scaleDownOrder)
+		rm.expectations.expect(controllerSpec.ID, 0, diff)
 		wait := sync.WaitGroup{}
 		wait.Add(diff)
 		for i := 0; i < diff; i++ {
 			go func(ix int) {
 				defer wait.Done()
-				rm.podControl.deletePod(filteredList[ix].ID)
+				if err := rm.podControl.deletePod(filteredList[ix].ID); err != nil {
+					glog.Errorf("Failed to delete %s of %s: %v", filteredList[ix].ID, controllerSpec.ID, err)
+					rm.expectations.deletionObserved(controllerSpec.ID)
+					atomic.AddInt32(&failures, 1)
+				}
 			}(i)
 		}
 		wait.Wait()
 	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d pod creations or deletions failed", failures, diff)
+	}
 	return nil
 }
 
-func (rm *ReplicationManager) synchronize() {
-	// TODO: remove this method completely and rely on the watch.
-	// Add resource version tracking to watch to make this work.
-	var controllerSpecs []api.ReplicationController
-	list, err := rm.kubeClient.ListReplicationControllers(labels.Everything())
-	if err != nil {
-		glog.Errorf("Synchronization error: %v (%#v)", err, err)
-		return
-	}
-	controllerSpecs = list.Items
-	wg := sync.WaitGroup{}
-	wg.Add(len(controllerSpecs))
-	for ix := range controllerSpecs {
-		go func(ix int) {
-			defer wg.Done()
-			glog.Infof("periodic sync of %v", controllerSpecs[ix].ID)
-			err := rm.syncHandler(controllerSpecs[ix])
-			if err != nil {
-				glog.Errorf("Error synchronizing: %#v", err)
+// claimPods returns the pods, of those selected by the controller with id, which
+// it owns. Orphans, i.e. pods owned by no controller or by one which was
+// deleted, are adopted, unless another controller selects them too: rather than
+// taking turns adopting them, both leave them alone until the selectors or pod
+// labels are fixed. Daemon pods are never adopted.
+func (rm *ReplicationManager) claimPods(id string, pods []api.Pod) []api.Pod {
+	var owned []api.Pod
+	for _, pod := range pods {
+		owner := pod.Labels[controllerLabel]
+		if owner == id {
+			owned = append(owned, pod)
+			continue
+		}
+		if _, isDaemon := pod.Labels[daemonControllerLabel]; isDaemon {
+			continue
+		}
+		if owner != "" {
+			if _, exists := rm.controllers.Get(owner); exists {
+				continue
 			}
-		}(ix)
+		}
+		if selectors := rm.controllersOf(&pod); len(selectors) > 1 {
+			glog.Warningf("Not adopting %s, since it is selected by controllers %v", pod.ID, selectors)
+			continue
+		}
+		if err := rm.podControl.setPodOwner(pod, id); err != nil {
+			// A changed pod queues the controller again.
+			glog.Errorf("Failed to adopt %s into %s: %v", pod.ID, id, err)
+			continue
+		}
+		glog.Infof("Controller %s adopted %s", id, pod.ID)
+		owned = append(owned, pod)
+	}
+	return owned
+}
+
+// releasePods disowns the pods of the controller with id which selector no
+// longer matches, e.g. since their labels were changed to take them out of
+// service. Other controllers may then adopt them.
+func (rm *ReplicationManager) releasePods(id string, selector labels.Selector) {
+	for _, obj := range rm.pods.Index(cache.PodLabelIndex, controllerLabel+"="+id) {
+		pod := obj.(*api.Pod)
+		if selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if err := rm.podControl.setPodOwner(*pod, ""); err != nil {
+			glog.Errorf("Failed to release %s from %s: %v", pod.ID, id, err)
+			continue
+		}
+		glog.Infof("Controller %s released %s", id, pod.ID)
 	}
-	wg.Wait()
 }
@@ -18,10 +18,11 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -29,10 +30,8 @@ import (
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
-	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
-	"github.com/coreos/go-etcd/etcd"
 )
 
 // TODO: Move this to a common place, it's needed in multiple tests.
@@ -45,20 +44,41 @@ func makeURL(suffix string) string {
 type FakePodControl struct {
 	controllerSpec []api.ReplicationController
 	deletePodID    []string
-	lock           sync.Mutex
+	// The hosts createDaemonPod was called for.
+	daemonHosts []string
+	// The "pod=owner" changes setPodOwner was called for.
+	ownerChanges []string
+	// What createReplica, createDaemonPod, deletePod and setPodOwner return.
+	err  error
+	lock sync.Mutex
 }
 
-func (f *FakePodControl) createReplica(spec api.ReplicationController) {
+func (f *FakePodControl) createReplica(spec api.ReplicationController) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	f.controllerSpec = append(f.controllerSpec, spec)
+	return f.err
+}
+
+func (f *FakePodControl) createDaemonPod(daemon api.DaemonController, host string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.daemonHosts = append(f.daemonHosts, host)
+	return f.err
 }
 
 func (f *FakePodControl) deletePod(podID string) error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	f.deletePodID = append(f.deletePodID, podID)
-	return nil
+	return f.err
+}
+
+func (f *FakePodControl) setPodOwner(pod api.Pod, owner string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.ownerChanges = append(f.ownerChanges, pod.ID+"="+owner)
+	return f.err
 }
 
 func newReplicationController(replicas int) api.ReplicationController {
@@ -98,6 +118,12 @@ func newPodList(count int) api.PodList {
 	}
 }
 
+func addPods(manager *ReplicationManager, podList api.PodList) {
+	for i := range podList.Items {
+		manager.pods.Add(podList.Items[i].ID, &podList.Items[i])
+	}
+}
+
 func validateSyncReplication(t *testing.T, fakePodControl *FakePodControl, expectedCreates, expectedDeletes int) {
 	if len(fakePodControl.controllerSpec) != expectedCreates {
 		t.Errorf("Unexpected number of creates.  Expected %d, saw %d\n", expectedCreates, len(fakePodControl.controllerSpec))
@@ -108,18 +134,11 @@ func validateSyncReplication(t *testing.T, fakePodControl *FakePodControl, expec
 }
 
 func TestSyncReplicationControllerDoesNothing(t *testing.T) {
-	body, _ := api.Encode(newPodList(2))
-	fakeHandler := util.FakeHandler{
-		StatusCode:   200,
-		ResponseBody: string(body),
-	}
-	testServer := httptest.NewTLSServer(&fakeHandler)
-	client := client.New(testServer.URL, nil)
-
 	fakePodControl := FakePodControl{}
 
-	manager := NewReplicationManager(client)
+	manager := NewReplicationManager(&client.Fake{})
 	manager.podControl = &fakePodControl
+	addPods(manager, newPodList(2))
 
 	controllerSpec := newReplicationController(2)
 
@@ -128,18 +147,11 @@ func TestSyncReplicationControllerDoesNothing(t *testing.T) {
 }
 
 func TestSyncReplicationControllerDeletes(t *testing.T) {
-	body, _ := api.Encode(newPodList(2))
-	fakeHandler := util.FakeHandler{
-		StatusCode:   200,
-		ResponseBody: string(body),
-	}
-	testServer := httptest.NewTLSServer(&fakeHandler)
-	client := client.New(testServer.URL, nil)
-
 	fakePodControl := FakePodControl{}
 
-	manager := NewReplicationManager(client)
+	manager := NewReplicationManager(&client.Fake{})
 	manager.podControl = &fakePodControl
+	addPods(manager, newPodList(2))
 
 	controllerSpec := newReplicationController(1)
 
@@ -148,18 +160,11 @@ func TestSyncReplicationControllerDeletes(t *testing.T) {
 }
 
 func TestSyncReplicationControllerCreates(t *testing.T) {
-	body, _ := api.Encode(newPodList(0))
-	fakeHandler := util.FakeHandler{
-		StatusCode:   200,
-		ResponseBody: string(body),
-	}
-	testServer := httptest.NewTLSServer(&fakeHandler)
-	client := client.New(testServer.URL, nil)
-
 	fakePodControl := FakePodControl{}
 
-	manager := NewReplicationManager(client)
+	manager := NewReplicationManager(&client.Fake{})
 	manager.podControl = &fakePodControl
+	addPods(manager, newPodList(0))
 
 	controllerSpec := newReplicationController(2)
 
@@ -199,6 +204,12 @@ func TestCreateReplica(t *testing.T) {
 					"name": "foo",
 					"type": "production",
 				},
+				NodeSelector: map[string]string{
+					"disk": "ssd",
+				},
+				Annotations: map[string]string{
+					"scheduler": "batch",
+				},
 			},
 		},
 	}
@@ -212,6 +223,8 @@ func TestCreateReplica(t *testing.T) {
 		},
 		Labels:       controllerSpec.DesiredState.PodTemplate.Labels,
 		DesiredState: controllerSpec.DesiredState.PodTemplate.DesiredState,
+		NodeSelector: controllerSpec.DesiredState.PodTemplate.NodeSelector,
+		Annotations:  controllerSpec.DesiredState.PodTemplate.Annotations,
 	}
 	fakeHandler.ValidateRequest(t, makeURL("/pods"), "POST", nil)
 	actualPod := api.Pod{}
@@ -224,131 +237,371 @@ func TestCreateReplica(t *testing.T) {
 	}
 }
 
-func TestSyncronize(t *testing.T) {
-	controllerSpec1 := api.ReplicationController{
-		JSONBase: api.JSONBase{APIVersion: "v1beta1"},
-		DesiredState: api.ReplicationControllerState{
-			Replicas: 4,
-			PodTemplate: api.PodTemplate{
-				DesiredState: api.PodState{
-					Manifest: api.ContainerManifest{
-						Containers: []api.Container{
-							{
-								Image: "foo/bar",
-							},
-						},
-					},
-				},
-				Labels: map[string]string{
-					"name": "foo",
-					"type": "production",
-				},
-			},
-		},
+func TestSyncReplicationControllerSelectsPods(t *testing.T) {
+	fakePodControl := FakePodControl{}
+
+	manager := NewReplicationManager(&client.Fake{})
+	manager.podControl = &fakePodControl
+	podList := newPodList(3)
+	podList.Items[0].Labels = map[string]string{"name": "foo"}
+	podList.Items[1].Labels = map[string]string{"name": "bar"}
+	podList.Items[2].Labels = map[string]string{"name": "foo"}
+	podList.Items[2].CurrentState.Status = api.PodTerminated
+	addPods(manager, podList)
+
+	controllerSpec := newReplicationController(2)
+	controllerSpec.DesiredState.ReplicaSelector = map[string]string{"name": "foo"}
+
+	manager.syncReplicationController(controllerSpec)
+	validateSyncReplication(t, &fakePodControl, 1, 0)
+}
+
+func TestSyncReplicationControllerExpectations(t *testing.T) {
+	fakePodControl := FakePodControl{}
+	manager := NewReplicationManager(&client.Fake{})
+	manager.podControl = &fakePodControl
+	controllerSpec := newReplicationController(2)
+	controllerSpec.ID = "foo"
+	controllerSpec.DesiredState.ReplicaSelector = map[string]string{"name": "foo"}
+	manager.controllers.Add(controllerSpec.ID, &controllerSpec)
+	store := podNotifier{manager.pods.Indexer, manager.podChanged}
+
+	manager.syncReplicationController(controllerSpec)
+	validateSyncReplication(t, &fakePodControl, 2, 0)
+
+	// Until both pods are seen, the cache has too few of them.
+	store.Add("pod0", &api.Pod{JSONBase: api.JSONBase{ID: "pod0"}, Labels: map[string]string{"name": "foo"}})
+	manager.syncReplicationController(controllerSpec)
+	validateSyncReplication(t, &fakePodControl, 2, 0)
+	store.Add("pod1", &api.Pod{JSONBase: api.JSONBase{ID: "pod1"}, Labels: map[string]string{"name": "foo"}})
+	manager.syncReplicationController(controllerSpec)
+	validateSyncReplication(t, &fakePodControl, 2, 0)
+
+	// Until the deleted pod is seen gone, the cache has too many.
+	controllerSpec.DesiredState.Replicas = 1
+	manager.syncReplicationController(controllerSpec)
+	validateSyncReplication(t, &fakePodControl, 2, 1)
+	manager.syncReplicationController(controllerSpec)
+	validateSyncReplication(t, &fakePodControl, 2, 1)
+	store.Delete(fakePodControl.deletePodID[0])
+	controllerSpec.DesiredState.Replicas = 0
+	manager.syncReplicationController(controllerSpec)
+	validateSyncReplication(t, &fakePodControl, 2, 2)
+}
+
+func TestSyncReplicationControllerFailedCreations(t *testing.T) {
+	fakePodControl := FakePodControl{err: fmt.Errorf("failed")}
+	manager := NewReplicationManager(&client.Fake{})
+	manager.podControl = &fakePodControl
+	controllerSpec := newReplicationController(2)
+
+	// Failed creations are retried by the next sync, rather than waited for.
+	manager.syncReplicationController(controllerSpec)
+	manager.syncReplicationController(controllerSpec)
+	validateSyncReplication(t, &fakePodControl, 4, 0)
+}
+
+func newOwnedPod(id, owner string, podLabels map[string]string) *api.Pod {
+	pod := &api.Pod{JSONBase: api.JSONBase{ID: id}, Labels: map[string]string{}}
+	for key, value := range podLabels {
+		pod.Labels[key] = value
 	}
-	controllerSpec2 := api.ReplicationController{
-		JSONBase: api.JSONBase{APIVersion: "v1beta1"},
-		DesiredState: api.ReplicationControllerState{
-			Replicas: 3,
-			PodTemplate: api.PodTemplate{
-				DesiredState: api.PodState{
-					Manifest: api.ContainerManifest{
-						Containers: []api.Container{
-							{
-								Image: "bar/baz",
-							},
-						},
-					},
-				},
-				Labels: map[string]string{
-					"name": "bar",
-					"type": "production",
-				},
-			},
-		},
+	if owner != "" {
+		pod.Labels[controllerLabel] = owner
 	}
+	return pod
+}
 
-	fakeEtcd := tools.NewFakeEtcdClient(t)
-	fakeEtcd.Data["/registry/controllers"] = tools.EtcdResponseWithError{
-		R: &etcd.Response{
-			Node: &etcd.Node{
-				Nodes: []*etcd.Node{
-					{
-						Value: util.EncodeJSON(controllerSpec1),
-					},
-					{
-						Value: util.EncodeJSON(controllerSpec2),
-					},
-				},
-			},
-		},
+func TestSyncReplicationControllerAdoptsOrphans(t *testing.T) {
+	fakePodControl := FakePodControl{}
+	manager := NewReplicationManager(&client.Fake{})
+	manager.podControl = &fakePodControl
+	for _, id := range []string{"foo", "bar"} {
+		controller := newReplicationController(2)
+		controller.ID = id
+		controller.DesiredState.ReplicaSelector = map[string]string{"name": id}
+		manager.controllers.Add(id, &controller)
+	}
+	foo := map[string]string{"name": "foo"}
+	manager.pods.Add("owned", newOwnedPod("owned", "foo", foo))
+	manager.pods.Add("orphan", newOwnedPod("orphan", "", foo))
+	manager.pods.Add("deleted-owner", newOwnedPod("deleted-owner", "gone", foo))
+	// Relabeled into foo's selector, but still owned by bar.
+	manager.pods.Add("bars", newOwnedPod("bars", "bar", foo))
+	daemonPod := newOwnedPod("daemon", "", foo)
+	daemonPod.Labels[daemonControllerLabel] = "logger"
+	manager.pods.Add("daemon", daemonPod)
+
+	controller, _ := manager.controllers.Get("foo")
+	manager.syncReplicationController(*controller.(*api.ReplicationController))
+	sort.Strings(fakePodControl.ownerChanges)
+	if expected := []string{"deleted-owner=foo", "orphan=foo"}; !reflect.DeepEqual(fakePodControl.ownerChanges, expected) {
+		t.Errorf("Expected adoptions %v, got %v", expected, fakePodControl.ownerChanges)
 	}
+	validateSyncReplication(t, &fakePodControl, 0, 1)
+	if fakePodControl.deletePodID[0] == "bars" || fakePodControl.deletePodID[0] == "daemon" {
+		t.Errorf("Unexpected deletion of %s, which foo doesn't own", fakePodControl.deletePodID[0])
+	}
+}
 
-	fakePodHandler := util.FakeHandler{
-		StatusCode:   200,
-		ResponseBody: "{\"apiVersion\": \"v1beta1\", \"kind\": \"PodList\"}",
-		T:            t,
-	}
-	fakeControllerHandler := util.FakeHandler{
-		StatusCode: 200,
-		ResponseBody: api.EncodeOrDie(&api.ReplicationControllerList{
-			Items: []api.ReplicationController{
-				controllerSpec1,
-				controllerSpec2,
-			},
-		}),
-		T: t,
-	}
-	mux := http.NewServeMux()
-	mux.Handle("/api/v1beta1/pods/", &fakePodHandler)
-	mux.Handle("/api/v1beta1/replicationControllers/", &fakeControllerHandler)
-	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
-		w.WriteHeader(http.StatusNotFound)
-		t.Errorf("Unexpected request for %v", req.RequestURI)
-	})
-	testServer := httptest.NewServer(mux)
-	client := client.New(testServer.URL, nil)
-	manager := NewReplicationManager(client)
+func TestSyncReplicationControllerContestedOrphans(t *testing.T) {
+	fakePodControl := FakePodControl{}
+	manager := NewReplicationManager(&client.Fake{})
+	manager.podControl = &fakePodControl
+	for _, id := range []string{"foo", "bar"} {
+		controller := newReplicationController(1)
+		controller.ID = id
+		controller.DesiredState.ReplicaSelector = map[string]string{"name": "foo"}
+		manager.controllers.Add(id, &controller)
+	}
+	manager.pods.Add("orphan", newOwnedPod("orphan", "", map[string]string{"name": "foo"}))
+
+	// Neither controller adopts a pod both select.
+	for _, id := range []string{"foo", "bar"} {
+		controller, _ := manager.controllers.Get(id)
+		manager.syncReplicationController(*controller.(*api.ReplicationController))
+	}
+	if len(fakePodControl.ownerChanges) != 0 {
+		t.Errorf("Unexpected adoptions %v", fakePodControl.ownerChanges)
+	}
+	validateSyncReplication(t, &fakePodControl, 2, 0)
+}
+
+func TestSyncReplicationControllerReleasesPods(t *testing.T) {
 	fakePodControl := FakePodControl{}
+	manager := NewReplicationManager(&client.Fake{})
+	manager.podControl = &fakePodControl
+	controller := newReplicationController(1)
+	controller.ID = "foo"
+	controller.DesiredState.ReplicaSelector = map[string]string{"name": "foo"}
+	manager.controllers.Add(controller.ID, &controller)
+	// Taken out of service for debugging.
+	manager.pods.Add("debug", newOwnedPod("debug", "foo", map[string]string{"name": "debug"}))
+
+	manager.syncReplicationController(controller)
+	if expected := []string{"debug="}; !reflect.DeepEqual(fakePodControl.ownerChanges, expected) {
+		t.Errorf("Expected releases %v, got %v", expected, fakePodControl.ownerChanges)
+	}
+	validateSyncReplication(t, &fakePodControl, 1, 0)
+}
+
+// fakeUpdateClient records the pods it is asked to update.
+type fakeUpdateClient struct {
+	client.Fake
+	updated []api.Pod
+}
+
+func (c *fakeUpdateClient) UpdatePod(pod api.Pod) (api.Pod, error) {
+	c.updated = append(c.updated, pod)
+	return pod, nil
+}
+
+func TestSetPodOwner(t *testing.T) {
+	fakeClient := &fakeUpdateClient{}
+	podControl := RealPodControl{kubeClient: fakeClient}
+	pod := newOwnedPod("pod", "", map[string]string{"name": "foo"})
+	pod.ResourceVersion = 7
+
+	if err := podControl.setPodOwner(*pod, "foo"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := podControl.setPodOwner(*newOwnedPod("pod", "foo", pod.Labels), ""); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(fakeClient.updated) != 2 {
+		t.Fatalf("Expected 2 updates, got %#v", fakeClient.updated)
+	}
+	adopted := fakeClient.updated[0]
+	if expected := map[string]string{"name": "foo", controllerLabel: "foo"}; !reflect.DeepEqual(adopted.Labels, expected) || adopted.ResourceVersion != 7 {
+		t.Errorf("Unexpected adopted pod %#v", adopted)
+	}
+	if expected := map[string]string{"name": "foo"}; !reflect.DeepEqual(fakeClient.updated[1].Labels, expected) {
+		t.Errorf("Unexpected released pod %#v", fakeClient.updated[1])
+	}
+	// The pod read from the cache is left alone.
+	if _, ok := pod.Labels[controllerLabel]; ok {
+		t.Errorf("Unexpected change of %#v", pod)
+	}
+}
+
+func TestSyncronize(t *testing.T) {
+	manager := NewReplicationManager(&client.Fake{})
+	notifier := controllerNotifier{manager.controllers, manager.enqueue}
+	for _, id := range []string{"foo", "bar"} {
+		manager.controllers.Add(id, &api.ReplicationController{JSONBase: api.JSONBase{ID: id}})
+	}
+
+	// Every period, the controllers are listed again, which updates them all.
+	for _, id := range []string{"foo", "bar"} {
+		notifier.Update(id, &api.ReplicationController{JSONBase: api.JSONBase{ID: id}})
+	}
+
+	queued := util.StringSet{}
+	queued.Insert(manager.queue.get(), manager.queue.get())
+	if !queued.HasAll("foo", "bar") {
+		t.Errorf("expected both controllers to be queued, got %v", queued)
+	}
+}
+
+func TestPodChangesQueueControllers(t *testing.T) {
+	manager := NewReplicationManager(&client.Fake{})
+	for _, id := range []string{"foo", "bar"} {
+		controller := newReplicationController(1)
+		controller.ID = id
+		controller.DesiredState.ReplicaSelector = map[string]string{"name": id}
+		manager.controllers.Add(id, &controller)
+	}
+	store := podNotifier{manager.pods.Indexer, manager.podChanged}
+	expectQueued := func(expected ...string) {
+		queued := util.StringSet{}
+		for _ = range expected {
+			id := manager.queue.get()
+			manager.queue.done(id)
+			queued.Insert(id)
+		}
+		if !queued.HasAll(expected...) {
+			t.Errorf("expected %v to be queued, got %v", expected, queued)
+		}
+		if n := manager.queue.len(); n != 0 {
+			t.Errorf("unexpected controllers queued: %d", n)
+		}
+	}
+
+	store.Add("pod", &api.Pod{JSONBase: api.JSONBase{ID: "pod"}, Labels: map[string]string{"name": "foo"}})
+	expectQueued("foo")
+	// Relabeling a pod syncs both the controller it leaves and the one it joins.
+	store.Update("pod", &api.Pod{JSONBase: api.JSONBase{ID: "pod"}, Labels: map[string]string{"name": "bar"}})
+	expectQueued("foo", "bar")
+	store.Delete("pod")
+	expectQueued("bar")
+	store.Add("other", &api.Pod{JSONBase: api.JSONBase{ID: "other"}, Labels: map[string]string{"name": "baz"}})
+	expectQueued()
+}
+
+func TestSlowControllerDoesntBlockOthers(t *testing.T) {
+	manager := NewReplicationManager(&client.Fake{})
+	for _, id := range []string{"slow", "fast"} {
+		controller := newReplicationController(1)
+		controller.ID = id
+		manager.controllers.Add(id, &controller)
+	}
+	unblock := make(chan struct{})
+	synced := make(chan string, 2)
+	manager.syncHandler = func(controllerSpec api.ReplicationController) error {
+		if controllerSpec.ID == "slow" {
+			<-unblock
+		}
+		synced <- controllerSpec.ID
+		return nil
+	}
+	manager.enqueue("slow")
+	manager.enqueue("fast")
+	go manager.syncNext()
+	go manager.syncNext()
+
+	select {
+	case id := <-synced:
+		if id != "fast" {
+			t.Errorf("Expected fast to be synced first, got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected fast to be synced while slow is stuck")
+	}
+	close(unblock)
+	if id := <-synced; id != "slow" {
+		t.Errorf("Expected slow to be synced, got %s", id)
+	}
+}
+
+func TestFailedCreatesRetry(t *testing.T) {
+	defer func(delay time.Duration) { minRetryDelay = delay }(minRetryDelay)
+	minRetryDelay = time.Millisecond
+
+	fakePodControl := FakePodControl{err: errors.New("quota exceeded")}
+	manager := NewReplicationManager(&client.Fake{})
 	manager.podControl = &fakePodControl
+	controller := newReplicationController(2)
+	manager.controllers.Add(controller.ID, &controller)
 
-	manager.synchronize()
+	if err := manager.syncReplicationController(controller); err == nil {
+		t.Errorf("Expected the failed creations to be reported")
+	}
+	if !manager.expectations.satisfied(controller.ID) {
+		t.Errorf("Expected failed creations not to be waited for")
+	}
 
-	validateSyncReplication(t, &fakePodControl, 7, 0)
+	// No pod shows up to queue the controller, so it is retried.
+	manager.enqueue(controller.ID)
+	manager.syncNext()
+	done := make(chan struct{})
+	go func() {
+		manager.syncNext()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the controller to be synced again")
+	}
+	fakePodControl.lock.Lock()
+	defer fakePodControl.lock.Unlock()
+	if n := len(fakePodControl.controllerSpec); n != 6 {
+		t.Errorf("Expected 3 attempts to create 2 replicas, got %d creations", n)
+	}
 }
 
 type FakeWatcher struct {
-	w *watch.FakeWatcher
+	w        *watch.FakeWatcher
+	podWatch *watch.FakeWatcher
 	*client.Fake
 }
 
+func (fw FakeWatcher) ListReplicationControllers(selector labels.Selector) (api.ReplicationControllerList, error) {
+	return api.ReplicationControllerList{}, nil
+}
+
 func (fw FakeWatcher) WatchReplicationControllers(l, f labels.Selector, rv uint64) (watch.Interface, error) {
 	return fw.w, nil
 }
 
+func (fw FakeWatcher) ListPods(selector labels.Selector) (api.PodList, error) {
+	return api.PodList{}, nil
+}
+
+func (fw FakeWatcher) WatchPods(l, f labels.Selector, rv uint64) (watch.Interface, error) {
+	return fw.podWatch, nil
+}
+
 func TestWatchControllers(t *testing.T) {
-	client := FakeWatcher{watch.NewFake(), &client.Fake{}}
+	client := FakeWatcher{watch.NewFake(), watch.NewFake(), &client.Fake{}}
 	manager := NewReplicationManager(client)
-	var testControllerSpec api.ReplicationController
-	received := make(chan struct{})
+	testControllerSpec := newReplicationController(1)
+	testControllerSpec.DesiredState.ReplicaSelector = map[string]string{"name": "foo"}
+	received := make(chan struct{}, 2)
 	manager.syncHandler = func(controllerSpec api.ReplicationController) error {
 		if !reflect.DeepEqual(controllerSpec, testControllerSpec) {
 			t.Errorf("Expected %#v, but got %#v", testControllerSpec, controllerSpec)
 		}
-		close(received)
+		received <- struct{}{}
 		return nil
 	}
 
-	resourceVersion := uint64(0)
-	go manager.watchControllers(&resourceVersion)
+	manager.Run(time.Hour)
 
-	// Test normal case
+	// A new controller is synced.
 	testControllerSpec.ID = "foo"
 	client.w.Add(&testControllerSpec)
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Errorf("Expected a sync of the new controller")
+	}
 
+	// So is a controller one of whose pods changed.
+	client.podWatch.Add(&api.Pod{JSONBase: api.JSONBase{ID: "pod"}, Labels: map[string]string{"name": "foo"}})
 	select {
 	case <-received:
-	case <-time.After(10 * time.Millisecond):
-		t.Errorf("Expected 1 call but got 0")
+	case <-time.After(time.Second):
+		t.Errorf("Expected a sync after a pod of the controller changed")
 	}
 }
@@ -0,0 +1,271 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/wait"
+)
+
+// desiredReplicasAnnotation is the annotation of the new controller recording
+// how many replicas it has once the update is done, so that an update which is
+// resumed has the same target, whatever the two controllers have by then.
+const desiredReplicasAnnotation = "rollingUpdate.desiredReplicas"
+
+// ErrRollingUpdateAborted is returned by RollingUpdater.Update when its Stop
+// channel is closed. Both controllers are left as they are, and running the
+// update again resumes it.
+var ErrRollingUpdateAborted = errors.New("rolling update aborted")
+
+// RollingUpdaterConfig describes a rolling update from one replication controller
+// to another.
+type RollingUpdaterConfig struct {
+	// OldName is the controller whose pods are replaced. It is left with 0 replicas.
+	OldName string
+	// NewName is the controller which replaces them. It has to exist already, and
+	// the selectors of the two controllers must not select each other's pods.
+	NewName string
+	// Replicas the new controller has once the update is done. 0 means the replicas
+	// of both controllers together when the update starts, or, when it resumes,
+	// what it was when the update started.
+	Replicas int
+	// UpdatePeriod is the time to wait between steps, after the pods of the new
+	// controller became ready.
+	UpdatePeriod time.Duration
+	// Interval is how often to check if the pods of the new controller are ready.
+	// Defaults to a second.
+	Interval time.Duration
+	// Timeout is how long to wait for the pods of the new controller to become
+	// ready at every step; 0 waits forever.
+	Timeout time.Duration
+	// Stop aborts the update when it is closed.
+	Stop <-chan struct{}
+	// Out, if set, is where the progress of the update is written to.
+	Out io.Writer
+}
+
+// RollingUpdater moves replicas from one replication controller to another,
+// one at a time: it adds a replica to the new controller, waits for all of its
+// pods to be running and ready, and then removes a replica from the old one.
+//
+// The state of an update is the replicas of the two controllers, so an update
+// which failed or was aborted is resumed by running it again, and rolled back by
+// running it with the controllers swapped.
+type RollingUpdater struct {
+	kubeClient client.Interface
+	// To allow injection of time.Sleep for testing.
+	sleep func(time.Duration)
+}
+
+// NewRollingUpdater creates a RollingUpdater which works through kubeClient.
+func NewRollingUpdater(kubeClient client.Interface) *RollingUpdater {
+	return &RollingUpdater{
+		kubeClient: kubeClient,
+		sleep:      time.Sleep,
+	}
+}
+
+// Update runs the rolling update described by config, until the new controller
+// has all the replicas and the old one has none.
+func (r *RollingUpdater) Update(config *RollingUpdaterConfig) error {
+	out := config.Out
+	if out == nil {
+		out = ioutil.Discard
+	}
+	oldController, err := r.kubeClient.GetReplicationController(config.OldName)
+	if err != nil {
+		return err
+	}
+	newController, err := r.kubeClient.GetReplicationController(config.NewName)
+	if err != nil {
+		return err
+	}
+	if err := checkDisjoint(&oldController, &newController); err != nil {
+		return err
+	}
+	desired, err := r.recordDesiredReplicas(&newController, config.Replicas, oldController.DesiredState.Replicas)
+	if err != nil {
+		return err
+	}
+	oldReplicas := oldController.DesiredState.Replicas
+	newReplicas := newController.DesiredState.Replicas
+	fmt.Fprintf(out, "Updating %s replicas: %d, %s replicas: %d, to %s replicas: %d\n",
+		config.OldName, oldReplicas, config.NewName, newReplicas, config.NewName, desired)
+
+	first := true
+	for oldReplicas > 0 || newReplicas != desired {
+		if !first && config.UpdatePeriod > 0 {
+			r.sleep(config.UpdatePeriod)
+		}
+		first = false
+		if aborted(config.Stop) {
+			return ErrRollingUpdateAborted
+		}
+
+		// Scale up before scaling down, so that the update never has fewer
+		// replicas than it needs.
+		if newReplicas < desired {
+			newReplicas++
+		} else if newReplicas > desired {
+			newReplicas = desired
+		}
+		if newReplicas != newController.DesiredState.Replicas {
+			if newController, err = r.kubeClient.ResizeReplicationController(config.NewName, newReplicas); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Resized %s to %d\n", config.NewName, newReplicas)
+		}
+		if err := r.waitForReady(&newController, config); err != nil {
+			return err
+		}
+
+		if oldReplicas > desired-newReplicas {
+			oldReplicas = desired - newReplicas
+			if oldReplicas < 0 {
+				oldReplicas = 0
+			}
+			if oldController, err = r.kubeClient.ResizeReplicationController(config.OldName, oldReplicas); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Resized %s to %d\n", config.OldName, oldReplicas)
+		}
+	}
+	// A later update from or to the new controller starts over.
+	delete(newController.Annotations, desiredReplicasAnnotation)
+	if _, err := r.kubeClient.UpdateReplicationController(newController); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Update succeeded\n")
+	return nil
+}
+
+// recordDesiredReplicas returns how many replicas newController has once the
+// update is done, and records it in an annotation of newController before the
+// update changes any replicas. replicas is what the update was asked for, and
+// oldReplicas what the old controller has.
+func (r *RollingUpdater) recordDesiredReplicas(newController *api.ReplicationController, replicas, oldReplicas int) (int, error) {
+	desired := replicas
+	if recorded, ok := newController.Annotations[desiredReplicasAnnotation]; ok && desired == 0 {
+		n, err := strconv.Atoi(recorded)
+		if err != nil {
+			return 0, fmt.Errorf("invalid annotation %s=%q of %s: %v", desiredReplicasAnnotation, recorded, newController.ID, err)
+		}
+		return n, nil
+	}
+	if desired == 0 {
+		desired = oldReplicas + newController.DesiredState.Replicas
+	}
+	if newController.Annotations[desiredReplicasAnnotation] == strconv.Itoa(desired) {
+		return desired, nil
+	}
+	if newController.Annotations == nil {
+		newController.Annotations = map[string]string{}
+	}
+	newController.Annotations[desiredReplicasAnnotation] = strconv.Itoa(desired)
+	updated, err := r.kubeClient.UpdateReplicationController(*newController)
+	if err != nil {
+		return 0, err
+	}
+	*newController = updated
+	return desired, nil
+}
+
+// waitForReady waits until controller has as many running and ready pods as
+// it has replicas.
+func (r *RollingUpdater) waitForReady(controller *api.ReplicationController, config *RollingUpdaterConfig) error {
+	s := labels.Set(controller.DesiredState.ReplicaSelector).AsSelector()
+	condition := func() (bool, error) {
+		if aborted(config.Stop) {
+			return false, ErrRollingUpdateAborted
+		}
+		podList, err := r.kubeClient.ListPods(s)
+		if err != nil {
+			return false, err
+		}
+		ready := 0
+		for i := range podList.Items {
+			if podRunningAndReady(&podList.Items[i]) {
+				ready++
+			}
+		}
+		return ready >= controller.DesiredState.Replicas, nil
+	}
+	// Skip the first interval when the pods are ready already.
+	if done, err := condition(); done || err != nil {
+		return err
+	}
+	interval := config.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	err := wait.Poll(interval, config.Timeout, condition)
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("timed out waiting for the pods of %s to be ready", controller.ID)
+	}
+	return err
+}
+
+// checkDisjoint returns an error if either controller selects the pods of the
+// other, since it would then delete them as surplus replicas.
+func checkDisjoint(oldController, newController *api.ReplicationController) error {
+	oldSelector := labels.Set(oldController.DesiredState.ReplicaSelector).AsSelector()
+	newSelector := labels.Set(newController.DesiredState.ReplicaSelector).AsSelector()
+	if oldSelector.Matches(labels.Set(newController.DesiredState.PodTemplate.Labels)) ||
+		newSelector.Matches(labels.Set(oldController.DesiredState.PodTemplate.Labels)) {
+		return fmt.Errorf("the selectors of %s (%v) and %s (%v) select each other's pods",
+			oldController.ID, oldSelector, newController.ID, newSelector)
+	}
+	return nil
+}
+
+// podRunningAndReady returns true if pod is running and either none of its
+// containers has a readiness probe, or its kubelet reported it ready.
+func podRunningAndReady(pod *api.Pod) bool {
+	if pod.CurrentState.Status != api.PodRunning {
+		return false
+	}
+	for _, container := range pod.DesiredState.Manifest.Containers {
+		if container.ReadinessProbe == nil {
+			continue
+		}
+		for _, condition := range pod.CurrentState.Conditions {
+			if condition == api.PodReady {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func aborted(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
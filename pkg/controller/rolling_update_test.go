@@ -0,0 +1,261 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+)
+
+// fakeRollingClient keeps replication controllers, and lists a pod for every
+// replica of those whose selector matches.
+type fakeRollingClient struct {
+	client.Fake
+	controllers map[string]*api.ReplicationController
+	// Whether the pods of a controller are running and ready.
+	unready map[string]bool
+	resizes []string
+}
+
+func newFakeRollingClient(oldReplicas, newReplicas int) *fakeRollingClient {
+	makeController := func(name string, replicas int) *api.ReplicationController {
+		return &api.ReplicationController{
+			JSONBase: api.JSONBase{ID: name},
+			DesiredState: api.ReplicationControllerState{
+				Replicas:        replicas,
+				ReplicaSelector: map[string]string{"name": "foo", "version": name},
+				PodTemplate: api.PodTemplate{
+					Labels: map[string]string{"name": "foo", "version": name},
+				},
+			},
+		}
+	}
+	return &fakeRollingClient{
+		controllers: map[string]*api.ReplicationController{
+			"old": makeController("old", oldReplicas),
+			"new": makeController("new", newReplicas),
+		},
+		unready: map[string]bool{},
+	}
+}
+
+func (c *fakeRollingClient) GetReplicationController(name string) (api.ReplicationController, error) {
+	controller, ok := c.controllers[name]
+	if !ok {
+		return api.ReplicationController{}, fmt.Errorf("no controller %s", name)
+	}
+	return *controller, nil
+}
+
+func (c *fakeRollingClient) ResizeReplicationController(name string, replicas int) (api.ReplicationController, error) {
+	c.resizes = append(c.resizes, fmt.Sprintf("%s=%d", name, replicas))
+	c.controllers[name].DesiredState.Replicas = replicas
+	return *c.controllers[name], nil
+}
+
+func (c *fakeRollingClient) UpdateReplicationController(controller api.ReplicationController) (api.ReplicationController, error) {
+	c.controllers[controller.ID] = &controller
+	return controller, nil
+}
+
+func (c *fakeRollingClient) ListPods(selector labels.Selector) (api.PodList, error) {
+	list := api.PodList{}
+	for name, controller := range c.controllers {
+		podLabels := labels.Set(controller.DesiredState.PodTemplate.Labels)
+		if !selector.Matches(podLabels) {
+			continue
+		}
+		status := api.PodRunning
+		if c.unready[name] {
+			status = api.PodWaiting
+		}
+		for i := 0; i < controller.DesiredState.Replicas; i++ {
+			list.Items = append(list.Items, api.Pod{
+				JSONBase:     api.JSONBase{ID: fmt.Sprintf("%s-%d", name, i)},
+				Labels:       podLabels,
+				CurrentState: api.PodState{Status: status},
+			})
+		}
+	}
+	return list, nil
+}
+
+func newTestRollingUpdater(kubeClient client.Interface, sleeps *[]time.Duration) *RollingUpdater {
+	updater := NewRollingUpdater(kubeClient)
+	updater.sleep = func(d time.Duration) {
+		*sleeps = append(*sleeps, d)
+	}
+	return updater
+}
+
+func TestRollingUpdate(t *testing.T) {
+	table := []struct {
+		oldReplicas, newReplicas, replicas int
+		resizes                            []string
+		// The updater only sleeps between steps.
+		sleeps int
+	}{
+		{3, 0, 0, []string{"new=1", "old=2", "new=2", "old=1", "new=3", "old=0"}, 2},
+		// An update which stopped half way is resumed.
+		{1, 2, 0, []string{"new=3", "old=0"}, 0},
+		{3, 0, 2, []string{"new=1", "old=1", "new=2", "old=0"}, 1},
+		// The old controller keeps its replicas until the new one has enough.
+		{1, 0, 3, []string{"new=1", "new=2", "new=3", "old=0"}, 2},
+		{0, 2, 0, nil, 0},
+	}
+	for _, item := range table {
+		fakeClient := newFakeRollingClient(item.oldReplicas, item.newReplicas)
+		var sleeps []time.Duration
+		updater := newTestRollingUpdater(fakeClient, &sleeps)
+		err := updater.Update(&RollingUpdaterConfig{
+			OldName:      "old",
+			NewName:      "new",
+			Replicas:     item.replicas,
+			UpdatePeriod: time.Minute,
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(fakeClient.resizes, item.resizes) {
+			t.Errorf("expected resizes %v, got %v", item.resizes, fakeClient.resizes)
+		}
+		if len(sleeps) != item.sleeps {
+			t.Errorf("expected %d sleeps, got %v", item.sleeps, sleeps)
+		}
+	}
+}
+
+func TestRollingUpdateAborts(t *testing.T) {
+	fakeClient := newFakeRollingClient(3, 0)
+	stop := make(chan struct{})
+	var sleeps []time.Duration
+	updater := newTestRollingUpdater(fakeClient, &sleeps)
+	updater.sleep = func(time.Duration) {
+		close(stop)
+	}
+	err := updater.Update(&RollingUpdaterConfig{
+		OldName:      "old",
+		NewName:      "new",
+		UpdatePeriod: time.Minute,
+		Stop:         stop,
+	})
+	if err != ErrRollingUpdateAborted {
+		t.Errorf("expected %v, got %v", ErrRollingUpdateAborted, err)
+	}
+	expected := []string{"new=1", "old=2"}
+	if !reflect.DeepEqual(fakeClient.resizes, expected) {
+		t.Errorf("expected resizes %v, got %v", expected, fakeClient.resizes)
+	}
+}
+
+func TestRollingUpdateWaitsForReadyPods(t *testing.T) {
+	fakeClient := newFakeRollingClient(3, 0)
+	fakeClient.unready["new"] = true
+	var sleeps []time.Duration
+	updater := newTestRollingUpdater(fakeClient, &sleeps)
+	err := updater.Update(&RollingUpdaterConfig{
+		OldName:  "old",
+		NewName:  "new",
+		Interval: time.Millisecond,
+		Timeout:  10 * time.Millisecond,
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	// The old controller keeps its replicas while the new pods aren't ready.
+	expected := []string{"new=1"}
+	if !reflect.DeepEqual(fakeClient.resizes, expected) {
+		t.Errorf("expected resizes %v, got %v", expected, fakeClient.resizes)
+	}
+}
+
+func TestRollingUpdateResumesWithRecordedReplicas(t *testing.T) {
+	fakeClient := newFakeRollingClient(3, 0)
+	fakeClient.unready["new"] = true
+	var sleeps []time.Duration
+	updater := newTestRollingUpdater(fakeClient, &sleeps)
+	config := &RollingUpdaterConfig{
+		OldName:  "old",
+		NewName:  "new",
+		Interval: time.Millisecond,
+		Timeout:  10 * time.Millisecond,
+	}
+	if err := updater.Update(config); err == nil {
+		t.Fatalf("expected a timeout")
+	}
+	if n := fakeClient.controllers["new"].Annotations[desiredReplicasAnnotation]; n != "3" {
+		t.Errorf("expected the target to be recorded, got %q", n)
+	}
+
+	// The controllers now have 4 replicas together, but the target stays 3.
+	fakeClient.unready["new"] = false
+	fakeClient.resizes = nil
+	if err := updater.Update(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"new=2", "old=1", "new=3", "old=0"}
+	if !reflect.DeepEqual(fakeClient.resizes, expected) {
+		t.Errorf("expected resizes %v, got %v", expected, fakeClient.resizes)
+	}
+	if _, ok := fakeClient.controllers["new"].Annotations[desiredReplicasAnnotation]; ok {
+		t.Errorf("expected the recorded target to be removed once the update is done")
+	}
+}
+
+func TestRollingUpdateOverlappingSelectors(t *testing.T) {
+	fakeClient := newFakeRollingClient(3, 0)
+	fakeClient.controllers["old"].DesiredState.ReplicaSelector = map[string]string{"name": "foo"}
+	var sleeps []time.Duration
+	updater := newTestRollingUpdater(fakeClient, &sleeps)
+	if err := updater.Update(&RollingUpdaterConfig{OldName: "old", NewName: "new"}); err == nil {
+		t.Errorf("expected an error")
+	}
+	if len(fakeClient.resizes) != 0 {
+		t.Errorf("unexpected resizes %v", fakeClient.resizes)
+	}
+}
+
+func TestPodRunningAndReady(t *testing.T) {
+	probed := api.ContainerManifest{Containers: []api.Container{{ReadinessProbe: &api.LivenessProbe{}}}}
+	table := []struct {
+		pod   api.Pod
+		ready bool
+	}{
+		{api.Pod{CurrentState: api.PodState{Status: api.PodWaiting}}, false},
+		{api.Pod{CurrentState: api.PodState{Status: api.PodRunning}}, true},
+		{api.Pod{
+			DesiredState: api.PodState{Manifest: probed},
+			CurrentState: api.PodState{Status: api.PodRunning},
+		}, false},
+		{api.Pod{
+			DesiredState: api.PodState{Manifest: probed},
+			CurrentState: api.PodState{Status: api.PodRunning, Conditions: []api.PodCondition{api.PodReady}},
+		}, true},
+	}
+	for i, item := range table {
+		if ready := podRunningAndReady(&item.pod); ready != item.ready {
+			t.Errorf("%d: expected %v, got %v", i, item.ready, ready)
+		}
+	}
+}
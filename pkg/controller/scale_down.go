@@ -0,0 +1,97 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+// ScaleDownOrder is the order in which a controller with too many replicas
+// deletes its pods.
+type ScaleDownOrder string
+
+const (
+	// ScaleDownUnready deletes unscheduled pods first, then pending ones, then
+	// the running ones which aren't ready, and the youngest first among equals.
+	// Pods whose status isn't known yet are deleted last, with the ready ones.
+	ScaleDownUnready ScaleDownOrder = "unready"
+	// ScaleDownYoungest deletes the youngest pods first, whatever their state.
+	ScaleDownYoungest ScaleDownOrder = "youngest"
+	// ScaleDownOldest deletes the oldest pods first, whatever their state.
+	ScaleDownOldest ScaleDownOrder = "oldest"
+)
+
+// ParseScaleDownOrder returns the ScaleDownOrder named s.
+func ParseScaleDownOrder(s string) (ScaleDownOrder, error) {
+	switch order := ScaleDownOrder(s); order {
+	case ScaleDownUnready, ScaleDownYoungest, ScaleDownOldest:
+		return order, nil
+	}
+	return "", fmt.Errorf("unknown scale down order %q, expected %q, %q or %q", s, ScaleDownUnready, ScaleDownYoungest, ScaleDownOldest)
+}
+
+// sortForScaleDown sorts pods so that the ones to delete first come first.
+func sortForScaleDown(pods []api.Pod, order ScaleDownOrder) {
+	sort.Sort(scaleDownPods{pods, order})
+}
+
+type scaleDownPods struct {
+	pods  []api.Pod
+	order ScaleDownOrder
+}
+
+func (s scaleDownPods) Len() int      { return len(s.pods) }
+func (s scaleDownPods) Swap(i, j int) { s.pods[i], s.pods[j] = s.pods[j], s.pods[i] }
+
+func (s scaleDownPods) Less(i, j int) bool {
+	a, b := &s.pods[i], &s.pods[j]
+	if s.order == ScaleDownUnready {
+		if ra, rb := podProgress(a), podProgress(b); ra != rb {
+			return ra < rb
+		}
+	}
+	ta, tb := a.CreationTimestamp.Time, b.CreationTimestamp.Time
+	if !ta.Equal(tb) {
+		if s.order == ScaleDownOldest {
+			return ta.Before(tb)
+		}
+		return tb.Before(ta)
+	}
+	// Keep the order stable across syncs.
+	return a.ID < b.ID
+}
+
+// podProgress ranks how far pod got towards serving: 0 if it isn't scheduled, 1 if
+// it isn't running, 2 if it isn't ready and 3 if it is. Pods from a watch carry
+// no status until their host reported on them, and may well be serving, so
+// they rank 3 too.
+func podProgress(pod *api.Pod) int {
+	switch {
+	case pod.DesiredState.Host == "":
+		return 0
+	case pod.CurrentState.Status == "":
+		return 3
+	case pod.CurrentState.Status != api.PodRunning:
+		return 1
+	case !podRunningAndReady(pod):
+		return 2
+	}
+	return 3
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+func makeScaleDownPod(id string, created int64, host string, status api.PodStatus, ready bool) api.Pod {
+	pod := api.Pod{
+		JSONBase:     api.JSONBase{ID: id, CreationTimestamp: util.Unix(created, 0)},
+		DesiredState: api.PodState{Host: host},
+		CurrentState: api.PodState{Status: status},
+	}
+	pod.DesiredState.Manifest.Containers = []api.Container{{ReadinessProbe: &api.LivenessProbe{}}}
+	if ready {
+		pod.CurrentState.Conditions = []api.PodCondition{api.PodReady}
+	}
+	return pod
+}
+
+func TestSortForScaleDown(t *testing.T) {
+	pods := []api.Pod{
+		makeScaleDownPod("ready-old", 1, "m", api.PodRunning, true),
+		makeScaleDownPod("ready-young", 5, "m", api.PodRunning, true),
+		makeScaleDownPod("unready", 2, "m", api.PodRunning, false),
+		makeScaleDownPod("pending", 3, "m", api.PodWaiting, false),
+		makeScaleDownPod("unscheduled", 4, "", api.PodWaiting, false),
+		makeScaleDownPod("ready-young-2", 5, "m", api.PodRunning, true),
+		// Watched before its host reported on it.
+		makeScaleDownPod("unknown", 3, "m", "", false),
+	}
+	table := map[ScaleDownOrder][]string{
+		ScaleDownUnready:  {"unscheduled", "pending", "unready", "ready-young", "ready-young-2", "unknown", "ready-old"},
+		ScaleDownYoungest: {"ready-young", "ready-young-2", "unscheduled", "pending", "unknown", "unready", "ready-old"},
+		ScaleDownOldest:   {"ready-old", "unready", "pending", "unknown", "unscheduled", "ready-young", "ready-young-2"},
+	}
+	for order, expected := range table {
+		sorted := append([]api.Pod{}, pods...)
+		sortForScaleDown(sorted, order)
+		var ids []string
+		for _, pod := range sorted {
+			ids = append(ids, pod.ID)
+		}
+		if !reflect.DeepEqual(ids, expected) {
+			t.Errorf("%s: expected %v, got %v", order, expected, ids)
+		}
+	}
+}
+
+func TestParseScaleDownOrder(t *testing.T) {
+	for _, s := range []string{"unready", "youngest", "oldest"} {
+		if order, err := ParseScaleDownOrder(s); err != nil || string(order) != s {
+			t.Errorf("%s: unexpected order %q, error %v", s, order, err)
+		}
+	}
+	if _, err := ParseScaleDownOrder("random"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestSyncReplicationControllerDeletesUnreadyPods(t *testing.T) {
+	fakePodControl := FakePodControl{}
+	manager := NewReplicationManager(&client.Fake{})
+	manager.podControl = &fakePodControl
+	addPods(manager, api.PodList{Items: []api.Pod{
+		makeScaleDownPod("ready", 1, "m", api.PodRunning, true),
+		makeScaleDownPod("unready", 1, "m", api.PodRunning, false),
+		makeScaleDownPod("pending", 1, "m", api.PodWaiting, false),
+	}})
+
+	manager.syncReplicationController(newReplicationController(1))
+	validateSyncReplication(t, &fakePodControl, 0, 2)
+	deleted := util.NewStringSet(fakePodControl.deletePodID...)
+	if !deleted.HasAll("unready", "pending") {
+		t.Errorf("expected the pods which aren't ready to be deleted, got %v", fakePodControl.deletePodID)
+	}
+}
+
+func TestSyncReplicationControllerKeepsWatchedPods(t *testing.T) {
+	fakePodControl := FakePodControl{}
+	manager := NewReplicationManager(&client.Fake{})
+	manager.podControl = &fakePodControl
+	store := podNotifier{manager.pods.Indexer, manager.podChanged}
+	// A pending pod from a list, which has been running for a while since,
+	// and a pod from the watch, which carries no status.
+	stale := makeScaleDownPod("stale", 1, "m", api.PodWaiting, false)
+	store.Add("stale", &stale)
+	watched := makeScaleDownPod("watched", 2, "m", "", false)
+	store.Add("watched", &watched)
+
+	manager.syncReplicationController(newReplicationController(1))
+	validateSyncReplication(t, &fakePodControl, 0, 1)
+	if !reflect.DeepEqual(fakePodControl.deletePodID, []string{"stale"}) {
+		t.Errorf("expected the pod known to be pending to be deleted, got %v", fakePodControl.deletePodID)
+	}
+}
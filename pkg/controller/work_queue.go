@@ -0,0 +1,132 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// The delay before an ID whose sync failed is added again doubles with each
+// failure in a row, from minRetryDelay up to maxRetryDelay.
+var (
+	minRetryDelay = time.Second
+	maxRetryDelay = 5 * time.Minute
+)
+
+// workQueue hands the IDs of controllers to be synced to concurrent workers.
+// Each ID is queued at most once, however often it is added while it waits, and
+// is handed to one worker at a time: an ID added while it is being synced is
+// only handed out again once that sync is done.
+type workQueue struct {
+	lock sync.Mutex
+	cond *sync.Cond
+	// ids holds the IDs ready to be handed out, in the order they were added.
+	ids []string
+	// queued holds the IDs waiting, whether in ids or for their sync to finish.
+	queued util.StringSet
+	// processing holds the IDs handed out but not yet done.
+	processing util.StringSet
+	// retryDelays holds the delay before the IDs whose last sync failed are
+	// added again.
+	retryDelays map[string]time.Duration
+}
+
+func newWorkQueue() *workQueue {
+	q := &workQueue{
+		queued:      util.StringSet{},
+		processing:  util.StringSet{},
+		retryDelays: map[string]time.Duration{},
+	}
+	q.cond = sync.NewCond(&q.lock)
+	return q
+}
+
+// add queues id, unless it is queued already.
+func (q *workQueue) add(id string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.queued.Has(id) {
+		return
+	}
+	q.queued.Insert(id)
+	if q.processing.Has(id) {
+		// done hands it out again.
+		return
+	}
+	q.ids = append(q.ids, id)
+	q.cond.Signal()
+}
+
+// get blocks until an ID is ready and returns it. The caller must call done
+// with it once it is synced.
+func (q *workQueue) get() string {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.ids) == 0 {
+		q.cond.Wait()
+	}
+	id := q.ids[0]
+	q.ids = q.ids[1:]
+	q.queued.Delete(id)
+	q.processing.Insert(id)
+	return id
+}
+
+// done marks id, returned by get, as synced. If it was added again meanwhile,
+// it is ready again.
+func (q *workQueue) done(id string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.processing.Delete(id)
+	if q.queued.Has(id) {
+		q.ids = append(q.ids, id)
+		q.cond.Signal()
+	}
+}
+
+// retry adds id again after a delay, since its sync failed. Nothing else may
+// queue it, e.g. when the pods it failed to create never show up.
+func (q *workQueue) retry(id string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delay := q.retryDelays[id] * 2
+	if delay < minRetryDelay {
+		delay = minRetryDelay
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	q.retryDelays[id] = delay
+	time.AfterFunc(delay, func() { q.add(id) })
+}
+
+// forgetRetries resets the retry delay of id, once it synced successfully.
+func (q *workQueue) forgetRetries(id string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.retryDelays, id)
+}
+
+// len returns the number of IDs waiting.
+func (q *workQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.queued)
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWorkQueueOrder(t *testing.T) {
+	q := newWorkQueue()
+	q.add("foo")
+	q.add("bar")
+	q.add("foo")
+	if n := q.len(); n != 2 {
+		t.Errorf("Expected 2 queued, got %d", n)
+	}
+	for _, expected := range []string{"foo", "bar"} {
+		if id := q.get(); id != expected {
+			t.Errorf("Expected %s, got %s", expected, id)
+		}
+		q.done(expected)
+	}
+	if n := q.len(); n != 0 {
+		t.Errorf("Expected an empty queue, got %d", n)
+	}
+}
+
+func TestWorkQueueSerializesIDs(t *testing.T) {
+	q := newWorkQueue()
+	q.add("foo")
+	if id := q.get(); id != "foo" {
+		t.Fatalf("Expected foo, got %s", id)
+	}
+
+	// foo changes while it is synced; bar is handed out meanwhile.
+	q.add("foo")
+	q.add("bar")
+	got := make(chan string)
+	go func() {
+		got <- q.get()
+		got <- q.get()
+	}()
+	if id := <-got; id != "bar" {
+		t.Errorf("Expected bar, got %s", id)
+	}
+	select {
+	case id := <-got:
+		t.Errorf("Unexpected %s while foo is synced", id)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	q.done("foo")
+	if id := <-got; id != "foo" {
+		t.Errorf("Expected foo again, got %s", id)
+	}
+}
+
+func TestWorkQueueRetryBacksOff(t *testing.T) {
+	defer func(min, max time.Duration) { minRetryDelay, maxRetryDelay = min, max }(minRetryDelay, maxRetryDelay)
+	minRetryDelay, maxRetryDelay = 10*time.Millisecond, 40*time.Millisecond
+
+	q := newWorkQueue()
+	for _, expected := range []time.Duration{10, 20, 40, 40} {
+		q.retry("foo")
+		if delay := q.retryDelays["foo"]; delay != expected*time.Millisecond {
+			t.Errorf("Expected a delay of %v, got %v", expected*time.Millisecond, delay)
+		}
+		q.done(q.get())
+	}
+	q.forgetRetries("foo")
+	q.retry("foo")
+	if delay := q.retryDelays["foo"]; delay != minRetryDelay {
+		t.Errorf("Expected the delay to start over, got %v", delay)
+	}
+}
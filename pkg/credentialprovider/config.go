@@ -0,0 +1,77 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialprovider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// DockerConfig holds the credentials of docker registries, keyed by registry as
+// written in a .dockercfg file, e.g. "https://index.docker.io/v1/" or "quay.io".
+type DockerConfig map[string]DockerConfigEntry
+
+// DockerConfigEntry holds the credentials of one registry.
+type DockerConfigEntry struct {
+	Username string
+	Password string
+	Email    string
+}
+
+// dockerConfigEntryWithAuth is how an entry is written in a .dockercfg file: the
+// username and password are joined by a colon and base64 encoded.
+type dockerConfigEntryWithAuth struct {
+	Auth  string `json:"auth"`
+	Email string `json:"email,omitempty"`
+}
+
+// ReadDockerConfigFile reads the .dockercfg file at path.
+func ReadDockerConfigFile(path string) (DockerConfig, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDockerConfig(contents)
+}
+
+// ParseDockerConfig parses the contents of a .dockercfg file.
+func ParseDockerConfig(contents []byte) (DockerConfig, error) {
+	var entries map[string]dockerConfigEntryWithAuth
+	if err := json.Unmarshal(contents, &entries); err != nil {
+		return nil, err
+	}
+	config := DockerConfig{}
+	for registry, entry := range entries {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth of registry %s: %v", registry, err)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid auth of registry %s: expected username:password", registry)
+		}
+		config[registry] = DockerConfigEntry{
+			Username: parts[0],
+			Password: parts[1],
+			Email:    entry.Email,
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialprovider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseDockerConfig(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("foo:bar:baz"))
+	contents := fmt.Sprintf(`{"https://index.docker.io/v1/": {"auth": %q, "email": "foo@example.com"}}`, auth)
+	config, err := ParseDockerConfig([]byte(contents))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := DockerConfig{
+		"https://index.docker.io/v1/": {Username: "foo", Password: "bar:baz", Email: "foo@example.com"},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %#v, got %#v", expected, config)
+	}
+}
+
+func TestParseDockerConfigInvalid(t *testing.T) {
+	invalid := []string{
+		`not json`,
+		`{"quay.io": {"auth": "not base64!"}}`,
+		fmt.Sprintf(`{"quay.io": {"auth": %q}}`, base64.StdEncoding.EncodeToString([]byte("nocolon"))),
+	}
+	for _, contents := range invalid {
+		if _, err := ParseDockerConfig([]byte(contents)); err == nil {
+			t.Errorf("expected an error parsing %s", contents)
+		}
+	}
+}
+
+func TestDockerConfigFileProvider(t *testing.T) {
+	file, err := ioutil.TempFile("", "dockercfg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(file.Name())
+	auth := base64.StdEncoding.EncodeToString([]byte("foo:bar"))
+	fmt.Fprintf(file, `{"quay.io": {"auth": %q}}`, auth)
+	file.Close()
+
+	provider := NewDockerConfigFileProvider("/does/not/exist", file.Name())
+	if !provider.Enabled() {
+		t.Errorf("expected provider to be enabled")
+	}
+	expected := DockerConfig{"quay.io": {Username: "foo", Password: "bar"}}
+	if config := provider.Provide(); !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %#v, got %#v", expected, config)
+	}
+	if config := NewDockerConfigFileProvider("/does/not/exist").Provide(); len(config) != 0 {
+		t.Errorf("unexpected config %#v", config)
+	}
+}
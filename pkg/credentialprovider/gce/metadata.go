@@ -0,0 +1,95 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gce_credentials supplies registry credentials from the metadata of
+// Google Compute Engine instances.
+package gce_credentials
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/credentialprovider"
+	"github.com/golang/glog"
+)
+
+const (
+	metadataURL = "http://metadata/computeMetadata/v1/"
+	// The instance attribute holding the contents of a .dockercfg file.
+	dockerConfigKey = "instance/attributes/google-dockercfg"
+)
+
+func init() {
+	credentialprovider.RegisterCredentialProvider("google-dockercfg", &metadataProvider{
+		url:    metadataURL,
+		client: &http.Client{Timeout: 5 * time.Second},
+	})
+}
+
+// metadataProvider supplies the credentials in the google-dockercfg attribute of
+// the instance the kubelet runs on.
+type metadataProvider struct {
+	url    string
+	client *http.Client
+}
+
+// get returns the metadata at key, and false if it isn't set.
+func (p *metadataProvider) get(key string) ([]byte, bool, error) {
+	req, err := http.NewRequest("GET", p.url+key, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Add("X-Google-Metadata-Request", "True")
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("unexpected status %d getting %s", res.StatusCode, key)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	return data, true, err
+}
+
+// Enabled implements credentialprovider.DockerConfigProvider. It is true when
+// the kubelet runs on a Google Compute Engine instance.
+func (p *metadataProvider) Enabled() bool {
+	_, ok, err := p.get("")
+	return ok && err == nil
+}
+
+// Provide implements credentialprovider.DockerConfigProvider.
+func (p *metadataProvider) Provide() credentialprovider.DockerConfig {
+	data, ok, err := p.get(dockerConfigKey)
+	if err != nil {
+		glog.Errorf("Failed to get %s from the metadata server: %v", dockerConfigKey, err)
+	}
+	if !ok || err != nil {
+		return credentialprovider.DockerConfig{}
+	}
+	config, err := credentialprovider.ParseDockerConfig(data)
+	if err != nil {
+		glog.Errorf("Failed to parse %s: %v", dockerConfigKey, err)
+		return credentialprovider.DockerConfig{}
+	}
+	return config
+}
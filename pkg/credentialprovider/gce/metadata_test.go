@@ -0,0 +1,69 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gce_credentials
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/credentialprovider"
+)
+
+func TestMetadataProvider(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("foo:bar"))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Google-Metadata-Request") != "True" {
+			t.Errorf("missing metadata request header")
+		}
+		switch req.URL.Path {
+		case "/":
+		case "/" + dockerConfigKey:
+			fmt.Fprintf(w, `{"gcr.io": {"auth": %q}}`, auth)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := &metadataProvider{url: server.URL + "/", client: http.DefaultClient}
+	if !provider.Enabled() {
+		t.Errorf("expected provider to be enabled")
+	}
+	expected := credentialprovider.DockerConfig{"gcr.io": {Username: "foo", Password: "bar"}}
+	if config := provider.Provide(); !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %#v, got %#v", expected, config)
+	}
+}
+
+func TestMetadataProviderNoConfig(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	provider := &metadataProvider{url: server.URL + "/", client: http.DefaultClient}
+	if provider.Enabled() {
+		t.Errorf("expected provider to be disabled off GCE")
+	}
+	if config := provider.Provide(); len(config) != 0 {
+		t.Errorf("unexpected config %#v", config)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialprovider
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+// defaultRegistryHost is where images without a registry in their name come from.
+const defaultRegistryHost = "index.docker.io"
+
+// DockerKeyring finds the credentials to pull an image with.
+type DockerKeyring interface {
+	// Lookup returns the credentials of the registry image is pulled from, and
+	// false if there are none.
+	Lookup(image string) (docker.AuthConfiguration, bool)
+}
+
+// BasicDockerKeyring is a DockerKeyring holding the credentials it was given.
+type BasicDockerKeyring struct {
+	lock  sync.Mutex
+	creds map[string]docker.AuthConfiguration
+}
+
+// Add adds the credentials in config, replacing those of the same registries.
+func (k *BasicDockerKeyring) Add(config DockerConfig) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.creds == nil {
+		k.creds = map[string]docker.AuthConfiguration{}
+	}
+	for registry, entry := range config {
+		k.creds[registryHost(registry)] = docker.AuthConfiguration{
+			Username: entry.Username,
+			Password: entry.Password,
+			Email:    entry.Email,
+		}
+	}
+}
+
+// Lookup implements DockerKeyring.
+func (k *BasicDockerKeyring) Lookup(image string) (docker.AuthConfiguration, bool) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	auth, ok := k.creds[imageRegistryHost(image)]
+	return auth, ok
+}
+
+// registryHost returns the host of a registry as written in a .dockercfg file,
+// which may be a URL like "https://index.docker.io/v1/" or just a host.
+func registryHost(registry string) string {
+	if !strings.Contains(registry, "://") {
+		registry = "https://" + registry
+	}
+	u, err := url.Parse(registry)
+	if err != nil {
+		return registry
+	}
+	return u.Host
+}
+
+// imageRegistryHost returns the host of the registry image is pulled from. Like
+// docker, the first part of the name is a registry only if it looks like a host.
+func imageRegistryHost(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0]
+	}
+	return defaultRegistryHost
+}
+
+// providersKeyring is a DockerKeyring which asks the enabled providers for their
+// credentials on every lookup, so that it picks up changed credentials.
+type providersKeyring struct {
+	providers []DockerConfigProvider
+}
+
+// Lookup implements DockerKeyring.
+func (k *providersKeyring) Lookup(image string) (docker.AuthConfiguration, bool) {
+	keyring := &BasicDockerKeyring{}
+	for _, provider := range k.providers {
+		keyring.Add(provider.Provide())
+	}
+	return keyring.Lookup(image)
+}
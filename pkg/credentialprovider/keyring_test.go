@@ -0,0 +1,92 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialprovider
+
+import (
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestBasicDockerKeyringLookup(t *testing.T) {
+	keyring := &BasicDockerKeyring{}
+	keyring.Add(DockerConfig{
+		"https://index.docker.io/v1/": {Username: "hub"},
+		"quay.io":                     {Username: "quay"},
+		"http://registry.local:5000":  {Username: "local"},
+	})
+	tests := []struct {
+		image    string
+		username string
+		found    bool
+	}{
+		{"ubuntu", "hub", true},
+		{"foo/bar:latest", "hub", true},
+		{"quay.io/foo/bar", "quay", true},
+		{"registry.local:5000/bar", "local", true},
+		{"registry.local/bar", "", false},
+		{"localhost/bar", "", false},
+	}
+	for _, test := range tests {
+		auth, found := keyring.Lookup(test.image)
+		if found != test.found || auth.Username != test.username {
+			t.Errorf("%s: expected %q, %v, got %#v, %v", test.image, test.username, test.found, auth, found)
+		}
+	}
+}
+
+type fakeProvider struct {
+	enabled bool
+	config  DockerConfig
+}
+
+func (f *fakeProvider) Enabled() bool         { return f.enabled }
+func (f *fakeProvider) Provide() DockerConfig { return f.config }
+
+func TestNewDockerKeyring(t *testing.T) {
+	providersMutex.Lock()
+	saved := providers
+	providers = map[string]DockerConfigProvider{}
+	providersMutex.Unlock()
+	defer func() {
+		providersMutex.Lock()
+		providers = saved
+		providersMutex.Unlock()
+	}()
+
+	changing := &fakeProvider{enabled: true, config: DockerConfig{"quay.io": {Username: "a"}}}
+	RegisterCredentialProvider("a", changing)
+	RegisterCredentialProvider("b", &fakeProvider{enabled: true, config: DockerConfig{"gcr.io": {Username: "b"}}})
+	RegisterCredentialProvider("c", &fakeProvider{enabled: false, config: DockerConfig{"quay.io": {Username: "c"}}})
+	keyring := NewDockerKeyring()
+
+	expected := map[string]docker.AuthConfiguration{
+		"quay.io/foo": {Username: "a"},
+		"gcr.io/foo":  {Username: "b"},
+	}
+	for image, auth := range expected {
+		if found, ok := keyring.Lookup(image); !ok || found != auth {
+			t.Errorf("%s: expected %#v, got %#v", image, auth, found)
+		}
+	}
+
+	// Credentials are asked for on every lookup.
+	changing.config = DockerConfig{"quay.io": {Username: "changed"}}
+	if found, _ := keyring.Lookup("quay.io/foo"); found.Username != "changed" {
+		t.Errorf("expected changed credentials, got %#v", found)
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentialprovider
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// DockerConfigProvider supplies registry credentials.
+type DockerConfigProvider interface {
+	// Enabled returns true if the provider can supply credentials where the
+	// kubelet runs. It is asked once, when a keyring is made.
+	Enabled() bool
+	// Provide returns the current credentials.
+	Provide() DockerConfig
+}
+
+// All registered credential providers.
+var providersMutex sync.Mutex
+var providers = make(map[string]DockerConfigProvider)
+
+func init() {
+	RegisterCredentialProvider(".dockercfg", NewDockerConfigFileProvider(
+		filepath.Join(os.Getenv("HOME"), ".dockercfg"),
+		"/.dockercfg",
+	))
+}
+
+// RegisterCredentialProvider registers a DockerConfigProvider by name. This is
+// expected to happen during app startup.
+func RegisterCredentialProvider(name string, provider DockerConfigProvider) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	if _, found := providers[name]; found {
+		glog.Fatalf("Credential provider %q was registered twice", name)
+	}
+	glog.V(1).Infof("Registered credential provider %q", name)
+	providers[name] = provider
+}
+
+// NewDockerKeyring returns a DockerKeyring with the credentials of every
+// registered provider which is enabled. When several providers have credentials
+// for a registry, the provider whose name sorts last wins.
+func NewDockerKeyring() DockerKeyring {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	names := []string{}
+	for name := range providers {
+		names = append(names, name)
+	}
+	// Make the precedence between providers deterministic.
+	sort.Strings(names)
+	keyring := &providersKeyring{}
+	for _, name := range names {
+		if providers[name].Enabled() {
+			glog.V(1).Infof("Using credential provider %q", name)
+			keyring.providers = append(keyring.providers, providers[name])
+		}
+	}
+	return keyring
+}
+
+// dockerConfigFileProvider supplies the credentials of the first .dockercfg file
+// found.
+type dockerConfigFileProvider struct {
+	paths []string
+}
+
+// NewDockerConfigFileProvider returns a DockerConfigProvider supplying the
+// credentials in the first of paths which exists. The file is read every time
+// credentials are asked for.
+func NewDockerConfigFileProvider(paths ...string) DockerConfigProvider {
+	return &dockerConfigFileProvider{paths}
+}
+
+// Enabled implements DockerConfigProvider. A file which isn't there yet may be
+// written later.
+func (p *dockerConfigFileProvider) Enabled() bool {
+	return true
+}
+
+// Provide implements DockerConfigProvider.
+func (p *dockerConfigFileProvider) Provide() DockerConfig {
+	for _, path := range p.paths {
+		config, err := ReadDockerConfigFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			glog.Errorf("Failed to read docker config %s: %v", path, err)
+			return DockerConfig{}
+		}
+		return config
+	}
+	return DockerConfig{}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/golang/glog"
+)
+
+// ServiceLister lists the services which get DNS records, and the endpoints
+// of the headless ones.
+type ServiceLister interface {
+	ListServices(selector labels.Selector) (api.ServiceList, error)
+	ListEndpoints(selector labels.Selector) (api.EndpointsList, error)
+}
+
+// SkyDNSBridge writes an A record for every service into the etcd of a skydns
+// server serving domain, so <service>.<domain> resolves to the service. The
+// name of a headless service resolves to all of its endpoints instead, under
+// <service>/<endpoint> in etcd.
+type SkyDNSBridge struct {
+	services ServiceLister
+	etcd     tools.EtcdClient
+	domain   string
+}
+
+// skyDNSRecord is the record skydns reads from etcd. skydns ignores Kubernetes,
+// which marks the records a SkyDNSBridge writes, so that records others keep in
+// the domain are left alone.
+type skyDNSRecord struct {
+	Host       string `json:"host"`
+	Port       int    `json:"port,omitempty"`
+	Kubernetes bool   `json:"kubernetes,omitempty"`
+}
+
+// NewSkyDNSBridge returns a SkyDNSBridge publishing services in domain, e.g.
+// "kubernetes.local".
+func NewSkyDNSBridge(services ServiceLister, etcd tools.EtcdClient, domain string) *SkyDNSBridge {
+	return &SkyDNSBridge{
+		services: services,
+		etcd:     etcd,
+		domain:   strings.Trim(domain, "."),
+	}
+}
+
+// Run syncs the records every period, forever.
+func (b *SkyDNSBridge) Run(period time.Duration) {
+	go util.Forever(func() {
+		if err := b.Sync(); err != nil {
+			glog.Errorf("Failed to sync service DNS records: %v", err)
+		}
+	}, period)
+}
+
+// prefix returns the etcd directory of the records of the domain, which skydns
+// keeps under the labels of the domain in reverse.
+func (b *SkyDNSBridge) prefix() string {
+	labels := strings.Split(b.domain, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return path.Join(append([]string{"/skydns"}, labels...)...)
+}
+
+// serviceHost returns the IP a service's record points to: its portal IP, or
+// else its first public IP. Services with neither get no record.
+func serviceHost(service *api.Service) string {
+	if net.ParseIP(service.PortalIP) != nil {
+		return service.PortalIP
+	}
+	for _, ip := range service.PublicIPs {
+		if net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	return ""
+}
+
+// endpointRecords adds the records of the endpoints of a headless service to
+// wanted, keyed under dir.
+func endpointRecords(dir string, endpoints []string, wanted map[string]string) error {
+	for _, endpoint := range endpoints {
+		host, portStr, err := net.SplitHostPort(endpoint)
+		if err != nil || net.ParseIP(host) == nil {
+			continue
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			continue
+		}
+		data, err := json.Marshal(skyDNSRecord{Host: host, Port: port, Kubernetes: true})
+		if err != nil {
+			return err
+		}
+		name := strings.NewReplacer(".", "-", ":", "-").Replace(endpoint)
+		wanted[path.Join(dir, name)] = string(data)
+	}
+	return nil
+}
+
+// leaves adds the keys and values of the records under node which a
+// SkyDNSBridge wrote to records.
+func leaves(node *etcd.Node, records map[string]string) {
+	for _, child := range node.Nodes {
+		if child.Dir {
+			leaves(child, records)
+			continue
+		}
+		var record skyDNSRecord
+		if err := json.Unmarshal([]byte(child.Value), &record); err == nil && record.Kubernetes {
+			records[child.Key] = child.Value
+		}
+	}
+}
+
+// Sync writes the records of the current services, and deletes the records
+// of services which are gone. Records it didn't write are only replaced where
+// a service takes their name, and never deleted.
+func (b *SkyDNSBridge) Sync() error {
+	services, err := b.services.ListServices(labels.Everything())
+	if err != nil {
+		return err
+	}
+	prefix := b.prefix()
+	wanted := map[string]string{}
+	headless := map[string]bool{}
+	for i := range services.Items {
+		service := &services.Items[i]
+		if service.PortalIP == api.PortalIPNone {
+			headless[service.ID] = true
+			continue
+		}
+		host := serviceHost(service)
+		if host == "" {
+			continue
+		}
+		data, err := json.Marshal(skyDNSRecord{Host: host, Port: service.Port, Kubernetes: true})
+		if err != nil {
+			return err
+		}
+		wanted[path.Join(prefix, strings.ToLower(service.ID))] = string(data)
+	}
+	if len(headless) > 0 {
+		endpoints, err := b.services.ListEndpoints(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, e := range endpoints.Items {
+			if !headless[e.ID] {
+				continue
+			}
+			if err := endpointRecords(path.Join(prefix, strings.ToLower(e.ID)), e.Endpoints, wanted); err != nil {
+				return err
+			}
+		}
+	}
+
+	existing := map[string]string{}
+	response, err := b.etcd.Get(prefix, false, true)
+	if err != nil && !tools.IsEtcdNotFound(err) {
+		return err
+	}
+	if err == nil && response.Node != nil {
+		leaves(response.Node, existing)
+	}
+
+	// Stale records go first, so that a name can move between a record and
+	// a directory of records.
+	var errs []error
+	for key := range existing {
+		if _, ok := wanted[key]; ok {
+			continue
+		}
+		if _, err := b.etcd.Delete(key, false); err != nil && !tools.IsEtcdNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	for key, value := range wanted {
+		if existing[key] == value {
+			continue
+		}
+		if _, err := b.etcd.Set(key, value, 0); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to sync service DNS records (%v)", errs)
+	}
+	return nil
+}
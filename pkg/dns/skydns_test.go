@@ -0,0 +1,179 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dns
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/coreos/go-etcd/etcd"
+)
+
+type fakeServiceLister struct {
+	services  api.ServiceList
+	endpoints api.EndpointsList
+	err       error
+}
+
+func (f *fakeServiceLister) ListServices(selector labels.Selector) (api.ServiceList, error) {
+	return f.services, f.err
+}
+
+func (f *fakeServiceLister) ListEndpoints(selector labels.Selector) (api.EndpointsList, error) {
+	return f.endpoints, f.err
+}
+
+func TestPrefix(t *testing.T) {
+	table := map[string]string{
+		"kubernetes.local":  "/skydns/local/kubernetes",
+		"kubernetes.local.": "/skydns/local/kubernetes",
+		"a.b.c":             "/skydns/c/b/a",
+		"local":             "/skydns/local",
+	}
+	for domain, expected := range table {
+		if prefix := NewSkyDNSBridge(nil, nil, domain).prefix(); prefix != expected {
+			t.Errorf("%s: expected %s, got %s", domain, expected, prefix)
+		}
+	}
+}
+
+func TestSyncWritesRecords(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.ExpectNotFoundGet("/skydns/local/kubernetes")
+	services := &fakeServiceLister{services: api.ServiceList{Items: []api.Service{
+		{JSONBase: api.JSONBase{ID: "Foo"}, Port: 80, PortalIP: "10.0.0.1"},
+		{JSONBase: api.JSONBase{ID: "bar"}, Port: 53, PublicIPs: []string{"1.2.3.4"}},
+		{JSONBase: api.JSONBase{ID: "baz"}, Port: 8080},
+	}}}
+	bridge := NewSkyDNSBridge(services, fakeClient, "kubernetes.local")
+	if err := bridge.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"/skydns/local/kubernetes/foo": `{"host":"10.0.0.1","port":80,"kubernetes":true}`,
+		"/skydns/local/kubernetes/bar": `{"host":"1.2.3.4","port":53,"kubernetes":true}`,
+	}
+	for key, value := range expected {
+		if got := fakeClient.Data[key]; got.R == nil || got.R.Node.Value != value {
+			t.Errorf("%s: expected %s, got %#v", key, value, got.R)
+		}
+	}
+	if _, ok := fakeClient.Data["/skydns/local/kubernetes/baz"]; ok {
+		t.Errorf("unexpected record for a service without an IP")
+	}
+}
+
+func TestSyncDeletesStaleRecords(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.Data["/skydns/local/kubernetes"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Dir: true,
+				Nodes: []*etcd.Node{
+					{Key: "/skydns/local/kubernetes/foo", Value: `{"host":"10.0.0.1","port":80,"kubernetes":true}`},
+					{Key: "/skydns/local/kubernetes/old", Value: `{"host":"10.0.0.2","port":80,"kubernetes":true}`},
+					{Key: "/skydns/local/kubernetes/gateway", Value: `{"host":"10.0.0.254"}`},
+				},
+			},
+		},
+	}
+	services := &fakeServiceLister{services: api.ServiceList{Items: []api.Service{
+		{JSONBase: api.JSONBase{ID: "foo"}, Port: 80, PortalIP: "10.0.0.1"},
+	}}}
+	bridge := NewSkyDNSBridge(services, fakeClient, "kubernetes.local")
+	if err := bridge.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Records others wrote stay.
+	sort.Strings(fakeClient.DeletedKeys)
+	if expected := []string{"/skydns/local/kubernetes/old"}; !reflect.DeepEqual(fakeClient.DeletedKeys, expected) {
+		t.Errorf("expected %v deleted, got %v", expected, fakeClient.DeletedKeys)
+	}
+	if _, ok := fakeClient.Data["/skydns/local/kubernetes/foo"]; ok {
+		t.Errorf("unexpected rewrite of an unchanged record")
+	}
+}
+
+func TestSyncListError(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	services := &fakeServiceLister{err: errors.New("list failed")}
+	if err := NewSkyDNSBridge(services, fakeClient, "kubernetes.local").Sync(); err == nil {
+		t.Errorf("expected an error")
+	}
+	if len(fakeClient.Data) != 0 {
+		t.Errorf("unexpected writes: %v", fakeClient.Data)
+	}
+}
+
+func TestSyncHeadlessService(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.Data["/skydns/local/kubernetes"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Dir: true,
+				Nodes: []*etcd.Node{
+					{Key: "/skydns/local/kubernetes/db", Value: `{"host":"10.0.0.1","port":5432,"kubernetes":true}`},
+					{
+						Key: "/skydns/local/kubernetes/quorum",
+						Dir: true,
+						Nodes: []*etcd.Node{
+							{Key: "/skydns/local/kubernetes/quorum/10-244-1-2-2181", Value: `{"host":"10.244.1.2","port":2181,"kubernetes":true}`},
+							{Key: "/skydns/local/kubernetes/quorum/10-244-1-3-2181", Value: `{"host":"10.244.1.3","port":2181,"kubernetes":true}`},
+						},
+					},
+				},
+			},
+		},
+	}
+	services := &fakeServiceLister{
+		services: api.ServiceList{Items: []api.Service{
+			{JSONBase: api.JSONBase{ID: "db"}, Port: 5432, PortalIP: api.PortalIPNone},
+			{JSONBase: api.JSONBase{ID: "quorum"}, Port: 2181, PortalIP: api.PortalIPNone},
+		}},
+		endpoints: api.EndpointsList{Items: []api.Endpoints{
+			{JSONBase: api.JSONBase{ID: "db"}, Endpoints: []string{"10.244.1.5:5432", "10.244.2.6:5432"}},
+			{JSONBase: api.JSONBase{ID: "quorum"}, Endpoints: []string{"10.244.1.2:2181"}},
+			{JSONBase: api.JSONBase{ID: "web"}, Endpoints: []string{"10.244.1.7:80"}},
+		}},
+	}
+	bridge := NewSkyDNSBridge(services, fakeClient, "kubernetes.local")
+	if err := bridge.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"/skydns/local/kubernetes/db/10-244-1-5-5432": `{"host":"10.244.1.5","port":5432,"kubernetes":true}`,
+		"/skydns/local/kubernetes/db/10-244-2-6-5432": `{"host":"10.244.2.6","port":5432,"kubernetes":true}`,
+	}
+	for key, value := range expected {
+		if got := fakeClient.Data[key]; got.R == nil || got.R.Node.Value != value {
+			t.Errorf("%s: expected %s, got %#v", key, value, got.R)
+		}
+	}
+	sort.Strings(fakeClient.DeletedKeys)
+	deleted := []string{"/skydns/local/kubernetes/db", "/skydns/local/kubernetes/quorum/10-244-1-3-2181"}
+	if !reflect.DeepEqual(fakeClient.DeletedKeys, deleted) {
+		t.Errorf("expected %v deleted, got %v", deleted, fakeClient.DeletedKeys)
+	}
+	if _, ok := fakeClient.Data["/skydns/local/kubernetes/web/10-244-1-7-80"]; ok {
+		t.Errorf("unexpected records for the endpoints of a service which isn't headless")
+	}
+}
@@ -27,9 +27,15 @@ import (
 	"github.com/golang/glog"
 )
 
-// NewEtcdMasterElector returns an implementation of election.MasterElector backed by etcd
-func NewEtcdMasterElector(h tools.EtcdGetSet) MasterElector {
-	return &etcdMasterElector{etcd: h}
+// NewEtcdMasterElector returns an implementation of election.MasterElector backed by etcd.
+// The master holds a lease of ttl, rounded up to whole seconds, which it renews every third
+// of ttl; another participant takes over within a third of ttl after it expires.
+func NewEtcdMasterElector(h tools.EtcdGetSet, ttl time.Duration) MasterElector {
+	seconds := uint64((ttl + time.Second - 1) / time.Second)
+	if seconds == 0 {
+		seconds = 1
+	}
+	return &etcdMasterElector{etcd: h, ttl: seconds}
 }
 
 type empty struct{}
@@ -37,6 +43,7 @@ type empty struct{}
 // internal implementation struct
 type etcdMasterElector struct {
 	etcd   tools.EtcdGetSet
+	ttl    uint64
 	done   chan empty
 	events chan watch.Event
 }
@@ -52,7 +59,7 @@ func (e *etcdMasterElector) Elect(path, id string) watch.Interface {
 func (e *etcdMasterElector) run(path, id string) {
 	masters := make(chan string)
 	errors := make(chan error)
-	go e.master(path, id, 30, masters, errors, e.done)
+	go e.master(path, id, e.ttl, masters, errors, e.done)
 	for {
 		select {
 		case m := <-masters:
@@ -147,25 +154,41 @@ func (e *etcdMasterElector) handleMaster(path, id string, ttl uint64) (string, e
 //   Otherwise
 //      If we are the master, extend the lease
 //      If the master is different than the last time through the loop, report the master
-//   Sleep 80% of TTL
+//   If we were the master but couldn't extend the lease in time, report no master
+//   Sleep a third of TTL
 func (e *etcdMasterElector) master(path, id string, ttl uint64, masters chan<- string, errors chan<- error, done <-chan empty) {
+	lease := time.Duration(ttl) * time.Second
+	period := lease / 3
 	lastMaster := ""
+	var renewed time.Time
 	for {
+		start := time.Now()
 		master, err := e.handleMaster(path, id, ttl)
 		if err != nil {
 			errors <- err
+			// Once the lease may expire before the next try, another participant
+			// may become the master, so we must stop acting as one.
+			if lastMaster == id && !time.Now().Add(period).Before(renewed.Add(lease)) {
+				lastMaster = ""
+				masters <- ""
+			}
 		} else if len(master) == 0 {
 			continue
-		} else if master != lastMaster {
-			lastMaster = master
-			masters <- master
+		} else {
+			if master == id {
+				renewed = start
+			}
+			if master != lastMaster {
+				lastMaster = master
+				masters <- master
+			}
 		}
 		// TODO: Add Watch here, skip the polling for faster reactions
 		// If done is closed, break out.
 		select {
 		case <-done:
 			return
-		case <-time.After(time.Duration((ttl*8)/10) * time.Second):
+		case <-time.After(period):
 		}
 	}
 }
@@ -17,7 +17,10 @@ limitations under the License.
 package election
 
 import (
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
@@ -28,7 +31,7 @@ func TestEtcdMasterOther(t *testing.T) {
 	path := "foo"
 	etcd := tools.NewFakeEtcdClient(t)
 	etcd.Set(path, "baz", 0)
-	master := NewEtcdMasterElector(etcd)
+	master := NewEtcdMasterElector(etcd, 30*time.Second)
 	w := master.Elect(path, "bar")
 	result := <-w.ResultChan()
 	if result.Type != watch.Modified || result.Object.(string) != "baz" {
@@ -49,7 +52,7 @@ func TestEtcdMasterNoOther(t *testing.T) {
 			ErrorCode: tools.EtcdErrorCodeNotFound,
 		},
 	}
-	master := NewEtcdMasterElector(e)
+	master := NewEtcdMasterElector(e, 30*time.Second)
 	w := master.Elect(path, "bar")
 	result := <-w.ResultChan()
 	if result.Type != watch.Modified || result.Object.(string) != "bar" {
@@ -88,7 +91,7 @@ func TestEtcdMasterNoOtherThenConflict(t *testing.T) {
 		},
 	}
 	e.Data["foo"] = empty
-	master := NewEtcdMasterElector(e)
+	master := NewEtcdMasterElector(e, 30*time.Second)
 	w := master.Elect(path, "bar")
 	result := <-w.ResultChan()
 	if result.Type != watch.Modified || result.Object.(string) != "bar" {
@@ -96,3 +99,70 @@ func TestEtcdMasterNoOtherThenConflict(t *testing.T) {
 	}
 	w.Stop()
 }
+
+// unreachableClient fails every request once unreachable is set.
+type unreachableClient struct {
+	*tools.FakeEtcdClient
+	unreachable int32
+}
+
+func (c *unreachableClient) Get(key string, sort, recursive bool) (*etcd.Response, error) {
+	if atomic.LoadInt32(&c.unreachable) == 1 {
+		return nil, errors.New("etcd is unreachable")
+	}
+	return c.FakeEtcdClient.Get(key, sort, recursive)
+}
+
+func TestEtcdMasterLosesLeaseWhenUnreachable(t *testing.T) {
+	e := tools.NewFakeEtcdClient(t)
+	e.TestIndex = true
+	e.ExpectNotFoundGet("foo")
+	client := &unreachableClient{FakeEtcdClient: e}
+	master := NewEtcdMasterElector(client, 3*time.Second)
+	w := master.Elect("foo", "bar")
+	defer w.Stop()
+	if result := <-w.ResultChan(); result.Object.(string) != "bar" {
+		t.Fatalf("unexpected event: %#v", result)
+	}
+
+	// The lease can't be extended, so once it may have expired there is no
+	// master as far as bar knows.
+	atomic.StoreInt32(&client.unreachable, 1)
+	select {
+	case result := <-w.ResultChan():
+		if result.Object.(string) != "" {
+			t.Errorf("unexpected event: %#v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected bar to give up the lease")
+	}
+}
+
+type fakeMasterElector struct {
+	w *watch.FakeWatcher
+}
+
+func (e fakeMasterElector) Elect(path, id string) watch.Interface {
+	return e.w
+}
+
+func TestLead(t *testing.T) {
+	elector := fakeMasterElector{watch.NewFake()}
+	go func() {
+		elector.w.Modify("baz")
+		elector.w.Modify("bar")
+	}()
+	lost := Lead(elector, "foo", "bar")
+
+	select {
+	case <-lost:
+		t.Errorf("unexpected loss of mastership")
+	default:
+	}
+	elector.w.Modify("baz")
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Errorf("expected the loss of mastership")
+	}
+}
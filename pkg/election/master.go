@@ -27,8 +27,32 @@ type MasterElector interface {
 	// RequestMaster makes the caller represented by 'id' enter into a master election for the
 	// distributed lock defined by 'path'
 	// The returned watch.Interface provides a stream of Master objects which
-	// contain the current master.
+	// contain the current master. An empty master means none is known, e.g.
+	// since the caller was the master but couldn't extend its lease.
 	// Calling Stop on the returned interface relinquishes ownership (if currently possesed)
 	// and removes the caller from the election
 	Elect(path, id string) watch.Interface
 }
+
+// Lead enters id into the election for path, and blocks until it is the master.
+// The returned channel is closed once it no longer is, after which the caller
+// must stop acting as the master.
+func Lead(elector MasterElector, path, id string) <-chan struct{} {
+	w := elector.Elect(path, id)
+	for event := range w.ResultChan() {
+		if event.Object.(string) == id {
+			break
+		}
+	}
+	lost := make(chan struct{})
+	go func() {
+		defer close(lost)
+		for event := range w.ResultChan() {
+			if event.Object.(string) != id {
+				w.Stop()
+				return
+			}
+		}
+	}()
+	return lost
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envvars
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// FromServices returns the environment variables containers find services by:
+// <SERVICE>_SERVICE_HOST and <SERVICE>_SERVICE_PORT, and the ones a docker link
+// to a container named after the service would set. Services are at their
+// portal IP if portals is set, or else at the proxy on host. Portals only work
+// where the proxy redirects them with iptables. Headless services have no
+// single address, so they are only found through DNS.
+func FromServices(services []api.Service, host string, portals bool) []api.EnvVar {
+	var result []api.EnvVar
+	for _, service := range services {
+		if service.PortalIP == api.PortalIPNone {
+			continue
+		}
+		serviceHost := host
+		if portals && service.PortalIP != "" {
+			serviceHost = service.PortalIP
+		}
+		prefix := makeEnvVariableName(service.ID)
+		result = append(result,
+			api.EnvVar{Name: prefix + "_SERVICE_HOST", Value: serviceHost},
+			api.EnvVar{Name: prefix + "_SERVICE_PORT", Value: strconv.Itoa(service.Port)})
+		result = append(result, makeLinkVariables(service, serviceHost)...)
+	}
+	result = append(result, api.EnvVar{Name: "SERVICE_HOST", Value: host})
+	return result
+}
+
+func makeEnvVariableName(str string) string {
+	return strings.ToUpper(strings.Replace(str, "-", "_", -1))
+}
+
+// makeLinkVariables returns the variables of a docker link to service, named
+// after the container port the service sends traffic to.
+func makeLinkVariables(service api.Service, host string) []api.EnvVar {
+	prefix := makeEnvVariableName(service.ID)
+	targetPort := api.ServiceTargetPort(&service)
+	var port string
+	if targetPort.Kind == util.IntstrString {
+		port = targetPort.StrVal
+	} else {
+		port = strconv.Itoa(targetPort.IntVal)
+	}
+	protocol := strings.ToLower(service.Protocol)
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	url := fmt.Sprintf("%s://%s:%d", protocol, host, service.Port)
+	portPrefix := prefix + "_PORT_" + makeEnvVariableName(port) + "_" + strings.ToUpper(protocol)
+	return []api.EnvVar{
+		{
+			Name:  prefix + "_PORT",
+			Value: url,
+		},
+		{
+			Name:  portPrefix,
+			Value: url,
+		},
+		{
+			Name:  portPrefix + "_PROTO",
+			Value: protocol,
+		},
+		{
+			Name:  portPrefix + "_PORT",
+			Value: strconv.Itoa(service.Port),
+		},
+		{
+			Name:  portPrefix + "_ADDR",
+			Value: host,
+		},
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package envvars
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+func TestFromServices(t *testing.T) {
+	services := []api.Service{
+		{
+			JSONBase:   api.JSONBase{ID: "test"},
+			Port:       8080,
+			TargetPort: util.NewIntOrStringFromInt(900),
+		},
+		{
+			JSONBase: api.JSONBase{ID: "dns-server"},
+			Port:     53,
+			Protocol: "UDP",
+			PortalIP: "10.0.0.53",
+			// Stored before TargetPort existed.
+			ContainerPort: util.NewIntOrStringFromString("dns"),
+		},
+		{
+			JSONBase: api.JSONBase{ID: "headless"},
+			Port:     5432,
+			PortalIP: api.PortalIPNone,
+		},
+	}
+	expected := []api.EnvVar{
+		{Name: "TEST_SERVICE_HOST", Value: "machine"},
+		{Name: "TEST_SERVICE_PORT", Value: "8080"},
+		{Name: "TEST_PORT", Value: "tcp://machine:8080"},
+		{Name: "TEST_PORT_900_TCP", Value: "tcp://machine:8080"},
+		{Name: "TEST_PORT_900_TCP_PROTO", Value: "tcp"},
+		{Name: "TEST_PORT_900_TCP_PORT", Value: "8080"},
+		{Name: "TEST_PORT_900_TCP_ADDR", Value: "machine"},
+		{Name: "DNS_SERVER_SERVICE_HOST", Value: "10.0.0.53"},
+		{Name: "DNS_SERVER_SERVICE_PORT", Value: "53"},
+		{Name: "DNS_SERVER_PORT", Value: "udp://10.0.0.53:53"},
+		{Name: "DNS_SERVER_PORT_DNS_UDP", Value: "udp://10.0.0.53:53"},
+		{Name: "DNS_SERVER_PORT_DNS_UDP_PROTO", Value: "udp"},
+		{Name: "DNS_SERVER_PORT_DNS_UDP_PORT", Value: "53"},
+		{Name: "DNS_SERVER_PORT_DNS_UDP_ADDR", Value: "10.0.0.53"},
+		{Name: "SERVICE_HOST", Value: "machine"},
+	}
+	vars := FromServices(services, "machine", true)
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("expected %#v, got %#v", expected, vars)
+	}
+	for _, v := range vars {
+		if !util.IsCIdentifier(v.Name) {
+			t.Errorf("Environment variable name is not valid: %v", v.Name)
+		}
+	}
+}
+
+func TestFromServicesWithoutPortals(t *testing.T) {
+	services := []api.Service{
+		{
+			JSONBase:   api.JSONBase{ID: "db"},
+			Port:       5432,
+			PortalIP:   "10.0.0.5",
+			TargetPort: util.NewIntOrStringFromInt(5432),
+		},
+	}
+	expected := []api.EnvVar{
+		{Name: "DB_SERVICE_HOST", Value: "machine"},
+		{Name: "DB_SERVICE_PORT", Value: "5432"},
+		{Name: "DB_PORT", Value: "tcp://machine:5432"},
+		{Name: "DB_PORT_5432_TCP", Value: "tcp://machine:5432"},
+		{Name: "DB_PORT_5432_TCP_PROTO", Value: "tcp"},
+		{Name: "DB_PORT_5432_TCP_PORT", Value: "5432"},
+		{Name: "DB_PORT_5432_TCP_ADDR", Value: "machine"},
+		{Name: "SERVICE_HOST", Value: "machine"},
+	}
+	if vars := FromServices(services, "machine", false); !reflect.DeepEqual(vars, expected) {
+		t.Errorf("expected %#v, got %#v", expected, vars)
+	}
+}
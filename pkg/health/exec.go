@@ -43,14 +43,16 @@ func IsExitError(err error) bool {
 	return ok
 }
 
+// HealthCheck runs the command of the container's exec probe inside it. The
+// container is healthy if the command exits with status 0.
 func (e *ExecHealthChecker) HealthCheck(podFullName string, currentState api.PodState, container api.Container) (Status, error) {
 	if container.LivenessProbe.Exec == nil {
 		return Unknown, fmt.Errorf("Missing exec parameters")
 	}
 	data, err := e.runner.RunInContainer(podFullName, container.Name, container.LivenessProbe.Exec.Command)
-	glog.V(1).Infof("container %s failed health check: %s", podFullName, string(data))
 	if err != nil {
 		if IsExitError(err) {
+			glog.V(1).Infof("container %s/%s failed health check: %v: %s", podFullName, container.Name, err, string(data))
 			return Unhealthy, nil
 		}
 		return Unknown, err
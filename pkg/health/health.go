@@ -94,3 +94,15 @@ func findPortByName(container api.Container, portName string) int {
 	}
 	return -1
 }
+
+// findContainerPortByName returns the port the container itself listens on for
+// the port named portName, for checks made against the pod IP rather than the
+// host. It returns -1 if the container has no such port.
+func findContainerPortByName(container api.Container, portName string) int {
+	for _, port := range container.Ports {
+		if port.Name == portName {
+			return port.ContainerPort
+		}
+	}
+	return -1
+}
@@ -39,7 +39,7 @@ type HTTPHealthChecker struct {
 }
 
 func NewHTTPHealthChecker(client *http.Client) HealthChecker {
-	return &HTTPHealthChecker{client: &http.Client{}}
+	return &HTTPHealthChecker{client: client}
 }
 
 // Get the components of the target URL.  For testability.
@@ -20,12 +20,18 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	"github.com/golang/glog"
 )
 
+// tcpDialTimeout bounds how long a TCP check waits for the connection to open.
+var tcpDialTimeout = 5 * time.Second
+
+// TCPHealthChecker is an implementation of HealthChecker which checks container health by
+// opening a TCP connection to a port of the container.
 type TCPHealthChecker struct{}
 
 // Get the components of a TCP connection address.  For testability.
@@ -39,7 +45,8 @@ func getTCPAddrParts(currentState api.PodState, container api.Container) (string
 	case util.IntstrInt:
 		port = params.Port.IntVal
 	case util.IntstrString:
-		port = findPortByName(container, params.Port.StrVal)
+		// The connection goes to the pod IP, where the container listens on its own port.
+		port = findContainerPortByName(container, params.Port.StrVal)
 		if port == -1 {
 			// Last ditch effort - maybe it was an int stored as string?
 			var err error
@@ -60,10 +67,10 @@ func getTCPAddrParts(currentState api.PodState, container api.Container) (string
 
 // DoTCPCheck checks that a TCP socket to the address can be opened.
 // If the socket can be opened, it returns Healthy.
-// If the socket fails to open, it returns Unhealthy.
+// If the socket fails to open within a few seconds, it returns Unhealthy.
 // This is exported because some other packages may want to do direct TCP checks.
 func DoTCPCheck(addr string) (Status, error) {
-	conn, err := net.Dial("tcp", addr)
+	conn, err := net.DialTimeout("tcp", addr, tcpDialTimeout)
 	if err != nil {
 		return Unhealthy, nil
 	}
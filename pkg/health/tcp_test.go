@@ -46,7 +46,7 @@ func TestGetTCPAddrParts(t *testing.T) {
 	for _, test := range testCases {
 		state := api.PodState{PodIP: "1.2.3.4"}
 		container := api.Container{
-			Ports: []api.Port{{Name: "found", HostPort: 93}},
+			Ports: []api.Port{{Name: "found", ContainerPort: 93, HostPort: 8093}},
 			LivenessProbe: &api.LivenessProbe{
 				TCPSocket: test.probe,
 				Type:      "tcp",
@@ -28,6 +28,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/controller"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/wait"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/version"
@@ -111,6 +112,23 @@ func Update(name string, client client.Interface, updatePeriod time.Duration) er
 	})
 }
 
+// RollingUpdate moves the replicas of the controller named 'oldName' to the
+// controller named 'newName', one at a time, waiting 'updatePeriod' after the
+// pods of every step are ready. Closing 'stop' aborts the update, which is
+// resumed by running it again.
+func RollingUpdate(oldName, newName string, client client.Interface, updatePeriod time.Duration, stop <-chan struct{}) error {
+	updater := controller.NewRollingUpdater(client)
+	return updater.Update(&controller.RollingUpdaterConfig{
+		OldName:      oldName,
+		NewName:      newName,
+		UpdatePeriod: updatePeriod,
+		Interval:     time.Second * 5,
+		Timeout:      time.Second * 300,
+		Stop:         stop,
+		Out:          os.Stdout,
+	})
+}
+
 // StopController stops a controller named 'name' by setting replicas to zero
 func StopController(name string, client client.Interface) error {
 	return ResizeController(name, 0, client)
@@ -118,12 +136,7 @@ func StopController(name string, client client.Interface) error {
 
 // ResizeController resizes a controller named 'name' by setting replicas to 'replicas'
 func ResizeController(name string, replicas int, client client.Interface) error {
-	controller, err := client.GetReplicationController(name)
-	if err != nil {
-		return err
-	}
-	controller.DesiredState.Replicas = replicas
-	controllerOut, err := client.UpdateReplicationController(controller)
+	controllerOut, err := client.ResizeReplicationController(name, replicas)
 	if err != nil {
 		return err
 	}
@@ -105,18 +105,14 @@ func TestStopController(t *testing.T) {
 	fakeClient := client.Fake{}
 	name := "name"
 	StopController(name, &fakeClient)
-	if len(fakeClient.Actions) != 2 {
-		t.Errorf("Unexpected actions: %#v", fakeClient.Actions)
+	if len(fakeClient.Actions) != 1 {
+		t.Fatalf("Unexpected actions: %#v", fakeClient.Actions)
 	}
-	if fakeClient.Actions[0].Action != "get-controller" ||
-		fakeClient.Actions[0].Value.(string) != name {
+	resize := fakeClient.Actions[0].Value.(api.Resize)
+	if fakeClient.Actions[0].Action != "resize-controller" ||
+		resize.ControllerID != name || resize.Replicas != 0 {
 		t.Errorf("Unexpected Action: %#v", fakeClient.Actions[0])
 	}
-	controller := fakeClient.Actions[1].Value.(api.ReplicationController)
-	if fakeClient.Actions[1].Action != "update-controller" ||
-		controller.DesiredState.Replicas != 0 {
-		t.Errorf("Unexpected Action: %#v", fakeClient.Actions[1])
-	}
 }
 
 func TestResizeController(t *testing.T) {
@@ -124,18 +120,14 @@ func TestResizeController(t *testing.T) {
 	name := "name"
 	replicas := 17
 	ResizeController(name, replicas, &fakeClient)
-	if len(fakeClient.Actions) != 2 {
-		t.Errorf("Unexpected actions: %#v", fakeClient.Actions)
+	if len(fakeClient.Actions) != 1 {
+		t.Fatalf("Unexpected actions: %#v", fakeClient.Actions)
 	}
-	if fakeClient.Actions[0].Action != "get-controller" ||
-		fakeClient.Actions[0].Value.(string) != name {
+	resize := fakeClient.Actions[0].Value.(api.Resize)
+	if fakeClient.Actions[0].Action != "resize-controller" ||
+		resize.ControllerID != name || resize.Replicas != 17 {
 		t.Errorf("Unexpected Action: %#v", fakeClient.Actions[0])
 	}
-	controller := fakeClient.Actions[1].Value.(api.ReplicationController)
-	if fakeClient.Actions[1].Action != "update-controller" ||
-		controller.DesiredState.Replicas != 17 {
-		t.Errorf("Unexpected Action: %#v", fakeClient.Actions[1])
-	}
 }
 
 func TestCloudCfgDeleteController(t *testing.T) {
@@ -59,6 +59,7 @@ var testParser = NewParser(map[string]interface{}{
 	"pods":                   api.Pod{},
 	"services":               api.Service{},
 	"replicationControllers": api.ReplicationController{},
+	"daemonControllers":      api.DaemonController{},
 })
 
 func TestParsePod(t *testing.T) {
@@ -113,6 +114,27 @@ func TestParseController(t *testing.T) {
 	}, testParser)
 }
 
+func TestParseDaemonController(t *testing.T) {
+	DoParseTest(t, "daemonControllers", api.DaemonController{
+		JSONBase: api.JSONBase{APIVersion: "v1beta1", ID: "my daemon", Kind: "DaemonController"},
+		DesiredState: api.DaemonControllerState{
+			ReplicaSelector: map[string]string{"name": "logger"},
+			PodTemplate: api.PodTemplate{
+				DesiredState: api.PodState{
+					Manifest: api.ContainerManifest{
+						ID: "My manifest",
+						Containers: []api.Container{
+							{Name: "my container"},
+						},
+					},
+				},
+				Labels:       map[string]string{"name": "logger"},
+				NodeSelector: map[string]string{"disk": "ssd"},
+			},
+		},
+	}, testParser)
+}
+
 type TestParseType struct {
 	api.JSONBase `json:",inline" yaml:",inline"`
 	Data         string `json:"data" yaml:"data"`
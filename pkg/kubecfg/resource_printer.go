@@ -24,6 +24,7 @@ import (
 	"strings"
 	"text/tabwriter"
 	"text/template"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
@@ -138,8 +139,10 @@ func (h *HumanReadablePrinter) validatePrintHandlerFunc(printFunc reflect.Value)
 
 var podColumns = []string{"Name", "Image(s)", "Host", "Labels"}
 var replicationControllerColumns = []string{"Name", "Image(s)", "Selector", "Replicas"}
+var daemonControllerColumns = []string{"Name", "Image(s)", "Selector", "Minion Selector"}
 var serviceColumns = []string{"Name", "Labels", "Selector", "Port"}
 var minionColumns = []string{"Minion identifier"}
+var eventColumns = []string{"Time", "Object", "Reason", "Source", "Message"}
 var statusColumns = []string{"Status"}
 
 // handleDefaultTypes adds print handlers for default Kubernetes types
@@ -148,10 +151,14 @@ func (h *HumanReadablePrinter) addDefaultHandlers() {
 	h.Handler(podColumns, printPodList)
 	h.Handler(replicationControllerColumns, printReplicationController)
 	h.Handler(replicationControllerColumns, printReplicationControllerList)
+	h.Handler(daemonControllerColumns, printDaemonController)
+	h.Handler(daemonControllerColumns, printDaemonControllerList)
 	h.Handler(serviceColumns, printService)
 	h.Handler(serviceColumns, printServiceList)
 	h.Handler(minionColumns, printMinion)
 	h.Handler(minionColumns, printMinionList)
+	h.Handler(eventColumns, printEvent)
+	h.Handler(eventColumns, printEventList)
 	h.Handler(statusColumns, printStatus)
 }
 
@@ -212,6 +219,22 @@ func printReplicationControllerList(list *api.ReplicationControllerList, w io.Wr
 	return nil
 }
 
+func printDaemonController(ctrl *api.DaemonController, w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+		ctrl.ID, makeImageList(ctrl.DesiredState.PodTemplate.DesiredState.Manifest),
+		labels.Set(ctrl.DesiredState.ReplicaSelector), labels.Set(ctrl.DesiredState.PodTemplate.NodeSelector))
+	return err
+}
+
+func printDaemonControllerList(list *api.DaemonControllerList, w io.Writer) error {
+	for _, ctrl := range list.Items {
+		if err := printDaemonController(&ctrl, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func printService(svc *api.Service, w io.Writer) error {
 	_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", svc.ID, labels.Set(svc.Labels),
 		labels.Set(svc.Selector), svc.Port)
@@ -241,6 +264,24 @@ func printMinionList(list *api.MinionList, w io.Writer) error {
 	return nil
 }
 
+func printEvent(event *api.Event, w io.Writer) error {
+	object := event.InvolvedObject.Kind + "/" + event.InvolvedObject.ID
+	if event.InvolvedObject.FieldPath != "" {
+		object += " " + event.InvolvedObject.FieldPath
+	}
+	_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", event.Timestamp.Format(time.RFC1123Z), object, event.Reason, event.Source, event.Message)
+	return err
+}
+
+func printEventList(list *api.EventList, w io.Writer) error {
+	for _, event := range list.Items {
+		if err := printEvent(&event, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func printStatus(status *api.Status, w io.Writer) error {
 	_, err := fmt.Fprintf(w, "%v\n", status.Status)
 	return err
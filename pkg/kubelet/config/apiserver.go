@@ -0,0 +1,90 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Reads the pod configuration from the pods the apiserver has bound to this host
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/kubelet"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/golang/glog"
+)
+
+// podLister lists the pods matching both selectors. client.Client implements it.
+type podLister interface {
+	ListPodsWithFields(label, field labels.Selector) (api.PodList, error)
+}
+
+type SourceApiserver struct {
+	client   podLister
+	hostname string
+	updates  chan<- interface{}
+}
+
+// NewSourceApiserver creates a config source that polls the apiserver for the pods
+// bound to hostname. updates should be the channel of the "etcd" source: the kubelet
+// takes the pods of that namespace for the ones the master scheduled, e.g. when
+// reporting their status, and the pods read from the apiserver are the same pods.
+func NewSourceApiserver(client podLister, hostname string, period time.Duration, updates chan<- interface{}) *SourceApiserver {
+	config := &SourceApiserver{
+		client:   client,
+		hostname: hostname,
+		updates:  updates,
+	}
+	glog.Infof("Watching apiserver for pods bound to %s", hostname)
+	go util.Forever(config.run, period)
+	return config
+}
+
+func (s *SourceApiserver) run() {
+	if err := s.extractFromApiserver(); err != nil {
+		glog.Errorf("Unable to list pods from the apiserver: %v", err)
+	}
+}
+
+// extractFromApiserver sends the pods bound to the host as a SET. If listing fails
+// nothing is sent, so the pods last seen keep running.
+func (s *SourceApiserver) extractFromApiserver() error {
+	field := labels.Set{"DesiredState.Host": s.hostname}.AsSelector()
+	list, err := s.client.ListPodsWithFields(labels.Everything(), field)
+	if err != nil {
+		return err
+	}
+	pods := []kubelet.Pod{}
+	for i := range list.Items {
+		pod := &list.Items[i]
+		if pod.DesiredState.Host != s.hostname {
+			// Selected by the apiserver already; checked again to be safe.
+			continue
+		}
+		// Name pods like the etcd source does, so switching sources keeps containers.
+		name := pod.DesiredState.Manifest.ID
+		if name == "" {
+			name = pod.ID
+		}
+		if name == "" {
+			return fmt.Errorf("pod %d bound to %s has no ID", i+1, s.hostname)
+		}
+		pods = append(pods, kubelet.Pod{Name: name, Manifest: pod.DesiredState.Manifest})
+	}
+	s.updates <- kubelet.PodUpdate{Pods: pods, Op: kubelet.SET}
+	return nil
+}
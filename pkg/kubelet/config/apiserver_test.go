@@ -0,0 +1,90 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/kubelet"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+)
+
+func TestExtractFromApiserver(t *testing.T) {
+	fake := &client.Fake{
+		Pods: api.PodList{
+			Items: []api.Pod{
+				{
+					JSONBase: api.JSONBase{ID: "foo"},
+					DesiredState: api.PodState{
+						Host:     "machine",
+						Manifest: api.ContainerManifest{Version: "v1beta1", ID: "foo"},
+					},
+				},
+				{
+					JSONBase: api.JSONBase{ID: "bar"},
+					DesiredState: api.PodState{
+						Host:     "machine",
+						Manifest: api.ContainerManifest{Version: "v1beta1"},
+					},
+				},
+				{
+					JSONBase: api.JSONBase{ID: "elsewhere"},
+					DesiredState: api.PodState{
+						Host:     "other-machine",
+						Manifest: api.ContainerManifest{Version: "v1beta1", ID: "elsewhere"},
+					},
+				},
+			},
+		},
+	}
+	ch := make(chan interface{}, 1)
+	c := SourceApiserver{fake, "machine", ch}
+	if err := c.extractFromApiserver(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := CreatePodUpdate(kubelet.SET,
+		kubelet.Pod{Name: "foo", Manifest: api.ContainerManifest{Version: "v1beta1", ID: "foo"}},
+		kubelet.Pod{Name: "bar", Manifest: api.ContainerManifest{Version: "v1beta1"}})
+	update := (<-ch).(kubelet.PodUpdate)
+	if !reflect.DeepEqual(expected, update) {
+		t.Errorf("Expected %#v, Got %#v", expected, update)
+	}
+	if e, a := []client.FakeAction{{Action: "list-pods", Value: "DesiredState.Host=machine"}}, fake.Actions; !reflect.DeepEqual(e, a) {
+		t.Errorf("Expected the pods bound to the host to be listed, got %#v", a)
+	}
+}
+
+type failingPodLister struct {
+	client.Fake
+}
+
+func (f *failingPodLister) ListPodsWithFields(label, field labels.Selector) (api.PodList, error) {
+	return api.PodList{}, errors.New("apiserver unavailable")
+}
+
+func TestExtractFromApiserverErrorNoUpdate(t *testing.T) {
+	ch := make(chan interface{}, 1)
+	c := SourceApiserver{&failingPodLister{}, "machine", ch}
+	if err := c.extractFromApiserver(); err == nil {
+		t.Errorf("Expected error")
+	}
+	expectEmptyChannel(t, ch)
+}
@@ -51,6 +51,10 @@ type PodConfig struct {
 
 	// the channel of denormalized changes passed to listeners
 	updates chan kubelet.PodUpdate
+
+	// the names of the sources a channel was created for
+	sourcesLock sync.Mutex
+	sources     util.StringSet
 }
 
 // NewPodConfig creates an object that can merge many configuration sources into a stream
@@ -62,6 +66,7 @@ func NewPodConfig(mode PodConfigNotificationMode) *PodConfig {
 		pods:    storage,
 		mux:     config.NewMux(storage),
 		updates: updates,
+		sources: util.StringSet{},
 	}
 	return podConfig
 }
@@ -69,14 +74,34 @@ func NewPodConfig(mode PodConfigNotificationMode) *PodConfig {
 // Channel creates or returns a config source channel.  The channel
 // only accepts PodUpdates
 func (c *PodConfig) Channel(source string) chan<- interface{} {
+	c.sourcesLock.Lock()
+	defer c.sourcesLock.Unlock()
+	c.sources.Insert(source)
 	return c.mux.Channel(source)
 }
 
+// SeenAllSources returns true if every source a channel was created for has
+// delivered an update. Until then the merged state may be missing pods.
+func (c *PodConfig) SeenAllSources() bool {
+	c.sourcesLock.Lock()
+	defer c.sourcesLock.Unlock()
+	return c.pods.seenSources(c.sources.List()...)
+}
+
 // Updates returns a channel of updates to the configuration, properly denormalized.
 func (c *PodConfig) Updates() <-chan kubelet.PodUpdate {
 	return c.updates
 }
 
+// AddValidation makes the pods from source which validate returns errors for
+// fail validation, in addition to the checks of kubelet.ValidatePod. Must be
+// called before the source delivers any pods.
+func (c *PodConfig) AddValidation(source string, validate func(*kubelet.Pod) []error) {
+	c.pods.updateLock.Lock()
+	defer c.pods.updateLock.Unlock()
+	c.pods.validations[source] = validate
+}
+
 // Sync requests the full configuration be delivered to the update channel.
 func (c *PodConfig) Sync() {
 	c.pods.Sync()
@@ -91,6 +116,11 @@ type podStorage struct {
 	// map of source name to pod name to pod reference
 	pods map[string]map[string]*kubelet.Pod
 	mode PodConfigNotificationMode
+	// the sources which have delivered at least one update
+	sourcesSeen util.StringSet
+	// the validations of pods in addition to kubelet.ValidatePod, by source;
+	// guarded by updateLock
+	validations map[string]func(*kubelet.Pod) []error
 
 	// ensures that updates are delivered in strict order
 	// on the updates channel
@@ -103,9 +133,11 @@ type podStorage struct {
 // TODO: allow initialization of the current state of the store with snapshotted version.
 func newPodStorage(updates chan<- kubelet.PodUpdate, mode PodConfigNotificationMode) *podStorage {
 	return &podStorage{
-		pods:    make(map[string]map[string]*kubelet.Pod),
-		mode:    mode,
-		updates: updates,
+		pods:        make(map[string]map[string]*kubelet.Pod),
+		mode:        mode,
+		updates:     updates,
+		sourcesSeen: util.StringSet{},
+		validations: map[string]func(*kubelet.Pod) []error{},
 	}
 }
 
@@ -173,7 +205,7 @@ func (s *podStorage) merge(source string, change interface{}) (adds, updates, de
 			glog.Infof("Updating pods from source %s : %v", source, update.Pods)
 		}
 
-		filtered := filterInvalidPods(update.Pods, source)
+		filtered := filterInvalidPods(update.Pods, source, s.validations[source])
 		for _, ref := range filtered {
 			name := ref.Name
 			if existing, found := pods[name]; found {
@@ -211,7 +243,7 @@ func (s *podStorage) merge(source string, change interface{}) (adds, updates, de
 		oldPods := pods
 		pods = make(map[string]*kubelet.Pod)
 
-		filtered := filterInvalidPods(update.Pods, source)
+		filtered := filterInvalidPods(update.Pods, source, s.validations[source])
 		for _, ref := range filtered {
 			name := ref.Name
 			if existing, found := oldPods[name]; found {
@@ -243,10 +275,18 @@ func (s *podStorage) merge(source string, change interface{}) (adds, updates, de
 	}
 
 	s.pods[source] = pods
+	s.sourcesSeen.Insert(source)
 	return adds, updates, deletes
 }
 
-func filterInvalidPods(pods []kubelet.Pod, source string) (filtered []*kubelet.Pod) {
+// seenSources returns true if every one of sources has delivered an update.
+func (s *podStorage) seenSources(sources ...string) bool {
+	s.podLock.RLock()
+	defer s.podLock.RUnlock()
+	return s.sourcesSeen.HasAll(sources...)
+}
+
+func filterInvalidPods(pods []kubelet.Pod, source string, validate func(*kubelet.Pod) []error) (filtered []*kubelet.Pod) {
 	names := util.StringSet{}
 	for i := range pods {
 		var errors []error
@@ -258,6 +298,9 @@ func filterInvalidPods(pods []kubelet.Pod, source string) (filtered []*kubelet.P
 		if errs := kubelet.ValidatePod(&pods[i]); len(errs) != 0 {
 			errors = append(errors, errs...)
 		}
+		if validate != nil {
+			errors = append(errors, validate(&pods[i])...)
+		}
 		if len(errors) > 0 {
 			glog.Warningf("Pod %d from %s failed validation, ignoring: %v", i+1, source, errors)
 			continue
@@ -17,8 +17,10 @@ limitations under the License.
 package config
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/kubelet"
@@ -116,6 +118,24 @@ func TestInvalidPodFiltered(t *testing.T) {
 	expectNoPodUpdate(t, ch)
 }
 
+func TestAddValidation(t *testing.T) {
+	config := NewPodConfig(PodConfigNotificationIncremental)
+	config.AddValidation("test", func(pod *kubelet.Pod) []error {
+		if pod.Name == "bad" {
+			return []error{fmt.Errorf("bad pod")}
+		}
+		return nil
+	})
+	channel, ch := config.Channel("test"), config.Updates()
+
+	channel <- CreatePodUpdate(kubelet.ADD, CreateValidPod("bad", ""), CreateValidPod("foo", ""))
+	expectPodUpdate(t, ch, CreatePodUpdate(kubelet.ADD, CreateValidPod("foo", "test")))
+
+	// Other sources are left alone.
+	config.Channel("other") <- CreatePodUpdate(kubelet.ADD, CreateValidPod("bad", ""))
+	expectPodUpdate(t, ch, CreatePodUpdate(kubelet.ADD, CreateValidPod("bad", "other")))
+}
+
 func TestNewPodAddedSnapshotAndUpdates(t *testing.T) {
 	channel, ch, config := createPodConfigTester(PodConfigNotificationSnapshotAndUpdates)
 
@@ -196,3 +216,31 @@ func TestNewPodAddedUpdatedSet(t *testing.T) {
 		CreatePodUpdate(kubelet.ADD, CreateValidPod("foo4", "test")),
 		CreatePodUpdate(kubelet.UPDATE, pod))
 }
+
+func TestSeenAllSources(t *testing.T) {
+	config := NewPodConfig(PodConfigNotificationIncremental)
+	file := config.Channel("file")
+	etcd := config.Channel("etcd")
+	if config.SeenAllSources() {
+		t.Errorf("Expected sources not to be seen before they deliver")
+	}
+
+	file <- CreatePodUpdate(kubelet.SET, CreateValidPod("foo", ""))
+	expectPodUpdate(t, config.Updates(), CreatePodUpdate(kubelet.ADD, CreateValidPod("foo", "file")))
+	if config.SeenAllSources() {
+		t.Errorf("Expected etcd not to be seen yet")
+	}
+
+	// An empty update counts, and leaves the pods of other sources alone.
+	etcd <- CreatePodUpdate(kubelet.SET)
+	// The update is merged asynchronously, and causes no notification to wait for.
+	for i := 0; i < 100 && !config.SeenAllSources(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !config.SeenAllSources() {
+		t.Errorf("Expected all sources to be seen")
+	}
+	expectNoPodUpdate(t, config.Updates())
+	config.Sync()
+	expectPodUpdate(t, config.Updates(), CreatePodUpdate(kubelet.SET, CreateValidPod("foo", "file")))
+}
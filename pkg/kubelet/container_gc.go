@@ -0,0 +1,140 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+)
+
+// ContainerGCPolicy says how many exited containers are kept around, so that
+// their logs can still be looked at.
+type ContainerGCPolicy struct {
+	// The number of exited containers kept for each pod.
+	MaxDeadPerPod int
+	// The number of exited containers kept for each pod which was deleted, i.e.
+	// which no configuration source wants on this host anymore.
+	MaxDeadPerDeletedPod int
+}
+
+// DesiredPodsFn returns the full names of the pods which should run on this
+// host, and whether they are known yet.
+type DesiredPodsFn func() (util.StringSet, bool)
+
+// ContainerGarbageCollector removes the exited containers of pods beyond what
+// its policy keeps. The newest docker container of every container of a pod
+// which still runs on this host is always kept, since that is where the kubelet
+// learns how the container exited. Until the desired pods are known, no pod is
+// taken for deleted.
+type ContainerGarbageCollector struct {
+	client      DockerInterface
+	policy      ContainerGCPolicy
+	desiredPods DesiredPodsFn
+}
+
+// NewContainerGarbageCollector makes a new ContainerGarbageCollector for the
+// containers of client, which takes the pods desiredPods doesn't return for
+// deleted.
+func NewContainerGarbageCollector(client DockerInterface, policy ContainerGCPolicy, desiredPods DesiredPodsFn) (*ContainerGarbageCollector, error) {
+	if policy.MaxDeadPerPod < 0 {
+		return nil, fmt.Errorf("invalid number of exited containers to keep per pod %d, must not be negative", policy.MaxDeadPerPod)
+	}
+	if policy.MaxDeadPerDeletedPod < 0 {
+		return nil, fmt.Errorf("invalid number of exited containers to keep per deleted pod %d, must not be negative", policy.MaxDeadPerDeletedPod)
+	}
+	return &ContainerGarbageCollector{
+		client:      client,
+		policy:      policy,
+		desiredPods: desiredPods,
+	}, nil
+}
+
+// GarbageCollect removes the exited containers the policy doesn't keep. Meant to
+// be called periodically, e.g. via util.Forever.
+func (gc *ContainerGarbageCollector) GarbageCollect() error {
+	all, err := gc.client.ListContainers(docker.ListContainersOptions{All: true})
+	if err != nil {
+		return err
+	}
+	running, err := getKubeletDockerContainers(gc.client)
+	if err != nil {
+		return err
+	}
+	activePods := map[string]bool{}
+	for _, container := range running {
+		podFullName, _, _ := parseDockerName(container.Names[0])
+		activePods[podFullName] = true
+	}
+	desiredPods, known := gc.desiredPods()
+
+	// Newest first, so the first container seen with a name is the one to keep.
+	sort.Sort(byCreatedDescending(all))
+	newest := map[podContainer]bool{}
+	dead := map[string][]docker.APIContainers{}
+	for _, container := range all {
+		if len(container.Names) == 0 {
+			continue
+		}
+		podFullName, containerName, _ := parseDockerName(container.Names[0])
+		if podFullName == "" {
+			// Not created by the kubelet.
+			continue
+		}
+		key := podContainer{podFullName, containerName}
+		isNewest := !newest[key]
+		newest[key] = true
+		if _, ok := running[DockerID(container.ID)]; ok {
+			continue
+		}
+		if isNewest && activePods[podFullName] {
+			continue
+		}
+		dead[podFullName] = append(dead[podFullName], container)
+	}
+
+	var errs []error
+	for podFullName, containers := range dead {
+		keep := gc.policy.MaxDeadPerPod
+		if known && !desiredPods.Has(podFullName) {
+			keep = gc.policy.MaxDeadPerDeletedPod
+		}
+		if len(containers) <= keep {
+			continue
+		}
+		for _, container := range containers[keep:] {
+			glog.V(1).Infof("Removing exited container %s of pod %s", container.ID, podFullName)
+			if err := gc.client.RemoveContainer(docker.RemoveContainerOptions{ID: container.ID}); err != nil {
+				errs = append(errs, fmt.Errorf("failed to remove container %s: %v", container.ID, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("container garbage collection failed: %v", errs)
+	}
+	return nil
+}
+
+// byCreatedDescending sorts containers newest first.
+type byCreatedDescending []docker.APIContainers
+
+func (b byCreatedDescending) Len() int           { return len(b) }
+func (b byCreatedDescending) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byCreatedDescending) Less(i, j int) bool { return b[i].Created > b[j].Created }
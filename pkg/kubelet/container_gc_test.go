@@ -0,0 +1,91 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/fsouza/go-dockerclient"
+)
+
+func newGCFakeDocker() *FakeDockerClient {
+	return &FakeDockerClient{
+		containerList: []docker.APIContainers{
+			{Names: []string{"/k8s--net--foo.test--1"}, ID: "net", Created: 1},
+			{Names: []string{"/k8s--bar--foo.test--5"}, ID: "running", Created: 5},
+		},
+		exitedContainerList: []docker.APIContainers{
+			// The newest baz of a pod still running tells how it exited.
+			{Names: []string{"/k8s--baz--foo.test--4"}, ID: "baz-newest", Created: 4},
+			{Names: []string{"/k8s--bar--foo.test--3"}, ID: "bar-3", Created: 3},
+			{Names: []string{"/k8s--bar--foo.test--2"}, ID: "bar-2", Created: 2},
+			{Names: []string{"/k8s--baz--foo.test--1"}, ID: "baz-1", Created: 1},
+			// A pod which doesn't run here anymore.
+			{Names: []string{"/k8s--bar--gone.test--2"}, ID: "gone-2", Created: 2},
+			{Names: []string{"/k8s--bar--gone.test--1"}, ID: "gone-1", Created: 1},
+			// Not created by the kubelet.
+			{Names: []string{"/other"}, ID: "other", Created: 1},
+		},
+	}
+}
+
+func TestContainerGarbageCollector(t *testing.T) {
+	fakeDocker := newGCFakeDocker()
+	// Until the desired pods are known, no pod is taken for deleted.
+	unknown := func() (util.StringSet, bool) { return nil, false }
+	gc, err := NewContainerGarbageCollector(fakeDocker, ContainerGCPolicy{MaxDeadPerPod: 1}, unknown)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := gc.GarbageCollect(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	sort.Strings(fakeDocker.removed)
+	expected := []string{"bar-2", "baz-1", "gone-1"}
+	if !reflect.DeepEqual(fakeDocker.removed, expected) {
+		t.Errorf("expected %v to be removed, got %v", expected, fakeDocker.removed)
+	}
+}
+
+func TestContainerGarbageCollectorDeletedPods(t *testing.T) {
+	fakeDocker := newGCFakeDocker()
+	desired := func() (util.StringSet, bool) { return util.NewStringSet("foo.test"), true }
+	gc, err := NewContainerGarbageCollector(fakeDocker, ContainerGCPolicy{MaxDeadPerPod: 1}, desired)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := gc.GarbageCollect(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	sort.Strings(fakeDocker.removed)
+	expected := []string{"bar-2", "baz-1", "gone-1", "gone-2"}
+	if !reflect.DeepEqual(fakeDocker.removed, expected) {
+		t.Errorf("expected %v to be removed, got %v", expected, fakeDocker.removed)
+	}
+}
+
+func TestNewContainerGarbageCollectorInvalidPolicy(t *testing.T) {
+	desired := func() (util.StringSet, bool) { return nil, false }
+	for _, policy := range []ContainerGCPolicy{{MaxDeadPerPod: -1}, {MaxDeadPerDeletedPod: -1}} {
+		if _, err := NewContainerGarbageCollector(&FakeDockerClient{}, policy, desired); err == nil {
+			t.Errorf("expected an error for %+v", policy)
+		}
+	}
+}
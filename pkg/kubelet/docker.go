@@ -20,12 +20,14 @@ import (
 	"errors"
 	"fmt"
 	"hash/adler32"
+	"io"
 	"math/rand"
 	"os/exec"
 	"strconv"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/credentialprovider"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/golang/glog"
 )
@@ -45,6 +47,12 @@ type DockerInterface interface {
 	StartContainer(id string, hostConfig *docker.HostConfig) error
 	StopContainer(id string, timeout uint) error
 	PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error
+	Info() (*docker.Env, error)
+	ListImages(all bool) ([]docker.APIImages, error)
+	RemoveImage(name string) error
+	RemoveContainer(opts docker.RemoveContainerOptions) error
+	InspectImage(name string) (*docker.Image, error)
+	Logs(opts docker.LogsOptions) error
 }
 
 // DockerID is an ID of docker container. It is a type to make it clear when we're working with docker container Ids
@@ -53,18 +61,23 @@ type DockerID string
 // DockerPuller is an abstract interface for testability.  It abstracts image pull operations.
 type DockerPuller interface {
 	Pull(image string) error
+	IsImagePresent(image string) (bool, error)
 }
 
 // dockerPuller is the default implementation of DockerPuller.
 type dockerPuller struct {
-	client DockerInterface
+	client  DockerInterface
+	keyring credentialprovider.DockerKeyring
 }
 
-// NewDockerPuller creates a new instance of the default implementation of DockerPuller.
+// NewDockerPuller creates a new instance of the default implementation of DockerPuller,
+// which pulls with the credentials of the registered credential providers. Concurrent
+// pulls of an image share one pull, and images which fail to pull are backed off.
 func NewDockerPuller(client DockerInterface) DockerPuller {
-	return dockerPuller{
-		client: client,
-	}
+	return newCoordinatedPuller(dockerPuller{
+		client:  client,
+		keyring: credentialprovider.NewDockerKeyring(),
+	})
 }
 
 type dockerContainerCommandRunner struct{}
@@ -85,12 +98,67 @@ func (d *dockerContainerCommandRunner) RunInContainer(containerID string, cmd []
 	return c.CombinedOutput()
 }
 
+// ExecInContainer uses nsinit to run the command inside the container identified by containerID,
+// streaming its input and output.
+func (d *dockerContainerCommandRunner) ExecInContainer(containerID string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	c, err := d.getRunInContainerCommand(containerID, cmd)
+	if err != nil {
+		return err
+	}
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	return c.Run()
+}
+
 // NewDockerContainerCommandRunner creates a ContainerCommandRunner which uses nsinit to run a command
 // inside a container.
 func NewDockerContainerCommandRunner() ContainerCommandRunner {
 	return &dockerContainerCommandRunner{}
 }
 
+// dockerExecCommandRunner runs commands with "docker exec", which needs docker 1.3 or later.
+type dockerExecCommandRunner struct {
+	dockerBinary string
+}
+
+func (d *dockerExecCommandRunner) getRunInContainerCommand(containerID string, cmd []string) *exec.Cmd {
+	args := append([]string{"exec", containerID}, cmd...)
+	return exec.Command(d.dockerBinary, args...)
+}
+
+// getExecInContainerCommand is getRunInContainerCommand, but keeps stdin open if interactive.
+func (d *dockerExecCommandRunner) getExecInContainerCommand(containerID string, cmd []string, interactive bool) *exec.Cmd {
+	args := []string{"exec"}
+	if interactive {
+		args = append(args, "-i")
+	}
+	args = append(append(args, containerID), cmd...)
+	return exec.Command(d.dockerBinary, args...)
+}
+
+// RunInContainer runs cmd inside the container identified by containerID with "docker exec".
+// A command which exits with a non-zero status returns an *exec.ExitError.
+func (d *dockerExecCommandRunner) RunInContainer(containerID string, cmd []string) ([]byte, error) {
+	return d.getRunInContainerCommand(containerID, cmd).CombinedOutput()
+}
+
+// ExecInContainer runs cmd inside the container identified by containerID with "docker exec",
+// streaming its input and output.
+func (d *dockerExecCommandRunner) ExecInContainer(containerID string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	c := d.getExecInContainerCommand(containerID, cmd, stdin != nil)
+	c.Stdin = stdin
+	c.Stdout = stdout
+	c.Stderr = stderr
+	return c.Run()
+}
+
+// NewDockerExecCommandRunner creates a ContainerCommandRunner which runs commands inside a
+// container with the "exec" command of dockerBinary, the docker client.
+func NewDockerExecCommandRunner(dockerBinary string) ContainerCommandRunner {
+	return &dockerExecCommandRunner{dockerBinary}
+}
+
 func (p dockerPuller) Pull(image string) error {
 	image, tag := parseImageName(image)
 
@@ -103,7 +171,18 @@ func (p dockerPuller) Pull(image string) error {
 		Repository: image,
 		Tag:        tag,
 	}
-	return p.client.PullImage(opts, docker.AuthConfiguration{})
+	// Without credentials for the registry, pull anonymously.
+	auth, _ := p.keyring.Lookup(image)
+	return p.client.PullImage(opts, auth)
+}
+
+// IsImagePresent returns true if image is on the machine.
+func (p dockerPuller) IsImagePresent(image string) (bool, error) {
+	_, err := p.client.InspectImage(image)
+	if err == docker.ErrNoSuchImage {
+		return false, nil
+	}
+	return err == nil, err
 }
 
 // DockerContainers is a map of containers
@@ -131,6 +210,23 @@ func (c DockerContainers) FindContainersByPodFullName(podFullName string) map[st
 	return containers
 }
 
+// getDockerContainerLogs writes the stdout and stderr of the container with the given ID.
+// tail is the number of lines to write from the end of the logs, or "all". If follow
+// is true it keeps writing new output until the container exits.
+func getDockerContainerLogs(client DockerInterface, containerID, tail string, follow bool, stdout, stderr io.Writer) error {
+	return client.Logs(docker.LogsOptions{
+		Container:    containerID,
+		Stdout:       true,
+		Stderr:       true,
+		OutputStream: stdout,
+		ErrorStream:  stderr,
+		Timestamps:   true,
+		RawTerminal:  false,
+		Follow:       follow,
+		Tail:         tail,
+	})
+}
+
 // GetKubeletDockerContainers returns a map of docker containers that we manage. The map key is the docker container ID
 func getKubeletDockerContainers(client DockerInterface) (DockerContainers, error) {
 	result := make(DockerContainers)
@@ -153,20 +249,31 @@ func getKubeletDockerContainers(client DockerInterface) (DockerContainers, error
 // ErrNoContainersInPod is returned when there are no running containers for a given pod
 var ErrNoContainersInPod = errors.New("no containers exist for this pod")
 
+// ErrContainerNotFound is returned when a pod has no running container of a given name
+var ErrContainerNotFound = errors.New("no container of that name is running in this pod")
+
 // GetDockerPodInfo returns docker info for all containers in the pod/manifest.
+// Containers which exited are included, so that the info tells how they ended;
+// for each container only its most recently created docker container is used.
 func getDockerPodInfo(client DockerInterface, podFullName string) (api.PodInfo, error) {
 	info := api.PodInfo{}
 
-	containers, err := client.ListContainers(docker.ListContainersOptions{})
+	containers, err := client.ListContainers(docker.ListContainersOptions{All: true})
 	if err != nil {
 		return nil, err
 	}
 
+	newest := map[string]docker.APIContainers{}
 	for _, value := range containers {
 		dockerManifestID, dockerContainerName, _ := parseDockerName(value.Names[0])
 		if dockerManifestID != podFullName {
 			continue
 		}
+		if current, ok := newest[dockerContainerName]; !ok || value.Created > current.Created {
+			newest[dockerContainerName] = value
+		}
+	}
+	for dockerContainerName, value := range newest {
 		inspectResult, err := client.InspectContainer(value.ID)
 		if err != nil {
 			return nil, err
@@ -185,6 +292,25 @@ func getDockerPodInfo(client DockerInterface, podFullName string) (api.PodInfo,
 	return info, nil
 }
 
+// resourceLimitSupport tells which resource limits the kernel docker runs on
+// can enforce. Docker runs containers without the limits it can't enforce.
+type resourceLimitSupport struct {
+	memory bool
+	swap   bool
+}
+
+// getResourceLimitSupport asks docker which resource limits it can enforce.
+func getResourceLimitSupport(client DockerInterface) (resourceLimitSupport, error) {
+	info, err := client.Info()
+	if err != nil {
+		return resourceLimitSupport{}, err
+	}
+	return resourceLimitSupport{
+		memory: info.GetBool("MemoryLimit"),
+		swap:   info.GetBool("SwapLimit"),
+	}, nil
+}
+
 // Converts "-" to "_-_" and "_" to "___" so that we can use "--" to meaningfully separate parts of a docker name.
 func escapeDash(in string) (out string) {
 	out = strings.Replace(in, "_", "___", -1)
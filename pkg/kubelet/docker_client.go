@@ -0,0 +1,244 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+)
+
+// ErrDockerTimeout is returned for docker operations which took too long.
+var ErrDockerTimeout = errors.New("docker operation timed out")
+
+// The classes of errors docker operations fail with.
+const (
+	// DockerImageNotFound means an image doesn't exist, locally or in its registry.
+	DockerImageNotFound = "ImageNotFound"
+	// DockerUnavailable means the docker daemon can't be reached.
+	DockerUnavailable = "DockerUnavailable"
+	// DockerTimeout means a docker operation took too long.
+	DockerTimeout = "DockerTimeout"
+	// DockerFailed covers all other errors.
+	DockerFailed = "DockerFailed"
+)
+
+// ClassifyDockerError returns which of the classes above err, returned by a
+// docker operation, belongs to.
+func ClassifyDockerError(err error) string {
+	switch err {
+	case ErrDockerTimeout:
+		return DockerTimeout
+	case docker.ErrNoSuchImage:
+		return DockerImageNotFound
+	case docker.ErrConnectionRefused:
+		return DockerUnavailable
+	}
+	switch e := err.(type) {
+	case *docker.Error:
+		if e.Status == 404 && strings.Contains(strings.ToLower(e.Message), "image") {
+			return DockerImageNotFound
+		}
+	case *url.Error:
+		return ClassifyDockerError(e.Err)
+	case *net.OpError:
+		return DockerUnavailable
+	case syscall.Errno:
+		if e == syscall.ECONNREFUSED || e == syscall.ENOENT {
+			return DockerUnavailable
+		}
+	}
+	// Pulls report failures in the progress they stream.
+	message := strings.ToLower(err.Error())
+	if strings.Contains(message, "not found") && (strings.Contains(message, "image") || strings.Contains(message, "repository")) {
+		return DockerImageNotFound
+	}
+	return DockerFailed
+}
+
+const (
+	// How many times reads are tried before giving up on an unreachable docker.
+	dockerReadAttempts = 3
+	// How long to wait before trying a read again. Doubles with every attempt.
+	initialDockerRetryDelay = 500 * time.Millisecond
+)
+
+// timeoutDockerClient is a DockerInterface which gives up on operations taking
+// longer than a timeout, and tries reads again while docker can't be reached.
+// Pulls and logs are streamed for as long as they take.
+type timeoutDockerClient struct {
+	client  DockerInterface
+	timeout time.Duration
+	sleep   func(time.Duration)
+}
+
+// NewTimeoutDockerClient wraps client, giving up on operations which take longer
+// than timeout. Reads are tried again with backoff while docker can't be reached.
+func NewTimeoutDockerClient(client DockerInterface, timeout time.Duration) DockerInterface {
+	return &timeoutDockerClient{
+		client:  client,
+		timeout: timeout,
+		sleep:   time.Sleep,
+	}
+}
+
+// withTimeout runs f, returning ErrDockerTimeout if it doesn't return within
+// timeout. f keeps running in the background then, and what it returns is dropped.
+func withTimeout(timeout time.Duration, f func() (interface{}, error)) (interface{}, error) {
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := f()
+		done <- result{value, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-time.After(timeout):
+		return nil, ErrDockerTimeout
+	}
+}
+
+// read runs the read f with a timeout, trying it again while docker can't be
+// reached or doesn't answer.
+func (c *timeoutDockerClient) read(name string, f func() (interface{}, error)) (interface{}, error) {
+	delay := initialDockerRetryDelay
+	for attempt := 1; ; attempt++ {
+		value, err := withTimeout(c.timeout, f)
+		if err == nil || attempt == dockerReadAttempts {
+			return value, err
+		}
+		if class := ClassifyDockerError(err); class != DockerUnavailable && class != DockerTimeout {
+			return value, err
+		}
+		glog.V(1).Infof("Docker %s failed, trying again in %v: %v", name, delay, err)
+		c.sleep(delay)
+		delay *= 2
+	}
+}
+
+// write runs f with a timeout. Writes are not tried again, as they may have
+// happened even if docker didn't answer.
+func (c *timeoutDockerClient) write(f func() error) error {
+	_, err := withTimeout(c.timeout, func() (interface{}, error) {
+		return nil, f()
+	})
+	return err
+}
+
+func (c *timeoutDockerClient) ListContainers(options docker.ListContainersOptions) ([]docker.APIContainers, error) {
+	value, err := c.read("list", func() (interface{}, error) {
+		return c.client.ListContainers(options)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]docker.APIContainers), nil
+}
+
+func (c *timeoutDockerClient) InspectContainer(id string) (*docker.Container, error) {
+	value, err := c.read("inspect", func() (interface{}, error) {
+		return c.client.InspectContainer(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*docker.Container), nil
+}
+
+func (c *timeoutDockerClient) CreateContainer(opts docker.CreateContainerOptions) (*docker.Container, error) {
+	value, err := withTimeout(c.timeout, func() (interface{}, error) {
+		return c.client.CreateContainer(opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*docker.Container), nil
+}
+
+func (c *timeoutDockerClient) StartContainer(id string, hostConfig *docker.HostConfig) error {
+	return c.write(func() error {
+		return c.client.StartContainer(id, hostConfig)
+	})
+}
+
+func (c *timeoutDockerClient) StopContainer(id string, timeout uint) error {
+	// Docker itself waits up to timeout seconds for the container to stop.
+	_, err := withTimeout(c.timeout+time.Duration(timeout)*time.Second, func() (interface{}, error) {
+		return nil, c.client.StopContainer(id, timeout)
+	})
+	return err
+}
+
+func (c *timeoutDockerClient) PullImage(opts docker.PullImageOptions, auth docker.AuthConfiguration) error {
+	return c.client.PullImage(opts, auth)
+}
+
+func (c *timeoutDockerClient) Info() (*docker.Env, error) {
+	value, err := c.read("info", func() (interface{}, error) {
+		return c.client.Info()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*docker.Env), nil
+}
+
+func (c *timeoutDockerClient) ListImages(all bool) ([]docker.APIImages, error) {
+	value, err := c.read("list images", func() (interface{}, error) {
+		return c.client.ListImages(all)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]docker.APIImages), nil
+}
+
+func (c *timeoutDockerClient) RemoveImage(name string) error {
+	return c.write(func() error {
+		return c.client.RemoveImage(name)
+	})
+}
+
+func (c *timeoutDockerClient) RemoveContainer(opts docker.RemoveContainerOptions) error {
+	return c.write(func() error {
+		return c.client.RemoveContainer(opts)
+	})
+}
+
+func (c *timeoutDockerClient) InspectImage(name string) (*docker.Image, error) {
+	value, err := c.read("inspect image", func() (interface{}, error) {
+		return c.client.InspectImage(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*docker.Image), nil
+}
+
+func (c *timeoutDockerClient) Logs(opts docker.LogsOptions) error {
+	return c.client.Logs(opts)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestClassifyDockerError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{ErrDockerTimeout, DockerTimeout},
+		{docker.ErrNoSuchImage, DockerImageNotFound},
+		{docker.ErrConnectionRefused, DockerUnavailable},
+		{&docker.Error{Status: 404, Message: "No such image: foo"}, DockerImageNotFound},
+		{&docker.Error{Status: 404, Message: "No such container: 1234"}, DockerFailed},
+		{&docker.Error{Status: 500, Message: "Server error"}, DockerFailed},
+		{&url.Error{Op: "Get", URL: "http://docker", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, DockerUnavailable},
+		{syscall.ENOENT, DockerUnavailable},
+		{errors.New("Error: image library/foo not found"), DockerImageNotFound},
+		{errors.New("HTTP code: 404 repository foo not found"), DockerImageNotFound},
+		{errors.New("something else"), DockerFailed},
+	}
+	for _, test := range tests {
+		if class := ClassifyDockerError(test.err); class != test.expected {
+			t.Errorf("%v: expected %s, got %s", test.err, test.expected, class)
+		}
+	}
+}
+
+// slowDockerClient is a FakeDockerClient whose calls block until released.
+type slowDockerClient struct {
+	FakeDockerClient
+	release chan struct{}
+}
+
+func (c *slowDockerClient) StartContainer(id string, hostConfig *docker.HostConfig) error {
+	<-c.release
+	return c.FakeDockerClient.StartContainer(id, hostConfig)
+}
+
+func (c *slowDockerClient) ListContainers(options docker.ListContainersOptions) ([]docker.APIContainers, error) {
+	<-c.release
+	return c.FakeDockerClient.ListContainers(options)
+}
+
+func TestTimeoutDockerClientTimesOut(t *testing.T) {
+	fake := &slowDockerClient{release: make(chan struct{})}
+	defer close(fake.release)
+	client := NewTimeoutDockerClient(fake, 10*time.Millisecond).(*timeoutDockerClient)
+	client.sleep = func(time.Duration) {}
+
+	if err := client.StartContainer("1234", nil); err != ErrDockerTimeout {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if _, err := client.ListContainers(docker.ListContainersOptions{}); err != ErrDockerTimeout {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestTimeoutDockerClientRetriesReads(t *testing.T) {
+	fake := &FakeDockerClient{
+		err:           docker.ErrConnectionRefused,
+		containerList: []docker.APIContainers{{ID: "1234"}},
+	}
+	client := NewTimeoutDockerClient(fake, time.Second).(*timeoutDockerClient)
+	var delays []time.Duration
+	client.sleep = func(d time.Duration) {
+		delays = append(delays, d)
+	}
+
+	if _, err := client.ListContainers(docker.ListContainersOptions{}); err != docker.ErrConnectionRefused {
+		t.Errorf("expected the error of the last attempt, got %v", err)
+	}
+	verifyCalls(t, fake, []string{"list", "list", "list"})
+	expected := []time.Duration{initialDockerRetryDelay, 2 * initialDockerRetryDelay}
+	if !reflect.DeepEqual(delays, expected) {
+		t.Errorf("expected delays %v, got %v", expected, delays)
+	}
+
+	// Writes aren't tried again, and neither are errors docker answered with.
+	fake.clearCalls()
+	if err := client.StartContainer("1234", nil); err != docker.ErrConnectionRefused {
+		t.Errorf("unexpected error: %v", err)
+	}
+	fake.err = fmt.Errorf("bad request")
+	if _, err := client.InspectContainer("1234"); err != fake.err {
+		t.Errorf("unexpected error: %v", err)
+	}
+	verifyCalls(t, fake, []string{"start", "inspect"})
+
+	fake.clearCalls()
+	fake.err = nil
+	containers, err := client.ListContainers(docker.ListContainersOptions{})
+	if err != nil || !reflect.DeepEqual(containers, fake.containerList) {
+		t.Errorf("unexpected result: %v, %v", containers, err)
+	}
+	verifyCalls(t, fake, []string{"list"})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/envvars"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+)
+
+// ServiceLister lists the services whose environment variables the kubelet
+// gives the containers it starts.
+type ServiceLister interface {
+	ListServices(selector labels.Selector) (api.ServiceList, error)
+}
+
+// cachedServiceLister lists the services a cache.Poller last found, so that
+// starting a container doesn't wait for the apiserver.
+type cachedServiceLister struct {
+	store  cache.Store
+	poller *cache.Poller
+}
+
+// NewCachedServiceLister returns a ServiceLister which lists the services lister
+// listed last, asking it again every period. Listing fails until lister first
+// answered, so that no container starts without the variables of services.
+func NewCachedServiceLister(lister ServiceLister, period time.Duration) ServiceLister {
+	store := cache.NewStore()
+	poller := cache.NewPoller(func() (cache.Enumerator, error) {
+		services, err := lister.ListServices(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		return serviceEnumerator(services.Items), nil
+	}, period, store)
+	poller.Run()
+	return &cachedServiceLister{store, poller}
+}
+
+// ListServices returns the services matching selector, sorted by ID.
+func (c *cachedServiceLister) ListServices(selector labels.Selector) (api.ServiceList, error) {
+	if !c.poller.HasListed() {
+		return api.ServiceList{}, fmt.Errorf("services have not been listed yet")
+	}
+	list := api.ServiceList{}
+	for _, obj := range c.store.List() {
+		service := obj.(*api.Service)
+		if selector.Matches(labels.Set(service.Labels)) {
+			list.Items = append(list.Items, *service)
+		}
+	}
+	sort.Sort(servicesByID(list.Items))
+	return list, nil
+}
+
+// serviceEnumerator enumerates services for a cache.Poller.
+type serviceEnumerator []api.Service
+
+func (s serviceEnumerator) Len() int {
+	return len(s)
+}
+
+func (s serviceEnumerator) Get(index int) (string, interface{}) {
+	return s[index].ID, &s[index]
+}
+
+// servicesByID sorts services by ID.
+type servicesByID []api.Service
+
+func (s servicesByID) Len() int           { return len(s) }
+func (s servicesByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+func (s servicesByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// getServiceEnvVars returns the environment variables of the services visible
+// right now, or none without a ServiceLister.
+func (kl *Kubelet) getServiceEnvVars() ([]api.EnvVar, error) {
+	if kl.serviceLister == nil {
+		return nil, nil
+	}
+	services, err := kl.serviceLister.ListServices(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	return envvars.FromServices(services.Items, kl.hostname, !kl.noPortals), nil
+}
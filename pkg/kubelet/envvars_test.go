@@ -0,0 +1,99 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/wait"
+)
+
+type fakeServiceLister struct {
+	services []api.Service
+	err      error
+}
+
+func (f *fakeServiceLister) ListServices(selector labels.Selector) (api.ServiceList, error) {
+	return api.ServiceList{Items: f.services}, f.err
+}
+
+func TestRunContainerServiceEnvVars(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.hostname = "machine"
+	kubelet.serviceLister = &fakeServiceLister{
+		services: []api.Service{{JSONBase: api.JSONBase{ID: "test"}, Port: 8080}},
+	}
+	pod := &Pod{Name: "foo", Namespace: "test"}
+	container := &api.Container{
+		Name: "bar",
+		Env:  []api.EnvVar{{Name: "TEST_SERVICE_HOST", Value: "elsewhere"}},
+	}
+	if _, err := kubelet.runContainer(pod, container, nil, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	env := fakeDocker.createOptions[0].Config.Env
+	// The variables of the container come last, so that they win.
+	if len(env) < 3 || env[0] != "TEST_SERVICE_HOST=machine" || env[1] != "TEST_SERVICE_PORT=8080" || env[len(env)-1] != "TEST_SERVICE_HOST=elsewhere" {
+		t.Errorf("unexpected environment: %#v", env)
+	}
+}
+
+func TestRunContainerServiceListError(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.serviceLister = &fakeServiceLister{err: fmt.Errorf("apiserver down")}
+	pod := &Pod{Name: "foo", Namespace: "test"}
+	container := &api.Container{Name: "bar", Env: []api.EnvVar{{Name: "foo", Value: "bar"}}}
+	// The container doesn't start without the variables of services.
+	if _, err := kubelet.runContainer(pod, container, nil, ""); err == nil {
+		t.Errorf("expected an error")
+	}
+	if len(fakeDocker.createOptions) != 0 {
+		t.Errorf("unexpected containers created: %#v", fakeDocker.createOptions)
+	}
+}
+
+func TestCachedServiceLister(t *testing.T) {
+	failing := NewCachedServiceLister(&fakeServiceLister{err: fmt.Errorf("apiserver down")}, time.Hour)
+	if _, err := failing.ListServices(labels.Everything()); err == nil {
+		t.Errorf("expected an error before the services were listed")
+	}
+
+	lister := NewCachedServiceLister(&fakeServiceLister{services: []api.Service{
+		{JSONBase: api.JSONBase{ID: "web"}, Labels: map[string]string{"tier": "frontend"}},
+		{JSONBase: api.JSONBase{ID: "db"}},
+	}}, time.Hour)
+	var list api.ServiceList
+	err := wait.Poll(time.Millisecond, time.Second, func() (bool, error) {
+		var err error
+		list, err = lister.ListServices(labels.Everything())
+		return err == nil, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0].ID != "db" || list.Items[1].ID != "web" {
+		t.Errorf("expected db and web, got %#v", list.Items)
+	}
+	list, err = lister.ListServices(labels.Set{"tier": "frontend"}.AsSelector())
+	if err != nil || len(list.Items) != 1 || list.Items[0].ID != "web" {
+		t.Errorf("expected web, got %#v (%v)", list.Items, err)
+	}
+}
@@ -18,6 +18,7 @@ package kubelet
 
 import (
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/fsouza/go-dockerclient"
@@ -27,12 +28,33 @@ import (
 type FakeDockerClient struct {
 	lock          sync.Mutex
 	containerList []docker.APIContainers
-	container     *docker.Container
-	err           error
-	called        []string
-	stopped       []string
-	pulled        []string
-	Created       []string
+	// Only listed when all containers, not just running ones, are asked for.
+	exitedContainerList []docker.APIContainers
+	container           *docker.Container
+	// If set, what InspectContainer returns for the containers in it.
+	containerMap map[string]*docker.Container
+	err          error
+	called       []string
+	stopped      []string
+	// The timeout of every StopContainer call.
+	stopTimeouts []uint
+	pulled       []string
+	Created      []string
+	// What Info returns; nil means an empty Env.
+	info *docker.Env
+	// The options of every CreateContainer call.
+	createOptions []docker.CreateContainerOptions
+	// The host config of every StartContainer call.
+	hostConfigs   []*docker.HostConfig
+	images        []docker.APIImages
+	removedImages []string
+	removed       []string
+	pulledAuths   []docker.AuthConfiguration
+	// The images InspectImage finds.
+	presentImages []string
+	// What Logs writes to the output stream, and the options of every call.
+	logs        string
+	logsOptions []docker.LogsOptions
 }
 
 func (f *FakeDockerClient) clearCalls() {
@@ -47,6 +69,9 @@ func (f *FakeDockerClient) ListContainers(options docker.ListContainersOptions)
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	f.called = append(f.called, "list")
+	if options.All {
+		return append(append([]docker.APIContainers{}, f.containerList...), f.exitedContainerList...), f.err
+	}
 	return f.containerList, f.err
 }
 
@@ -56,6 +81,9 @@ func (f *FakeDockerClient) InspectContainer(id string) (*docker.Container, error
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	f.called = append(f.called, "inspect")
+	if container, ok := f.containerMap[id]; ok {
+		return container, f.err
+	}
 	return f.container, f.err
 }
 
@@ -66,6 +94,7 @@ func (f *FakeDockerClient) CreateContainer(c docker.CreateContainerOptions) (*do
 	defer f.lock.Unlock()
 	f.called = append(f.called, "create")
 	f.Created = append(f.Created, c.Name)
+	f.createOptions = append(f.createOptions, c)
 	// This is not a very good fake. We'll just add this container's name to the list.
 	// Docker likes to add a '/', so copy that behavior.
 	name := "/" + c.Name
@@ -79,6 +108,7 @@ func (f *FakeDockerClient) StartContainer(id string, hostConfig *docker.HostConf
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	f.called = append(f.called, "start")
+	f.hostConfigs = append(f.hostConfigs, hostConfig)
 	return f.err
 }
 
@@ -89,6 +119,7 @@ func (f *FakeDockerClient) StopContainer(id string, timeout uint) error {
 	defer f.lock.Unlock()
 	f.called = append(f.called, "stop")
 	f.stopped = append(f.stopped, id)
+	f.stopTimeouts = append(f.stopTimeouts, timeout)
 	var newList []docker.APIContainers
 	for _, container := range f.containerList {
 		if container.ID != id {
@@ -106,9 +137,82 @@ func (f *FakeDockerClient) PullImage(opts docker.PullImageOptions, auth docker.A
 	defer f.lock.Unlock()
 	f.called = append(f.called, "pull")
 	f.pulled = append(f.pulled, fmt.Sprintf("%s/%s:%s", opts.Repository, opts.Registry, opts.Tag))
+	f.pulledAuths = append(f.pulledAuths, auth)
+	return f.err
+}
+
+// Info is a test-spy implementation of DockerInterface.Info.
+// It adds an entry "info" to the internal method call record.
+func (f *FakeDockerClient) Info() (*docker.Env, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.called = append(f.called, "info")
+	if f.info == nil {
+		return &docker.Env{}, f.err
+	}
+	return f.info, f.err
+}
+
+// ListImages is a test-spy implementation of DockerInterface.ListImages.
+// It adds an entry "list_images" to the internal method call record.
+func (f *FakeDockerClient) ListImages(all bool) ([]docker.APIImages, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.called = append(f.called, "list_images")
+	return f.images, f.err
+}
+
+// RemoveImage is a test-spy implementation of DockerInterface.RemoveImage.
+// It adds an entry "remove_image" to the internal method call record.
+func (f *FakeDockerClient) RemoveImage(name string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.called = append(f.called, "remove_image")
+	f.removedImages = append(f.removedImages, name)
+	return f.err
+}
+
+// RemoveContainer is a test-spy implementation of DockerInterface.RemoveContainer.
+// It adds an entry "remove" to the internal method call record.
+func (f *FakeDockerClient) RemoveContainer(opts docker.RemoveContainerOptions) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.called = append(f.called, "remove")
+	f.removed = append(f.removed, opts.ID)
 	return f.err
 }
 
+// InspectImage is a test-spy implementation of DockerInterface.InspectImage.
+// It adds an entry "inspect_image" to the internal method call record.
+func (f *FakeDockerClient) InspectImage(name string) (*docker.Image, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.called = append(f.called, "inspect_image")
+	if f.err != nil {
+		return nil, f.err
+	}
+	for _, image := range f.presentImages {
+		if image == name {
+			return &docker.Image{ID: name}, nil
+		}
+	}
+	return nil, docker.ErrNoSuchImage
+}
+
+// Logs is a test-spy implementation of DockerInterface.Logs.
+// It adds an entry "logs" to the internal method call record.
+func (f *FakeDockerClient) Logs(opts docker.LogsOptions) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.called = append(f.called, "logs")
+	f.logsOptions = append(f.logsOptions, opts)
+	if f.err != nil {
+		return f.err
+	}
+	_, err := io.WriteString(opts.OutputStream, f.logs)
+	return err
+}
+
 // FakeDockerPuller is a stub implementation of DockerPuller.
 type FakeDockerPuller struct {
 	lock         sync.Mutex
@@ -117,6 +221,9 @@ type FakeDockerPuller struct {
 	// Every pull will return the first error here, and then reslice
 	// to remove it. Will give nil errors if this slice is empty.
 	ErrorsToInject []error
+
+	// The images IsImagePresent finds.
+	PresentImages []string
 }
 
 // Pull records the image pull attempt, and optionally injects an error.
@@ -131,3 +238,15 @@ func (f *FakeDockerPuller) Pull(image string) (err error) {
 	}
 	return err
 }
+
+// IsImagePresent returns true if image is one of PresentImages.
+func (f *FakeDockerPuller) IsImagePresent(image string) (bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, present := range f.PresentImages {
+		if present == image {
+			return true, nil
+		}
+	}
+	return false, nil
+}
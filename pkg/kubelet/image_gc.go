@@ -0,0 +1,204 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+)
+
+// ImageGCPolicy says when unused images are removed.
+type ImageGCPolicy struct {
+	// Images are removed once more than this percentage of the disk is in use...
+	HighThresholdPercent int
+	// ...until the disk is at most this full again.
+	LowThresholdPercent int
+}
+
+// ImageGarbageCollector removes the least recently used images which no container
+// uses when the disk docker keeps its images on fills up.
+type ImageGarbageCollector struct {
+	client DockerInterface
+	policy ImageGCPolicy
+	// diskUsage returns the used and total bytes of the disk images are on.
+	diskUsage func() (used, total uint64, err error)
+	now       func() time.Time
+
+	lock sync.Mutex
+	// lastUsed is when an image was last seen used by a container, or when it was
+	// first seen if no container used it yet, keyed by image ID.
+	lastUsed map[string]time.Time
+}
+
+// NewImageGarbageCollector makes a new ImageGarbageCollector for the images of
+// client, which docker keeps on the same disk as dockerRoot.
+func NewImageGarbageCollector(client DockerInterface, dockerRoot string, policy ImageGCPolicy) (*ImageGarbageCollector, error) {
+	if policy.HighThresholdPercent < 0 || policy.HighThresholdPercent > 100 {
+		return nil, fmt.Errorf("invalid high threshold %d, must be between 0 and 100", policy.HighThresholdPercent)
+	}
+	if policy.LowThresholdPercent < 0 || policy.LowThresholdPercent > policy.HighThresholdPercent {
+		return nil, fmt.Errorf("invalid low threshold %d, must be between 0 and the high threshold %d", policy.LowThresholdPercent, policy.HighThresholdPercent)
+	}
+	return &ImageGarbageCollector{
+		client:    client,
+		policy:    policy,
+		diskUsage: func() (uint64, uint64, error) { return statfsUsage(dockerRoot) },
+		now:       time.Now,
+		lastUsed:  map[string]time.Time{},
+	}, nil
+}
+
+// statfsUsage returns the used and total bytes of the file system path is on.
+// Like getFilesystemStats, it fails on other platforms than linux.
+func statfsUsage(path string) (used, total uint64, err error) {
+	stats, err := getFilesystemStats(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	return stats.UsedBytes, stats.CapacityBytes, nil
+}
+
+// GarbageCollect notes which images are in use, and if the disk is fuller than
+// the high threshold removes unused images, least recently used first, until
+// it is expected to be no fuller than the low threshold. Meant to be called
+// periodically, e.g. via util.Forever.
+func (gc *ImageGarbageCollector) GarbageCollect() error {
+	images, err := gc.detectImages()
+	if err != nil {
+		return err
+	}
+	used, total, err := gc.diskUsage()
+	if err != nil {
+		return err
+	}
+	if total == 0 || used*100 <= uint64(gc.policy.HighThresholdPercent)*total {
+		return nil
+	}
+	toFree := int64(used - uint64(gc.policy.LowThresholdPercent)*total/100)
+	glog.Infof("Disk is %d%% full, above the image GC threshold of %d%%. Trying to free %d bytes.", used*100/total, gc.policy.HighThresholdPercent, toFree)
+
+	freed := int64(0)
+	var errs []error
+	for _, image := range images {
+		if freed >= toFree {
+			break
+		}
+		if err := gc.removeImage(image); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		freed += image.Size
+	}
+	if freed < toFree {
+		errs = append(errs, fmt.Errorf("freed only %d of %d bytes by removing unused images", freed, toFree))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("image garbage collection failed: %v", errs)
+	}
+	return nil
+}
+
+// detectImages records which images are in use, and returns the unused ones,
+// least recently used first.
+func (gc *ImageGarbageCollector) detectImages() ([]docker.APIImages, error) {
+	images, err := gc.client.ListImages(false)
+	if err != nil {
+		return nil, err
+	}
+	// Exited containers keep their images too.
+	containers, err := gc.client.ListContainers(docker.ListContainersOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	inUse := map[string]bool{}
+	for _, container := range containers {
+		inspected, err := gc.client.InspectContainer(container.ID)
+		if err != nil {
+			return nil, err
+		}
+		if inspected != nil {
+			inUse[inspected.Image] = true
+		}
+	}
+
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	now := gc.now()
+	existing := map[string]bool{}
+	unused := []docker.APIImages{}
+	for _, image := range images {
+		existing[image.ID] = true
+		if _, ok := gc.lastUsed[image.ID]; !ok || inUse[image.ID] {
+			gc.lastUsed[image.ID] = now
+		}
+		if !inUse[image.ID] {
+			unused = append(unused, image)
+		}
+	}
+	for id := range gc.lastUsed {
+		if !existing[id] {
+			delete(gc.lastUsed, id)
+		}
+	}
+	sort.Sort(byLastUsed{unused, gc.lastUsed})
+	return unused, nil
+}
+
+// removeImage removes image by removing each of its tags; an untagged image is
+// removed by its ID.
+func (gc *ImageGarbageCollector) removeImage(image docker.APIImages) error {
+	names := []string{}
+	for _, tag := range image.RepoTags {
+		if tag != "<none>:<none>" {
+			names = append(names, tag)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, image.ID)
+	}
+	for _, name := range names {
+		glog.Infof("Removing unused image %s", name)
+		if err := gc.client.RemoveImage(name); err != nil {
+			return fmt.Errorf("failed to remove image %s: %v", name, err)
+		}
+	}
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	delete(gc.lastUsed, image.ID)
+	return nil
+}
+
+// byLastUsed sorts images by when they were last used, oldest first.
+type byLastUsed struct {
+	images   []docker.APIImages
+	lastUsed map[string]time.Time
+}
+
+func (b byLastUsed) Len() int      { return len(b.images) }
+func (b byLastUsed) Swap(i, j int) { b.images[i], b.images[j] = b.images[j], b.images[i] }
+func (b byLastUsed) Less(i, j int) bool {
+	ti, tj := b.lastUsed[b.images[i].ID], b.lastUsed[b.images[j].ID]
+	if ti.Equal(tj) {
+		return b.images[i].Created < b.images[j].Created
+	}
+	return ti.Before(tj)
+}
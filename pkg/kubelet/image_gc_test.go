@@ -0,0 +1,123 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func newTestImageGarbageCollector(t *testing.T, fakeDocker *FakeDockerClient, used *uint64) *ImageGarbageCollector {
+	gc, err := NewImageGarbageCollector(fakeDocker, "/", ImageGCPolicy{HighThresholdPercent: 90, LowThresholdPercent: 80})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	gc.diskUsage = func() (uint64, uint64, error) { return *used, 1000, nil }
+	return gc
+}
+
+func TestImageGarbageCollectorBelowThreshold(t *testing.T) {
+	fakeDocker := &FakeDockerClient{
+		images: []docker.APIImages{{ID: "unused", RepoTags: []string{"foo:latest"}, Size: 500}},
+	}
+	used := uint64(900)
+	gc := newTestImageGarbageCollector(t, fakeDocker, &used)
+	if err := gc.GarbageCollect(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(fakeDocker.removedImages) != 0 {
+		t.Errorf("unexpected removed images: %v", fakeDocker.removedImages)
+	}
+}
+
+func TestImageGarbageCollectorRemovesLeastRecentlyUsed(t *testing.T) {
+	fakeDocker := &FakeDockerClient{
+		images: []docker.APIImages{
+			{ID: "running", RepoTags: []string{"running:latest"}, Size: 100},
+			{ID: "exited", RepoTags: []string{"exited:latest"}, Size: 100},
+			{ID: "recent", RepoTags: []string{"recent:latest"}, Size: 100},
+			{ID: "old", RepoTags: []string{"old:latest", "old:v1"}, Size: 50},
+			{ID: "untagged", RepoTags: []string{"<none>:<none>"}, Size: 50, Created: 1},
+		},
+		containerList:       []docker.APIContainers{{ID: "1"}},
+		exitedContainerList: []docker.APIContainers{{ID: "2"}, {ID: "3"}},
+		containerMap: map[string]*docker.Container{
+			"1": {Image: "running"},
+			"2": {Image: "exited"},
+			"3": {Image: "recent"},
+		},
+	}
+	used := uint64(0)
+	gc := newTestImageGarbageCollector(t, fakeDocker, &used)
+	now := time.Unix(100, 0)
+	gc.now = func() time.Time { return now }
+	if err := gc.GarbageCollect(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := gc.GarbageCollect(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// The container using "recent" goes away, but "recent" was used more
+	// recently than the other unused images.
+	fakeDocker.exitedContainerList = fakeDocker.exitedContainerList[:1]
+	now = now.Add(time.Minute)
+	used = 950
+	if err := gc.GarbageCollect(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// 150 bytes have to be freed.
+	expected := []string{"old:latest", "old:v1", "untagged", "recent:latest"}
+	if !reflect.DeepEqual(fakeDocker.removedImages, expected) {
+		t.Errorf("expected %v to be removed, got %v", expected, fakeDocker.removedImages)
+	}
+	if _, ok := gc.lastUsed["old"]; ok {
+		t.Errorf("expected removed image to be forgotten")
+	}
+}
+
+func TestImageGarbageCollectorNotEnoughFreed(t *testing.T) {
+	fakeDocker := &FakeDockerClient{
+		images: []docker.APIImages{{ID: "unused", Size: 10}},
+	}
+	used := uint64(1000)
+	gc := newTestImageGarbageCollector(t, fakeDocker, &used)
+	if err := gc.GarbageCollect(); err == nil {
+		t.Errorf("expected an error")
+	}
+	if !reflect.DeepEqual(fakeDocker.removedImages, []string{"unused"}) {
+		t.Errorf("unexpected removed images: %v", fakeDocker.removedImages)
+	}
+}
+
+func TestNewImageGarbageCollectorInvalidPolicy(t *testing.T) {
+	policies := []ImageGCPolicy{
+		{HighThresholdPercent: 101, LowThresholdPercent: 80},
+		{HighThresholdPercent: 80, LowThresholdPercent: 90},
+		{HighThresholdPercent: 80, LowThresholdPercent: -1},
+	}
+	for _, policy := range policies {
+		if _, err := NewImageGarbageCollector(&FakeDockerClient{}, "/", policy); err == nil {
+			t.Errorf("expected policy %#v to be invalid", policy)
+		}
+	}
+}
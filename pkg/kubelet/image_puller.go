@@ -0,0 +1,118 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	// How long pulling an image is backed off after its first failure. Every
+	// further failure doubles that, up to maxPullBackoff.
+	initialPullBackoff = 10 * time.Second
+	maxPullBackoff     = 5 * time.Minute
+)
+
+// coordinatedPuller is a DockerPuller which lets concurrent pulls of an image
+// share one pull, and refuses to pull an image again too soon after pulling it
+// failed, so that pods whose images can't be pulled don't hammer the registry.
+type coordinatedPuller struct {
+	puller DockerPuller
+	now    func() time.Time
+
+	lock sync.Mutex
+	// The pulls in progress, keyed by image.
+	pulls map[string]*pull
+	// The backoff of the images whose last pull failed, keyed by image.
+	backoffs map[string]*pullBackoff
+}
+
+// pull is a pull in progress; done is closed once err is set.
+type pull struct {
+	done chan struct{}
+	err  error
+	// The number of other Pull calls waiting for this one, which share its
+	// result instead of pulling themselves.
+	waiters int
+}
+
+type pullBackoff struct {
+	until  time.Time
+	period time.Duration
+}
+
+func newCoordinatedPuller(puller DockerPuller) *coordinatedPuller {
+	return &coordinatedPuller{
+		puller:   puller,
+		now:      time.Now,
+		pulls:    map[string]*pull{},
+		backoffs: map[string]*pullBackoff{},
+	}
+}
+
+// Pull implements DockerPuller.
+func (p *coordinatedPuller) Pull(image string) error {
+	p.lock.Lock()
+	if current, ok := p.pulls[image]; ok {
+		current.waiters++
+		p.lock.Unlock()
+		<-current.done
+		return current.err
+	}
+	if backoff, ok := p.backoffs[image]; ok && p.now().Before(backoff.until) {
+		p.lock.Unlock()
+		return fmt.Errorf("backing off pulling image %s until %v", image, backoff.until)
+	}
+	current := &pull{done: make(chan struct{})}
+	p.pulls[image] = current
+	p.lock.Unlock()
+
+	current.err = p.puller.Pull(image)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.pulls, image)
+	close(current.done)
+	if current.waiters > 0 {
+		glog.V(4).Infof("Pull of image %s was shared with %d other pulls", image, current.waiters)
+	}
+	if current.err == nil {
+		delete(p.backoffs, image)
+		return nil
+	}
+	backoff, ok := p.backoffs[image]
+	if !ok {
+		backoff = &pullBackoff{period: initialPullBackoff}
+		p.backoffs[image] = backoff
+	} else {
+		backoff.period *= 2
+		if backoff.period > maxPullBackoff {
+			backoff.period = maxPullBackoff
+		}
+	}
+	backoff.until = p.now().Add(backoff.period)
+	return current.err
+}
+
+// IsImagePresent implements DockerPuller.
+func (p *coordinatedPuller) IsImagePresent(image string) (bool, error) {
+	return p.puller.IsImagePresent(image)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingPuller counts pulls, which block until release is closed.
+type blockingPuller struct {
+	FakeDockerPuller
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingPuller) Pull(image string) error {
+	b.started <- struct{}{}
+	<-b.release
+	return b.FakeDockerPuller.Pull(image)
+}
+
+func TestCoordinatedPullerSharesConcurrentPulls(t *testing.T) {
+	fake := &blockingPuller{started: make(chan struct{}, 10), release: make(chan struct{})}
+	puller := newCoordinatedPuller(fake)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := puller.Pull("foo"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}()
+	<-fake.started
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := puller.Pull("foo"); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	// Wait for the other pulls to join the one in progress.
+	for {
+		puller.lock.Lock()
+		joined := puller.pulls["foo"].waiters == 3
+		puller.lock.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(fake.release)
+	wg.Wait()
+
+	if len(fake.ImagesPulled) != 1 {
+		t.Errorf("expected one pull, got %v", fake.ImagesPulled)
+	}
+}
+
+func TestCoordinatedPullerBacksOff(t *testing.T) {
+	pullErr := errors.New("pull failed")
+	fake := &FakeDockerPuller{ErrorsToInject: []error{pullErr, pullErr}}
+	puller := newCoordinatedPuller(fake)
+	now := time.Unix(100, 0)
+	puller.now = func() time.Time { return now }
+
+	if err := puller.Pull("foo"); err != pullErr {
+		t.Errorf("expected %v, got %v", pullErr, err)
+	}
+	if err := puller.Pull("foo"); err == nil || err == pullErr {
+		t.Errorf("expected a backoff error, got %v", err)
+	}
+	if err := puller.Pull("bar"); err != pullErr {
+		t.Errorf("expected other images not to be backed off, got %v", err)
+	}
+
+	now = now.Add(initialPullBackoff)
+	if err := puller.Pull("foo"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, ok := puller.backoffs["foo"]; ok {
+		t.Errorf("expected a successful pull to reset the backoff")
+	}
+	expected := []string{"foo", "bar", "foo"}
+	if len(fake.ImagesPulled) != len(expected) {
+		t.Errorf("expected pulls %v, got %v", expected, fake.ImagesPulled)
+	}
+}
+
+func TestCoordinatedPullerBackoffDoubles(t *testing.T) {
+	pullErr := errors.New("pull failed")
+	fake := &FakeDockerPuller{ErrorsToInject: []error{pullErr, pullErr, pullErr}}
+	puller := newCoordinatedPuller(fake)
+	now := time.Unix(100, 0)
+	puller.now = func() time.Time { return now }
+
+	for _, expected := range []time.Duration{initialPullBackoff, 2 * initialPullBackoff, 4 * initialPullBackoff} {
+		puller.Pull("foo")
+		if backoff := puller.backoffs["foo"]; backoff.period != expected || !backoff.until.Equal(now.Add(expected)) {
+			t.Errorf("expected backoff of %v, got %#v", expected, backoff)
+		}
+		now = now.Add(expected)
+	}
+}
@@ -17,10 +17,15 @@ limitations under the License.
 package kubelet
 
 import (
-	"encoding/json"
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"os/exec"
 	"path"
 	"strconv"
 	"strings"
@@ -28,11 +33,12 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/health"
-	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/version"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
-	"github.com/coreos/go-etcd/etcd"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/golang/glog"
 	"github.com/google/cadvisor/info"
@@ -56,6 +62,9 @@ type SyncHandler interface {
 	SyncPods([]Pod) error
 }
 
+// SourcesReadyFn returns true once every configuration source has delivered its pods.
+type SourcesReadyFn func() bool
+
 type volumeMap map[string]volume.Interface
 
 // New creates a new Kubelet for use in main
@@ -63,18 +72,37 @@ func NewMainKubelet(
 	hn string,
 	dc DockerInterface,
 	cc CadvisorInterface,
-	ec tools.EtcdClient,
 	rd string,
-	ri time.Duration) *Kubelet {
+	ri time.Duration,
+	cr ContainerCommandRunner,
+	sr SourcesReadyFn,
+	clusterDNS net.IP,
+	clusterDomain string,
+	np NetworkPlugin,
+	ml map[string]string,
+	sl ServiceLister,
+	noPortals bool) *Kubelet {
+	if cr == nil {
+		cr = NewDockerContainerCommandRunner()
+	}
+	if np == nil {
+		np = NewNoopNetworkPlugin()
+	}
 	return &Kubelet{
 		hostname:       hn,
 		dockerClient:   dc,
 		cadvisorClient: cc,
-		etcdClient:     ec,
 		rootDirectory:  rd,
 		resyncInterval: ri,
 		podWorkers:     newPodWorkers(),
-		runner:         NewDockerContainerCommandRunner(),
+		runner:         cr,
+		sourcesReady:   sr,
+		clusterDNS:     clusterDNS,
+		clusterDomain:  clusterDomain,
+		networkPlugin:  np,
+		minionLabels:   ml,
+		serviceLister:  sl,
+		noPortals:      noPortals,
 	}
 }
 
@@ -87,11 +115,15 @@ func NewIntegrationTestKubelet(hn string, dc DockerInterface) *Kubelet {
 		dockerPuller:   &FakeDockerPuller{},
 		resyncInterval: 3 * time.Second,
 		podWorkers:     newPodWorkers(),
+		sourcesReady:   func() bool { return true },
 	}
 }
 
 type ContainerCommandRunner interface {
 	RunInContainer(containerID string, cmd []string) ([]byte, error)
+	// ExecInContainer runs cmd in the container, streaming stdin (if not nil) to
+	// it and its output to stdout and stderr until it exits.
+	ExecInContainer(containerID string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error
 }
 
 // Kubelet is the main kubelet implementation.
@@ -102,18 +134,514 @@ type Kubelet struct {
 	podWorkers     podWorkers
 	resyncInterval time.Duration
 
-	// Optional, no events will be sent without it
-	etcdClient tools.EtcdClient
 	// Optional, no statistics will be available if omitted
 	cadvisorClient CadvisorInterface
 	// Optional, defaults to simple implementaiton
 	healthChecker health.HealthChecker
+	// Counts the liveness probes containers failed in a row.
+	probeFailures probeFailureCounts
+	// Remembers which pods passed their readiness probes.
+	readiness podReadiness
+	// Remembers the containers which failed to start, to back off starting them.
+	startFailures startFailures
+	// Remembers the containers which crashed, to back off restarting them.
+	crashLoops crashLoops
+	// The pods of the last sync, as served by GetPods, and whether there was one.
+	podLock    sync.Mutex
+	pods       []Pod
+	podsSynced bool
+
+	// The last known spec of every pod with containers on this host, so that the
+	// containers of a deleted pod are still stopped the way it asked for. Only
+	// used by SyncPods. Lost when the kubelet restarts, after which containers of
+	// deleted pods are stopped with the defaults.
+	knownPods map[string]Pod
 	// Optional, defaults to simple Docker implementation
 	dockerPuller DockerPuller
 	// Optional, defaults to /logs/ from /var/log
 	logServer http.Handler
 	// Optional, defaults to simple Docker implementation
 	runner ContainerCommandRunner
+	// Optional, defaults to applyCgroupOOMScoreAdj, which sets the score of
+	// every process of the container. The OOM scores of containers are left
+	// alone if omitted.
+	oomScoreAdjuster func(pid, value int) error
+	// Optional, defaults to nsenterPortForwardCommand. Ports of pods can't be
+	// forwarded if omitted.
+	portForwardCommand func(pid int, port uint16) *exec.Cmd
+	// Optional: sets up the network of pods. Docker's network is left as it is
+	// if omitted.
+	networkPlugin NetworkPlugin
+
+	// Optional: the DNS server containers use instead of the host's.
+	clusterDNS net.IP
+	// Optional: the domain of the cluster, which containers search in addition
+	// to the host's search domains.
+	clusterDomain string
+	// Optional: the labels the minion registers with, for the NodeSelector of
+	// pods to match.
+	minionLabels map[string]string
+	// Optional: lists the services whose environment variables containers get.
+	// Containers get none if omitted.
+	serviceLister ServiceLister
+	// Whether the proxy on this minion runs without iptables, so that portal IPs
+	// don't reach it, and containers find services at the host instead.
+	noPortals bool
+
+	// Whether every configuration source has delivered its pods. Until then a pod
+	// missing from the desired state may just not have been seen yet, so nothing is killed.
+	sourcesReady SourcesReadyFn
+
+	// The resource limits docker can enforce, found out when first needed.
+	limitSupportLock sync.Mutex
+	limitSupport     *resourceLimitSupport
+
+	// The memory of the machine in bytes, found out when first needed.
+	machineMemoryLock sync.Mutex
+	machineMemory     int64
+}
+
+// probeFailureCounts counts the consecutive failed liveness probes of each
+// container. The zero value is ready to use.
+type probeFailureCounts struct {
+	lock   sync.Mutex
+	counts map[DockerID]int
+}
+
+// record records the result of probing container id, and returns the number
+// of probes it has failed in a row.
+func (p *probeFailureCounts) record(id DockerID, healthy bool) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if healthy {
+		delete(p.counts, id)
+		return 0
+	}
+	if p.counts == nil {
+		p.counts = map[DockerID]int{}
+	}
+	p.counts[id]++
+	return p.counts[id]
+}
+
+// forget drops the count of container id.
+func (p *probeFailureCounts) forget(id DockerID) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.counts, id)
+}
+
+// retain drops the counts of the containers not in running, e.g. because they
+// exited on their own.
+func (p *probeFailureCounts) retain(running DockerContainers) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for id := range p.counts {
+		if _, ok := running[id]; !ok {
+			delete(p.counts, id)
+		}
+	}
+}
+
+// podReadiness remembers whether each pod passed all of its readiness probes
+// when it was last synced. The zero value is ready to use.
+type podReadiness struct {
+	lock  sync.Mutex
+	ready map[string]bool
+}
+
+func (r *podReadiness) set(podFullName string, ready bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.ready == nil {
+		r.ready = map[string]bool{}
+	}
+	r.ready[podFullName] = ready
+}
+
+// get returns whether podFullName was ready; pods never synced are not.
+func (r *podReadiness) get(podFullName string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.ready[podFullName]
+}
+
+// retain forgets every pod not in podFullNames.
+func (r *podReadiness) retain(podFullNames util.StringSet) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for podFullName := range r.ready {
+		if !podFullNames.Has(podFullName) {
+			delete(r.ready, podFullName)
+		}
+	}
+}
+
+const (
+	// How long starting a container is backed off after it first failed. Every
+	// further failure doubles that, up to maxStartBackoff.
+	initialStartBackoff = 10 * time.Second
+	maxStartBackoff     = 5 * time.Minute
+)
+
+// startFailures remembers the containers which failed to start, keyed by the full
+// name of their pod and their name, so they are not started again at full speed.
+// The zero value is ready to use.
+type startFailures struct {
+	lock sync.Mutex
+	// Defaults to time.Now.
+	now     func() time.Time
+	waiting map[string]map[string]api.ContainerWaiting
+}
+
+func (f *startFailures) getNow() time.Time {
+	if f.now == nil {
+		return time.Now()
+	}
+	return f.now()
+}
+
+// record records that starting a container failed with err.
+func (f *startFailures) record(podFullName, containerName string, err error) api.ContainerWaiting {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.waiting == nil {
+		f.waiting = map[string]map[string]api.ContainerWaiting{}
+	}
+	containers, ok := f.waiting[podFullName]
+	if !ok {
+		containers = map[string]api.ContainerWaiting{}
+		f.waiting[podFullName] = containers
+	}
+	waiting := containers[containerName]
+	waiting.Failures++
+	waiting.Reason = ClassifyDockerError(err)
+	waiting.Message = err.Error()
+	backoff := initialStartBackoff
+	for i := 1; i < waiting.Failures && backoff < maxStartBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxStartBackoff {
+		backoff = maxStartBackoff
+	}
+	waiting.NextAttempt = util.Time{Time: f.getNow().Add(backoff)}
+	containers[containerName] = waiting
+	return waiting
+}
+
+// clear forgets the failures of a container which started.
+func (f *startFailures) clear(podFullName, containerName string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.waiting[podFullName], containerName)
+	if len(f.waiting[podFullName]) == 0 {
+		delete(f.waiting, podFullName)
+	}
+}
+
+// backingOff returns whether starting a container has to wait after its last
+// failure, and until when.
+func (f *startFailures) backingOff(podFullName, containerName string) (time.Time, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	waiting, ok := f.waiting[podFullName][containerName]
+	if !ok || !f.getNow().Before(waiting.NextAttempt.Time) {
+		return time.Time{}, false
+	}
+	return waiting.NextAttempt.Time, true
+}
+
+// get returns the containers of a pod which failed to start, or nil.
+func (f *startFailures) get(podFullName string) map[string]api.ContainerWaiting {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	containers, ok := f.waiting[podFullName]
+	if !ok {
+		return nil
+	}
+	result := map[string]api.ContainerWaiting{}
+	for name, waiting := range containers {
+		result[name] = waiting
+	}
+	return result
+}
+
+// retain forgets every pod not in podFullNames.
+func (f *startFailures) retain(podFullNames util.StringSet) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for podFullName := range f.waiting {
+		if !podFullNames.Has(podFullName) {
+			delete(f.waiting, podFullName)
+		}
+	}
+}
+
+// CrashLoopBackOff is the reason reported for containers which are not restarted
+// yet because they exited, or failed their liveness probes, again and again.
+const CrashLoopBackOff = "CrashLoopBackOff"
+
+const (
+	// How long restarting a container is backed off after its second crash in a
+	// row. Every further crash doubles that, up to maxCrashBackoff. A container
+	// which crashed only once is restarted right away.
+	initialCrashBackoff = 10 * time.Second
+	maxCrashBackoff     = 5 * time.Minute
+	// Containers which ran for this long before crashing start over with no backoff.
+	crashLoopResetPeriod = 10 * time.Minute
+)
+
+// crashLoop tracks the crashes in a row of a container.
+type crashLoop struct {
+	// The docker container which crashed last, so that every crash is counted once.
+	lastID      string
+	crashes     int
+	nextAttempt time.Time
+}
+
+// crashLoops remembers the containers which crashed, keyed by the full name of
+// their pod and their name, so they are not restarted again at full speed. The
+// zero value is ready to use.
+type crashLoops struct {
+	lock sync.Mutex
+	// Defaults to time.Now.
+	now   func() time.Time
+	loops map[string]map[string]*crashLoop
+}
+
+func (c *crashLoops) getNow() time.Time {
+	if c.now == nil {
+		return time.Now()
+	}
+	return c.now()
+}
+
+// record records that the docker container id of a container, which ran from
+// started to finished, crashed. It returns how long restarting the container
+// is backed off, and false if the crash was already recorded.
+func (c *crashLoops) record(podFullName, containerName, id string, started, finished time.Time) (time.Duration, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.loops == nil {
+		c.loops = map[string]map[string]*crashLoop{}
+	}
+	containers, ok := c.loops[podFullName]
+	if !ok {
+		containers = map[string]*crashLoop{}
+		c.loops[podFullName] = containers
+	}
+	loop, ok := containers[containerName]
+	if !ok {
+		loop = &crashLoop{}
+		containers[containerName] = loop
+	}
+	if loop.lastID == id {
+		return loop.nextAttempt.Sub(finished), false
+	}
+	if finished.Sub(started) >= crashLoopResetPeriod {
+		loop.crashes = 0
+	}
+	loop.lastID = id
+	loop.crashes++
+	var backoff time.Duration
+	if loop.crashes > 1 {
+		backoff = initialCrashBackoff
+		for i := 2; i < loop.crashes && backoff < maxCrashBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > maxCrashBackoff {
+			backoff = maxCrashBackoff
+		}
+	}
+	loop.nextAttempt = finished.Add(backoff)
+	return backoff, true
+}
+
+// backingOff returns whether restarting a container has to wait after its last
+// crash, and until when.
+func (c *crashLoops) backingOff(podFullName, containerName string) (time.Time, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	loop, ok := c.loops[podFullName][containerName]
+	if !ok || !c.getNow().Before(loop.nextAttempt) {
+		return time.Time{}, false
+	}
+	return loop.nextAttempt, true
+}
+
+// get returns the containers of a pod whose restart is backed off, or nil.
+func (c *crashLoops) get(podFullName string) map[string]api.ContainerWaiting {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var result map[string]api.ContainerWaiting
+	now := c.getNow()
+	for name, loop := range c.loops[podFullName] {
+		if !now.Before(loop.nextAttempt) {
+			continue
+		}
+		if result == nil {
+			result = map[string]api.ContainerWaiting{}
+		}
+		result[name] = api.ContainerWaiting{
+			Reason:      CrashLoopBackOff,
+			Message:     fmt.Sprintf("Container crashed %d times in a row", loop.crashes),
+			Failures:    loop.crashes,
+			NextAttempt: util.Time{Time: loop.nextAttempt},
+		}
+	}
+	return result
+}
+
+// retain forgets every pod not in podFullNames.
+func (c *crashLoops) retain(podFullNames util.StringSet) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for podFullName := range c.loops {
+		if !podFullNames.Has(podFullName) {
+			delete(c.loops, podFullName)
+		}
+	}
+}
+
+// The layout of the root directory of the kubelet, which holds everything the
+// kubelet keeps on disk, so that kubelets with different root directories don't
+// get in each other's way:
+//
+//	(ROOT_DIR)/pods/(POD_ID)/volumes/(VOLUME_KIND)/(VOLUME_NAME)
+//	(ROOT_DIR)/pods/(POD_ID)/containers/(CONTAINER_NAME)
+//	(ROOT_DIR)/plugins/(PLUGIN_NAME)
+//
+// POD_ID is the ID of the pod's manifest. The volume package builds the paths of
+// volumes the same way.
+
+func (kl *Kubelet) getRootDir() string {
+	return kl.rootDirectory
+}
+
+func (kl *Kubelet) getPodsDir() string {
+	return path.Join(kl.getRootDir(), "pods")
+}
+
+func (kl *Kubelet) getPluginsDir() string {
+	return path.Join(kl.getRootDir(), "plugins")
+}
+
+func (kl *Kubelet) getPodDir(podID string) string {
+	return path.Join(kl.getPodsDir(), podID)
+}
+
+func (kl *Kubelet) getPodVolumesDir(podID string) string {
+	return path.Join(kl.getPodDir(podID), "volumes")
+}
+
+// getPodContainerDir returns the directory of what the kubelet keeps about a
+// container of a pod.
+func (kl *Kubelet) getPodContainerDir(podID, containerName string) string {
+	return path.Join(kl.getPodDir(podID), "containers", containerName)
+}
+
+// SetupDataDirs creates the directories directly under the root directory, and
+// moves the directories of pods from where older kubelets kept them.
+func (kl *Kubelet) SetupDataDirs() error {
+	for _, dir := range []string{kl.getRootDir(), kl.getPodsDir(), kl.getPluginsDir()} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return fmt.Errorf("error creating directory %s: %v", dir, err)
+		}
+	}
+	return kl.migrateLegacyPodDirs()
+}
+
+// migrateLegacyPodDirs moves the (ROOT_DIR)/(POD_ID) directories older kubelets
+// kept volumes in to (ROOT_DIR)/pods/(POD_ID), so that the volumes of pods which
+// are still running are found, and cleaned up once the pods are deleted. Mounts
+// under a directory move along with it.
+func (kl *Kubelet) migrateLegacyPodDirs() error {
+	entries, err := ioutil.ReadDir(kl.getRootDir())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || name == "pods" || name == "plugins" {
+			continue
+		}
+		oldDir := path.Join(kl.getRootDir(), name)
+		if info, err := os.Stat(path.Join(oldDir, "volumes")); err != nil || !info.IsDir() {
+			continue
+		}
+		newDir := kl.getPodDir(name)
+		if _, err := os.Stat(newDir); err == nil {
+			glog.Warningf("Not moving %s, %s already exists", oldDir, newDir)
+			continue
+		}
+		glog.Infof("Moving pod directory %s to %s", oldDir, newDir)
+		if err := os.Rename(oldDir, newDir); err != nil {
+			glog.Errorf("Unable to move pod directory %s: %v", oldDir, err)
+		}
+	}
+	return nil
+}
+
+// makePodDataDirs creates the directories of pod and its containers.
+func (kl *Kubelet) makePodDataDirs(pod *Pod) error {
+	podID := pod.Manifest.ID
+	dirs := []string{kl.getPodDir(podID), kl.getPodVolumesDir(podID)}
+	for _, container := range pod.Manifest.Containers {
+		dirs = append(dirs, kl.getPodContainerDir(podID, container.Name))
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanupOrphanedPodDirs removes the directories of the pods which are not in
+// pods, as long as they hold nothing but directories. Whatever is left in them,
+// e.g. a volume which failed to tear down, keeps them around until a later
+// cleanup.
+func (kl *Kubelet) cleanupOrphanedPodDirs(pods []Pod) error {
+	desired := util.StringSet{}
+	for i := range pods {
+		desired.Insert(pods[i].Manifest.ID)
+	}
+	podDirs, err := ioutil.ReadDir(kl.getPodsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, podDir := range podDirs {
+		if !podDir.IsDir() || desired.Has(podDir.Name()) {
+			continue
+		}
+		dir := kl.getPodDir(podDir.Name())
+		if err := removeEmptyDirs(dir); err != nil {
+			glog.V(1).Infof("Keeping directory %s of deleted pod: %v", dir, err)
+		}
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir if it holds nothing but directories which hold
+// nothing but directories. Unlike os.RemoveAll it never removes files, and
+// never descends into mount points, whose directories aren't empty.
+func removeEmptyDirs(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			return fmt.Errorf("%s is not empty", dir)
+		}
+		if err := removeEmptyDirs(path.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return os.Remove(dir)
 }
 
 // Run starts the kubelet reacting to config updates
@@ -127,72 +655,205 @@ func (kl *Kubelet) Run(updates <-chan PodUpdate) {
 	if kl.healthChecker == nil {
 		kl.healthChecker = health.NewHealthChecker()
 	}
+	if kl.oomScoreAdjuster == nil {
+		kl.oomScoreAdjuster = applyCgroupOOMScoreAdj
+	}
+	if kl.portForwardCommand == nil {
+		kl.portForwardCommand = nsenterPortForwardCommand
+	}
 	kl.syncLoop(updates, kl)
 }
 
-// Per-pod workers.
+// RegisterMinion registers this host as a minion with the master. The master
+// treats every registration as a heartbeat, so this is meant to be called
+// periodically, e.g. via util.Forever.
+//
+// Every registration reports the minion's capacity, its addresses, the version of
+// the kubelet and whether the kubelet can run pods, so the master has a live view
+// of the minion without probing it.
+func (kl *Kubelet) RegisterMinion(c client.MinionInterface) {
+	minion := api.Minion{
+		JSONBase: api.JSONBase{ID: kl.hostname},
+		Labels:   kl.minionLabels,
+		Status: api.MinionStatus{
+			Condition:      api.MinionReady,
+			KubeletVersion: version.Get().String(),
+		},
+	}
+	if kl.cadvisorClient != nil {
+		if info, err := kl.cadvisorClient.MachineInfo(); err != nil {
+			glog.Errorf("Error getting machine info: %v", err)
+		} else {
+			minion.NodeResources.Capacity = api.ResourceList{
+				api.ResourceCPU:    int64(info.NumCores) * 1000,
+				api.ResourceMemory: info.MemoryCapacity,
+			}
+		}
+	}
+	if addresses, err := hostAddresses(); err != nil {
+		glog.Errorf("Error getting the addresses of the minion: %v", err)
+	} else {
+		minion.Status.Addresses = addresses
+	}
+	// Pods can't be run without docker.
+	if _, err := kl.dockerClient.Info(); err != nil {
+		glog.Errorf("Docker is unavailable, reporting minion %s as not ready: %v", kl.hostname, err)
+		minion.Status.Condition = api.MinionNotReady
+	}
+	if _, err := c.CreateMinion(minion); err != nil {
+		glog.Errorf("Failed to register minion %s with the master: %v", kl.hostname, err)
+	}
+}
+
+// interfaceAddrs lists the addresses of the host's network interfaces. It is a
+// variable for testing.
+var interfaceAddrs = net.InterfaceAddrs
+
+// hostAddresses returns the IP addresses of the host, other than loopback ones.
+func hostAddresses() ([]string, error) {
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	addresses := []string{}
+	for _, addr := range addrs {
+		ip, _, err := net.ParseCIDR(addr.String())
+		if err != nil || ip.IsLoopback() {
+			continue
+		}
+		addresses = append(addresses, ip.String())
+	}
+	return addresses, nil
+}
+
+// ReportPodStatus sends the master the container information of every pod the
+// master scheduled here. Meant to be called periodically, e.g. via util.Forever.
+func (kl *Kubelet) ReportPodStatus(c client.PodInterface) {
+	dockerContainers, err := getKubeletDockerContainers(kl.dockerClient)
+	if err != nil {
+		glog.Errorf("Error listing containers: %v", err)
+		return
+	}
+	reported := util.StringSet{}
+	for _, container := range dockerContainers {
+		podFullName, _, _ := parseDockerName(container.Names[0])
+		// Only pods from etcd were scheduled by the master.
+		if reported.Has(podFullName) || !strings.HasSuffix(podFullName, ".etcd") {
+			continue
+		}
+		reported.Insert(podFullName)
+		info, err := kl.GetPodInfo(podFullName)
+		if err != nil {
+			glog.Errorf("Error getting info for pod %s: %v", podFullName, err)
+			continue
+		}
+		report := api.PodStatusReport{
+			JSONBase: api.JSONBase{ID: strings.TrimSuffix(podFullName, ".etcd")},
+			Host:     kl.hostname,
+			Info:     info,
+			PodIP:    getPodIP(info),
+		}
+		if kl.readiness.get(podFullName) {
+			report.Conditions = []api.PodCondition{api.PodReady}
+		}
+		report.Waiting = kl.startFailures.get(podFullName)
+		for name, waiting := range kl.crashLoops.get(podFullName) {
+			if report.Waiting == nil {
+				report.Waiting = map[string]api.ContainerWaiting{}
+			}
+			// A container which fails to start tells more than one backed off after a crash.
+			if _, ok := report.Waiting[name]; !ok {
+				report.Waiting[name] = waiting
+			}
+		}
+		if err := c.ReportPodStatus(report); err != nil {
+			glog.Errorf("Failed to report status of pod %s: %v", podFullName, err)
+		}
+	}
+}
+
+// Per-pod workers. Every pod is synced by a goroutine of its own, so a pod
+// which takes long to sync, e.g. pulling a huge image, holds up no other pod.
 type podWorkers struct {
 	lock sync.Mutex
 
 	// Set of pods with existing workers.
 	workers util.StringSet
+	// The action each busy worker runs next.
+	pending map[string]func(waited bool)
 }
 
 func newPodWorkers() podWorkers {
 	return podWorkers{
 		workers: util.NewStringSet(),
+		pending: map[string]func(bool){},
 	}
 }
 
-// Runs a worker for "podFullName" asynchronously with the specified "action".
-// If the worker for the "podFullName" is already running, functions as a no-op.
-func (self *podWorkers) Run(podFullName string, action func()) {
+// Runs "action" for "podFullName" asynchronously in the pod's worker. Actions for
+// the same pod run one after another. If the worker is busy, "action" waits for
+// it, replacing any action already waiting, since only the latest desired state of
+// a pod matters. Actions are told whether they waited, as anything looked up for
+// them before then is out of date.
+func (self *podWorkers) Run(podFullName string, action func(waited bool)) {
 	self.lock.Lock()
 	defer self.lock.Unlock()
 
-	// This worker is already running, let it finish.
+	// This worker is already running, let it finish first.
 	if self.workers.Has(podFullName) {
+		self.pending[podFullName] = action
 		return
 	}
 	self.workers.Insert(podFullName)
 
 	// Run worker async.
 	go func() {
-		defer util.HandleCrash()
-		action()
+		waited := false
+		for {
+			func() {
+				defer util.HandleCrash()
+				action(waited)
+			}()
 
-		self.lock.Lock()
-		defer self.lock.Unlock()
-		self.workers.Delete(podFullName)
+			self.lock.Lock()
+			next, ok := self.pending[podFullName]
+			if !ok {
+				self.workers.Delete(podFullName)
+				self.lock.Unlock()
+				return
+			}
+			delete(self.pending, podFullName)
+			self.lock.Unlock()
+			action, waited = next, true
+		}
 	}()
 }
 
-// LogEvent logs an event to the etcd backend.
-func (kl *Kubelet) LogEvent(event *api.Event) error {
-	if kl.etcdClient == nil {
-		return fmt.Errorf("no etcd client connection")
-	}
-	event.Timestamp = time.Now().Unix()
-	data, err := json.Marshal(event)
-	if err != nil {
-		return err
+// parsePodFullName splits what GetPodFullName returns into the name and
+// namespace of the pod.
+func parsePodFullName(podFullName string) (name, namespace string) {
+	if i := strings.LastIndex(podFullName, "."); i >= 0 {
+		return podFullName[:i], podFullName[i+1:]
 	}
+	return podFullName, ""
+}
 
-	var response *etcd.Response
-	response, err = kl.etcdClient.AddChild(fmt.Sprintf("/events/%s", event.Container.Name), string(data), 60*60*48 /* 2 days */)
-	// TODO(bburns) : examine response here.
-	if err != nil {
-		glog.Errorf("Error writing event: %s\n", err)
-		if response != nil {
-			glog.Infof("Response was: %v\n", *response)
-		}
+// containerRef returns a reference to a container of the pod with the given
+// full name, for the events about it.
+func containerRef(podFullName, containerName string) api.ObjectReference {
+	podName, _ := parsePodFullName(podFullName)
+	return api.ObjectReference{
+		Kind:      "Pod",
+		ID:        podName,
+		FieldPath: fmt.Sprintf("desiredState.manifest.containers[%s]", containerName),
 	}
-	return err
 }
 
-func makeEnvironmentVariables(container *api.Container) []string {
+// makeEnvironmentVariables returns the environment of container, which starts
+// with the variables of services so that the container's own take precedence.
+func makeEnvironmentVariables(container *api.Container, serviceEnv []api.EnvVar) []string {
 	var result []string
-	for _, value := range container.Env {
+	for _, value := range append(append([]api.EnvVar{}, serviceEnv...), container.Env...) {
 		result = append(result, fmt.Sprintf("%s=%s", value.Name, value.Value))
 	}
 	return result
@@ -257,6 +918,49 @@ func makePortsAndBindings(container *api.Container) (map[docker.Port]struct{}, m
 	return exposedPorts, portBindings
 }
 
+// resolvConfPath is the DNS configuration of the host.
+var resolvConfPath = "/etc/resolv.conf"
+
+// getClusterDNS returns the DNS servers and search domains of pods, nil for
+// docker's defaults. Pods search the cluster domain first, then the domains the
+// host searches. The namespace of a Pod is the source it came from, not one
+// DNS names services in, so it isn't searched.
+func (kl *Kubelet) getClusterDNS() (dns, dnsSearch []string) {
+	if kl.clusterDNS != nil {
+		dns = []string{kl.clusterDNS.String()}
+	}
+	if kl.clusterDomain != "" {
+		dnsSearch = []string{kl.clusterDomain}
+		f, err := os.Open(resolvConfPath)
+		if err != nil {
+			glog.Errorf("Failed to read the search domains of the host: %v", err)
+			return
+		}
+		defer f.Close()
+		hostSearch, err := parseResolvConfSearch(f)
+		if err != nil {
+			glog.Errorf("Failed to read the search domains of the host: %v", err)
+			return
+		}
+		dnsSearch = append(dnsSearch, hostSearch...)
+	}
+	return
+}
+
+// parseResolvConfSearch returns the search domains of a resolv.conf file. Like
+// the resolver, it uses the last search line.
+func parseResolvConfSearch(r io.Reader) ([]string, error) {
+	var search []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && fields[0] == "search" {
+			search = fields[1:]
+		}
+	}
+	return search, scanner.Err()
+}
+
 func milliCPUToShares(milliCPU int) int {
 	if milliCPU == 0 {
 		// zero milliCPU means unset. Use kernel default.
@@ -293,10 +997,28 @@ func (kl *Kubelet) mountExternalVolumes(manifest *api.ContainerManifest) (volume
 
 // Run a single container from a pod. Returns the docker container ID
 func (kl *Kubelet) runContainer(pod *Pod, container *api.Container, podVolumes volumeMap, netMode string) (id DockerID, err error) {
-	envVariables := makeEnvironmentVariables(container)
+	ref := containerRef(GetPodFullName(pod), container.Name)
+	// Without the variables of every service, the container would start with
+	// an environment it never gets fixed; the next sync tries again.
+	serviceEnv, err := kl.getServiceEnvVars()
+	if err != nil {
+		return "", fmt.Errorf("failed to list the services for the environment of container %s: %v", container.Name, err)
+	}
+	envVariables := makeEnvironmentVariables(container, serviceEnv)
 	volumes, binds := makeVolumesAndBinds(pod, container, podVolumes)
 	exposedPorts, portBindings := makePortsAndBindings(container)
 
+	var memorySwap int64
+	if container.Memory > 0 {
+		support := kl.getResourceLimitSupport()
+		if !support.memory {
+			record.Eventf(ref, "limitNotEnforced", "The kernel doesn't support memory limits, container runs without its limit of %d bytes.", container.Memory)
+		} else if support.swap {
+			// Otherwise docker lets the container swap out as much again as its limit.
+			memorySwap = int64(container.Memory)
+		}
+	}
+
 	opts := docker.CreateContainerOptions{
 		Name: buildDockerName(pod, container),
 		Config: &docker.Config{
@@ -306,6 +1028,7 @@ func (kl *Kubelet) runContainer(pod *Pod, container *api.Container, podVolumes v
 			Hostname:     container.Name,
 			Image:        container.Image,
 			Memory:       int64(container.Memory),
+			MemorySwap:   memorySwap,
 			CpuShares:    int64(milliCPUToShares(container.CPU)),
 			Volumes:      volumes,
 			WorkingDir:   container.WorkingDir,
@@ -313,40 +1036,258 @@ func (kl *Kubelet) runContainer(pod *Pod, container *api.Container, podVolumes v
 	}
 	dockerContainer, err := kl.dockerClient.CreateContainer(opts)
 	if err != nil {
+		record.Eventf(ref, "failed", "Failed to create container with image %s: %v", container.Image, err)
 		return "", err
 	}
-	err = kl.dockerClient.StartContainer(dockerContainer.ID, &docker.HostConfig{
+	record.Eventf(ref, "created", "Created container %s with image %s", dockerContainer.ID, container.Image)
+	hostConfig := &docker.HostConfig{
 		PortBindings: portBindings,
 		Binds:        binds,
 		NetworkMode:  netMode,
-	})
-	return DockerID(dockerContainer.ID), err
+	}
+	// The other containers of the pod share the resolv.conf of its network container.
+	if container.Name == networkContainerName {
+		hostConfig.Dns, hostConfig.DnsSearch = kl.getClusterDNS()
+	}
+	err = kl.dockerClient.StartContainer(dockerContainer.ID, hostConfig)
+	if err != nil {
+		record.Eventf(ref, "failed", "Failed to start container %s: %v", dockerContainer.ID, err)
+		return DockerID(dockerContainer.ID), err
+	}
+	record.Eventf(ref, "started", "Started container %s", dockerContainer.ID)
+	kl.adjustOOMScore(dockerContainer.ID, container)
+	if container.Lifecycle != nil && container.Lifecycle.PostStart != nil {
+		podFullName := GetPodFullName(pod)
+		if err := kl.runHandler(podFullName, container, dockerContainer.ID, container.Lifecycle.PostStart); err != nil {
+			glog.Errorf("Post-start hook of pod %s container %s failed: %v", podFullName, container.Name, err)
+			record.Eventf(ref, "failed", "Post-start hook of container %s failed: %v", dockerContainer.ID, err)
+			// The next sync starts the container afresh.
+			kl.killContainer(pod, &docker.APIContainers{ID: dockerContainer.ID, Names: []string{"/" + opts.Name}})
+			return "", err
+		}
+		record.Eventf(ref, "postStart", "Ran post-start hook of container %s", dockerContainer.ID)
+	}
+	return DockerID(dockerContainer.ID), nil
 }
 
-// Kill a docker container
-func (kl *Kubelet) killContainer(dockerContainer *docker.APIContainers) error {
+// getResourceLimitSupport returns which resource limits docker can enforce,
+// asking docker the first time. Until docker answers, limits are assumed to work.
+func (kl *Kubelet) getResourceLimitSupport() resourceLimitSupport {
+	kl.limitSupportLock.Lock()
+	defer kl.limitSupportLock.Unlock()
+	if kl.limitSupport == nil {
+		support, err := getResourceLimitSupport(kl.dockerClient)
+		if err != nil {
+			glog.Errorf("Failed to find out which resource limits docker supports: %v", err)
+			return resourceLimitSupport{memory: true, swap: true}
+		}
+		if !support.memory {
+			glog.Warningf("The kernel doesn't support memory limits, containers will run without them.")
+		}
+		kl.limitSupport = &support
+	}
+	return *kl.limitSupport
+}
+
+// getPullPolicy returns the ImagePullPolicy of container, or its default: images
+// tagged "latest", or not tagged at all, change, so they are always pulled.
+func getPullPolicy(container *api.Container) api.PullPolicy {
+	if container.ImagePullPolicy != "" {
+		return container.ImagePullPolicy
+	}
+	if _, tag := parseImageName(container.Image); tag == "" || tag == "latest" {
+		return api.PullAlways
+	}
+	return api.PullIfNotPresent
+}
+
+// pullImage pulls the image of container, of the pod with the given full name,
+// if its pull policy says so.
+func (kl *Kubelet) pullImage(podFullName string, container *api.Container) error {
+	switch getPullPolicy(container) {
+	case api.PullNever:
+		return nil
+	case api.PullIfNotPresent:
+		present, err := kl.dockerPuller.IsImagePresent(container.Image)
+		if err != nil {
+			glog.Errorf("Failed to find out if image %s is present, pulling it: %v", container.Image, err)
+		}
+		if present {
+			return nil
+		}
+	}
+	ref := containerRef(podFullName, container.Name)
+	if err := kl.dockerPuller.Pull(container.Image); err != nil {
+		record.Eventf(ref, "failed", "Failed to pull image %s: %v", container.Image, err)
+		return err
+	}
+	record.Eventf(ref, "pulled", "Pulled image %s", container.Image)
+	return nil
+}
+
+// defaultTerminationGracePeriod is how long containers get to shut down when their
+// pod does not say.
+const defaultTerminationGracePeriod = 10 * time.Second
+
+// terminationGracePeriod returns how long the containers of pod, which may be nil,
+// get to shut down, pre-stop hooks included, before they are killed.
+func terminationGracePeriod(pod *Pod) time.Duration {
+	if pod == nil || pod.Manifest.TerminationGracePeriodSeconds == nil {
+		return defaultTerminationGracePeriod
+	}
+	return time.Duration(*pod.Manifest.TerminationGracePeriodSeconds) * time.Second
+}
+
+// adjustOOMScore sets the oom_score_adj of the running docker container
+// containerID according to the memory limit of container, so that the kernel
+// kills containers without limits first when the machine runs out of memory.
+func (kl *Kubelet) adjustOOMScore(containerID string, container *api.Container) {
+	if kl.oomScoreAdjuster == nil {
+		return
+	}
+	inspected, err := kl.dockerClient.InspectContainer(containerID)
+	if err != nil {
+		glog.Errorf("Failed to inspect container %s for its pid: %v", containerID, err)
+		return
+	}
+	if inspected.State.Pid == 0 {
+		// The container isn't running anymore.
+		return
+	}
+	value := containerOOMScoreAdj(container.Memory, kl.getMachineMemory())
+	if container.Name == networkContainerName {
+		value = networkContainerOOMScoreAdj
+	}
+	if err := kl.oomScoreAdjuster(inspected.State.Pid, value); err != nil {
+		glog.Errorf("Failed to set oom_score_adj of container %s to %d: %v", containerID, value, err)
+	}
+}
+
+// getMachineMemory returns the memory of the machine in bytes, or 0 if it
+// isn't known.
+func (kl *Kubelet) getMachineMemory() int64 {
+	kl.machineMemoryLock.Lock()
+	defer kl.machineMemoryLock.Unlock()
+	if kl.machineMemory == 0 && kl.cadvisorClient != nil {
+		info, err := kl.cadvisorClient.MachineInfo()
+		if err != nil {
+			glog.Errorf("Failed to get the memory of the machine: %v", err)
+			return 0
+		}
+		kl.machineMemory = info.MemoryCapacity
+	}
+	return kl.machineMemory
+}
+
+// Kill a docker container. If the spec of its pod is known, the container's
+// pre-stop hook is run first, and the container gets the pod's grace period to
+// shut down after SIGTERM before docker kills it.
+func (kl *Kubelet) killContainer(pod *Pod, dockerContainer *docker.APIContainers) error {
 	glog.Infof("Killing: %s", dockerContainer.ID)
-	err := kl.dockerClient.StopContainer(dockerContainer.ID, 10)
 	podFullName, containerName, _ := parseDockerName(dockerContainer.Names[0])
-	kl.LogEvent(&api.Event{
-		Event: "STOP",
-		Manifest: &api.ContainerManifest{
-			//TODO: This should be reported using either the apiserver schema or the kubelet schema
-			ID: podFullName,
-		},
-		Container: &api.Container{
-			Name: containerName,
-		},
-	})
-
+	ref := containerRef(podFullName, containerName)
+	grace := terminationGracePeriod(pod)
+	deadline := time.Now().Add(grace)
+	if pod != nil && grace > 0 {
+		for i := range pod.Manifest.Containers {
+			container := &pod.Manifest.Containers[i]
+			if container.Name == containerName && container.Lifecycle != nil && container.Lifecycle.PreStop != nil {
+				kl.runPreStopHook(podFullName, container, dockerContainer, grace)
+				break
+			}
+		}
+	}
+	if containerName == networkContainerName {
+		kl.tearDownPodNetwork(podFullName, dockerContainer.ID)
+	}
+	// The hook used up part of the grace period. Docker takes whole seconds, so
+	// round up what's left.
+	remaining := deadline.Sub(time.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	err := kl.dockerClient.StopContainer(dockerContainer.ID, uint((remaining+time.Second-1)/time.Second))
+	if err != nil {
+		record.Eventf(ref, "failed", "Failed to kill container %s: %v", dockerContainer.ID, err)
+	} else {
+		record.Eventf(ref, "killed", "Killed container %s", dockerContainer.ID)
+	}
 	return err
 }
 
+// runPreStopHook runs the pre-stop hook of container, giving up on it after
+// timeout. The container is stopped whatever the outcome, so failures are only
+// recorded.
+func (kl *Kubelet) runPreStopHook(podFullName string, container *api.Container, dockerContainer *docker.APIContainers, timeout time.Duration) {
+	ref := containerRef(podFullName, container.Name)
+	// A hook which overruns ends when its container is stopped.
+	done := make(chan error, 1)
+	go func() {
+		done <- kl.runHandler(podFullName, container, dockerContainer.ID, container.Lifecycle.PreStop)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		err = fmt.Errorf("timed out after %v", timeout)
+	}
+	if err != nil {
+		glog.Errorf("Pre-stop hook of pod %s container %s failed: %v", podFullName, container.Name, err)
+		record.Eventf(ref, "failed", "Pre-stop hook of container %s failed: %v", dockerContainer.ID, err)
+		return
+	}
+	record.Eventf(ref, "preStop", "Ran pre-stop hook of container %s", dockerContainer.ID)
+}
+
+// runHandler runs the lifecycle handler of container, running as the docker
+// container containerID.
+func (kl *Kubelet) runHandler(podFullName string, container *api.Container, containerID string, handler *api.Handler) error {
+	switch {
+	case handler.Exec != nil:
+		if kl.runner == nil {
+			return fmt.Errorf("no runner specified")
+		}
+		_, err := kl.runner.RunInContainer(containerID, handler.Exec.Command)
+		return err
+	case handler.HTTPGet != nil:
+		if kl.healthChecker == nil {
+			return fmt.Errorf("no health checker specified")
+		}
+		// The HTTP health checker resolves the port and host of a request the
+		// same way a handler needs them resolved.
+		podState := api.PodState{}
+		if info, err := kl.GetPodInfo(podFullName); err == nil {
+			podState.PodIP = getPodIP(info)
+		}
+		probed := *container
+		probed.LivenessProbe = &api.LivenessProbe{Type: "http", HTTPGet: handler.HTTPGet}
+		status, err := kl.healthChecker.HealthCheck(podFullName, podState, probed)
+		if err != nil {
+			return err
+		}
+		if status != health.Healthy {
+			return fmt.Errorf("request failed")
+		}
+		return nil
+	}
+	return fmt.Errorf("handler has no action")
+}
+
 const (
 	networkContainerName  = "net"
 	networkContainerImage = "kubernetes/pause:latest"
 )
 
+// getPodIP returns the IP address of the pod's network container, which every
+// container of the pod shares, or "" if info has none.
+func getPodIP(info api.PodInfo) string {
+	netInfo, found := info[networkContainerName]
+	if !found || netInfo.NetworkSettings == nil {
+		return ""
+	}
+	return netInfo.NetworkSettings.IPAddress
+}
+
 // createNetworkContainer starts the network container for a pod. Returns the docker container ID of the newly created container.
 func (kl *Kubelet) createNetworkContainer(pod *Pod) (DockerID, error) {
 	var ports []api.Port
@@ -364,6 +1305,55 @@ func (kl *Kubelet) createNetworkContainer(pod *Pod) (DockerID, error) {
 	return kl.runContainer(pod, container, nil, "")
 }
 
+// netnsPath returns the path of the network namespace of the running docker
+// container containerID.
+func (kl *Kubelet) netnsPath(containerID string) (string, error) {
+	inspected, err := kl.dockerClient.InspectContainer(containerID)
+	if err != nil {
+		return "", err
+	}
+	if inspected.State.Pid == 0 {
+		return "", fmt.Errorf("container %s is not running", containerID)
+	}
+	return fmt.Sprintf("/proc/%d/ns/net", inspected.State.Pid), nil
+}
+
+// setUpPodNetwork has the network plugin set up the network of pod, whose
+// network container containerID just started.
+func (kl *Kubelet) setUpPodNetwork(pod *Pod, containerID string) error {
+	if kl.networkPlugin == nil {
+		return nil
+	}
+	ref := containerRef(GetPodFullName(pod), networkContainerName)
+	path, err := kl.netnsPath(containerID)
+	if err == nil {
+		err = kl.networkPlugin.SetUpPod(pod.Namespace, pod.Name, containerID, path)
+	}
+	if err != nil {
+		record.Eventf(ref, "failed", "Network plugin %s failed to set up the pod network: %v", kl.networkPlugin.Name(), err)
+		return err
+	}
+	return nil
+}
+
+// tearDownPodNetwork has the network plugin tear down the network of the pod
+// with the given full name before its network container containerID stops.
+// The container is stopped whatever the outcome, so failures are only recorded.
+func (kl *Kubelet) tearDownPodNetwork(podFullName, containerID string) {
+	if kl.networkPlugin == nil {
+		return
+	}
+	path, err := kl.netnsPath(containerID)
+	if err == nil {
+		name, namespace := parsePodFullName(podFullName)
+		err = kl.networkPlugin.TearDownPod(namespace, name, containerID, path)
+	}
+	if err != nil {
+		glog.Errorf("Network plugin %s failed to tear down the network of pod %s: %v", kl.networkPlugin.Name(), podFullName, err)
+		record.Eventf(containerRef(podFullName, networkContainerName), "failed", "Network plugin %s failed to tear down the pod network: %v", kl.networkPlugin.Name(), err)
+	}
+}
+
 // Delete all containers in a pod (except the network container) returns the number of containers deleted
 // and an error if one occurs.
 func (kl *Kubelet) deleteAllContainers(pod *Pod, podFullName string, dockerContainers DockerContainers) (int, error) {
@@ -375,7 +1365,7 @@ func (kl *Kubelet) deleteAllContainers(pod *Pod, podFullName string, dockerConta
 			count++
 			wg.Add(1)
 			go func() {
-				err := kl.killContainer(dockerContainer)
+				err := kl.killContainer(pod, dockerContainer)
 				if err != nil {
 					glog.Errorf("Failed to delete container. (%v)  Skipping pod %s", err, podFullName)
 					errs <- err
@@ -396,6 +1386,33 @@ func (kl *Kubelet) deleteAllContainers(pod *Pod, podFullName string, dockerConta
 	return count, nil
 }
 
+// killContainers kills containers, all of which belong to pod, which may be nil.
+// The network container is killed last, so the others keep their network while
+// they shut down.
+func (kl *Kubelet) killContainers(pod *Pod, containers []*docker.APIContainers) {
+	var netContainer *docker.APIContainers
+	wg := sync.WaitGroup{}
+	for _, container := range containers {
+		if _, containerName, _ := parseDockerName(container.Names[0]); containerName == networkContainerName {
+			netContainer = container
+			continue
+		}
+		wg.Add(1)
+		go func(container *docker.APIContainers) {
+			defer wg.Done()
+			if err := kl.killContainer(pod, container); err != nil {
+				glog.Errorf("Error killing container: %v", err)
+			}
+		}(container)
+	}
+	wg.Wait()
+	if netContainer != nil {
+		if err := kl.killContainer(pod, netContainer); err != nil {
+			glog.Errorf("Error killing container: %v", err)
+		}
+	}
+}
+
 type empty struct{}
 
 func (kl *Kubelet) syncPod(pod *Pod, dockerContainers DockerContainers) error {
@@ -418,6 +1435,12 @@ func (kl *Kubelet) syncPod(pod *Pod, dockerContainers DockerContainers) error {
 			glog.Errorf("Failed to introspect network container. (%v)  Skipping pod %s", err, podFullName)
 			return err
 		}
+		if err := kl.setUpPodNetwork(pod, string(dockerNetworkID)); err != nil {
+			glog.Errorf("Failed to set up the network of pod %s: %v", podFullName, err)
+			// The next sync starts the network container afresh.
+			kl.killContainer(pod, &docker.APIContainers{ID: string(dockerNetworkID), Names: []string{"/" + buildDockerName(pod, &api.Container{Name: networkContainerName})}})
+			return err
+		}
 		netID = dockerNetworkID
 		if count > 0 {
 			// relist everything, otherwise we'll think we're ok
@@ -430,6 +1453,11 @@ func (kl *Kubelet) syncPod(pod *Pod, dockerContainers DockerContainers) error {
 	}
 	containersToKeep[netID] = empty{}
 
+	if err := kl.makePodDataDirs(pod); err != nil {
+		glog.Errorf("Unable to make the directories of pod %s: (%v) Skipping pod.", podFullName, err)
+		return err
+	}
+
 	podVolumes, err := kl.mountExternalVolumes(&pod.Manifest)
 	if err != nil {
 		glog.Errorf("Unable to mount volumes for pod %s: (%v) Skipping pod.", podFullName, err)
@@ -441,13 +1469,12 @@ func (kl *Kubelet) syncPod(pod *Pod, dockerContainers DockerContainers) error {
 	if err != nil {
 		glog.Errorf("Unable to get pod info, health checks may be invalid.")
 	}
-	netInfo, found := info[networkContainerName]
-	if found && netInfo.NetworkSettings != nil {
-		podState.PodIP = netInfo.NetworkSettings.IPAddress
-	}
+	podState.PodIP = getPodIP(info)
 
+	ready := true
 	for _, container := range pod.Manifest.Containers {
 		expectedHash := hashContainer(&container)
+		unhealthy := false
 		if dockerContainer, found, hash := dockerContainers.FindPodContainer(podFullName, container.Name); found {
 			containerID := DockerID(dockerContainer.ID)
 			glog.Infof("pod %s container %s exists as %v", podFullName, container.Name, containerID)
@@ -456,6 +1483,9 @@ func (kl *Kubelet) syncPod(pod *Pod, dockerContainers DockerContainers) error {
 			// look for changes in the container.
 			if hash == 0 || hash == expectedHash {
 				// TODO: This should probably be separated out into a separate goroutine.
+				if !kl.ready(podFullName, podState, container, dockerContainer) {
+					ready = false
+				}
 				healthy, err := kl.healthy(podFullName, podState, container, dockerContainer)
 				if err != nil {
 					glog.V(1).Infof("health check errored: %v", err)
@@ -463,33 +1493,77 @@ func (kl *Kubelet) syncPod(pod *Pod, dockerContainers DockerContainers) error {
 					continue
 				}
 				if healthy == health.Healthy {
+					kl.probeFailures.record(containerID, true)
+					containersToKeep[containerID] = empty{}
+					continue
+				}
+				failures := kl.probeFailures.record(containerID, false)
+				if failures < container.LivenessProbe.FailureThreshold {
+					glog.V(1).Infof("pod %s container %s failed %d of %d liveness probes.", podFullName, container.Name, failures, container.LivenessProbe.FailureThreshold)
 					containersToKeep[containerID] = empty{}
 					continue
 				}
-				glog.V(1).Infof("pod %s container %s is unhealthy.", podFullName, container.Name, healthy)
+				glog.V(1).Infof("pod %s container %s is unhealthy.", podFullName, container.Name)
+				record.Eventf(containerRef(podFullName, container.Name), "unhealthy", "Restarting container %s after %d failed liveness probes in a row.", containerID, failures)
+				// Restarting for failed probes counts as a crash, so a container which
+				// never gets healthy isn't restarted at full speed either.
+				now := kl.crashLoops.getNow()
+				kl.crashLoops.record(podFullName, container.Name, dockerContainer.ID, time.Unix(dockerContainer.Created, 0), now)
+				unhealthy = true
 			} else {
 				glog.V(1).Infof("container hash changed %d vs %d.", hash, expectedHash)
 			}
-			if err := kl.killContainer(dockerContainer); err != nil {
+			if err := kl.killContainer(pod, dockerContainer); err != nil {
 				glog.V(1).Infof("Failed to kill container %s: %v", dockerContainer.ID, err)
 				continue
 			}
+			kl.probeFailures.forget(containerID)
 			killedContainers[containerID] = empty{}
+			// info was taken while the container ran, so the policy check below
+			// doesn't see this exit. Killing it for failed probes is a failure.
+			if unhealthy && pod.Manifest.RestartPolicy.Type == api.RestartNever {
+				glog.V(1).Infof("pod %s container %s was killed as unhealthy, not restarting it under %s.", podFullName, container.Name, pod.Manifest.RestartPolicy.Type)
+				continue
+			}
 		}
 
+		// A container we are about to start hasn't passed its readiness probe yet.
+		if container.ReadinessProbe != nil {
+			ready = false
+		}
+		if last, ok := info[container.Name]; ok && !last.State.Running {
+			if !shouldRestart(pod.Manifest.RestartPolicy, expectedHash, &last) {
+				glog.V(1).Infof("pod %s container %s exited with %d, not restarting it under %s.", podFullName, container.Name, last.State.ExitCode, pod.Manifest.RestartPolicy.Type)
+				continue
+			}
+			if backoff, first := kl.crashLoops.record(podFullName, container.Name, last.ID, last.State.StartedAt, last.State.FinishedAt); first {
+				recordExit(podFullName, &container, &last, backoff)
+			}
+		}
+		if until, ok := kl.crashLoops.backingOff(podFullName, container.Name); ok {
+			glog.V(1).Infof("Backing off restarting pod %s container %s until %v.", podFullName, container.Name, until)
+			continue
+		}
+		if until, ok := kl.startFailures.backingOff(podFullName, container.Name); ok {
+			glog.V(1).Infof("Backing off starting pod %s container %s until %v.", podFullName, container.Name, until)
+			continue
+		}
 		glog.Infof("Container doesn't exist, creating %#v", container)
-		if err := kl.dockerPuller.Pull(container.Image); err != nil {
-			glog.Errorf("Failed to pull image %s: %v skipping pod %s container %s.", container.Image, err, podFullName, container.Name)
+		if err := kl.pullImage(podFullName, &container); err != nil {
+			waiting := kl.startFailures.record(podFullName, container.Name, err)
+			glog.Errorf("Failed to pull image %s: %v skipping pod %s container %s until %v.", container.Image, err, podFullName, container.Name, waiting.NextAttempt)
 			continue
 		}
 		containerID, err := kl.runContainer(pod, &container, podVolumes, "container:"+string(netID))
 		if err != nil {
-			// TODO(bburns) : Perhaps blacklist a container after N failures?
-			glog.Errorf("Error running pod %s container %s: %v", podFullName, container.Name, err)
+			waiting := kl.startFailures.record(podFullName, container.Name, err)
+			glog.Errorf("Error running pod %s container %s: %v, trying again after %v", podFullName, container.Name, err, waiting.NextAttempt)
 			continue
 		}
+		kl.startFailures.clear(podFullName, container.Name)
 		containersToKeep[containerID] = empty{}
 	}
+	kl.readiness.set(podFullName, ready)
 
 	// Kill any containers in this pod which were not identified above (guards against duplicates).
 	for id, container := range dockerContainers {
@@ -499,7 +1573,7 @@ func (kl *Kubelet) syncPod(pod *Pod, dockerContainers DockerContainers) error {
 			_, keep := containersToKeep[id]
 			_, killed := killedContainers[id]
 			if !keep && !killed {
-				err = kl.killContainer(container)
+				err = kl.killContainer(pod, container)
 				if err != nil {
 					glog.Errorf("Error killing container: %v", err)
 				}
@@ -510,6 +1584,25 @@ func (kl *Kubelet) syncPod(pod *Pod, dockerContainers DockerContainers) error {
 	return nil
 }
 
+// exitCodeKilled is the exit code of a process killed by SIGKILL.
+const exitCodeKilled = 128 + 9
+
+// recordExit records that container, last run as the docker container last, has
+// exited and is restarted after backoff. Containers with a memory limit which got
+// killed were most likely killed by the kernel for running out of memory.
+func recordExit(podFullName string, container *api.Container, last *docker.Container, backoff time.Duration) {
+	ref := containerRef(podFullName, container.Name)
+	restart := "Restarting it."
+	if backoff > 0 {
+		restart = fmt.Sprintf("Restarting it after %v.", backoff)
+	}
+	if last.State.ExitCode == exitCodeKilled && container.Memory > 0 {
+		record.Eventf(ref, "oomKilled", "Container %s was killed, probably for using more than its memory limit of %d bytes. %s", last.ID, container.Memory, restart)
+		return
+	}
+	record.Eventf(ref, "exited", "Container %s exited with %d. %s", last.ID, last.State.ExitCode, restart)
+}
+
 type podContainer struct {
 	podFullName   string
 	containerName string
@@ -533,26 +1626,81 @@ func getDesiredVolumes(pods []Pod) map[string]api.Volume {
 func (kl *Kubelet) reconcileVolumes(pods []Pod) error {
 	desiredVolumes := getDesiredVolumes(pods)
 	currentVolumes := volume.GetCurrentVolumes(kl.rootDirectory)
+	var errs []error
 	for name, vol := range currentVolumes {
 		if _, ok := desiredVolumes[name]; !ok {
-			//TODO (jonesdl) We should somehow differentiate between volumes that are supposed
-			//to be deleted and volumes that are leftover after a crash.
 			glog.Infof("Orphaned volume %s found, tearing down volume", name)
-			//TODO (jonesdl) This should not block other kubelet synchronization procedures
-			err := vol.TearDown()
-			if err != nil {
-				glog.Infof("Could not tear down volume %s (%s)", name, err)
+			if err := vol.TearDown(); err != nil {
+				errs = append(errs, fmt.Errorf("could not tear down volume %s: %v", name, err))
 			}
 		}
 	}
+	if len(errs) > 0 {
+		return fmt.Errorf("volume cleanup failed: %v", errs)
+	}
 	return nil
 }
 
+// DesiredPods returns the full names of the pods of the last sync, and whether
+// they are all the pods which should run here, i.e. whether every configuration
+// source had delivered its pods by then.
+func (kl *Kubelet) DesiredPods() (util.StringSet, bool) {
+	kl.podLock.Lock()
+	pods, synced := kl.pods, kl.podsSynced
+	kl.podLock.Unlock()
+	if !synced || !kl.sourcesReady() {
+		return nil, false
+	}
+	desiredPods := util.StringSet{}
+	for i := range pods {
+		desiredPods.Insert(GetPodFullName(&pods[i]))
+	}
+	return desiredPods, true
+}
+
+// CleanupOrphans tears down the volumes and removes the directories of the pods
+// which should not run here, e.g. pods deleted while the kubelet was down.
+// Nothing is cleaned up before every configuration source has delivered its pods
+// and they were synced, nor while containers of deleted pods still run and may
+// still use their volumes. Meant to be called periodically, e.g. via util.Forever.
+func (kl *Kubelet) CleanupOrphans() error {
+	kl.podLock.Lock()
+	pods, synced := kl.pods, kl.podsSynced
+	kl.podLock.Unlock()
+	if !synced || !kl.sourcesReady() {
+		glog.V(1).Infof("Skipping orphan cleanup, not all sources are ready yet.")
+		return nil
+	}
+	desiredPods := util.StringSet{}
+	for i := range pods {
+		desiredPods.Insert(GetPodFullName(&pods[i]))
+	}
+	dockerContainers, err := getKubeletDockerContainers(kl.dockerClient)
+	if err != nil {
+		return err
+	}
+	for _, container := range dockerContainers {
+		if podFullName, _, _ := parseDockerName(container.Names[0]); !desiredPods.Has(podFullName) {
+			glog.V(1).Infof("Skipping orphan cleanup, containers of deleted pod %s are still running.", podFullName)
+			return nil
+		}
+	}
+	if err := kl.reconcileVolumes(pods); err != nil {
+		return err
+	}
+	return kl.cleanupOrphanedPodDirs(pods)
+}
+
 // SyncPods synchronizes the configured list of pods (desired state) with the host current state.
 func (kl *Kubelet) SyncPods(pods []Pod) error {
 	glog.Infof("Desired [%s]: %+v", kl.hostname, pods)
+	kl.podLock.Lock()
+	kl.pods = pods
+	kl.podsSynced = true
+	kl.podLock.Unlock()
 	var err error
 	desiredContainers := make(map[podContainer]empty)
+	desiredPods := util.StringSet{}
 
 	dockerContainers, err := getKubeletDockerContainers(kl.dockerClient)
 	if err != nil {
@@ -564,6 +1712,7 @@ func (kl *Kubelet) SyncPods(pods []Pod) error {
 	for i := range pods {
 		pod := &pods[i]
 		podFullName := GetPodFullName(pod)
+		desiredPods.Insert(podFullName)
 
 		// Add all containers (including net) to the map.
 		desiredContainers[podContainer{podFullName, networkContainerName}] = empty{}
@@ -572,33 +1721,75 @@ func (kl *Kubelet) SyncPods(pods []Pod) error {
 		}
 
 		// Run the sync in an async manifest worker.
-		kl.podWorkers.Run(podFullName, func() {
-			err := kl.syncPod(pod, dockerContainers)
+		kl.podWorkers.Run(podFullName, func(waited bool) {
+			containers := dockerContainers
+			if waited {
+				// Earlier syncs of the pod may have started or killed containers since.
+				var err error
+				if containers, err = getKubeletDockerContainers(kl.dockerClient); err != nil {
+					glog.Errorf("Error listing containers: %v skipping pod %s.", err, podFullName)
+					return
+				}
+			}
+			err := kl.syncPod(pod, containers)
 			if err != nil {
 				glog.Errorf("Error syncing pod: %v skipping.", err)
 			}
 		})
 	}
 
+	if !kl.sourcesReady() {
+		// A source which has not delivered yet may still want containers we don't know
+		// of, so wait for it before killing anything.
+		glog.V(1).Infof("Skipping deletes, not all sources are ready yet.")
+		return nil
+	}
+
+	kl.readiness.retain(desiredPods)
+	kl.startFailures.retain(desiredPods)
+	kl.crashLoops.retain(desiredPods)
+
 	// Kill any containers we don't need
 	existingContainers, err := getKubeletDockerContainers(kl.dockerClient)
 	if err != nil {
 		glog.Errorf("Error listing containers: %v", err)
 		return err
 	}
+	kl.probeFailures.retain(existingContainers)
+	unwanted := map[string][]*docker.APIContainers{}
 	for _, container := range existingContainers {
 		// Don't kill containers that are in the desired pods.
 		podFullName, containerName, _ := parseDockerName(container.Names[0])
 		if _, ok := desiredContainers[podContainer{podFullName, containerName}]; !ok {
-			err = kl.killContainer(container)
-			if err != nil {
-				glog.Errorf("Error killing container: %v", err)
-			}
+			unwanted[podFullName] = append(unwanted[podFullName], container)
+		}
+	}
+	for podFullName, containers := range unwanted {
+		var pod *Pod
+		if known, ok := kl.knownPods[podFullName]; ok {
+			pod = &known
 		}
+		containers := containers
+		// Containers may take their whole grace period to stop, so kill them in
+		// the pod's worker rather than hold up the sync of other pods.
+		kl.podWorkers.Run(podFullName, func(bool) {
+			// Containers which are gone by now only fail to stop again.
+			kl.killContainers(pod, containers)
+		})
 	}
 
-	// Remove any orphaned volumes.
-	kl.reconcileVolumes(pods)
+	// Remember the pods wanted now, and the deleted pods whose containers are
+	// still being stopped.
+	knownPods := map[string]Pod{}
+	for i := range pods {
+		knownPods[GetPodFullName(&pods[i])] = pods[i]
+	}
+	for podFullName := range unwanted {
+		if known, ok := kl.knownPods[podFullName]; ok && !desiredPods.Has(podFullName) {
+			knownPods[podFullName] = known
+		}
+	}
+	kl.knownPods = knownPods
 
 	return err
 }
@@ -704,6 +1895,13 @@ func (kl *Kubelet) GetRootInfo(req *info.ContainerInfoRequest) (*info.ContainerI
 	return kl.statsFromContainerPath("/", req)
 }
 
+// GetPods returns the pods the kubelet last synced, which it should be running.
+func (kl *Kubelet) GetPods() []Pod {
+	kl.podLock.Lock()
+	defer kl.podLock.Unlock()
+	return append([]Pod{}, kl.pods...)
+}
+
 func (kl *Kubelet) GetMachineInfo() (*info.MachineInfo, error) {
 	return kl.cadvisorClient.MachineInfo()
 }
@@ -722,12 +1920,174 @@ func (kl *Kubelet) healthy(podFullName string, currentState api.PodState, contai
 	return kl.healthChecker.HealthCheck(podFullName, currentState, container)
 }
 
+// shouldRestart returns whether a container which exited as described by last
+// should be started again under policy. An empty policy means RestartAlways.
+func shouldRestart(policy api.RestartPolicy, expectedHash uint64, last *docker.Container) bool {
+	if last.State.StartedAt.IsZero() {
+		// The container was created but never ran.
+		return true
+	}
+	if last.Name != "" {
+		if _, _, hash := parseDockerName(last.Name); hash != 0 && hash != expectedHash {
+			// The container changed since it exited, so its new version gets to run.
+			return true
+		}
+	}
+	switch policy.Type {
+	case api.RestartNever:
+		return false
+	case api.RestartOnFailure:
+		return last.State.ExitCode != 0
+	}
+	return true
+}
+
+// ready runs the readiness probe of container, if it has one. Unlike liveness
+// probes, a container is not ready until InitialDelaySeconds have passed.
+func (kl *Kubelet) ready(podFullName string, currentState api.PodState, container api.Container, dockerContainer *docker.APIContainers) bool {
+	if container.ReadinessProbe == nil {
+		return true
+	}
+	if time.Now().Unix()-dockerContainer.Created < container.ReadinessProbe.InitialDelaySeconds {
+		return false
+	}
+	if kl.healthChecker == nil {
+		return true
+	}
+	// The health checkers probe what the LivenessProbe field describes.
+	probed := container
+	probed.LivenessProbe = container.ReadinessProbe
+	status, err := kl.healthChecker.HealthCheck(podFullName, currentState, probed)
+	if err != nil {
+		glog.V(1).Infof("readiness check of pod %s container %s errored: %v", podFullName, container.Name, err)
+		return false
+	}
+	return status == health.Healthy
+}
+
 // Returns logs of current machine.
 func (kl *Kubelet) ServeLogs(w http.ResponseWriter, req *http.Request) {
 	// TODO: whitelist logs we are willing to serve
 	kl.logServer.ServeHTTP(w, req)
 }
 
+// ExecInContainer runs a command in a container of a pod, streaming stdin (if not nil)
+// to it and its output to stdout and stderr until it exits.
+func (kl *Kubelet) ExecInContainer(podFullName, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	if kl.runner == nil {
+		return fmt.Errorf("no runner specified.")
+	}
+	dockerContainers, err := getKubeletDockerContainers(kl.dockerClient)
+	if err != nil {
+		return err
+	}
+	dockerContainer, found, _ := dockerContainers.FindPodContainer(podFullName, container)
+	if !found {
+		return ErrContainerNotFound
+	}
+	return kl.runner.ExecInContainer(dockerContainer.ID, cmd, stdin, stdout, stderr)
+}
+
+// PortForwardConn is a connection to a port of a pod. CloseWrite ends the data
+// sent to the port, while what the port sends back can still be read.
+type PortForwardConn interface {
+	io.ReadWriteCloser
+	CloseWrite() error
+}
+
+// PortForward connects to a port of a pod from inside the network namespace of
+// its network container, so that ports bound only to the pod's localhost are
+// reachable too. It fails without connecting if the pod has no running network
+// container. A port nothing listens on gives a connection which ends at once.
+func (kl *Kubelet) PortForward(podFullName string, port uint16) (PortForwardConn, error) {
+	if kl.portForwardCommand == nil {
+		return nil, fmt.Errorf("no port forwarding command specified")
+	}
+	dockerContainers, err := getKubeletDockerContainers(kl.dockerClient)
+	if err != nil {
+		return nil, err
+	}
+	netContainer, found, _ := dockerContainers.FindPodContainer(podFullName, networkContainerName)
+	if !found {
+		return nil, ErrContainerNotFound
+	}
+	inspected, err := kl.dockerClient.InspectContainer(netContainer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if inspected.State.Pid == 0 {
+		return nil, fmt.Errorf("the network container of pod %s is not running", podFullName)
+	}
+	cmd := kl.portForwardCommand(inspected.State.Pid, port)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandConn{cmd, stdin, stdout}, nil
+}
+
+// portForwardHalfCloseSeconds is how long socat keeps copying what a port sends
+// back after the client is done sending. socat otherwise gives up after half a
+// second.
+const portForwardHalfCloseSeconds = 3600
+
+// nsenterPortForwardCommand returns a command which connects its stdin and stdout
+// to port of localhost, as seen from the network namespace of process pid. It
+// needs nsenter and socat on the host.
+func nsenterPortForwardCommand(pid int, port uint16) *exec.Cmd {
+	return exec.Command("nsenter", "-t", strconv.Itoa(pid), "-n",
+		"socat", "-t", strconv.Itoa(portForwardHalfCloseSeconds), "-", fmt.Sprintf("TCP4:localhost:%d", port))
+}
+
+// commandConn is a connection made of the stdin and stdout of a running command.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.Reader
+}
+
+func (c *commandConn) Read(p []byte) (int, error) {
+	return c.stdout.Read(p)
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// CloseWrite closes the stdin of the command.
+func (c *commandConn) CloseWrite() error {
+	return c.stdin.Close()
+}
+
+// Close kills the command, if it is still running, and waits for it.
+func (c *commandConn) Close() error {
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	return c.cmd.Wait()
+}
+
+// GetKubeletContainerLogs writes the logs of a container of a pod to stdout and stderr.
+// tail is the number of lines to write from the end of the logs, or "all". If follow is
+// true it keeps writing new output until the container exits.
+func (kl *Kubelet) GetKubeletContainerLogs(podFullName, containerName, tail string, follow bool, stdout, stderr io.Writer) error {
+	dockerContainers, err := getKubeletDockerContainers(kl.dockerClient)
+	if err != nil {
+		return err
+	}
+	dockerContainer, found, _ := dockerContainers.FindPodContainer(podFullName, containerName)
+	if !found {
+		return ErrContainerNotFound
+	}
+	return getDockerContainerLogs(kl.dockerClient, dockerContainer.ID, tail, follow, stdout, stderr)
+}
+
 // Run a command in a container, returns the combined stdout, stderr as an array of bytes
 func (kl *Kubelet) RunInContainer(podFullName, container string, cmd []string) ([]byte, error) {
 	if kl.runner == nil {
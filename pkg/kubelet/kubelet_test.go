@@ -17,27 +17,37 @@ limitations under the License.
 package kubelet
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
 	"hash/adler32"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path"
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/credentialprovider"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/health"
-	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/version"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/volume"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/google/cadvisor/info"
 	"github.com/stretchr/testify/mock"
 )
 
-func newTestKubelet(t *testing.T) (*Kubelet, *tools.FakeEtcdClient, *FakeDockerClient) {
-	fakeEtcdClient := tools.NewFakeEtcdClient(t)
+func newTestKubelet(t *testing.T) (*Kubelet, *FakeDockerClient) {
 	fakeDocker := &FakeDockerClient{
 		err: nil,
 	}
@@ -45,10 +55,42 @@ func newTestKubelet(t *testing.T) (*Kubelet, *tools.FakeEtcdClient, *FakeDockerC
 	kubelet := &Kubelet{}
 	kubelet.dockerClient = fakeDocker
 	kubelet.dockerPuller = &FakeDockerPuller{}
-	kubelet.etcdClient = fakeEtcdClient
 	kubelet.rootDirectory = "/tmp/kubelet"
 	kubelet.podWorkers = newPodWorkers()
-	return kubelet, fakeEtcdClient, fakeDocker
+	kubelet.sourcesReady = func() bool { return true }
+	return kubelet, fakeDocker
+}
+
+// recordEvents collects the events recorded until stop is called.
+func recordEvents() (events <-chan api.Event, stop func()) {
+	ch := make(chan api.Event, 100)
+	w := record.GetEvents(func(event api.Event) {
+		select {
+		case ch <- event:
+		default:
+		}
+	})
+	return ch, w.Stop
+}
+
+// waitForEvent waits for an event with the given reason about the named
+// container, and returns it together with the events which came before it.
+func waitForEvent(t *testing.T, events <-chan api.Event, containerName, reason string) (api.Event, []api.Event) {
+	fieldPath := fmt.Sprintf("desiredState.manifest.containers[%s]", containerName)
+	timeout := time.After(time.Second)
+	before := []api.Event{}
+	for {
+		select {
+		case event := <-events:
+			if event.Reason == reason && event.InvolvedObject.FieldPath == fieldPath {
+				return event, before
+			}
+			before = append(before, event)
+		case <-timeout:
+			t.Fatalf("timed out waiting for a %s event about container %s", reason, containerName)
+			return api.Event{}, nil
+		}
+	}
 }
 
 func verifyCalls(t *testing.T, fakeDocker *FakeDockerClient, calls []string) {
@@ -114,7 +156,7 @@ func TestContainerManifestNaming(t *testing.T) {
 }
 
 func TestGetContainerID(t *testing.T) {
-	_, _, fakeDocker := newTestKubelet(t)
+	_, fakeDocker := newTestKubelet(t)
 	fakeDocker.containerList = []docker.APIContainers{
 		{
 			ID:    "foobar",
@@ -164,9 +206,9 @@ func TestKillContainerWithError(t *testing.T) {
 			},
 		},
 	}
-	kubelet, _, _ := newTestKubelet(t)
+	kubelet, _ := newTestKubelet(t)
 	kubelet.dockerClient = fakeDocker
-	err := kubelet.killContainer(&fakeDocker.containerList[0])
+	err := kubelet.killContainer(nil, &fakeDocker.containerList[0])
 	if err == nil {
 		t.Errorf("expected error, found nil")
 	}
@@ -174,7 +216,7 @@ func TestKillContainerWithError(t *testing.T) {
 }
 
 func TestKillContainer(t *testing.T) {
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	fakeDocker.containerList = []docker.APIContainers{
 		{
 			ID:    "1234",
@@ -189,13 +231,182 @@ func TestKillContainer(t *testing.T) {
 		ID: "foobar",
 	}
 
-	err := kubelet.killContainer(&fakeDocker.containerList[0])
+	err := kubelet.killContainer(nil, &fakeDocker.containerList[0])
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 	verifyCalls(t, fakeDocker, []string{"stop"})
 }
 
+func TestKillContainerRunsPreStopHook(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	runner := &fakeContainerCommandRunner{}
+	kubelet.runner = runner
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			ID:    "1234",
+			Names: []string{"/k8s--foo--qux.test--1234"},
+		},
+	}
+	pod := &Pod{
+		Name:      "qux",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID:                            "qux",
+			TerminationGracePeriodSeconds: newInt64(30),
+			Containers: []api.Container{
+				{
+					Name: "foo",
+					Lifecycle: &api.Lifecycle{
+						PreStop: &api.Handler{Exec: &api.ExecProbe{Command: []string{"flush"}}},
+					},
+				},
+			},
+		},
+	}
+
+	err := kubelet.killContainer(pod, &fakeDocker.containerList[0])
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if runner.ID != "1234" || !reflect.DeepEqual(runner.Cmd, []string{"flush"}) {
+		t.Errorf("expected the pre-stop hook to run in 1234, ran %v in %q", runner.Cmd, runner.ID)
+	}
+	verifyCalls(t, fakeDocker, []string{"stop"})
+	if !reflect.DeepEqual(fakeDocker.stopTimeouts, []uint{30}) {
+		t.Errorf("expected a grace period of 30s, got %v", fakeDocker.stopTimeouts)
+	}
+}
+
+func TestKillContainerPreStopHookFailure(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.runner = &fakeContainerCommandRunner{E: fmt.Errorf("no such command")}
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			ID:    "1234",
+			Names: []string{"/k8s--foo--qux.test--1234"},
+		},
+	}
+	pod := &Pod{
+		Name:      "qux",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID: "qux",
+			Containers: []api.Container{
+				{
+					Name: "foo",
+					Lifecycle: &api.Lifecycle{
+						PreStop: &api.Handler{Exec: &api.ExecProbe{Command: []string{"flush"}}},
+					},
+				},
+			},
+		},
+	}
+
+	// The container is stopped anyway, with the default grace period.
+	err := kubelet.killContainer(pod, &fakeDocker.containerList[0])
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	verifyCalls(t, fakeDocker, []string{"stop"})
+	if !reflect.DeepEqual(fakeDocker.stopTimeouts, []uint{10}) {
+		t.Errorf("expected the default grace period, got %v", fakeDocker.stopTimeouts)
+	}
+}
+
+func newInt64(i int64) *int64 {
+	return &i
+}
+
+func TestKillContainerNoGracePeriod(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	runner := &fakeContainerCommandRunner{}
+	kubelet.runner = runner
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			ID:    "1234",
+			Names: []string{"/k8s--foo--qux.test--1234"},
+		},
+	}
+	pod := &Pod{
+		Name:      "qux",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID:                            "qux",
+			TerminationGracePeriodSeconds: newInt64(0),
+			Containers: []api.Container{
+				{
+					Name: "foo",
+					Lifecycle: &api.Lifecycle{
+						PreStop: &api.Handler{Exec: &api.ExecProbe{Command: []string{"flush"}}},
+					},
+				},
+			},
+		},
+	}
+
+	// The container is killed at once, without its hook.
+	err := kubelet.killContainer(pod, &fakeDocker.containerList[0])
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if runner.Cmd != nil {
+		t.Errorf("expected the pre-stop hook not to run, ran %v", runner.Cmd)
+	}
+	if !reflect.DeepEqual(fakeDocker.stopTimeouts, []uint{0}) {
+		t.Errorf("expected no grace period, got %v", fakeDocker.stopTimeouts)
+	}
+}
+
+// hangingCommandRunner runs commands which don't finish until released.
+type hangingCommandRunner struct {
+	fakeContainerCommandRunner
+	release chan struct{}
+}
+
+func (h *hangingCommandRunner) RunInContainer(id string, cmd []string) ([]byte, error) {
+	<-h.release
+	return []byte{}, nil
+}
+
+func TestKillContainerPreStopHookTimeout(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	runner := &hangingCommandRunner{release: make(chan struct{})}
+	defer close(runner.release)
+	kubelet.runner = runner
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			ID:    "1234",
+			Names: []string{"/k8s--foo--qux.test--1234"},
+		},
+	}
+	pod := &Pod{
+		Name:      "qux",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID:                            "qux",
+			TerminationGracePeriodSeconds: newInt64(1),
+			Containers: []api.Container{
+				{
+					Name: "foo",
+					Lifecycle: &api.Lifecycle{
+						PreStop: &api.Handler{Exec: &api.ExecProbe{Command: []string{"flush"}}},
+					},
+				},
+			},
+		},
+	}
+
+	// The hook uses up the grace period, so the container is killed at once.
+	err := kubelet.killContainer(pod, &fakeDocker.containerList[0])
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fakeDocker.stopTimeouts, []uint{0}) {
+		t.Errorf("expected no grace period left, got %v", fakeDocker.stopTimeouts)
+	}
+}
+
 type channelReader struct {
 	list [][]Pod
 	wg   sync.WaitGroup
@@ -223,7 +434,7 @@ func (cr *channelReader) GetList() [][]Pod {
 }
 
 func TestSyncPodsDoesNothing(t *testing.T) {
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	container := api.Container{Name: "bar"}
 	fakeDocker.containerList = []docker.APIContainers{
 		{
@@ -252,8 +463,9 @@ func TestSyncPodsDoesNothing(t *testing.T) {
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+	kubelet.drainWorkers()
 
-	verifyCalls(t, fakeDocker, []string{"list", "list"})
+	verifyCalls(t, fakeDocker, []string{"list", "list", "list", "inspect", "inspect"})
 }
 
 // drainWorkers waits until all workers are done.  Should only used for testing.
@@ -269,6 +481,59 @@ func (kl *Kubelet) drainWorkers() {
 	}
 }
 
+func TestPodWorkersRunsWaitingActionLast(t *testing.T) {
+	kl := &Kubelet{podWorkers: newPodWorkers()}
+	workers := &kl.podWorkers
+	release := make(chan struct{})
+	var lock sync.Mutex
+	var ran []string
+	run := func(name string) func(bool) {
+		return func(waited bool) {
+			lock.Lock()
+			defer lock.Unlock()
+			ran = append(ran, fmt.Sprintf("%s %v", name, waited))
+		}
+	}
+	workers.Run("foo.test", func(waited bool) {
+		<-release
+		run("first")(waited)
+	})
+	// Both wait for the first action, and only the latest is run.
+	workers.Run("foo.test", run("second"))
+	workers.Run("foo.test", run("third"))
+	// Other pods don't wait.
+	workers.Run("bar.test", run("other"))
+	for {
+		lock.Lock()
+		done := len(ran) == 1
+		lock.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	kl.drainWorkers()
+
+	expected := []string{"other false", "first false", "third true"}
+	if !reflect.DeepEqual(ran, expected) {
+		t.Errorf("expected %v, got %v", expected, ran)
+	}
+}
+
+func TestPodWorkersSurvivesPanic(t *testing.T) {
+	kl := &Kubelet{podWorkers: newPodWorkers()}
+	kl.podWorkers.Run("foo.test", func(bool) { panic("test") })
+	kl.drainWorkers()
+	ran := make(chan struct{})
+	kl.podWorkers.Run("foo.test", func(bool) { close(ran) })
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Errorf("expected the pod's next action to run")
+	}
+}
+
 func matchString(t *testing.T, pattern, str string) bool {
 	match, err := regexp.MatchString(pattern, str)
 	if err != nil {
@@ -278,7 +543,7 @@ func matchString(t *testing.T, pattern, str string) bool {
 }
 
 func TestSyncPodsCreatesNetAndContainer(t *testing.T) {
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	fakeDocker.containerList = []docker.APIContainers{}
 	err := kubelet.SyncPods([]Pod{
 		{
@@ -309,8 +574,83 @@ func TestSyncPodsCreatesNetAndContainer(t *testing.T) {
 	fakeDocker.lock.Unlock()
 }
 
+type fakeNetworkPlugin struct {
+	err      error
+	setUp    []string
+	tornDown []string
+}
+
+func (f *fakeNetworkPlugin) Name() string {
+	return "fake"
+}
+
+func (f *fakeNetworkPlugin) SetUpPod(namespace, name, containerID, netnsPath string) error {
+	f.setUp = append(f.setUp, fmt.Sprintf("%s/%s %s", namespace, name, netnsPath))
+	return f.err
+}
+
+func (f *fakeNetworkPlugin) TearDownPod(namespace, name, containerID, netnsPath string) error {
+	f.tornDown = append(f.tornDown, fmt.Sprintf("%s/%s %s", namespace, name, netnsPath))
+	return f.err
+}
+
+func TestSyncPodsSetsUpNetwork(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	plugin := &fakeNetworkPlugin{}
+	kubelet.networkPlugin = plugin
+	fakeDocker.container = &docker.Container{State: docker.State{Running: true, Pid: 42}}
+	pod := Pod{
+		Name:      "foo",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID:         "foo",
+			Containers: []api.Container{{Name: "bar"}},
+		},
+	}
+	if err := kubelet.SyncPods([]Pod{pod}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	kubelet.drainWorkers()
+	if !reflect.DeepEqual(plugin.setUp, []string{"test/foo /proc/42/ns/net"}) {
+		t.Errorf("unexpected network set ups: %v", plugin.setUp)
+	}
+
+	// Deleting the pod tears its network down.
+	if err := kubelet.SyncPods([]Pod{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	kubelet.drainWorkers()
+	if !reflect.DeepEqual(plugin.tornDown, []string{"test/foo /proc/42/ns/net"}) {
+		t.Errorf("unexpected network tear downs: %v", plugin.tornDown)
+	}
+}
+
+func TestSyncPodsNetworkSetUpFails(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.networkPlugin = &fakeNetworkPlugin{err: fmt.Errorf("no bridge")}
+	fakeDocker.container = &docker.Container{State: docker.State{Running: true, Pid: 42}}
+	err := kubelet.syncPod(&Pod{
+		Name:      "foo",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID:         "foo",
+			Containers: []api.Container{{Name: "bar"}},
+		},
+	}, DockerContainers{})
+	if err == nil {
+		t.Errorf("expected the failed network set up to fail the sync")
+	}
+
+	// Only the network container was created, and it was stopped again.
+	fakeDocker.lock.Lock()
+	defer fakeDocker.lock.Unlock()
+	if len(fakeDocker.Created) != 1 || len(fakeDocker.stopped) != 1 {
+		t.Errorf("unexpected containers created %v and stopped %v", fakeDocker.Created, fakeDocker.stopped)
+	}
+}
+
 func TestSyncPodsWithNetCreatesContainer(t *testing.T) {
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	fakeDocker.containerList = []docker.APIContainers{
 		{
 			// network container
@@ -346,8 +686,98 @@ func TestSyncPodsWithNetCreatesContainer(t *testing.T) {
 	fakeDocker.lock.Unlock()
 }
 
+func TestSyncPodsBacksOffFailedStarts(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	now := time.Unix(100, 0)
+	kubelet.startFailures.now = func() time.Time { return now }
+	puller := &FakeDockerPuller{ErrorsToInject: []error{docker.ErrNoSuchImage, docker.ErrNoSuchImage}}
+	kubelet.dockerPuller = puller
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			// network container
+			Names: []string{"/k8s--net--foo.etcd--"},
+			ID:    "9876",
+		},
+	}
+	pods := []Pod{
+		{
+			Name:      "foo",
+			Namespace: "etcd",
+			Manifest: api.ContainerManifest{
+				ID:         "foo",
+				Containers: []api.Container{{Name: "bar", Image: "missing"}},
+			},
+		},
+	}
+	sync := func() {
+		if err := kubelet.SyncPods(pods); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		kubelet.drainWorkers()
+	}
+
+	sync()
+	expected := map[string]api.ContainerWaiting{
+		"bar": {
+			Reason:      DockerImageNotFound,
+			Message:     docker.ErrNoSuchImage.Error(),
+			Failures:    1,
+			NextAttempt: util.Time{Time: now.Add(initialStartBackoff)},
+		},
+	}
+	if waiting := kubelet.startFailures.get("foo.etcd"); !reflect.DeepEqual(waiting, expected) {
+		t.Errorf("expected %#v, got %#v", expected, waiting)
+	}
+	fakeClient := &client.Fake{}
+	kubelet.ReportPodStatus(fakeClient)
+	if report := fakeClient.Actions[0].Value.(api.PodStatusReport); !reflect.DeepEqual(report.Waiting, expected) {
+		t.Errorf("expected the report to tell of %#v, got %#v", expected, report.Waiting)
+	}
+
+	// Syncs during the backoff don't try again.
+	now = now.Add(initialStartBackoff / 2)
+	sync()
+	if len(puller.ImagesPulled) != 1 {
+		t.Errorf("unexpected pulls while backing off: %v", puller.ImagesPulled)
+	}
+
+	// The next failure doubles the backoff.
+	now = now.Add(initialStartBackoff)
+	sync()
+	if waiting := kubelet.startFailures.get("foo.etcd")["bar"]; waiting.Failures != 2 || !waiting.NextAttempt.Equal(now.Add(2*initialStartBackoff)) {
+		t.Errorf("unexpected backoff: %#v", waiting)
+	}
+
+	// Once the container starts, its failures are forgotten.
+	now = now.Add(2 * initialStartBackoff)
+	sync()
+	if waiting := kubelet.startFailures.get("foo.etcd"); waiting != nil {
+		t.Errorf("unexpected failures after starting: %#v", waiting)
+	}
+	fakeDocker.lock.Lock()
+	if len(fakeDocker.Created) != 1 {
+		t.Errorf("expected the container to be created once, got %v", fakeDocker.Created)
+	}
+	fakeDocker.lock.Unlock()
+}
+
+func TestStartFailuresBackoffIsCapped(t *testing.T) {
+	failures := startFailures{now: func() time.Time { return time.Unix(0, 0) }}
+	var waiting api.ContainerWaiting
+	for i := 0; i < 20; i++ {
+		waiting = failures.record("foo.test", "bar", fmt.Errorf("failed"))
+	}
+	if waiting.Failures != 20 || !waiting.NextAttempt.Equal(time.Unix(0, 0).Add(maxStartBackoff)) {
+		t.Errorf("unexpected backoff: %#v", waiting)
+	}
+	failures.retain(util.NewStringSet())
+	if waiting := failures.get("foo.test"); waiting != nil {
+		t.Errorf("expected the deleted pod to be forgotten, got %#v", waiting)
+	}
+}
+
 func TestSyncPodsDeletesWithNoNetContainer(t *testing.T) {
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	fakeDocker.containerList = []docker.APIContainers{
 		{
 			// format is k8s--<container-id>--<pod-fullname>
@@ -388,7 +818,7 @@ func TestSyncPodsDeletesWithNoNetContainer(t *testing.T) {
 }
 
 func TestSyncPodsDeletes(t *testing.T) {
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	fakeDocker.containerList = []docker.APIContainers{
 		{
 			// the k8s prefix is required for the kubelet to manage the container
@@ -409,6 +839,7 @@ func TestSyncPodsDeletes(t *testing.T) {
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+	kubelet.drainWorkers()
 
 	verifyCalls(t, fakeDocker, []string{"list", "list", "stop", "stop"})
 
@@ -425,8 +856,475 @@ func TestSyncPodsDeletes(t *testing.T) {
 	}
 }
 
+func TestSyncPodsDeletesWithGracePeriod(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			Names: []string{"/k8s--bar--foo.test"},
+			ID:    "1234",
+		},
+		{
+			Names: []string{"/k8s--net--foo.test--"},
+			ID:    "9876",
+		},
+	}
+	pod := Pod{
+		Name:      "foo",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID:                            "foo",
+			TerminationGracePeriodSeconds: newInt64(60),
+			Containers:                    []api.Container{{Name: "bar"}},
+		},
+	}
+	// Learn the pod's spec, then delete it.
+	kubelet.knownPods = map[string]Pod{GetPodFullName(&pod): pod}
+	err := kubelet.SyncPods([]Pod{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	kubelet.drainWorkers()
+
+	// The network container goes last.
+	if !reflect.DeepEqual(fakeDocker.stopped, []string{"1234", "9876"}) {
+		t.Errorf("unexpected containers stopped: %v", fakeDocker.stopped)
+	}
+	if !reflect.DeepEqual(fakeDocker.stopTimeouts, []uint{60, 60}) {
+		t.Errorf("expected the pod's grace period, got %v", fakeDocker.stopTimeouts)
+	}
+	if _, ok := kubelet.knownPods[GetPodFullName(&pod)]; !ok {
+		t.Errorf("expected the pod to be remembered while its containers are stopped")
+	}
+
+	err = kubelet.SyncPods([]Pod{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(kubelet.knownPods) != 0 {
+		t.Errorf("expected the pod to be forgotten, got %v", kubelet.knownPods)
+	}
+}
+
+func TestSyncPodsDeletesWaitForSources(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	ready := false
+	kubelet.sourcesReady = func() bool { return ready }
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			Names: []string{"/k8s--foo--bar.test"},
+			ID:    "1234",
+		},
+	}
+	if err := kubelet.SyncPods([]Pod{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	verifyCalls(t, fakeDocker, []string{"list"})
+
+	ready = true
+	fakeDocker.clearCalls()
+	if err := kubelet.SyncPods([]Pod{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	kubelet.drainWorkers()
+	verifyCalls(t, fakeDocker, []string{"list", "list", "stop"})
+}
+
+func TestSyncPodHonorsRestartPolicy(t *testing.T) {
+	container := api.Container{Name: "bar"}
+	exitedName := "/k8s--bar." + strconv.FormatUint(hashContainer(&container), 16) + "--foo.test--1"
+	started := time.Unix(100, 0)
+	tests := []struct {
+		policy   api.RestartPolicyType
+		exitCode int
+		restart  bool
+	}{
+		{"", 0, true},
+		{api.RestartAlways, 0, true},
+		{api.RestartOnFailure, 0, false},
+		{api.RestartOnFailure, 1, true},
+		{api.RestartNever, 0, false},
+		{api.RestartNever, 1, false},
+	}
+	for _, test := range tests {
+		kubelet, fakeDocker := newTestKubelet(t)
+		fakeDocker.containerList = []docker.APIContainers{
+			{
+				// network container
+				Names: []string{"/k8s--net--foo.test--"},
+				ID:    "9876",
+			},
+		}
+		fakeDocker.exitedContainerList = []docker.APIContainers{
+			{Names: []string{exitedName}, ID: "1234"},
+		}
+		fakeDocker.containerMap = map[string]*docker.Container{
+			"1234": {
+				Name:  exitedName,
+				State: docker.State{ExitCode: test.exitCode, StartedAt: started, FinishedAt: started.Add(time.Minute)},
+			},
+		}
+		dockerContainers, _ := getKubeletDockerContainers(fakeDocker)
+		pod := &Pod{
+			Name:      "foo",
+			Namespace: "test",
+			Manifest: api.ContainerManifest{
+				ID:            "foo",
+				Containers:    []api.Container{container},
+				RestartPolicy: api.RestartPolicy{Type: test.policy},
+			},
+		}
+		if err := kubelet.syncPod(pod, dockerContainers); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if restarted := len(fakeDocker.Created) == 1; restarted != test.restart {
+			t.Errorf("policy %q, exit code %d: expected restart %v, created %v", test.policy, test.exitCode, test.restart, fakeDocker.Created)
+		}
+	}
+}
+
+func TestSyncPodBacksOffCrashLoops(t *testing.T) {
+	container := api.Container{Name: "bar"}
+	exitedName := "/k8s--bar." + strconv.FormatUint(hashContainer(&container), 16) + "--foo.test--1"
+	started := time.Unix(100, 0)
+	finished := started.Add(time.Minute)
+	kubelet, fakeDocker := newTestKubelet(t)
+	now := finished.Add(initialCrashBackoff / 2)
+	kubelet.crashLoops.now = func() time.Time { return now }
+	// The container crashed before.
+	kubelet.crashLoops.record("foo.test", "bar", "1233", started.Add(-time.Minute), started)
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			// network container
+			Names: []string{"/k8s--net--foo.test--"},
+			ID:    "9876",
+		},
+	}
+	fakeDocker.exitedContainerList = []docker.APIContainers{
+		{Names: []string{exitedName}, ID: "1234"},
+	}
+	fakeDocker.containerMap = map[string]*docker.Container{
+		"1234": {
+			ID:    "1234",
+			Name:  exitedName,
+			State: docker.State{ExitCode: 1, StartedAt: started, FinishedAt: finished},
+		},
+	}
+	pod := &Pod{
+		Name:      "foo",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID:         "foo",
+			Containers: []api.Container{container},
+		},
+	}
+	sync := func() {
+		dockerContainers, _ := getKubeletDockerContainers(fakeDocker)
+		if err := kubelet.syncPod(pod, dockerContainers); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	sync()
+	if len(fakeDocker.Created) != 0 {
+		t.Errorf("unexpected restart while backing off: %v", fakeDocker.Created)
+	}
+	expected := map[string]api.ContainerWaiting{
+		"bar": {
+			Reason:      CrashLoopBackOff,
+			Message:     "Container crashed 2 times in a row",
+			Failures:    2,
+			NextAttempt: util.Time{Time: finished.Add(initialCrashBackoff)},
+		},
+	}
+	if waiting := kubelet.crashLoops.get("foo.test"); !reflect.DeepEqual(waiting, expected) {
+		t.Errorf("expected %#v, got %#v", expected, waiting)
+	}
+
+	// Once the backoff is over, the container is restarted.
+	now = finished.Add(initialCrashBackoff)
+	sync()
+	if len(fakeDocker.Created) != 1 {
+		t.Errorf("expected the container to be restarted, created %v", fakeDocker.Created)
+	}
+	if waiting := kubelet.crashLoops.get("foo.test"); waiting != nil {
+		t.Errorf("unexpected waiting containers after the backoff: %#v", waiting)
+	}
+}
+
+func TestCrashLoopsBackoff(t *testing.T) {
+	loops := crashLoops{}
+	started := time.Unix(0, 0)
+	var backoff time.Duration
+	for i := 0; i < 20; i++ {
+		finished := started.Add(time.Second)
+		backoff, _ = loops.record("foo.test", "bar", strconv.Itoa(i), started, finished)
+		if i == 0 && backoff != 0 {
+			t.Errorf("expected the first crash to be restarted right away, got %v", backoff)
+		}
+		if i == 2 && backoff != 2*initialCrashBackoff {
+			t.Errorf("expected the third crash to double the backoff, got %v", backoff)
+		}
+		started = finished.Add(backoff)
+	}
+	if backoff != maxCrashBackoff {
+		t.Errorf("expected the backoff to be capped, got %v", backoff)
+	}
+	// A crash is only counted once.
+	if _, first := loops.record("foo.test", "bar", "19", started, started); first {
+		t.Errorf("expected the crash to be known")
+	}
+	// A container which ran long enough starts over.
+	if backoff, _ := loops.record("foo.test", "bar", "20", started, started.Add(crashLoopResetPeriod)); backoff != 0 {
+		t.Errorf("expected no backoff after a long run, got %v", backoff)
+	}
+	loops.retain(util.NewStringSet())
+	if _, ok := loops.loops["foo.test"]; ok {
+		t.Errorf("expected the deleted pod to be forgotten")
+	}
+}
+
+func TestShouldRestartChangedContainer(t *testing.T) {
+	last := &docker.Container{
+		Name:  "/k8s--bar.1234--foo.test--1",
+		State: docker.State{StartedAt: time.Unix(100, 0)},
+	}
+	if !shouldRestart(api.RestartPolicy{Type: api.RestartNever}, 0x5678, last) {
+		t.Errorf("expected a changed container to be started")
+	}
+	if shouldRestart(api.RestartPolicy{Type: api.RestartNever}, 0x1234, last) {
+		t.Errorf("expected an unchanged container to stay exited")
+	}
+	if !shouldRestart(api.RestartPolicy{Type: api.RestartNever}, 0x1234, &docker.Container{}) {
+		t.Errorf("expected a container which never ran to be started")
+	}
+}
+
+func TestGetDockerPodInfoUsesNewestContainer(t *testing.T) {
+	fakeDocker := &FakeDockerClient{
+		containerList: []docker.APIContainers{
+			{Names: []string{"/k8s--bar--foo.test--2"}, ID: "running", Created: 200},
+		},
+		exitedContainerList: []docker.APIContainers{
+			{Names: []string{"/k8s--bar--foo.test--1"}, ID: "old", Created: 100},
+			{Names: []string{"/k8s--baz--foo.test--1"}, ID: "exited", Created: 100},
+			{Names: []string{"/k8s--bar--other.test--1"}, ID: "other", Created: 300},
+		},
+		containerMap: map[string]*docker.Container{
+			"running": {ID: "running", State: docker.State{Running: true}},
+			"old":     {ID: "old"},
+			"exited":  {ID: "exited", State: docker.State{ExitCode: 2}},
+		},
+	}
+	info, err := getDockerPodInfo(fakeDocker, "foo.test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(info) != 2 || info["bar"].ID != "running" || info["baz"].State.ExitCode != 2 {
+		t.Errorf("unexpected info: %#v", info)
+	}
+}
+
+func TestRunContainerMemoryLimits(t *testing.T) {
+	tests := []struct {
+		info       docker.Env
+		memory     int
+		memorySwap int64
+		event      bool
+	}{
+		{docker.Env{"MemoryLimit=1", "SwapLimit=1"}, 1024, 1024, false},
+		{docker.Env{"MemoryLimit=1", "SwapLimit=0"}, 1024, 0, false},
+		{docker.Env{"MemoryLimit=0", "SwapLimit=0"}, 1024, 0, true},
+		{docker.Env{"MemoryLimit=0", "SwapLimit=0"}, 0, 0, false},
+	}
+	for i, test := range tests {
+		kubelet, fakeDocker := newTestKubelet(t)
+		fakeDocker.info = &test.info
+		events, stop := recordEvents()
+		pod := &Pod{Name: "foo", Namespace: "test"}
+		container := &api.Container{Name: "bar", Memory: test.memory}
+		if _, err := kubelet.runContainer(pod, container, nil, ""); err != nil {
+			t.Errorf("%d: unexpected error: %v", i, err)
+		}
+		config := fakeDocker.createOptions[0].Config
+		if config.Memory != int64(test.memory) || config.MemorySwap != test.memorySwap {
+			t.Errorf("%d: unexpected limits: memory %d, swap %d", i, config.Memory, config.MemorySwap)
+		}
+		// Any event about the limit comes before the container is started.
+		_, before := waitForEvent(t, events, "bar", "started")
+		stop()
+		event := false
+		for _, e := range before {
+			event = event || e.Reason == "limitNotEnforced"
+		}
+		if event != test.event {
+			t.Errorf("%d: expected event %v, got %v", i, test.event, event)
+		}
+	}
+}
+
+func TestRunContainerPostStartHook(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	runner := &fakeContainerCommandRunner{}
+	kubelet.runner = runner
+	pod := &Pod{Name: "foo", Namespace: "test"}
+	container := &api.Container{
+		Name: "bar",
+		Lifecycle: &api.Lifecycle{
+			PostStart: &api.Handler{Exec: &api.ExecProbe{Command: []string{"warmup"}}},
+		},
+	}
+	id, err := kubelet.runContainer(pod, container, nil, "")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if runner.ID != string(id) || !reflect.DeepEqual(runner.Cmd, []string{"warmup"}) {
+		t.Errorf("expected the post-start hook to run in %q, ran %v in %q", id, runner.Cmd, runner.ID)
+	}
+	verifyCalls(t, fakeDocker, []string{"create", "start"})
+}
+
+func TestRunContainerPostStartHookFailure(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.runner = &fakeContainerCommandRunner{E: fmt.Errorf("no such command")}
+	pod := &Pod{Name: "foo", Namespace: "test"}
+	container := &api.Container{
+		Name: "bar",
+		Lifecycle: &api.Lifecycle{
+			PostStart: &api.Handler{Exec: &api.ExecProbe{Command: []string{"warmup"}}},
+		},
+	}
+	if _, err := kubelet.runContainer(pod, container, nil, ""); err == nil {
+		t.Errorf("expected error, found nil")
+	}
+	// The container is killed, to be started afresh by the next sync.
+	verifyCalls(t, fakeDocker, []string{"create", "start", "stop"})
+}
+
+func TestRunContainerAdjustsOOMScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		memory   int
+		expected int
+	}{
+		{"bar", 0, 1000},
+		{"bar", 256, 750},
+		{networkContainerName, 0, -998},
+	}
+	for _, test := range tests {
+		kubelet, fakeDocker := newTestKubelet(t)
+		mockCadvisor := &mockCadvisorClient{}
+		mockCadvisor.On("MachineInfo").Return(&info.MachineInfo{MemoryCapacity: 1024}, nil)
+		kubelet.cadvisorClient = mockCadvisor
+		fakeDocker.container = &docker.Container{State: docker.State{Pid: 42}}
+		adjusted := map[int]int{}
+		kubelet.oomScoreAdjuster = func(pid, value int) error {
+			adjusted[pid] = value
+			return nil
+		}
+		pod := &Pod{Name: "foo", Namespace: "test"}
+		container := &api.Container{Name: test.name, Memory: test.memory}
+		if _, err := kubelet.runContainer(pod, container, nil, ""); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(adjusted, map[int]int{42: test.expected}) {
+			t.Errorf("%s with limit %d: expected oom_score_adj %d, got %v", test.name, test.memory, test.expected, adjusted)
+		}
+	}
+}
+
+func TestRunContainerSkipsOOMScoreOfExitedContainer(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	fakeDocker.container = &docker.Container{}
+	kubelet.oomScoreAdjuster = func(pid, value int) error {
+		t.Errorf("unexpected oom_score_adj %d for pid %d", value, pid)
+		return nil
+	}
+	pod := &Pod{Name: "foo", Namespace: "test"}
+	if _, err := kubelet.runContainer(pod, &api.Container{Name: "bar"}, nil, ""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseResolvConfSearch(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected []string
+	}{
+		{"", nil},
+		{"nameserver 10.0.0.1\n", nil},
+		{"search foo.com bar.com\nnameserver 10.0.0.1\n", []string{"foo.com", "bar.com"}},
+		{"search foo.com\n# a comment\nsearch bar.com\n", []string{"bar.com"}},
+		{"  search\tfoo.com  bar.com\n", []string{"foo.com", "bar.com"}},
+	}
+	for _, test := range tests {
+		search, err := parseResolvConfSearch(strings.NewReader(test.data))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(search, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.data, test.expected, search)
+		}
+	}
+}
+
+func TestRunContainerClusterDNS(t *testing.T) {
+	f, err := ioutil.TempFile("", "resolv.conf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("nameserver 169.254.169.254\nsearch c.project.internal\n")
+	f.Close()
+	resolvConfPath = f.Name()
+	defer func() { resolvConfPath = "/etc/resolv.conf" }()
+
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.clusterDNS = net.ParseIP("10.0.0.10")
+	kubelet.clusterDomain = "kubernetes.local"
+	pod := &Pod{Name: "foo", Namespace: "test"}
+	if _, err := kubelet.runContainer(pod, &api.Container{Name: networkContainerName}, nil, ""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := kubelet.runContainer(pod, &api.Container{Name: "bar"}, nil, "container:net"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	netConfig := fakeDocker.hostConfigs[0]
+	if !reflect.DeepEqual(netConfig.Dns, []string{"10.0.0.10"}) {
+		t.Errorf("unexpected DNS servers: %v", netConfig.Dns)
+	}
+	if !reflect.DeepEqual(netConfig.DnsSearch, []string{"kubernetes.local", "c.project.internal"}) {
+		t.Errorf("unexpected DNS search domains: %v", netConfig.DnsSearch)
+	}
+	// The other containers use the resolv.conf of the network container.
+	if config := fakeDocker.hostConfigs[1]; config.Dns != nil || config.DnsSearch != nil {
+		t.Errorf("unexpected DNS config of a container: %#v", config)
+	}
+}
+
+func TestRunContainerWithoutClusterDNS(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	pod := &Pod{Name: "foo", Namespace: "test"}
+	if _, err := kubelet.runContainer(pod, &api.Container{Name: networkContainerName}, nil, ""); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if config := fakeDocker.hostConfigs[0]; config.Dns != nil || config.DnsSearch != nil {
+		t.Errorf("expected docker's DNS defaults, got %#v", config)
+	}
+}
+
+func TestGetResourceLimitSupportAsksOnce(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	fakeDocker.info = &docker.Env{"MemoryLimit=1"}
+	for i := 0; i < 2; i++ {
+		if support := kubelet.getResourceLimitSupport(); !support.memory || support.swap {
+			t.Errorf("unexpected support: %#v", support)
+		}
+	}
+	verifyCalls(t, fakeDocker, []string{"info"})
+}
+
 func TestSyncPodDeletesDuplicate(t *testing.T) {
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	dockerContainers := DockerContainers{
 		"1234": &docker.APIContainers{
 			// the k8s prefix is required for the kubelet to manage the container
@@ -478,7 +1376,7 @@ func (f *FalseHealthChecker) HealthCheck(podFullName string, state api.PodState,
 }
 
 func TestSyncPodBadHash(t *testing.T) {
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	kubelet.healthChecker = &FalseHealthChecker{}
 	dockerContainers := DockerContainers{
 		"1234": &docker.APIContainers{
@@ -498,30 +1396,120 @@ func TestSyncPodBadHash(t *testing.T) {
 		Manifest: api.ContainerManifest{
 			ID: "foo",
 			Containers: []api.Container{
-				{Name: "bar"},
+				{Name: "bar"},
+			},
+		},
+	}, dockerContainers)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	verifyCalls(t, fakeDocker, []string{"list", "stop", "create", "start"})
+
+	// A map interation is used to delete containers, so must not depend on
+	// order here.
+	expectedToStop := map[string]bool{
+		"1234": true,
+	}
+	if len(fakeDocker.stopped) != 1 ||
+		!expectedToStop[fakeDocker.stopped[0]] {
+		t.Errorf("Wrong containers were stopped: %v", fakeDocker.stopped)
+	}
+}
+
+func TestSyncPodUnhealthy(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.healthChecker = &FalseHealthChecker{}
+	dockerContainers := DockerContainers{
+		"1234": &docker.APIContainers{
+			// the k8s prefix is required for the kubelet to manage the container
+			Names: []string{"/k8s--bar--foo.test"},
+			ID:    "1234",
+		},
+		"9876": &docker.APIContainers{
+			// network container
+			Names: []string{"/k8s--net--foo.test--"},
+			ID:    "9876",
+		},
+	}
+	err := kubelet.syncPod(&Pod{
+		Name:      "foo",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID: "foo",
+			Containers: []api.Container{
+				{Name: "bar",
+					LivenessProbe: &api.LivenessProbe{
+						// Always returns healthy == false
+						Type: "false",
+					},
+				},
+			},
+		},
+	}, dockerContainers)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	verifyCalls(t, fakeDocker, []string{"list", "stop", "create", "start"})
+
+	// A map interation is used to delete containers, so must not depend on
+	// order here.
+	expectedToStop := map[string]bool{
+		"1234": true,
+	}
+	if len(fakeDocker.stopped) != 1 ||
+		!expectedToStop[fakeDocker.stopped[0]] {
+		t.Errorf("Wrong containers were stopped: %v", fakeDocker.stopped)
+	}
+}
+
+func TestSyncPodUnhealthyRestartNever(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.healthChecker = &FalseHealthChecker{}
+	dockerContainers := DockerContainers{
+		"1234": &docker.APIContainers{
+			// the k8s prefix is required for the kubelet to manage the container
+			Names: []string{"/k8s--bar--foo.test"},
+			ID:    "1234",
+		},
+		"9876": &docker.APIContainers{
+			// network container
+			Names: []string{"/k8s--net--foo.test--"},
+			ID:    "9876",
+		},
+	}
+	err := kubelet.syncPod(&Pod{
+		Name:      "foo",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID: "foo",
+			Containers: []api.Container{
+				{Name: "bar",
+					LivenessProbe: &api.LivenessProbe{
+						// Always returns healthy == false
+						Type: "false",
+					},
+				},
 			},
+			RestartPolicy: api.RestartPolicy{Type: api.RestartNever},
 		},
 	}, dockerContainers)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	verifyCalls(t, fakeDocker, []string{"list", "stop", "create", "start"})
-
-	// A map interation is used to delete containers, so must not depend on
-	// order here.
-	expectedToStop := map[string]bool{
-		"1234": true,
-	}
-	if len(fakeDocker.stopped) != 1 ||
-		!expectedToStop[fakeDocker.stopped[0]] {
+	verifyCalls(t, fakeDocker, []string{"list", "stop"})
+	if len(fakeDocker.stopped) != 1 || fakeDocker.stopped[0] != "1234" {
 		t.Errorf("Wrong containers were stopped: %v", fakeDocker.stopped)
 	}
 }
 
-func TestSyncPodUnhealthy(t *testing.T) {
-	kubelet, _, fakeDocker := newTestKubelet(t)
+func TestSyncPodUnhealthyFailureThreshold(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
 	kubelet.healthChecker = &FalseHealthChecker{}
+	events, stop := recordEvents()
+	defer stop()
 	dockerContainers := DockerContainers{
 		"1234": &docker.APIContainers{
 			// the k8s prefix is required for the kubelet to manage the container
@@ -534,7 +1522,7 @@ func TestSyncPodUnhealthy(t *testing.T) {
 			ID:    "9876",
 		},
 	}
-	err := kubelet.syncPod(&Pod{
+	pod := &Pod{
 		Name:      "foo",
 		Namespace: "test",
 		Manifest: api.ContainerManifest{
@@ -543,73 +1531,94 @@ func TestSyncPodUnhealthy(t *testing.T) {
 				{Name: "bar",
 					LivenessProbe: &api.LivenessProbe{
 						// Always returns healthy == false
-						Type: "false",
+						Type:             "false",
+						FailureThreshold: 2,
 					},
 				},
 			},
 		},
-	}, dockerContainers)
-	if err != nil {
+	}
+	if err := kubelet.syncPod(pod, dockerContainers); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+	if len(fakeDocker.stopped) != 0 {
+		t.Errorf("container stopped after a single failed probe: %v", fakeDocker.stopped)
+	}
 
-	verifyCalls(t, fakeDocker, []string{"list", "stop", "create", "start"})
-
-	// A map interation is used to delete containers, so must not depend on
-	// order here.
-	expectedToStop := map[string]bool{
-		"1234": true,
+	if err := kubelet.syncPod(pod, dockerContainers); err != nil {
+		t.Errorf("unexpected error: %v", err)
 	}
-	if len(fakeDocker.stopped) != 1 ||
-		!expectedToStop[fakeDocker.stopped[0]] {
+	if len(fakeDocker.stopped) != 1 || fakeDocker.stopped[0] != "1234" {
 		t.Errorf("Wrong containers were stopped: %v", fakeDocker.stopped)
 	}
+	// Events of earlier tests may still be arriving.
+	event, _ := waitForEvent(t, events, "bar", "unhealthy")
+	for !strings.Contains(event.Message, "2 failed liveness probes") {
+		event, _ = waitForEvent(t, events, "bar", "unhealthy")
+	}
+	if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.ID != "foo" {
+		t.Errorf("unexpected event %#v", event)
+	}
+	waitForEvent(t, events, "bar", "killed")
 }
 
-func TestEventWriting(t *testing.T) {
-	kubelet, fakeEtcd, _ := newTestKubelet(t)
-	expectedEvent := api.Event{
-		Event: "test",
-		Container: &api.Container{
-			Name: "foo",
-		},
+func TestProbeFailureCountsRetain(t *testing.T) {
+	var counts probeFailureCounts
+	counts.record("1234", false)
+	counts.record("5678", false)
+	counts.retain(DockerContainers{"1234": &docker.APIContainers{ID: "1234"}})
+	if failures := counts.record("1234", false); failures != 2 {
+		t.Errorf("expected the running container's failures to be kept, got %d", failures)
 	}
-	err := kubelet.LogEvent(&expectedEvent)
-	if err != nil {
-		t.Errorf("unexpected error: %v", err)
+	if failures := counts.record("5678", false); failures != 1 {
+		t.Errorf("expected the exited container's failures to be dropped, got %d", failures)
 	}
+}
 
-	if fakeEtcd.Ix != 1 {
-		t.Errorf("Unexpected number of children added: %d, expected 1", fakeEtcd.Ix)
+func TestSyncPodReadiness(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.healthChecker = &FalseHealthChecker{}
+	dockerContainers := DockerContainers{
+		"1234": &docker.APIContainers{
+			Names: []string{"/k8s--bar--foo.test"},
+			ID:    "1234",
+		},
+		"9876": &docker.APIContainers{
+			Names: []string{"/k8s--net--foo.test--"},
+			ID:    "9876",
+		},
 	}
-	response, err := fakeEtcd.Get("/events/foo/1", false, false)
-	if err != nil {
+	pod := &Pod{
+		Name:      "foo",
+		Namespace: "test",
+		Manifest: api.ContainerManifest{
+			ID: "foo",
+			Containers: []api.Container{
+				{Name: "bar", ReadinessProbe: &api.LivenessProbe{Type: "false"}},
+			},
+		},
+	}
+	if err := kubelet.syncPod(pod, dockerContainers); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+	if len(fakeDocker.stopped) != 0 {
+		t.Errorf("failed readiness probe stopped containers: %v", fakeDocker.stopped)
+	}
+	if kubelet.readiness.get("foo.test") {
+		t.Errorf("expected pod to not be ready")
+	}
 
-	var event api.Event
-	err = json.Unmarshal([]byte(response.Node.Value), &event)
-	if err != nil {
+	kubelet.healthChecker = nil
+	if err := kubelet.syncPod(pod, dockerContainers); err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-
-	if event.Event != expectedEvent.Event ||
-		event.Container.Name != expectedEvent.Container.Name {
-		t.Errorf("Event's don't match.  Expected: %#v Saw: %#v", expectedEvent, event)
+	if !kubelet.readiness.get("foo.test") {
+		t.Errorf("expected pod to be ready")
 	}
-}
 
-func TestEventWritingError(t *testing.T) {
-	kubelet, fakeEtcd, _ := newTestKubelet(t)
-	fakeEtcd.Err = fmt.Errorf("test error")
-	err := kubelet.LogEvent(&api.Event{
-		Event: "test",
-		Container: &api.Container{
-			Name: "foo",
-		},
-	})
-	if err == nil {
-		t.Errorf("Unexpected non-error")
+	kubelet.readiness.retain(util.StringSet{})
+	if kubelet.readiness.get("foo.test") {
+		t.Errorf("expected readiness of a removed pod to be forgotten")
 	}
 }
 
@@ -626,7 +1635,7 @@ func TestMakeEnvVariables(t *testing.T) {
 			},
 		},
 	}
-	vars := makeEnvironmentVariables(&container)
+	vars := makeEnvironmentVariables(&container, nil)
 	if len(vars) != len(container.Env) {
 		t.Errorf("Vars don't match.  Expected: %#v Found: %#v", container.Env, vars)
 	}
@@ -639,7 +1648,7 @@ func TestMakeEnvVariables(t *testing.T) {
 }
 
 func TestMountExternalVolumes(t *testing.T) {
-	kubelet, _, _ := newTestKubelet(t)
+	kubelet, _ := newTestKubelet(t)
 	manifest := api.ContainerManifest{
 		Volumes: []api.Volume{
 			{
@@ -663,6 +1672,153 @@ func TestMountExternalVolumes(t *testing.T) {
 	}
 }
 
+func TestPodDataDirs(t *testing.T) {
+	kubelet, _ := newTestKubelet(t)
+	tempDir, err := ioutil.TempDir("", "kubelet")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	kubelet.rootDirectory = tempDir
+	if err := kubelet.SetupDataDirs(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pods := []Pod{
+		{Name: "foo", Namespace: "test", Manifest: api.ContainerManifest{ID: "foo", Containers: []api.Container{{Name: "bar"}}}},
+		{Name: "baz", Namespace: "test", Manifest: api.ContainerManifest{ID: "baz"}},
+		{Name: "busy", Namespace: "test", Manifest: api.ContainerManifest{ID: "busy"}},
+	}
+	for i := range pods {
+		if err := kubelet.makePodDataDirs(&pods[i]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := os.Stat(path.Join(tempDir, "pods/foo/containers/bar")); err != nil {
+		t.Errorf("expected the container directory to exist: %v", err)
+	}
+	// A deleted pod whose volume is still there keeps its directory.
+	leftover := path.Join(tempDir, "pods/busy/volumes/nfs/data/file")
+	if err := os.MkdirAll(path.Dir(leftover), 0750); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(leftover, []byte("data"), 0640); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := kubelet.cleanupOrphanedPodDirs(pods[:1]); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for dir, exists := range map[string]bool{"foo": true, "baz": false, "busy": true} {
+		if _, err := os.Stat(path.Join(tempDir, "pods", dir)); os.IsNotExist(err) == exists {
+			t.Errorf("expected directory of pod %s to exist: %v, got %v", dir, exists, err)
+		}
+	}
+	if _, err := os.Stat(leftover); err != nil {
+		t.Errorf("expected the leftover file to be kept: %v", err)
+	}
+}
+
+func TestCleanupOrphans(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	tempDir, err := ioutil.TempDir("", "kubelet")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	kubelet.rootDirectory = tempDir
+	for _, dir := range []string{"pods/foo/volumes/empty/data", "pods/gone/volumes/empty/data", "pods/gone/containers/bar"} {
+		if err := os.MkdirAll(path.Join(tempDir, dir), 0750); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	pods := []Pod{
+		{
+			Name:      "foo",
+			Namespace: "test",
+			Manifest: api.ContainerManifest{
+				ID:      "foo",
+				Volumes: []api.Volume{{Name: "data", Source: &api.VolumeSource{EmptyDirectory: &api.EmptyDirectory{}}}},
+			},
+		},
+	}
+	exists := func(dir string) bool {
+		_, err := os.Stat(path.Join(tempDir, dir))
+		return err == nil
+	}
+
+	// Nothing is cleaned up before the first sync.
+	if err := kubelet.CleanupOrphans(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !exists("pods/gone") {
+		t.Errorf("expected nothing to be cleaned up before the pods are synced")
+	}
+
+	kubelet.podLock.Lock()
+	kubelet.pods, kubelet.podsSynced = pods, true
+	kubelet.podLock.Unlock()
+	// Nor while containers of a deleted pod still run.
+	fakeDocker.containerList = []docker.APIContainers{
+		{Names: []string{"/k8s--bar--gone.test--1"}, ID: "1234"},
+	}
+	if err := kubelet.CleanupOrphans(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !exists("pods/gone/volumes/empty/data") {
+		t.Errorf("expected the volume of a pod with running containers to be kept")
+	}
+
+	fakeDocker.containerList = []docker.APIContainers{}
+	if err := kubelet.CleanupOrphans(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if exists("pods/gone") {
+		t.Errorf("expected the directory of the deleted pod to be removed")
+	}
+	if !exists("pods/foo/volumes/empty/data") {
+		t.Errorf("expected the volume of the desired pod to be kept")
+	}
+}
+
+func TestSetupDataDirsMovesLegacyPodDirs(t *testing.T) {
+	kubelet, _ := newTestKubelet(t)
+	tempDir, err := ioutil.TempDir("", "kubelet")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	kubelet.rootDirectory = tempDir
+
+	legacy := path.Join(tempDir, "foo/volumes/empty/data/file")
+	if err := os.MkdirAll(path.Dir(legacy), 0750); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ioutil.WriteFile(legacy, []byte("data"), 0640); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Directories which aren't pods are left alone.
+	if err := os.MkdirAll(path.Join(tempDir, "other"), 0750); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := kubelet.SetupDataDirs(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path.Join(tempDir, "pods/foo/volumes/empty/data/file")); err != nil {
+		t.Errorf("expected the pod directory to be moved: %v", err)
+	}
+	if _, err := os.Stat(path.Join(tempDir, "foo")); !os.IsNotExist(err) {
+		t.Errorf("expected the legacy directory to be gone: %v", err)
+	}
+	if _, err := os.Stat(path.Join(tempDir, "other")); err != nil {
+		t.Errorf("expected other directories to be kept: %v", err)
+	}
+	if _, exists := volume.GetCurrentVolumes(tempDir)["foo/data"]; !exists {
+		t.Errorf("expected the moved volume to be found")
+	}
+}
+
 func TestMakeVolumesAndBinds(t *testing.T) {
 	container := api.Container{
 		VolumeMounts: []api.VolumeMount{
@@ -709,7 +1865,7 @@ func TestMakeVolumesAndBinds(t *testing.T) {
 
 	expectedVolumes := []string{"/mnt/path", "/mnt/path2"}
 	expectedBinds := []string{"/exports/pod.test/disk:/mnt/path", "/exports/pod.test/disk2:/mnt/path2:ro", "/mnt/path3:/mnt/path3",
-		"/mnt/host:/mnt/path4", "/var/lib/kubelet/podID/volumes/empty/disk5:/mnt/path5"}
+		"/mnt/host:/mnt/path4", "/var/lib/kubelet/pods/podID/volumes/empty/disk5:/mnt/path5"}
 
 	if len(volumes) != len(expectedVolumes) {
 		t.Errorf("Unexpected volumes. Expected %#v got %#v.  Container was: %#v", expectedVolumes, volumes, container)
@@ -887,7 +2043,7 @@ func TestGetContainerInfo(t *testing.T) {
 	cadvisorReq := getCadvisorContainerInfoRequest(req)
 	mockCadvisor.On("ContainerInfo", containerPath, cadvisorReq).Return(containerInfo, nil)
 
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	kubelet.cadvisorClient = mockCadvisor
 	fakeDocker.containerList = []docker.APIContainers{
 		{
@@ -958,7 +2114,7 @@ func TestGetRooInfo(t *testing.T) {
 }
 
 func TestGetContainerInfoWithoutCadvisor(t *testing.T) {
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	fakeDocker.containerList = []docker.APIContainers{
 		{
 			ID: "foobar",
@@ -995,7 +2151,7 @@ func TestGetContainerInfoWhenCadvisorFailed(t *testing.T) {
 	expectedErr := fmt.Errorf("some error")
 	mockCadvisor.On("ContainerInfo", containerPath, cadvisorReq).Return(containerInfo, expectedErr)
 
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	kubelet.cadvisorClient = mockCadvisor
 	fakeDocker.containerList = []docker.APIContainers{
 		{
@@ -1023,7 +2179,7 @@ func TestGetContainerInfoWhenCadvisorFailed(t *testing.T) {
 func TestGetContainerInfoOnNonExistContainer(t *testing.T) {
 	mockCadvisor := &mockCadvisorClient{}
 
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	kubelet.cadvisorClient = mockCadvisor
 	fakeDocker.containerList = []docker.APIContainers{}
 
@@ -1046,9 +2202,18 @@ func (f *fakeContainerCommandRunner) RunInContainer(id string, cmd []string) ([]
 	return []byte{}, f.E
 }
 
+func (f *fakeContainerCommandRunner) ExecInContainer(id string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	f.Cmd = cmd
+	f.ID = id
+	if stdin != nil {
+		io.Copy(stdout, stdin)
+	}
+	return f.E
+}
+
 func TestRunInContainerNoSuchPod(t *testing.T) {
 	fakeCommandRunner := fakeContainerCommandRunner{}
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	fakeDocker.containerList = []docker.APIContainers{}
 	kubelet.runner = &fakeCommandRunner
 
@@ -1069,7 +2234,7 @@ func TestRunInContainerNoSuchPod(t *testing.T) {
 
 func TestRunInContainer(t *testing.T) {
 	fakeCommandRunner := fakeContainerCommandRunner{}
-	kubelet, _, fakeDocker := newTestKubelet(t)
+	kubelet, fakeDocker := newTestKubelet(t)
 	kubelet.runner = &fakeCommandRunner
 
 	containerID := "abc1234"
@@ -1100,6 +2265,116 @@ func TestRunInContainer(t *testing.T) {
 	}
 }
 
+func TestGetKubeletContainerLogs(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			ID:    "abc1234",
+			Names: []string{"/k8s--containerFoo--podFoo.etcd--1234"},
+		},
+	}
+	fakeDocker.logs = "some output\n"
+
+	var stdout bytes.Buffer
+	err := kubelet.GetKubeletContainerLogs("podFoo.etcd", "containerFoo", "10", true, &stdout, &stdout)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if stdout.String() != "some output\n" {
+		t.Errorf("unexpected logs: %q", stdout.String())
+	}
+	opts := fakeDocker.logsOptions[0]
+	if opts.Container != "abc1234" || opts.Tail != "10" || !opts.Follow || !opts.Stdout || !opts.Stderr {
+		t.Errorf("unexpected logs options: %+v", opts)
+	}
+
+	err = kubelet.GetKubeletContainerLogs("podFoo.etcd", "containerBar", "all", false, &stdout, &stdout)
+	if err != ErrContainerNotFound {
+		t.Errorf("expected ErrContainerNotFound, got %v", err)
+	}
+}
+
+func TestExecInContainer(t *testing.T) {
+	fakeCommandRunner := fakeContainerCommandRunner{}
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.runner = &fakeCommandRunner
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			ID:    "abc1234",
+			Names: []string{"/k8s--containerFoo--podFoo.etcd--1234"},
+		},
+	}
+
+	var stdout bytes.Buffer
+	err := kubelet.ExecInContainer("podFoo.etcd", "containerFoo", []string{"cat"}, strings.NewReader("input"), &stdout, &stdout)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if fakeCommandRunner.ID != "abc1234" || !reflect.DeepEqual(fakeCommandRunner.Cmd, []string{"cat"}) {
+		t.Errorf("unexpected command %v in %s", fakeCommandRunner.Cmd, fakeCommandRunner.ID)
+	}
+	if stdout.String() != "input" {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+
+	err = kubelet.ExecInContainer("podFoo.etcd", "containerBar", []string{"cat"}, nil, &stdout, &stdout)
+	if err != ErrContainerNotFound {
+		t.Errorf("expected ErrContainerNotFound, got %v", err)
+	}
+}
+
+func TestKubeletPortForward(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	var commandPid int
+	var commandPort uint16
+	kubelet.portForwardCommand = func(pid int, port uint16) *exec.Cmd {
+		commandPid, commandPort = pid, port
+		return exec.Command("tr", "a-z", "A-Z")
+	}
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			ID:    "net1234",
+			Names: []string{"/k8s--net--podFoo.etcd--1234"},
+		},
+	}
+	fakeDocker.container = &docker.Container{
+		State: docker.State{Pid: 42},
+	}
+
+	conn, err := kubelet.PortForward("podFoo.etcd", 8080)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if commandPid != 42 || commandPort != 8080 {
+		t.Errorf("expected port 8080 in the namespace of pid 42, got %d in %d", commandPort, commandPid)
+	}
+	io.WriteString(conn, "ping")
+	if err := conn.CloseWrite(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	data, err := ioutil.ReadAll(conn)
+	if err != nil || string(data) != "PING" {
+		t.Errorf("expected %q, got %q (%v)", "PING", data, err)
+	}
+	conn.Close()
+
+	if _, err := kubelet.PortForward("podBar.etcd", 8080); err != ErrContainerNotFound {
+		t.Errorf("expected ErrContainerNotFound, got %v", err)
+	}
+	fakeDocker.container = &docker.Container{}
+	if _, err := kubelet.PortForward("podFoo.etcd", 8080); err == nil {
+		t.Errorf("expected an error for a network container which isn't running")
+	}
+}
+
+func TestNsenterPortForwardCommand(t *testing.T) {
+	cmd := nsenterPortForwardCommand(42, 8080)
+	expected := []string{"nsenter", "-t", "42", "-n", "socat", "-t", "3600", "-", "TCP4:localhost:8080"}
+	if !reflect.DeepEqual(cmd.Args, expected) {
+		t.Errorf("expected %v, got %v", expected, cmd.Args)
+	}
+}
+
 func TestDockerContainerCommand(t *testing.T) {
 	runner := dockerContainerCommandRunner{}
 	containerID := "1234"
@@ -1114,6 +2389,18 @@ func TestDockerContainerCommand(t *testing.T) {
 
 }
 
+func TestDockerExecCommand(t *testing.T) {
+	runner := dockerExecCommandRunner{"docker"}
+	cmd := runner.getRunInContainerCommand("1234", []string{"ls", "-l"})
+	if !reflect.DeepEqual(cmd.Args, []string{"docker", "exec", "1234", "ls", "-l"}) {
+		t.Errorf("unexpected command args: %s", cmd.Args)
+	}
+	cmd = runner.getExecInContainerCommand("1234", []string{"cat"}, true)
+	if !reflect.DeepEqual(cmd.Args, []string{"docker", "exec", "-i", "1234", "cat"}) {
+		t.Errorf("unexpected command args: %s", cmd.Args)
+	}
+}
+
 var parseImageNameTests = []struct {
 	imageName string
 	name      string
@@ -1136,3 +2423,208 @@ func TestParseImageName(t *testing.T) {
 		}
 	}
 }
+
+func TestDockerPullerUsesKeyring(t *testing.T) {
+	fakeDocker := &FakeDockerClient{}
+	keyring := &credentialprovider.BasicDockerKeyring{}
+	keyring.Add(credentialprovider.DockerConfig{"quay.io": {Username: "foo", Password: "bar"}})
+	puller := dockerPuller{client: fakeDocker, keyring: keyring}
+	for _, image := range []string{"quay.io/foo/bar", "ubuntu"} {
+		if err := puller.Pull(image); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	expected := []docker.AuthConfiguration{{Username: "foo", Password: "bar"}, {}}
+	if !reflect.DeepEqual(fakeDocker.pulledAuths, expected) {
+		t.Errorf("expected %#v, got %#v", expected, fakeDocker.pulledAuths)
+	}
+}
+
+func TestPullImagePolicies(t *testing.T) {
+	tests := []struct {
+		image   string
+		policy  api.PullPolicy
+		present bool
+		pulled  bool
+	}{
+		{"foo", "", true, true},
+		{"foo:latest", "", true, true},
+		{"foo:v1", "", true, false},
+		{"foo:v1", "", false, true},
+		{"foo:v1", api.PullAlways, true, true},
+		{"foo", api.PullIfNotPresent, true, false},
+		{"foo", api.PullNever, false, false},
+	}
+	for _, test := range tests {
+		kubelet, _ := newTestKubelet(t)
+		puller := &FakeDockerPuller{}
+		if test.present {
+			puller.PresentImages = []string{test.image}
+		}
+		kubelet.dockerPuller = puller
+		container := &api.Container{Image: test.image, ImagePullPolicy: test.policy}
+		if err := kubelet.pullImage("foo.test", container); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if pulled := len(puller.ImagesPulled) == 1; pulled != test.pulled {
+			t.Errorf("image %s, policy %q, present %v: expected pulled %v, got %v", test.image, test.policy, test.present, test.pulled, pulled)
+		}
+	}
+}
+
+func TestPullImageEvents(t *testing.T) {
+	kubelet, _ := newTestKubelet(t)
+	puller := &FakeDockerPuller{}
+	kubelet.dockerPuller = puller
+	events, stop := recordEvents()
+	defer stop()
+
+	if err := kubelet.pullImage("foo.test", &api.Container{Name: "bar", Image: "foo"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	event, _ := waitForEvent(t, events, "bar", "pulled")
+	if event.InvolvedObject.ID != "foo" || event.Message != "Pulled image foo" {
+		t.Errorf("unexpected event: %#v", event)
+	}
+
+	puller.ErrorsToInject = []error{fmt.Errorf("no such image")}
+	if err := kubelet.pullImage("foo.test", &api.Container{Name: "bar", Image: "foo"}); err == nil {
+		t.Errorf("expected an error")
+	}
+	event, _ = waitForEvent(t, events, "bar", "failed")
+	if !strings.Contains(event.Message, "no such image") {
+		t.Errorf("unexpected event: %#v", event)
+	}
+}
+
+func TestRecordExit(t *testing.T) {
+	tests := []struct {
+		memory   int
+		exitCode int
+		reason   string
+	}{
+		{0, 1, "exited"},
+		{0, 137, "exited"},
+		{1024, 1, "exited"},
+		{1024, 137, "oomKilled"},
+	}
+	for _, test := range tests {
+		events, stop := recordEvents()
+		recordExit("foo.test", &api.Container{Name: "bar", Memory: test.memory}, &docker.Container{ID: "1234", State: docker.State{ExitCode: test.exitCode}}, 0)
+		event, _ := waitForEvent(t, events, "bar", test.reason)
+		stop()
+		if event.InvolvedObject.ID != "foo" || !strings.Contains(event.Message, "1234") {
+			t.Errorf("memory %d, exit code %d: unexpected event %#v", test.memory, test.exitCode, event)
+		}
+	}
+}
+
+func TestContainerRef(t *testing.T) {
+	expected := api.ObjectReference{Kind: "Pod", ID: "foo.bar", FieldPath: "desiredState.manifest.containers[baz]"}
+	if ref := containerRef("foo.bar.etcd", "baz"); ref != expected {
+		t.Errorf("expected %#v, got %#v", expected, ref)
+	}
+}
+
+func TestDockerPullerIsImagePresent(t *testing.T) {
+	fakeDocker := &FakeDockerClient{presentImages: []string{"foo:v1"}}
+	puller := dockerPuller{client: fakeDocker}
+	if present, err := puller.IsImagePresent("foo:v1"); !present || err != nil {
+		t.Errorf("expected image to be present, got %v, %v", present, err)
+	}
+	if present, err := puller.IsImagePresent("foo:v2"); present || err != nil {
+		t.Errorf("expected image to be missing, got %v, %v", present, err)
+	}
+}
+
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "ip+net" }
+func (a fakeAddr) String() string  { return string(a) }
+
+func TestRegisterMinion(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.hostname = "machine"
+	kubelet.minionLabels = map[string]string{"disk": "ssd"}
+	mockCadvisor := &mockCadvisorClient{}
+	mockCadvisor.On("MachineInfo").Return(&info.MachineInfo{NumCores: 2, MemoryCapacity: 1024}, nil)
+	kubelet.cadvisorClient = mockCadvisor
+	interfaceAddrs = func() ([]net.Addr, error) {
+		return []net.Addr{fakeAddr("127.0.0.1/8"), fakeAddr("10.240.0.5/32"), fakeAddr("fe80::1/64")}, nil
+	}
+	defer func() { interfaceAddrs = net.InterfaceAddrs }()
+
+	fakeClient := &client.Fake{}
+	kubelet.RegisterMinion(fakeClient)
+	fakeDocker.err = fmt.Errorf("docker is down")
+	kubelet.RegisterMinion(fakeClient)
+
+	expected := api.Minion{
+		JSONBase: api.JSONBase{ID: "machine"},
+		Labels:   map[string]string{"disk": "ssd"},
+		NodeResources: api.NodeResources{
+			Capacity: api.ResourceList{api.ResourceCPU: 2000, api.ResourceMemory: 1024},
+		},
+		Status: api.MinionStatus{
+			Condition:      api.MinionReady,
+			Addresses:      []string{"10.240.0.5", "fe80::1"},
+			KubeletVersion: version.Get().String(),
+		},
+	}
+	notReady := expected
+	notReady.Status.Condition = api.MinionNotReady
+	expectedActions := []client.FakeAction{
+		{Action: "create-minion", Value: expected},
+		{Action: "create-minion", Value: notReady},
+	}
+	if !reflect.DeepEqual(expectedActions, fakeClient.Actions) {
+		t.Errorf("expected %#v, got %#v", expectedActions, fakeClient.Actions)
+	}
+}
+
+func TestReportPodStatus(t *testing.T) {
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.hostname = "machine"
+	fakeDocker.containerList = []docker.APIContainers{
+		{
+			Names: []string{"/k8s--bar--foo.etcd--1"},
+			ID:    "1234",
+		},
+		{
+			Names: []string{"/k8s--net--foo.etcd--"},
+			ID:    "9876",
+		},
+		{
+			// Not scheduled by the master.
+			Names: []string{"/k8s--bar--static.file--1"},
+			ID:    "4567",
+		},
+	}
+	fakeDocker.containerMap = map[string]*docker.Container{
+		"9876": {NetworkSettings: &docker.NetworkSettings{IPAddress: "10.1.2.3"}},
+	}
+	fakeClient := &client.Fake{}
+	kubelet.ReportPodStatus(fakeClient)
+
+	if len(fakeClient.Actions) != 1 || fakeClient.Actions[0].Action != "report-pod-status" {
+		t.Fatalf("unexpected actions: %#v", fakeClient.Actions)
+	}
+	report := fakeClient.Actions[0].Value.(api.PodStatusReport)
+	if report.ID != "foo" || report.Host != "machine" || len(report.Info) != 2 {
+		t.Errorf("unexpected report: %#v", report)
+	}
+	if len(report.Conditions) != 0 {
+		t.Errorf("unexpected conditions of a pod never synced: %v", report.Conditions)
+	}
+	if report.PodIP != "10.1.2.3" {
+		t.Errorf("expected the network container's IP, got %q", report.PodIP)
+	}
+
+	kubelet.readiness.set("foo.etcd", true)
+	fakeClient = &client.Fake{}
+	kubelet.ReportPodStatus(fakeClient)
+	report = fakeClient.Actions[0].Value.(api.PodStatusReport)
+	if !reflect.DeepEqual(report.Conditions, []api.PodCondition{api.PodReady}) {
+		t.Errorf("unexpected conditions: %v", report.Conditions)
+	}
+}
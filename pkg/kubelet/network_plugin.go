@@ -0,0 +1,95 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// NetworkPlugin sets up the network of pods, after docker gave their network
+// container its own network namespace and before any other container of the pod
+// starts. Every container of a pod shares that namespace.
+type NetworkPlugin interface {
+	// Name returns the name of the plugin, for logs and events.
+	Name() string
+	// SetUpPod configures the network namespace at netnsPath, e.g.
+	// /proc/<pid>/ns/net, of the pod's network container containerID.
+	SetUpPod(namespace, name, containerID, netnsPath string) error
+	// TearDownPod undoes SetUpPod before the network container is stopped.
+	TearDownPod(namespace, name, containerID, netnsPath string) error
+}
+
+// noopNetworkPlugin leaves the pod network to docker.
+type noopNetworkPlugin struct{}
+
+// NewNoopNetworkPlugin returns the default NetworkPlugin, which does nothing.
+func NewNoopNetworkPlugin() NetworkPlugin {
+	return noopNetworkPlugin{}
+}
+
+func (noopNetworkPlugin) Name() string {
+	return "noop"
+}
+
+func (noopNetworkPlugin) SetUpPod(namespace, name, containerID, netnsPath string) error {
+	return nil
+}
+
+func (noopNetworkPlugin) TearDownPod(namespace, name, containerID, netnsPath string) error {
+	return nil
+}
+
+// execNetworkPlugin runs an executable to set up and tear down pod networks.
+type execNetworkPlugin struct {
+	path string
+	// Defaults to running the command and returning its combined output.
+	run func(cmd *exec.Cmd) ([]byte, error)
+}
+
+// NewExecNetworkPlugin returns a NetworkPlugin which runs the executable at path as
+//
+//	<path> setup|teardown <namespace> <name> <container ID> <netns path>
+//
+// A pod's network counts as set up or torn down once the executable exits with 0.
+func NewExecNetworkPlugin(path string) NetworkPlugin {
+	return &execNetworkPlugin{path: path}
+}
+
+func (p *execNetworkPlugin) Name() string {
+	return p.path
+}
+
+func (p *execNetworkPlugin) SetUpPod(namespace, name, containerID, netnsPath string) error {
+	return p.exec("setup", namespace, name, containerID, netnsPath)
+}
+
+func (p *execNetworkPlugin) TearDownPod(namespace, name, containerID, netnsPath string) error {
+	return p.exec("teardown", namespace, name, containerID, netnsPath)
+}
+
+func (p *execNetworkPlugin) exec(action string, args ...string) error {
+	cmd := exec.Command(p.path, append([]string{action}, args...)...)
+	run := p.run
+	if run == nil {
+		run = (*exec.Cmd).CombinedOutput
+	}
+	if output, err := run(cmd); err != nil {
+		return fmt.Errorf("network plugin %s %s failed: %v: %s", p.path, action, err, output)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"fmt"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestExecNetworkPlugin(t *testing.T) {
+	var args [][]string
+	var err error
+	plugin := &execNetworkPlugin{
+		path: "/opt/net/plugin",
+		run: func(cmd *exec.Cmd) ([]byte, error) {
+			args = append(args, cmd.Args)
+			return []byte("bridge missing"), err
+		},
+	}
+	if err := plugin.SetUpPod("test", "foo", "1234", "/proc/42/ns/net"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := plugin.TearDownPod("test", "foo", "1234", "/proc/42/ns/net"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := [][]string{
+		{"/opt/net/plugin", "setup", "test", "foo", "1234", "/proc/42/ns/net"},
+		{"/opt/net/plugin", "teardown", "test", "foo", "1234", "/proc/42/ns/net"},
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+
+	err = fmt.Errorf("exit status 1")
+	if err := plugin.SetUpPod("test", "foo", "1234", "/proc/42/ns/net"); err == nil {
+		t.Errorf("expected the failed command to fail")
+	}
+}
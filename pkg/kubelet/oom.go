@@ -0,0 +1,106 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	// KubeletOOMScoreAdj is the default oom_score_adj of the kubelet, which the
+	// kernel should hardly ever pick when it runs out of memory.
+	KubeletOOMScoreAdj = -900
+	// DockerOOMScoreAdj is the default oom_score_adj of the docker daemon.
+	DockerOOMScoreAdj = -900
+	// networkContainerOOMScoreAdj is the oom_score_adj of network containers.
+	// They use next to no memory, and killing one takes down its whole pod.
+	networkContainerOOMScoreAdj = -998
+	// bestEffortOOMScoreAdj is the oom_score_adj of containers without a memory
+	// limit, which go first when the machine runs out of memory.
+	bestEffortOOMScoreAdj = 1000
+	// The range of oom_score_adj of containers with a memory limit: above the
+	// system daemons and below the containers without a limit.
+	minLimitedOOMScoreAdj = 2
+	maxLimitedOOMScoreAdj = 999
+)
+
+// containerOOMScoreAdj returns the oom_score_adj for a container with the given
+// memory limit on a machine with machineMemory bytes, zero when unknown.
+// Containers without a limit are killed first, then those with a limit, the
+// ones with the smallest share of the machine's memory first.
+func containerOOMScoreAdj(memoryLimit int, machineMemory int64) int {
+	if memoryLimit <= 0 {
+		return bestEffortOOMScoreAdj
+	}
+	if machineMemory <= 0 {
+		return maxLimitedOOMScoreAdj
+	}
+	adj := 1000 - int(1000*int64(memoryLimit)/machineMemory)
+	if adj < minLimitedOOMScoreAdj {
+		return minLimitedOOMScoreAdj
+	}
+	if adj > maxLimitedOOMScoreAdj {
+		return maxLimitedOOMScoreAdj
+	}
+	return adj
+}
+
+// findCgroupTasksFile returns the tasks file of the cgroup a process is in,
+// given the contents of its /proc/<pid>/cgroup and of /proc/mounts. The first
+// hierarchy of the process which is mounted is used; a container is in the
+// same cgroup in each.
+func findCgroupTasksFile(procCgroup, procMounts string) (string, error) {
+	for _, line := range strings.Split(procCgroup, "\n") {
+		// e.g. "4:memory:/docker/<id>"
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 || parts[1] == "" {
+			continue
+		}
+		subsystems := strings.Split(parts[1], ",")
+		for _, mount := range strings.Split(procMounts, "\n") {
+			// e.g. "cgroup /sys/fs/cgroup/memory cgroup rw,nosuid,memory 0 0"
+			fields := strings.Fields(mount)
+			if len(fields) < 4 || fields[2] != "cgroup" {
+				continue
+			}
+			options := strings.Split(fields[3], ",")
+			if hasAll(options, subsystems) {
+				return path.Join(fields[1], parts[2], "tasks"), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no mounted cgroup hierarchy found")
+}
+
+// hasAll returns whether every one of wanted is in values.
+func hasAll(values, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, v := range values {
+			if v == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ApplyOOMScoreAdj sets the oom_score_adj of the process pid, or of the calling
+// process if pid is 0. Lowering it needs the CAP_SYS_RESOURCE capability.
+func ApplyOOMScoreAdj(pid int, value int) error {
+	if value < -1000 || value > 1000 {
+		return fmt.Errorf("invalid oom_score_adj %d, must be between -1000 and 1000", value)
+	}
+	process := "self"
+	if pid != 0 {
+		process = strconv.Itoa(pid)
+	}
+	return ioutil.WriteFile(path.Join("/proc", process, "oom_score_adj"), []byte(strconv.Itoa(value)), 0700)
+}
+
+// maxOOMScoreAdjPasses bounds how often applyCgroupOOMScoreAdj looks for
+// processes forked while it was adjusting the others.
+const maxOOMScoreAdjPasses = 5
+
+// applyCgroupOOMScoreAdj sets the oom_score_adj of every process in the cgroup
+// of process pid, the first process of a container. Processes it forked before
+// being adjusted don't inherit the value, so they are found in the tasks of the
+// cgroup, until no new ones turn up.
+func applyCgroupOOMScoreAdj(pid int, value int) error {
+	procCgroup, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return err
+	}
+	procMounts, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return err
+	}
+	tasksFile, err := findCgroupTasksFile(string(procCgroup), string(procMounts))
+	if err != nil {
+		return err
+	}
+	if err := ApplyOOMScoreAdj(pid, value); err != nil {
+		return err
+	}
+	adjusted := map[string]bool{strconv.Itoa(pid): true}
+	for pass := 0; pass < maxOOMScoreAdjPasses; pass++ {
+		tasks, err := ioutil.ReadFile(tasksFile)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, task := range strings.Fields(string(tasks)) {
+			if adjusted[task] {
+				continue
+			}
+			adjusted[task] = true
+			found = true
+			taskPid, err := strconv.Atoi(task)
+			if err != nil {
+				return err
+			}
+			if err := ApplyOOMScoreAdj(taskPid, value); err != nil && !processExited(err) {
+				return err
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return fmt.Errorf("processes of pid %d kept forking while their oom_score_adj was set", pid)
+}
+
+// processExited returns whether err is from writing to the /proc of a process
+// which is gone.
+func processExited(err error) bool {
+	if os.IsNotExist(err) {
+		return true
+	}
+	pathErr, ok := err.(*os.PathError)
+	return ok && pathErr.Err == syscall.ESRCH
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"testing"
+)
+
+func TestContainerOOMScoreAdj(t *testing.T) {
+	tests := []struct {
+		memoryLimit   int
+		machineMemory int64
+		expected      int
+	}{
+		{0, 1000, 1000},
+		{0, 0, 1000},
+		{100, 0, 999},
+		{100, 1000, 900},
+		{500, 1000, 500},
+		{1, 1000000, 999},
+		{1000, 1000, 2},
+		{2000, 1000, 2},
+	}
+	for _, test := range tests {
+		if adj := containerOOMScoreAdj(test.memoryLimit, test.machineMemory); adj != test.expected {
+			t.Errorf("limit %d of %d: expected %d, got %d", test.memoryLimit, test.machineMemory, test.expected, adj)
+		}
+	}
+}
+
+func TestFindCgroupTasksFile(t *testing.T) {
+	mounts := `rootfs / rootfs rw 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+cgroup /sys/fs/cgroup/cpu,cpuacct cgroup rw,nosuid,nodev,noexec,relatime,cpuacct,cpu 0 0
+cgroup /sys/fs/cgroup/memory cgroup rw,nosuid,nodev,noexec,relatime,memory 0 0
+`
+	tests := []struct {
+		cgroup   string
+		expected string
+	}{
+		{"4:memory:/docker/abc\n3:cpuacct,cpu:/docker/abc\n", "/sys/fs/cgroup/memory/docker/abc/tasks"},
+		{"5:blkio:/docker/abc\n3:cpuacct,cpu:/docker/abc\n", "/sys/fs/cgroup/cpu,cpuacct/docker/abc/tasks"},
+		{"5:blkio:/docker/abc\n", ""},
+	}
+	for _, test := range tests {
+		tasksFile, err := findCgroupTasksFile(test.cgroup, mounts)
+		if tasksFile != test.expected || (err != nil) != (test.expected == "") {
+			t.Errorf("%q: expected %q, got %q (%v)", test.cgroup, test.expected, tasksFile, err)
+		}
+	}
+}
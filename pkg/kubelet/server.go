@@ -17,6 +17,8 @@ limitations under the License.
 package kubelet
 
 import (
+	"bufio"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,6 +30,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
@@ -42,6 +45,8 @@ type Server struct {
 	host    HostInterface
 	updates chan<- interface{}
 	mux     *http.ServeMux
+	// Whether only GET and HEAD requests are served.
+	readOnly bool
 }
 
 // ListenAndServeKubeletServer initializes a server to respond to HTTP network requests on the Kubelet
@@ -58,14 +63,34 @@ func ListenAndServeKubeletServer(host HostInterface, updates chan<- interface{},
 	s.ListenAndServe()
 }
 
+// ListenAndServeKubeletReadOnlyServer initializes a server which serves the read-only
+// handlers of the kubelet, e.g. for monitoring agents which must not change anything.
+func ListenAndServeKubeletReadOnlyServer(host HostInterface, address string, port uint) {
+	glog.Infof("Starting to listen read-only on %s:%d", address, port)
+	handler := NewReadOnlyServer(host)
+	s := &http.Server{
+		Addr:           net.JoinHostPort(address, strconv.FormatUint(uint64(port), 10)),
+		Handler:        &handler,
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+	s.ListenAndServe()
+}
+
 // HostInterface contains all the kubelet methods required by the server.
 // For testablitiy.
 type HostInterface interface {
 	GetContainerInfo(podFullName, containerName string, req *info.ContainerInfoRequest) (*info.ContainerInfo, error)
 	GetRootInfo(req *info.ContainerInfoRequest) (*info.ContainerInfo, error)
 	GetMachineInfo() (*info.MachineInfo, error)
+	GetStatsSummary() (*StatsSummary, error)
 	GetPodInfo(name string) (api.PodInfo, error)
+	GetPods() []Pod
 	ServeLogs(w http.ResponseWriter, req *http.Request)
+	GetKubeletContainerLogs(podFullName, containerName, tail string, follow bool, stdout, stderr io.Writer) error
+	ExecInContainer(podFullName, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error
+	PortForward(podFullName string, port uint16) (PortForwardConn, error)
 }
 
 // NewServer initializes and configures a kubelet.Server object to handle HTTP requests
@@ -79,15 +104,43 @@ func NewServer(host HostInterface, updates chan<- interface{}) Server {
 	return server
 }
 
+// NewReadOnlyServer initializes a kubelet.Server object which only serves what
+// InstallReadOnlyHandlers registers, and only to GET and HEAD requests.
+func NewReadOnlyServer(host HostInterface) Server {
+	server := Server{
+		host:     host,
+		mux:      http.NewServeMux(),
+		readOnly: true,
+	}
+	server.InstallReadOnlyHandlers()
+	return server
+}
+
+// InstallReadOnlyHandlers registers the HTTP request patterns which neither change
+// anything on the host nor reveal what runs in its containers. On a read-only
+// server, /pods and /podInfo leave out the commands and environments of the
+// containers, which may carry secrets.
+func (s *Server) InstallReadOnlyHandlers() {
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	s.mux.HandleFunc("/pods", s.handlePods)
+	s.mux.HandleFunc("/podInfo", s.handlePodInfo)
+	s.mux.HandleFunc("/stats/", s.handleStats)
+	s.mux.HandleFunc("/spec/", s.handleSpec)
+}
+
 // InstallDefaultHandlers registers the set of supported HTTP request patterns with the mux
 func (s *Server) InstallDefaultHandlers() {
 	s.mux.HandleFunc("/healthz", s.handleHealth)
 	s.mux.HandleFunc("/container", s.handleContainer)
 	s.mux.HandleFunc("/containers", s.handleContainers)
+	s.mux.HandleFunc("/pods", s.handlePods)
 	s.mux.HandleFunc("/podInfo", s.handlePodInfo)
 	s.mux.HandleFunc("/stats/", s.handleStats)
 	s.mux.HandleFunc("/logs/", s.handleLogs)
 	s.mux.HandleFunc("/spec/", s.handleSpec)
+	s.mux.HandleFunc("/containerLogs/", s.handleContainerLogs)
+	s.mux.HandleFunc("/exec/", s.handleExec)
+	s.mux.HandleFunc("/portForward/", s.handlePortForward)
 }
 
 // error serializes an error object into an HTTP response
@@ -143,6 +196,22 @@ func (s *Server) handleContainers(w http.ResponseWriter, req *http.Request) {
 
 }
 
+// handlePods handles pods requests against the Kubelet, returning the pods it
+// should be running.
+func (s *Server) handlePods(w http.ResponseWriter, req *http.Request) {
+	pods := s.host.GetPods()
+	if s.readOnly {
+		pods = podsWithoutSecrets(pods)
+	}
+	data, err := json.Marshal(pods)
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+	w.Header().Add("Content-type", "application/json")
+	w.Write(data)
+}
+
 // handlePodInfo handles podInfo requests against the Kubelet
 func (s *Server) handlePodInfo(w http.ResponseWriter, req *http.Request) {
 	u, err := url.ParseRequestURI(req.RequestURI)
@@ -167,6 +236,9 @@ func (s *Server) handlePodInfo(w http.ResponseWriter, req *http.Request) {
 		s.error(w, err)
 		return
 	}
+	if s.readOnly {
+		info = podInfoWithoutSecrets(info)
+	}
 	data, err := json.Marshal(info)
 	if err != nil {
 		s.error(w, err)
@@ -177,6 +249,42 @@ func (s *Server) handlePodInfo(w http.ResponseWriter, req *http.Request) {
 	w.Write(data)
 }
 
+// podsWithoutSecrets returns copies of pods without the commands and environments
+// of their containers.
+func podsWithoutSecrets(pods []Pod) []Pod {
+	stripped := make([]Pod, len(pods))
+	for i, pod := range pods {
+		containers := make([]api.Container, len(pod.Manifest.Containers))
+		for j, container := range pod.Manifest.Containers {
+			container.Command = nil
+			container.Env = nil
+			containers[j] = container
+		}
+		pod.Manifest.Containers = containers
+		stripped[i] = pod
+	}
+	return stripped
+}
+
+// podInfoWithoutSecrets returns a copy of info without the commands and
+// environments of the containers.
+func podInfoWithoutSecrets(info api.PodInfo) api.PodInfo {
+	stripped := api.PodInfo{}
+	for name, container := range info {
+		container.Path = ""
+		container.Args = nil
+		if container.Config != nil {
+			config := *container.Config
+			config.Cmd = nil
+			config.Entrypoint = nil
+			config.Env = nil
+			container.Config = &config
+		}
+		stripped[name] = container
+	}
+	return stripped
+}
+
 // handleStats handles stats requests against the Kubelet
 func (s *Server) handleStats(w http.ResponseWriter, req *http.Request) {
 	s.serveStats(w, req)
@@ -187,6 +295,274 @@ func (s *Server) handleLogs(w http.ResponseWriter, req *http.Request) {
 	s.host.ServeLogs(w, req)
 }
 
+// handleContainerLogs handles containerLogs requests against the Kubelet:
+// /containerLogs/<podID>/<containerName>?follow=true&tail=<lines>
+func (s *Server) handleContainerLogs(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(path.Clean(req.URL.Path), "/containerLogs/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "Expected /containerLogs/<podID>/<containerName>.", http.StatusBadRequest)
+		return
+	}
+	podID, containerName := parts[0], parts[1]
+	query := req.URL.Query()
+	follow, _ := strconv.ParseBool(query.Get("follow"))
+	tail := query.Get("tail")
+	if tail == "" {
+		tail = "all"
+	} else if tail != "all" {
+		if lines, err := strconv.Atoi(tail); err != nil || lines < 0 {
+			http.Error(w, fmt.Sprintf("Invalid tail %q: must be a number of lines or \"all\".", tail), http.StatusBadRequest)
+			return
+		}
+	}
+	// TODO: backwards compatibility with existing API, needs API change
+	podFullName := GetPodFullName(&Pod{Name: podID, Namespace: "etcd"})
+
+	w.Header().Set("Content-Type", "text/plain")
+	fw := &flushWriter{writer: w}
+	if flusher, ok := httplog.Unlogged(w).(http.Flusher); ok && follow {
+		fw.flusher = flusher
+	}
+	err := s.host.GetKubeletContainerLogs(podFullName, containerName, tail, follow, fw, fw)
+	if err == ErrContainerNotFound {
+		http.Error(w, "Container not found", http.StatusNotFound)
+		return
+	}
+	if err != nil && !fw.written {
+		s.error(w, err)
+		return
+	}
+	if err != nil {
+		glog.Errorf("Error streaming logs of %s/%s: %v", podFullName, containerName, err)
+	}
+}
+
+// flushWriter flushes after every write, so followed logs reach the client as they are written.
+type flushWriter struct {
+	lock    sync.Mutex
+	writer  io.Writer
+	flusher http.Flusher
+	written bool
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	fw.written = true
+	n, err := fw.writer.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+	return n, nil
+}
+
+// ExecProtocol is the protocol exec requests must ask to upgrade their connection to.
+const ExecProtocol = "kubernetes-exec"
+
+// The streams of the frames the exec protocol sends.
+const (
+	ExecStreamStdout byte = 1
+	ExecStreamStderr byte = 2
+	ExecStreamResult byte = 3
+)
+
+// handleExec handles exec requests against the Kubelet:
+// /exec/<podID>/<containerName>?command=<arg>&command=<arg>...&stdin=true
+//
+// The request must carry "Connection: Upgrade" and "Upgrade: kubernetes-exec".
+// Once the kubelet replies 101 Switching Protocols, everything the client sends
+// is the command's stdin (if stdin=true), and the kubelet sends the command's
+// output as frames with an 8 byte header: the stream, three zero bytes and the
+// big-endian uint32 length of the payload that follows. The last frame is on the
+// result stream; its payload is empty if the command succeeded and holds the
+// error otherwise. The kubelet then closes the connection.
+func (s *Server) handleExec(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(path.Clean(req.URL.Path), "/exec/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, "Expected /exec/<podID>/<containerName>.", http.StatusBadRequest)
+		return
+	}
+	podID, containerName := parts[0], parts[1]
+	query := req.URL.Query()
+	cmd := query["command"]
+	if len(cmd) == 0 {
+		http.Error(w, "Missing 'command=' query entry.", http.StatusBadRequest)
+		return
+	}
+	attachStdin, _ := strconv.ParseBool(query.Get("stdin"))
+	conn, buf, ok := s.upgrade(w, req, ExecProtocol)
+	if !ok {
+		return
+	}
+	defer conn.Close()
+	// TODO: backwards compatibility with existing API, needs API change
+	podFullName := GetPodFullName(&Pod{Name: podID, Namespace: "etcd"})
+
+	lock := &sync.Mutex{}
+	stdout := &execFrameWriter{lock, conn, ExecStreamStdout}
+	stderr := &execFrameWriter{lock, conn, ExecStreamStderr}
+	var stdin io.Reader
+	if attachStdin {
+		stdin = buf.Reader
+	}
+	result := ""
+	if err := s.host.ExecInContainer(podFullName, containerName, cmd, stdin, stdout, stderr); err != nil {
+		result = err.Error()
+	}
+	if _, err := (&execFrameWriter{lock, conn, ExecStreamResult}).Write([]byte(result)); err != nil {
+		glog.Errorf("Error writing the exec result of %s/%s: %v", podFullName, containerName, err)
+	}
+}
+
+// execFrameWriter writes everything written to it as frames of one stream of the exec protocol.
+type execFrameWriter struct {
+	lock   *sync.Mutex
+	writer io.Writer
+	stream byte
+}
+
+func (fw *execFrameWriter) Write(p []byte) (int, error) {
+	fw.lock.Lock()
+	defer fw.lock.Unlock()
+	header := [8]byte{fw.stream}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(p)))
+	if _, err := fw.writer.Write(header[:]); err != nil {
+		return 0, err
+	}
+	return fw.writer.Write(p)
+}
+
+// PortForwardProtocol is the protocol port forwarding requests must ask to upgrade their connection to.
+const PortForwardProtocol = "kubernetes-portforward"
+
+// handlePortForward handles port forwarding requests against the Kubelet:
+// /portForward/<podID>?port=<port>
+//
+// The request must carry "Connection: Upgrade" and "Upgrade: kubernetes-portforward".
+// Once the kubelet replies 101 Switching Protocols, the connection carries the raw
+// data of a TCP connection to the port of the pod. Each side half-closes the
+// connection when it is done sending. Requests for pods which aren't running are
+// answered without upgrading.
+func (s *Server) handlePortForward(w http.ResponseWriter, req *http.Request) {
+	podID := strings.TrimPrefix(path.Clean(req.URL.Path), "/portForward/")
+	if podID == "" || strings.Contains(podID, "/") {
+		http.Error(w, "Expected /portForward/<podID>.", http.StatusBadRequest)
+		return
+	}
+	port, err := strconv.ParseUint(req.URL.Query().Get("port"), 10, 16)
+	if err != nil || port == 0 {
+		http.Error(w, "Missing or invalid 'port=' query entry.", http.StatusBadRequest)
+		return
+	}
+	if !requestsUpgrade(w, req, PortForwardProtocol) {
+		return
+	}
+	// TODO: backwards compatibility with existing API, needs API change
+	podFullName := GetPodFullName(&Pod{Name: podID, Namespace: "etcd"})
+	target, err := s.host.PortForward(podFullName, uint16(port))
+	if err == ErrContainerNotFound {
+		http.Error(w, "Pod not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+	defer target.Close()
+	conn, buf, ok := s.upgrade(w, req, PortForwardProtocol)
+	if !ok {
+		return
+	}
+	defer conn.Close()
+	if err := copyBothWays(&hijackedStream{buf.Reader, conn}, target); err != nil {
+		glog.Errorf("Error forwarding port %d of %s: %v", port, podFullName, err)
+	}
+}
+
+// halfCloser is a stream which can be closed for writing only.
+type halfCloser interface {
+	io.ReadWriter
+	CloseWrite() error
+}
+
+// copyBothWays copies data between a and b until both directions are done,
+// closing each for writing once the other has nothing more to send.
+func copyBothWays(a, b halfCloser) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(b, a)
+		b.CloseWrite()
+		done <- err
+	}()
+	_, err := io.Copy(a, b)
+	a.CloseWrite()
+	if inErr := <-done; err == nil {
+		err = inErr
+	}
+	return err
+}
+
+// requestsUpgrade checks that req asks to upgrade to protocol. If it returns false,
+// it already answered req.
+func requestsUpgrade(w http.ResponseWriter, req *http.Request, protocol string) bool {
+	if !strings.EqualFold(req.Header.Get("Connection"), "Upgrade") || req.Header.Get("Upgrade") != protocol {
+		http.Error(w, fmt.Sprintf("Requests must upgrade to %q.", protocol), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// upgrade checks that req asks to upgrade to protocol, hijacks its connection and
+// replies 101 Switching Protocols. If it returns false, it already answered req.
+func (s *Server) upgrade(w http.ResponseWriter, req *http.Request, protocol string) (net.Conn, *bufio.ReadWriter, bool) {
+	if !requestsUpgrade(w, req, protocol) {
+		return nil, nil, false
+	}
+	hijacker, ok := httplog.Unlogged(w).(http.Hijacker)
+	if !ok {
+		s.error(w, errors.New("connection can not be upgraded"))
+		return nil, nil, false
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		glog.Errorf("Error hijacking connection: %v", err)
+		return nil, nil, false
+	}
+	fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", protocol)
+	if err := buf.Flush(); err != nil {
+		glog.Errorf("Error upgrading connection: %v", err)
+		conn.Close()
+		return nil, nil, false
+	}
+	return conn, buf, true
+}
+
+// hijackedStream reads what the client sent, including what was buffered before the
+// connection was hijacked, and writes to the connection.
+type hijackedStream struct {
+	reader io.Reader
+	conn   net.Conn
+}
+
+func (h *hijackedStream) Read(p []byte) (int, error) {
+	return h.reader.Read(p)
+}
+
+func (h *hijackedStream) Write(p []byte) (int, error) {
+	return h.conn.Write(p)
+}
+
+// CloseWrite half-closes the connection, if it supports it.
+func (h *hijackedStream) CloseWrite() error {
+	if tcpConn, ok := h.conn.(*net.TCPConn); ok {
+		return tcpConn.CloseWrite()
+	}
+	return nil
+}
+
 // handleSpec handles spec requests against the Kubelet
 func (s *Server) handleSpec(w http.ResponseWriter, req *http.Request) {
 	info, err := s.host.GetMachineInfo()
@@ -204,6 +580,30 @@ func (s *Server) handleSpec(w http.ResponseWriter, req *http.Request) {
 
 }
 
+// serveStatsSummary serves the StatsSummary at /stats/summary/<version>.
+func (s *Server) serveStatsSummary(w http.ResponseWriter, version []string) {
+	if len(version) != 1 {
+		http.Error(w, fmt.Sprintf("Stats summary version required, e.g. /stats/summary/%s", StatsSummaryVersion), http.StatusNotFound)
+		return
+	}
+	if version[0] != StatsSummaryVersion {
+		http.Error(w, fmt.Sprintf("Unsupported stats summary version %q, only %s is served", version[0], StatsSummaryVersion), http.StatusNotFound)
+		return
+	}
+	summary, err := s.host.GetStatsSummary()
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		s.error(w, err)
+		return
+	}
+	w.Header().Add("Content-type", "application/json")
+	w.Write(data)
+}
+
 // ServeHTTP responds to HTTP requests on the Kubelet
 func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	defer httplog.NewLogged(req, &w).StacktraceWhen(
@@ -212,6 +612,10 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			http.StatusNotFound,
 		),
 	).Log()
+	if s.readOnly && req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(w, fmt.Sprintf("%s is not allowed on the read-only port", req.Method), http.StatusMethodNotAllowed)
+		return
+	}
 	s.mux.ServeHTTP(w, req)
 }
 
@@ -219,6 +623,11 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 func (s *Server) serveStats(w http.ResponseWriter, req *http.Request) {
 	// /stats/<podfullname>/<containerName>
 	components := strings.Split(strings.TrimPrefix(path.Clean(req.URL.Path), "/"), "/")
+	// Full pod names always have a dot, so can't be mistaken for "summary".
+	if len(components) > 1 && components[1] == "summary" {
+		s.serveStatsSummary(w, components[2:])
+		return
+	}
 	var stats *info.ContainerInfo
 	var err error
 	var query info.ContainerInfoRequest
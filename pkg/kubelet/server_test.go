@@ -17,10 +17,15 @@ limitations under the License.
 package kubelet
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
@@ -39,13 +44,22 @@ type fakeKubelet struct {
 	containerInfoFunc func(podFullName, containerName string, req *info.ContainerInfoRequest) (*info.ContainerInfo, error)
 	rootInfoFunc      func(query *info.ContainerInfoRequest) (*info.ContainerInfo, error)
 	machineInfoFunc   func() (*info.MachineInfo, error)
+	statsSummaryFunc  func() (*StatsSummary, error)
 	logFunc           func(w http.ResponseWriter, req *http.Request)
+	containerLogsFunc func(podFullName, containerName, tail string, follow bool, stdout, stderr io.Writer) error
+	execFunc          func(podFullName, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error
+	portForwardFunc   func(podFullName string, port uint16) (PortForwardConn, error)
+	podsFunc          func() []Pod
 }
 
 func (fk *fakeKubelet) GetPodInfo(name string) (api.PodInfo, error) {
 	return fk.infoFunc(name)
 }
 
+func (fk *fakeKubelet) GetPods() []Pod {
+	return fk.podsFunc()
+}
+
 func (fk *fakeKubelet) GetContainerInfo(podFullName, containerName string, req *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
 	return fk.containerInfoFunc(podFullName, containerName, req)
 }
@@ -58,10 +72,26 @@ func (fk *fakeKubelet) GetMachineInfo() (*info.MachineInfo, error) {
 	return fk.machineInfoFunc()
 }
 
+func (fk *fakeKubelet) GetStatsSummary() (*StatsSummary, error) {
+	return fk.statsSummaryFunc()
+}
+
 func (fk *fakeKubelet) ServeLogs(w http.ResponseWriter, req *http.Request) {
 	fk.logFunc(w, req)
 }
 
+func (fk *fakeKubelet) GetKubeletContainerLogs(podFullName, containerName, tail string, follow bool, stdout, stderr io.Writer) error {
+	return fk.containerLogsFunc(podFullName, containerName, tail, follow, stdout, stderr)
+}
+
+func (fk *fakeKubelet) ExecInContainer(podFullName, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	return fk.execFunc(podFullName, container, cmd, stdin, stdout, stderr)
+}
+
+func (fk *fakeKubelet) PortForward(podFullName string, port uint16) (PortForwardConn, error) {
+	return fk.portForwardFunc(podFullName, port)
+}
+
 type serverTestFramework struct {
 	updateChan      chan interface{}
 	updateReader    *channelReader
@@ -236,6 +266,45 @@ func TestRootInfo(t *testing.T) {
 	}
 }
 
+func TestStatsSummary(t *testing.T) {
+	fw := newServerTest()
+	milliCores := uint64(250)
+	expected := &StatsSummary{
+		Version: StatsSummaryVersion,
+		Node:    NodeStats{CPU: &CPUStats{UsageCoreNanoSeconds: 100, UsageMilliCores: &milliCores}},
+		Pods: []PodStats{
+			{Name: "foo.etcd", Containers: []ContainerStats{{Name: "bar", Memory: &MemoryStats{UsageBytes: 1024}}}},
+		},
+	}
+	fw.fakeKubelet.statsSummaryFunc = func() (*StatsSummary, error) {
+		return expected, nil
+	}
+
+	resp, err := http.Get(fw.testHTTPServer.URL + "/stats/summary/" + StatsSummaryVersion)
+	if err != nil {
+		t.Fatalf("Got error GETing: %v", err)
+	}
+	defer resp.Body.Close()
+	var received StatsSummary
+	if err := json.NewDecoder(resp.Body).Decode(&received); err != nil {
+		t.Fatalf("received invalid json data: %v", err)
+	}
+	if !reflect.DeepEqual(&received, expected) {
+		t.Errorf("expected %#v, got %#v", expected, received)
+	}
+
+	for _, path := range []string{"/stats/summary", "/stats/summary/v0"} {
+		resp, err := http.Get(fw.testHTTPServer.URL + path)
+		if err != nil {
+			t.Fatalf("Got error GETing: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected not found, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
 func TestMachineInfo(t *testing.T) {
 	fw := newServerTest()
 	expectedInfo := &info.MachineInfo{
@@ -288,3 +357,380 @@ func TestServeLogs(t *testing.T) {
 		t.Errorf("Received wrong data: %s", result)
 	}
 }
+
+func TestContainerLogs(t *testing.T) {
+	fw := newServerTest()
+	expectedPodName := GetPodFullName(&Pod{Name: "my-pod", Namespace: "etcd"})
+	tests := []struct {
+		query          string
+		expectedTail   string
+		expectedFollow bool
+	}{
+		{"", "all", false},
+		{"?tail=5", "5", false},
+		{"?follow=true&tail=all", "all", true},
+	}
+	for _, test := range tests {
+		var podName, containerName, tail string
+		var follow bool
+		fw.fakeKubelet.containerLogsFunc = func(podFullName, name, t string, f bool, stdout, stderr io.Writer) error {
+			podName, containerName, tail, follow = podFullName, name, t, f
+			io.WriteString(stdout, "line one\n")
+			io.WriteString(stderr, "line two\n")
+			return nil
+		}
+		resp, err := http.Get(fw.testHTTPServer.URL + "/containerLogs/my-pod/web" + test.query)
+		if err != nil {
+			t.Fatalf("Got error GETing: %v", err)
+		}
+		body, err := readResp(resp)
+		if err != nil {
+			t.Errorf("Error reading body: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%q: unexpected status %d", test.query, resp.StatusCode)
+		}
+		if body != "line one\nline two\n" {
+			t.Errorf("%q: unexpected body %q", test.query, body)
+		}
+		if podName != expectedPodName || containerName != "web" {
+			t.Errorf("%q: unexpected container %s/%s", test.query, podName, containerName)
+		}
+		if tail != test.expectedTail || follow != test.expectedFollow {
+			t.Errorf("%q: expected tail %q and follow %v, got %q and %v", test.query, test.expectedTail, test.expectedFollow, tail, follow)
+		}
+	}
+}
+
+func TestContainerLogsErrors(t *testing.T) {
+	fw := newServerTest()
+	fw.fakeKubelet.containerLogsFunc = func(podFullName, name, tail string, follow bool, stdout, stderr io.Writer) error {
+		return ErrContainerNotFound
+	}
+	tests := map[string]int{
+		"/containerLogs/my-pod":                 http.StatusBadRequest,
+		"/containerLogs/my-pod/web/extra":       http.StatusBadRequest,
+		"/containerLogs/my-pod/web?tail=lots":   http.StatusBadRequest,
+		"/containerLogs/my-pod/web?tail=-1":     http.StatusBadRequest,
+		"/containerLogs/my-pod/missing?tail=10": http.StatusNotFound,
+	}
+	for path, expected := range tests {
+		resp, err := http.Get(fw.testHTTPServer.URL + path)
+		if err != nil {
+			t.Fatalf("Got error GETing: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Errorf("%s: expected status %d, got %d", path, expected, resp.StatusCode)
+		}
+	}
+}
+
+// readExecFrame reads one frame of the exec protocol.
+func readExecFrame(r io.Reader) (byte, string, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, "", err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[4:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, "", err
+	}
+	return header[0], string(payload), nil
+}
+
+func TestExec(t *testing.T) {
+	fw := newServerTest()
+	expectedPodName := GetPodFullName(&Pod{Name: "my-pod", Namespace: "etcd"})
+	fw.fakeKubelet.execFunc = func(podFullName, container string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+		if podFullName != expectedPodName || container != "web" {
+			t.Errorf("unexpected container %s/%s", podFullName, container)
+		}
+		if !reflect.DeepEqual(cmd, []string{"cat", "-n"}) {
+			t.Errorf("unexpected command %v", cmd)
+		}
+		input, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			t.Errorf("unexpected error reading stdin: %v", err)
+		}
+		fmt.Fprintf(stdout, "1 %s", input)
+		fmt.Fprint(stderr, "warning")
+		return errors.New("exit status 1")
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(fw.testHTTPServer.URL, "http://"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /exec/my-pod/web?command=cat&command=-n&stdin=true HTTP/1.1\r\nHost: kubelet\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", ExecProtocol)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != ExecProtocol {
+		t.Fatalf("unexpected response: %#v", resp)
+	}
+	io.WriteString(conn, "hello")
+	conn.(*net.TCPConn).CloseWrite()
+
+	expected := []struct {
+		stream  byte
+		payload string
+	}{
+		{ExecStreamStdout, "1 hello"},
+		{ExecStreamStderr, "warning"},
+		{ExecStreamResult, "exit status 1"},
+	}
+	for _, frame := range expected {
+		stream, payload, err := readExecFrame(reader)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if stream != frame.stream || payload != frame.payload {
+			t.Errorf("expected frame %d %q, got %d %q", frame.stream, frame.payload, stream, payload)
+		}
+	}
+	if _, _, err := readExecFrame(reader); err != io.EOF {
+		t.Errorf("expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestExecWithoutUpgrade(t *testing.T) {
+	fw := newServerTest()
+	tests := map[string]int{
+		"/exec/my-pod/web?command=ls": http.StatusBadRequest,
+		"/exec/my-pod/web":            http.StatusBadRequest,
+		"/exec/my-pod?command=ls":     http.StatusBadRequest,
+	}
+	for path, expected := range tests {
+		resp, err := http.Get(fw.testHTTPServer.URL + path)
+		if err != nil {
+			t.Fatalf("Got error GETing: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != expected {
+			t.Errorf("%s: expected status %d, got %d", path, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestPortForward(t *testing.T) {
+	fw := newServerTest()
+	expectedPodName := GetPodFullName(&Pod{Name: "my-pod", Namespace: "etcd"})
+	fw.fakeKubelet.portForwardFunc = func(podFullName string, port uint16) (PortForwardConn, error) {
+		if podFullName != expectedPodName || port != 8080 {
+			t.Errorf("unexpected port %s:%d", podFullName, port)
+		}
+		// The port answers with what it was sent, in upper case.
+		requestReader, requestWriter := io.Pipe()
+		replyReader, replyWriter := io.Pipe()
+		go func() {
+			data, err := ioutil.ReadAll(requestReader)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			replyWriter.Write(bytes.ToUpper(data))
+			replyWriter.Close()
+		}()
+		return &pipeConn{replyReader, requestWriter}, nil
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(fw.testHTTPServer.URL, "http://"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "POST /portForward/my-pod?port=8080 HTTP/1.1\r\nHost: kubelet\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", PortForwardProtocol)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != PortForwardProtocol {
+		t.Fatalf("unexpected response: %#v", resp)
+	}
+	io.WriteString(conn, "ping")
+	conn.(*net.TCPConn).CloseWrite()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if string(data) != "PING" {
+		t.Errorf("expected %q, got %q", "PING", data)
+	}
+}
+
+func TestPortForwardPodNotFound(t *testing.T) {
+	fw := newServerTest()
+	fw.fakeKubelet.portForwardFunc = func(podFullName string, port uint16) (PortForwardConn, error) {
+		return nil, ErrContainerNotFound
+	}
+	req, err := http.NewRequest("POST", fw.testHTTPServer.URL+"/portForward/my-pod?port=8080", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", PortForwardProtocol)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+// pipeConn is a PortForwardConn which reads from one pipe and writes to another.
+type pipeConn struct {
+	*io.PipeReader
+	writer *io.PipeWriter
+}
+
+func (p *pipeConn) Write(data []byte) (int, error) {
+	return p.writer.Write(data)
+}
+
+func (p *pipeConn) CloseWrite() error {
+	return p.writer.Close()
+}
+
+func (p *pipeConn) Close() error {
+	p.writer.Close()
+	return p.PipeReader.Close()
+}
+
+func TestPortForwardBadRequests(t *testing.T) {
+	fw := newServerTest()
+	tests := []string{
+		"/portForward/my-pod?port=8080",
+		"/portForward/my-pod",
+		"/portForward/my-pod?port=65536",
+		"/portForward/my-pod/web?port=80",
+	}
+	for _, path := range tests {
+		resp, err := http.Get(fw.testHTTPServer.URL + path)
+		if err != nil {
+			t.Fatalf("Got error GETing: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusBadRequest, resp.StatusCode)
+		}
+	}
+}
+
+func TestPods(t *testing.T) {
+	fw := newServerTest()
+	expected := []Pod{
+		{Namespace: "test", Name: "foo", Manifest: api.ContainerManifest{ID: "foo"}},
+	}
+	fw.fakeKubelet.podsFunc = func() []Pod {
+		return expected
+	}
+
+	resp, err := http.Get(fw.testHTTPServer.URL + "/pods")
+	if err != nil {
+		t.Fatalf("Got error GETing: %v", err)
+	}
+	defer resp.Body.Close()
+	var received []Pod
+	err = json.NewDecoder(resp.Body).Decode(&received)
+	if err != nil {
+		t.Fatalf("received invalid json data: %v", err)
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected %#v, got %#v", expected, received)
+	}
+}
+
+func TestReadOnlyServer(t *testing.T) {
+	fk := &fakeKubelet{
+		podsFunc: func() []Pod { return []Pod{} },
+		machineInfoFunc: func() (*info.MachineInfo, error) {
+			return &info.MachineInfo{NumCores: 4}, nil
+		},
+	}
+	server := NewReadOnlyServer(fk)
+	testServer := httptest.NewServer(&server)
+	defer testServer.Close()
+
+	for _, path := range []string{"/healthz", "/pods", "/spec"} {
+		resp, err := http.Get(testServer.URL + path)
+		if err != nil {
+			t.Fatalf("Got error GETing: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusOK, resp.StatusCode)
+		}
+	}
+	for _, path := range []string{"/containers", "/logs/", "/containerLogs/pod/container", "/exec/pod/container", "/portForward/pod"} {
+		resp, err := http.Get(testServer.URL + path)
+		if err != nil {
+			t.Fatalf("Got error GETing: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusNotFound, resp.StatusCode)
+		}
+	}
+	resp, err := http.Post(testServer.URL+"/pods", "application/json", bytes.NewBufferString("[]"))
+	if err != nil {
+		t.Fatalf("Got error POSTing: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
+
+func TestReadOnlyServerHidesSecrets(t *testing.T) {
+	pods := []Pod{{
+		Name:      "foo",
+		Namespace: "etcd",
+		Manifest: api.ContainerManifest{
+			Containers: []api.Container{{
+				Name:    "bar",
+				Image:   "image",
+				Command: []string{"run", "--password=secret"},
+				Env:     []api.EnvVar{{Name: "PASSWORD", Value: "secret"}},
+			}},
+		},
+	}}
+	info := api.PodInfo{"bar": docker.Container{
+		ID:     "id",
+		Path:   "run",
+		Args:   []string{"--password=secret"},
+		Config: &docker.Config{Image: "image", Env: []string{"PASSWORD=secret"}, Cmd: []string{"run", "--password=secret"}},
+	}}
+	fk := &fakeKubelet{
+		podsFunc: func() []Pod { return pods },
+		infoFunc: func(name string) (api.PodInfo, error) { return info, nil },
+	}
+	server := NewReadOnlyServer(fk)
+	testServer := httptest.NewServer(&server)
+	defer testServer.Close()
+
+	for _, path := range []string{"/pods", "/podInfo?podID=foo"} {
+		resp, err := http.Get(testServer.URL + path)
+		if err != nil {
+			t.Fatalf("Got error GETing: %v", err)
+		}
+		body, err := readResp(resp)
+		if err != nil {
+			t.Fatalf("Error reading body: %v", err)
+		}
+		if strings.Contains(body, "secret") {
+			t.Errorf("%s: unexpected secret in %s", path, body)
+		}
+		if !strings.Contains(body, "image") {
+			t.Errorf("%s: expected the image in %s", path, body)
+		}
+	}
+	if len(pods[0].Manifest.Containers[0].Env) != 1 || len(info["bar"].Config.Env) != 1 {
+		t.Errorf("the kubelet's pods were changed: %#v %#v", pods, info)
+	}
+}
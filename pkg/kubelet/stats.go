@@ -0,0 +1,188 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+)
+
+// StatsSummaryVersion is the version of StatsSummary this kubelet serves, at
+// /stats/summary/<version>. Fields are only ever added within a version.
+const StatsSummaryVersion = "v1"
+
+// StatsSummary is a snapshot of the resources used on the minion and by each
+// of its pods' containers, for monitoring and autoscaling.
+type StatsSummary struct {
+	Version   string     `json:"version"`
+	Timestamp time.Time  `json:"timestamp"`
+	Node      NodeStats  `json:"node"`
+	Pods      []PodStats `json:"pods"`
+}
+
+// NodeStats are the resources used on the whole minion.
+type NodeStats struct {
+	CPU    *CPUStats    `json:"cpu,omitempty"`
+	Memory *MemoryStats `json:"memory,omitempty"`
+	// Filesystem is the filesystem holding the kubelet's root directory, which
+	// pod volumes are created in.
+	Filesystem *FilesystemStats `json:"filesystem,omitempty"`
+}
+
+// PodStats are the resources used by the containers of a pod.
+type PodStats struct {
+	// Name is the full name of the pod, e.g. "foo.etcd".
+	Name       string           `json:"name"`
+	Containers []ContainerStats `json:"containers"`
+}
+
+// ContainerStats are the resources used by one container.
+type ContainerStats struct {
+	Name   string       `json:"name"`
+	CPU    *CPUStats    `json:"cpu,omitempty"`
+	Memory *MemoryStats `json:"memory,omitempty"`
+}
+
+// CPUStats describes CPU usage.
+type CPUStats struct {
+	// UsageCoreNanoSeconds is the CPU time used so far.
+	UsageCoreNanoSeconds uint64 `json:"usageCoreNanoSeconds"`
+	// UsageMilliCores is the CPU used per second between the last two samples,
+	// in thousandths of a core. Missing until there are two samples.
+	UsageMilliCores *uint64 `json:"usageMilliCores,omitempty"`
+}
+
+// MemoryStats describes memory usage.
+type MemoryStats struct {
+	UsageBytes uint64 `json:"usageBytes"`
+	// WorkingSetBytes is the memory recently used, which can't be reclaimed.
+	WorkingSetBytes uint64 `json:"workingSetBytes"`
+}
+
+// FilesystemStats describes the space on a filesystem.
+type FilesystemStats struct {
+	Path           string `json:"path"`
+	CapacityBytes  uint64 `json:"capacityBytes"`
+	AvailableBytes uint64 `json:"availableBytes"`
+	UsedBytes      uint64 `json:"usedBytes"`
+}
+
+// statsRequest asks cadvisor for the two latest samples, to compute rates from.
+var statsRequest = info.ContainerInfoRequest{NumStats: 2}
+
+// GetStatsSummary returns the resources used on the minion and by the
+// containers of every pod, other than their network containers.
+func (kl *Kubelet) GetStatsSummary() (*StatsSummary, error) {
+	if kl.cadvisorClient == nil {
+		return nil, errors.New("no cadvisor client, stats are unavailable")
+	}
+	summary := &StatsSummary{
+		Version:   StatsSummaryVersion,
+		Timestamp: time.Now(),
+		Pods:      []PodStats{},
+	}
+	root, err := kl.cadvisorClient.ContainerInfo("/", &statsRequest)
+	if err != nil {
+		return nil, err
+	}
+	summary.Node.CPU, summary.Node.Memory = convertStats(root)
+	if fs, err := getFilesystemStats(kl.rootDirectory); err != nil {
+		glog.Errorf("Unable to get the stats of the filesystem of %s: %v", kl.rootDirectory, err)
+	} else {
+		summary.Node.Filesystem = fs
+	}
+
+	dockerContainers, err := getKubeletDockerContainers(kl.dockerClient)
+	if err != nil {
+		return nil, err
+	}
+	pods := map[string][]ContainerStats{}
+	for _, dockerContainer := range dockerContainers {
+		podFullName, containerName, _ := parseDockerName(dockerContainer.Names[0])
+		if containerName == networkContainerName {
+			continue
+		}
+		cinfo, err := kl.cadvisorClient.ContainerInfo(fmt.Sprintf("/docker/%s", dockerContainer.ID), &statsRequest)
+		if err != nil {
+			glog.Errorf("Unable to get the stats of pod %s container %s: %v", podFullName, containerName, err)
+			continue
+		}
+		stats := ContainerStats{Name: containerName}
+		stats.CPU, stats.Memory = convertStats(cinfo)
+		pods[podFullName] = append(pods[podFullName], stats)
+	}
+	for name, containers := range pods {
+		sort.Sort(containerStatsByName(containers))
+		summary.Pods = append(summary.Pods, PodStats{Name: name, Containers: containers})
+	}
+	sort.Sort(podStatsByName(summary.Pods))
+	return summary, nil
+}
+
+// convertStats returns the CPU and memory usage of the latest sample in cinfo,
+// if there is one. The CPU rate is computed from the two latest samples.
+func convertStats(cinfo *info.ContainerInfo) (*CPUStats, *MemoryStats) {
+	var latest, previous *info.ContainerStats
+	for _, stats := range cinfo.Stats {
+		if stats == nil {
+			continue
+		}
+		if latest == nil || stats.Timestamp.After(latest.Timestamp) {
+			latest, previous = stats, latest
+		} else if previous == nil || stats.Timestamp.After(previous.Timestamp) {
+			previous = stats
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	var cpu *CPUStats
+	if latest.Cpu != nil {
+		cpu = &CPUStats{UsageCoreNanoSeconds: latest.Cpu.Usage.Total}
+		if previous != nil && previous.Cpu != nil && latest.Cpu.Usage.Total >= previous.Cpu.Usage.Total {
+			if elapsed := latest.Timestamp.Sub(previous.Timestamp); elapsed > 0 {
+				milliCores := (latest.Cpu.Usage.Total - previous.Cpu.Usage.Total) * 1000 / uint64(elapsed)
+				cpu.UsageMilliCores = &milliCores
+			}
+		}
+	}
+	var memory *MemoryStats
+	if latest.Memory != nil {
+		memory = &MemoryStats{
+			UsageBytes:      latest.Memory.Usage,
+			WorkingSetBytes: latest.Memory.WorkingSet,
+		}
+	}
+	return cpu, memory
+}
+
+type podStatsByName []PodStats
+
+func (s podStatsByName) Len() int           { return len(s) }
+func (s podStatsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s podStatsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+type containerStatsByName []ContainerStats
+
+func (s containerStatsByName) Len() int           { return len(s) }
+func (s containerStatsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s containerStatsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
@@ -0,0 +1,36 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"syscall"
+)
+
+// getFilesystemStats returns the space on the filesystem holding path.
+func getFilesystemStats(path string) (*FilesystemStats, error) {
+	var statfs syscall.Statfs_t
+	if err := syscall.Statfs(path, &statfs); err != nil {
+		return nil, err
+	}
+	blockSize := uint64(statfs.Bsize)
+	return &FilesystemStats{
+		Path:           path,
+		CapacityBytes:  statfs.Blocks * blockSize,
+		AvailableBytes: statfs.Bavail * blockSize,
+		UsedBytes:      (statfs.Blocks - statfs.Bfree) * blockSize,
+	}, nil
+}
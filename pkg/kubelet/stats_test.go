@@ -0,0 +1,114 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubelet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/google/cadvisor/info"
+)
+
+func makeStats(when time.Time, cpuTotal, memory uint64) *info.ContainerStats {
+	stats := &info.ContainerStats{
+		Timestamp: when,
+		Cpu:       &info.CpuStats{},
+		Memory:    &info.MemoryStats{Usage: memory, WorkingSet: memory / 2},
+	}
+	stats.Cpu.Usage.Total = cpuTotal
+	return stats
+}
+
+func TestConvertStats(t *testing.T) {
+	now := time.Unix(100, 0)
+	// Samples aren't necessarily in order; half a core over two seconds.
+	cinfo := &info.ContainerInfo{
+		Stats: []*info.ContainerStats{
+			makeStats(now, 3000000000, 2048),
+			makeStats(now.Add(-2*time.Second), 2000000000, 1024),
+		},
+	}
+	cpu, memory := convertStats(cinfo)
+	if cpu == nil || cpu.UsageCoreNanoSeconds != 3000000000 || cpu.UsageMilliCores == nil || *cpu.UsageMilliCores != 500 {
+		t.Errorf("unexpected cpu stats: %#v", cpu)
+	}
+	if e, a := (&MemoryStats{UsageBytes: 2048, WorkingSetBytes: 1024}), memory; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %#v, got %#v", e, a)
+	}
+
+	// A single sample has no rate.
+	cpu, _ = convertStats(&info.ContainerInfo{Stats: []*info.ContainerStats{makeStats(now, 1, 1)}})
+	if cpu == nil || cpu.UsageMilliCores != nil {
+		t.Errorf("unexpected cpu stats: %#v", cpu)
+	}
+	if cpu, memory := convertStats(&info.ContainerInfo{}); cpu != nil || memory != nil {
+		t.Errorf("expected no stats, got %#v, %#v", cpu, memory)
+	}
+}
+
+func TestGetStatsSummary(t *testing.T) {
+	now := time.Unix(100, 0)
+	containerInfo := func(memory uint64) *info.ContainerInfo {
+		return &info.ContainerInfo{Stats: []*info.ContainerStats{makeStats(now, 1000, memory)}}
+	}
+	mockCadvisor := &mockCadvisorClient{}
+	mockCadvisor.On("ContainerInfo", "/", &statsRequest).Return(containerInfo(4096), nil)
+	mockCadvisor.On("ContainerInfo", "/docker/1", &statsRequest).Return(containerInfo(1024), nil)
+	mockCadvisor.On("ContainerInfo", "/docker/2", &statsRequest).Return(containerInfo(2048), nil)
+
+	kubelet, fakeDocker := newTestKubelet(t)
+	kubelet.cadvisorClient = mockCadvisor
+	kubelet.rootDirectory = "/"
+	fakeDocker.containerList = []docker.APIContainers{
+		{ID: "2", Names: []string{"/k8s--qux--foo.etcd--2"}},
+		{ID: "1", Names: []string{"/k8s--bar--foo.etcd--1"}},
+		{ID: "9", Names: []string{"/k8s--net--foo.etcd--9"}},
+	}
+
+	summary, err := kubelet.GetStatsSummary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if summary.Version != StatsSummaryVersion || summary.Node.Memory.UsageBytes != 4096 || summary.Node.CPU.UsageCoreNanoSeconds != 1000 {
+		t.Errorf("unexpected node stats: %#v", summary)
+	}
+	if fs := summary.Node.Filesystem; fs == nil || fs.Path != "/" || fs.CapacityBytes == 0 {
+		t.Errorf("unexpected filesystem stats: %#v", fs)
+	}
+	expected := []PodStats{
+		{
+			Name: "foo.etcd",
+			Containers: []ContainerStats{
+				{Name: "bar", CPU: &CPUStats{UsageCoreNanoSeconds: 1000}, Memory: &MemoryStats{UsageBytes: 1024, WorkingSetBytes: 512}},
+				{Name: "qux", CPU: &CPUStats{UsageCoreNanoSeconds: 1000}, Memory: &MemoryStats{UsageBytes: 2048, WorkingSetBytes: 1024}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(summary.Pods, expected) {
+		t.Errorf("expected %#v, got %#v", expected, summary.Pods)
+	}
+	mockCadvisor.AssertExpectations(t)
+}
+
+func TestGetStatsSummaryWithoutCadvisor(t *testing.T) {
+	kubelet, _ := newTestKubelet(t)
+	if _, err := kubelet.GetStatsSummary(); err == nil {
+		t.Errorf("expected an error without cadvisor")
+	}
+}
@@ -25,9 +25,9 @@ import (
 // Pod represents the structure of a pod on the Kubelet, distinct from the apiserver
 // representation of a Pod.
 type Pod struct {
-	Namespace string
-	Name      string
-	Manifest  api.ContainerManifest
+	Namespace string                `json:"namespace" yaml:"namespace"`
+	Name      string                `json:"name" yaml:"name"`
+	Manifest  api.ContainerManifest `json:"manifest" yaml:"manifest"`
 }
 
 // PodOperation defines what changes will be made on a pod configuration.
@@ -31,3 +31,16 @@ func ValidatePod(pod *Pod) (errors []error) {
 	}
 	return errors
 }
+
+// ValidateUnreportedPod validates a pod the master scheduled to a kubelet which
+// doesn't report the status of pods to the apiserver. The master would never
+// learn that such a pod passed its readiness probes, so it would never send it
+// traffic; pods with readiness probes are refused instead.
+func ValidateUnreportedPod(pod *Pod) (errors []error) {
+	for _, container := range pod.Manifest.Containers {
+		if container.ReadinessProbe != nil {
+			errors = append(errors, apierrs.NewNotSupported("Container.ReadinessProbe", container.Name))
+		}
+	}
+	return errors
+}
@@ -40,3 +40,17 @@ func TestValidatePodNoName(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateUnreportedPod(t *testing.T) {
+	pod := Pod{Name: "test", Manifest: api.ContainerManifest{
+		Version:    "v1beta1",
+		Containers: []api.Container{{Name: "web", Image: "image"}},
+	}}
+	if errs := ValidateUnreportedPod(&pod); len(errs) != 0 {
+		t.Errorf("expected success: %v", errs)
+	}
+	pod.Manifest.Containers[0].ReadinessProbe = &api.LivenessProbe{Type: "tcp"}
+	if errs := ValidateUnreportedPod(&pod); len(errs) != 1 {
+		t.Errorf("expected a failure for the readiness probe, got %v", errs)
+	}
+}
@@ -198,3 +198,24 @@ func ParseSelector(selector string) (Selector, error) {
 	}
 	return andTerm(items), nil
 }
+
+// RequiredLabels returns the labels which selector requires to have exactly one
+// value, e.g. {"a": "b"} for "a=b,c!=d". Any matching Labels contain all of
+// them, though that alone doesn't make them match. Useful for looking up
+// candidates in an index before calling Matches.
+func RequiredLabels(selector Selector) Set {
+	required := Set{}
+	addRequiredLabels(selector, required)
+	return required
+}
+
+func addRequiredLabels(selector Selector, required Set) {
+	switch t := selector.(type) {
+	case *hasTerm:
+		required[t.label] = t.value
+	case andTerm:
+		for _, q := range t {
+			addRequiredLabels(q, required)
+		}
+	}
+}
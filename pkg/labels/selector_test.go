@@ -219,3 +219,35 @@ func TestLabelSelectorMatches(t *testing.T) {
 	expectMatchLabSelector(t, allMatch, s)
 	expectNoMatchLabSelector(t, singleNonMatch, s)
 }
+
+func TestRequiredLabels(t *testing.T) {
+	parse := func(s string) Selector {
+		sel, err := ParseSelector(s)
+		if err != nil {
+			t.Fatalf("Unable to parse %v as a selector: %v", s, err)
+		}
+		return sel
+	}
+	table := []struct {
+		selector Selector
+		expected Set
+	}{
+		{Everything(), Set{}},
+		{parse("x=a"), Set{"x": "a"}},
+		{parse("x=a,y!=b,z==c"), Set{"x": "a", "z": "c"}},
+		{parse("y!=b"), Set{}},
+		{Set{"x": "a", "y": "b"}.AsSelector(), Set{"x": "a", "y": "b"}},
+	}
+	for _, item := range table {
+		got := RequiredLabels(item.selector)
+		if len(got) != len(item.expected) {
+			t.Errorf("%v: expected %v, got %v", item.selector, item.expected, got)
+			continue
+		}
+		for k, v := range item.expected {
+			if got[k] != v {
+				t.Errorf("%v: expected %v, got %v", item.selector, item.expected, got)
+			}
+		}
+	}
+}
@@ -17,25 +17,32 @@ limitations under the License.
 package master
 
 import (
-	"math/rand"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"regexp"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/cloudprovider"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/binding"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/controller"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/daemon"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/endpoint"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/etcd"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/event"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/minion"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/pod"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/service"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 
-	goetcd "github.com/coreos/go-etcd/etcd"
 	"github.com/golang/glog"
 )
 
@@ -44,42 +51,148 @@ type Config struct {
 	Client             *client.Client
 	Cloud              cloudprovider.Interface
 	EtcdServers        []string
+	EtcdClientFactory  tools.EtcdClientFactory
 	HealthCheckMinions bool
 	Minions            []string
 	MinionCacheTTL     time.Duration
 	MinionRegexp       string
-	PodInfoGetter      client.PodInfoGetter
+	// MinionHeartbeatTimeout, if non-zero, is how long a minion may go without
+	// registering itself before it is marked NotReady.
+	MinionHeartbeatTimeout time.Duration
+	// MinionAdmissionRegexp, if non-empty, restricts which minions may register.
+	MinionAdmissionRegexp string
+	OperationTTL          time.Duration
+	// OperationOwner names this apiserver in the records of its operations, so
+	// that when it restarts it only fails its own unfinished operations, not
+	// those of other apiservers sharing etcd. Defaults to the hostname.
+	OperationOwner string
+	// EventTTL is how long events are kept. Defaults to event.DefaultTTL.
+	EventTTL      time.Duration
+	PodInfoGetter client.PodInfoGetter
+	// PortalNet, if set, is the network services are given portal IPs from.
+	PortalNet *net.IPNet
+	// ServicePortRange, if not empty, is the range the ports of services
+	// without a portal IP have to be in.
+	ServicePortRange util.PortRange
+	// EtcdCodec, if set, is the codec objects are stored in etcd with, e.g. a
+	// tools.EncryptingCodec. Defaults to api.Codec.
+	EtcdCodec tools.Codec
+	// RegistryStorage, if set, holds the backends objects are kept in instead
+	// of etcd, e.g. etcd.NewMemoryRegistryStorage() in tests. Operation records
+	// and events are still kept in etcd.
+	RegistryStorage *etcd.RegistryStorage
+	// Janitor says which orphaned registry entries to remove; by default none are.
+	Janitor etcd.JanitorConfig
+	// Storage holds additional resources to serve next to the built-in ones,
+	// keyed by the path they are served at. They can't replace a built-in resource.
+	Storage map[string]apiserver.RESTStorage
 }
 
 // Master contains state for a Kubernetes cluster master/api server.
 type Master struct {
 	podRegistry        pod.Registry
 	controllerRegistry controller.Registry
+	daemonRegistry     daemon.Registry
 	serviceRegistry    service.Registry
 	minionRegistry     minion.Registry
+	minionAdmission    minion.AdmissionFunc
+	portals            *service.IPAllocator
+	servicePorts       *util.PortRange
 	bindingRegistry    binding.Registry
+	eventRegistry      event.Registry
+	eventTTL           time.Duration
 	storage            map[string]apiserver.RESTStorage
+	operations         *apiserver.Operations
 	client             *client.Client
 }
 
-// New returns a new instance of Master connected to the given etcdServer.
-func New(c *Config) *Master {
-	etcdClient := goetcd.NewClient(c.EtcdServers)
-	minionRegistry := makeMinionRegistry(c)
+// New returns a new instance of Master connected to the given etcd servers. Requests
+// fail over between the servers when one of them can't be reached. It fails if c is
+// invalid.
+func New(c *Config) (*Master, error) {
+	minionAdmission, err := makeMinionAdmission(c)
+	if err != nil {
+		return nil, err
+	}
+	newEtcdClient := c.EtcdClientFactory
+	if newEtcdClient == nil {
+		// Plain HTTP; the zero config can't fail.
+		newEtcdClient, _ = tools.NewEtcdClientFactory(tools.EtcdClientConfig{})
+	}
+	etcdClient := tools.NewFailoverEtcdClient(c.EtcdServers, newEtcdClient)
+	go util.Forever(etcdClient.CheckHealth, time.Second*10)
+	minionRegistry, knownMinions := makeMinionRegistry(c)
+	etcdCodec := c.EtcdCodec
+	if etcdCodec == nil {
+		etcdCodec = api.Codec
+	}
+	newRegistry := func() *etcd.Registry {
+		return etcd.NewRegistryWithCodec(etcdClient, etcdCodec, minionRegistry)
+	}
+	if c.RegistryStorage != nil {
+		// Every registry has to share the one set of backends.
+		s := *c.RegistryStorage
+		newRegistry = func() *etcd.Registry {
+			return etcd.NewRegistryWithStorage(etcdClient, s, minionRegistry)
+		}
+	}
+	if err := newRegistry().MigrateKeys(); err != nil {
+		return nil, fmt.Errorf("unable to migrate etcd keys to the namespaced layout: %v", err)
+	}
 	m := &Master{
-		podRegistry:        etcd.NewRegistry(etcdClient, minionRegistry),
-		controllerRegistry: etcd.NewRegistry(etcdClient, minionRegistry),
-		serviceRegistry:    etcd.NewRegistry(etcdClient, minionRegistry),
-		bindingRegistry:    etcd.NewRegistry(etcdClient, minionRegistry),
+		podRegistry:        newRegistry(),
+		controllerRegistry: newRegistry(),
+		daemonRegistry:     newRegistry(),
+		serviceRegistry:    newRegistry(),
+		bindingRegistry:    newRegistry(),
+		eventRegistry:      newRegistry(),
+		eventTTL:           c.EventTTL,
 		minionRegistry:     minionRegistry,
+		minionAdmission:    minionAdmission,
 		client:             c.Client,
 	}
-	m.init(c.Cloud, c.PodInfoGetter)
-	return m
+	owner := c.OperationOwner
+	if owner == "" {
+		if owner, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("unable to name the owner of operations: %v", err)
+		}
+	}
+	operations, err := apiserver.NewOperationsWithRegistry(newRegistry(), owner, c.OperationTTL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start tracking operations: %v", err)
+	}
+	m.operations = operations
+	if m.eventTTL == 0 {
+		m.eventTTL = event.DefaultTTL
+	}
+	if c.PortalNet != nil {
+		m.portals = service.NewEtcdIPAllocator(c.PortalNet, etcdClient, "/registry/portalIPs")
+	}
+	if c.ServicePortRange.Size > 0 {
+		servicePorts := c.ServicePortRange
+		m.servicePorts = &servicePorts
+	}
+	if err := m.init(c.Cloud, c.PodInfoGetter); err != nil {
+		return nil, err
+	}
+	if j := c.Janitor; j.PodTTL > 0 || j.EndpointsTTL > 0 || j.OperationTTL > 0 {
+		// Minions which are merely unhealthy still own their pods.
+		janitor := etcd.NewJanitor(newRegistry(), knownMinions, j)
+		go util.Forever(func() {
+			if err := janitor.Sweep(); err != nil {
+				glog.Errorf("Unable to remove orphaned registry entries: %v", err)
+			}
+		}, time.Minute)
+	}
+	if err := addStorage(m.storage, c.Storage); err != nil {
+		glog.Fatalf("Unable to add storage: %v", err)
+	}
+	return m, nil
 }
 
-func makeMinionRegistry(c *Config) minion.Registry {
-	var minionRegistry minion.Registry
+// makeMinionRegistry returns the minion registry to schedule against, and the
+// one it filters, which lists every known minion whatever its health.
+func makeMinionRegistry(c *Config) (minionRegistry, known minion.Registry) {
 	if c.Cloud != nil && len(c.MinionRegexp) > 0 {
 		var err error
 		minionRegistry, err = minion.NewCloudRegistry(c.Cloud, c.MinionRegexp)
@@ -90,6 +203,8 @@ func makeMinionRegistry(c *Config) minion.Registry {
 	if minionRegistry == nil {
 		minionRegistry = minion.NewRegistry(c.Minions)
 	}
+	minionRegistry = minion.NewInstrumentedRegistry(minionRegistry, metrics.Default)
+	known = minionRegistry
 	if c.HealthCheckMinions {
 		minionRegistry = minion.NewHealthyRegistry(minionRegistry, &http.Client{})
 	}
@@ -101,18 +216,59 @@ func makeMinionRegistry(c *Config) minion.Registry {
 			minionRegistry = cachingMinionRegistry
 		}
 	}
-	return minionRegistry
+	if c.MinionHeartbeatTimeout > 0 {
+		minionRegistry = minion.NewHeartbeatRegistry(minionRegistry, c.MinionHeartbeatTimeout)
+	}
+	return minionRegistry, known
 }
 
-func (m *Master) init(cloud cloudprovider.Interface, podInfoGetter client.PodInfoGetter) {
-	podCache := NewPodCache(podInfoGetter, m.podRegistry)
-	go util.Forever(func() { podCache.UpdateAllContainers() }, time.Second*30)
+// makeMinionAdmission returns the check of registering minions, nil to admit
+// every minion.
+func makeMinionAdmission(c *Config) (minion.AdmissionFunc, error) {
+	if len(c.MinionAdmissionRegexp) == 0 {
+		return nil, nil
+	}
+	re, err := regexp.Compile(c.MinionAdmissionRegexp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minion admission regexp %q: %v", c.MinionAdmissionRegexp, err)
+	}
+	return minion.AdmitMatching(re), nil
+}
+
+// init sets up the storage of m and starts its background work. Nothing is
+// started if the scheduler can't be built.
+func (m *Master) init(cloud cloudprovider.Interface, podInfoGetter client.PodInfoGetter) error {
+	s, err := scheduler.NewAlgorithmFromProvider(
+		scheduler.DefaultProvider,
+		scheduler.PluginFactoryArgs{
+			PodLister:     registryPodLister{m.podRegistry},
+			ServiceLister: m.serviceRegistry,
+			MinionInfo:    registryMinionInfo{m.minionRegistry},
+		})
+	if err != nil {
+		return fmt.Errorf("unable to create the scheduler: %v", err)
+	}
+
+	podCache := NewPodCache(podInfoGetter, m.podRegistry, time.Second*30)
+	go util.Forever(func() { podCache.WatchPods() }, time.Second)
+	go util.Forever(func() { podCache.UpdateStaleContainers() }, time.Second*10)
 
 	endpoints := endpoint.NewEndpointController(m.serviceRegistry, m.client)
-	go util.Forever(func() { endpoints.SyncServiceEndpoints() }, time.Second*10)
+	endpoints.Run(time.Second * 10)
+
+	if m.portals != nil {
+		repairer := service.NewPortalRepairer(m.serviceRegistry, m.portals)
+		// Rebuild the allocations before serving, so that no IP in use is handed out again.
+		if err := repairer.Repair(); err != nil {
+			glog.Errorf("Unable to repair portal IP allocations: %v", err)
+		}
+		go util.Forever(func() {
+			if err := repairer.Repair(); err != nil {
+				glog.Errorf("Unable to repair portal IP allocations: %v", err)
+			}
+		}, time.Minute)
+	}
 
-	random := rand.New(rand.NewSource(int64(time.Now().Nanosecond())))
-	s := scheduler.NewRandomFitScheduler(m.podRegistry, random)
 	m.storage = map[string]apiserver.RESTStorage{
 		"pods": pod.NewRegistryStorage(&pod.RegistryStorageConfig{
 			CloudProvider: cloud,
@@ -123,12 +279,72 @@ func (m *Master) init(cloud cloudprovider.Interface, podInfoGetter client.PodInf
 			Scheduler:     s,
 		}),
 		"replicationControllers": controller.NewRegistryStorage(m.controllerRegistry, m.podRegistry),
-		"services":               service.NewRegistryStorage(m.serviceRegistry, cloud, m.minionRegistry),
-		"minions":                minion.NewRegistryStorage(m.minionRegistry),
+		"resizes":                controller.NewResizeStorage(m.controllerRegistry),
+		"daemonControllers":      daemon.NewRegistryStorage(m.daemonRegistry),
+		"services":               service.NewRegistryStorageWithPortals(m.serviceRegistry, cloud, m.minionRegistry, m.portals, m.servicePorts),
+		"endpoints":              endpoint.NewRegistryStorage(m.serviceRegistry),
+		"minions":                minion.NewAdmittingRegistryStorage(m.minionRegistry, m.minionAdmission),
+		"podStatusReports":       pod.NewReportStorage(podCache, m.podRegistry),
+		"events":                 event.NewRegistryStorage(m.eventRegistry, m.eventTTL),
 
 		// TODO: should appear only in scheduler API group.
 		"bindings": binding.NewBindingStorage(m.bindingRegistry),
 	}
+	return nil
+}
+
+// registryPodLister lets the scheduler list pods straight from a pod registry.
+type registryPodLister struct {
+	registry pod.Registry
+}
+
+func (l registryPodLister) ListPods(selector labels.Selector) ([]api.Pod, error) {
+	list, err := l.registry.ListPods(selector)
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// registryMinionInfo lets the scheduler find what minions reported about
+// themselves to a minion registry, and how healthy the registry deems them.
+type registryMinionInfo struct {
+	registry minion.Registry
+}
+
+// GetMinionInfo returns the last report of the minion, with the status the
+// registry tracks if it does. Minions which haven't reported have no known
+// capacity nor labels.
+func (i registryMinionInfo) GetMinionInfo(minionID string) (*api.Minion, error) {
+	info := api.Minion{JSONBase: api.JSONBase{ID: minionID}}
+	if reporting, ok := i.registry.(minion.ReportingRegistry); ok {
+		if reported, ok := reporting.Reported(minionID); ok {
+			info = reported
+		}
+	}
+	if status, ok := i.registry.(minion.StatusRegistry); ok {
+		info.Status = status.Status(minionID)
+	}
+	return &info, nil
+}
+
+// addStorage adds the resources in extra to storage, refusing to replace any
+// resource storage already has.
+func addStorage(storage, extra map[string]apiserver.RESTStorage) error {
+	for name := range extra {
+		if _, exists := storage[name]; exists {
+			return fmt.Errorf("resource %q is already registered", name)
+		}
+	}
+	for name, s := range extra {
+		storage[name] = s
+	}
+	return nil
+}
+
+// Operations returns the master's asynchronous operations, which are recorded in etcd.
+func (m *Master) Operations() *apiserver.Operations {
+	return m.operations
 }
 
 // API_v1beta1 returns the resources and codec for API version v1beta1
@@ -139,3 +355,16 @@ func (m *Master) API_v1beta1() (map[string]apiserver.RESTStorage, apiserver.Code
 	}
 	return storage, api.Codec
 }
+
+// API_v1beta2 returns the resources and codec for API version v1beta2
+func (m *Master) API_v1beta2() (map[string]apiserver.RESTStorage, apiserver.Codec) {
+	storage := make(map[string]apiserver.RESTStorage)
+	for k, v := range m.storage {
+		storage[k] = v
+	}
+	codec, err := api.CodecForVersion("v1beta2")
+	if err != nil {
+		glog.Fatalf("Unable to get the v1beta2 codec: %v", err)
+	}
+	return storage, codec
+}
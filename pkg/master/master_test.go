@@ -0,0 +1,48 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/minion"
+)
+
+func TestAddStorage(t *testing.T) {
+	pods := minion.NewRegistryStorage(minion.NewRegistry(nil))
+	events := minion.NewRegistryStorage(minion.NewRegistry(nil))
+	storage := map[string]apiserver.RESTStorage{"pods": pods}
+
+	if err := addStorage(storage, map[string]apiserver.RESTStorage{"events": events}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if storage["events"] != events || storage["pods"] != pods {
+		t.Errorf("unexpected storage: %#v", storage)
+	}
+
+	err := addStorage(storage, map[string]apiserver.RESTStorage{"pods": events, "secrets": events})
+	if err == nil {
+		t.Errorf("expected an error replacing a resource")
+	}
+	if storage["pods"] != pods {
+		t.Errorf("built-in resource was replaced")
+	}
+	if _, ok := storage["secrets"]; ok {
+		t.Errorf("expected no resources to be added on error")
+	}
+}
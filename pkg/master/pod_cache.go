@@ -18,31 +18,70 @@ package master
 
 import (
 	"sync"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/pod"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
 
 	"github.com/golang/glog"
 )
 
 // PodCache contains both a cache of container information, as well as the mechanism for keeping
-// that cache up to date.
+// that cache up to date. Kubelets push the information for their pods to the cache, and a watch
+// on the pod registry tells it which pods exist where. A kubelet is only asked directly about a
+// pod when it hasn't reported on it for longer than maxAge.
 type PodCache struct {
 	containerInfo client.PodInfoGetter
 	pods          pod.Registry
-	// This is a map of pod id to a map of container name to the
-	podInfo map[string]api.PodInfo
+	maxAge        time.Duration
+	now           func() time.Time
+	// This is a map of pod to what the kubelet of a host said about it.
+	podInfo map[podKey]*podCacheEntry
+	// This is a map of pod id to the host the pod is on, as far as the pod
+	// registry says.
+	hosts   map[string]podHostEntry
 	podLock sync.Mutex
 }
 
-// NewPodCache returns a new PodCache which watches container information registered in the given PodRegistry.
-func NewPodCache(info client.PodInfoGetter, pods pod.Registry) *PodCache {
+// podHostEntry is the host a pod is on, and the resourceVersion of the pod
+// registry that was learned at, or 0 if it was only reported by the host.
+type podHostEntry struct {
+	host    string
+	version uint64
+}
+
+// podKey identifies a pod on a host. Only what the kubelet of the host a pod is
+// on says about it counts, and a pod which moves starts over.
+type podKey struct {
+	host, podID string
+}
+
+// podCacheEntry is what a PodCache knows about a single pod.
+type podCacheEntry struct {
+	// info is nil until the first report or poll.
+	info api.PodInfo
+	// updated is when info was last reported or polled.
+	updated time.Time
+	// conditions, waiting and podIP are what the kubelet last reported; polls
+	// don't change them.
+	conditions []api.PodCondition
+	waiting    map[string]api.ContainerWaiting
+	podIP      string
+}
+
+// NewPodCache returns a new PodCache which watches container information registered in the given
+// PodRegistry, considering the information about a pod stale after maxAge.
+func NewPodCache(info client.PodInfoGetter, pods pod.Registry, maxAge time.Duration) *PodCache {
 	return &PodCache{
 		containerInfo: info,
 		pods:          pods,
-		podInfo:       map[string]api.PodInfo{},
+		maxAge:        maxAge,
+		now:           time.Now,
+		podInfo:       map[podKey]*podCacheEntry{},
+		hosts:         map[string]podHostEntry{},
 	}
 }
 
@@ -51,11 +90,93 @@ func NewPodCache(info client.PodInfoGetter, pods pod.Registry) *PodCache {
 func (p *PodCache) GetPodInfo(host, podID string) (api.PodInfo, error) {
 	p.podLock.Lock()
 	defer p.podLock.Unlock()
-	value, ok := p.podInfo[podID]
-	if !ok {
+	entry, ok := p.podInfo[podKey{host, podID}]
+	if !ok || entry.info == nil {
 		return nil, client.ErrPodInfoNotAvailable
 	}
-	return value, nil
+	return entry.info, nil
+}
+
+// GetPodConditions implements pod.ConditionGetter. It returns nil for pods whose
+// kubelet hasn't reported on them since they moved to host.
+func (p *PodCache) GetPodConditions(host, podID string) []api.PodCondition {
+	p.podLock.Lock()
+	defer p.podLock.Unlock()
+	entry, ok := p.podInfo[podKey{host, podID}]
+	if !ok {
+		return nil
+	}
+	return entry.conditions
+}
+
+// GetPodWaiting implements pod.ConditionGetter. It returns nil for pods whose
+// kubelet hasn't reported on them since they moved to host.
+func (p *PodCache) GetPodWaiting(host, podID string) map[string]api.ContainerWaiting {
+	p.podLock.Lock()
+	defer p.podLock.Unlock()
+	entry, ok := p.podInfo[podKey{host, podID}]
+	if !ok {
+		return nil
+	}
+	return entry.waiting
+}
+
+// GetPodIP implements pod.ConditionGetter. It returns "" for pods whose kubelet
+// hasn't reported an IP address for them since they moved to host.
+func (p *PodCache) GetPodIP(host, podID string) string {
+	p.podLock.Lock()
+	defer p.podLock.Unlock()
+	entry, ok := p.podInfo[podKey{host, podID}]
+	if !ok {
+		return ""
+	}
+	return entry.podIP
+}
+
+// ReportPodInfo records the container information, conditions, waiting
+// containers and IP address a kubelet sent for one of its pods.
+func (p *PodCache) ReportPodInfo(host, podID string, info api.PodInfo, conditions []api.PodCondition, waiting map[string]api.ContainerWaiting, podIP string) {
+	p.podLock.Lock()
+	defer p.podLock.Unlock()
+	p.podInfo[podKey{host, podID}] = &podCacheEntry{info: info, updated: p.now(), conditions: conditions, waiting: waiting, podIP: podIP}
+	if _, ok := p.hosts[podID]; !ok {
+		// Reports are only accepted from the host a pod is bound to.
+		p.hosts[podID] = podHostEntry{host: host}
+	}
+}
+
+// setHost records where a pod is as of version, forgetting what is known about
+// it if it moved.
+func (p *PodCache) setHost(podID, host string, version uint64) {
+	p.podLock.Lock()
+	defer p.podLock.Unlock()
+	p.setHostLocked(podID, host, version)
+}
+
+// setHostLocked is setHost for callers holding podLock.
+func (p *PodCache) setHostLocked(podID, host string, version uint64) {
+	if old, ok := p.hosts[podID]; ok && old.host != host {
+		delete(p.podInfo, podKey{old.host, podID})
+	}
+	p.hosts[podID] = podHostEntry{host, version}
+}
+
+// remove forgets a pod.
+func (p *PodCache) remove(podID string) {
+	p.podLock.Lock()
+	defer p.podLock.Unlock()
+	if entry, ok := p.hosts[podID]; ok {
+		delete(p.podInfo, podKey{entry.host, podID})
+		delete(p.hosts, podID)
+	}
+}
+
+// fresh returns true if the information about podID on host is younger than maxAge.
+func (p *PodCache) fresh(host, podID string) bool {
+	p.podLock.Lock()
+	defer p.podLock.Unlock()
+	entry, ok := p.podInfo[podKey{host, podID}]
+	return ok && entry.info != nil && p.now().Sub(entry.updated) < p.maxAge
 }
 
 func (p *PodCache) updatePodInfo(host, id string) error {
@@ -65,21 +186,86 @@ func (p *PodCache) updatePodInfo(host, id string) error {
 	}
 	p.podLock.Lock()
 	defer p.podLock.Unlock()
-	p.podInfo[id] = info
+	key := podKey{host, id}
+	entry, ok := p.podInfo[key]
+	if !ok {
+		entry = &podCacheEntry{}
+		p.podInfo[key] = entry
+	}
+	entry.info, entry.updated = info, p.now()
 	return nil
 }
 
-// UpdateAllContainers updates information about all containers.  Either called by Loop() below, or one-off.
-func (p *PodCache) UpdateAllContainers() {
+// WatchPods keeps the cache's view of which pods exist, and on which host, up to date
+// until the watch on the pod registry ends. Meant to be called via util.Forever.
+func (p *PodCache) WatchPods() {
+	w, err := p.pods.WatchPods(0)
+	if err != nil {
+		glog.Errorf("Error watching pods: %v", err)
+		return
+	}
+	defer w.Stop()
+	for event := range w.ResultChan() {
+		pod, ok := event.Object.(*api.Pod)
+		if !ok {
+			glog.Errorf("Unexpected object in pod watch: %#v", event.Object)
+			continue
+		}
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			p.setHost(pod.ID, podHost(pod), pod.ResourceVersion)
+		case watch.Deleted:
+			p.remove(pod.ID)
+		}
+	}
+}
+
+// podHost returns the host pod is on. Unlike listed pods, pods from a watch don't
+// have their current host filled in from the desired one.
+func podHost(pod *api.Pod) string {
+	if pod.CurrentState.Host != "" {
+		return pod.CurrentState.Host
+	}
+	return pod.DesiredState.Host
+}
+
+// UpdateStaleContainers asks the kubelets about the pods whose information is missing or older
+// than maxAge, and forgets about pods which no longer exist or have moved.
+func (p *PodCache) UpdateStaleContainers() {
 	pods, err := p.pods.ListPods(labels.Everything())
 	if err != nil {
 		glog.Errorf("Error synchronizing container list: %v", err)
 		return
 	}
-	for _, pod := range pods {
+	existing := map[podKey]bool{}
+	hosts := map[string]string{}
+	for _, pod := range pods.Items {
+		existing[podKey{pod.CurrentState.Host, pod.ID}] = true
+		hosts[pod.ID] = pod.CurrentState.Host
+		if p.fresh(pod.CurrentState.Host, pod.ID) {
+			continue
+		}
 		err := p.updatePodInfo(pod.CurrentState.Host, pod.ID)
 		if err != nil && err != client.ErrPodInfoNotAvailable {
 			glog.Errorf("Error synchronizing container: %v", err)
 		}
 	}
+	p.podLock.Lock()
+	defer p.podLock.Unlock()
+	for key := range p.podInfo {
+		if !existing[key] {
+			delete(p.podInfo, key)
+		}
+	}
+	// The watch may have seen newer changes than the list, which are kept.
+	for id, entry := range p.hosts {
+		if _, listed := hosts[id]; !listed && entry.version <= pods.ResourceVersion {
+			delete(p.hosts, id)
+		}
+	}
+	for id, host := range hosts {
+		if entry, ok := p.hosts[id]; !ok || entry.version <= pods.ResourceVersion {
+			p.setHostLocked(id, host, pods.ResourceVersion)
+		}
+	}
 }
@@ -19,6 +19,7 @@ package master
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/registrytest"
@@ -39,10 +40,10 @@ func (f *FakePodInfoGetter) GetPodInfo(host, id string) (api.PodInfo, error) {
 }
 
 func TestPodCacheGet(t *testing.T) {
-	cache := NewPodCache(nil, nil)
+	cache := NewPodCache(nil, nil, time.Minute)
 
 	expected := api.PodInfo{"foo": docker.Container{ID: "foo"}}
-	cache.podInfo["foo"] = expected
+	cache.podInfo[podKey{"host", "foo"}] = &podCacheEntry{info: expected}
 
 	info, err := cache.GetPodInfo("host", "foo")
 	if err != nil {
@@ -54,7 +55,7 @@ func TestPodCacheGet(t *testing.T) {
 }
 
 func TestPodCacheGetMissing(t *testing.T) {
-	cache := NewPodCache(nil, nil)
+	cache := NewPodCache(nil, nil, time.Minute)
 
 	info, err := cache.GetPodInfo("host", "foo")
 	if err == nil {
@@ -70,7 +71,7 @@ func TestPodGetPodInfoGetter(t *testing.T) {
 	fake := FakePodInfoGetter{
 		data: expected,
 	}
-	cache := NewPodCache(&fake, nil)
+	cache := NewPodCache(&fake, nil, time.Minute)
 
 	cache.updatePodInfo("host", "foo")
 
@@ -87,7 +88,7 @@ func TestPodGetPodInfoGetter(t *testing.T) {
 	}
 }
 
-func TestPodUpdateAllContainers(t *testing.T) {
+func TestPodUpdateStaleContainers(t *testing.T) {
 	pod := api.Pod{
 		JSONBase: api.JSONBase{ID: "foo"},
 		CurrentState: api.PodState{
@@ -102,9 +103,9 @@ func TestPodUpdateAllContainers(t *testing.T) {
 	fake := FakePodInfoGetter{
 		data: expected,
 	}
-	cache := NewPodCache(&fake, mockRegistry)
+	cache := NewPodCache(&fake, mockRegistry, time.Minute)
 
-	cache.UpdateAllContainers()
+	cache.UpdateStaleContainers()
 
 	if fake.host != "machine" || fake.id != "foo" {
 		t.Errorf("Unexpected access: %#v", fake)
@@ -118,3 +119,131 @@ func TestPodUpdateAllContainers(t *testing.T) {
 		t.Errorf("Unexpected mismatch. Expected: %#v, Got: #%v", &expected, info)
 	}
 }
+
+func TestPodUpdateStaleContainersKeepsNewerHosts(t *testing.T) {
+	mockRegistry := registrytest.NewPodRegistry([]api.Pod{
+		{JSONBase: api.JSONBase{ID: "foo"}, CurrentState: api.PodState{Host: "machine"}},
+	})
+	cache := NewPodCache(&FakePodInfoGetter{}, mockRegistry, time.Minute)
+	// The watch saw these after the pods were listed.
+	cache.setHost("foo", "other", 5)
+	cache.setHost("new", "machine", 5)
+	cache.setHost("gone", "machine", 0)
+
+	cache.UpdateStaleContainers()
+	expected := map[string]podHostEntry{
+		"foo": {"other", 5},
+		"new": {"machine", 5},
+	}
+	cache.podLock.Lock()
+	defer cache.podLock.Unlock()
+	if !reflect.DeepEqual(cache.hosts, expected) {
+		t.Errorf("expected hosts %v, got %v", expected, cache.hosts)
+	}
+}
+
+func TestPodUpdateStaleContainersSkipsFresh(t *testing.T) {
+	pods := []api.Pod{
+		{JSONBase: api.JSONBase{ID: "foo"}, CurrentState: api.PodState{Host: "machine"}},
+		{JSONBase: api.JSONBase{ID: "bar"}, CurrentState: api.PodState{Host: "machine"}},
+	}
+	mockRegistry := registrytest.NewPodRegistry(pods)
+	polled := api.PodInfo{"polled": docker.Container{ID: "polled"}}
+	fake := FakePodInfoGetter{data: polled}
+	now := time.Unix(100, 0)
+	cache := NewPodCache(&fake, mockRegistry, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	reported := api.PodInfo{"reported": docker.Container{ID: "reported"}}
+	ready := []api.PodCondition{api.PodReady}
+	waiting := map[string]api.ContainerWaiting{"bar": {Reason: "ImageNotFound"}}
+	cache.ReportPodInfo("machine", "foo", reported, ready, waiting, "10.1.2.3")
+	cache.ReportPodInfo("machine", "gone", reported, nil, nil, "")
+	cache.UpdateStaleContainers()
+
+	if fake.id != "bar" {
+		t.Errorf("expected only the unreported pod to be polled, got %#v", fake)
+	}
+	if info, _ := cache.GetPodInfo("machine", "foo"); !reflect.DeepEqual(info, reported) {
+		t.Errorf("unexpected info: %#v", info)
+	}
+	if _, err := cache.GetPodInfo("machine", "gone"); err == nil {
+		t.Errorf("expected info of a deleted pod to be dropped")
+	}
+
+	// Once the report is too old, the kubelet is asked.
+	now = now.Add(2 * time.Minute)
+	fake.id = ""
+	cache.UpdateStaleContainers()
+	if info, _ := cache.GetPodInfo("machine", "foo"); !reflect.DeepEqual(info, polled) {
+		t.Errorf("unexpected info: %#v", info)
+	}
+	// Polling doesn't tell the cache about conditions, so the reported ones are kept.
+	if conditions := cache.GetPodConditions("machine", "foo"); !reflect.DeepEqual(conditions, ready) {
+		t.Errorf("unexpected conditions: %v", conditions)
+	}
+	if conditions := cache.GetPodConditions("other", "foo"); conditions != nil {
+		t.Errorf("unexpected conditions on another host: %v", conditions)
+	}
+	if w := cache.GetPodWaiting("machine", "foo"); !reflect.DeepEqual(w, waiting) {
+		t.Errorf("unexpected waiting containers: %v", w)
+	}
+	if w := cache.GetPodWaiting("other", "foo"); w != nil {
+		t.Errorf("unexpected waiting containers on another host: %v", w)
+	}
+	if ip := cache.GetPodIP("machine", "foo"); ip != "10.1.2.3" {
+		t.Errorf("unexpected pod IP: %q", ip)
+	}
+	if ip := cache.GetPodIP("other", "foo"); ip != "" {
+		t.Errorf("unexpected pod IP on another host: %q", ip)
+	}
+}
+
+func TestPodCacheWatchPods(t *testing.T) {
+	mockRegistry := registrytest.NewPodRegistry(nil)
+	cache := NewPodCache(nil, mockRegistry, time.Minute)
+	done := make(chan struct{})
+	go func() {
+		cache.WatchPods()
+		close(done)
+	}()
+	cache.ReportPodInfo("machine", "foo", api.PodInfo{"foo": docker.Container{ID: "foo"}}, nil, nil, "")
+
+	// Wait for the watch to be established.
+	for {
+		mockRegistry.UpdatePod(api.Pod{JSONBase: api.JSONBase{ID: "bar"}, DesiredState: api.PodState{Host: "other"}})
+		cache.podLock.Lock()
+		_, ok := cache.hosts["bar"]
+		cache.podLock.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Moving a pod forgets what was known about it.
+	mockRegistry.UpdatePod(api.Pod{JSONBase: api.JSONBase{ID: "foo"}, DesiredState: api.PodState{Host: "other"}})
+	mockRegistry.UpdatePod(api.Pod{JSONBase: api.JSONBase{ID: "bar"}, DesiredState: api.PodState{Host: "other"}})
+	mockRegistry.DeletePod("bar")
+	mockRegistry.UpdatePod(api.Pod{JSONBase: api.JSONBase{ID: "sync"}})
+	for {
+		cache.podLock.Lock()
+		_, ok := cache.hosts["sync"]
+		cache.podLock.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := cache.GetPodInfo("other", "foo"); err == nil {
+		t.Errorf("expected info of a moved pod to be dropped")
+	}
+	if _, err := cache.GetPodInfo("machine", "foo"); err == nil {
+		t.Errorf("expected info from the old host of a moved pod to be dropped")
+	}
+	cache.podLock.Lock()
+	if _, ok := cache.hosts["bar"]; ok {
+		t.Errorf("expected deleted pod to be dropped")
+	}
+	cache.podLock.Unlock()
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter is a number which only goes up.
+type Counter struct {
+	value uint64
+}
+
+// Inc adds one to c.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds n to c.
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value returns the current value of c.
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// latencyBuckets are the upper bounds of the buckets of a Histogram.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// Histogram counts durations in buckets from 1ms to 5s, plus one for
+// anything longer.
+type Histogram struct {
+	lock    sync.Mutex
+	count   uint64
+	sum     time.Duration
+	buckets []uint64
+}
+
+func newHistogram() *Histogram {
+	return &Histogram{buckets: make([]uint64, len(latencyBuckets)+1)}
+}
+
+// Observe records one duration.
+func (h *Histogram) Observe(d time.Duration) {
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return d <= latencyBuckets[i] })
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.count++
+	h.sum += d
+	h.buckets[i]++
+}
+
+// Since records the time passed since start.
+func (h *Histogram) Since(start time.Time) {
+	h.Observe(time.Since(start))
+}
+
+// HistogramSnapshot is the state of a Histogram as served.
+type HistogramSnapshot struct {
+	Count uint64 `json:"count"`
+	// SumMillis is the total of all durations, in milliseconds.
+	SumMillis float64 `json:"sumMillis"`
+	// Buckets counts the durations no longer than each bound, given in
+	// milliseconds, or "+Inf". Buckets are not cumulative.
+	Buckets map[string]uint64 `json:"buckets"`
+}
+
+// Snapshot returns the current state of h.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	s := HistogramSnapshot{
+		Count:     h.count,
+		SumMillis: float64(h.sum) / float64(time.Millisecond),
+		Buckets:   map[string]uint64{},
+	}
+	for i, n := range h.buckets {
+		bound := "+Inf"
+		if i < len(latencyBuckets) {
+			bound = strconv.FormatInt(int64(latencyBuckets[i]/time.Millisecond), 10)
+		}
+		s.Buckets[bound] = n
+	}
+	return s
+}
+
+// Registry holds named counters, gauges and histograms.
+type Registry struct {
+	lock       sync.Mutex
+	counters   map[string]*Counter
+	gauges     map[string]func() int64
+	histograms map[string]*Histogram
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		counters:   map[string]*Counter{},
+		gauges:     map[string]func() int64{},
+		histograms: map[string]*Histogram{},
+	}
+}
+
+// Default is the Registry served by InstallHandler.
+var Default = NewRegistry()
+
+// Counter returns the counter called name, creating it if needed.
+func (r *Registry) Counter(name string) *Counter {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	c, ok := r.counters[name]
+	if !ok {
+		c = &Counter{}
+		r.counters[name] = c
+	}
+	return c
+}
+
+// Gauge makes value the gauge called name, replacing any gauge called so before.
+// Gauges measure something which goes up and down, like the length of a queue;
+// value is called whenever r is snapshotted, and must be safe to call from any
+// goroutine.
+func (r *Registry) Gauge(name string, value func() int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.gauges[name] = value
+}
+
+// Histogram returns the histogram called name, creating it if needed.
+func (r *Registry) Histogram(name string) *Histogram {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	h, ok := r.histograms[name]
+	if !ok {
+		h = newHistogram()
+		r.histograms[name] = h
+	}
+	return h
+}
+
+// Delete drops the counter, gauge and histogram called name, e.g. once what
+// they measured is gone. A Counter or Histogram returned before keeps working,
+// but is no longer served.
+func (r *Registry) Delete(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.counters, name)
+	delete(r.gauges, name)
+	delete(r.histograms, name)
+}
+
+// Snapshot is the state of a Registry as served.
+type Snapshot struct {
+	Counters   map[string]uint64            `json:"counters"`
+	Gauges     map[string]int64             `json:"gauges"`
+	Histograms map[string]HistogramSnapshot `json:"histograms"`
+}
+
+// Snapshot returns the current state of every metric in r.
+func (r *Registry) Snapshot() Snapshot {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	s := Snapshot{
+		Counters:   map[string]uint64{},
+		Gauges:     map[string]int64{},
+		Histograms: map[string]HistogramSnapshot{},
+	}
+	for name, c := range r.counters {
+		s.Counters[name] = c.Value()
+	}
+	for name, value := range r.gauges {
+		s.Gauges[name] = value()
+	}
+	for name, h := range r.histograms {
+		s.Histograms[name] = h.Snapshot()
+	}
+	return s
+}
+
+// ServeHTTP serves a Snapshot of r as JSON.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	data, err := json.Marshal(r.Snapshot())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// mux is an interface describing the methods InstallHandler requires.
+type mux interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// InstallHandler registers a handler serving Default on the path "/metrics" to mux.
+func InstallHandler(mux mux) {
+	mux.Handle("/metrics", Default)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.Histogram("latency")
+	h.Observe(time.Millisecond)
+	h.Observe(3 * time.Millisecond)
+	h.Observe(time.Minute)
+	if r.Histogram("latency") != h {
+		t.Errorf("expected the same histogram back")
+	}
+	s := h.Snapshot()
+	if s.Count != 3 || s.SumMillis != 60004 {
+		t.Errorf("unexpected snapshot %#v", s)
+	}
+	for bound, expected := range map[string]uint64{"1": 1, "2": 0, "5": 1, "+Inf": 1} {
+		if s.Buckets[bound] != expected {
+			t.Errorf("expected %d in bucket %s, got %d", expected, bound, s.Buckets[bound])
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("foo").Inc()
+	r.Gauge("foo", func() int64 { return 1 })
+	r.Counter("bar").Inc()
+	r.Delete("foo")
+	s := r.Snapshot()
+	if !reflect.DeepEqual(s.Counters, map[string]uint64{"bar": 1}) || len(s.Gauges) != 0 {
+		t.Errorf("unexpected snapshot %#v", s)
+	}
+	if r.Counter("foo").Value() != 0 {
+		t.Errorf("expected a new counter after deleting the old one")
+	}
+}
+
+func TestInstallHandler(t *testing.T) {
+	defer func(old *Registry) { Default = old }(Default)
+	Default = NewRegistry()
+	Default.Counter("foo").Add(2)
+	Default.Histogram("bar").Observe(time.Millisecond)
+	queued := int64(3)
+	Default.Gauge("baz", func() int64 { return queued })
+
+	mux := http.NewServeMux()
+	InstallHandler(mux)
+	req, err := http.NewRequest("GET", "http://example.com/metrics", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %v, got %v", http.StatusOK, w.Code)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(s.Counters, map[string]uint64{"foo": 2}) || s.Histograms["bar"].Count != 1 {
+		t.Errorf("unexpected snapshot %#v", s)
+	}
+	if !reflect.DeepEqual(s.Gauges, map[string]int64{"baz": 3}) {
+		t.Errorf("unexpected gauges %#v", s.Gauges)
+	}
+
+	queued = 1
+	if e, a := int64(1), Default.Snapshot().Gauges["baz"]; e != a {
+		t.Errorf("expected the gauge to be read on every snapshot, got %v", a)
+	}
+}
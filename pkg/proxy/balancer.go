@@ -0,0 +1,293 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/golang/glog"
+)
+
+var (
+	ErrMissingServiceEntry = errors.New("missing service entry")
+	ErrMissingEndpoints    = errors.New("missing endpoints")
+)
+
+// affinityState is the endpoint a client IP sticks to.
+type affinityState struct {
+	endpoint string
+	lastUsed time.Time
+}
+
+// affinityPolicy is the session affinity of a service.
+type affinityPolicy struct {
+	ttl     time.Duration
+	clients map[string]*affinityState // client IP -> affinity
+	// When the expired clients were last forgotten.
+	lastExpired time.Time
+}
+
+// Balancer is a LoadBalancer which spreads the connections to every service
+// according to its balancing policy, and counts the open connections of every
+// endpoint.
+type Balancer struct {
+	lock         sync.Mutex
+	endpointsMap map[string][]string
+	policies     map[string]api.BalancingPolicyType
+	pickers      map[string]endpointPicker
+	affinityMap  map[string]*affinityPolicy
+	// The open connections of every endpoint of any service.
+	connections map[string]int
+	// nil means every endpoint gets connections.
+	healthChecker *HealthChecker
+	// Defaults to time.Now, overridden in tests.
+	now func() time.Time
+}
+
+// NewBalancer returns a new Balancer, with every service balanced round-robin
+// until told otherwise.
+func NewBalancer() *Balancer {
+	return &Balancer{
+		endpointsMap: make(map[string][]string),
+		policies:     make(map[string]api.BalancingPolicyType),
+		pickers:      make(map[string]endpointPicker),
+		affinityMap:  make(map[string]*affinityPolicy),
+		connections:  make(map[string]int),
+		now:          time.Now,
+	}
+}
+
+// SetBalancingPolicy sets the balancing policy of the given service.
+func (lb *Balancer) SetBalancingPolicy(service string, policy api.BalancingPolicyType) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	if policy == "" {
+		policy = api.BalancingPolicyRoundRobin
+	}
+	if current, exists := lb.policies[service]; exists && current == policy {
+		return
+	}
+	glog.Infof("Balancer: Balancing %s with policy %s", service, policy)
+	lb.policies[service] = policy
+	delete(lb.pickers, service)
+}
+
+// SetSessionAffinity sets the session affinity of the given service. The
+// endpoints of clients are kept as long as the affinity type doesn't change.
+func (lb *Balancer) SetSessionAffinity(service string, affinityType api.AffinityType, ttl time.Duration) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	if affinityType != api.AffinityTypeClientIP {
+		delete(lb.affinityMap, service)
+		return
+	}
+	if policy, exists := lb.affinityMap[service]; exists {
+		policy.ttl = ttl
+		return
+	}
+	glog.Infof("Balancer: Keeping clients of %s on their endpoints for %v", service, ttl)
+	lb.affinityMap[service] = &affinityPolicy{
+		ttl:     ttl,
+		clients: make(map[string]*affinityState),
+	}
+}
+
+// SetHealthChecker makes the balancer leave out the endpoints checker found
+// unhealthy, unless no endpoint of the service is healthy.
+func (lb *Balancer) SetHealthChecker(checker *HealthChecker) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	lb.healthChecker = checker
+}
+
+// healthyEndpoints returns the endpoints the health checker, if any, found
+// healthy, or all of them if none is.
+func (lb *Balancer) healthyEndpoints(endpoints []string) []string {
+	if lb.healthChecker == nil {
+		return endpoints
+	}
+	var healthy []string
+	for _, endpoint := range endpoints {
+		if lb.healthChecker.Healthy(endpoint) {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	if len(healthy) == 0 {
+		return endpoints
+	}
+	return healthy
+}
+
+// clientIP returns the IP of srcAddr, or "" if it has none.
+func clientIP(srcAddr net.Addr) string {
+	if srcAddr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(srcAddr.String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// NextEndpoint returns a service endpoint, and counts a connection to it until
+// ReleaseEndpoint is called.
+// The service endpoint is chosen by the balancing policy of the service, unless
+// the service has client IP affinity and srcAddr already has an endpoint.
+// Endpoints the health checker found unhealthy are left out.
+func (lb *Balancer) NextEndpoint(service string, srcAddr net.Addr) (string, error) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	endpoints, exists := lb.endpointsMap[service]
+	if !exists {
+		return "", ErrMissingServiceEntry
+	}
+	if len(endpoints) == 0 {
+		return "", ErrMissingEndpoints
+	}
+	endpoints = lb.healthyEndpoints(endpoints)
+	now := lb.now()
+	policy := lb.affinityMap[service]
+	ip := clientIP(srcAddr)
+	if policy != nil && ip != "" {
+		if state, found := policy.clients[ip]; found && now.Sub(state.lastUsed) < policy.ttl && (lb.healthChecker == nil || lb.healthChecker.Healthy(state.endpoint)) {
+			state.lastUsed = now
+			lb.connections[state.endpoint]++
+			return state.endpoint, nil
+		}
+	}
+	picker, exists := lb.pickers[service]
+	if !exists {
+		picker = newEndpointPicker(lb.policies[service])
+		lb.pickers[service] = picker
+	}
+	endpoint := picker.Pick(endpoints, lb.connections)
+	lb.connections[endpoint]++
+	if policy != nil && ip != "" {
+		if now.Sub(policy.lastExpired) >= policy.ttl {
+			policy.expire(now)
+		}
+		policy.clients[ip] = &affinityState{endpoint: endpoint, lastUsed: now}
+	}
+	return endpoint, nil
+}
+
+// ForgetService forgets the balancing policy and session affinity of the
+// given service.
+func (lb *Balancer) ForgetService(service string) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	delete(lb.policies, service)
+	delete(lb.pickers, service)
+	delete(lb.affinityMap, service)
+}
+
+// ReleaseEndpoint stops counting a connection NextEndpoint returned endpoint for.
+func (lb *Balancer) ReleaseEndpoint(service, endpoint string) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	if lb.connections[endpoint] <= 1 {
+		delete(lb.connections, endpoint)
+		return
+	}
+	lb.connections[endpoint]--
+}
+
+// expire forgets the clients which made no request for the ttl. It scans
+// every client, so NextEndpoint calls it at most once per ttl; until then the
+// expired clients are only skipped on lookup.
+func (policy *affinityPolicy) expire(now time.Time) {
+	policy.lastExpired = now
+	for ip, state := range policy.clients {
+		if now.Sub(state.lastUsed) >= policy.ttl {
+			delete(policy.clients, ip)
+		}
+	}
+}
+
+// forgetEndpoints forgets the clients whose endpoint isn't in endpoints.
+func (policy *affinityPolicy) forgetEndpoints(endpoints []string) {
+	valid := util.NewStringSet(endpoints...)
+	for ip, state := range policy.clients {
+		if !valid.Has(state.endpoint) {
+			delete(policy.clients, ip)
+		}
+	}
+}
+
+func isValidEndpoint(spec string) bool {
+	_, port, err := net.SplitHostPort(spec)
+	if err != nil {
+		return false
+	}
+	value, err := strconv.Atoi(port)
+	if err != nil {
+		return false
+	}
+	return value > 0
+}
+
+func filterValidEndpoints(endpoints []string) []string {
+	var result []string
+	for _, spec := range endpoints {
+		if isValidEndpoint(spec) {
+			result = append(result, spec)
+		}
+	}
+	return result
+}
+
+// OnUpdate manages the registered service endpoints.
+// Registered endpoints are updated if found in the update set or
+// unregistered if missing from the update set.
+func (lb *Balancer) OnUpdate(endpoints []api.Endpoints) {
+	registeredEndpoints := make(map[string]bool)
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	// Update endpoints for services.
+	for _, endpoint := range endpoints {
+		existingEndpoints, exists := lb.endpointsMap[endpoint.ID]
+		validEndpoints := filterValidEndpoints(endpoint.Endpoints)
+		if !exists || !reflect.DeepEqual(existingEndpoints, validEndpoints) {
+			glog.Infof("Balancer: Setting endpoints for %s to %+v", endpoint.ID, endpoint.Endpoints)
+			lb.endpointsMap[endpoint.ID] = validEndpoints
+			// Start the policy over, e.g. reset the round-robin index.
+			delete(lb.pickers, endpoint.ID)
+			if policy, exists := lb.affinityMap[endpoint.ID]; exists {
+				policy.forgetEndpoints(validEndpoints)
+			}
+		}
+		registeredEndpoints[endpoint.ID] = true
+	}
+	// Remove endpoints missing from the update.
+	for k, v := range lb.endpointsMap {
+		if _, exists := registeredEndpoints[k]; !exists {
+			glog.Infof("Balancer: Removing endpoints for %s -> %+v", k, v)
+			delete(lb.endpointsMap, k)
+			if policy, exists := lb.affinityMap[k]; exists {
+				policy.forgetEndpoints(nil)
+			}
+		}
+	}
+}
@@ -17,7 +17,9 @@ limitations under the License.
 package proxy
 
 import (
+	"net"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 )
@@ -56,7 +58,7 @@ func TestFilterWorks(t *testing.T) {
 }
 
 func TestLoadBalanceFailsWithNoEndpoints(t *testing.T) {
-	loadBalancer := NewLoadBalancerRR()
+	loadBalancer := NewBalancer()
 	var endpoints []api.Endpoints
 	loadBalancer.OnUpdate(endpoints)
 	endpoint, err := loadBalancer.NextEndpoint("foo", nil)
@@ -68,7 +70,7 @@ func TestLoadBalanceFailsWithNoEndpoints(t *testing.T) {
 	}
 }
 
-func expectEndpoint(t *testing.T, loadBalancer *LoadBalancerRR, service string, expected string) {
+func expectEndpoint(t *testing.T, loadBalancer *Balancer, service string, expected string) {
 	endpoint, err := loadBalancer.NextEndpoint(service, nil)
 	if err != nil {
 		t.Errorf("Didn't find a service for %s, expected %s, failed with: %v", service, expected, err)
@@ -79,7 +81,7 @@ func expectEndpoint(t *testing.T, loadBalancer *LoadBalancerRR, service string,
 }
 
 func TestLoadBalanceWorksWithSingleEndpoint(t *testing.T) {
-	loadBalancer := NewLoadBalancerRR()
+	loadBalancer := NewBalancer()
 	endpoint, err := loadBalancer.NextEndpoint("foo", nil)
 	if err == nil || len(endpoint) != 0 {
 		t.Errorf("Didn't fail with non-existent service")
@@ -97,7 +99,7 @@ func TestLoadBalanceWorksWithSingleEndpoint(t *testing.T) {
 }
 
 func TestLoadBalanceWorksWithMultipleEndpoints(t *testing.T) {
-	loadBalancer := NewLoadBalancerRR()
+	loadBalancer := NewBalancer()
 	endpoint, err := loadBalancer.NextEndpoint("foo", nil)
 	if err == nil || len(endpoint) != 0 {
 		t.Errorf("Didn't fail with non-existent service")
@@ -115,7 +117,7 @@ func TestLoadBalanceWorksWithMultipleEndpoints(t *testing.T) {
 }
 
 func TestLoadBalanceWorksWithMultipleEndpointsAndUpdates(t *testing.T) {
-	loadBalancer := NewLoadBalancerRR()
+	loadBalancer := NewBalancer()
 	endpoint, err := loadBalancer.NextEndpoint("foo", nil)
 	if err == nil || len(endpoint) != 0 {
 		t.Errorf("Didn't fail with non-existent service")
@@ -152,7 +154,7 @@ func TestLoadBalanceWorksWithMultipleEndpointsAndUpdates(t *testing.T) {
 }
 
 func TestLoadBalanceWorksWithServiceRemoval(t *testing.T) {
-	loadBalancer := NewLoadBalancerRR()
+	loadBalancer := NewBalancer()
 	endpoint, err := loadBalancer.NextEndpoint("foo", nil)
 	if err == nil || len(endpoint) != 0 {
 		t.Errorf("Didn't fail with non-existent service")
@@ -192,3 +194,125 @@ func TestLoadBalanceWorksWithServiceRemoval(t *testing.T) {
 	expectEndpoint(t, loadBalancer, "bar", "endpoint:5")
 	expectEndpoint(t, loadBalancer, "bar", "endpoint:4")
 }
+
+func expectClientEndpoint(t *testing.T, loadBalancer *Balancer, service string, client string, expected string) {
+	endpoint, err := loadBalancer.NextEndpoint(service, &net.TCPAddr{IP: net.ParseIP(client), Port: 1234})
+	if err != nil {
+		t.Errorf("Didn't find a service for %s, expected %s, failed with: %v", service, expected, err)
+	}
+	if endpoint != expected {
+		t.Errorf("Didn't get expected endpoint for service %s and client %s, expected %s, got: %s", service, client, expected, endpoint)
+	}
+}
+
+func TestLoadBalanceClientIPAffinity(t *testing.T) {
+	loadBalancer := NewBalancer()
+	now := time.Now()
+	loadBalancer.now = func() time.Time { return now }
+	loadBalancer.SetSessionAffinity("foo", api.AffinityTypeClientIP, time.Minute)
+	loadBalancer.OnUpdate([]api.Endpoints{{
+		JSONBase:  api.JSONBase{ID: "foo"},
+		Endpoints: []string{"endpoint:1", "endpoint:2", "endpoint:3"},
+	}})
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.1", "endpoint:1")
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.2", "endpoint:2")
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.1", "endpoint:1")
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.2", "endpoint:2")
+	// Requests without a client address are spread as usual.
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:3")
+
+	// Using the endpoint keeps it.
+	now = now.Add(50 * time.Second)
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.1", "endpoint:1")
+	now = now.Add(50 * time.Second)
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.1", "endpoint:1")
+	// 10.0.0.2 was idle for longer than the ttl.
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.2", "endpoint:1")
+
+	// Clients whose endpoint went away get a new one.
+	loadBalancer.OnUpdate([]api.Endpoints{{
+		JSONBase:  api.JSONBase{ID: "foo"},
+		Endpoints: []string{"endpoint:2", "endpoint:3"},
+	}})
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.1", "endpoint:2")
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.2", "endpoint:3")
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.1", "endpoint:2")
+
+	// Without affinity, clients are spread again.
+	loadBalancer.SetSessionAffinity("foo", api.AffinityTypeNone, time.Minute)
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.1", "endpoint:2")
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.1", "endpoint:3")
+}
+
+func TestLoadBalanceExpiresClientsOncePerTTL(t *testing.T) {
+	loadBalancer := NewBalancer()
+	now := time.Now()
+	loadBalancer.now = func() time.Time { return now }
+	loadBalancer.SetSessionAffinity("foo", api.AffinityTypeClientIP, time.Minute)
+	loadBalancer.OnUpdate([]api.Endpoints{{
+		JSONBase:  api.JSONBase{ID: "foo"},
+		Endpoints: []string{"endpoint:1", "endpoint:2"},
+	}})
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.1", "endpoint:1")
+	now = now.Add(30 * time.Second)
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.2", "endpoint:2")
+	// The sweep forgets 10.0.0.1.
+	now = now.Add(35 * time.Second)
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.3", "endpoint:1")
+	// 10.0.0.2 expired, but the last sweep was less than the ttl ago.
+	now = now.Add(35 * time.Second)
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.4", "endpoint:2")
+	if clients := loadBalancer.affinityMap["foo"].clients; len(clients) != 3 {
+		t.Errorf("expected 10.0.0.2, 10.0.0.3 and 10.0.0.4 before the next sweep, got %v", clients)
+	}
+	now = now.Add(30 * time.Second)
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.5", "endpoint:1")
+	if clients := loadBalancer.affinityMap["foo"].clients; len(clients) != 2 {
+		t.Errorf("expected 10.0.0.4 and 10.0.0.5 after the sweep, got %v", clients)
+	}
+}
+
+func TestLoadBalanceForgetService(t *testing.T) {
+	loadBalancer := NewBalancer()
+	loadBalancer.SetBalancingPolicy("foo", api.BalancingPolicyLeastConnections)
+	loadBalancer.SetSessionAffinity("foo", api.AffinityTypeClientIP, time.Minute)
+	loadBalancer.OnUpdate([]api.Endpoints{{
+		JSONBase:  api.JSONBase{ID: "foo"},
+		Endpoints: []string{"endpoint:1", "endpoint:2"},
+	}})
+	expectClientEndpoint(t, loadBalancer, "foo", "10.0.0.1", "endpoint:1")
+	loadBalancer.ForgetService("foo")
+	if _, exists := loadBalancer.affinityMap["foo"]; exists {
+		t.Errorf("expected the affinity of foo to be forgotten")
+	}
+	if _, exists := loadBalancer.policies["foo"]; exists {
+		t.Errorf("expected the balancing policy of foo to be forgotten")
+	}
+	if _, exists := loadBalancer.pickers["foo"]; exists {
+		t.Errorf("expected the picker of foo to be forgotten")
+	}
+}
+
+func TestLoadBalanceLeastConnections(t *testing.T) {
+	loadBalancer := NewBalancer()
+	loadBalancer.SetBalancingPolicy("foo", api.BalancingPolicyLeastConnections)
+	loadBalancer.OnUpdate([]api.Endpoints{{
+		JSONBase:  api.JSONBase{ID: "foo"},
+		Endpoints: []string{"endpoint:1", "endpoint:2"},
+	}})
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:1")
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:2")
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:1")
+	// endpoint:1 has two connections, endpoint:2 one.
+	loadBalancer.ReleaseEndpoint("foo", "endpoint:1")
+	loadBalancer.ReleaseEndpoint("foo", "endpoint:1")
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:1")
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:2")
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:1")
+
+	// Going back to round-robin starts over.
+	loadBalancer.SetBalancingPolicy("foo", api.BalancingPolicyRoundRobin)
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:1")
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:2")
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:1")
+}
@@ -14,18 +14,19 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// Watches etcd and gets the full configuration on preset intervals.
+// Watches etcd for changes of services and their endpoints, and lists them
+// all again every resync period in case a change was missed.
 // It expects the list of exposed services to live under:
-// registry/services
+// registry/services/specs/<namespace>
 // which in etcd is exposed like so:
-// http://<etcd server>/v2/keys/registry/services
+// http://<etcd server>/v2/keys/registry/services/specs/<namespace>
 //
 // The port that proxy needs to listen in for each service is a value in:
-// registry/services/<service>
+// registry/services/specs/<namespace>/<service>
 //
 // The endpoints for each of the services found is a json string
 // representing that service at:
-// /registry/services/<service>/endpoint
+// /registry/services/endpoints/<namespace>/<service>
 // and the format is:
 // '[ { "machine": <host>, "name": <name", "port": <port> },
 //    { "machine": <host2>, "name": <name2", "port": <port2> }
@@ -35,6 +36,7 @@ package config
 
 import (
 	"fmt"
+	"path"
 	"strings"
 	"time"
 
@@ -50,95 +52,98 @@ const registryRoot = "registry/services"
 
 // ConfigSourceEtcd communicates with a etcd via the client, and sends the change notification of services and endpoints to the specified channels.
 type ConfigSourceEtcd struct {
-	client           *etcd.Client
+	client           tools.EtcdClient
 	serviceChannel   chan ServiceUpdate
 	endpointsChannel chan EndpointsUpdate
-	interval         time.Duration
+	// How long to wait before listing again after a failure.
+	interval time.Duration
+	// How often to list everything again, besides watching.
+	resyncPeriod time.Duration
 }
 
 // NewConfigSourceEtcd creates a new ConfigSourceEtcd and immediately runs the created ConfigSourceEtcd in a goroutine.
-func NewConfigSourceEtcd(client *etcd.Client, serviceChannel chan ServiceUpdate, endpointsChannel chan EndpointsUpdate) ConfigSourceEtcd {
-	config := ConfigSourceEtcd{
+func NewConfigSourceEtcd(client tools.EtcdClient, serviceChannel chan ServiceUpdate, endpointsChannel chan EndpointsUpdate) *ConfigSourceEtcd {
+	config := &ConfigSourceEtcd{
 		client:           client,
 		serviceChannel:   serviceChannel,
 		endpointsChannel: endpointsChannel,
 		interval:         2 * time.Second,
+		resyncPeriod:     30 * time.Second,
 	}
 	go config.Run()
 	return config
 }
 
-// Run begins watching for new services and their endpoints on etcd.
-func (s ConfigSourceEtcd) Run() {
-	// Initially, just wait for the etcd to come up before doing anything more complicated.
-	var services []api.Service
-	var endpoints []api.Endpoints
-	var err error
-	for {
-		services, endpoints, err = s.GetServices()
-		if err == nil {
-			break
-		}
-		glog.V(1).Infof("Failed to get any services: %v", err)
-		time.Sleep(s.interval)
-	}
+// Run lists the services and their endpoints on etcd, then watches them for
+// changes until the resync period is over, forever.
+func (s *ConfigSourceEtcd) Run() {
+	util.Forever(func() {
+		stop := make(chan bool)
+		timer := time.AfterFunc(s.resyncPeriod, func() { close(stop) })
+		defer timer.Stop()
+		s.listAndWatch(stop)
+	}, s.interval)
+}
 
-	if len(services) > 0 {
-		serviceUpdate := ServiceUpdate{Op: SET, Services: services}
-		s.serviceChannel <- serviceUpdate
-	}
-	if len(endpoints) > 0 {
-		endpointsUpdate := EndpointsUpdate{Op: SET, Endpoints: endpoints}
-		s.endpointsChannel <- endpointsUpdate
+// listAndWatch sends the current services and endpoints, then the changes
+// since, until stop is closed or the watch fails.
+func (s *ConfigSourceEtcd) listAndWatch(stop chan bool) {
+	services, endpoints, index, err := s.GetServices()
+	if err != nil && !tools.IsEtcdNotFound(err) {
+		glog.Errorf("ConfigSourceEtcd: Failed to get services: %v", err)
+		return
 	}
+	s.serviceChannel <- ServiceUpdate{Op: SET, Services: services}
+	s.endpointsChannel <- EndpointsUpdate{Op: SET, Endpoints: endpoints}
 
-	// Ok, so we got something back from etcd. Let's set up a watch for new services, and
-	// their endpoints
-	go util.Forever(s.WatchForChanges, 1*time.Second)
-
-	for {
-		services, endpoints, err = s.GetServices()
-		if err != nil {
-			glog.Errorf("ConfigSourceEtcd: Failed to get services: %v", err)
-		} else {
-			if len(services) > 0 {
-				serviceUpdate := ServiceUpdate{Op: SET, Services: services}
-				s.serviceChannel <- serviceUpdate
-			}
-			if len(endpoints) > 0 {
-				endpointsUpdate := EndpointsUpdate{Op: SET, Endpoints: endpoints}
-				s.endpointsChannel <- endpointsUpdate
-			}
+	// Start right after the listed state, so that no change is missed.
+	var waitIndex uint64
+	if index > 0 {
+		waitIndex = index + 1
+	}
+	watchChannel := make(chan *etcd.Response)
+	go func() {
+		_, err := s.client.Watch("/"+registryRoot+"/", waitIndex, true, watchChannel, stop)
+		if err != nil && !tools.IsEtcdWatchStoppedByUser(err) {
+			glog.Errorf("ConfigSourceEtcd: Failed to watch services: %v", err)
 		}
-		time.Sleep(30 * time.Second)
+	}()
+	for response := range watchChannel {
+		s.ProcessChange(response)
 	}
 }
 
-// GetServices finds the list of services and their endpoints from etcd.
-// This operation is akin to a set a known good at regular intervals.
-func (s ConfigSourceEtcd) GetServices() ([]api.Service, []api.Endpoints, error) {
-	response, err := s.client.Get(registryRoot+"/specs", true, false)
+// GetServices finds the list of services and their endpoints from etcd, and
+// the etcd index they were read at. If there are no services yet, it returns
+// an etcd not found error besides the empty lists.
+func (s *ConfigSourceEtcd) GetServices() ([]api.Service, []api.Endpoints, uint64, error) {
+	response, err := s.client.Get(registryRoot+"/specs", true, true)
 	if err != nil {
 		glog.V(1).Infof("Failed to get the key %s: %v", registryRoot, err)
-		if tools.IsEtcdNotFound(err) {
-			return []api.Service{}, []api.Endpoints{}, err
-		}
+		return []api.Service{}, []api.Endpoints{}, 0, err
+	}
+	if response.Node == nil || !response.Node.Dir {
+		return nil, nil, 0, fmt.Errorf("did not get the root of the registry %s", registryRoot)
 	}
-	if response.Node.Dir == true {
-		retServices := make([]api.Service, len(response.Node.Nodes))
-		retEndpoints := make([]api.Endpoints, len(response.Node.Nodes))
-		// Ok, so we have directories, this list should be the list
-		// of services. Find the local port to listen on and remote endpoints
-		// and create a Service entry for it.
-		for i, node := range response.Node.Nodes {
+	retServices := []api.Service{}
+	retEndpoints := []api.Endpoints{}
+	// Ok, so we have directories, one per namespace, holding the services.
+	// Find the local port to listen on and remote endpoints and create a
+	// Service entry for each.
+	for _, namespace := range response.Node.Nodes {
+		if !namespace.Dir {
+			continue
+		}
+		ns := path.Base(namespace.Key)
+		for _, node := range namespace.Nodes {
 			var svc api.Service
 			err = api.DecodeInto([]byte(node.Value), &svc)
 			if err != nil {
 				glog.Errorf("Failed to load Service: %s (%#v)", node.Value, err)
 				continue
 			}
-			retServices[i] = svc
-			endpoints, err := s.GetEndpoints(svc.ID)
+			retServices = append(retServices, svc)
+			endpoints, err := s.GetEndpoints(ns, svc.ID)
 			if err != nil {
 				if tools.IsEtcdNotFound(err) {
 					glog.V(1).Infof("Unable to get endpoints for %s : %v", svc.ID, err)
@@ -146,18 +151,17 @@ func (s ConfigSourceEtcd) GetServices() ([]api.Service, []api.Endpoints, error)
 				glog.Errorf("Couldn't get endpoints for %s : %v skipping", svc.ID, err)
 				endpoints = api.Endpoints{}
 			} else {
-				glog.Infof("Got service: %s on localport %d mapping to: %s", svc.ID, svc.Port, endpoints)
+				glog.V(2).Infof("Got service: %s on localport %d mapping to: %s", svc.ID, svc.Port, endpoints)
 			}
-			retEndpoints[i] = endpoints
+			retEndpoints = append(retEndpoints, endpoints)
 		}
-		return retServices, retEndpoints, err
 	}
-	return nil, nil, fmt.Errorf("did not get the root of the registry %s", registryRoot)
+	return retServices, retEndpoints, response.EtcdIndex, nil
 }
 
-// GetEndpoints finds the list of endpoints of the service from etcd.
-func (s ConfigSourceEtcd) GetEndpoints(service string) (api.Endpoints, error) {
-	key := fmt.Sprintf(registryRoot + "/endpoints/" + service)
+// GetEndpoints finds the list of endpoints of the service in namespace from etcd.
+func (s *ConfigSourceEtcd) GetEndpoints(namespace, service string) (api.Endpoints, error) {
+	key := registryRoot + "/endpoints/" + namespace + "/" + service
 	response, err := s.client.Get(key, true, false)
 	if err != nil {
 		glog.Errorf("Failed to get the key: %s %v", key, err)
@@ -182,52 +186,49 @@ func etcdResponseToService(response *etcd.Response) (*api.Service, error) {
 	return &svc, err
 }
 
-func (s ConfigSourceEtcd) WatchForChanges() {
-	glog.Info("Setting up a watch for new services")
-	watchChannel := make(chan *etcd.Response)
-	go s.client.Watch("/registry/services/", 0, true, watchChannel, nil)
-	for {
-		watchResponse, ok := <-watchChannel
-		if !ok {
-			break
-		}
-		s.ProcessChange(watchResponse)
+// ProcessChange sends the change of a service or its endpoints a watch saw.
+func (s *ConfigSourceEtcd) ProcessChange(response *etcd.Response) {
+	if response.Node == nil || response.Node.Dir {
+		return
 	}
-}
-
-func (s ConfigSourceEtcd) ProcessChange(response *etcd.Response) {
-	glog.Infof("Processing a change in service configuration... %s", *response)
+	glog.V(2).Infof("Processing a change in service configuration... %s", *response)
 
-	// If it's a new service being added (signified by a localport being added)
-	// then process it as such
-	if strings.Contains(response.Node.Key, "/endpoints/") {
-		s.ProcessEndpointResponse(response)
-	} else if response.Action == "set" {
+	// registry/services/{specs,endpoints}/<namespace>/<service>
+	parts := strings.Split(strings.Trim(response.Node.Key, "/"), "/")
+	if len(parts) != 5 {
+		glog.Infof("Unknown service configuration key: %s", response.Node.Key)
+		return
+	}
+	kind, id := parts[2], parts[4]
+	switch response.Action {
+	case "set", "create", "update", "compareAndSwap":
+		if kind == "endpoints" {
+			s.ProcessEndpointResponse(response)
+			return
+		}
 		service, err := etcdResponseToService(response)
 		if err != nil {
 			glog.Errorf("Failed to parse %s Port: %s", response, err)
 			return
 		}
-
-		glog.Infof("New service added/updated: %#v", service)
-		serviceUpdate := ServiceUpdate{Op: ADD, Services: []api.Service{*service}}
-		s.serviceChannel <- serviceUpdate
-		return
-	}
-	if response.Action == "delete" {
-		parts := strings.Split(response.Node.Key[1:], "/")
-		if len(parts) == 4 {
-			glog.Infof("Deleting service: %s", parts[3])
-			serviceUpdate := ServiceUpdate{Op: REMOVE, Services: []api.Service{{JSONBase: api.JSONBase{ID: parts[3]}}}}
-			s.serviceChannel <- serviceUpdate
+		glog.Infof("New service added/updated: %s", service.ID)
+		s.serviceChannel <- ServiceUpdate{Op: ADD, Services: []api.Service{*service}}
+	case "delete", "expire", "compareAndDelete":
+		if kind == "endpoints" {
+			glog.Infof("Deleting endpoints: %s", id)
+			s.endpointsChannel <- EndpointsUpdate{Op: REMOVE, Endpoints: []api.Endpoints{{JSONBase: api.JSONBase{ID: id}}}}
 			return
 		}
-		glog.Infof("Unknown service delete: %#v", parts)
+		glog.Infof("Deleting service: %s", id)
+		s.serviceChannel <- ServiceUpdate{Op: REMOVE, Services: []api.Service{{JSONBase: api.JSONBase{ID: id}}}}
+	default:
+		glog.Infof("Unknown service configuration change: %s %s", response.Action, response.Node.Key)
 	}
 }
 
-func (s ConfigSourceEtcd) ProcessEndpointResponse(response *etcd.Response) {
-	glog.Infof("Processing a change in endpoint configuration... %s", *response)
+// ProcessEndpointResponse sends the endpoints a watch saw set.
+func (s *ConfigSourceEtcd) ProcessEndpointResponse(response *etcd.Response) {
+	glog.V(2).Infof("Processing a change in endpoint configuration... %s", *response)
 	var endpoints api.Endpoints
 	err := api.DecodeInto([]byte(response.Node.Value), &endpoints)
 	if err != nil {
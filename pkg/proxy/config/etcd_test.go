@@ -0,0 +1,167 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/coreos/go-etcd/etcd"
+)
+
+func newTestConfigSourceEtcd(client tools.EtcdClient) *ConfigSourceEtcd {
+	return &ConfigSourceEtcd{
+		client:           client,
+		serviceChannel:   make(chan ServiceUpdate, 10),
+		endpointsChannel: make(chan EndpointsUpdate, 10),
+		interval:         time.Millisecond,
+		resyncPeriod:     time.Hour,
+	}
+}
+
+func TestProcessChange(t *testing.T) {
+	source := newTestConfigSourceEtcd(nil)
+	service, _ := api.Encode(&api.Service{JSONBase: api.JSONBase{ID: "foo"}, Port: 80})
+	endpoints, _ := api.Encode(&api.Endpoints{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"1.2.3.4:80"}})
+
+	source.ProcessChange(&etcd.Response{
+		Action: "create",
+		Node:   &etcd.Node{Key: "/registry/services/specs/default/foo", Value: string(service)},
+	})
+	if update := <-source.serviceChannel; update.Op != ADD || len(update.Services) != 1 || update.Services[0].Port != 80 {
+		t.Errorf("Unexpected service update: %#v", update)
+	}
+
+	source.ProcessChange(&etcd.Response{
+		Action: "compareAndSwap",
+		Node:   &etcd.Node{Key: "/registry/services/endpoints/default/foo", Value: string(endpoints)},
+	})
+	if update := <-source.endpointsChannel; update.Op != ADD || !reflect.DeepEqual(update.Endpoints[0].Endpoints, []string{"1.2.3.4:80"}) {
+		t.Errorf("Unexpected endpoints update: %#v", update)
+	}
+
+	source.ProcessChange(&etcd.Response{
+		Action: "delete",
+		Node:   &etcd.Node{Key: "/registry/services/endpoints/default/foo"},
+	})
+	if update := <-source.endpointsChannel; update.Op != REMOVE || update.Endpoints[0].ID != "foo" {
+		t.Errorf("Unexpected endpoints update: %#v", update)
+	}
+
+	source.ProcessChange(&etcd.Response{
+		Action: "delete",
+		Node:   &etcd.Node{Key: "/registry/services/specs/default/foo"},
+	})
+	if update := <-source.serviceChannel; update.Op != REMOVE || update.Services[0].ID != "foo" {
+		t.Errorf("Unexpected service update: %#v", update)
+	}
+
+	// Namespace directories are not services.
+	source.ProcessChange(&etcd.Response{
+		Action: "set",
+		Node:   &etcd.Node{Key: "/registry/services/specs/default", Dir: true},
+	})
+	select {
+	case update := <-source.serviceChannel:
+		t.Errorf("Unexpected service update: %#v", update)
+	default:
+	}
+}
+
+func TestListAndWatch(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	service, _ := api.Encode(&api.Service{JSONBase: api.JSONBase{ID: "foo"}, Port: 80})
+	endpoints, _ := api.Encode(&api.Endpoints{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"1.2.3.4:80"}})
+	fakeClient.Data["registry/services/specs"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			EtcdIndex: 7,
+			Node: &etcd.Node{
+				Dir: true,
+				Nodes: []*etcd.Node{
+					{
+						Key: "/registry/services/specs/default",
+						Dir: true,
+						Nodes: []*etcd.Node{
+							{Key: "/registry/services/specs/default/foo", Value: string(service)},
+						},
+					},
+				},
+			},
+		},
+	}
+	fakeClient.Data["registry/services/endpoints/default/foo"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{Node: &etcd.Node{Value: string(endpoints)}},
+	}
+	source := newTestConfigSourceEtcd(fakeClient)
+	stop := make(chan bool)
+	done := make(chan struct{})
+	go func() {
+		source.listAndWatch(stop)
+		close(done)
+	}()
+
+	if update := <-source.serviceChannel; update.Op != SET || len(update.Services) != 1 || update.Services[0].ID != "foo" {
+		t.Errorf("Unexpected service update: %#v", update)
+	}
+	if update := <-source.endpointsChannel; update.Op != SET || len(update.Endpoints) != 1 || update.Endpoints[0].ID != "foo" {
+		t.Errorf("Unexpected endpoints update: %#v", update)
+	}
+
+	fakeClient.WaitForWatchCompletion()
+	if fakeClient.WatchIndex != 8 {
+		t.Errorf("Expected to watch from index 8, got %d", fakeClient.WatchIndex)
+	}
+	fakeClient.WatchResponse <- &etcd.Response{
+		Action: "delete",
+		Node:   &etcd.Node{Key: "/registry/services/specs/default/foo"},
+	}
+	if update := <-source.serviceChannel; update.Op != REMOVE || update.Services[0].ID != "foo" {
+		t.Errorf("Unexpected service update: %#v", update)
+	}
+
+	close(stop)
+	<-done
+}
+
+func TestListAndWatchNoServices(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.ExpectNotFoundGet("registry/services/specs")
+	source := newTestConfigSourceEtcd(fakeClient)
+	stop := make(chan bool)
+	done := make(chan struct{})
+	go func() {
+		source.listAndWatch(stop)
+		close(done)
+	}()
+
+	// Services which were all deleted must be forgotten too.
+	if update := <-source.serviceChannel; update.Op != SET || len(update.Services) != 0 {
+		t.Errorf("Unexpected service update: %#v", update)
+	}
+	if update := <-source.endpointsChannel; update.Op != SET || len(update.Endpoints) != 0 {
+		t.Errorf("Unexpected endpoints update: %#v", update)
+	}
+	fakeClient.WaitForWatchCompletion()
+	if fakeClient.WatchIndex != 0 {
+		t.Errorf("Expected to watch from the current index, got %d", fakeClient.WatchIndex)
+	}
+	close(stop)
+	<-done
+}
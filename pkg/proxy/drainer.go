@@ -0,0 +1,119 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/golang/glog"
+)
+
+// Drainer closes the connections the proxier made to endpoints which went
+// away, once they had the drain period to finish. New connections stop going
+// to such endpoints right away, since the LoadBalancer no longer returns them.
+type Drainer struct {
+	period time.Duration
+	mu     sync.Mutex
+	// The open connections of every endpoint of any service.
+	conns map[string]map[io.Closer]bool
+	// The pending closes of the connections of the endpoints which went away.
+	draining map[string]*time.Timer
+}
+
+// NewDrainer returns a Drainer which gives the connections to endpoints which
+// went away period to finish.
+func NewDrainer(period time.Duration) *Drainer {
+	return &Drainer{
+		period:   period,
+		conns:    make(map[string]map[io.Closer]bool),
+		draining: make(map[string]*time.Timer),
+	}
+}
+
+// Track counts conn among the open connections of endpoint, until Untrack.
+func (d *Drainer) Track(endpoint string, conn io.Closer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	conns, ok := d.conns[endpoint]
+	if !ok {
+		conns = make(map[io.Closer]bool)
+		d.conns[endpoint] = conns
+	}
+	conns[conn] = true
+}
+
+// Untrack forgets conn, which was closed.
+func (d *Drainer) Untrack(endpoint string, conn io.Closer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	conns := d.conns[endpoint]
+	delete(conns, conn)
+	if len(conns) == 0 {
+		delete(d.conns, endpoint)
+	}
+}
+
+// OnUpdate starts draining the endpoints which are no longer the endpoint of
+// any service, and stops draining the ones which came back.
+func (d *Drainer) OnUpdate(endpoints []api.Endpoints) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	current := util.StringSet{}
+	for _, e := range endpoints {
+		current.Insert(e.Endpoints...)
+	}
+	for endpoint, timer := range d.draining {
+		if current.Has(endpoint) {
+			glog.Infof("Drainer: Endpoint %s is back, keeping its connections", endpoint)
+			timer.Stop()
+			delete(d.draining, endpoint)
+		}
+	}
+	for endpoint := range d.conns {
+		if _, draining := d.draining[endpoint]; draining || current.Has(endpoint) {
+			continue
+		}
+		glog.Infof("Drainer: Closing the connections to %s in %v", endpoint, d.period)
+		endpoint := endpoint
+		d.draining[endpoint] = time.AfterFunc(d.period, func() { d.closeEndpoint(endpoint) })
+	}
+}
+
+// closeEndpoint closes the connections of endpoint, unless it came back.
+func (d *Drainer) closeEndpoint(endpoint string) {
+	d.mu.Lock()
+	if _, draining := d.draining[endpoint]; !draining {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.draining, endpoint)
+	var conns []io.Closer
+	for conn := range d.conns[endpoint] {
+		conns = append(conns, conn)
+	}
+	d.mu.Unlock()
+	if len(conns) > 0 {
+		glog.Infof("Drainer: Closing %d connections to %s", len(conns), endpoint)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+type fakeConn struct {
+	closed chan struct{}
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{closed: make(chan struct{})}
+}
+
+func (c *fakeConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func (c *fakeConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func waitForClose(t *testing.T, c *fakeConn) {
+	select {
+	case <-c.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the connection to be closed")
+	}
+}
+
+func TestDrainerClosesConnectionsOfRemovedEndpoints(t *testing.T) {
+	d := NewDrainer(10 * time.Millisecond)
+	kept, removed := newFakeConn(), newFakeConn()
+	d.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"endpoint1:1", "endpoint2:2"}}})
+	d.Track("endpoint1:1", kept)
+	d.Track("endpoint2:2", removed)
+
+	d.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"endpoint1:1"}}})
+	if removed.isClosed() {
+		t.Errorf("expected the connection to get the drain period to finish")
+	}
+	waitForClose(t, removed)
+	if kept.isClosed() {
+		t.Errorf("expected the connection to a current endpoint to stay open")
+	}
+}
+
+func TestDrainerKeepsConnectionsOfReturningEndpoints(t *testing.T) {
+	d := NewDrainer(50 * time.Millisecond)
+	conn := newFakeConn()
+	d.Track("endpoint1:1", conn)
+	d.OnUpdate([]api.Endpoints{})
+	d.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"endpoint1:1"}}})
+	time.Sleep(100 * time.Millisecond)
+	if conn.isClosed() {
+		t.Errorf("expected the connection to an endpoint which came back to stay open")
+	}
+}
+
+func TestDrainerForgetsClosedConnections(t *testing.T) {
+	d := NewDrainer(0)
+	conn := newFakeConn()
+	d.Track("endpoint1:1", conn)
+	d.Untrack("endpoint1:1", conn)
+	d.OnUpdate([]api.Endpoints{})
+	time.Sleep(10 * time.Millisecond)
+	if conn.isClosed() {
+		t.Errorf("expected a closed connection not to be closed again")
+	}
+	if len(d.conns) != 0 || len(d.draining) != 0 {
+		t.Errorf("expected nothing to drain, got %v, %v", d.conns, d.draining)
+	}
+}
+
+func TestProxyDrainsRemovedEndpoints(t *testing.T) {
+	endpoints := []api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", port)}}}
+	lb := NewBalancer()
+	lb.OnUpdate(endpoints)
+	d := NewDrainer(10 * time.Millisecond)
+	d.OnUpdate(endpoints)
+
+	p := NewProxier(lb, nil, nil, d, nil)
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "TCP", time.Second)
+	if err != nil {
+		t.Fatalf("error adding new service: %#v", err)
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", proxyPort))
+	if err != nil {
+		t.Fatalf("error connecting to proxy: %v", err)
+	}
+	defer conn.Close()
+	// Make sure the proxier dialed the endpoint before it goes away.
+	if _, err := conn.Write([]byte("GET /aaaaa HTTP/1.1\r\nHost: echo\r\n\r\n")); err != nil {
+		t.Fatalf("error writing request: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1024)); err != nil {
+		t.Fatalf("error reading response: %v", err)
+	}
+
+	lb.OnUpdate(nil)
+	d.OnUpdate(nil)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buffer := make([]byte, 1024)
+	for {
+		_, err := conn.Read(buffer)
+		if err == nil {
+			continue
+		}
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			t.Fatalf("expected the connection to be closed after draining")
+		}
+		break
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/golang/glog"
+)
+
+// HealthChecker probes the endpoints of every TCP service with a TCP connect,
+// so that the Balancer stops sending connections to an endpoint which fails,
+// until it passes a probe again. It covers the time the endpoints controller
+// takes to notice a dead pod. The endpoints of other services aren't probed,
+// a UDP endpoint doesn't answer a TCP connect.
+type HealthChecker struct {
+	timeout time.Duration
+	mu      sync.Mutex
+	// The endpoints of every service, by service ID.
+	serviceEndpoints map[string][]string
+	// The IDs of the services which aren't TCP.
+	notTCP util.StringSet
+	// The endpoints to probe.
+	endpoints util.StringSet
+	// The endpoints which failed their last probe.
+	unhealthy util.StringSet
+	// Defaults to tcpProbe, overridden in tests.
+	probe func(endpoint string, timeout time.Duration) error
+}
+
+// NewHealthChecker returns a HealthChecker which gives every probe timeout
+// to connect.
+func NewHealthChecker(timeout time.Duration) *HealthChecker {
+	return &HealthChecker{
+		timeout:          timeout,
+		serviceEndpoints: map[string][]string{},
+		notTCP:           util.StringSet{},
+		endpoints:        util.StringSet{},
+		unhealthy:        util.StringSet{},
+		probe:            tcpProbe,
+	}
+}
+
+// tcpProbe checks endpoint accepts connections.
+func tcpProbe(endpoint string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", endpoint, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Run probes the endpoints every period.
+func (h *HealthChecker) Run(period time.Duration) {
+	go util.Forever(h.CheckEndpoints, period)
+}
+
+// Healthy returns false if endpoint failed its last probe.
+func (h *HealthChecker) Healthy(endpoint string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.unhealthy.Has(endpoint)
+}
+
+// OnUpdate sets the endpoints of every service. The endpoints which went away
+// are forgotten, and new ones are healthy until they fail a probe.
+func (h *HealthChecker) OnUpdate(endpoints []api.Endpoints) {
+	serviceEndpoints := map[string][]string{}
+	for _, e := range endpoints {
+		serviceEndpoints[e.ID] = filterValidEndpoints(e.Endpoints)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.serviceEndpoints = serviceEndpoints
+	h.updateEndpoints()
+}
+
+// ServiceHandler returns the handler of service updates which tells h the
+// protocol of every service.
+func (h *HealthChecker) ServiceHandler() *HealthCheckerServices {
+	return &HealthCheckerServices{h}
+}
+
+// HealthCheckerServices passes service updates to a HealthChecker.
+type HealthCheckerServices struct {
+	checker *HealthChecker
+}
+
+// OnUpdate stops probing the endpoints of the services which aren't TCP.
+func (s *HealthCheckerServices) OnUpdate(services []api.Service) {
+	notTCP := util.StringSet{}
+	for _, service := range services {
+		if protocol := strings.ToUpper(service.Protocol); protocol != "" && protocol != "TCP" {
+			notTCP.Insert(service.ID)
+		}
+	}
+	h := s.checker
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.notTCP = notTCP
+	h.updateEndpoints()
+}
+
+// updateEndpoints sets the endpoints to probe to those of the TCP services,
+// and forgets the health of the others. h.mu must be held.
+func (h *HealthChecker) updateEndpoints() {
+	current := util.StringSet{}
+	for service, endpoints := range h.serviceEndpoints {
+		if !h.notTCP.Has(service) {
+			current.Insert(endpoints...)
+		}
+	}
+	h.endpoints = current
+	for endpoint := range h.unhealthy {
+		if !current.Has(endpoint) {
+			h.unhealthy.Delete(endpoint)
+		}
+	}
+}
+
+// CheckEndpoints probes every endpoint at once, and waits for the results.
+func (h *HealthChecker) CheckEndpoints() {
+	h.mu.Lock()
+	endpoints := h.endpoints.List()
+	h.mu.Unlock()
+	wg := sync.WaitGroup{}
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		go func(endpoint string) {
+			defer wg.Done()
+			h.setHealth(endpoint, h.probe(endpoint, h.timeout))
+		}(endpoint)
+	}
+	wg.Wait()
+}
+
+// setHealth records the result of the probe of endpoint, unless it went away
+// in the meantime.
+func (h *HealthChecker) setHealth(endpoint string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.endpoints.Has(endpoint) {
+		return
+	}
+	switch {
+	case err != nil && !h.unhealthy.Has(endpoint):
+		glog.Infof("HealthChecker: Endpoint %s failed its probe, ejecting it: %v", endpoint, err)
+		h.unhealthy.Insert(endpoint)
+	case err == nil && h.unhealthy.Has(endpoint):
+		glog.Infof("HealthChecker: Endpoint %s passed its probe, bringing it back", endpoint)
+		h.unhealthy.Delete(endpoint)
+	}
+}
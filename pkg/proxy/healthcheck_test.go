@@ -0,0 +1,178 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+// fakeProber fails the probes of the endpoints in down.
+type fakeProber struct {
+	lock   sync.Mutex
+	down   map[string]bool
+	probed []string
+}
+
+func (p *fakeProber) probe(endpoint string, timeout time.Duration) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.probed = append(p.probed, endpoint)
+	if p.down[endpoint] {
+		return fmt.Errorf("connection refused")
+	}
+	return nil
+}
+
+func newFakeHealthChecker(prober *fakeProber) *HealthChecker {
+	checker := NewHealthChecker(time.Second)
+	checker.probe = prober.probe
+	return checker
+}
+
+func TestHealthCheckerEjectsAndRecovers(t *testing.T) {
+	prober := &fakeProber{down: map[string]bool{"endpoint2:2": true}}
+	checker := newFakeHealthChecker(prober)
+	checker.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"endpoint1:1", "endpoint2:2"}}})
+	if !checker.Healthy("endpoint2:2") {
+		t.Errorf("expected endpoint2:2 to be healthy before its first probe")
+	}
+	checker.CheckEndpoints()
+	if len(prober.probed) != 2 {
+		t.Errorf("expected both endpoints to be probed, got %v", prober.probed)
+	}
+	if !checker.Healthy("endpoint1:1") || checker.Healthy("endpoint2:2") {
+		t.Errorf("expected only endpoint2:2 to be ejected")
+	}
+	prober.down = nil
+	checker.CheckEndpoints()
+	if !checker.Healthy("endpoint2:2") {
+		t.Errorf("expected endpoint2:2 to be back after passing a probe")
+	}
+}
+
+func TestHealthCheckerForgetsRemovedEndpoints(t *testing.T) {
+	prober := &fakeProber{down: map[string]bool{"endpoint1:1": true}}
+	checker := newFakeHealthChecker(prober)
+	checker.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"endpoint1:1"}}})
+	checker.CheckEndpoints()
+	if checker.Healthy("endpoint1:1") {
+		t.Errorf("expected endpoint1:1 to be ejected")
+	}
+	checker.OnUpdate([]api.Endpoints{})
+	if !checker.Healthy("endpoint1:1") {
+		t.Errorf("expected endpoint1:1 to be forgotten")
+	}
+	prober.probed = nil
+	checker.CheckEndpoints()
+	if len(prober.probed) != 0 {
+		t.Errorf("expected no probes, got %v", prober.probed)
+	}
+}
+
+func TestHealthCheckerSkipsUDPServices(t *testing.T) {
+	prober := &fakeProber{down: map[string]bool{"endpoint2:2": true}}
+	checker := newFakeHealthChecker(prober)
+	checker.OnUpdate([]api.Endpoints{
+		{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"endpoint1:1"}},
+		{JSONBase: api.JSONBase{ID: "bar"}, Endpoints: []string{"endpoint2:2"}},
+	})
+	checker.CheckEndpoints()
+	if checker.Healthy("endpoint2:2") {
+		t.Errorf("expected endpoint2:2 to be ejected")
+	}
+	checker.ServiceHandler().OnUpdate([]api.Service{
+		{JSONBase: api.JSONBase{ID: "foo"}},
+		{JSONBase: api.JSONBase{ID: "bar"}, Protocol: "udp"},
+	})
+	if !checker.Healthy("endpoint2:2") {
+		t.Errorf("expected the health of endpoint2:2 to be forgotten")
+	}
+	prober.probed = nil
+	checker.CheckEndpoints()
+	if len(prober.probed) != 1 || prober.probed[0] != "endpoint1:1" {
+		t.Errorf("expected only endpoint1:1 to be probed, got %v", prober.probed)
+	}
+	if !checker.Healthy("endpoint2:2") {
+		t.Errorf("expected endpoint2:2 to stay healthy")
+	}
+}
+
+func TestLoadBalanceSkipsUnhealthyEndpoints(t *testing.T) {
+	prober := &fakeProber{down: map[string]bool{"endpoint:2": true}}
+	checker := newFakeHealthChecker(prober)
+	loadBalancer := NewBalancer()
+	loadBalancer.SetHealthChecker(checker)
+	endpoints := []api.Endpoints{{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"endpoint:1", "endpoint:2", "endpoint:3"}}}
+	loadBalancer.OnUpdate(endpoints)
+	checker.OnUpdate(endpoints)
+	checker.CheckEndpoints()
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:1")
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:3")
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:1")
+
+	// With every endpoint down, the balancer falls back to all of them.
+	prober.down = map[string]bool{"endpoint:1": true, "endpoint:2": true, "endpoint:3": true}
+	checker.CheckEndpoints()
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:2")
+	expectEndpoint(t, loadBalancer, "foo", "endpoint:3")
+}
+
+func TestLoadBalanceMovesAffinityOffUnhealthyEndpoints(t *testing.T) {
+	prober := &fakeProber{}
+	checker := newFakeHealthChecker(prober)
+	loadBalancer := NewBalancer()
+	loadBalancer.SetHealthChecker(checker)
+	loadBalancer.SetSessionAffinity("foo", api.AffinityTypeClientIP, time.Hour)
+	endpoints := []api.Endpoints{{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"endpoint:1", "endpoint:2"}}}
+	loadBalancer.OnUpdate(endpoints)
+	checker.OnUpdate(endpoints)
+	client := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}
+	first, err := loadBalancer.NextEndpoint("foo", client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	prober.down = map[string]bool{first: true}
+	checker.CheckEndpoints()
+	second, err := loadBalancer.NextEndpoint("foo", client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second == first {
+		t.Errorf("expected the client to move off unhealthy %s", first)
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	address := listener.Addr().String()
+	if err := tcpProbe(address, time.Second); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	listener.Close()
+	if err := tcpProbe(address, time.Second); err == nil {
+		t.Errorf("expected the probe of a closed port to fail")
+	}
+}
@@ -18,11 +18,29 @@ package proxy
 
 import (
 	"net"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 )
 
 // A LoadBalancer distributes incoming requests to service endpoints.
 type LoadBalancer interface {
 	// NextEndpoint returns the endpoint to handle a request for the given
 	// service and source address.
+	// Every endpoint it returns has to be released with ReleaseEndpoint once
+	// the connection to it is closed.
 	NextEndpoint(service string, srcAddr net.Addr) (string, error)
+	// ReleaseEndpoint tells the load balancer a connection to endpoint which
+	// NextEndpoint returned is closed.
+	ReleaseEndpoint(service, endpoint string)
+	// SetBalancingPolicy sets how the connections to the given service are
+	// spread over its endpoints.
+	SetBalancingPolicy(service string, policy api.BalancingPolicyType)
+	// SetSessionAffinity sets the session affinity of the given service.
+	// With AffinityTypeClientIP, a client IP keeps its endpoint until it made
+	// no request for ttl, or the endpoint goes away.
+	SetSessionAffinity(service string, affinityType api.AffinityType, ttl time.Duration)
+	// ForgetService drops the balancing policy and session affinity of the
+	// given service, once it is removed.
+	ForgetService(service string)
 }
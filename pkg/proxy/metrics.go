@@ -0,0 +1,145 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
+)
+
+// serviceMetrics counts the traffic a proxier proxied for a service.
+type serviceMetrics struct {
+	// The attempts to connect to an endpoint, and how many of them failed.
+	dials      *metrics.Counter
+	dialErrors *metrics.Counter
+	// The bytes sent from clients to endpoints, and back.
+	bytesIn  *metrics.Counter
+	bytesOut *metrics.Counter
+	// active is the number of connections, or UDP clients, proxied to an
+	// endpoint right now. It is updated atomically.
+	active int64
+
+	owner   *proxyMetrics
+	service string
+	// forgotten is set once the service is no longer proxied, so that the
+	// metrics are unregistered when its last connection closes. It is guarded
+	// by owner.mu.
+	forgotten bool
+}
+
+// serviceMetricNames are the names, after "proxy.<service>.", under which the
+// metrics of a service are registered.
+var serviceMetricNames = []string{"dials", "dialErrors", "bytesIn", "bytesOut", "activeConnections"}
+
+// proxyMetrics keeps the metrics of every service of a proxier in a
+// metrics.Registry, as the counters "proxy.<service>.dials", ".dialErrors",
+// ".bytesIn" and ".bytesOut", and the gauge ".activeConnections".
+type proxyMetrics struct {
+	registry *metrics.Registry
+	mu       sync.Mutex
+	services map[string]*serviceMetrics
+}
+
+func newProxyMetrics(registry *metrics.Registry) *proxyMetrics {
+	return &proxyMetrics{registry: registry, services: make(map[string]*serviceMetrics)}
+}
+
+// forService returns the metrics of service, registering them if needed. A
+// service proxied again before the last connection of its forgotten metrics
+// closed carries on with them.
+func (m *proxyMetrics) forService(service string) *serviceMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counters, ok := m.services[service]
+	if ok {
+		counters.forgotten = false
+	} else {
+		prefix := "proxy." + service + "."
+		counters = &serviceMetrics{
+			dials:      m.registry.Counter(prefix + "dials"),
+			dialErrors: m.registry.Counter(prefix + "dialErrors"),
+			bytesIn:    m.registry.Counter(prefix + "bytesIn"),
+			bytesOut:   m.registry.Counter(prefix + "bytesOut"),
+			owner:      m,
+			service:    service,
+		}
+		m.registry.Gauge(prefix+"activeConnections", func() int64 {
+			return atomic.LoadInt64(&counters.active)
+		})
+		m.services[service] = counters
+	}
+	return counters
+}
+
+// forget unregisters the metrics of service, which is no longer proxied, once
+// none of its connections are open any more.
+func (m *proxyMetrics) forget(service string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counters, ok := m.services[service]
+	if !ok {
+		return
+	}
+	counters.forgotten = true
+	m.unregisterIdle(counters)
+}
+
+// unregisterIdle unregisters counters if they are forgotten and have no open
+// connections. Must be called with m.mu held.
+func (m *proxyMetrics) unregisterIdle(counters *serviceMetrics) {
+	if !counters.forgotten || atomic.LoadInt64(&counters.active) != 0 || m.services[counters.service] != counters {
+		return
+	}
+	delete(m.services, counters.service)
+	for _, name := range serviceMetricNames {
+		m.registry.Delete("proxy." + counters.service + "." + name)
+	}
+}
+
+func (counters *serviceMetrics) dialed(err error) {
+	counters.dials.Inc()
+	if err != nil {
+		counters.dialErrors.Inc()
+	}
+}
+
+func (counters *serviceMetrics) opened() {
+	atomic.AddInt64(&counters.active, 1)
+}
+
+func (counters *serviceMetrics) closed() {
+	if atomic.AddInt64(&counters.active, -1) == 0 {
+		counters.owner.mu.Lock()
+		defer counters.owner.mu.Unlock()
+		counters.owner.unregisterIdle(counters)
+	}
+}
+
+// countingReader adds the number of bytes read through it to count.
+type countingReader struct {
+	io.Reader
+	count *metrics.Counter
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.count.Add(uint64(n))
+	return n, err
+}
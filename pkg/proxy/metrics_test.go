@@ -0,0 +1,185 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
+)
+
+func TestProxyMetrics(t *testing.T) {
+	r := metrics.NewRegistry()
+	m := newProxyMetrics(r)
+	counters := m.forService("foo")
+	counters.dialed(nil)
+	counters.dialed(errors.New("refused"))
+	counters.opened()
+	counters.opened()
+	counters.closed()
+	m.forService("bar").bytesIn.Add(5)
+
+	snapshot := r.Snapshot()
+	expected := map[string]uint64{
+		"proxy.foo.dials": 2, "proxy.foo.dialErrors": 1, "proxy.foo.bytesIn": 0, "proxy.foo.bytesOut": 0,
+		"proxy.bar.dials": 0, "proxy.bar.dialErrors": 0, "proxy.bar.bytesIn": 5, "proxy.bar.bytesOut": 0,
+	}
+	if !reflect.DeepEqual(snapshot.Counters, expected) {
+		t.Errorf("expected %#v, got %#v", expected, snapshot.Counters)
+	}
+	if expected := map[string]int64{"proxy.foo.activeConnections": 1, "proxy.bar.activeConnections": 0}; !reflect.DeepEqual(snapshot.Gauges, expected) {
+		t.Errorf("expected %#v, got %#v", expected, snapshot.Gauges)
+	}
+
+	m.forget("bar")
+	snapshot = r.Snapshot()
+	if len(snapshot.Counters) != 4 || len(snapshot.Gauges) != 1 {
+		t.Errorf("expected the metrics of bar to be gone, got %#v", snapshot)
+	}
+}
+
+func TestProxyMetricsForgetWaitsForConnections(t *testing.T) {
+	r := metrics.NewRegistry()
+	m := newProxyMetrics(r)
+	counters := m.forService("foo")
+	counters.opened()
+	counters.opened()
+
+	// The open connections keep the metrics, and a service proxied again
+	// carries on with them.
+	m.forget("foo")
+	if again := m.forService("foo"); again != counters {
+		t.Errorf("expected the metrics of foo to be kept while connections are open")
+	}
+	m.forget("foo")
+	counters.closed()
+	if gauges := r.Snapshot().Gauges; gauges["proxy.foo.activeConnections"] != 1 {
+		t.Errorf("expected one connection to be counted, got %#v", gauges)
+	}
+	counters.closed()
+	if snapshot := r.Snapshot(); len(snapshot.Counters) != 0 || len(snapshot.Gauges) != 0 {
+		t.Errorf("expected the metrics of foo to be gone, got %#v", snapshot)
+	}
+}
+
+// serviceSnapshot is what the registry of a proxier holds about a service.
+type serviceSnapshot struct {
+	dials, dialErrors, bytesIn, bytesOut uint64
+	activeConnections                    int64
+}
+
+// waitForMetrics waits until check accepts the metrics of service.
+func waitForMetrics(t *testing.T, r *metrics.Registry, service string, check func(serviceSnapshot) bool) {
+	var s serviceSnapshot
+	for i := 0; i < 500; i++ {
+		snapshot := r.Snapshot()
+		prefix := "proxy." + service + "."
+		s = serviceSnapshot{
+			dials:             snapshot.Counters[prefix+"dials"],
+			dialErrors:        snapshot.Counters[prefix+"dialErrors"],
+			bytesIn:           snapshot.Counters[prefix+"bytesIn"],
+			bytesOut:          snapshot.Counters[prefix+"bytesOut"],
+			activeConnections: snapshot.Gauges[prefix+"activeConnections"],
+		}
+		if check(s) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Errorf("unexpected metrics of %s: %#v", service, s)
+}
+
+// newMeteredProxier returns a Proxier recording its metrics into a registry of
+// its own, so tests don't count each other's traffic.
+func newMeteredProxier(lb LoadBalancer) (*Proxier, *metrics.Registry) {
+	r := metrics.NewRegistry()
+	p := NewProxier(lb, nil, nil, nil, nil)
+	p.metrics = newProxyMetrics(r)
+	return p, r
+}
+
+func TestProxyMetricsTCP(t *testing.T) {
+	lb := NewBalancer()
+	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", port)}}})
+
+	p, r := newMeteredProxier(lb)
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "TCP", time.Second)
+	if err != nil {
+		t.Fatalf("error adding new service: %#v", err)
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", proxyPort))
+	if err != nil {
+		t.Fatalf("error connecting to proxy: %v", err)
+	}
+	request := "GET /aaaaa HTTP/1.0\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("error writing request: %v", err)
+	}
+	response, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("error reading response: %v", err)
+	}
+	conn.Close()
+	waitForMetrics(t, r, "echo", func(s serviceSnapshot) bool {
+		return s.dials == 1 && s.dialErrors == 0 && s.activeConnections == 0 &&
+			s.bytesIn == uint64(len(request)) && s.bytesOut == uint64(len(response))
+	})
+}
+
+func TestProxyMetricsDialErrors(t *testing.T) {
+	// Nothing listens on the endpoint once the listener is closed.
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	endpoint := l.Addr().String()
+	l.Close()
+	lb := NewBalancer()
+	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{endpoint}}})
+
+	p, r := newMeteredProxier(lb)
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "TCP", time.Second)
+	if err != nil {
+		t.Fatalf("error adding new service: %#v", err)
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", proxyPort))
+	if err != nil {
+		t.Fatalf("error connecting to proxy: %v", err)
+	}
+	conn.Close()
+	waitForMetrics(t, r, "echo", func(s serviceSnapshot) bool {
+		return s.dials == 1 && s.dialErrors == 1 && s.activeConnections == 0
+	})
+}
+
+func TestProxyMetricsUDP(t *testing.T) {
+	lb := NewBalancer()
+	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", udpPort)}}})
+
+	p, r := newMeteredProxier(lb)
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "UDP", time.Second)
+	if err != nil {
+		t.Fatalf("error adding new service: %#v", err)
+	}
+	testEchoUDP(t, "127.0.0.1", proxyPort)
+	waitForMetrics(t, r, "echo", func(s serviceSnapshot) bool {
+		return s.dials == 1 && s.activeConnections == 1 && s.bytesIn == 5 && s.bytesOut == 5
+	})
+}
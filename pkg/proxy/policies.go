@@ -0,0 +1,86 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+// endpointPicker picks the endpoint of a new connection to a service. Every
+// service has its own endpointPicker, which is replaced when the endpoints of
+// the service change.
+type endpointPicker interface {
+	// Pick returns one of endpoints, which is never empty. connections holds
+	// the number of open connections of every endpoint.
+	Pick(endpoints []string, connections map[string]int) string
+}
+
+// newEndpointPicker returns the endpointPicker implementing policy, with
+// round-robin for unknown policies.
+func newEndpointPicker(policy api.BalancingPolicyType) endpointPicker {
+	switch policy {
+	case api.BalancingPolicyLeastConnections:
+		return &leastConnectionsPicker{}
+	case api.BalancingPolicyRandom:
+		return &randomPicker{rand.New(rand.NewSource(time.Now().UnixNano()))}
+	}
+	return &roundRobinPicker{}
+}
+
+// roundRobinPicker picks the endpoints in turn.
+type roundRobinPicker struct {
+	index int
+}
+
+func (p *roundRobinPicker) Pick(endpoints []string, connections map[string]int) string {
+	if p.index >= len(endpoints) {
+		p.index = 0
+	}
+	endpoint := endpoints[p.index]
+	p.index = (p.index + 1) % len(endpoints)
+	return endpoint
+}
+
+// leastConnectionsPicker picks the endpoint with the fewest open connections.
+// Ties go to the endpoints in turn, so that short connections are still spread.
+type leastConnectionsPicker struct {
+	index int
+}
+
+func (p *leastConnectionsPicker) Pick(endpoints []string, connections map[string]int) string {
+	best := -1
+	for i := range endpoints {
+		candidate := (p.index + i) % len(endpoints)
+		if best == -1 || connections[endpoints[candidate]] < connections[endpoints[best]] {
+			best = candidate
+		}
+	}
+	p.index = (best + 1) % len(endpoints)
+	return endpoints[best]
+}
+
+// randomPicker picks an endpoint at random.
+type randomPicker struct {
+	rand *rand.Rand
+}
+
+func (p *randomPicker) Pick(endpoints []string, connections map[string]int) string {
+	return endpoints[p.rand.Intn(len(endpoints))]
+}
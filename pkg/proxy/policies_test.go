@@ -0,0 +1,66 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+func TestRoundRobinPicker(t *testing.T) {
+	picker := newEndpointPicker(api.BalancingPolicyRoundRobin)
+	endpoints := []string{"endpoint:1", "endpoint:2", "endpoint:3"}
+	for _, expected := range []string{"endpoint:1", "endpoint:2", "endpoint:3", "endpoint:1"} {
+		if endpoint := picker.Pick(endpoints, map[string]int{"endpoint:2": 5}); endpoint != expected {
+			t.Errorf("expected %s, got %s", expected, endpoint)
+		}
+	}
+}
+
+func TestLeastConnectionsPicker(t *testing.T) {
+	picker := newEndpointPicker(api.BalancingPolicyLeastConnections)
+	endpoints := []string{"endpoint:1", "endpoint:2", "endpoint:3"}
+	connections := map[string]int{"endpoint:1": 2, "endpoint:3": 1}
+	if endpoint := picker.Pick(endpoints, connections); endpoint != "endpoint:2" {
+		t.Errorf("expected endpoint:2, got %s", endpoint)
+	}
+	// Ties go to the endpoints in turn.
+	connections = map[string]int{}
+	for _, expected := range []string{"endpoint:3", "endpoint:1", "endpoint:2", "endpoint:3"} {
+		if endpoint := picker.Pick(endpoints, connections); endpoint != expected {
+			t.Errorf("expected %s, got %s", expected, endpoint)
+		}
+	}
+}
+
+func TestRandomPicker(t *testing.T) {
+	picker := newEndpointPicker(api.BalancingPolicyRandom)
+	endpoints := []string{"endpoint:1", "endpoint:2", "endpoint:3"}
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		seen[picker.Pick(endpoints, nil)] = true
+	}
+	for endpoint := range seen {
+		if endpoint != "endpoint:1" && endpoint != "endpoint:2" && endpoint != "endpoint:3" {
+			t.Errorf("unexpected endpoint %s", endpoint)
+		}
+	}
+	if len(seen) < 2 {
+		t.Errorf("expected the endpoints to be spread, got %v", seen)
+	}
+}
@@ -20,55 +20,460 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/iptables"
 	"github.com/golang/glog"
 )
 
 type serviceInfo struct {
-	name     string
-	port     int
-	listener net.Listener
-	mu       sync.Mutex // protects active
-	active   bool
+	name      string
+	port      int
+	protocol  string
+	publicIPs []string
+	socket    proxySocket
+	// The sockets on publicIPs, if socket doesn't listen on them already.
+	publicSockets []proxySocket
+	// The virtual IP and port which iptables redirect to port, if any. Then
+	// the public IPs are redirected too, instead of listened on.
+	portalIP   net.IP
+	portalPort int
+	// Whether connections to endpoints start with a PROXY protocol header.
+	proxyProtocol bool
+	timeout       time.Duration
+	// mu protects active, and portalIP and portalPort from the goroutine
+	// accepting connections.
+	mu     sync.Mutex
+	active bool
 }
 
-// Proxier is a simple proxy for TCP connections between a localhost:lport
+// portalAddr returns the portal of the service, or nil if it has none.
+func (info *serviceInfo) portalAddr() *net.TCPAddr {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	if info.portalIP == nil {
+		return nil
+	}
+	return &net.TCPAddr{IP: info.portalIP, Port: info.portalPort}
+}
+
+func (info *serviceInfo) isActive() bool {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	return info.active
+}
+
+// How long to wait for a connection to an endpoint.
+const endpointDialTimeout = 5 * time.Second
+
+// How long a UDP client keeps its endpoint without sending or receiving
+// anything through the proxy.
+const udpIdleTimeout = 1 * time.Minute
+
+// How long a client IP keeps its endpoint without connecting to a service
+// with client IP affinity.
+const sessionAffinityTTL = 3 * time.Hour
+
+// The largest datagram the UDP proxy forwards.
+const udpBufferSize = 64 * 1024
+
+// proxySocket is an abstraction over the TCP and UDP sockets of a service proxy.
+type proxySocket interface {
+	// Addr returns the address the socket listens on.
+	Addr() net.Addr
+	// Close stops the socket from accepting any more traffic.
+	Close() error
+	// ProxyLoop proxies traffic arriving at the socket to the load-balanced
+	// endpoints of service until info is no longer active.
+	ProxyLoop(service string, info *serviceInfo, proxier *Proxier)
+}
+
+// newProxySocket listens on ip and port for the given protocol, "TCP" or "UDP".
+// A nil ip listens on all addresses.
+func newProxySocket(protocol string, ip net.IP, port int) (proxySocket, error) {
+	host := ""
+	if ip != nil {
+		host = ip.String()
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	switch protocol {
+	case "TCP":
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		return &tcpProxySocket{listener}, nil
+	case "UDP":
+		addr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return &udpProxySocket{conn}, nil
+	}
+	return nil, fmt.Errorf("unknown protocol %q", protocol)
+}
+
+// tcpProxySocket proxies every accepted connection to an endpoint.
+type tcpProxySocket struct {
+	net.Listener
+}
+
+func (tcp *tcpProxySocket) ProxyLoop(service string, info *serviceInfo, proxier *Proxier) {
+	for info.isActive() {
+		inConn, err := tcp.Accept()
+		if err != nil {
+			glog.Errorf("Accept failed: %v", err)
+			continue
+		}
+		glog.Infof("Accepted connection from: %v to %v", inConn.RemoteAddr(), inConn.LocalAddr())
+		endpoint, err := proxier.loadBalancer.NextEndpoint(service, inConn.RemoteAddr())
+		if err != nil {
+			glog.Errorf("Couldn't find an endpoint for %s %v", service, err)
+			inConn.Close()
+			continue
+		}
+		glog.Infof("Mapped service %s to endpoint %s", service, endpoint)
+		counters := proxier.metrics.forService(service)
+		outConn, err := net.DialTimeout("tcp", endpoint, endpointDialTimeout)
+		counters.dialed(err)
+		if err != nil {
+			glog.Errorf("Dial failed: %v", err)
+			proxier.loadBalancer.ReleaseEndpoint(service, endpoint)
+			inConn.Close()
+			continue
+		}
+		if info.proxyProtocol {
+			if err := writeProxyHeader(outConn, inConn, info.portalAddr()); err != nil {
+				glog.Errorf("Failed to send the PROXY header to %s: %v", endpoint, err)
+				proxier.loadBalancer.ReleaseEndpoint(service, endpoint)
+				inConn.Close()
+				outConn.Close()
+				continue
+			}
+		}
+		go func(endpoint string) {
+			conns := &connPair{inConn, outConn}
+			proxier.track(endpoint, conns)
+			counters.opened()
+			proxyConnection(inConn.(*net.TCPConn), outConn.(*net.TCPConn), counters)
+			counters.closed()
+			proxier.untrack(endpoint, conns)
+			proxier.loadBalancer.ReleaseEndpoint(service, endpoint)
+		}(endpoint)
+	}
+}
+
+// writeProxyHeader starts out with the PROXY protocol (version 1) header of
+// in, naming the client and the address it connected to. iptables redirected
+// connections to a portal to the proxier before it accepted them, so their
+// destination is portal rather than the address the proxier accepted them at.
+func writeProxyHeader(out io.Writer, in net.Conn, portal *net.TCPAddr) error {
+	header := "PROXY UNKNOWN\r\n"
+	src, srcOK := in.RemoteAddr().(*net.TCPAddr)
+	dst, dstOK := in.LocalAddr().(*net.TCPAddr)
+	if portal != nil {
+		dst, dstOK = portal, true
+	}
+	if srcOK && dstOK {
+		family := "TCP6"
+		if src.IP.To4() != nil && dst.IP.To4() != nil {
+			family = "TCP4"
+		}
+		header = fmt.Sprintf("PROXY %s %s %s %d %d\r\n", family, src.IP, dst.IP, src.Port, dst.Port)
+	}
+	_, err := io.WriteString(out, header)
+	return err
+}
+
+// connPair is a proxied connection, which closes both ends at once.
+type connPair struct {
+	in, out net.Conn
+}
+
+func (c *connPair) Close() error {
+	err := c.in.Close()
+	if outErr := c.out.Close(); err == nil {
+		err = outErr
+	}
+	return err
+}
+
+// udpProxySocket associates every client address with an endpoint, and
+// forwards the datagrams of the client to it and the replies back, until the
+// association idles for longer than the service timeout.
+type udpProxySocket struct {
+	*net.UDPConn
+}
+
+func (udp *udpProxySocket) Addr() net.Addr {
+	return udp.LocalAddr()
+}
+
+// clientCache holds the connections to the endpoints of the UDP clients.
+type clientCache struct {
+	mu      sync.Mutex
+	clients map[string]net.Conn // client address -> endpoint connection
+}
+
+func (udp *udpProxySocket) ProxyLoop(service string, info *serviceInfo, proxier *Proxier) {
+	activeClients := &clientCache{clients: map[string]net.Conn{}}
+	buffer := make([]byte, udpBufferSize)
+	for info.isActive() {
+		n, cliAddr, err := udp.ReadFrom(buffer)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Temporary() {
+				glog.Errorf("ReadFrom had a temporary failure: %v", err)
+				continue
+			}
+			if info.isActive() {
+				glog.Errorf("ReadFrom failed, no longer proxying %s: %v", service, err)
+			}
+			break
+		}
+		svrConn, err := udp.getBackendConn(activeClients, cliAddr, proxier, service, info.timeout)
+		if err != nil {
+			continue
+		}
+		if _, err := svrConn.Write(buffer[0:n]); err != nil {
+			glog.Errorf("Write failed: %v", err)
+			continue
+		}
+		proxier.metrics.forService(service).bytesIn.Add(uint64(n))
+		svrConn.SetReadDeadline(time.Now().Add(info.timeout))
+	}
+}
+
+// getBackendConn returns the connection to the endpoint of cliAddr, dialing a
+// new endpoint if the client has none yet.
+func (udp *udpProxySocket) getBackendConn(activeClients *clientCache, cliAddr net.Addr, proxier *Proxier, service string, timeout time.Duration) (net.Conn, error) {
+	activeClients.mu.Lock()
+	defer activeClients.mu.Unlock()
+	svrConn, found := activeClients.clients[cliAddr.String()]
+	if found {
+		return svrConn, nil
+	}
+	endpoint, err := proxier.loadBalancer.NextEndpoint(service, cliAddr)
+	if err != nil {
+		glog.Errorf("Couldn't find an endpoint for %s %v", service, err)
+		return nil, err
+	}
+	glog.Infof("Mapped service %s to endpoint %s", service, endpoint)
+	counters := proxier.metrics.forService(service)
+	svrConn, err = net.DialTimeout("udp", endpoint, endpointDialTimeout)
+	counters.dialed(err)
+	if err != nil {
+		glog.Errorf("Dial failed: %v", err)
+		proxier.loadBalancer.ReleaseEndpoint(service, endpoint)
+		return nil, err
+	}
+	activeClients.clients[cliAddr.String()] = svrConn
+	proxier.track(endpoint, svrConn)
+	counters.opened()
+	go func() {
+		udp.proxyClient(cliAddr, svrConn, activeClients, timeout, counters)
+		counters.closed()
+		proxier.untrack(endpoint, svrConn)
+		proxier.loadBalancer.ReleaseEndpoint(service, endpoint)
+	}()
+	return svrConn, nil
+}
+
+// proxyClient copies the replies arriving at svrConn back to cliAddr, until
+// nothing arrived for timeout. The bytes copied are added to counters.
+func (udp *udpProxySocket) proxyClient(cliAddr net.Addr, svrConn net.Conn, activeClients *clientCache, timeout time.Duration, counters *serviceMetrics) {
+	buffer := make([]byte, udpBufferSize)
+	for {
+		svrConn.SetReadDeadline(time.Now().Add(timeout))
+		n, err := svrConn.Read(buffer)
+		if err != nil {
+			if e, ok := err.(net.Error); !ok || !e.Timeout() {
+				glog.Errorf("Read failed: %v", err)
+			}
+			break
+		}
+		if _, err := udp.WriteTo(buffer[0:n], cliAddr); err != nil {
+			glog.Errorf("WriteTo failed: %v", err)
+			break
+		}
+		counters.bytesOut.Add(uint64(n))
+	}
+	activeClients.mu.Lock()
+	delete(activeClients.clients, cliAddr.String())
+	activeClients.mu.Unlock()
+	svrConn.Close()
+}
+
+// Proxier is a simple proxy for TCP and UDP traffic between a localhost:lport
 // and services that provide the actual implementations.
 type Proxier struct {
 	loadBalancer LoadBalancer
-	mu           sync.Mutex // protects serviceMap
-	serviceMap   map[string]*serviceInfo
+	// nil means all addresses.
+	listenAddress net.IP
+	// nil means services with portal IPs are served on their port like others.
+	iptables iptables.Interface
+	// nil means connections to endpoints which went away are left open.
+	drainer *Drainer
+	// nil means services with portal IPs are served on any free port.
+	portRange  *util.PortRange
+	metrics    *proxyMetrics
+	mu         sync.Mutex // protects serviceMap
+	serviceMap map[string]*serviceInfo
 }
 
-// NewProxier returns a new Proxier given a LoadBalancer.
-func NewProxier(loadBalancer LoadBalancer) *Proxier {
+// iptablesProxyChain is the NAT chain of the rules which redirect portals to
+// the proxier. Packets arriving at the host and sent by it both jump to it.
+const iptablesProxyChain iptables.Chain = "KUBE-PROXY"
+
+// NewProxier returns a new Proxier given a LoadBalancer, which listens for
+// services on listenAddress and on their public IPs. An unspecified address
+// listens on all addresses, which covers any public IP of the host.
+// Given iptables, the proxier listens for services with a portal IP on any
+// free port instead, and redirects the portal IP and port to it.
+// Given a drainer, the proxier lets it close the connections to endpoints
+// which went away. Given portRange, the ports the proxier listens on for
+// services with a portal IP are taken from it, so that firewalls can let them
+// through.
+func NewProxier(loadBalancer LoadBalancer, listenAddress net.IP, iptables iptables.Interface, drainer *Drainer, portRange *util.PortRange) *Proxier {
+	if listenAddress != nil && listenAddress.IsUnspecified() {
+		listenAddress = nil
+	}
+	if iptables != nil {
+		if err := initIPTables(iptables); err != nil {
+			glog.Errorf("Failed to set up portals, serving services on their ports only: %v", err)
+			iptables = nil
+		}
+	}
 	return &Proxier{
-		loadBalancer: loadBalancer,
-		serviceMap:   make(map[string]*serviceInfo),
+		loadBalancer:  loadBalancer,
+		listenAddress: listenAddress,
+		iptables:      iptables,
+		drainer:       drainer,
+		portRange:     portRange,
+		metrics:       newProxyMetrics(metrics.Default),
+		serviceMap:    make(map[string]*serviceInfo),
+	}
+}
+
+// track lets the drainer, if any, close conn once endpoint went away.
+func (proxier *Proxier) track(endpoint string, conn io.Closer) {
+	if proxier.drainer != nil {
+		proxier.drainer.Track(endpoint, conn)
+	}
+}
+
+// untrack tells the drainer, if any, conn was closed.
+func (proxier *Proxier) untrack(endpoint string, conn io.Closer) {
+	if proxier.drainer != nil {
+		proxier.drainer.Untrack(endpoint, conn)
+	}
+}
+
+// initIPTables sets up the chain of the portal rules, and deletes the rules a
+// previous proxier left, which redirect to ports it listened on.
+func initIPTables(ipt iptables.Interface) error {
+	if _, err := ipt.EnsureChain(iptables.TableNAT, iptablesProxyChain); err != nil {
+		return err
+	}
+	if err := ipt.FlushChain(iptables.TableNAT, iptablesProxyChain); err != nil {
+		return err
+	}
+	for _, chain := range []iptables.Chain{iptables.ChainPrerouting, iptables.ChainOutput} {
+		if _, err := ipt.EnsureRule(iptables.TableNAT, chain, "-j", string(iptablesProxyChain)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// portalRuleArgs returns the rule which redirects ip and the portal port of
+// info to the port the proxier listens on for it.
+func (proxier *Proxier) portalRuleArgs(info *serviceInfo, ip net.IP) []string {
+	args := []string{
+		"-m", "comment", "--comment", info.name,
+		"-p", strings.ToLower(info.protocol),
+		"-d", ip.String() + "/32",
+		"--dport", strconv.Itoa(info.portalPort),
+	}
+	// REDIRECT sends packets to the primary address of the interface they
+	// arrived on, which only a proxier listening on all addresses is sure to get.
+	if proxier.listenAddress == nil {
+		return append(args, "-j", "REDIRECT", "--to-ports", strconv.Itoa(info.port))
+	}
+	return append(args, "-j", "DNAT", "--to-destination", net.JoinHostPort(proxier.listenAddress.String(), strconv.Itoa(info.port)))
+}
+
+// portalIPs returns the IPs redirected to the proxier for info: its portal IP
+// and its public IPs.
+func portalIPs(info *serviceInfo) []net.IP {
+	ips := []net.IP{info.portalIP}
+	for _, publicIP := range info.publicIPs {
+		if ip := net.ParseIP(publicIP); ip != nil {
+			ips = append(ips, ip)
+		}
 	}
+	return ips
 }
 
-func copyBytes(in, out *net.TCPConn) {
+// openPortal redirects the portal of info to the proxier.
+func (proxier *Proxier) openPortal(info *serviceInfo) error {
+	for _, ip := range portalIPs(info) {
+		existed, err := proxier.iptables.EnsureRule(iptables.TableNAT, iptablesProxyChain, proxier.portalRuleArgs(info, ip)...)
+		if err != nil {
+			return err
+		}
+		if !existed {
+			glog.Infof("Opened portal %s:%d for %s", ip, info.portalPort, info.name)
+		}
+	}
+	return nil
+}
+
+// closePortal deletes the rules openPortal added.
+func (proxier *Proxier) closePortal(info *serviceInfo) error {
+	var err error
+	for _, ip := range portalIPs(info) {
+		if deleteErr := proxier.iptables.DeleteRule(iptables.TableNAT, iptablesProxyChain, proxier.portalRuleArgs(info, ip)...); err == nil {
+			err = deleteErr
+		}
+	}
+	return err
+}
+
+// copyBytes copies from out to in, adding the bytes copied to count.
+func copyBytes(in, out *net.TCPConn, count *metrics.Counter, wg *sync.WaitGroup) {
+	defer wg.Done()
 	glog.Infof("Copying from %v <-> %v <-> %v <-> %v",
 		in.RemoteAddr(), in.LocalAddr(), out.LocalAddr(), out.RemoteAddr())
-	if _, err := io.Copy(in, out); err != nil {
+	if _, err := io.Copy(in, &countingReader{out, count}); err != nil {
 		glog.Errorf("I/O error: %v", err)
 	}
 	in.CloseRead()
 	out.CloseWrite()
 }
 
-// proxyConnection proxies data bidirectionally between in and out.
-func proxyConnection(in, out *net.TCPConn) {
+// proxyConnection proxies data bidirectionally between in and out, and closes
+// both once they are done. The bytes copied are added to counters.
+func proxyConnection(in, out *net.TCPConn, counters *serviceMetrics) {
 	glog.Infof("Creating proxy between %v <-> %v <-> %v <-> %v",
 		in.RemoteAddr(), in.LocalAddr(), out.LocalAddr(), out.RemoteAddr())
-	go copyBytes(in, out)
-	go copyBytes(out, in)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go copyBytes(in, out, counters.bytesOut, &wg)
+	go copyBytes(out, in, counters.bytesIn, &wg)
+	wg.Wait()
+	in.Close()
+	out.Close()
 }
 
 // StopProxy stops the proxy for the named service.
@@ -89,7 +494,19 @@ func (proxier *Proxier) stopProxyInternal(info *serviceInfo) error {
 	}
 	glog.Infof("Removing service: %s", info.name)
 	info.active = false
-	return info.listener.Close()
+	var err error
+	if info.portalIP != nil {
+		err = proxier.closePortal(info)
+	}
+	if closeErr := info.socket.Close(); err == nil {
+		err = closeErr
+	}
+	for _, sock := range info.publicSockets {
+		if closeErr := sock.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
 func (proxier *Proxier) getServiceInfo(service string) (*serviceInfo, bool) {
@@ -106,122 +523,156 @@ func (proxier *Proxier) setServiceInfo(service string, info *serviceInfo) {
 	proxier.serviceMap[service] = info
 }
 
-// AcceptHandler proxies incoming connections for the specified service
-// to the load-balanced service endpoints.
-func (proxier *Proxier) AcceptHandler(service string, listener net.Listener) {
-	info, found := proxier.getServiceInfo(service)
-	if !found {
-		glog.Errorf("Failed to find service: %s", service)
-		return
+// addServiceOnPort creates, registers and starts a service proxy for the given
+// service on the specified protocol and port, and on publicIPs unless the
+// proxier listens on all addresses. A port of 0 picks a free port.
+// With proxyProtocol, TCP connections to endpoints start with a PROXY header.
+func (proxier *Proxier) addServiceOnPort(service, protocol string, port int, publicIPs []string, timeout time.Duration, proxyProtocol bool) (*serviceInfo, error) {
+	sock, err := proxier.listen(protocol, port)
+	if err != nil {
+		return nil, err
 	}
-	for {
-		info.mu.Lock()
-		if !info.active {
-			info.mu.Unlock()
-			break
-		}
-		info.mu.Unlock()
-		inConn, err := listener.Accept()
-		if err != nil {
-			glog.Errorf("Accept failed: %v", err)
-			continue
-		}
-		glog.Infof("Accepted connection from: %v to %v", inConn.RemoteAddr(), inConn.LocalAddr())
-		endpoint, err := proxier.loadBalancer.NextEndpoint(service, inConn.RemoteAddr())
-		if err != nil {
-			glog.Errorf("Couldn't find an endpoint for %s %v", service, err)
-			inConn.Close()
-			continue
-		}
-		glog.Infof("Mapped service %s to endpoint %s", service, endpoint)
-		outConn, err := net.DialTimeout("tcp", endpoint, time.Duration(5)*time.Second)
-		if err != nil {
-			glog.Errorf("Dial failed: %v", err)
-			inConn.Close()
-			continue
+	_, portStr, err := net.SplitHostPort(sock.Addr().String())
+	if err != nil {
+		sock.Close()
+		return nil, err
+	}
+	portNum, err := strconv.Atoi(portStr)
+	if err != nil {
+		sock.Close()
+		return nil, err
+	}
+	info := &serviceInfo{
+		port:          portNum,
+		protocol:      protocol,
+		publicIPs:     publicIPs,
+		socket:        sock,
+		proxyProtocol: proxyProtocol,
+		timeout:       timeout,
+		active:        true,
+	}
+	if proxier.listenAddress != nil {
+		for _, publicIP := range publicIPs {
+			ip := net.ParseIP(publicIP)
+			if ip == nil || ip.Equal(proxier.listenAddress) {
+				continue
+			}
+			publicSock, err := newProxySocket(protocol, ip, portNum)
+			if err != nil {
+				sock.Close()
+				for _, publicSock := range info.publicSockets {
+					publicSock.Close()
+				}
+				return nil, err
+			}
+			info.publicSockets = append(info.publicSockets, publicSock)
 		}
-		proxyConnection(inConn.(*net.TCPConn), outConn.(*net.TCPConn))
 	}
+	proxier.setServiceInfo(service, info)
+	for _, sock := range append([]proxySocket{sock}, info.publicSockets...) {
+		glog.Infof("Listening for %s on %s %s", service, protocol, sock.Addr().String())
+		go sock.ProxyLoop(service, info, proxier)
+	}
+	return info, nil
 }
 
-// addService creates and registers a service proxy for the given service on
-// the specified port.
-// It returns the net.Listener of the service proxy.
-func (proxier *Proxier) addService(service string, port int) (net.Listener, error) {
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
-	if err != nil {
-		return nil, err
+// listen listens for the given protocol on port. A port of 0 picks the first
+// free port of the port range of the proxier, or any free port without one.
+func (proxier *Proxier) listen(protocol string, port int) (proxySocket, error) {
+	if port != 0 || proxier.portRange == nil {
+		return newProxySocket(protocol, proxier.listenAddress, port)
+	}
+	var lastErr error
+	for port := proxier.portRange.Base; proxier.portRange.Contains(port); port++ {
+		sock, err := newProxySocket(protocol, proxier.listenAddress, port)
+		if err == nil {
+			return sock, nil
+		}
+		lastErr = err
 	}
-	proxier.addServiceCommon(service, l)
-	return l, nil
+	return nil, fmt.Errorf("no free %s port in %s: %v", protocol, proxier.portRange, lastErr)
 }
 
 // used to globally lock around unused ports. Only used in testing.
 var unusedPortLock sync.Mutex
 
-// addService starts listening for a new service, returning the port it's using.
+// addServiceOnUnusedPort starts listening for a new service, returning the port it's using.
 // For testing on a system with unknown ports used.
-func (proxier *Proxier) addServiceOnUnusedPort(service string) (string, error) {
+func (proxier *Proxier) addServiceOnUnusedPort(service, protocol string, timeout time.Duration) (string, error) {
 	unusedPortLock.Lock()
 	defer unusedPortLock.Unlock()
-	l, err := net.Listen("tcp", ":0")
-	if err != nil {
-		return "", err
-	}
-	_, port, err := net.SplitHostPort(l.Addr().String())
-	if err != nil {
-		return "", err
-	}
-	portNum, err := strconv.Atoi(port)
+	info, err := proxier.addServiceOnPort(service, protocol, 0, nil, timeout, false)
 	if err != nil {
 		return "", err
 	}
-	proxier.setServiceInfo(service, &serviceInfo{
-		port:     portNum,
-		active:   true,
-		listener: l,
-	})
-	proxier.addServiceCommon(service, l)
-	return port, nil
-}
-
-func (proxier *Proxier) addServiceCommon(service string, l net.Listener) {
-	glog.Infof("Listening for %s on %s", service, l.Addr().String())
-	go proxier.AcceptHandler(service, l)
+	return strconv.Itoa(info.port), nil
 }
 
 // OnUpdate manages the active set of service proxies.
 // Active service proxies are reinitialized if found in the update set or
-// shutdown if missing from the update set.
+// shutdown if missing from the update set. Headless services aren't proxied.
 func (proxier *Proxier) OnUpdate(services []api.Service) {
 	glog.Infof("Received update notice: %+v", services)
 	activeServices := util.StringSet{}
 	for _, service := range services {
+		if service.PortalIP == api.PortalIPNone {
+			continue
+		}
 		activeServices.Insert(service.ID)
+		protocol := strings.ToUpper(service.Protocol)
+		if protocol == "" {
+			protocol = "TCP"
+		}
+		proxier.loadBalancer.SetBalancingPolicy(service.ID, service.BalancingPolicy)
+		proxier.loadBalancer.SetSessionAffinity(service.ID, service.SessionAffinity, sessionAffinityTTL)
+		var portalIP net.IP
+		if proxier.iptables != nil {
+			portalIP = net.ParseIP(service.PortalIP)
+		}
+		proxyProtocol := service.ClientSourceIP == api.ClientSourceIPProxyProtocol
 		info, exists := proxier.getServiceInfo(service.ID)
-		if exists && info.active && info.port == service.Port {
+		changed := exists && (info.protocol != protocol || !reflect.DeepEqual(info.publicIPs, service.PublicIPs) || !info.portalIP.Equal(portalIP) || info.proxyProtocol != proxyProtocol)
+		if portalIP != nil {
+			changed = changed || exists && info.portalPort != service.Port
+		} else {
+			changed = changed || exists && info.port != service.Port
+		}
+		if exists && info.isActive() && !changed {
 			continue
 		}
-		if exists && info.port != service.Port {
+		if changed {
 			proxier.StopProxy(service.ID)
 		}
-		glog.Infof("Adding a new service %s on port %d", service.ID, service.Port)
-		listener, err := proxier.addService(service.ID, service.Port)
+		if portalIP == nil {
+			glog.Infof("Adding a new service %s on %s port %d", service.ID, protocol, service.Port)
+			if _, err := proxier.addServiceOnPort(service.ID, protocol, service.Port, service.PublicIPs, udpIdleTimeout, proxyProtocol); err != nil {
+				glog.Infof("Failed to start listening for %s on %s port %d: %v", service.ID, protocol, service.Port, err)
+			}
+			continue
+		}
+		glog.Infof("Adding a new service %s on portal %s %s:%d", service.ID, protocol, portalIP, service.Port)
+		info, err := proxier.addServiceOnPort(service.ID, protocol, 0, nil, udpIdleTimeout, proxyProtocol)
 		if err != nil {
-			glog.Infof("Failed to start listening for %s on %d", service.ID, service.Port)
+			glog.Infof("Failed to start listening for %s: %v", service.ID, err)
 			continue
 		}
-		proxier.setServiceInfo(service.ID, &serviceInfo{
-			port:     service.Port,
-			active:   true,
-			listener: listener,
-		})
+		info.publicIPs = service.PublicIPs
+		info.mu.Lock()
+		info.portalIP = portalIP
+		info.portalPort = service.Port
+		info.mu.Unlock()
+		if err := proxier.openPortal(info); err != nil {
+			glog.Errorf("Failed to open portal for %s: %v", service.ID, err)
+			proxier.StopProxy(service.ID)
+		}
 	}
 	proxier.mu.Lock()
 	defer proxier.mu.Unlock()
 	for name, info := range proxier.serviceMap {
 		if !activeServices.Has(name) {
 			proxier.stopProxyInternal(info)
+			proxier.metrics.forget(name)
+			proxier.loadBalancer.ForgetService(name)
 		}
 	}
 }
@@ -17,19 +17,70 @@ limitations under the License.
 package proxy
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util/iptables"
 )
 
+// fakeIPTables keeps the rules of the chains in memory.
+type fakeIPTables struct {
+	chains map[iptables.Chain][]string
+}
+
+func newFakeIPTables() *fakeIPTables {
+	return &fakeIPTables{chains: map[iptables.Chain][]string{}}
+}
+
+func (f *fakeIPTables) EnsureChain(table iptables.Table, chain iptables.Chain) (bool, error) {
+	_, ok := f.chains[chain]
+	if !ok {
+		f.chains[chain] = []string{}
+	}
+	return ok, nil
+}
+
+func (f *fakeIPTables) FlushChain(table iptables.Table, chain iptables.Chain) error {
+	f.chains[chain] = []string{}
+	return nil
+}
+
+func (f *fakeIPTables) EnsureRule(table iptables.Table, chain iptables.Chain, args ...string) (bool, error) {
+	rule := strings.Join(args, " ")
+	for _, existing := range f.chains[chain] {
+		if existing == rule {
+			return true, nil
+		}
+	}
+	f.chains[chain] = append(f.chains[chain], rule)
+	return false, nil
+}
+
+func (f *fakeIPTables) DeleteRule(table iptables.Table, chain iptables.Chain, args ...string) error {
+	rule := strings.Join(args, " ")
+	var rules []string
+	for _, existing := range f.chains[chain] {
+		if existing != rule {
+			rules = append(rules, existing)
+		}
+	}
+	f.chains[chain] = rules
+	return nil
+}
+
 func waitForClosedPort(p *Proxier, proxyPort string) error {
 	for i := 0; i < 50; i++ {
 		_, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", proxyPort))
@@ -41,9 +92,28 @@ func waitForClosedPort(p *Proxier, proxyPort string) error {
 	return fmt.Errorf("port %s still open", proxyPort)
 }
 
-var port string
+var port, udpPort string
 
 func init() {
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		panic(fmt.Sprintf("failed to listen: %v", err))
+	}
+	go func() {
+		buffer := make([]byte, 1024)
+		for {
+			n, addr, err := udp.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			udp.WriteTo(buffer[0:n], addr)
+		}
+	}()
+	_, udpPort, err = net.SplitHostPort(udp.LocalAddr().String())
+	if err != nil {
+		panic(fmt.Sprintf("failed to parse: %v", err))
+	}
+
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(r.URL.Path[1:]))
@@ -74,14 +144,34 @@ func testEchoConnection(t *testing.T, address, port string) {
 	}
 }
 
+func testEchoUDP(t *testing.T, address, port string) {
+	conn, err := net.Dial("udp", net.JoinHostPort(address, port))
+	if err != nil {
+		t.Fatalf("error connecting to server: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("aaaaa")); err != nil {
+		t.Fatalf("error sending data: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buffer := make([]byte, 1024)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatalf("error reading data: %v", err)
+	}
+	if string(buffer[0:n]) != "aaaaa" {
+		t.Errorf("expected: aaaaa, got %s", string(buffer[0:n]))
+	}
+}
+
 func TestProxy(t *testing.T) {
-	lb := NewLoadBalancerRR()
+	lb := NewBalancer()
 	lb.OnUpdate([]api.Endpoints{
 		{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", port)}}})
 
-	p := NewProxier(lb)
+	p := NewProxier(lb, nil, nil, nil, nil)
 
-	proxyPort, err := p.addServiceOnUnusedPort("echo")
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "TCP", time.Second)
 	if err != nil {
 		t.Fatalf("error adding new service: %#v", err)
 	}
@@ -89,12 +179,12 @@ func TestProxy(t *testing.T) {
 }
 
 func TestProxyStop(t *testing.T) {
-	lb := NewLoadBalancerRR()
+	lb := NewBalancer()
 	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", port)}}})
 
-	p := NewProxier(lb)
+	p := NewProxier(lb, nil, nil, nil, nil)
 
-	proxyPort, err := p.addServiceOnUnusedPort("echo")
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "TCP", time.Second)
 	if err != nil {
 		t.Fatalf("error adding new service: %#v", err)
 	}
@@ -112,12 +202,12 @@ func TestProxyStop(t *testing.T) {
 }
 
 func TestProxyUpdateDelete(t *testing.T) {
-	lb := NewLoadBalancerRR()
+	lb := NewBalancer()
 	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", port)}}})
 
-	p := NewProxier(lb)
+	p := NewProxier(lb, nil, nil, nil, nil)
 
-	proxyPort, err := p.addServiceOnUnusedPort("echo")
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "TCP", time.Second)
 	if err != nil {
 		t.Fatalf("error adding new service: %#v", err)
 	}
@@ -134,12 +224,12 @@ func TestProxyUpdateDelete(t *testing.T) {
 }
 
 func TestProxyUpdateDeleteUpdate(t *testing.T) {
-	lb := NewLoadBalancerRR()
+	lb := NewBalancer()
 	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", port)}}})
 
-	p := NewProxier(lb)
+	p := NewProxier(lb, nil, nil, nil, nil)
 
-	proxyPort, err := p.addServiceOnUnusedPort("echo")
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "TCP", time.Second)
 	if err != nil {
 		t.Fatalf("error adding new service: %#v", err)
 	}
@@ -161,12 +251,12 @@ func TestProxyUpdateDeleteUpdate(t *testing.T) {
 }
 
 func TestProxyUpdatePort(t *testing.T) {
-	lb := NewLoadBalancerRR()
+	lb := NewBalancer()
 	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", port)}}})
 
-	p := NewProxier(lb)
+	p := NewProxier(lb, nil, nil, nil, nil)
 
-	proxyPort, err := p.addServiceOnUnusedPort("echo")
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "TCP", time.Second)
 	if err != nil {
 		t.Fatalf("error adding new service: %#v", err)
 	}
@@ -194,12 +284,12 @@ func TestProxyUpdatePort(t *testing.T) {
 }
 
 func TestProxyUpdatePortLetsGoOfOldPort(t *testing.T) {
-	lb := NewLoadBalancerRR()
+	lb := NewBalancer()
 	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", port)}}})
 
-	p := NewProxier(lb)
+	p := NewProxier(lb, nil, nil, nil, nil)
 
-	proxyPort, err := p.addServiceOnUnusedPort("echo")
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "TCP", time.Second)
 	if err != nil {
 		t.Fatalf("error adding new service: %#v", err)
 	}
@@ -233,3 +323,332 @@ func TestProxyUpdatePortLetsGoOfOldPort(t *testing.T) {
 	}
 	testEchoConnection(t, "127.0.0.1", proxyPort)
 }
+
+func TestUDPProxy(t *testing.T) {
+	lb := NewBalancer()
+	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", udpPort)}}})
+
+	p := NewProxier(lb, nil, nil, nil, nil)
+
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "UDP", time.Second)
+	if err != nil {
+		t.Fatalf("error adding new service: %#v", err)
+	}
+	testEchoUDP(t, "127.0.0.1", proxyPort)
+}
+
+func TestUDPProxyIdleTimeout(t *testing.T) {
+	lb := NewBalancer()
+	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", udpPort)}}})
+
+	p := NewProxier(lb, nil, nil, nil, nil)
+
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "UDP", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("error adding new service: %#v", err)
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", proxyPort))
+	if err != nil {
+		t.Fatalf("error connecting to proxy: %v", err)
+	}
+	defer conn.Close()
+	buffer := make([]byte, 1024)
+	for i := 0; i < 2; i++ {
+		if _, err := conn.Write([]byte("aaaaa")); err != nil {
+			t.Fatalf("error sending data: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := conn.Read(buffer); err != nil {
+			t.Fatalf("error reading data: %v", err)
+		}
+		// Let the association of the client expire; the next datagram
+		// has to get a new one.
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestUDPProxyStop(t *testing.T) {
+	lb := NewBalancer()
+	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", udpPort)}}})
+
+	p := NewProxier(lb, nil, nil, nil, nil)
+
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "UDP", time.Second)
+	if err != nil {
+		t.Fatalf("error adding new service: %#v", err)
+	}
+	testEchoUDP(t, "127.0.0.1", proxyPort)
+
+	p.StopProxy("echo")
+	// The port can be bound again once the proxy let go of it.
+	proxyPortNum, _ := strconv.Atoi(proxyPort)
+	for i := 0; ; i++ {
+		l, err := net.ListenUDP("udp", &net.UDPAddr{Port: proxyPortNum})
+		if err == nil {
+			l.Close()
+			break
+		}
+		if i == 50 {
+			t.Fatalf("port %s still open", proxyPort)
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+}
+
+func TestProxyUpdateProtocol(t *testing.T) {
+	lb := NewBalancer()
+	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", udpPort)}}})
+
+	p := NewProxier(lb, nil, nil, nil, nil)
+
+	proxyPort, err := p.addServiceOnUnusedPort("echo", "TCP", time.Second)
+	if err != nil {
+		t.Fatalf("error adding new service: %#v", err)
+	}
+	proxyPortNum, _ := strconv.Atoi(proxyPort)
+	p.OnUpdate([]api.Service{
+		{JSONBase: api.JSONBase{ID: "echo"}, Port: proxyPortNum, Protocol: "UDP"},
+	})
+	if err := waitForClosedPort(p, proxyPort); err != nil {
+		t.Fatal(err)
+	}
+	testEchoUDP(t, "127.0.0.1", proxyPort)
+}
+
+func TestProxyPublicIPs(t *testing.T) {
+	lb := NewBalancer()
+	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", port)}}})
+
+	p := NewProxier(lb, net.ParseIP("127.0.0.1"), nil, nil, nil)
+
+	// add a new dummy listener in order to get a port that is free
+	l, _ := net.Listen("tcp", ":0")
+	_, proxyPort, _ := net.SplitHostPort(l.Addr().String())
+	proxyPortNum, _ := strconv.Atoi(proxyPort)
+	l.Close()
+
+	p.OnUpdate([]api.Service{
+		{JSONBase: api.JSONBase{ID: "echo"}, Port: proxyPortNum, PublicIPs: []string{"127.0.0.2"}},
+	})
+	testEchoConnection(t, "127.0.0.1", proxyPort)
+	testEchoConnection(t, "127.0.0.2", proxyPort)
+
+	p.OnUpdate([]api.Service{
+		{JSONBase: api.JSONBase{ID: "echo"}, Port: proxyPortNum},
+	})
+	if _, err := net.Dial("tcp", net.JoinHostPort("127.0.0.2", proxyPort)); err == nil {
+		t.Errorf("expected the public IP to be let go of")
+	}
+	testEchoConnection(t, "127.0.0.1", proxyPort)
+}
+
+func TestNewProxierSetsUpPortalChain(t *testing.T) {
+	ipt := newFakeIPTables()
+	ipt.chains[iptablesProxyChain] = []string{"stale rule"}
+	NewProxier(NewBalancer(), nil, ipt, nil, nil)
+	if rules := ipt.chains[iptablesProxyChain]; len(rules) != 0 {
+		t.Errorf("expected the rules of an old proxier to be flushed, got %v", rules)
+	}
+	for _, chain := range []iptables.Chain{iptables.ChainPrerouting, iptables.ChainOutput} {
+		if expected := []string{"-j KUBE-PROXY"}; !reflect.DeepEqual(ipt.chains[chain], expected) {
+			t.Errorf("%s: expected %v, got %v", chain, expected, ipt.chains[chain])
+		}
+	}
+}
+
+func TestProxyPortal(t *testing.T) {
+	lb := NewBalancer()
+	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{net.JoinHostPort("127.0.0.1", port)}}})
+
+	ipt := newFakeIPTables()
+	p := NewProxier(lb, nil, ipt, nil, nil)
+	p.OnUpdate([]api.Service{
+		{JSONBase: api.JSONBase{ID: "echo"}, Port: 80, PortalIP: "10.0.0.1", PublicIPs: []string{"1.2.3.4"}},
+	})
+	info, ok := p.getServiceInfo("echo")
+	if !ok {
+		t.Fatalf("expected the service to be proxied")
+	}
+	if info.port == 80 {
+		t.Errorf("expected the proxier to listen on any free port, not the service's")
+	}
+	proxyPort := strconv.Itoa(info.port)
+	testEchoConnection(t, "127.0.0.1", proxyPort)
+	expected := []string{
+		"-m comment --comment echo -p tcp -d 10.0.0.1/32 --dport 80 -j REDIRECT --to-ports " + proxyPort,
+		"-m comment --comment echo -p tcp -d 1.2.3.4/32 --dport 80 -j REDIRECT --to-ports " + proxyPort,
+	}
+	if rules := ipt.chains[iptablesProxyChain]; !reflect.DeepEqual(rules, expected) {
+		t.Errorf("expected %v, got %v", expected, rules)
+	}
+
+	// Updates which don't change the portal keep the port.
+	p.OnUpdate([]api.Service{
+		{JSONBase: api.JSONBase{ID: "echo"}, Port: 80, PortalIP: "10.0.0.1", PublicIPs: []string{"1.2.3.4"}},
+	})
+	if info, _ := p.getServiceInfo("echo"); strconv.Itoa(info.port) != proxyPort {
+		t.Errorf("expected port %s to be kept, got %d", proxyPort, info.port)
+	}
+
+	p.OnUpdate([]api.Service{})
+	if rules := ipt.chains[iptablesProxyChain]; len(rules) != 0 {
+		t.Errorf("expected the portal to be closed, got %v", rules)
+	}
+	if err := waitForClosedPort(p, proxyPort); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProxyPortalBindAddress(t *testing.T) {
+	ipt := newFakeIPTables()
+	p := NewProxier(NewBalancer(), net.ParseIP("127.0.0.1"), ipt, nil, nil)
+	p.OnUpdate([]api.Service{
+		{JSONBase: api.JSONBase{ID: "echo"}, Port: 53, PortalIP: "10.0.0.1", Protocol: "UDP"},
+	})
+	info, ok := p.getServiceInfo("echo")
+	if !ok {
+		t.Fatalf("expected the service to be proxied")
+	}
+	defer p.StopProxy("echo")
+	expected := []string{
+		"-m comment --comment echo -p udp -d 10.0.0.1/32 --dport 53 -j DNAT --to-destination 127.0.0.1:" + strconv.Itoa(info.port),
+	}
+	if rules := ipt.chains[iptablesProxyChain]; !reflect.DeepEqual(rules, expected) {
+		t.Errorf("expected %v, got %v", expected, rules)
+	}
+}
+
+func TestProxyPortalPortRange(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	taken := l.Addr().(*net.TCPAddr).Port
+	p := NewProxier(NewBalancer(), net.ParseIP("127.0.0.1"), newFakeIPTables(), nil, &util.PortRange{Base: taken, Size: 1})
+	services := []api.Service{
+		{JSONBase: api.JSONBase{ID: "echo"}, Port: 80, PortalIP: "10.0.0.1"},
+	}
+	p.OnUpdate(services)
+	if _, ok := p.getServiceInfo("echo"); ok {
+		t.Errorf("expected no service without a free port in the range")
+	}
+	l.Close()
+	p.OnUpdate(services)
+	info, ok := p.getServiceInfo("echo")
+	if !ok {
+		t.Fatalf("expected the service to be proxied")
+	}
+	defer p.StopProxy("echo")
+	if info.port != taken {
+		t.Errorf("expected port %d, got %d", taken, info.port)
+	}
+}
+
+func TestProxyHeadlessService(t *testing.T) {
+	ipt := newFakeIPTables()
+	p := NewProxier(NewBalancer(), nil, ipt, nil, nil)
+	p.OnUpdate([]api.Service{
+		{JSONBase: api.JSONBase{ID: "db"}, Port: 5432, PortalIP: api.PortalIPNone},
+	})
+	if _, ok := p.getServiceInfo("db"); ok {
+		t.Errorf("expected a headless service not to be proxied")
+	}
+	if rules := ipt.chains[iptablesProxyChain]; len(rules) != 0 {
+		t.Errorf("expected no portal, got %v", rules)
+	}
+}
+
+func TestTCPProxyProtocolHeader(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer backend.Close()
+	go func() {
+		conn, err := backend.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Echo the header back.
+		header, _ := bufio.NewReader(conn).ReadString('\n')
+		conn.Write([]byte(header))
+	}()
+
+	lb := NewBalancer()
+	lb.OnUpdate([]api.Endpoints{{JSONBase: api.JSONBase{ID: "echo"}, Endpoints: []string{backend.Addr().String()}}})
+	p := NewProxier(lb, nil, nil, nil, nil)
+	info, err := p.addServiceOnPort("echo", "TCP", 0, nil, time.Second, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer p.StopProxy("echo")
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(info.port)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	header, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := conn.LocalAddr().(*net.TCPAddr)
+	expected := fmt.Sprintf("PROXY TCP4 127.0.0.1 127.0.0.1 %d %d\r\n", client.Port, info.port)
+	if header != expected {
+		t.Errorf("expected %q, got %q", expected, header)
+	}
+}
+
+// addrConn is a connection from remote to local.
+type addrConn struct {
+	net.Conn
+	local, remote net.Addr
+}
+
+func (c addrConn) LocalAddr() net.Addr  { return c.local }
+func (c addrConn) RemoteAddr() net.Addr { return c.remote }
+
+func TestProxyProtocolHeaderNamesPortal(t *testing.T) {
+	in := addrConn{
+		local:  &net.TCPAddr{IP: net.ParseIP("10.240.0.2"), Port: 43210},
+		remote: &net.TCPAddr{IP: net.ParseIP("10.240.0.5"), Port: 51234},
+	}
+	var out bytes.Buffer
+	if err := writeProxyHeader(&out, in, &net.TCPAddr{IP: net.ParseIP("10.0.0.7"), Port: 80}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := "PROXY TCP4 10.240.0.5 10.0.0.7 51234 80\r\n", out.String(); e != a {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+}
+
+func TestProxyProtocolChangeRestartsService(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	free := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	p := NewProxier(NewBalancer(), net.ParseIP("127.0.0.1"), nil, nil, nil)
+	service := api.Service{JSONBase: api.JSONBase{ID: "echo"}, Port: free}
+	p.OnUpdate([]api.Service{service})
+	info, ok := p.getServiceInfo("echo")
+	if !ok || info.proxyProtocol {
+		t.Fatalf("expected the service to be proxied without PROXY headers")
+	}
+	p.OnUpdate([]api.Service{service})
+	if unchanged, _ := p.getServiceInfo("echo"); unchanged != info {
+		t.Fatalf("expected an unchanged service to keep its proxy")
+	}
+	service.ClientSourceIP = api.ClientSourceIPProxyProtocol
+	p.OnUpdate([]api.Service{service})
+	updated, ok := p.getServiceInfo("echo")
+	if !ok || !updated.proxyProtocol {
+		t.Fatalf("expected the service to be proxied with PROXY headers")
+	}
+	defer p.StopProxy("echo")
+	if info.isActive() {
+		t.Errorf("expected the old proxy to be stopped")
+	}
+}
@@ -64,6 +64,9 @@ func (b *BindingStorage) Create(obj interface{}) (<-chan interface{}, error) {
 	if !ok {
 		return nil, fmt.Errorf("incorrect type: %#v", obj)
 	}
+	if errs := api.ValidateBinding(binding); len(errs) > 0 {
+		return nil, apiserver.NewInvalidErr("binding", binding.PodID, errs)
+	}
 	return apiserver.MakeAsync(func() (interface{}, error) {
 		if err := b.registry.ApplyBinding(binding); err != nil {
 			return nil, err
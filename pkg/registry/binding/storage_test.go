@@ -67,6 +67,9 @@ func TestBindingStorageUnsupported(t *testing.T) {
 	if _, err := b.List(labels.Set{"name": "foo"}.AsSelector()); err == nil {
 		t.Errorf("unexpected non-error")
 	}
+	if _, err := b.Create(&api.Binding{PodID: "foo"}); err == nil {
+		t.Errorf("unexpected non-error for a binding without a host")
+	}
 	// Try sending wrong object just to get 100% coverage
 	if _, err := b.Create(&api.Pod{}); err == nil {
 		t.Errorf("unexpected non-error")
@@ -23,7 +23,9 @@ import (
 
 // Registry is an interface for things that know how to store ReplicationControllers.
 type Registry interface {
-	ListControllers() ([]api.ReplicationController, error)
+	// ListControllers obtains every controller, carrying the resourceVersion
+	// the list was read at.
+	ListControllers() (api.ReplicationControllerList, error)
 	WatchControllers(resourceVersion uint64) (watch.Interface, error)
 	GetController(controllerID string) (*api.ReplicationController, error)
 	CreateController(controller api.ReplicationController) error
@@ -0,0 +1,109 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/golang/glog"
+)
+
+// maxResizeAttempts is how many times a resize rereads its controller after
+// losing a race with another update of it.
+const maxResizeAttempts = 5
+
+// ResizeStorage implements the RESTStorage interface. When resizes are written,
+// it changes the number of replicas of the affected replication controllers,
+// and nothing else, so resizing doesn't race with other updates.
+type ResizeStorage struct {
+	registry Registry
+}
+
+// NewResizeStorage makes a new ResizeStorage backed by the given controller registry.
+func NewResizeStorage(registry Registry) *ResizeStorage {
+	return &ResizeStorage{
+		registry: registry,
+	}
+}
+
+// List returns an error because resizes are write-only objects.
+func (*ResizeStorage) List(selector labels.Selector) (interface{}, error) {
+	return nil, apiserver.NewNotFoundErr("resize", "list")
+}
+
+// Get returns an error because resizes are write-only objects.
+func (*ResizeStorage) Get(id string) (interface{}, error) {
+	return nil, apiserver.NewNotFoundErr("resize", id)
+}
+
+// Delete returns an error because resizes are write-only objects.
+func (*ResizeStorage) Delete(id string) (<-chan interface{}, error) {
+	return nil, apiserver.NewNotFoundErr("resize", id)
+}
+
+// New returns a new resize object fit for having data unmarshalled into it.
+func (*ResizeStorage) New() interface{} {
+	return &api.Resize{}
+}
+
+// Create sets the replicas of the controller the resize names, and returns
+// the resized controller.
+func (rs *ResizeStorage) Create(obj interface{}) (<-chan interface{}, error) {
+	resize, ok := obj.(*api.Resize)
+	if !ok {
+		return nil, fmt.Errorf("incorrect type: %#v", obj)
+	}
+	if errs := api.ValidateResize(resize); len(errs) > 0 {
+		return nil, apiserver.NewInvalidErr("resize", resize.ControllerID, errs)
+	}
+	return apiserver.MakeAsync(func() (interface{}, error) {
+		return rs.resize(resize.ControllerID, resize.Replicas)
+	}), nil
+}
+
+// resize updates the replicas of the controller id, rereading it on conflicts.
+func (rs *ResizeStorage) resize(id string, replicas int) (*api.ReplicationController, error) {
+	var err error
+	for attempt := 0; attempt < maxResizeAttempts; attempt++ {
+		var controller *api.ReplicationController
+		controller, err = rs.registry.GetController(id)
+		if err != nil {
+			return nil, err
+		}
+		if controller.DesiredState.Replicas == replicas {
+			return controller, nil
+		}
+		controller.DesiredState.Replicas = replicas
+		err = rs.registry.UpdateController(*controller)
+		if err == nil {
+			return rs.registry.GetController(id)
+		}
+		if !apiserver.IsConflict(err) {
+			return nil, err
+		}
+		glog.V(2).Infof("Resizing controller %s raced with another update, retrying", id)
+	}
+	return nil, err
+}
+
+// Update returns an error-- this object may not be updated.
+func (*ResizeStorage) Update(obj interface{}) (<-chan interface{}, error) {
+	return nil, fmt.Errorf("Resizes may not be changed.")
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/registrytest"
+)
+
+// racingRegistry holds one controller, and fails the first conflicts updates
+// as if another writer got there first.
+type racingRegistry struct {
+	registrytest.ControllerRegistry
+	controller api.ReplicationController
+	conflicts  int
+	updates    int
+}
+
+func (r *racingRegistry) GetController(id string) (*api.ReplicationController, error) {
+	if id != r.controller.ID {
+		return nil, apiserver.NewNotFoundErr("replicationController", id)
+	}
+	controller := r.controller
+	return &controller, nil
+}
+
+func (r *racingRegistry) UpdateController(controller api.ReplicationController) error {
+	r.updates++
+	if r.conflicts > 0 {
+		r.conflicts--
+		// The other writer changed the template.
+		r.controller.DesiredState.PodTemplate.Labels = map[string]string{"version": "2"}
+		return apiserver.NewConflictErr("replicationController", controller.ID, errors.New("resourceVersion changed"))
+	}
+	r.controller = controller
+	return nil
+}
+
+func TestResizeStorage(t *testing.T) {
+	registry := &racingRegistry{
+		controller: api.ReplicationController{
+			JSONBase:     api.JSONBase{ID: "foo"},
+			DesiredState: api.ReplicationControllerState{Replicas: 1},
+		},
+		conflicts: 2,
+	}
+	storage := NewResizeStorage(registry)
+	channel, err := storage.Create(&api.Resize{ControllerID: "foo", Replicas: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := <-channel
+	controller, ok := result.(*api.ReplicationController)
+	if !ok {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	if controller.DesiredState.Replicas != 5 {
+		t.Errorf("expected 5 replicas, got %d", controller.DesiredState.Replicas)
+	}
+	if controller.DesiredState.PodTemplate.Labels["version"] != "2" {
+		t.Errorf("expected the resize to keep the other update, got %#v", controller)
+	}
+	if registry.updates != 3 {
+		t.Errorf("expected 3 updates, got %d", registry.updates)
+	}
+}
+
+func TestResizeStorageGivesUp(t *testing.T) {
+	registry := &racingRegistry{
+		controller: api.ReplicationController{JSONBase: api.JSONBase{ID: "foo"}},
+		conflicts:  maxResizeAttempts,
+	}
+	channel, err := NewResizeStorage(registry).Create(&api.Resize{ControllerID: "foo", Replicas: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := <-channel
+	if status, ok := result.(*api.Status); !ok || status.Code != 409 {
+		t.Errorf("expected a conflict, got %#v", result)
+	}
+}
+
+func TestResizeStorageUnchanged(t *testing.T) {
+	registry := &racingRegistry{
+		controller: api.ReplicationController{
+			JSONBase:     api.JSONBase{ID: "foo"},
+			DesiredState: api.ReplicationControllerState{Replicas: 3},
+		},
+	}
+	channel, err := NewResizeStorage(registry).Create(&api.Resize{ControllerID: "foo", Replicas: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-channel
+	if registry.updates != 0 {
+		t.Errorf("expected no update, got %d", registry.updates)
+	}
+}
+
+func TestResizeStorageInvalid(t *testing.T) {
+	storage := NewResizeStorage(&racingRegistry{})
+	if _, err := storage.Create(&api.Resize{ControllerID: "foo", Replicas: -1}); !apiserver.IsInvalid(err) {
+		t.Errorf("expected an invalid error, got %v", err)
+	}
+	if _, err := storage.Get("foo"); !apiserver.IsNotFound(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if _, err := storage.Update(&api.Resize{}); err == nil {
+		t.Errorf("unexpected non-error")
+	}
+}
@@ -60,7 +60,7 @@ func (rs *RegistryStorage) Create(obj interface{}) (<-chan interface{}, error) {
 	// Pod Manifest ID should be assigned by the pod API
 	controller.DesiredState.PodTemplate.DesiredState.Manifest.ID = ""
 	if errs := api.ValidateReplicationController(controller); len(errs) > 0 {
-		return nil, fmt.Errorf("Validation errors: %v", errs)
+		return nil, apiserver.NewInvalidErr("replicationController", controller.ID, errs)
 	}
 
 	controller.CreationTimestamp = util.Now()
@@ -95,7 +95,8 @@ func (rs *RegistryStorage) List(selector labels.Selector) (interface{}, error) {
 	result := api.ReplicationControllerList{}
 	controllers, err := rs.registry.ListControllers()
 	if err == nil {
-		for _, controller := range controllers {
+		result.ResourceVersion = controllers.ResourceVersion
+		for _, controller := range controllers.Items {
 			if selector.Matches(labels.Set(controller.Labels)) {
 				result.Items = append(result.Items, controller)
 			}
@@ -117,7 +118,7 @@ func (rs *RegistryStorage) Update(obj interface{}) (<-chan interface{}, error) {
 		return nil, fmt.Errorf("not a replication controller: %#v", obj)
 	}
 	if errs := api.ValidateReplicationController(controller); len(errs) > 0 {
-		return nil, fmt.Errorf("Validation errors: %v", errs)
+		return nil, apiserver.NewInvalidErr("replicationController", controller.ID, errs)
 	}
 	return apiserver.MakeAsync(func() (interface{}, error) {
 		err := rs.registry.UpdateController(*controller)
@@ -150,7 +151,7 @@ func (rs *RegistryStorage) waitForController(ctrl api.ReplicationController) (in
 		if err != nil {
 			return ctrl, err
 		}
-		if len(pods) == ctrl.DesiredState.Replicas {
+		if len(pods.Items) == ctrl.DesiredState.Replicas {
 			break
 		}
 		time.Sleep(rs.pollPeriod)
@@ -0,0 +1,34 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemon
+
+import (
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+)
+
+// Registry is an interface for things that know how to store DaemonControllers.
+type Registry interface {
+	// ListDaemons obtains every daemon controller, carrying the resourceVersion
+	// the list was read at.
+	ListDaemons() (api.DaemonControllerList, error)
+	WatchDaemons(resourceVersion uint64) (watch.Interface, error)
+	GetDaemon(daemonID string) (*api.DaemonController, error)
+	CreateDaemon(daemon api.DaemonController) error
+	UpdateDaemon(daemon api.DaemonController) error
+	DeleteDaemon(daemonID string) error
+}
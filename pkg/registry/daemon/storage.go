@@ -0,0 +1,132 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+
+	"code.google.com/p/go-uuid/uuid"
+)
+
+// RegistryStorage stores data for the daemon controller service.
+// It implements apiserver.RESTStorage.
+type RegistryStorage struct {
+	registry Registry
+}
+
+// NewRegistryStorage returns a new apiserver.RESTStorage for the given registry.
+func NewRegistryStorage(registry Registry) apiserver.RESTStorage {
+	return &RegistryStorage{registry: registry}
+}
+
+// Create registers the given DaemonController.
+func (rs *RegistryStorage) Create(obj interface{}) (<-chan interface{}, error) {
+	daemon, ok := obj.(*api.DaemonController)
+	if !ok {
+		return nil, fmt.Errorf("not a daemon controller: %#v", obj)
+	}
+	if len(daemon.ID) == 0 {
+		daemon.ID = uuid.NewUUID().String()
+	}
+	// Pod Manifest ID should be assigned by the pod API
+	daemon.DesiredState.PodTemplate.DesiredState.Manifest.ID = ""
+	if errs := api.ValidateDaemonController(daemon); len(errs) > 0 {
+		return nil, apiserver.NewInvalidErr("daemonController", daemon.ID, errs)
+	}
+
+	daemon.CreationTimestamp = util.Now()
+
+	return apiserver.MakeAsync(func() (interface{}, error) {
+		if err := rs.registry.CreateDaemon(*daemon); err != nil {
+			return nil, err
+		}
+		return rs.registry.GetDaemon(daemon.ID)
+	}), nil
+}
+
+// Delete asynchronously deletes the DaemonController specified by its id. Its
+// pods are deleted by the daemon manager.
+func (rs *RegistryStorage) Delete(id string) (<-chan interface{}, error) {
+	return apiserver.MakeAsync(func() (interface{}, error) {
+		return &api.Status{Status: api.StatusSuccess}, rs.registry.DeleteDaemon(id)
+	}), nil
+}
+
+// Get obtains the DaemonController specified by its id.
+func (rs *RegistryStorage) Get(id string) (interface{}, error) {
+	return rs.registry.GetDaemon(id)
+}
+
+// List obtains a list of DaemonControllers that match selector.
+func (rs *RegistryStorage) List(selector labels.Selector) (interface{}, error) {
+	result := api.DaemonControllerList{}
+	daemons, err := rs.registry.ListDaemons()
+	if err == nil {
+		result.ResourceVersion = daemons.ResourceVersion
+		for _, daemon := range daemons.Items {
+			if selector.Matches(labels.Set(daemon.Labels)) {
+				result.Items = append(result.Items, daemon)
+			}
+		}
+	}
+	return result, err
+}
+
+// New creates a new DaemonController for use with Create and Update.
+func (rs RegistryStorage) New() interface{} {
+	return &api.DaemonController{}
+}
+
+// Update replaces a given DaemonController instance with an existing instance
+// in storage.registry.
+func (rs *RegistryStorage) Update(obj interface{}) (<-chan interface{}, error) {
+	daemon, ok := obj.(*api.DaemonController)
+	if !ok {
+		return nil, fmt.Errorf("not a daemon controller: %#v", obj)
+	}
+	if errs := api.ValidateDaemonController(daemon); len(errs) > 0 {
+		return nil, apiserver.NewInvalidErr("daemonController", daemon.ID, errs)
+	}
+	return apiserver.MakeAsync(func() (interface{}, error) {
+		if err := rs.registry.UpdateDaemon(*daemon); err != nil {
+			return nil, err
+		}
+		return rs.registry.GetDaemon(daemon.ID)
+	}), nil
+}
+
+// Watch returns DaemonController events via a watch.Interface.
+// It implements apiserver.ResourceWatcher.
+func (rs *RegistryStorage) Watch(label, field labels.Selector, resourceVersion uint64) (watch.Interface, error) {
+	if !field.Empty() {
+		return nil, fmt.Errorf("no field selector implemented for daemon controllers")
+	}
+	incoming, err := rs.registry.WatchDaemons(resourceVersion)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(incoming, func(e watch.Event) (watch.Event, bool) {
+		daemon := e.Object.(*api.DaemonController)
+		return e, label.Matches(labels.Set(daemon.Labels))
+	}), nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemon
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/registrytest"
+)
+
+func validDaemon(id string) *api.DaemonController {
+	return &api.DaemonController{
+		JSONBase: api.JSONBase{ID: id},
+		DesiredState: api.DaemonControllerState{
+			ReplicaSelector: map[string]string{"name": "logs"},
+			PodTemplate: api.PodTemplate{
+				DesiredState: api.PodState{
+					Manifest: api.ContainerManifest{
+						Version:    "v1beta1",
+						Containers: []api.Container{{Name: "fluentd", Image: "fluentd"}},
+					},
+				},
+				Labels: map[string]string{"name": "logs"},
+			},
+		},
+		Labels: map[string]string{"name": "logs"},
+	}
+}
+
+func TestCreateDaemon(t *testing.T) {
+	registry := registrytest.NewDaemonRegistry()
+	storage := NewRegistryStorage(registry)
+	channel, err := storage.Create(validDaemon("logs"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := <-channel
+	daemon, ok := result.(*api.DaemonController)
+	if !ok || daemon.ID != "logs" || daemon.CreationTimestamp.IsZero() {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if _, ok := registry.Daemons["logs"]; !ok {
+		t.Errorf("expected the daemon to be stored")
+	}
+}
+
+func TestCreateInvalidDaemon(t *testing.T) {
+	registry := registrytest.NewDaemonRegistry()
+	storage := NewRegistryStorage(registry)
+	daemon := validDaemon("logs")
+	daemon.DesiredState.ReplicaSelector = map[string]string{"name": "other"}
+	if _, err := storage.Create(daemon); !apiserver.IsInvalid(err) {
+		t.Errorf("expected an invalid error, got %v", err)
+	}
+	if _, err := storage.Update(daemon); !apiserver.IsInvalid(err) {
+		t.Errorf("expected an invalid error, got %v", err)
+	}
+	if len(registry.Daemons) != 0 {
+		t.Errorf("unexpected daemons: %#v", registry.Daemons)
+	}
+}
+
+func TestUpdateDaemon(t *testing.T) {
+	registry := registrytest.NewDaemonRegistry()
+	registry.Daemons["logs"] = *validDaemon("logs")
+	storage := NewRegistryStorage(registry)
+	daemon := validDaemon("logs")
+	daemon.DesiredState.PodTemplate.NodeSelector = map[string]string{"logs": "shipped"}
+	channel, err := storage.Update(daemon)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-channel
+	if registry.Daemons["logs"].DesiredState.PodTemplate.NodeSelector["logs"] != "shipped" {
+		t.Errorf("unexpected daemon: %#v", registry.Daemons["logs"])
+	}
+}
+
+func TestListDaemons(t *testing.T) {
+	registry := registrytest.NewDaemonRegistry()
+	registry.Daemons["logs"] = *validDaemon("logs")
+	storage := NewRegistryStorage(registry)
+	for selector, count := range map[string]int{"": 1, "name=logs": 1, "name=monitoring": 0} {
+		s, err := labels.ParseSelector(selector)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		obj, err := storage.List(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if items := obj.(api.DaemonControllerList).Items; len(items) != count {
+			t.Errorf("%q: expected %d daemons, got %#v", selector, count, items)
+		}
+	}
+}
+
+func TestDeleteDaemon(t *testing.T) {
+	registry := registrytest.NewDaemonRegistry()
+	registry.Daemons["logs"] = *validDaemon("logs")
+	storage := NewRegistryStorage(registry)
+	channel, err := storage.Delete("logs")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status, ok := (<-channel).(*api.Status); !ok || status.Status != api.StatusSuccess {
+		t.Errorf("unexpected result: %#v", status)
+	}
+	if len(registry.Daemons) != 0 {
+		t.Errorf("unexpected daemons: %#v", registry.Daemons)
+	}
+}
@@ -19,32 +19,116 @@ package endpoint
 import (
 	"fmt"
 	"net"
+	"reflect"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/service"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
 
 	"github.com/golang/glog"
 )
 
 // A EndpointController manages service endpoints.
 type EndpointController struct {
-	client          *client.Client
+	client          client.Interface
 	serviceRegistry service.Registry
+	// pods is kept up to date by a watch, so that syncing a service only reads
+	// the pods it selects.
+	pods cache.StoreToPodLister
+	// deletions holds a value while there are pods deleted since the endpoints
+	// were last synced, so that pods deleted in bulk cause a single sync.
+	deletions chan struct{}
 }
 
 // NewEndpointController returns a new *EndpointController.
-func NewEndpointController(serviceRegistry service.Registry, client *client.Client) *EndpointController {
+func NewEndpointController(serviceRegistry service.Registry, client client.Interface) *EndpointController {
 	return &EndpointController{
 		serviceRegistry: serviceRegistry,
 		client:          client,
+		pods:            cache.StoreToPodLister{Indexer: cache.NewPodIndexer()},
+		deletions:       make(chan struct{}, 1),
 	}
 }
 
-// SyncServiceEndpoints syncs service endpoints.
+// Run syncs the endpoints of every service each period, and right after pods
+// are deleted, so that traffic stops going to them without waiting for the period.
+func (e *EndpointController) Run(period time.Duration) {
+	podReflector := cache.NewListWatchReflector(
+		e.listPods,
+		e.watchPods,
+		&api.Pod{},
+		podDeletionNotifier{e.pods.Indexer, e.podDeleted},
+	)
+	podReflector.SetResyncPeriod(period)
+	podReflector.Run()
+	go func() {
+		// Until every pod is known, services would lose the endpoints of the
+		// pods not yet listed.
+		for !podReflector.HasListed() {
+			time.Sleep(100 * time.Millisecond)
+		}
+		go e.syncDeletions()
+		util.Forever(func() {
+			if err := e.SyncServiceEndpoints(); err != nil {
+				glog.Errorf("Failed to sync service endpoints: %v", err)
+			}
+		}, period)
+	}()
+}
+
+func (e *EndpointController) listPods() (interface{}, error) {
+	return e.client.ListPods(labels.Everything())
+}
+
+func (e *EndpointController) watchPods(resourceVersion uint64) (watch.Interface, error) {
+	return e.client.WatchPods(labels.Everything(), labels.Everything(), resourceVersion)
+}
+
+// podDeleted has syncDeletions sync the endpoints of every service, now that pod
+// is gone from the cache.
+func (e *EndpointController) podDeleted(pod *api.Pod) {
+	glog.V(1).Infof("Pod %s was deleted, syncing service endpoints", pod.ID)
+	select {
+	case e.deletions <- struct{}{}:
+	default:
+		// A sync is pending already, and will see the pod gone.
+	}
+}
+
+// syncDeletions syncs the endpoints of every service whenever pods were
+// deleted. The pods deleted while it syncs cause one more sync.
+func (e *EndpointController) syncDeletions() {
+	for _ = range e.deletions {
+		if err := e.SyncServiceEndpoints(); err != nil {
+			glog.Errorf("Failed to sync service endpoints: %v", err)
+		}
+	}
+}
+
+// podDeletionNotifier passes every pod deleted from the Indexer to deleted.
+type podDeletionNotifier struct {
+	cache.Indexer
+	deleted func(pod *api.Pod)
+}
+
+func (n podDeletionNotifier) Delete(id string) {
+	old, exists := n.Indexer.Get(id)
+	n.Indexer.Delete(id)
+	if exists {
+		n.deleted(old.(*api.Pod))
+	}
+}
+
+// SyncServiceEndpoints syncs service endpoints. Only pods which are running
+// and ready are endpoints.
 func (e *EndpointController) SyncServiceEndpoints() error {
 	services, err := e.serviceRegistry.ListServices()
 	if err != nil {
@@ -53,15 +137,27 @@ func (e *EndpointController) SyncServiceEndpoints() error {
 	}
 	var resultErr error
 	for _, service := range services.Items {
-		pods, err := e.client.ListPods(labels.Set(service.Selector).AsSelector())
+		if len(service.Selector) == 0 {
+			// The endpoints of services without a selector are set through the API.
+			continue
+		}
+		pods, err := e.pods.ListPods(labels.Set(service.Selector).AsSelector())
 		if err != nil {
 			glog.Errorf("Error syncing service: %#v, skipping.", service)
 			resultErr = err
 			continue
 		}
-		endpoints := make([]string, len(pods.Items))
-		for ix, pod := range pods.Items {
-			port, err := findPort(&pod.DesiredState.Manifest, service.ContainerPort)
+		endpoints := make([]string, 0, len(pods))
+		for _, pod := range pods {
+			if pod.CurrentState.Status != api.PodRunning {
+				glog.V(1).Infof("Pod %s is not running, leaving it out of service %s", pod.ID, service.ID)
+				continue
+			}
+			if !podReady(&pod) {
+				glog.V(1).Infof("Pod %s is not ready, leaving it out of service %s", pod.ID, service.ID)
+				continue
+			}
+			port, err := findPort(&pod.DesiredState.Manifest, api.ServiceTargetPort(&service))
 			if err != nil {
 				glog.Errorf("Failed to find port for service: %v, %v", service, err)
 				continue
@@ -70,20 +166,51 @@ func (e *EndpointController) SyncServiceEndpoints() error {
 				glog.Errorf("Failed to find an IP for pod: %v", pod)
 				continue
 			}
-			endpoints[ix] = net.JoinHostPort(pod.CurrentState.PodIP, strconv.Itoa(port))
+			endpoints = append(endpoints, net.JoinHostPort(pod.CurrentState.PodIP, strconv.Itoa(port)))
+		}
+		// The cache lists pods in no particular order.
+		sort.Strings(endpoints)
+		current, err := e.serviceRegistry.GetEndpoints(service.ID)
+		if apiserver.IsNotFound(err) {
+			current, err = &api.Endpoints{JSONBase: api.JSONBase{ID: service.ID}}, nil
 		}
-		err = e.serviceRegistry.UpdateEndpoints(api.Endpoints{
-			JSONBase:  api.JSONBase{ID: service.ID},
-			Endpoints: endpoints,
-		})
 		if err != nil {
+			glog.Errorf("Error getting endpoints: %#v", err)
+			resultErr = err
+			continue
+		}
+		if reflect.DeepEqual(current.Endpoints, endpoints) {
+			continue
+		}
+		// A conflict means someone else wrote the endpoints since we read them;
+		// the next sync will recompute them.
+		current.Endpoints = endpoints
+		if err := e.serviceRegistry.UpdateEndpoints(*current); err != nil {
 			glog.Errorf("Error updating endpoints: %#v", err)
+			resultErr = err
 			continue
 		}
 	}
 	return resultErr
 }
 
+// podReady returns true if pod can be sent traffic: either none of its containers
+// has a readiness probe, or its kubelet reported it ready.
+func podReady(pod *api.Pod) bool {
+	for _, container := range pod.DesiredState.Manifest.Containers {
+		if container.ReadinessProbe == nil {
+			continue
+		}
+		for _, condition := range pod.CurrentState.Conditions {
+			if condition == api.PodReady {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
 // findPort locates the container port for the given manifest and portName.
 func findPort(manifest *api.ContainerManifest, portName util.IntOrString) (int, error) {
 	if ((portName.Kind == util.IntstrString && len(portName.StrVal) == 0) ||
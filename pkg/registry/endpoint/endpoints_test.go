@@ -17,9 +17,8 @@ limitations under the License.
 package endpoint
 
 import (
-	"encoding/json"
 	"fmt"
-	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
@@ -36,6 +35,7 @@ func newPodList(count int) api.PodList {
 				ID:         fmt.Sprintf("pod%d", i),
 				APIVersion: "v1beta1",
 			},
+			Labels: map[string]string{"foo": "bar"},
 			DesiredState: api.PodState{
 				Manifest: api.ContainerManifest{
 					Containers: []api.Container{
@@ -50,7 +50,8 @@ func newPodList(count int) api.PodList {
 				},
 			},
 			CurrentState: api.PodState{
-				PodIP: "1.2.3.4",
+				Status: api.PodRunning,
+				PodIP:  "1.2.3.4",
 			},
 		})
 	}
@@ -60,6 +61,15 @@ func newPodList(count int) api.PodList {
 	}
 }
 
+// newEndpointController returns an EndpointController whose pod cache holds pods.
+func newEndpointController(serviceRegistry *registrytest.ServiceRegistry, pods api.PodList) *EndpointController {
+	endpoints := NewEndpointController(serviceRegistry, &client.Fake{})
+	for i := range pods.Items {
+		endpoints.pods.Add(pods.Items[i].ID, &pods.Items[i])
+	}
+	return endpoints
+}
+
 func TestFindPort(t *testing.T) {
 	manifest := api.ContainerManifest{
 		Containers: []api.Container{
@@ -122,15 +132,9 @@ func TestFindPort(t *testing.T) {
 }
 
 func TestSyncEndpointsEmpty(t *testing.T) {
-	body, _ := json.Marshal(newPodList(0))
-	fakeHandler := util.FakeHandler{
-		StatusCode:   200,
-		ResponseBody: string(body),
-	}
-	testServer := httptest.NewTLSServer(&fakeHandler)
-	client := client.New(testServer.URL, nil)
+	pods := newPodList(0)
 	serviceRegistry := registrytest.ServiceRegistry{}
-	endpoints := NewEndpointController(&serviceRegistry, client)
+	endpoints := newEndpointController(&serviceRegistry, pods)
 	err := endpoints.SyncServiceEndpoints()
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -139,17 +143,11 @@ func TestSyncEndpointsEmpty(t *testing.T) {
 }
 
 func TestSyncEndpointsError(t *testing.T) {
-	body, _ := json.Marshal(newPodList(0))
-	fakeHandler := util.FakeHandler{
-		StatusCode:   200,
-		ResponseBody: string(body),
-	}
-	testServer := httptest.NewTLSServer(&fakeHandler)
-	client := client.New(testServer.URL, nil)
+	pods := newPodList(0)
 	serviceRegistry := registrytest.ServiceRegistry{
 		Err: fmt.Errorf("test error"),
 	}
-	endpoints := NewEndpointController(&serviceRegistry, client)
+	endpoints := newEndpointController(&serviceRegistry, pods)
 	err := endpoints.SyncServiceEndpoints()
 	if err != serviceRegistry.Err {
 		t.Errorf("Errors don't match: %#v %#v", err, serviceRegistry.Err)
@@ -157,13 +155,7 @@ func TestSyncEndpointsError(t *testing.T) {
 }
 
 func TestSyncEndpointsItems(t *testing.T) {
-	body, _ := json.Marshal(newPodList(1))
-	fakeHandler := util.FakeHandler{
-		StatusCode:   200,
-		ResponseBody: string(body),
-	}
-	testServer := httptest.NewTLSServer(&fakeHandler)
-	client := client.New(testServer.URL, nil)
+	pods := newPodList(1)
 	serviceRegistry := registrytest.ServiceRegistry{
 		List: api.ServiceList{
 			Items: []api.Service{
@@ -175,7 +167,7 @@ func TestSyncEndpointsItems(t *testing.T) {
 			},
 		},
 	}
-	endpoints := NewEndpointController(&serviceRegistry, client)
+	endpoints := newEndpointController(&serviceRegistry, pods)
 	err := endpoints.SyncServiceEndpoints()
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -185,12 +177,63 @@ func TestSyncEndpointsItems(t *testing.T) {
 	}
 }
 
-func TestSyncEndpointsPodError(t *testing.T) {
-	fakeHandler := util.FakeHandler{
-		StatusCode: 500,
+func TestSyncEndpointsSkipsSelectorlessServices(t *testing.T) {
+	client := &client.Fake{}
+	serviceRegistry := registrytest.ServiceRegistry{
+		List: api.ServiceList{
+			Items: []api.Service{
+				{JSONBase: api.JSONBase{ID: "db"}},
+			},
+		},
+		Endpoints: api.Endpoints{JSONBase: api.JSONBase{ID: "db"}, Endpoints: []string{"10.240.0.5:3306"}},
+	}
+	endpoints := NewEndpointController(&serviceRegistry, client)
+	if err := endpoints.SyncServiceEndpoints(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(client.Actions) != 0 {
+		t.Errorf("unexpected actions: %#v", client.Actions)
+	}
+	if !reflect.DeepEqual(serviceRegistry.Endpoints.Endpoints, []string{"10.240.0.5:3306"}) {
+		t.Errorf("unexpected endpoints update: %#v", serviceRegistry.Endpoints)
+	}
+}
+
+func TestSyncEndpointsSkipsUnreadyPods(t *testing.T) {
+	pods := newPodList(3)
+	probe := &api.LivenessProbe{Type: "http"}
+	pods.Items[1].DesiredState.Manifest.Containers[0].ReadinessProbe = probe
+	pods.Items[1].CurrentState.PodIP = "1.2.3.5"
+	pods.Items[2].DesiredState.Manifest.Containers[0].ReadinessProbe = probe
+	pods.Items[2].CurrentState.PodIP = "1.2.3.6"
+	pods.Items[2].CurrentState.Conditions = []api.PodCondition{api.PodReady}
+	serviceRegistry := registrytest.ServiceRegistry{
+		List: api.ServiceList{
+			Items: []api.Service{
+				{
+					Selector: map[string]string{
+						"foo": "bar",
+					},
+				},
+			},
+		},
 	}
-	testServer := httptest.NewTLSServer(&fakeHandler)
-	client := client.New(testServer.URL, nil)
+	endpoints := newEndpointController(&serviceRegistry, pods)
+	if err := endpoints.SyncServiceEndpoints(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := []string{"1.2.3.4:8080", "1.2.3.6:8080"}
+	if !reflect.DeepEqual(serviceRegistry.Endpoints.Endpoints, expected) {
+		t.Errorf("Unexpected endpoints update: %#v", serviceRegistry.Endpoints)
+	}
+}
+
+func TestSyncEndpointsSkipsPodsNotRunning(t *testing.T) {
+	pods := newPodList(3)
+	pods.Items[1].CurrentState.Status = api.PodWaiting
+	pods.Items[1].CurrentState.PodIP = "1.2.3.5"
+	pods.Items[2].CurrentState.Status = api.PodTerminated
+	pods.Items[2].CurrentState.PodIP = "1.2.3.6"
 	serviceRegistry := registrytest.ServiceRegistry{
 		List: api.ServiceList{
 			Items: []api.Service{
@@ -202,9 +245,106 @@ func TestSyncEndpointsPodError(t *testing.T) {
 			},
 		},
 	}
-	endpoints := NewEndpointController(&serviceRegistry, client)
-	err := endpoints.SyncServiceEndpoints()
-	if err == nil {
-		t.Error("Unexpected non-error")
+	endpoints := newEndpointController(&serviceRegistry, pods)
+	if err := endpoints.SyncServiceEndpoints(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := []string{"1.2.3.4:8080"}
+	if !reflect.DeepEqual(serviceRegistry.Endpoints.Endpoints, expected) {
+		t.Errorf("Unexpected endpoints update: %#v", serviceRegistry.Endpoints)
+	}
+}
+
+func TestSyncEndpointsOnPodDeletion(t *testing.T) {
+	serviceRegistry := registrytest.ServiceRegistry{
+		List: api.ServiceList{
+			Items: []api.Service{
+				{
+					JSONBase: api.JSONBase{ID: "foo"},
+					Selector: map[string]string{
+						"foo": "bar",
+					},
+				},
+			},
+		},
+		Endpoints: api.Endpoints{
+			JSONBase:  api.JSONBase{ID: "foo"},
+			Endpoints: []string{"1.2.3.4:8080"},
+		},
+	}
+	endpoints := newEndpointController(&serviceRegistry, api.PodList{})
+	store := podDeletionNotifier{endpoints.pods.Indexer, endpoints.podDeleted}
+	pods := newPodList(2)
+	pods.Items[0].CurrentState.PodIP = "1.2.3.5"
+	store.Add("pod0", &pods.Items[0])
+	store.Add("pod1", &pods.Items[1])
+	if len(endpoints.deletions) != 0 {
+		t.Errorf("Expected no sync before the deletion")
+	}
+	// Deletions in bulk cause a single sync.
+	store.Delete("pod0")
+	store.Delete("pod1")
+	if len(endpoints.deletions) != 1 {
+		t.Errorf("Expected a single sync to be pending, got %d", len(endpoints.deletions))
+	}
+	close(endpoints.deletions)
+	endpoints.syncDeletions()
+	if len(serviceRegistry.Endpoints.Endpoints) != 0 {
+		t.Errorf("Unexpected endpoints: %#v", serviceRegistry.Endpoints)
+	}
+}
+
+func TestSyncEndpointsNamedTargetPort(t *testing.T) {
+	pods := newPodList(2)
+	pods.Items[0].DesiredState.Manifest.Containers[0].Ports = []api.Port{
+		{Name: "metrics", ContainerPort: 9090},
+		{Name: "http", ContainerPort: 8080},
+	}
+	// The second pod runs a version of the container with another port number.
+	pods.Items[1].DesiredState.Manifest.Containers[0].Ports = []api.Port{{Name: "http", ContainerPort: 8081}}
+	pods.Items[1].CurrentState.PodIP = "1.2.3.5"
+	for _, service := range []api.Service{
+		{Selector: map[string]string{"foo": "bar"}, TargetPort: util.NewIntOrStringFromString("http")},
+		// Stored before TargetPort existed.
+		{Selector: map[string]string{"foo": "bar"}, ContainerPort: util.NewIntOrStringFromString("http")},
+	} {
+		serviceRegistry := registrytest.ServiceRegistry{
+			List: api.ServiceList{Items: []api.Service{service}},
+		}
+		endpoints := newEndpointController(&serviceRegistry, pods)
+		if err := endpoints.SyncServiceEndpoints(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		expected := []string{"1.2.3.4:8080", "1.2.3.5:8081"}
+		if !reflect.DeepEqual(serviceRegistry.Endpoints.Endpoints, expected) {
+			t.Errorf("Unexpected endpoints update: %#v", serviceRegistry.Endpoints)
+		}
+	}
+}
+
+func TestSyncEndpointsKeepsResourceVersion(t *testing.T) {
+	pods := newPodList(1)
+	serviceRegistry := registrytest.ServiceRegistry{
+		List: api.ServiceList{
+			Items: []api.Service{
+				{
+					JSONBase: api.JSONBase{ID: "foo"},
+					Selector: map[string]string{
+						"foo": "bar",
+					},
+				},
+			},
+		},
+		Endpoints: api.Endpoints{
+			JSONBase:  api.JSONBase{ID: "foo", ResourceVersion: 7},
+			Endpoints: []string{"1.2.3.4:80"},
+		},
+	}
+	endpoints := newEndpointController(&serviceRegistry, pods)
+	if err := endpoints.SyncServiceEndpoints(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if serviceRegistry.Endpoints.ResourceVersion != 7 || len(serviceRegistry.Endpoints.Endpoints) != 1 || serviceRegistry.Endpoints.Endpoints[0] == "1.2.3.4:80" {
+		t.Errorf("Unexpected endpoints update: %#v", serviceRegistry.Endpoints)
 	}
 }
@@ -0,0 +1,99 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoint
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/service"
+)
+
+// RegistryStorage implements the RESTStorage interface for the endpoints of
+// services. The EndpointController keeps the endpoints of services with a
+// selector up to date; the endpoints of services without one are set here.
+type RegistryStorage struct {
+	registry service.Registry
+}
+
+// NewRegistryStorage returns a new RegistryStorage.
+func NewRegistryStorage(registry service.Registry) apiserver.RESTStorage {
+	return &RegistryStorage{
+		registry: registry,
+	}
+}
+
+// Create sets the endpoints of a service without a selector.
+func (rs *RegistryStorage) Create(obj interface{}) (<-chan interface{}, error) {
+	return rs.Update(obj)
+}
+
+// Delete returns an error because endpoints are deleted with their service.
+func (rs *RegistryStorage) Delete(id string) (<-chan interface{}, error) {
+	return nil, fmt.Errorf("Endpoints may not be deleted, only their service.")
+}
+
+func (rs *RegistryStorage) Get(id string) (interface{}, error) {
+	return rs.registry.GetEndpoints(id)
+}
+
+// List returns the endpoints whose ID matches selector, e.g. "ID=foo".
+// Endpoints have no labels.
+func (rs *RegistryStorage) List(selector labels.Selector) (interface{}, error) {
+	all, err := rs.registry.ListEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	result := api.EndpointsList{JSONBase: all.JSONBase}
+	for _, endpoints := range all.Items {
+		if selector.Matches(labels.Set{"ID": endpoints.ID}) {
+			result.Items = append(result.Items, endpoints)
+		}
+	}
+	return result, nil
+}
+
+func (rs *RegistryStorage) New() interface{} {
+	return &api.Endpoints{}
+}
+
+// Update replaces the endpoints of a service without a selector.
+func (rs *RegistryStorage) Update(obj interface{}) (<-chan interface{}, error) {
+	endpoints, ok := obj.(*api.Endpoints)
+	if !ok {
+		return nil, fmt.Errorf("not endpoints: %#v", obj)
+	}
+	if errs := api.ValidateEndpoints(endpoints); len(errs) > 0 {
+		return nil, apiserver.NewInvalidErr("endpoints", endpoints.ID, errs)
+	}
+	svc, err := rs.registry.GetService(endpoints.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(svc.Selector) != 0 {
+		return nil, apiserver.NewConflictErr("endpoints", endpoints.ID,
+			fmt.Errorf("service %s has a selector, which its endpoints are computed from", svc.ID))
+	}
+	return apiserver.MakeAsync(func() (interface{}, error) {
+		if err := rs.registry.UpdateEndpoints(*endpoints); err != nil {
+			return nil, err
+		}
+		return rs.registry.GetEndpoints(endpoints.ID)
+	}), nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpoint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/registrytest"
+)
+
+func TestUpdateSelectorlessServiceEndpoints(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	registry.Service = &api.Service{JSONBase: api.JSONBase{ID: "db"}, Port: 3306}
+	storage := NewRegistryStorage(registry)
+	endpoints := &api.Endpoints{JSONBase: api.JSONBase{ID: "db"}, Endpoints: []string{"10.240.0.5:3306"}}
+	channel, err := storage.Create(endpoints)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result := <-channel
+	if got, ok := result.(*api.Endpoints); !ok || !reflect.DeepEqual(got, endpoints) {
+		t.Errorf("unexpected result: %#v", result)
+	}
+	if !reflect.DeepEqual(registry.Endpoints, *endpoints) {
+		t.Errorf("unexpected endpoints: %#v", registry.Endpoints)
+	}
+}
+
+func TestUpdateSelectorServiceEndpoints(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	registry.Service = &api.Service{JSONBase: api.JSONBase{ID: "web"}, Selector: map[string]string{"name": "web"}}
+	storage := NewRegistryStorage(registry)
+	_, err := storage.Update(&api.Endpoints{JSONBase: api.JSONBase{ID: "web"}, Endpoints: []string{"10.240.0.5:80"}})
+	if !apiserver.IsConflict(err) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+	if len(registry.Endpoints.Endpoints) != 0 {
+		t.Errorf("unexpected endpoints: %#v", registry.Endpoints)
+	}
+}
+
+func TestUpdateInvalidEndpoints(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	registry.Service = &api.Service{JSONBase: api.JSONBase{ID: "db"}}
+	storage := NewRegistryStorage(registry)
+	_, err := storage.Update(&api.Endpoints{JSONBase: api.JSONBase{ID: "db"}, Endpoints: []string{"10.240.0.5"}})
+	if !apiserver.IsInvalid(err) {
+		t.Errorf("expected an invalid error, got %v", err)
+	}
+}
+
+func TestListEndpoints(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	registry.Endpoints = api.Endpoints{JSONBase: api.JSONBase{ID: "db"}, Endpoints: []string{"10.240.0.5:3306"}}
+	storage := NewRegistryStorage(registry)
+	for selector, count := range map[string]int{"": 1, "ID=db": 1, "ID=web": 0} {
+		s, err := labels.ParseSelector(selector)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		obj, err := storage.List(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if list := obj.(api.EndpointsList); len(list.Items) != count {
+			t.Errorf("%q: expected %d endpoints, got %#v", selector, count, list)
+		}
+	}
+}
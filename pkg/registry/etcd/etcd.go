@@ -18,12 +18,19 @@ package etcd
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	errs "github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/minion"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/storage"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
 
 	"github.com/golang/glog"
@@ -37,33 +44,189 @@ import (
 type Registry struct {
 	tools.EtcdHelper
 	manifestFactory ManifestFactory
+
+	pods        storage.Interface
+	controllers storage.Interface
+	daemons     storage.Interface
+	services    storage.Interface
+	endpoints   storage.Interface
+	manifests   storage.Interface
+	// backends holds the backends above before they were instrumented.
+	backends RegistryStorage
+	// Operation records and events are always kept in etcd, which expires them.
+	// operations is operationStore, instrumented.
+	operations     storage.Interface
+	operationStore *Store
+	events         *Store
+}
+
+// RegistryStorage holds the backends a Registry keeps each kind of object in.
+type RegistryStorage struct {
+	Pods        storage.Interface
+	Controllers storage.Interface
+	Daemons     storage.Interface
+	Services    storage.Interface
+	Endpoints   storage.Interface
+	// Manifests holds each machine's api.ContainerManifestList, keyed by
+	// machine name.
+	Manifests storage.Interface
+}
+
+// NewEtcdRegistryStorage returns a RegistryStorage keeping everything in etcd.
+func NewEtcdRegistryStorage(helper *tools.EtcdHelper) RegistryStorage {
+	return newEtcdRegistryStorage(helper, helper)
+}
+
+// newEtcdRegistryStorage is like NewEtcdRegistryStorage, but keeps the kinds
+// other components read from etcd directly with plainHelper.
+func newEtcdRegistryStorage(helper, plainHelper *tools.EtcdHelper) RegistryStorage {
+	return RegistryStorage{
+		Pods: &Store{
+			Helper:    helper,
+			Kind:      "pod",
+			Prefix:    "/registry/pods",
+			Namespace: DefaultNamespace,
+			NewFunc:   func() interface{} { return &api.Pod{} },
+		},
+		Controllers: &Store{
+			Helper:    helper,
+			Kind:      "replicationController",
+			Prefix:    "/registry/controllers",
+			Namespace: DefaultNamespace,
+			NewFunc:   func() interface{} { return &api.ReplicationController{} },
+		},
+		Daemons: &Store{
+			Helper:    helper,
+			Kind:      "daemonController",
+			Prefix:    "/registry/daemons",
+			Namespace: DefaultNamespace,
+			NewFunc:   func() interface{} { return &api.DaemonController{} },
+		},
+		Services: &Store{
+			Helper:    plainHelper,
+			Kind:      "service",
+			Prefix:    "/registry/services/specs",
+			Namespace: DefaultNamespace,
+			NewFunc:   func() interface{} { return &api.Service{} },
+		},
+		Endpoints: &Store{
+			Helper:    plainHelper,
+			Kind:      "endpoints",
+			Prefix:    "/registry/services/endpoints",
+			Namespace: DefaultNamespace,
+			NewFunc:   func() interface{} { return &api.Endpoints{} },
+		},
+		// Kubelets watch these keys directly, so they can't move. Only Get and
+		// AtomicUpdate make sense on them.
+		Manifests: &Store{
+			Helper:  plainHelper,
+			Kind:    "containerManifestList",
+			Prefix:  "/registry/hosts",
+			KeyFunc: makeContainerKey,
+			NewFunc: func() interface{} { return &api.ContainerManifestList{} },
+		},
+	}
+}
+
+// NewMemoryRegistryStorage returns a RegistryStorage keeping everything in
+// memory, for tests which don't want to run etcd. The backends share a clock,
+// so resourceVersions are ordered across kinds as they are in etcd.
+func NewMemoryRegistryStorage() RegistryStorage {
+	clock := storage.NewMemoryClock()
+	newMemory := func(kind string) storage.Interface {
+		return storage.NewMemoryWithClock(kind, api.Codec, api.ResourceVersioner, clock)
+	}
+	return RegistryStorage{
+		Pods:        newMemory("pod"),
+		Controllers: newMemory("replicationController"),
+		Daemons:     newMemory("daemonController"),
+		Services:    newMemory("service"),
+		Endpoints:   newMemory("endpoints"),
+		Manifests:   newMemory("containerManifestList"),
+	}
 }
 
 // NewRegistry creates an etcd registry.
 func NewRegistry(client tools.EtcdClient, machines minion.Registry) *Registry {
+	return NewRegistryWithCodec(client, api.Codec, machines)
+}
+
+// NewRegistryWithCodec creates an etcd registry which stores objects with codec,
+// e.g. a tools.EncryptingCodec. Container manifests are read from etcd by the
+// kubelets, and services and endpoints by the proxies, so they are always
+// stored with api.Codec.
+func NewRegistryWithCodec(client tools.EtcdClient, codec tools.Codec, machines minion.Registry) *Registry {
+	helper := &tools.EtcdHelper{Client: client, Codec: codec, ResourceVersioner: api.ResourceVersioner}
+	plainHelper := &tools.EtcdHelper{Client: client, Codec: api.Codec, ResourceVersioner: api.ResourceVersioner}
+	return NewRegistryWithStorage(client, newEtcdRegistryStorage(helper, plainHelper), machines)
+}
+
+// NewRegistryWithStorage creates a registry keeping objects in the backends
+// given by s. Operation records are still kept in etcd through client.
+// Calls to the backends and to the operation records are recorded in
+// metrics.Default.
+func NewRegistryWithStorage(client tools.EtcdClient, s RegistryStorage, machines minion.Registry) *Registry {
 	registry := &Registry{
 		EtcdHelper: tools.EtcdHelper{
 			client,
 			api.Codec,
 			api.ResourceVersioner,
 		},
+		pods:        storage.Instrument("pod", s.Pods, metrics.Default),
+		controllers: storage.Instrument("replicationController", s.Controllers, metrics.Default),
+		daemons:     storage.Instrument("daemonController", s.Daemons, metrics.Default),
+		services:    storage.Instrument("service", s.Services, metrics.Default),
+		endpoints:   storage.Instrument("endpoints", s.Endpoints, metrics.Default),
+		manifests:   storage.Instrument("containerManifestList", s.Manifests, metrics.Default),
+		backends:    s,
 	}
 	registry.manifestFactory = &BasicManifestFactory{
 		serviceRegistry: registry,
 	}
+	registry.operationStore = &Store{
+		Helper:  &registry.EtcdHelper,
+		Kind:    "operation",
+		Prefix:  "/registry/operations",
+		NewFunc: func() interface{} { return &api.ServerOp{} },
+	}
+	registry.operations = storage.Instrument("operation", registry.operationStore, metrics.Default)
+	registry.events = &Store{
+		Helper:  &registry.EtcdHelper,
+		Kind:    "event",
+		Prefix:  "/registry/events",
+		NewFunc: func() interface{} { return &api.Event{} },
+	}
 	return registry
 }
 
-func makePodKey(podID string) string {
-	return "/registry/pods/" + podID
+// MigrateKeys moves objects stored in the flat key layout used before
+// namespaces into the default namespace. Backends other than etcd are left
+// alone. Safe to call on every startup.
+func (r *Registry) MigrateKeys() error {
+	b := r.backends
+	for _, s := range []storage.Interface{b.Pods, b.Controllers, b.Services, b.Endpoints} {
+		store, ok := s.(*Store)
+		if !ok {
+			continue
+		}
+		moved, err := store.MigrateFlatKeys()
+		if err != nil {
+			return fmt.Errorf("unable to migrate %s keys: %v", store.Kind, err)
+		}
+		if moved > 0 {
+			glog.Infof("Moved %d %s objects into namespace %s", moved, store.Kind, store.Namespace)
+		}
+	}
+	return nil
 }
 
-// ListPods obtains a list of pods that match selector.
-func (r *Registry) ListPods(selector labels.Selector) ([]api.Pod, error) {
+// ListPods obtains a list of pods that match selector. The list's
+// resourceVersion is the version it was read at.
+func (r *Registry) ListPods(selector labels.Selector) (api.PodList, error) {
 	allPods := []api.Pod{}
-	filteredPods := []api.Pod{}
-	if err := r.ExtractList("/registry/pods", &allPods); err != nil {
-		return nil, err
+	list := api.PodList{Items: []api.Pod{}}
+	if err := r.pods.List(&allPods, &list.ResourceVersion); err != nil {
+		return api.PodList{}, err
 	}
 	for _, pod := range allPods {
 		if selector.Matches(labels.Set(pod.Labels)) {
@@ -71,21 +234,21 @@ func (r *Registry) ListPods(selector labels.Selector) ([]api.Pod, error) {
 			// the CurrentState.Host and Status fields. Here we pretend that reality perfectly
 			// matches our desires.
 			pod.CurrentState.Host = pod.DesiredState.Host
-			filteredPods = append(filteredPods, pod)
+			list.Items = append(list.Items, pod)
 		}
 	}
-	return filteredPods, nil
+	return list, nil
 }
 
 // WatchPods begins watching for new, changed, or deleted pods.
 func (r *Registry) WatchPods(resourceVersion uint64) (watch.Interface, error) {
-	return r.WatchList("/registry/pods", resourceVersion, tools.Everything)
+	return r.pods.Watch(resourceVersion, tools.Everything)
 }
 
 // GetPod gets a specific pod specified by its ID.
 func (r *Registry) GetPod(podID string) (*api.Pod, error) {
 	var pod api.Pod
-	if err := r.ExtractObj(makePodKey(podID), &pod, false); err != nil {
+	if err := r.pods.Get(podID, &pod); err != nil {
 		return nil, err
 	}
 	// TODO: Currently nothing sets CurrentState.Host. We need a feedback loop that sets
@@ -100,6 +263,7 @@ func makeContainerKey(machine string) string {
 }
 
 // CreatePod creates a pod based on a specification, schedule it onto a specific machine.
+// Pods created for no machine wait for a scheduler to bind them.
 func (r *Registry) CreatePod(machine string, pod api.Pod) error {
 	// Set current status to "Waiting".
 	pod.CurrentState.Status = api.PodWaiting
@@ -107,82 +271,178 @@ func (r *Registry) CreatePod(machine string, pod api.Pod) error {
 	// DesiredState.Host == "" is a signal to the scheduler that this pod needs scheduling.
 	pod.DesiredState.Status = api.PodRunning
 	pod.DesiredState.Host = ""
-	if err := r.CreateObj(makePodKey(pod.ID), &pod); err != nil {
+	if err := r.pods.Create(pod.ID, &pod); err != nil {
 		return err
 	}
+	if machine == "" {
+		return nil
+	}
 	// TODO: Until scheduler separation is completed, just assign here.
-	return r.assignPod(pod.ID, machine)
+	if err := r.assignPod(pod.ID, machine); err != nil {
+		// Don't strand stuff. This is a terrible hack that won't be needed
+		// once pods are only ever bound through ApplyBinding.
+		if err2 := r.pods.Delete(pod.ID, false); err2 != nil {
+			glog.Errorf("Probably stranding a pod, couldn't delete %v: %#v", pod.ID, err2)
+		}
+		return err
+	}
+	return nil
+}
+
+// checkHostPorts returns an error if manifest asks for a host port which one of
+// manifests, the manifests already on a machine, holds with the same protocol.
+func checkHostPorts(manifests []api.ContainerManifest, manifest api.ContainerManifest) error {
+	inUse := util.StringSet{}
+	for _, m := range manifests {
+		for _, c := range m.Containers {
+			for _, p := range c.Ports {
+				if p.HostPort != 0 {
+					inUse.Insert(hostPortKey(p))
+				}
+			}
+		}
+	}
+	var conflicts []string
+	for _, c := range manifest.Containers {
+		for _, p := range c.Ports {
+			if p.HostPort == 0 {
+				continue
+			}
+			key := hostPortKey(p)
+			if inUse.Has(key) {
+				conflicts = append(conflicts, key)
+			}
+			inUse.Insert(key)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("host ports in use: %v", conflicts)
+	}
+	return nil
+}
+
+// hostPortKey returns the host port and protocol of p as "port/protocol". TCP
+// and UDP ports of the same number don't collide.
+func hostPortKey(p api.Port) string {
+	protocol := strings.ToUpper(p.Protocol)
+	if protocol == "" {
+		protocol = "TCP"
+	}
+	return fmt.Sprintf("%d/%s", p.HostPort, protocol)
 }
 
-// ApplyBinding implements binding's registry
+// ApplyBinding implements binding's registry. The pod's host is set with a
+// compare-and-swap, so of two schedulers racing to bind the same pod exactly one
+// succeeds and the other gets a conflict. Binding a pod to a machine where one of
+// its host ports is already taken is a conflict too.
 func (r *Registry) ApplyBinding(binding *api.Binding) error {
 	return r.assignPod(binding.PodID, binding.Host)
 }
 
-// assignPod assigns the given pod to the given machine.
-// TODO: hook this up via apiserver, not by calling it from CreatePod().
+// assignPod assigns the given pod to the given machine. Assigning a pod to the
+// machine it is already on does nothing; assigning it anywhere else is a conflict.
+// If the machine's manifests can't be updated, the assignment is undone.
 func (r *Registry) assignPod(podID string, machine string) error {
-	podKey := makePodKey(podID)
 	var finalPod *api.Pod
-	err := r.AtomicUpdate(podKey, &api.Pod{}, func(obj interface{}) (interface{}, error) {
+	err := r.pods.AtomicUpdate(podID, &api.Pod{}, func(obj interface{}) (interface{}, error) {
 		pod, ok := obj.(*api.Pod)
 		if !ok {
 			return nil, fmt.Errorf("unexpected object: %#v", obj)
 		}
+		if pod.ID == "" {
+			return nil, apiserver.NewNotFoundErr("pod", podID)
+		}
 		if pod.DesiredState.Host != "" {
-			return nil, fmt.Errorf("pod %v is already assigned to host %v", pod.ID, pod.DesiredState.Host)
+			if pod.DesiredState.Host == machine {
+				return pod, nil
+			}
+			return nil, apiserver.NewConflictErr("pod", podID, fmt.Errorf("already assigned to host %v", pod.DesiredState.Host))
 		}
 		pod.DesiredState.Host = machine
 		finalPod = pod
 		return pod, nil
 	})
-	if err != nil {
+	if err != nil || finalPod == nil {
 		return err
 	}
 	// TODO: move this to a watch/rectification loop.
 	manifest, err := r.manifestFactory.MakeManifest(machine, *finalPod)
-	if err != nil {
-		return err
+	if err == nil {
+		err = r.manifests.AtomicUpdate(machine, &api.ContainerManifestList{}, func(in interface{}) (interface{}, error) {
+			manifests := *in.(*api.ContainerManifestList)
+			if err := checkHostPorts(manifests.Items, manifest); err != nil {
+				return nil, apiserver.NewConflictErr("pod", podID, fmt.Errorf("can't run on host %v: %v", machine, err))
+			}
+			manifests.Items = append(manifests.Items, manifest)
+			return manifests, nil
+		})
 	}
-	contKey := makeContainerKey(machine)
-	err = r.AtomicUpdate(contKey, &api.ContainerManifestList{}, func(in interface{}) (interface{}, error) {
-		manifests := *in.(*api.ContainerManifestList)
-		manifests.Items = append(manifests.Items, manifest)
-		return manifests, nil
-	})
 	if err != nil {
-		// Don't strand stuff. This is a terrible hack that won't be needed
-		// when the above TODO is fixed.
-		err2 := r.Delete(podKey, false)
+		err2 := r.pods.AtomicUpdate(podID, &api.Pod{}, func(obj interface{}) (interface{}, error) {
+			pod := obj.(*api.Pod)
+			if pod.ID == "" || pod.DesiredState.Host != machine {
+				return nil, fmt.Errorf("pod %v changed while being assigned", podID)
+			}
+			pod.DesiredState.Host = ""
+			return pod, nil
+		})
 		if err2 != nil {
-			glog.Errorf("Probably stranding a pod, couldn't delete %v: %#v", podKey, err2)
+			glog.Errorf("Couldn't undo assignment of pod %v to %v: %v", podID, machine, err2)
 		}
 	}
 	return err
 }
 
+// UpdatePod updates the labels of an existing pod. The rest of a pod can't
+// change, since its host runs it from the manifest made when it was assigned, so
+// an update which changes anything else is invalid. Current state is reported
+// by the kubelets rather than set by clients, and is ignored. If pod has a
+// resourceVersion, the stored pod must still be at it.
 func (r *Registry) UpdatePod(pod api.Pod) error {
-	return fmt.Errorf("unimplemented!")
+	return r.pods.AtomicUpdate(pod.ID, &api.Pod{}, func(obj interface{}) (interface{}, error) {
+		current := obj.(*api.Pod)
+		if current.ID == "" {
+			return nil, apiserver.NewNotFoundErr("pod", pod.ID)
+		}
+		if pod.ResourceVersion != 0 && pod.ResourceVersion != current.ResourceVersion {
+			return nil, apiserver.NewConflictErr("pod", pod.ID, fmt.Errorf("resourceVersion %d is out of date", pod.ResourceVersion))
+		}
+		if allErrs := validatePodUpdate(current, &pod); len(allErrs) > 0 {
+			return nil, apiserver.NewInvalidErr("pod", pod.ID, allErrs)
+		}
+		current.Labels = pod.Labels
+		return current, nil
+	})
+}
+
+// validatePodUpdate returns an error for each field, other than the labels, in
+// which pod differs from current.
+func validatePodUpdate(current, pod *api.Pod) errs.ErrorList {
+	allErrs := errs.ErrorList{}
+	if !reflect.DeepEqual(current.DesiredState.Manifest, pod.DesiredState.Manifest) {
+		allErrs = append(allErrs, errs.NewInvalid("Pod.DesiredState.Manifest", pod.DesiredState.Manifest))
+	}
+	if current.DesiredState.Host != pod.DesiredState.Host {
+		allErrs = append(allErrs, errs.NewInvalid("Pod.DesiredState.Host", pod.DesiredState.Host))
+	}
+	if !reflect.DeepEqual(current.NodeSelector, pod.NodeSelector) {
+		allErrs = append(allErrs, errs.NewInvalid("Pod.NodeSelector", pod.NodeSelector))
+	}
+	if !reflect.DeepEqual(current.Annotations, pod.Annotations) {
+		allErrs = append(allErrs, errs.NewInvalid("Pod.Annotations", pod.Annotations))
+	}
+	return allErrs
 }
 
 // DeletePod deletes an existing pod specified by its ID.
 func (r *Registry) DeletePod(podID string) error {
 	var pod api.Pod
-	podKey := makePodKey(podID)
-	err := r.ExtractObj(podKey, &pod, false)
-	if tools.IsEtcdNotFound(err) {
-		return apiserver.NewNotFoundErr("pod", podID)
-	}
-	if err != nil {
+	if err := r.pods.Get(podID, &pod); err != nil {
 		return err
 	}
 	// First delete the pod, so a scheduler doesn't notice it getting removed from the
 	// machine and attempt to put it somewhere.
-	err = r.Delete(podKey, true)
-	if tools.IsEtcdNotFound(err) {
-		return apiserver.NewNotFoundErr("pod", podID)
-	}
-	if err != nil {
+	if err := r.pods.Delete(podID, true); err != nil {
 		return err
 	}
 	machine := pod.DesiredState.Host
@@ -191,8 +451,13 @@ func (r *Registry) DeletePod(podID string) error {
 		return nil
 	}
 	// Next, remove the pod from the machine atomically.
-	contKey := makeContainerKey(machine)
-	return r.AtomicUpdate(contKey, &api.ContainerManifestList{}, func(in interface{}) (interface{}, error) {
+	return r.removeManifest(machine, podID)
+}
+
+// removeManifest atomically removes the manifest of podID from machine's
+// manifest list, leaving the others in place.
+func (r *Registry) removeManifest(machine, podID string) error {
+	return r.manifests.AtomicUpdate(machine, &api.ContainerManifestList{}, func(in interface{}) (interface{}, error) {
 		manifests := in.(*api.ContainerManifestList)
 		newManifests := make([]api.ContainerManifest, 0, len(manifests.Items))
 		found := false
@@ -214,31 +479,23 @@ func (r *Registry) DeletePod(podID string) error {
 	})
 }
 
-// ListControllers obtains a list of ReplicationControllers.
-func (r *Registry) ListControllers() ([]api.ReplicationController, error) {
-	var controllers []api.ReplicationController
-	err := r.ExtractList("/registry/controllers", &controllers)
-	return controllers, err
+// ListControllers obtains a list of ReplicationControllers. The list's
+// resourceVersion is the version it was read at.
+func (r *Registry) ListControllers() (api.ReplicationControllerList, error) {
+	var list api.ReplicationControllerList
+	err := r.controllers.List(&list.Items, &list.ResourceVersion)
+	return list, err
 }
 
 // WatchControllers begins watching for new, changed, or deleted controllers.
 func (r *Registry) WatchControllers(resourceVersion uint64) (watch.Interface, error) {
-	return r.WatchList("/registry/controllers", resourceVersion, tools.Everything)
-}
-
-func makeControllerKey(id string) string {
-	return "/registry/controllers/" + id
+	return r.controllers.Watch(resourceVersion, tools.Everything)
 }
 
 // GetController gets a specific ReplicationController specified by its ID.
 func (r *Registry) GetController(controllerID string) (*api.ReplicationController, error) {
 	var controller api.ReplicationController
-	key := makeControllerKey(controllerID)
-	err := r.ExtractObj(key, &controller, false)
-	if tools.IsEtcdNotFound(err) {
-		return nil, apiserver.NewNotFoundErr("replicationController", controllerID)
-	}
-	if err != nil {
+	if err := r.controllers.Get(controllerID, &controller); err != nil {
 		return nil, err
 	}
 	return &controller, nil
@@ -246,93 +503,169 @@ func (r *Registry) GetController(controllerID string) (*api.ReplicationControlle
 
 // CreateController creates a new ReplicationController.
 func (r *Registry) CreateController(controller api.ReplicationController) error {
-	err := r.CreateObj(makeControllerKey(controller.ID), controller)
-	if tools.IsEtcdNodeExist(err) {
-		return apiserver.NewAlreadyExistsErr("replicationController", controller.ID)
-	}
-	return err
+	return r.controllers.Create(controller.ID, controller)
 }
 
-// UpdateController replaces an existing ReplicationController.
+// UpdateController replaces an existing ReplicationController, which must still
+// have controller's resourceVersion.
 func (r *Registry) UpdateController(controller api.ReplicationController) error {
-	return r.SetObj(makeControllerKey(controller.ID), controller)
+	return r.controllers.Update(controller.ID, controller)
 }
 
 // DeleteController deletes a ReplicationController specified by its ID.
 func (r *Registry) DeleteController(controllerID string) error {
-	key := makeControllerKey(controllerID)
-	err := r.Delete(key, false)
-	if tools.IsEtcdNotFound(err) {
-		return apiserver.NewNotFoundErr("replicationController", controllerID)
+	return r.controllers.Delete(controllerID, false)
+}
+
+// ListDaemons obtains a list of DaemonControllers. The list's resourceVersion
+// is the version it was read at.
+func (r *Registry) ListDaemons() (api.DaemonControllerList, error) {
+	var list api.DaemonControllerList
+	err := r.daemons.List(&list.Items, &list.ResourceVersion)
+	return list, err
+}
+
+// WatchDaemons begins watching for new, changed, or deleted daemon controllers.
+func (r *Registry) WatchDaemons(resourceVersion uint64) (watch.Interface, error) {
+	return r.daemons.Watch(resourceVersion, tools.Everything)
+}
+
+// GetDaemon gets a specific DaemonController specified by its ID.
+func (r *Registry) GetDaemon(daemonID string) (*api.DaemonController, error) {
+	var daemon api.DaemonController
+	if err := r.daemons.Get(daemonID, &daemon); err != nil {
+		return nil, err
 	}
-	return err
+	return &daemon, nil
+}
+
+// CreateDaemon creates a new DaemonController.
+func (r *Registry) CreateDaemon(daemon api.DaemonController) error {
+	return r.daemons.Create(daemon.ID, daemon)
 }
 
-func makeServiceKey(name string) string {
-	return "/registry/services/specs/" + name
+// UpdateDaemon replaces an existing DaemonController, which must still have
+// daemon's resourceVersion.
+func (r *Registry) UpdateDaemon(daemon api.DaemonController) error {
+	return r.daemons.Update(daemon.ID, daemon)
 }
 
-// ListServices obtains a list of Services.
+// DeleteDaemon deletes a DaemonController specified by its ID.
+func (r *Registry) DeleteDaemon(daemonID string) error {
+	return r.daemons.Delete(daemonID, false)
+}
+
+// ListServices obtains a list of Services. The list's resourceVersion is the
+// version it was read at.
 func (r *Registry) ListServices() (api.ServiceList, error) {
 	var list api.ServiceList
-	err := r.ExtractList("/registry/services/specs", &list.Items)
+	err := r.services.List(&list.Items, &list.ResourceVersion)
 	return list, err
 }
 
 // CreateService creates a new Service.
 func (r *Registry) CreateService(svc api.Service) error {
-	err := r.CreateObj(makeServiceKey(svc.ID), svc)
-	if tools.IsEtcdNodeExist(err) {
-		return apiserver.NewAlreadyExistsErr("service", svc.ID)
-	}
-	return err
+	return r.services.Create(svc.ID, svc)
 }
 
 // GetService obtains a Service specified by its name.
 func (r *Registry) GetService(name string) (*api.Service, error) {
-	key := makeServiceKey(name)
 	var svc api.Service
-	err := r.ExtractObj(key, &svc, false)
-	if tools.IsEtcdNotFound(err) {
-		return nil, apiserver.NewNotFoundErr("service", name)
-	}
-	if err != nil {
+	if err := r.services.Get(name, &svc); err != nil {
 		return nil, err
 	}
 	return &svc, nil
 }
 
-func makeServiceEndpointsKey(name string) string {
-	return "/registry/services/endpoints/" + name
-}
-
 // DeleteService deletes a Service specified by its name.
 func (r *Registry) DeleteService(name string) error {
-	key := makeServiceKey(name)
-	err := r.Delete(key, true)
-	if tools.IsEtcdNotFound(err) {
-		return apiserver.NewNotFoundErr("service", name)
-	}
-	if err != nil {
+	if err := r.services.Delete(name, true); err != nil {
 		return err
 	}
-	key = makeServiceEndpointsKey(name)
-	err = r.Delete(key, true)
-	if !tools.IsEtcdNotFound(err) {
+	err := r.endpoints.Delete(name, true)
+	if !apiserver.IsNotFound(err) {
 		return err
 	}
 	return nil
 }
 
-// UpdateService replaces an existing Service.
+// UpdateService replaces an existing Service, which must still have svc's resourceVersion.
 func (r *Registry) UpdateService(svc api.Service) error {
-	return r.SetObj(makeServiceKey(svc.ID), svc)
+	return r.services.Update(svc.ID, svc)
+}
+
+// ListEndpoints obtains the Endpoints of every Service.
+func (r *Registry) ListEndpoints() (api.EndpointsList, error) {
+	var list api.EndpointsList
+	err := r.endpoints.List(&list.Items, &list.ResourceVersion)
+	return list, err
 }
 
-// UpdateEndpoints update Endpoints of a Service.
+// GetEndpoints obtains the Endpoints of the Service specified by its name.
+func (r *Registry) GetEndpoints(name string) (*api.Endpoints, error) {
+	var endpoints api.Endpoints
+	if err := r.endpoints.Get(name, &endpoints); err != nil {
+		return nil, err
+	}
+	return &endpoints, nil
+}
+
+// UpdateEndpoints update Endpoints of a Service. e must carry the resourceVersion
+// of the Endpoints it replaces, or none if the Service has no Endpoints yet.
 func (r *Registry) UpdateEndpoints(e api.Endpoints) error {
-	return r.AtomicUpdate(makeServiceEndpointsKey(e.ID), &api.Endpoints{},
-		func(interface{}) (interface{}, error) {
-			return e, nil
-		})
+	return r.endpoints.Update(e.ID, e)
+}
+
+// ListOperations obtains the records of all operations.
+func (r *Registry) ListOperations() ([]api.ServerOp, error) {
+	var ops []api.ServerOp
+	err := r.operations.List(&ops, nil)
+	return ops, err
+}
+
+// GetOperation gets the record of the operation specified by its ID.
+func (r *Registry) GetOperation(id string) (*api.ServerOp, error) {
+	var op api.ServerOp
+	if err := r.operations.Get(id, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// SaveOperation records an operation. etcd removes the record after ttl.
+func (r *Registry) SaveOperation(op *api.ServerOp, ttl time.Duration) error {
+	seconds := uint64(ttl / time.Second)
+	if ttl > 0 && seconds == 0 {
+		seconds = 1
+	}
+	start := time.Now()
+	err := r.OverwriteObj(r.operationStore.Key(op.ID), op, seconds)
+	storage.RecordCall(metrics.Default, "operation", "SaveOperation", start, err)
+	return err
+}
+
+// ListEvents obtains a list of all events.
+func (r *Registry) ListEvents() (api.EventList, error) {
+	list := api.EventList{}
+	err := r.events.List(&list.Items, &list.ResourceVersion)
+	return list, err
+}
+
+// GetEvent gets the event specified by its ID.
+func (r *Registry) GetEvent(id string) (*api.Event, error) {
+	var event api.Event
+	if err := r.events.Get(id, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// CreateEvent records an event, whose ID must not be in use yet. etcd removes it
+// after ttl.
+func (r *Registry) CreateEvent(event api.Event, ttl time.Duration) error {
+	seconds := uint64(ttl / time.Second)
+	if ttl > 0 && seconds == 0 {
+		seconds = 1
+	}
+	return r.events.CreateWithTTL(event.ID, &event, seconds)
 }
@@ -18,13 +18,15 @@ package etcd
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/minion"
-	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/registrytest"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/storage"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
 
 	"github.com/coreos/go-etcd/etcd"
@@ -32,15 +34,13 @@ import (
 
 func NewTestEtcdRegistry(client tools.EtcdClient, machines []string) *Registry {
 	registry := NewRegistry(client, minion.NewRegistry(machines))
-	registry.manifestFactory = &BasicManifestFactory{
-		serviceRegistry: &registrytest.ServiceRegistry{},
-	}
+	registry.manifestFactory = &BasicManifestFactory{}
 	return registry
 }
 
 func TestEtcdGetPod(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	fakeClient.Set("/registry/pods/foo", api.EncodeOrDie(api.Pod{JSONBase: api.JSONBase{ID: "foo"}}), 0)
+	fakeClient.Set("/registry/pods/default/foo", api.EncodeOrDie(api.Pod{JSONBase: api.JSONBase{ID: "foo"}}), 0)
 	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
 	pod, err := registry.GetPod("foo")
 	if err != nil {
@@ -54,7 +54,7 @@ func TestEtcdGetPod(t *testing.T) {
 
 func TestEtcdGetPodNotFound(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	fakeClient.Data["/registry/pods/foo"] = tools.EtcdResponseWithError{
+	fakeClient.Data["/registry/pods/default/foo"] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: nil,
 		},
@@ -67,10 +67,64 @@ func TestEtcdGetPodNotFound(t *testing.T) {
 	}
 }
 
+func TestEtcdCreatePodEncrypted(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	fakeClient.ExpectNotFoundGet("/registry/pods/default/foo")
+	fakeClient.ExpectNotFoundGet("/registry/services/specs/default")
+	fakeClient.Set("/registry/hosts/machine/kubelet", api.EncodeOrDie(&api.ContainerManifestList{}), 0)
+	codec, err := tools.NewEncryptingCodec(api.Codec, []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	registry := NewRegistryWithCodec(fakeClient, codec, minion.NewRegistry([]string{"machine"}))
+	pod := api.Pod{
+		JSONBase: api.JSONBase{ID: "foo"},
+		DesiredState: api.PodState{
+			Manifest: api.ContainerManifest{ID: "foo", Containers: []api.Container{{Name: "foo"}}},
+		},
+	}
+	if err := registry.CreatePod("machine", pod); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, _ := fakeClient.Get("/registry/pods/default/foo", false, false)
+	if strings.Contains(resp.Node.Value, `"manifest"`) {
+		t.Errorf("expected the pod to be stored encrypted: %s", resp.Node.Value)
+	}
+	if got, err := registry.GetPod("foo"); err != nil || got.ID != "foo" {
+		t.Errorf("unexpected pod %#v (%v)", got, err)
+	}
+	// Kubelets read the manifests, so they stay readable.
+	var manifests api.ContainerManifestList
+	resp, _ = fakeClient.Get("/registry/hosts/machine/kubelet", false, false)
+	if err := api.DecodeInto([]byte(resp.Node.Value), &manifests); err != nil || len(manifests.Items) != 1 {
+		t.Errorf("unexpected manifests %#v (%v)", manifests, err)
+	}
+
+	// So do services and endpoints, which proxies read.
+	if err := registry.CreateService(api.Service{JSONBase: api.JSONBase{ID: "foo"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var service api.Service
+	resp, _ = fakeClient.Get("/registry/services/specs/default/foo", false, false)
+	if err := api.DecodeInto([]byte(resp.Node.Value), &service); err != nil || service.ID != "foo" {
+		t.Errorf("unexpected service %#v (%v)", service, err)
+	}
+	if err := registry.UpdateEndpoints(api.Endpoints{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"1.2.3.4:80"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var endpoints api.Endpoints
+	resp, _ = fakeClient.Get("/registry/services/endpoints/default/foo", false, false)
+	if err := api.DecodeInto([]byte(resp.Node.Value), &endpoints); err != nil || len(endpoints.Endpoints) != 1 {
+		t.Errorf("unexpected endpoints %#v (%v)", endpoints, err)
+	}
+}
+
 func TestEtcdCreatePod(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
 	fakeClient.TestIndex = true
-	fakeClient.Data["/registry/pods/foo"] = tools.EtcdResponseWithError{
+	fakeClient.Data["/registry/pods/default/foo"] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: nil,
 		},
@@ -96,7 +150,7 @@ func TestEtcdCreatePod(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	resp, err := fakeClient.Get("/registry/pods/foo", false, false)
+	resp, err := fakeClient.Get("/registry/pods/default/foo", false, false)
 	if err != nil {
 		t.Fatalf("Unexpected error %v", err)
 	}
@@ -121,9 +175,172 @@ func TestEtcdCreatePod(t *testing.T) {
 	}
 }
 
+func TestEtcdCreatePodUnassigned(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	fakeClient.Data["/registry/pods/default/foo"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: nil,
+		},
+		E: tools.EtcdErrorNotFound,
+	}
+	fakeClient.Set("/registry/hosts/machine/kubelet", api.EncodeOrDie(&api.ContainerManifestList{}), 0)
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+	err := registry.CreatePod("", api.Pod{
+		JSONBase: api.JSONBase{
+			ID: "foo",
+		},
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	resp, err := fakeClient.Get("/registry/pods/default/foo", false, false)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	var pod api.Pod
+	err = api.DecodeInto([]byte(resp.Node.Value), &pod)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if pod.ID != "foo" || pod.DesiredState.Host != "" {
+		t.Errorf("Unexpected pod: %#v %s", pod, resp.Node.Value)
+	}
+
+	var manifests api.ContainerManifestList
+	resp, err = fakeClient.Get("/registry/hosts/machine/kubelet", false, false)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = api.DecodeInto([]byte(resp.Node.Value), &manifests)
+	if len(manifests.Items) != 0 {
+		t.Errorf("Unexpected manifest list: %#v", manifests)
+	}
+}
+
+func TestEtcdApplyBinding(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	fakeClient.Set("/registry/pods/default/foo", api.EncodeOrDie(api.Pod{
+		JSONBase:     api.JSONBase{ID: "foo"},
+		DesiredState: api.PodState{Manifest: api.ContainerManifest{ID: "foo"}},
+	}), 0)
+	fakeClient.Set("/registry/hosts/machine/kubelet", api.EncodeOrDie(&api.ContainerManifestList{}), 0)
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+
+	if err := registry.ApplyBinding(&api.Binding{PodID: "foo", Host: "machine"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod, err := registry.GetPod("foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pod.DesiredState.Host != "machine" {
+		t.Errorf("expected pod to be bound to machine, got %#v", pod)
+	}
+
+	// Binding again to the same host is harmless.
+	if err := registry.ApplyBinding(&api.Binding{PodID: "foo", Host: "machine"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	var manifests api.ContainerManifestList
+	resp, err := fakeClient.Get("/registry/hosts/machine/kubelet", false, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	api.DecodeInto([]byte(resp.Node.Value), &manifests)
+	if len(manifests.Items) != 1 || manifests.Items[0].ID != "foo" {
+		t.Errorf("Unexpected manifest list: %#v", manifests)
+	}
+
+	// Binding to another host is a conflict.
+	err = registry.ApplyBinding(&api.Binding{PodID: "foo", Host: "other"})
+	if !apiserver.IsConflict(err) {
+		t.Errorf("expected conflict, got %#v", err)
+	}
+}
+
+func TestEtcdApplyBindingPodNotFound(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	fakeClient.ExpectNotFoundGet("/registry/pods/default/foo")
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+
+	err := registry.ApplyBinding(&api.Binding{PodID: "foo", Host: "machine"})
+	if !apiserver.IsNotFound(err) {
+		t.Errorf("expected not found, got %#v", err)
+	}
+	if _, err := fakeClient.Get("/registry/pods/default/foo", false, false); !tools.IsEtcdNotFound(err) {
+		t.Errorf("binding should not create a pod, got %v", err)
+	}
+}
+
+func TestEtcdApplyBindingWithContainersError(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	fakeClient.Set("/registry/pods/default/foo", api.EncodeOrDie(api.Pod{JSONBase: api.JSONBase{ID: "foo"}}), 0)
+	fakeClient.Data["/registry/hosts/machine/kubelet"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: nil,
+		},
+		E: tools.EtcdErrorValueRequired,
+	}
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+
+	if err := registry.ApplyBinding(&api.Binding{PodID: "foo", Host: "machine"}); err == nil {
+		t.Fatalf("Unexpected non-error")
+	}
+	pod, err := registry.GetPod("foo")
+	if err != nil {
+		t.Fatalf("expected pod to survive a failed binding: %v", err)
+	}
+	if pod.DesiredState.Host != "" {
+		t.Errorf("expected binding to be undone, got %#v", pod)
+	}
+}
+
+func TestEtcdApplyBindingHostPortConflict(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	ports := []api.Container{{Ports: []api.Port{{ContainerPort: 80, HostPort: 8080}}}}
+	fakeClient.Set("/registry/pods/default/foo", api.EncodeOrDie(api.Pod{
+		JSONBase:     api.JSONBase{ID: "foo"},
+		DesiredState: api.PodState{Manifest: api.ContainerManifest{ID: "foo", Containers: ports}},
+	}), 0)
+	fakeClient.Set("/registry/hosts/machine/kubelet", api.EncodeOrDie(&api.ContainerManifestList{
+		Items: []api.ContainerManifest{{ID: "bar", Containers: ports}},
+	}), 0)
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+
+	err := registry.ApplyBinding(&api.Binding{PodID: "foo", Host: "machine"})
+	if !apiserver.IsConflict(err) {
+		t.Fatalf("expected conflict, got %#v", err)
+	}
+	pod, err := registry.GetPod("foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pod.DesiredState.Host != "" {
+		t.Errorf("expected binding to be undone, got %#v", pod)
+	}
+}
+
+func TestCheckHostPorts(t *testing.T) {
+	onHost := []api.ContainerManifest{{Containers: []api.Container{{Ports: []api.Port{{HostPort: 53, Protocol: "UDP"}}}}}}
+	tcp := api.ContainerManifest{Containers: []api.Container{{Ports: []api.Port{{HostPort: 53}}}}}
+	if err := checkHostPorts(onHost, tcp); err != nil {
+		t.Errorf("expected TCP and UDP ports not to collide, got %v", err)
+	}
+	udp := api.ContainerManifest{Containers: []api.Container{{Ports: []api.Port{{HostPort: 53, Protocol: "udp"}}}}}
+	if err := checkHostPorts(onHost, udp); err == nil {
+		t.Errorf("expected the UDP ports to collide")
+	}
+}
+
 func TestEtcdCreatePodAlreadyExisting(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	fakeClient.Data["/registry/pods/foo"] = tools.EtcdResponseWithError{
+	fakeClient.Data["/registry/pods/default/foo"] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: &etcd.Node{
 				Value: api.EncodeOrDie(api.Pod{JSONBase: api.JSONBase{ID: "foo"}}),
@@ -145,7 +362,7 @@ func TestEtcdCreatePodAlreadyExisting(t *testing.T) {
 func TestEtcdCreatePodWithContainersError(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
 	fakeClient.TestIndex = true
-	fakeClient.Data["/registry/pods/foo"] = tools.EtcdResponseWithError{
+	fakeClient.Data["/registry/pods/default/foo"] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: nil,
 		},
@@ -166,7 +383,7 @@ func TestEtcdCreatePodWithContainersError(t *testing.T) {
 	if err == nil {
 		t.Fatalf("Unexpected non-error")
 	}
-	_, err = fakeClient.Get("/registry/pods/foo", false, false)
+	_, err = fakeClient.Get("/registry/pods/default/foo", false, false)
 	if err == nil {
 		t.Error("Unexpected non-error")
 	}
@@ -178,7 +395,7 @@ func TestEtcdCreatePodWithContainersError(t *testing.T) {
 func TestEtcdCreatePodWithContainersNotFound(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
 	fakeClient.TestIndex = true
-	fakeClient.Data["/registry/pods/foo"] = tools.EtcdResponseWithError{
+	fakeClient.Data["/registry/pods/default/foo"] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: nil,
 		},
@@ -210,7 +427,7 @@ func TestEtcdCreatePodWithContainersNotFound(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	resp, err := fakeClient.Get("/registry/pods/foo", false, false)
+	resp, err := fakeClient.Get("/registry/pods/default/foo", false, false)
 	if err != nil {
 		t.Fatalf("Unexpected error %v", err)
 	}
@@ -238,7 +455,7 @@ func TestEtcdCreatePodWithContainersNotFound(t *testing.T) {
 func TestEtcdCreatePodWithExistingContainers(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
 	fakeClient.TestIndex = true
-	fakeClient.Data["/registry/pods/foo"] = tools.EtcdResponseWithError{
+	fakeClient.Data["/registry/pods/default/foo"] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: nil,
 		},
@@ -269,7 +486,7 @@ func TestEtcdCreatePodWithExistingContainers(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	resp, err := fakeClient.Get("/registry/pods/foo", false, false)
+	resp, err := fakeClient.Get("/registry/pods/default/foo", false, false)
 	if err != nil {
 		t.Fatalf("Unexpected error %v", err)
 	}
@@ -298,7 +515,7 @@ func TestEtcdDeletePod(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
 	fakeClient.TestIndex = true
 
-	key := "/registry/pods/foo"
+	key := "/registry/pods/default/foo"
 	fakeClient.Set(key, api.EncodeOrDie(api.Pod{
 		JSONBase:     api.JSONBase{ID: "foo"},
 		DesiredState: api.PodState{Host: "machine"},
@@ -334,7 +551,7 @@ func TestEtcdDeletePodMultipleContainers(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
 	fakeClient.TestIndex = true
 
-	key := "/registry/pods/foo"
+	key := "/registry/pods/default/foo"
 	fakeClient.Set(key, api.EncodeOrDie(api.Pod{
 		JSONBase:     api.JSONBase{ID: "foo"},
 		DesiredState: api.PodState{Host: "machine"},
@@ -373,7 +590,7 @@ func TestEtcdDeletePodMultipleContainers(t *testing.T) {
 
 func TestEtcdEmptyListPods(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	key := "/registry/pods"
+	key := "/registry/pods/default"
 	fakeClient.Data[key] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: &etcd.Node{
@@ -388,14 +605,14 @@ func TestEtcdEmptyListPods(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	if len(pods) != 0 {
+	if len(pods.Items) != 0 {
 		t.Errorf("Unexpected pod list: %#v", pods)
 	}
 }
 
 func TestEtcdListPodsNotFound(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	key := "/registry/pods"
+	key := "/registry/pods/default"
 	fakeClient.Data[key] = tools.EtcdResponseWithError{
 		R: &etcd.Response{},
 		E: tools.EtcdErrorNotFound,
@@ -406,16 +623,17 @@ func TestEtcdListPodsNotFound(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	if len(pods) != 0 {
+	if len(pods.Items) != 0 {
 		t.Errorf("Unexpected pod list: %#v", pods)
 	}
 }
 
 func TestEtcdListPods(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	key := "/registry/pods"
+	key := "/registry/pods/default"
 	fakeClient.Data[key] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
+			EtcdIndex: 10,
 			Node: &etcd.Node{
 				Nodes: []*etcd.Node{
 					{
@@ -441,18 +659,21 @@ func TestEtcdListPods(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	if len(pods) != 2 || pods[0].ID != "foo" || pods[1].ID != "bar" {
-		t.Errorf("Unexpected pod list: %#v", pods)
+	if len(pods.Items) != 2 || pods.Items[0].ID != "foo" || pods.Items[1].ID != "bar" {
+		t.Fatalf("Unexpected pod list: %#v", pods)
+	}
+	if pods.ResourceVersion != 10 {
+		t.Errorf("Expected the list at resourceVersion 10, got %v", pods.ResourceVersion)
 	}
-	if pods[0].CurrentState.Host != "machine" ||
-		pods[1].CurrentState.Host != "machine" {
+	if pods.Items[0].CurrentState.Host != "machine" ||
+		pods.Items[1].CurrentState.Host != "machine" {
 		t.Errorf("Failed to populate host name.")
 	}
 }
 
 func TestEtcdListControllersNotFound(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	key := "/registry/controllers"
+	key := "/registry/controllers/default"
 	fakeClient.Data[key] = tools.EtcdResponseWithError{
 		R: &etcd.Response{},
 		E: tools.EtcdErrorNotFound,
@@ -463,14 +684,14 @@ func TestEtcdListControllersNotFound(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	if len(controllers) != 0 {
+	if len(controllers.Items) != 0 {
 		t.Errorf("Unexpected controller list: %#v", controllers)
 	}
 }
 
 func TestEtcdListServicesNotFound(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	key := "/registry/services/specs"
+	key := "/registry/services/specs/default"
 	fakeClient.Data[key] = tools.EtcdResponseWithError{
 		R: &etcd.Response{},
 		E: tools.EtcdErrorNotFound,
@@ -488,9 +709,10 @@ func TestEtcdListServicesNotFound(t *testing.T) {
 
 func TestEtcdListControllers(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	key := "/registry/controllers"
+	key := "/registry/controllers/default"
 	fakeClient.Data[key] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
+			EtcdIndex: 12,
 			Node: &etcd.Node{
 				Nodes: []*etcd.Node{
 					{
@@ -510,14 +732,17 @@ func TestEtcdListControllers(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	if len(controllers) != 2 || controllers[0].ID != "foo" || controllers[1].ID != "bar" {
+	if len(controllers.Items) != 2 || controllers.Items[0].ID != "foo" || controllers.Items[1].ID != "bar" {
 		t.Errorf("Unexpected controller list: %#v", controllers)
 	}
+	if controllers.ResourceVersion != 12 {
+		t.Errorf("Expected the list at resourceVersion 12, got %v", controllers.ResourceVersion)
+	}
 }
 
 func TestEtcdGetController(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	fakeClient.Set("/registry/controllers/foo", api.EncodeOrDie(api.ReplicationController{JSONBase: api.JSONBase{ID: "foo"}}), 0)
+	fakeClient.Set("/registry/controllers/default/foo", api.EncodeOrDie(api.ReplicationController{JSONBase: api.JSONBase{ID: "foo"}}), 0)
 	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
 	ctrl, err := registry.GetController("foo")
 	if err != nil {
@@ -531,7 +756,7 @@ func TestEtcdGetController(t *testing.T) {
 
 func TestEtcdGetControllerNotFound(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	fakeClient.Data["/registry/controllers/foo"] = tools.EtcdResponseWithError{
+	fakeClient.Data["/registry/controllers/default/foo"] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: nil,
 		},
@@ -558,7 +783,7 @@ func TestEtcdDeleteController(t *testing.T) {
 	if len(fakeClient.DeletedKeys) != 1 {
 		t.Errorf("Expected 1 delete, found %#v", fakeClient.DeletedKeys)
 	}
-	key := "/registry/controllers/foo"
+	key := "/registry/controllers/default/foo"
 	if fakeClient.DeletedKeys[0] != key {
 		t.Errorf("Unexpected key: %s, expected %s", fakeClient.DeletedKeys[0], key)
 	}
@@ -576,7 +801,7 @@ func TestEtcdCreateController(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	resp, err := fakeClient.Get("/registry/controllers/foo", false, false)
+	resp, err := fakeClient.Get("/registry/controllers/default/foo", false, false)
 	if err != nil {
 		t.Fatalf("Unexpected error %v", err)
 	}
@@ -593,7 +818,7 @@ func TestEtcdCreateController(t *testing.T) {
 
 func TestEtcdCreateControllerAlreadyExisting(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	fakeClient.Set("/registry/controllers/foo", api.EncodeOrDie(api.ReplicationController{JSONBase: api.JSONBase{ID: "foo"}}), 0)
+	fakeClient.Set("/registry/controllers/default/foo", api.EncodeOrDie(api.ReplicationController{JSONBase: api.JSONBase{ID: "foo"}}), 0)
 
 	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
 	err := registry.CreateController(api.ReplicationController{
@@ -610,7 +835,7 @@ func TestEtcdUpdateController(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
 	fakeClient.TestIndex = true
 
-	resp, _ := fakeClient.Set("/registry/controllers/foo", api.EncodeOrDie(api.ReplicationController{JSONBase: api.JSONBase{ID: "foo"}}), 0)
+	resp, _ := fakeClient.Set("/registry/controllers/default/foo", api.EncodeOrDie(api.ReplicationController{JSONBase: api.JSONBase{ID: "foo"}}), 0)
 	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
 	err := registry.UpdateController(api.ReplicationController{
 		JSONBase: api.JSONBase{ID: "foo", ResourceVersion: resp.Node.ModifiedIndex},
@@ -628,9 +853,37 @@ func TestEtcdUpdateController(t *testing.T) {
 	}
 }
 
+func TestEtcdCreateAndListDaemons(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.Data["/registry/daemons/default"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{},
+		E: tools.EtcdErrorNotFound,
+	}
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+	if err := registry.CreateDaemon(api.DaemonController{JSONBase: api.JSONBase{ID: "foo"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := fakeClient.Get("/registry/daemons/default/foo", false, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	daemon, err := registry.GetDaemon("foo")
+	if err != nil || daemon.ID != "foo" {
+		t.Errorf("unexpected daemon %#v, error %v", daemon, err)
+	}
+	if err := registry.CreateDaemon(api.DaemonController{JSONBase: api.JSONBase{ID: "foo"}}); !apiserver.IsAlreadyExists(err) {
+		t.Errorf("expected already exists err, got %#v", err)
+	}
+	if err := registry.DeleteDaemon("foo"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if key := "/registry/daemons/default/foo"; len(fakeClient.DeletedKeys) != 1 || fakeClient.DeletedKeys[0] != key {
+		t.Errorf("expected %s to be deleted, got %v", key, fakeClient.DeletedKeys)
+	}
+}
+
 func TestEtcdListServices(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	key := "/registry/services/specs"
+	key := "/registry/services/specs/default"
 	fakeClient.Data[key] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: &etcd.Node{
@@ -657,6 +910,35 @@ func TestEtcdListServices(t *testing.T) {
 	}
 }
 
+func TestEtcdListEndpoints(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	key := "/registry/services/endpoints/default"
+	fakeClient.Data[key] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Nodes: []*etcd.Node{
+					{
+						Value: api.EncodeOrDie(api.Endpoints{JSONBase: api.JSONBase{ID: "foo"}, Endpoints: []string{"127.0.0.1:8345"}}),
+					},
+					{
+						Value: api.EncodeOrDie(api.Endpoints{JSONBase: api.JSONBase{ID: "bar"}}),
+					},
+				},
+			},
+		},
+		E: nil,
+	}
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+	endpoints, err := registry.ListEndpoints()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if len(endpoints.Items) != 2 || endpoints.Items[0].ID != "foo" || endpoints.Items[1].ID != "bar" {
+		t.Errorf("Unexpected endpoints list: %#v", endpoints)
+	}
+}
+
 func TestEtcdCreateService(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
 	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
@@ -667,7 +949,7 @@ func TestEtcdCreateService(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	resp, err := fakeClient.Get("/registry/services/specs/foo", false, false)
+	resp, err := fakeClient.Get("/registry/services/specs/default/foo", false, false)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -685,7 +967,7 @@ func TestEtcdCreateService(t *testing.T) {
 
 func TestEtcdCreateServiceAlreadyExisting(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	fakeClient.Set("/registry/services/specs/foo", api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "foo"}}), 0)
+	fakeClient.Set("/registry/services/specs/default/foo", api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "foo"}}), 0)
 	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
 	err := registry.CreateService(api.Service{
 		JSONBase: api.JSONBase{ID: "foo"},
@@ -697,7 +979,7 @@ func TestEtcdCreateServiceAlreadyExisting(t *testing.T) {
 
 func TestEtcdGetService(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	fakeClient.Set("/registry/services/specs/foo", api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "foo"}}), 0)
+	fakeClient.Set("/registry/services/specs/default/foo", api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "foo"}}), 0)
 	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
 	service, err := registry.GetService("foo")
 	if err != nil {
@@ -711,7 +993,7 @@ func TestEtcdGetService(t *testing.T) {
 
 func TestEtcdGetServiceNotFound(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
-	fakeClient.Data["/registry/services/specs/foo"] = tools.EtcdResponseWithError{
+	fakeClient.Data["/registry/services/specs/default/foo"] = tools.EtcdResponseWithError{
 		R: &etcd.Response{
 			Node: nil,
 		},
@@ -735,11 +1017,11 @@ func TestEtcdDeleteService(t *testing.T) {
 	if len(fakeClient.DeletedKeys) != 2 {
 		t.Errorf("Expected 2 delete, found %#v", fakeClient.DeletedKeys)
 	}
-	key := "/registry/services/specs/foo"
+	key := "/registry/services/specs/default/foo"
 	if fakeClient.DeletedKeys[0] != key {
 		t.Errorf("Unexpected key: %s, expected %s", fakeClient.DeletedKeys[0], key)
 	}
-	key = "/registry/services/endpoints/foo"
+	key = "/registry/services/endpoints/default/foo"
 	if fakeClient.DeletedKeys[1] != key {
 		t.Errorf("Unexpected key: %s, expected %s", fakeClient.DeletedKeys[1], key)
 	}
@@ -749,7 +1031,7 @@ func TestEtcdUpdateService(t *testing.T) {
 	fakeClient := tools.NewFakeEtcdClient(t)
 	fakeClient.TestIndex = true
 
-	resp, _ := fakeClient.Set("/registry/services/specs/foo", api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "foo"}}), 0)
+	resp, _ := fakeClient.Set("/registry/services/specs/default/foo", api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "foo"}}), 0)
 	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
 	testService := api.Service{
 		JSONBase: api.JSONBase{ID: "foo", ResourceVersion: resp.Node.ModifiedIndex},
@@ -783,18 +1065,18 @@ func TestEtcdUpdateEndpoints(t *testing.T) {
 	fakeClient.TestIndex = true
 	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
 	endpoints := api.Endpoints{
-		JSONBase:  api.JSONBase{ID: "foo"},
+		JSONBase:  api.JSONBase{ID: "foo", ResourceVersion: 1},
 		Endpoints: []string{"baz", "bar"},
 	}
 
-	fakeClient.Set("/registry/services/endpoints/foo", api.EncodeOrDie(api.Endpoints{}), 0)
+	fakeClient.Set("/registry/services/endpoints/default/foo", api.EncodeOrDie(api.Endpoints{}), 0)
 
 	err := registry.UpdateEndpoints(endpoints)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 
-	response, err := fakeClient.Get("/registry/services/endpoints/foo", false, false)
+	response, err := fakeClient.Get("/registry/services/endpoints/default/foo", false, false)
 	if err != nil {
 		t.Fatalf("Unexpected error %v", err)
 	}
@@ -805,6 +1087,24 @@ func TestEtcdUpdateEndpoints(t *testing.T) {
 	}
 }
 
+func TestEtcdUpdateEndpointsConflict(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+	fakeClient.Set("/registry/services/endpoints/default/foo", api.EncodeOrDie(api.Endpoints{}), 0)
+	fakeClient.Set("/registry/services/endpoints/default/foo", api.EncodeOrDie(api.Endpoints{Endpoints: []string{"baz"}}), 0)
+
+	for _, version := range []uint64{0, 1} {
+		err := registry.UpdateEndpoints(api.Endpoints{
+			JSONBase:  api.JSONBase{ID: "foo", ResourceVersion: version},
+			Endpoints: []string{"bar"},
+		})
+		if !apiserver.IsConflict(err) {
+			t.Errorf("version %d: expected conflict error, got %v", version, err)
+		}
+	}
+}
+
 // TODO We need a test for the compare and swap behavior.  This basically requires two things:
 //   1) Add a per-operation synchronization channel to the fake etcd client, such that any operation waits on that
 //      channel, this will enable us to orchestrate the flow of etcd requests in the test.
@@ -819,3 +1119,223 @@ func TestEtcdUpdateEndpoints(t *testing.T) {
 //         Update
 //   In the buggy case, this will result in lost data.  In the correct case, the second update should fail
 //   and be retried.
+
+func TestEtcdSaveOperation(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+	op := api.ServerOp{JSONBase: api.JSONBase{ID: "1"}, Resource: "pods", Status: api.StatusWorking}
+	if err := registry.SaveOperation(&op, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	op.Status = api.StatusSuccess
+	if err := registry.SaveOperation(&op, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := registry.GetOperation("1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Resource != "pods" || got.Status != api.StatusSuccess {
+		t.Errorf("unexpected operation: %#v", got)
+	}
+}
+
+func TestEtcdGetOperationNotFound(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.Data["/registry/operations/1"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: nil,
+		},
+		E: tools.EtcdErrorNotFound,
+	}
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+	_, err := registry.GetOperation("1")
+	if !apiserver.IsNotFound(err) {
+		t.Errorf("expected not found error, got %#v", err)
+	}
+}
+
+func TestEtcdListOperations(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.Data["/registry/operations"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Nodes: []*etcd.Node{
+					{
+						Value: api.EncodeOrDie(api.ServerOp{JSONBase: api.JSONBase{ID: "1"}, Status: api.StatusSuccess}),
+					},
+					{
+						Value: api.EncodeOrDie(api.ServerOp{JSONBase: api.JSONBase{ID: "2"}, Status: api.StatusWorking}),
+					},
+				},
+			},
+		},
+		E: nil,
+	}
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+	ops, err := registry.ListOperations()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ops) != 2 || ops[0].ID != "1" || ops[1].Status != api.StatusWorking {
+		t.Errorf("unexpected operations: %#v", ops)
+	}
+}
+
+func TestEtcdCreateEvent(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+	event := api.Event{
+		JSONBase:       api.JSONBase{ID: "1"},
+		InvolvedObject: api.ObjectReference{Kind: "Pod", ID: "foo"},
+		Reason:         "started",
+	}
+	if err := registry.CreateEvent(event, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := fakeClient.Data["/registry/events/1"]; !ok {
+		t.Errorf("expected the event to be stored, got %#v", fakeClient.Data)
+	}
+
+	got, err := registry.GetEvent("1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.InvolvedObject.ID != "foo" || got.Reason != "started" {
+		t.Errorf("unexpected event: %#v", got)
+	}
+
+	// Events given an ID by the client don't replace others.
+	event.Reason = "killed"
+	if err := registry.CreateEvent(event, time.Hour); !apiserver.IsAlreadyExists(err) {
+		t.Errorf("expected already exists error, got %v", err)
+	}
+	if got, _ := registry.GetEvent("1"); got == nil || got.Reason != "started" {
+		t.Errorf("unexpected event: %#v", got)
+	}
+}
+
+func TestEtcdListEvents(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.Data["/registry/events"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Nodes: []*etcd.Node{
+					{
+						Value: api.EncodeOrDie(api.Event{JSONBase: api.JSONBase{ID: "1"}, Reason: "started"}),
+					},
+					{
+						Value: api.EncodeOrDie(api.Event{JSONBase: api.JSONBase{ID: "2"}, Reason: "killed"}),
+					},
+				},
+			},
+		},
+		E: nil,
+	}
+	registry := NewTestEtcdRegistry(fakeClient, []string{"machine"})
+	list, err := registry.ListEvents()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Items) != 2 || list.Items[0].ID != "1" || list.Items[1].Reason != "killed" {
+		t.Errorf("unexpected events: %#v", list.Items)
+	}
+}
+
+func TestRegistryWithMemoryStorage(t *testing.T) {
+	s := NewMemoryRegistryStorage()
+	registry := NewRegistryWithStorage(nil, s, minion.NewRegistry([]string{"machine"}))
+	registry.manifestFactory = &BasicManifestFactory{}
+
+	pod := api.Pod{
+		JSONBase: api.JSONBase{ID: "foo"},
+		DesiredState: api.PodState{
+			Manifest: api.ContainerManifest{ID: "foo"},
+		},
+	}
+	if err := registry.CreatePod("machine", pod); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := registry.GetPod("foo")
+	if err != nil || got.DesiredState.Host != "machine" {
+		t.Errorf("unexpected pod %#v (%v)", got, err)
+	}
+	var manifests api.ContainerManifestList
+	if err := registry.manifests.Get("machine", &manifests); err != nil || len(manifests.Items) != 1 || manifests.Items[0].ID != "foo" {
+		t.Errorf("unexpected manifests %#v (%v)", manifests, err)
+	}
+	if err := registry.ApplyBinding(&api.Binding{PodID: "foo", Host: "other"}); !apiserver.IsConflict(err) {
+		t.Errorf("expected conflict, got %v", err)
+	}
+	if err := registry.DeletePod("foo"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := registry.manifests.Get("machine", &manifests); err != nil || len(manifests.Items) != 0 {
+		t.Errorf("unexpected manifests %#v (%v)", manifests, err)
+	}
+	if _, err := registry.GetPod("foo"); !apiserver.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	// Binding has to retry when another writer gets to the pod first.
+	if err := registry.CreatePod("", pod); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.Pods.(*storage.Memory).InjectConflicts(2)
+	if err := registry.ApplyBinding(&api.Binding{PodID: "foo", Host: "machine"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	got, err = registry.GetPod("foo")
+	if err != nil || got.DesiredState.Host != "machine" {
+		t.Errorf("unexpected pod %#v (%v)", got, err)
+	}
+	list, err := registry.ListPods(labels.Everything())
+	if err != nil || list.ResourceVersion < got.ResourceVersion {
+		t.Errorf("expected the list to be stamped after the pod, got %#v (%v)", list, err)
+	}
+}
+
+func TestUpdatePodLabels(t *testing.T) {
+	registry := NewRegistryWithStorage(nil, NewMemoryRegistryStorage(), minion.NewRegistry([]string{"machine"}))
+	registry.manifestFactory = &BasicManifestFactory{}
+	if err := registry.CreatePod("machine", api.Pod{JSONBase: api.JSONBase{ID: "foo"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod, err := registry.GetPod("foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Anything but the labels can't change.
+	update := *pod
+	update.Labels = map[string]string{"name": "foo"}
+	update.DesiredState.Host = "other"
+	if err := registry.UpdatePod(update); !apiserver.IsInvalid(err) {
+		t.Errorf("expected invalid error, got %v", err)
+	}
+	update.DesiredState.Host = "machine"
+	update.Annotations = map[string]string{"scheduler": "other"}
+	if err := registry.UpdatePod(update); !apiserver.IsInvalid(err) {
+		t.Errorf("expected invalid error, got %v", err)
+	}
+
+	// Only the labels change. The current state is ignored.
+	update.Annotations = pod.Annotations
+	update.CurrentState.Status = api.PodRunning
+	if err := registry.UpdatePod(update); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := registry.GetPod("foo")
+	if err != nil || !reflect.DeepEqual(got.Labels, update.Labels) || got.CurrentState.Status == api.PodRunning {
+		t.Errorf("unexpected pod %#v (%v)", got, err)
+	}
+
+	// The update was based on an older pod.
+	if err := registry.UpdatePod(update); !apiserver.IsConflict(err) {
+		t.Errorf("expected conflict, got %v", err)
+	}
+	if err := registry.UpdatePod(api.Pod{JSONBase: api.JSONBase{ID: "bar"}}); !apiserver.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
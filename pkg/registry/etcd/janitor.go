@@ -0,0 +1,211 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package etcd
+
+import (
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/minion"
+	"github.com/golang/glog"
+)
+
+// JanitorConfig says which orphaned entries a Janitor removes. An entry is
+// removed once it has been orphaned for longer than the TTL of its kind; a
+// zero TTL leaves that kind alone.
+type JanitorConfig struct {
+	// PodTTL applies to pods assigned to a minion which no longer exists.
+	PodTTL time.Duration
+	// EndpointsTTL applies to endpoints whose service no longer exists.
+	EndpointsTTL time.Duration
+	// OperationTTL applies to operation records which haven't changed. etcd
+	// normally expires them itself; this catches records saved without a TTL
+	// and records of operations which never finished.
+	OperationTTL time.Duration
+	// DryRun, if true, only logs what would be removed.
+	DryRun bool
+}
+
+// Janitor removes registry entries which nothing refers to any more.
+type Janitor struct {
+	registry *Registry
+	minions  minion.Registry
+	config   JanitorConfig
+
+	// orphans maps the key of every orphaned entry seen to the time it was
+	// first seen orphaned, or, for operations, unchanged.
+	orphans map[string]orphan
+	now     func() time.Time
+}
+
+type orphan struct {
+	since           time.Time
+	resourceVersion uint64
+}
+
+// NewJanitor returns a Janitor cleaning registry. Pods are orphaned when their
+// host isn't in minions.
+func NewJanitor(registry *Registry, minions minion.Registry, config JanitorConfig) *Janitor {
+	return &Janitor{
+		registry: registry,
+		minions:  minions,
+		config:   config,
+		orphans:  map[string]orphan{},
+		now:      time.Now,
+	}
+}
+
+// Sweep makes one pass over the registry, removing the entries which have
+// been orphaned for long enough.
+func (j *Janitor) Sweep() error {
+	seen := map[string]bool{}
+	if j.config.PodTTL > 0 {
+		if err := j.sweepPods(seen); err != nil {
+			return err
+		}
+	}
+	if j.config.EndpointsTTL > 0 {
+		if err := j.sweepEndpoints(seen); err != nil {
+			return err
+		}
+	}
+	if j.config.OperationTTL > 0 {
+		if err := j.sweepOperations(seen); err != nil {
+			return err
+		}
+	}
+	// Forget entries which were adopted again or removed by someone else.
+	for key := range j.orphans {
+		if !seen[key] {
+			delete(j.orphans, key)
+		}
+	}
+	return nil
+}
+
+// expired records that the entry at key is orphaned at resourceVersion, and
+// returns true if it has been so for longer than ttl. A new resourceVersion
+// restarts the clock.
+func (j *Janitor) expired(key string, resourceVersion uint64, ttl time.Duration, seen map[string]bool) bool {
+	seen[key] = true
+	now := j.now()
+	o, ok := j.orphans[key]
+	if !ok || o.resourceVersion != resourceVersion {
+		j.orphans[key] = orphan{since: now, resourceVersion: resourceVersion}
+		return false
+	}
+	return now.Sub(o.since) > ttl
+}
+
+// remove deletes the entry at key by calling del, unless in dry-run mode.
+func (j *Janitor) remove(kind, key string, del func() error) error {
+	if j.config.DryRun {
+		glog.Infof("Would remove orphaned %s %s (dry run)", kind, key)
+		return nil
+	}
+	glog.Infof("Removing orphaned %s %s", kind, key)
+	if err := del(); err != nil && !apiserver.IsNotFound(err) {
+		return err
+	}
+	delete(j.orphans, key)
+	return nil
+}
+
+func (j *Janitor) sweepPods(seen map[string]bool) error {
+	machines, err := j.minions.List()
+	if err != nil {
+		return err
+	}
+	exists := map[string]bool{}
+	for _, machine := range machines {
+		exists[machine] = true
+	}
+	var pods []api.Pod
+	if err := j.registry.pods.List(&pods, nil); err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		host := pod.DesiredState.Host
+		if host == "" || exists[host] {
+			continue
+		}
+		key := "pod/" + pod.ID
+		if !j.expired(key, pod.ResourceVersion, j.config.PodTTL, seen) {
+			continue
+		}
+		err := j.remove("pod", key, func() error {
+			if err := j.registry.pods.Delete(pod.ID, true); err != nil {
+				return err
+			}
+			// Other pods may still be listed on the host, so only take this
+			// one out of its manifests.
+			return j.registry.removeManifest(host, pod.ID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *Janitor) sweepEndpoints(seen map[string]bool) error {
+	services, err := j.registry.ListServices()
+	if err != nil {
+		return err
+	}
+	exists := map[string]bool{}
+	for _, service := range services.Items {
+		exists[service.ID] = true
+	}
+	var endpoints []api.Endpoints
+	if err := j.registry.endpoints.List(&endpoints, nil); err != nil {
+		return err
+	}
+	for _, e := range endpoints {
+		if exists[e.ID] {
+			continue
+		}
+		key := "endpoints/" + e.ID
+		if !j.expired(key, e.ResourceVersion, j.config.EndpointsTTL, seen) {
+			continue
+		}
+		id := e.ID
+		if err := j.remove("endpoints", key, func() error { return j.registry.endpoints.Delete(id, false) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *Janitor) sweepOperations(seen map[string]bool) error {
+	ops, err := j.registry.ListOperations()
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		key := "operation/" + op.ID
+		if !j.expired(key, op.ResourceVersion, j.config.OperationTTL, seen) {
+			continue
+		}
+		id := op.ID
+		if err := j.remove("operation", key, func() error { return j.registry.operations.Delete(id, false) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
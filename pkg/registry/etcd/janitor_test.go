@@ -0,0 +1,164 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package etcd
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/minion"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/coreos/go-etcd/etcd"
+)
+
+func setDir(fakeClient *tools.FakeEtcdClient, key string, nodes ...*etcd.Node) {
+	fakeClient.Data[key] = tools.EtcdResponseWithError{
+		R: &etcd.Response{Node: &etcd.Node{Dir: true, Nodes: nodes}},
+	}
+}
+
+func newTestJanitor(t *testing.T, config JanitorConfig) (*Janitor, *tools.FakeEtcdClient, *time.Time) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	setDir(fakeClient, "/registry/pods/default",
+		&etcd.Node{Key: "/registry/pods/default/foo", ModifiedIndex: 1, Value: api.EncodeOrDie(api.Pod{
+			JSONBase:     api.JSONBase{ID: "foo"},
+			DesiredState: api.PodState{Host: "gone"},
+		})},
+		&etcd.Node{Key: "/registry/pods/default/bar", ModifiedIndex: 2, Value: api.EncodeOrDie(api.Pod{
+			JSONBase:     api.JSONBase{ID: "bar"},
+			DesiredState: api.PodState{Host: "machine"},
+		})},
+	)
+	fakeClient.Set("/registry/hosts/gone/kubelet", api.EncodeOrDie(&api.ContainerManifestList{
+		Items: []api.ContainerManifest{{ID: "foo"}, {ID: "other"}},
+	}), 0)
+	setDir(fakeClient, "/registry/services/specs/default",
+		&etcd.Node{Key: "/registry/services/specs/default/svc", ModifiedIndex: 3, Value: api.EncodeOrDie(api.Service{
+			JSONBase: api.JSONBase{ID: "svc"},
+		})},
+	)
+	setDir(fakeClient, "/registry/services/endpoints/default",
+		&etcd.Node{Key: "/registry/services/endpoints/default/svc", ModifiedIndex: 4, Value: api.EncodeOrDie(api.Endpoints{
+			JSONBase: api.JSONBase{ID: "svc"},
+		})},
+		&etcd.Node{Key: "/registry/services/endpoints/default/old", ModifiedIndex: 5, Value: api.EncodeOrDie(api.Endpoints{
+			JSONBase: api.JSONBase{ID: "old"},
+		})},
+	)
+	setDir(fakeClient, "/registry/operations",
+		&etcd.Node{Key: "/registry/operations/1", ModifiedIndex: 6, Value: api.EncodeOrDie(api.ServerOp{
+			JSONBase: api.JSONBase{ID: "1"},
+		})},
+	)
+	now := time.Unix(1000, 0)
+	janitor := NewJanitor(NewTestEtcdRegistry(fakeClient, nil), minion.NewRegistry([]string{"machine"}), config)
+	janitor.now = func() time.Time { return now }
+	return janitor, fakeClient, &now
+}
+
+func TestJanitorSweep(t *testing.T) {
+	janitor, fakeClient, now := newTestJanitor(t, JanitorConfig{
+		PodTTL:       time.Minute,
+		EndpointsTTL: time.Minute,
+		OperationTTL: time.Hour,
+	})
+	if err := janitor.Sweep(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fakeClient.DeletedKeys) != 0 {
+		t.Errorf("nothing should be removed on first sight: %v", fakeClient.DeletedKeys)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if err := janitor.Sweep(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deleted := append([]string{}, fakeClient.DeletedKeys...)
+	sort.Strings(deleted)
+	expected := []string{
+		"/registry/pods/default/foo",
+		"/registry/services/endpoints/default/old",
+	}
+	if !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("expected %v removed, got %v", expected, deleted)
+	}
+	resp, err := fakeClient.Get("/registry/hosts/gone/kubelet", false, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var manifests api.ContainerManifestList
+	api.DecodeInto([]byte(resp.Node.Value), &manifests)
+	if len(manifests.Items) != 1 || manifests.Items[0].ID != "other" {
+		t.Errorf("expected only the orphan's manifest removed, got %#v", manifests.Items)
+	}
+
+	fakeClient.DeletedKeys = nil
+	*now = now.Add(2 * time.Hour)
+	if err := janitor.Sweep(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	found := false
+	for _, key := range fakeClient.DeletedKeys {
+		if key == "/registry/operations/1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the operation to be removed, got %v", fakeClient.DeletedKeys)
+	}
+}
+
+func TestJanitorChangedEntryRestartsClock(t *testing.T) {
+	janitor, fakeClient, now := newTestJanitor(t, JanitorConfig{OperationTTL: time.Minute})
+	if err := janitor.Sweep(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	setDir(fakeClient, "/registry/operations",
+		&etcd.Node{Key: "/registry/operations/1", ModifiedIndex: 7, Value: api.EncodeOrDie(api.ServerOp{
+			JSONBase: api.JSONBase{ID: "1"},
+		})},
+	)
+	*now = now.Add(2 * time.Minute)
+	if err := janitor.Sweep(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fakeClient.DeletedKeys) != 0 {
+		t.Errorf("an updated operation should not be removed: %v", fakeClient.DeletedKeys)
+	}
+}
+
+func TestJanitorDryRun(t *testing.T) {
+	janitor, fakeClient, now := newTestJanitor(t, JanitorConfig{
+		PodTTL:       time.Minute,
+		EndpointsTTL: time.Minute,
+		OperationTTL: time.Minute,
+		DryRun:       true,
+	})
+	for i := 0; i < 2; i++ {
+		if err := janitor.Sweep(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		*now = now.Add(2 * time.Minute)
+	}
+	if len(fakeClient.DeletedKeys) != 0 {
+		t.Errorf("a dry run should not remove anything: %v", fakeClient.DeletedKeys)
+	}
+}
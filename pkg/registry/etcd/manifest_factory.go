@@ -18,6 +18,7 @@ package etcd
 
 import (
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/envvars"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/service"
 )
 
@@ -26,18 +27,30 @@ type ManifestFactory interface {
 	MakeManifest(machine string, pod api.Pod) (api.ContainerManifest, error)
 }
 
+// BasicManifestFactory makes the manifest of a pod from its desired state,
+// adding the environment variables of the services in serviceRegistry, if set.
+// Kubelets started without -api_servers, as the salt config starts them, can't
+// list services themselves, so they only have these. The variables of the
+// manifest come after those a kubelet sets, so they take precedence. The master
+// can't tell whether the proxy of the machine serves portals, so it assumes so.
 type BasicManifestFactory struct {
 	serviceRegistry service.Registry
 }
 
 func (b *BasicManifestFactory) MakeManifest(machine string, pod api.Pod) (api.ContainerManifest, error) {
-	envVars, err := service.GetServiceEnvironmentVariables(b.serviceRegistry, machine)
-	if err != nil {
-		return api.ContainerManifest{}, err
+	pod.DesiredState.Manifest.ID = pod.ID
+	if b.serviceRegistry != nil {
+		services, err := b.serviceRegistry.ListServices()
+		if err != nil {
+			return api.ContainerManifest{}, err
+		}
+		envVars := envvars.FromServices(services.Items, machine, true)
+		for ix, container := range pod.DesiredState.Manifest.Containers {
+			pod.DesiredState.Manifest.Containers[ix].Env = append(container.Env, envVars...)
+		}
 	}
-	for ix, container := range pod.DesiredState.Manifest.Containers {
-		pod.DesiredState.Manifest.ID = pod.ID
-		pod.DesiredState.Manifest.Containers[ix].Env = append(container.Env, envVars...)
+	if pod.DesiredState.Manifest.RestartPolicy.Type == "" {
+		pod.DesiredState.Manifest.RestartPolicy = pod.DesiredState.RestartPolicy
 	}
 	return pod.DesiredState.Manifest, nil
 }
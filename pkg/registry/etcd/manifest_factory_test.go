@@ -25,11 +25,8 @@ import (
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 )
 
-func TestMakeManifestNoServices(t *testing.T) {
-	registry := registrytest.ServiceRegistry{}
-	factory := &BasicManifestFactory{
-		serviceRegistry: &registry,
-	}
+func TestMakeManifest(t *testing.T) {
+	factory := &BasicManifestFactory{}
 
 	manifest, err := factory.MakeManifest("machine", api.Pod{
 		JSONBase: api.JSONBase{ID: "foobar"},
@@ -38,6 +35,7 @@ func TestMakeManifestNoServices(t *testing.T) {
 				Containers: []api.Container{
 					{
 						Name: "foo",
+						Env:  []api.EnvVar{{Name: "foo", Value: "bar"}},
 					},
 				},
 			},
@@ -47,104 +45,43 @@ func TestMakeManifestNoServices(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 
+	// Without a service registry, the kubelet adds the environment variables
+	// of services.
 	container := manifest.Containers[0]
-	if len(container.Env) != 1 ||
-		container.Env[0].Name != "SERVICE_HOST" ||
-		container.Env[0].Value != "machine" {
-		t.Errorf("Expected one env vars, got: %#v", manifest)
+	if !reflect.DeepEqual(container.Env, []api.EnvVar{{Name: "foo", Value: "bar"}}) {
+		t.Errorf("Expected the env vars of the pod, got: %#v", manifest)
 	}
 	if manifest.ID != "foobar" {
 		t.Errorf("Failed to assign ID to manifest: %#v", manifest.ID)
 	}
 }
 
-func TestMakeManifestServices(t *testing.T) {
-	registry := registrytest.ServiceRegistry{
-		List: api.ServiceList{
-			Items: []api.Service{
-				{
-					JSONBase: api.JSONBase{ID: "test"},
-					Port:     8080,
-					ContainerPort: util.IntOrString{
-						Kind:   util.IntstrInt,
-						IntVal: 900,
-					},
-				},
-			},
-		},
-	}
-	factory := &BasicManifestFactory{
-		serviceRegistry: &registry,
-	}
+func TestMakeManifestRestartPolicy(t *testing.T) {
+	factory := &BasicManifestFactory{}
 
 	manifest, err := factory.MakeManifest("machine", api.Pod{
+		JSONBase: api.JSONBase{ID: "foobar"},
 		DesiredState: api.PodState{
-			Manifest: api.ContainerManifest{
-				Containers: []api.Container{
-					{
-						Name: "foo",
-					},
-				},
-			},
+			Manifest:      api.ContainerManifest{},
+			RestartPolicy: api.RestartPolicy{Type: api.RestartOnFailure},
 		},
 	})
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
-
-	container := manifest.Containers[0]
-	envs := []api.EnvVar{
-		{
-			Name:  "TEST_SERVICE_PORT",
-			Value: "8080",
-		},
-		{
-			Name:  "TEST_PORT",
-			Value: "tcp://machine:8080",
-		},
-		{
-			Name:  "TEST_PORT_900_TCP",
-			Value: "tcp://machine:8080",
-		},
-		{
-			Name:  "TEST_PORT_900_TCP_PROTO",
-			Value: "tcp",
-		},
-		{
-			Name:  "TEST_PORT_900_TCP_PORT",
-			Value: "8080",
-		},
-		{
-			Name:  "TEST_PORT_900_TCP_ADDR",
-			Value: "machine",
-		},
-		{
-			Name:  "SERVICE_HOST",
-			Value: "machine",
-		},
-	}
-	if len(container.Env) != 7 {
-		t.Errorf("Expected 7 env vars, got %d: %#v", len(container.Env), manifest)
-		return
-	}
-	for ix := range container.Env {
-		if !reflect.DeepEqual(envs[ix], container.Env[ix]) {
-			t.Errorf("expected %#v, got %#v", envs[ix], container.Env[ix])
-		}
+	if manifest.RestartPolicy.Type != api.RestartOnFailure {
+		t.Errorf("Failed to copy the restart policy of the pod: %#v", manifest.RestartPolicy)
 	}
 }
 
-func TestMakeManifestServicesExistingEnvVar(t *testing.T) {
+func TestMakeManifestServices(t *testing.T) {
 	registry := registrytest.ServiceRegistry{
 		List: api.ServiceList{
 			Items: []api.Service{
 				{
-					JSONBase: api.JSONBase{ID: "test"},
-					Port:     8080,
-					ContainerPort: util.IntOrString{
-						Kind:   util.IntstrInt,
-						IntVal: 900,
-					},
+					JSONBase:   api.JSONBase{ID: "test"},
+					Port:       8080,
+					TargetPort: util.NewIntOrStringFromInt(900),
 				},
 			},
 		},
@@ -158,12 +95,8 @@ func TestMakeManifestServicesExistingEnvVar(t *testing.T) {
 			Manifest: api.ContainerManifest{
 				Containers: []api.Container{
 					{
-						Env: []api.EnvVar{
-							{
-								Name:  "foo",
-								Value: "bar",
-							},
-						},
+						Name: "foo",
+						Env:  []api.EnvVar{{Name: "foo", Value: "bar"}},
 					},
 				},
 			},
@@ -174,48 +107,18 @@ func TestMakeManifestServicesExistingEnvVar(t *testing.T) {
 	}
 
 	container := manifest.Containers[0]
-
 	envs := []api.EnvVar{
-		{
-			Name:  "foo",
-			Value: "bar",
-		},
-		{
-			Name:  "TEST_SERVICE_PORT",
-			Value: "8080",
-		},
-		{
-			Name:  "TEST_PORT",
-			Value: "tcp://machine:8080",
-		},
-		{
-			Name:  "TEST_PORT_900_TCP",
-			Value: "tcp://machine:8080",
-		},
-		{
-			Name:  "TEST_PORT_900_TCP_PROTO",
-			Value: "tcp",
-		},
-		{
-			Name:  "TEST_PORT_900_TCP_PORT",
-			Value: "8080",
-		},
-		{
-			Name:  "TEST_PORT_900_TCP_ADDR",
-			Value: "machine",
-		},
-		{
-			Name:  "SERVICE_HOST",
-			Value: "machine",
-		},
-	}
-	if len(container.Env) != 8 {
-		t.Errorf("Expected 8 env vars, got: %#v", manifest)
-		return
-	}
-	for ix := range container.Env {
-		if !reflect.DeepEqual(envs[ix], container.Env[ix]) {
-			t.Errorf("expected %#v, got %#v", envs[ix], container.Env[ix])
-		}
+		{Name: "foo", Value: "bar"},
+		{Name: "TEST_SERVICE_HOST", Value: "machine"},
+		{Name: "TEST_SERVICE_PORT", Value: "8080"},
+		{Name: "TEST_PORT", Value: "tcp://machine:8080"},
+		{Name: "TEST_PORT_900_TCP", Value: "tcp://machine:8080"},
+		{Name: "TEST_PORT_900_TCP_PROTO", Value: "tcp"},
+		{Name: "TEST_PORT_900_TCP_PORT", Value: "8080"},
+		{Name: "TEST_PORT_900_TCP_ADDR", Value: "machine"},
+		{Name: "SERVICE_HOST", Value: "machine"},
+	}
+	if !reflect.DeepEqual(container.Env, envs) {
+		t.Errorf("expected %#v, got %#v", envs, container.Env)
 	}
 }
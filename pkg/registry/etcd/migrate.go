@@ -0,0 +1,71 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package etcd
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+)
+
+// DefaultNamespace is the namespace objects are kept in until the API knows
+// about namespaces.
+const DefaultNamespace = "default"
+
+// MigrateFlatKeys moves objects stored directly beneath Prefix, the layout used
+// before namespaces, into the Store's namespace directory, and returns how many
+// were moved. Directories beneath Prefix are taken to be namespaces and left
+// alone. If an object exists in both places, the namespaced copy wins and the
+// flat one is just removed, so an interrupted migration can be rerun. An
+// object whose ID is the namespace itself sits where the namespace directory
+// has to go, so nothing is moved and an error is returned instead.
+func (s *Store) MigrateFlatKeys() (int, error) {
+	if s.Namespace == "" {
+		return 0, nil
+	}
+	response, err := s.Helper.Client.Get(s.Prefix, false, false)
+	if tools.IsEtcdNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	for _, node := range response.Node.Nodes {
+		if !node.Dir && path.Base(node.Key) == s.Namespace {
+			return 0, fmt.Errorf("%s %q is stored at %s, where namespace %q belongs; rename it and migrate again", s.Kind, s.Namespace, node.Key, s.Namespace)
+		}
+	}
+	moved := 0
+	for _, node := range response.Node.Nodes {
+		if node.Dir {
+			continue
+		}
+		id := path.Base(node.Key)
+		_, createErr := s.Helper.Client.Create(s.Key(id), node.Value, 0)
+		if createErr != nil && !tools.IsEtcdNodeExist(createErr) {
+			return moved, createErr
+		}
+		if _, err := s.Helper.Client.Delete(node.Key, false); err != nil && !tools.IsEtcdNotFound(err) {
+			return moved, err
+		}
+		if createErr == nil {
+			moved++
+		}
+	}
+	return moved, nil
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package etcd
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/coreos/go-etcd/etcd"
+)
+
+func TestMigrateFlatKeys(t *testing.T) {
+	store, fakeClient := newTestStore(t, UpdateCompareAndSwap)
+	store.Namespace = DefaultNamespace
+	foo := api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "foo"}, Port: 80})
+	bar := api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "bar"}, Port: 81})
+	fakeClient.Data["/registry/services"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Dir: true,
+				Nodes: []*etcd.Node{
+					{Key: "/registry/services/foo", Value: foo},
+					{Key: "/registry/services/bar", Value: bar},
+					{Key: "/registry/services/default", Dir: true},
+				},
+			},
+		},
+	}
+	// bar was already copied by an earlier, interrupted migration.
+	fakeClient.Set("/registry/services/default/bar", bar, 0)
+
+	moved, err := store.MigrateFlatKeys()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("expected 1 object moved, got %d", moved)
+	}
+	var svc api.Service
+	if err := store.Get("foo", &svc); err != nil || svc.Port != 80 {
+		t.Errorf("unexpected service %#v (%v)", svc, err)
+	}
+	if err := store.Get("bar", &svc); err != nil || svc.Port != 81 {
+		t.Errorf("unexpected service %#v (%v)", svc, err)
+	}
+	if len(fakeClient.DeletedKeys) != 2 ||
+		fakeClient.DeletedKeys[0] != "/registry/services/foo" ||
+		fakeClient.DeletedKeys[1] != "/registry/services/bar" {
+		t.Errorf("unexpected deleted keys %v", fakeClient.DeletedKeys)
+	}
+}
+
+func TestMigrateFlatKeysNothingStored(t *testing.T) {
+	store, fakeClient := newTestStore(t, UpdateCompareAndSwap)
+	store.Namespace = DefaultNamespace
+	fakeClient.ExpectNotFoundGet("/registry/services")
+	if moved, err := store.MigrateFlatKeys(); moved != 0 || err != nil {
+		t.Errorf("unexpected result %d, %v", moved, err)
+	}
+}
+
+func TestMigrateFlatKeysNamespaceCollision(t *testing.T) {
+	store, fakeClient := newTestStore(t, UpdateCompareAndSwap)
+	store.Namespace = DefaultNamespace
+	foo := api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "foo"}, Port: 80})
+	def := api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "default"}, Port: 81})
+	fakeClient.Data["/registry/services"] = tools.EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Dir: true,
+				Nodes: []*etcd.Node{
+					{Key: "/registry/services/foo", Value: foo},
+					{Key: "/registry/services/default", Value: def},
+				},
+			},
+		},
+	}
+	moved, err := store.MigrateFlatKeys()
+	if err == nil {
+		t.Errorf("expected an error for a service named after the namespace")
+	}
+	if moved != 0 || len(fakeClient.DeletedKeys) != 0 {
+		t.Errorf("expected nothing moved, got %d moved and %v deleted", moved, fakeClient.DeletedKeys)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package etcd
+
+import (
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/storage"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+)
+
+// UpdateStrategy selects how Store.Update writes an object.
+type UpdateStrategy int
+
+const (
+	// UpdateCompareAndSwap writes the object only if the stored one still has
+	// the object's resourceVersion. An object without a resourceVersion is
+	// created, and only if nothing is stored yet. Either way, a writer which lost
+	// a race gets a conflict error.
+	UpdateCompareAndSwap UpdateStrategy = iota
+	// UpdateReplace unconditionally replaces whatever is stored, creating the
+	// object if it doesn't exist yet. Only safe for objects with a single writer.
+	UpdateReplace
+)
+
+// Store implements storage.Interface for one kind of object kept in a
+// directory in etcd. etcd errors are turned into the matching apiserver errors.
+type Store struct {
+	Helper *tools.EtcdHelper
+	// Kind names the objects in errors, e.g. "pod".
+	Kind string
+	// Prefix is the etcd directory holding the objects.
+	Prefix string
+	// Namespace, if set, is the directory beneath Prefix holding the objects,
+	// so that they are stored as <Prefix>/<Namespace>/<id>.
+	Namespace string
+	// KeyFunc returns the key of the object with the given ID. Defaults to an
+	// entry directly beneath the namespace directory.
+	KeyFunc func(id string) string
+	// NewFunc returns a pointer to a new, empty object of the stored type.
+	NewFunc func() interface{}
+	// UpdateStrategy decides how Update writes objects.
+	UpdateStrategy UpdateStrategy
+}
+
+// Key returns the etcd key of the object with the given ID.
+func (s *Store) Key(id string) string {
+	if s.KeyFunc != nil {
+		return s.KeyFunc(id)
+	}
+	return s.Dir() + "/" + id
+}
+
+// Dir returns the etcd directory List and Watch look at: the namespace
+// directory if there is a Namespace, otherwise Prefix.
+func (s *Store) Dir() string {
+	if s.Namespace != "" {
+		return s.Prefix + "/" + s.Namespace
+	}
+	return s.Prefix
+}
+
+// Get reads the object with the given ID into objPtr.
+func (s *Store) Get(id string, objPtr interface{}) error {
+	err := s.Helper.ExtractObj(s.Key(id), objPtr, false)
+	if tools.IsEtcdNotFound(err) {
+		return apiserver.NewNotFoundErr(s.Kind, id)
+	}
+	return err
+}
+
+// List reads every object into the slice slicePtr points to, and sets
+// *resourceVersion, if given, to the etcd index they were read at.
+func (s *Store) List(slicePtr interface{}, resourceVersion *uint64) error {
+	return s.Helper.ExtractList(s.Dir(), slicePtr, resourceVersion)
+}
+
+// Watch begins watching for new, changed, or deleted objects which pass filter.
+func (s *Store) Watch(resourceVersion uint64, filter tools.FilterFunc) (watch.Interface, error) {
+	return s.Helper.WatchList(s.Dir(), resourceVersion, filter)
+}
+
+// Create stores obj under the given ID, which must not be in use yet.
+func (s *Store) Create(id string, obj interface{}) error {
+	return s.CreateWithTTL(id, obj, 0)
+}
+
+// CreateWithTTL is like Create, but if ttl is nonzero, etcd removes the object
+// again after ttl seconds.
+func (s *Store) CreateWithTTL(id string, obj interface{}, ttl uint64) error {
+	err := s.Helper.CreateObjWithTTL(s.Key(id), obj, ttl)
+	if tools.IsEtcdNodeExist(err) {
+		return apiserver.NewAlreadyExistsErr(s.Kind, id)
+	}
+	return err
+}
+
+// Update stores obj under the given ID according to the UpdateStrategy.
+func (s *Store) Update(id string, obj interface{}) error {
+	switch s.UpdateStrategy {
+	case UpdateReplace:
+		return s.Helper.AtomicUpdate(s.Key(id), s.NewFunc(), func(interface{}) (interface{}, error) {
+			return obj, nil
+		})
+	default:
+		err := s.Helper.SetObj(s.Key(id), obj)
+		if tools.IsEtcdTestFailed(err) || tools.IsEtcdNodeExist(err) {
+			return apiserver.NewConflictErr(s.Kind, id, err)
+		}
+		if tools.IsEtcdNotFound(err) {
+			return apiserver.NewNotFoundErr(s.Kind, id)
+		}
+		return err
+	}
+}
+
+// AtomicUpdate stores what tryUpdate makes of the object with the given ID,
+// retrying if it changes in the meantime.
+func (s *Store) AtomicUpdate(id string, ptrToType interface{}, tryUpdate storage.UpdateFunc) error {
+	return s.Helper.AtomicUpdate(s.Key(id), ptrToType, tools.EtcdUpdateFunc(tryUpdate))
+}
+
+// Delete removes the object with the given ID. If recursive is set, anything
+// stored beneath its key goes with it.
+func (s *Store) Delete(id string, recursive bool) error {
+	err := s.Helper.Delete(s.Key(id), recursive)
+	if tools.IsEtcdNotFound(err) {
+		return apiserver.NewNotFoundErr(s.Kind, id)
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package etcd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+)
+
+func newTestStore(t *testing.T, strategy UpdateStrategy) (*Store, *tools.FakeEtcdClient) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	return &Store{
+		Helper:         &tools.EtcdHelper{Client: fakeClient, Codec: api.Codec, ResourceVersioner: api.ResourceVersioner},
+		Kind:           "service",
+		Prefix:         "/registry/services",
+		NewFunc:        func() interface{} { return &api.Service{} },
+		UpdateStrategy: strategy,
+	}, fakeClient
+}
+
+func TestStoreKey(t *testing.T) {
+	store, _ := newTestStore(t, UpdateCompareAndSwap)
+	if e, a := "/registry/services/foo", store.Key("foo"); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	store.Namespace = "ns"
+	if e, a := "/registry/services/ns/foo", store.Key("foo"); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if e, a := "/registry/services/ns", store.Dir(); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	store.KeyFunc = func(id string) string { return "/elsewhere/" + id }
+	if e, a := "/elsewhere/foo", store.Key("foo"); e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestStoreCreateGetDelete(t *testing.T) {
+	store, fakeClient := newTestStore(t, UpdateCompareAndSwap)
+	fakeClient.ExpectNotFoundGet("/registry/services/foo")
+
+	var svc api.Service
+	if err := store.Get("foo", &svc); !apiserver.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := store.Create("foo", api.Service{JSONBase: api.JSONBase{ID: "foo"}, Port: 80}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Create("foo", api.Service{JSONBase: api.JSONBase{ID: "foo"}}); !apiserver.IsAlreadyExists(err) {
+		t.Errorf("expected already exists error, got %v", err)
+	}
+	if err := store.Get("foo", &svc); err != nil || svc.Port != 80 {
+		t.Errorf("unexpected service %#v (%v)", svc, err)
+	}
+	if err := store.Delete("foo", false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if e, a := []string{"/registry/services/foo"}, fakeClient.DeletedKeys; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+}
+
+func TestStoreUpdateStrategies(t *testing.T) {
+	store, fakeClient := newTestStore(t, UpdateCompareAndSwap)
+	fakeClient.Set("/registry/services/foo", api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "foo"}}), 0)
+
+	// Without a resourceVersion, an update only creates.
+	if err := store.Update("foo", api.Service{JSONBase: api.JSONBase{ID: "foo"}, Port: 80}); !apiserver.IsConflict(err) {
+		t.Errorf("expected conflict error, got %v", err)
+	}
+	// A stale resourceVersion is a conflict too.
+	fakeClient.ChangeIndex = 4
+	fakeClient.Set("/registry/services/foo", api.EncodeOrDie(api.Service{JSONBase: api.JSONBase{ID: "foo"}}), 0)
+	if err := store.Update("foo", api.Service{JSONBase: api.JSONBase{ID: "foo", ResourceVersion: 4}, Port: 80}); !apiserver.IsConflict(err) {
+		t.Errorf("expected conflict error, got %v", err)
+	}
+	if err := store.Update("foo", api.Service{JSONBase: api.JSONBase{ID: "foo", ResourceVersion: 5}, Port: 80}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	store.UpdateStrategy = UpdateReplace
+	fakeClient.ExpectNotFoundGet("/registry/services/bar")
+	for id, port := range map[string]int{"foo": 80, "bar": 81} {
+		if err := store.Update(id, api.Service{JSONBase: api.JSONBase{ID: id}, Port: port}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var svc api.Service
+		if err := store.Get(id, &svc); err != nil || svc.Port != port {
+			t.Errorf("unexpected service %#v (%v)", svc, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package event
+
+import (
+	"fmt"
+	"time"
+
+	"code.google.com/p/go-uuid/uuid"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// DefaultTTL is how long events are kept by default.
+const DefaultTTL = 48 * time.Hour
+
+// RegistryStorage implements the RESTStorage interface for events. Events can
+// be created, listed and read, but not changed; they expire after a while.
+type RegistryStorage struct {
+	registry Registry
+	ttl      time.Duration
+}
+
+// NewRegistryStorage returns a new RegistryStorage keeping events in registry
+// for ttl.
+func NewRegistryStorage(registry Registry, ttl time.Duration) *RegistryStorage {
+	return &RegistryStorage{
+		registry: registry,
+		ttl:      ttl,
+	}
+}
+
+// Create stores the event it receives, giving it an ID and a timestamp if it
+// has none.
+func (rs *RegistryStorage) Create(obj interface{}) (<-chan interface{}, error) {
+	event, ok := obj.(*api.Event)
+	if !ok {
+		return nil, fmt.Errorf("not an event: %#v", obj)
+	}
+	if len(event.ID) == 0 {
+		event.ID = uuid.NewUUID().String()
+	}
+	if errs := api.ValidateEvent(event); len(errs) > 0 {
+		return nil, apiserver.NewInvalidErr("event", event.ID, errs)
+	}
+	event.CreationTimestamp = util.Now()
+	if event.Timestamp.IsZero() {
+		event.Timestamp = event.CreationTimestamp
+	}
+
+	return apiserver.MakeAsync(func() (interface{}, error) {
+		if err := rs.registry.CreateEvent(*event, rs.ttl); err != nil {
+			return nil, err
+		}
+		return rs.registry.GetEvent(event.ID)
+	}), nil
+}
+
+// Delete returns an error because events are only removed by expiring.
+func (rs *RegistryStorage) Delete(id string) (<-chan interface{}, error) {
+	return nil, fmt.Errorf("Events may not be deleted.")
+}
+
+func (rs *RegistryStorage) Get(id string) (interface{}, error) {
+	return rs.registry.GetEvent(id)
+}
+
+// List returns the events whose fields match selector. Events have no labels,
+// so the selector is matched against InvolvedObject.Kind, InvolvedObject.ID,
+// Reason and Source, e.g. "InvolvedObject.ID=foo".
+func (rs *RegistryStorage) List(selector labels.Selector) (interface{}, error) {
+	all, err := rs.registry.ListEvents()
+	if err != nil {
+		return nil, err
+	}
+	result := api.EventList{JSONBase: all.JSONBase}
+	for _, event := range all.Items {
+		fields := labels.Set{
+			"InvolvedObject.Kind": event.InvolvedObject.Kind,
+			"InvolvedObject.ID":   event.InvolvedObject.ID,
+			"Reason":              event.Reason,
+			"Source":              event.Source,
+		}
+		if selector.Matches(fields) {
+			result.Items = append(result.Items, event)
+		}
+	}
+	return result, nil
+}
+
+func (rs *RegistryStorage) New() interface{} {
+	return &api.Event{}
+}
+
+// Update returns an error-- events may not be changed.
+func (rs *RegistryStorage) Update(obj interface{}) (<-chan interface{}, error) {
+	return nil, fmt.Errorf("Events may not be changed.")
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/registrytest"
+)
+
+func TestEventStorageCreate(t *testing.T) {
+	registry := registrytest.NewEventRegistry()
+	storage := NewRegistryStorage(registry, time.Hour)
+
+	c, err := storage.Create(&api.Event{
+		InvolvedObject: api.ObjectReference{Kind: "Pod", ID: "foo"},
+		Reason:         "started",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	event := (<-c).(*api.Event)
+	if event.ID == "" || event.Timestamp.IsZero() || event.Reason != "started" {
+		t.Errorf("unexpected event: %#v", event)
+	}
+	if registry.TTL != time.Hour {
+		t.Errorf("expected the event to be kept for an hour, got %v", registry.TTL)
+	}
+	obj, err := storage.Get(event.ID)
+	if err != nil || obj.(*api.Event).InvolvedObject.ID != "foo" {
+		t.Errorf("unexpected event: %#v (%v)", obj, err)
+	}
+
+	if _, err := storage.Create(&api.Event{Reason: "started"}); !apiserver.IsInvalid(err) {
+		t.Errorf("expected invalid error, got %v", err)
+	}
+	if _, err := storage.Update(event); err == nil {
+		t.Errorf("expected an error updating an event")
+	}
+	if _, err := storage.Delete(event.ID); err == nil {
+		t.Errorf("expected an error deleting an event")
+	}
+}
+
+func TestEventStorageList(t *testing.T) {
+	registry := registrytest.NewEventRegistry()
+	registry.List.Items = []api.Event{
+		{JSONBase: api.JSONBase{ID: "1"}, InvolvedObject: api.ObjectReference{Kind: "Pod", ID: "foo"}, Reason: "started"},
+		{JSONBase: api.JSONBase{ID: "2"}, InvolvedObject: api.ObjectReference{Kind: "Pod", ID: "bar"}, Reason: "started"},
+		{JSONBase: api.JSONBase{ID: "3"}, InvolvedObject: api.ObjectReference{Kind: "Pod", ID: "foo"}, Reason: "killed"},
+	}
+	storage := NewRegistryStorage(registry, time.Hour)
+
+	table := map[string][]string{
+		"":                                    {"1", "2", "3"},
+		"InvolvedObject.ID=foo":               {"1", "3"},
+		"InvolvedObject.ID=foo,Reason=killed": {"3"},
+		"InvolvedObject.Kind=Minion":          {},
+	}
+	for selector, expected := range table {
+		s, err := labels.ParseSelector(selector)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		obj, err := storage.List(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		items := obj.(api.EventList).Items
+		if len(items) != len(expected) {
+			t.Errorf("%q: expected %v, got %#v", selector, expected, items)
+			continue
+		}
+		for i := range items {
+			if items[i].ID != expected[i] {
+				t.Errorf("%q: expected %v, got %#v", selector, expected, items)
+			}
+		}
+	}
+}
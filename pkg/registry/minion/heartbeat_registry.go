@@ -0,0 +1,144 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package minion
+
+import (
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// StatusRegistry is implemented by registries which know the condition of
+// their minions, not just whether they exist.
+type StatusRegistry interface {
+	Registry
+	Status(minion string) api.MinionStatus
+}
+
+// ReportingRegistry is implemented by registries which keep what minions
+// report about themselves when they register.
+type ReportingRegistry interface {
+	Registry
+	// Report inserts minion, remembering its resources and status.
+	Report(minion api.Minion) error
+	// Reported returns the last report from the named minion, if any.
+	Reported(name string) (api.Minion, bool)
+}
+
+// HeartbeatRegistry treats every Insert as a heartbeat from the minion, and
+// marks minions NotReady once they haven't been heard from within the timeout.
+// NotReady minions are still listed, with their status, and the scheduler's
+// MinionReady predicate keeps pods off them. Minions which report themselves
+// NotReady are treated the same way, even while their heartbeats arrive.
+type HeartbeatRegistry struct {
+	delegate Registry
+	timeout  time.Duration
+	clock    Clock
+
+	// lock guards lastSeen and reported.
+	lock     sync.Mutex
+	lastSeen map[string]time.Time
+	reported map[string]api.Minion
+}
+
+// NewHeartbeatRegistry returns a HeartbeatRegistry in front of delegate. Minions
+// the delegate already knows about are NotReady until their first heartbeat.
+func NewHeartbeatRegistry(delegate Registry, timeout time.Duration) *HeartbeatRegistry {
+	return &HeartbeatRegistry{
+		delegate: delegate,
+		timeout:  timeout,
+		clock:    SystemClock{},
+		lastSeen: map[string]time.Time{},
+		reported: map[string]api.Minion{},
+	}
+}
+
+func (r *HeartbeatRegistry) Contains(minion string) (bool, error) {
+	return r.delegate.Contains(minion)
+}
+
+func (r *HeartbeatRegistry) Delete(minion string) error {
+	if err := r.delegate.Delete(minion); err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.lastSeen, minion)
+	delete(r.reported, minion)
+	return nil
+}
+
+func (r *HeartbeatRegistry) Insert(minion string) error {
+	if err := r.delegate.Insert(minion); err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.lastSeen[minion] = r.clock.Now()
+	return nil
+}
+
+// Report counts as a heartbeat from minion, like Insert, and keeps its report.
+func (r *HeartbeatRegistry) Report(minion api.Minion) error {
+	if err := r.Insert(minion.ID); err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.reported[minion.ID] = minion
+	return nil
+}
+
+func (r *HeartbeatRegistry) Reported(name string) (api.Minion, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	minion, ok := r.reported[name]
+	return minion, ok
+}
+
+// List returns every minion, Ready or not. Status tells which are Ready.
+func (r *HeartbeatRegistry) List() ([]string, error) {
+	return r.delegate.List()
+}
+
+// Status returns the condition of minion, based on its last heartbeat and on
+// what it last reported.
+func (r *HeartbeatRegistry) Status(minion string) api.MinionStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	seen, ok := r.lastSeen[minion]
+	if !ok {
+		return api.MinionStatus{Condition: api.MinionNotReady}
+	}
+	status := api.MinionStatus{
+		Condition:         api.MinionReady,
+		LastHeartbeatTime: util.Time{Time: seen},
+	}
+	if reported, ok := r.reported[minion]; ok {
+		status.Addresses = reported.Status.Addresses
+		status.KubeletVersion = reported.Status.KubeletVersion
+		if reported.Status.Condition == api.MinionNotReady {
+			status.Condition = api.MinionNotReady
+		}
+	}
+	if r.clock.Now().Sub(seen) > r.timeout {
+		status.Condition = api.MinionNotReady
+	}
+	return status
+}
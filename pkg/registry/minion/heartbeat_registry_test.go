@@ -0,0 +1,122 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package minion
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+func TestHeartbeatReadiness(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	delegate := NewRegistry([]string{"m1"})
+	registry := NewHeartbeatRegistry(delegate, 10*time.Second)
+	registry.clock = clock
+
+	// Known, but never heard from.
+	if e, a := api.MinionNotReady, registry.Status("m1").Condition; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	// NotReady minions are still listed.
+	if list, err := registry.List(); err != nil || !reflect.DeepEqual(list, []string{"m1"}) {
+		t.Errorf("unexpected list: %v (%v)", list, err)
+	}
+
+	if err := registry.Insert("m1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.Insert("m2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.now = time.Unix(5, 0)
+	if list, err := registry.List(); err != nil || !reflect.DeepEqual(list, []string{"m1", "m2"}) {
+		t.Errorf("unexpected list: %v (%v)", list, err)
+	}
+
+	// m1 keeps up its heartbeats, m2 doesn't.
+	if err := registry.Insert("m1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.now = time.Unix(12, 0)
+	if list, err := registry.List(); err != nil || !reflect.DeepEqual(list, []string{"m1", "m2"}) {
+		t.Errorf("unexpected list: %v (%v)", list, err)
+	}
+	if e, a := api.MinionReady, registry.Status("m1").Condition; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	status := registry.Status("m2")
+	if status.Condition != api.MinionNotReady || !status.LastHeartbeatTime.Equal(time.Unix(0, 0)) {
+		t.Errorf("unexpected status: %#v", status)
+	}
+	if contains, err := registry.Contains("m2"); err != nil || !contains {
+		t.Errorf("expected NotReady minion to still exist")
+	}
+
+	if err := registry.Delete("m1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if list, err := registry.List(); err != nil || !reflect.DeepEqual(list, []string{"m2"}) {
+		t.Errorf("unexpected list: %v (%v)", list, err)
+	}
+}
+
+func TestHeartbeatReports(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	registry := NewHeartbeatRegistry(NewRegistry([]string{}), 10*time.Second)
+	registry.clock = clock
+
+	report := api.Minion{
+		JSONBase: api.JSONBase{ID: "m1"},
+		Status: api.MinionStatus{
+			Condition:      api.MinionReady,
+			Addresses:      []string{"10.0.0.1"},
+			KubeletVersion: "v0.4",
+		},
+	}
+	if err := registry.Report(report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reported, ok := registry.Reported("m1"); !ok || !reflect.DeepEqual(reported, report) {
+		t.Errorf("unexpected report: %#v", reported)
+	}
+	status := registry.Status("m1")
+	if status.Condition != api.MinionReady || !reflect.DeepEqual(status.Addresses, report.Status.Addresses) || status.KubeletVersion != "v0.4" {
+		t.Errorf("unexpected status: %#v", status)
+	}
+
+	// A minion which can't run pods is NotReady despite its heartbeats.
+	report.Status.Condition = api.MinionNotReady
+	if err := registry.Report(report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := api.MinionNotReady, registry.Status("m1").Condition; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if list, err := registry.List(); err != nil || !reflect.DeepEqual(list, []string{"m1"}) {
+		t.Errorf("unexpected list: %v (%v)", list, err)
+	}
+
+	if err := registry.Delete("m1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := registry.Reported("m1"); ok {
+		t.Errorf("expected the report to be forgotten")
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package minion
+
+import (
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/storage"
+)
+
+// InstrumentedRegistry records the latency and errors of every call to the
+// Registry it wraps as "registry.minion.<method>.latency" and ".errors".
+type InstrumentedRegistry struct {
+	delegate Registry
+	metrics  *metrics.Registry
+}
+
+// NewInstrumentedRegistry returns an InstrumentedRegistry in front of delegate,
+// recording into registry.
+func NewInstrumentedRegistry(delegate Registry, registry *metrics.Registry) *InstrumentedRegistry {
+	return &InstrumentedRegistry{delegate, registry}
+}
+
+func (r *InstrumentedRegistry) Contains(minion string) (bool, error) {
+	start := time.Now()
+	contains, err := r.delegate.Contains(minion)
+	storage.RecordCall(r.metrics, "minion", "Contains", start, err)
+	return contains, err
+}
+
+func (r *InstrumentedRegistry) Delete(minion string) error {
+	start := time.Now()
+	err := r.delegate.Delete(minion)
+	storage.RecordCall(r.metrics, "minion", "Delete", start, err)
+	return err
+}
+
+func (r *InstrumentedRegistry) Insert(minion string) error {
+	start := time.Now()
+	err := r.delegate.Insert(minion)
+	storage.RecordCall(r.metrics, "minion", "Insert", start, err)
+	return err
+}
+
+func (r *InstrumentedRegistry) List() ([]string, error) {
+	start := time.Now()
+	minions, err := r.delegate.List()
+	storage.RecordCall(r.metrics, "minion", "List", start, err)
+	return minions, err
+}
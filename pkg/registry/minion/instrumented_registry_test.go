@@ -0,0 +1,54 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package minion
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/registrytest"
+)
+
+func TestInstrumentedRegistry(t *testing.T) {
+	m := metrics.NewRegistry()
+	registry := NewInstrumentedRegistry(NewRegistry([]string{"m1"}), m)
+	if list, err := registry.List(); err != nil || !reflect.DeepEqual(list, []string{"m1"}) {
+		t.Errorf("unexpected list: %v (%v)", list, err)
+	}
+	if contains, err := registry.Contains("m1"); err != nil || !contains {
+		t.Errorf("expected m1 to be known: %v", err)
+	}
+
+	fake := registrytest.NewMinionRegistry(nil)
+	fake.Err = errors.New("test error")
+	NewInstrumentedRegistry(fake, m).Insert("m2")
+
+	snapshot := m.Snapshot()
+	for _, name := range []string{"registry.minion.List.latency", "registry.minion.Contains.latency", "registry.minion.Insert.latency"} {
+		if n := snapshot.Histograms[name].Count; n != 1 {
+			t.Errorf("expected %s to count 1 call, got %d", name, n)
+		}
+	}
+	if n := snapshot.Counters["registry.minion.Insert.errors"]; n != 1 {
+		t.Errorf("expected 1 insert error, got %d", n)
+	}
+	if n := snapshot.Counters["registry.minion.List.errors"]; n != 0 {
+		t.Errorf("expected no list errors, got %d", n)
+	}
+}
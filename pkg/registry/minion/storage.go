@@ -18,22 +18,47 @@ package minion
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 )
 
+// AdmissionFunc decides whether a minion may register itself. A non-nil error
+// is returned to the client as is.
+type AdmissionFunc func(minion *api.Minion) error
+
 // RegistryStorage implements the RESTStorage interface, backed by a MinionRegistry.
 type RegistryStorage struct {
 	registry Registry
+	admit    AdmissionFunc
 }
 
-// NewRegistryStorage returns a new RegistryStorage.
+// NewRegistryStorage returns a new RegistryStorage which accepts every minion.
 func NewRegistryStorage(m Registry) apiserver.RESTStorage {
+	return NewAdmittingRegistryStorage(m, nil)
+}
+
+// NewAdmittingRegistryStorage returns a new RegistryStorage which only creates
+// the minions admit allows. A nil admit allows every minion.
+func NewAdmittingRegistryStorage(m Registry, admit AdmissionFunc) apiserver.RESTStorage {
 	return &RegistryStorage{
 		registry: m,
+		admit:    admit,
+	}
+}
+
+// AdmitMatching returns an AdmissionFunc which only allows minions whose ID
+// matches re.
+func AdmitMatching(re *regexp.Regexp) AdmissionFunc {
+	return func(minion *api.Minion) error {
+		if !re.MatchString(minion.ID) {
+			return apiserver.NewInvalidErr("minion", minion.ID, errors.ErrorList{errors.NewNotSupported("Minion.ID", minion.ID)})
+		}
+		return nil
 	}
 }
 
@@ -42,14 +67,24 @@ func (rs *RegistryStorage) Create(obj interface{}) (<-chan interface{}, error) {
 	if !ok {
 		return nil, fmt.Errorf("not a minion: %#v", obj)
 	}
-	if minion.ID == "" {
-		return nil, fmt.Errorf("ID should not be empty: %#v", minion)
+	if errs := api.ValidateMinion(minion); len(errs) > 0 {
+		return nil, apiserver.NewInvalidErr("minion", minion.ID, errs)
+	}
+	if rs.admit != nil {
+		if err := rs.admit(minion); err != nil {
+			return nil, err
+		}
 	}
 
 	minion.CreationTimestamp = util.Now()
 
 	return apiserver.MakeAsync(func() (interface{}, error) {
-		err := rs.registry.Insert(minion.ID)
+		var err error
+		if reportingRegistry, ok := rs.registry.(ReportingRegistry); ok {
+			err = reportingRegistry.Report(*minion)
+		} else {
+			err = rs.registry.Insert(minion.ID)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -67,7 +102,7 @@ func (rs *RegistryStorage) Create(obj interface{}) (<-chan interface{}, error) {
 func (rs *RegistryStorage) Delete(id string) (<-chan interface{}, error) {
 	exists, err := rs.registry.Contains(id)
 	if !exists {
-		return nil, ErrDoesNotExist
+		return nil, apiserver.NewNotFoundErr("minion", id)
 	}
 	if err != nil {
 		return nil, err
@@ -80,7 +115,7 @@ func (rs *RegistryStorage) Delete(id string) (<-chan interface{}, error) {
 func (rs *RegistryStorage) Get(id string) (interface{}, error) {
 	exists, err := rs.registry.Contains(id)
 	if !exists {
-		return nil, ErrDoesNotExist
+		return nil, apiserver.NewNotFoundErr("minion", id)
 	}
 	return rs.toApiMinion(id), err
 }
@@ -92,7 +127,10 @@ func (rs *RegistryStorage) List(selector labels.Selector) (interface{}, error) {
 	}
 	var list api.MinionList
 	for _, name := range nameList {
-		list.Items = append(list.Items, rs.toApiMinion(name))
+		minion := rs.toApiMinion(name)
+		if selector.Matches(labels.Set(minion.Labels)) {
+			list.Items = append(list.Items, minion)
+		}
 	}
 	return list, nil
 }
@@ -106,5 +144,18 @@ func (rs *RegistryStorage) Update(minion interface{}) (<-chan interface{}, error
 }
 
 func (rs *RegistryStorage) toApiMinion(name string) api.Minion {
-	return api.Minion{JSONBase: api.JSONBase{ID: name}}
+	minion := api.Minion{
+		JSONBase: api.JSONBase{ID: name},
+		Status:   api.MinionStatus{Condition: api.MinionReady},
+	}
+	if reportingRegistry, ok := rs.registry.(ReportingRegistry); ok {
+		if reported, ok := reportingRegistry.Reported(name); ok {
+			minion.NodeResources = reported.NodeResources
+			minion.Labels = reported.Labels
+		}
+	}
+	if statusRegistry, ok := rs.registry.(StatusRegistry); ok {
+		minion.Status = statusRegistry.Status(name)
+	}
+	return minion
 }
@@ -18,9 +18,12 @@ package minion
 
 import (
 	"reflect"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 )
 
@@ -34,7 +37,7 @@ func TestMinionRegistryStorage(t *testing.T) {
 	if obj, err := ms.Get("bar"); err != nil || obj.(api.Minion).ID != "bar" {
 		t.Errorf("missing expected object")
 	}
-	if _, err := ms.Get("baz"); err != ErrDoesNotExist {
+	if _, err := ms.Get("baz"); !apiserver.IsNotFound(err) {
 		t.Errorf("has unexpected object")
 	}
 
@@ -58,12 +61,12 @@ func TestMinionRegistryStorage(t *testing.T) {
 	if s, ok := obj.(*api.Status); !ok || s.Status != api.StatusSuccess {
 		t.Errorf("delete return value was weird: %#v", obj)
 	}
-	if _, err := ms.Get("bar"); err != ErrDoesNotExist {
+	if _, err := ms.Get("bar"); !apiserver.IsNotFound(err) {
 		t.Errorf("delete didn't actually delete")
 	}
 
 	_, err = ms.Delete("bar")
-	if err != ErrDoesNotExist {
+	if !apiserver.IsNotFound(err) {
 		t.Errorf("delete returned wrong error")
 	}
 
@@ -74,11 +77,101 @@ func TestMinionRegistryStorage(t *testing.T) {
 	expect := []api.Minion{
 		{
 			JSONBase: api.JSONBase{ID: "baz"},
+			Status:   api.MinionStatus{Condition: api.MinionReady},
 		}, {
 			JSONBase: api.JSONBase{ID: "foo"},
+			Status:   api.MinionStatus{Condition: api.MinionReady},
 		},
 	}
 	if !reflect.DeepEqual(list.(api.MinionList).Items, expect) {
 		t.Errorf("Unexpected list value: %#v", list)
 	}
 }
+
+func TestMinionRegistryStorageAdmission(t *testing.T) {
+	ms := NewAdmittingRegistryStorage(NewRegistry([]string{}), AdmitMatching(regexp.MustCompile("^good-")))
+
+	if _, err := ms.Create(&api.Minion{JSONBase: api.JSONBase{ID: "bad-1"}}); !apiserver.IsInvalid(err) {
+		t.Errorf("expected invalid error, got %v", err)
+	}
+	c, err := ms.Create(&api.Minion{JSONBase: api.JSONBase{ID: "good-1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-c
+	if _, err := ms.Get("good-1"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ms.Get("bad-1"); !apiserver.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestMinionRegistryStorageStatus(t *testing.T) {
+	registry := NewHeartbeatRegistry(NewRegistry([]string{"m1"}), time.Minute)
+	ms := NewRegistryStorage(registry)
+
+	obj, err := ms.Get("m1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := api.MinionNotReady, obj.(api.Minion).Status.Condition; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	// NotReady minions are listed with their status.
+	obj, err = ms.List(labels.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if list := obj.(api.MinionList); len(list.Items) != 1 || list.Items[0].Status.Condition != api.MinionNotReady {
+		t.Errorf("expected m1 to be listed NotReady, got %#v", list)
+	}
+
+	c, err := ms.Create(&api.Minion{
+		JSONBase:      api.JSONBase{ID: "m1"},
+		NodeResources: api.NodeResources{Capacity: api.ResourceList{api.ResourceCPU: 1000}},
+		Status:        api.MinionStatus{KubeletVersion: "v0.4"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	minion := (<-c).(api.Minion)
+	if e, a := api.MinionReady, minion.Status.Condition; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	if minion.NodeResources.Capacity[api.ResourceCPU] != 1000 || minion.Status.KubeletVersion != "v0.4" {
+		t.Errorf("expected the reported minion, got %#v", minion)
+	}
+}
+
+func TestMinionRegistryStorageLabels(t *testing.T) {
+	registry := NewHeartbeatRegistry(NewRegistry([]string{}), time.Minute)
+	ms := NewRegistryStorage(registry)
+
+	for _, minion := range []api.Minion{
+		{JSONBase: api.JSONBase{ID: "m1"}, Labels: map[string]string{"disk": "ssd"}},
+		{JSONBase: api.JSONBase{ID: "m2"}},
+	} {
+		c, err := ms.Create(&minion)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		<-c
+	}
+
+	obj, err := ms.Get("m1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := "ssd", obj.(api.Minion).Labels["disk"]; e != a {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+	obj, err = ms.List(labels.Set{"disk": "ssd"}.AsSelector())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	list := obj.(api.MinionList)
+	if len(list.Items) != 1 || list.Items[0].ID != "m1" {
+		t.Errorf("expected only m1, got %#v", list.Items)
+	}
+}
@@ -24,8 +24,9 @@ import (
 
 // Registry is an interface implemented by things that know how to store Pod objects.
 type Registry interface {
-	// ListPods obtains a list of pods that match selector.
-	ListPods(selector labels.Selector) ([]api.Pod, error)
+	// ListPods obtains a list of pods that match selector, carrying the
+	// resourceVersion it was read at.
+	ListPods(selector labels.Selector) (api.PodList, error)
 	// Watch for new/changed/deleted pods
 	WatchPods(resourceVersion uint64) (watch.Interface, error)
 	// Get a specific pod
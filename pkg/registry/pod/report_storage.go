@@ -0,0 +1,103 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+)
+
+// InfoReporter is implemented by things that want to hear about the containers,
+// conditions, waiting containers and IP addresses of pods from the kubelets
+// running them.
+type InfoReporter interface {
+	ReportPodInfo(host, podID string, info api.PodInfo, conditions []api.PodCondition, waiting map[string]api.ContainerWaiting, podIP string)
+}
+
+// ConditionGetter is implemented by pod caches which know the conditions,
+// waiting containers and IP addresses the kubelets reported for their pods.
+type ConditionGetter interface {
+	GetPodConditions(host, podID string) []api.PodCondition
+	GetPodWaiting(host, podID string) map[string]api.ContainerWaiting
+	GetPodIP(host, podID string) string
+}
+
+// ReportStorage implements the RESTStorage interface. Kubelets create reports
+// to push the status of their pods to the master.
+type ReportStorage struct {
+	reporter InfoReporter
+	registry Registry
+}
+
+// NewReportStorage makes a new ReportStorage passing reports on to reporter.
+// Only reports from the hosts the pods in registry are bound to are accepted.
+func NewReportStorage(reporter InfoReporter, registry Registry) *ReportStorage {
+	return &ReportStorage{
+		reporter: reporter,
+		registry: registry,
+	}
+}
+
+// List returns an error because reports are write-only objects.
+func (*ReportStorage) List(selector labels.Selector) (interface{}, error) {
+	return nil, apiserver.NewNotFoundErr("podStatusReport", "list")
+}
+
+// Get returns an error because reports are write-only objects.
+func (*ReportStorage) Get(id string) (interface{}, error) {
+	return nil, apiserver.NewNotFoundErr("podStatusReport", id)
+}
+
+// Delete returns an error because reports are write-only objects.
+func (*ReportStorage) Delete(id string) (<-chan interface{}, error) {
+	return nil, apiserver.NewNotFoundErr("podStatusReport", id)
+}
+
+// New returns a new report object fit for having data unmarshalled into it.
+func (*ReportStorage) New() interface{} {
+	return &api.PodStatusReport{}
+}
+
+// Create passes the report it receives on.
+func (r *ReportStorage) Create(obj interface{}) (<-chan interface{}, error) {
+	report, ok := obj.(*api.PodStatusReport)
+	if !ok {
+		return nil, fmt.Errorf("incorrect type: %#v", obj)
+	}
+	if errs := api.ValidatePodStatusReport(report); len(errs) > 0 {
+		return nil, apiserver.NewInvalidErr("podStatusReport", report.ID, errs)
+	}
+	pod, err := r.registry.GetPod(report.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pod.DesiredState.Host != report.Host {
+		return nil, apiserver.NewConflictErr("podStatusReport", report.ID, fmt.Errorf("pod %s is bound to %q, not %q", report.ID, pod.DesiredState.Host, report.Host))
+	}
+	return apiserver.MakeAsync(func() (interface{}, error) {
+		r.reporter.ReportPodInfo(report.Host, report.ID, report.Info, report.Conditions, report.Waiting, report.PodIP)
+		return &api.Status{Status: api.StatusSuccess}, nil
+	}), nil
+}
+
+// Update returns an error-- this object may not be updated.
+func (*ReportStorage) Update(obj interface{}) (<-chan interface{}, error) {
+	return nil, fmt.Errorf("Pod status reports may not be changed.")
+}
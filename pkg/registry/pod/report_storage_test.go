@@ -0,0 +1,72 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pod
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/registrytest"
+	"github.com/fsouza/go-dockerclient"
+)
+
+type fakeInfoReporter struct {
+	host, podID string
+	info        api.PodInfo
+	conditions  []api.PodCondition
+	waiting     map[string]api.ContainerWaiting
+	podIP       string
+}
+
+func (f *fakeInfoReporter) ReportPodInfo(host, podID string, info api.PodInfo, conditions []api.PodCondition, waiting map[string]api.ContainerWaiting, podIP string) {
+	f.host, f.podID, f.info, f.conditions, f.waiting, f.podIP = host, podID, info, conditions, waiting, podIP
+}
+
+func TestReportStorageCreate(t *testing.T) {
+	reporter := &fakeInfoReporter{}
+	registry := registrytest.NewPodRegistry(nil)
+	registry.Pod = &api.Pod{JSONBase: api.JSONBase{ID: "foo"}, DesiredState: api.PodState{Host: "machine"}}
+	storage := NewReportStorage(reporter, registry)
+
+	info := api.PodInfo{"foo": docker.Container{ID: "foo"}}
+	conditions := []api.PodCondition{api.PodReady}
+	waiting := map[string]api.ContainerWaiting{"bar": {Reason: "ImageNotFound", Failures: 2}}
+	c, err := storage.Create(&api.PodStatusReport{JSONBase: api.JSONBase{ID: "foo"}, Host: "machine", Info: info, Conditions: conditions, Waiting: waiting, PodIP: "10.1.2.3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-c
+	if reporter.host != "machine" || reporter.podID != "foo" || !reflect.DeepEqual(reporter.info, info) || !reflect.DeepEqual(reporter.conditions, conditions) || !reflect.DeepEqual(reporter.waiting, waiting) || reporter.podIP != "10.1.2.3" {
+		t.Errorf("unexpected report: %#v", reporter)
+	}
+
+	reporter.host = ""
+	if _, err := storage.Create(&api.PodStatusReport{JSONBase: api.JSONBase{ID: "foo"}, Host: "other", Info: info}); !apiserver.IsConflict(err) {
+		t.Errorf("expected conflict error for a report from another host, got %v", err)
+	}
+	if reporter.host != "" {
+		t.Errorf("unexpected report: %#v", reporter)
+	}
+	if _, err := storage.Create(&api.PodStatusReport{JSONBase: api.JSONBase{ID: "foo"}}); !apiserver.IsInvalid(err) {
+		t.Errorf("expected invalid error, got %v", err)
+	}
+	if _, err := storage.Get("foo"); !apiserver.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
@@ -76,7 +76,7 @@ func (rs *RegistryStorage) Create(obj interface{}) (<-chan interface{}, error) {
 	}
 	pod.DesiredState.Manifest.ID = pod.ID
 	if errs := api.ValidatePod(pod); len(errs) > 0 {
-		return nil, fmt.Errorf("Validation errors: %v", errs)
+		return nil, apiserver.NewInvalidErr("pod", pod.ID, errs)
 	}
 
 	pod.CreationTimestamp = util.Now()
@@ -103,27 +103,45 @@ func (rs *RegistryStorage) Get(id string) (interface{}, error) {
 	if pod == nil {
 		return pod, nil
 	}
-	if rs.podCache != nil || rs.podInfoGetter != nil {
-		rs.fillPodInfo(pod)
-		pod.CurrentState.Status = getPodStatus(pod)
-	}
+	rs.fillPodStatus(pod)
 	pod.CurrentState.HostIP = getInstanceIP(rs.cloudProvider, pod.CurrentState.Host)
 	return pod, err
 }
 
 func (rs *RegistryStorage) List(selector labels.Selector) (interface{}, error) {
-	var result api.PodList
-	pods, err := rs.registry.ListPods(selector)
+	result, err := rs.registry.ListPods(selector)
 	if err == nil {
-		result.Items = pods
 		for i := range result.Items {
-			rs.fillPodInfo(&result.Items[i])
+			rs.fillPodStatus(&result.Items[i])
 		}
 	}
 	return result, err
 }
 
-// Watch begins watching for new, changed, or deleted pods.
+// ListWithFields is like List, but also selects on the pod's ID and
+// DesiredState.Host, e.g. to list the pods bound to a host.
+func (rs *RegistryStorage) ListWithFields(label, field labels.Selector) (interface{}, error) {
+	obj, err := rs.List(label)
+	if err != nil {
+		return nil, err
+	}
+	list := obj.(api.PodList)
+	result := api.PodList{JSONBase: list.JSONBase}
+	for _, pod := range list.Items {
+		fields := labels.Set{
+			"ID":                pod.ID,
+			"DesiredState.Host": pod.DesiredState.Host,
+		}
+		if field.Matches(fields) {
+			result.Items = append(result.Items, pod)
+		}
+	}
+	return result, nil
+}
+
+// Watch begins watching for new, changed, or deleted pods. The pods carry the
+// same status as those returned by List, except that those whose info isn't
+// known carry none.
 func (rs *RegistryStorage) Watch(label, field labels.Selector, resourceVersion uint64) (watch.Interface, error) {
 	source, err := rs.registry.WatchPods(resourceVersion)
 	if err != nil {
@@ -136,7 +154,18 @@ func (rs *RegistryStorage) Watch(label, field labels.Selector, resourceVersion u
 			"DesiredState.Status": string(pod.CurrentState.Status),
 			"DesiredState.Host":   pod.CurrentState.Host,
 		}
-		return e, label.Matches(labels.Set(pod.Labels)) && field.Matches(fields)
+		if !label.Matches(labels.Set(pod.Labels)) || !field.Matches(fields) {
+			return e, false
+		}
+		// The stored status is only what CreatePod assumed; a pod whose info
+		// isn't known yet is sent without one, so that watchers don't take it
+		// for a pending pod.
+		if rs.fillPodInfo(pod) {
+			pod.CurrentState.Status = getPodStatus(pod)
+		} else {
+			pod.CurrentState.Status = ""
+		}
+		return e, true
 	}), nil
 }
 
@@ -147,7 +176,7 @@ func (rs RegistryStorage) New() interface{} {
 func (rs *RegistryStorage) Update(obj interface{}) (<-chan interface{}, error) {
 	pod := obj.(*api.Pod)
 	if errs := api.ValidatePod(pod); len(errs) > 0 {
-		return nil, fmt.Errorf("Validation errors: %v", errs)
+		return nil, apiserver.NewInvalidErr("pod", pod.ID, errs)
 	}
 	return apiserver.MakeAsync(func() (interface{}, error) {
 		if err := rs.registry.UpdatePod(*pod); err != nil {
@@ -157,7 +186,17 @@ func (rs *RegistryStorage) Update(obj interface{}) (<-chan interface{}, error) {
 	}), nil
 }
 
-func (rs *RegistryStorage) fillPodInfo(pod *api.Pod) {
+// fillPodStatus fills in what is known about how pod is doing on its host.
+func (rs *RegistryStorage) fillPodStatus(pod *api.Pod) {
+	if rs.podCache != nil || rs.podInfoGetter != nil {
+		rs.fillPodInfo(pod)
+		pod.CurrentState.Status = getPodStatus(pod)
+	}
+}
+
+// fillPodInfo fills in the container info of pod and what the pod cache knows
+// about it, and reports whether the info was found.
+func (rs *RegistryStorage) fillPodInfo(pod *api.Pod) bool {
 	// Get cached info for the list currently.
 	// TODO: Optionally use fresh info
 	if rs.podCache != nil {
@@ -173,10 +212,19 @@ func (rs *RegistryStorage) fillPodInfo(pod *api.Pod) {
 				if err != client.ErrPodInfoNotAvailable {
 					glog.Errorf("Error getting fresh container info: %#v", err)
 				}
-				return
+				return false
 			}
 		}
 		pod.CurrentState.Info = info
+		pod.CurrentState.Terminations = getTerminations(pod)
+		if conditions, ok := rs.podCache.(ConditionGetter); ok {
+			pod.CurrentState.Conditions = conditions.GetPodConditions(pod.CurrentState.Host, pod.ID)
+			pod.CurrentState.Waiting = conditions.GetPodWaiting(pod.CurrentState.Host, pod.ID)
+			pod.CurrentState.PodIP = conditions.GetPodIP(pod.CurrentState.Host, pod.ID)
+		}
+		if pod.CurrentState.PodIP != "" {
+			return true
+		}
 		netContainerInfo, ok := info["net"]
 		if ok {
 			if netContainerInfo.NetworkSettings != nil {
@@ -187,7 +235,9 @@ func (rs *RegistryStorage) fillPodInfo(pod *api.Pod) {
 		} else {
 			glog.Warningf("Couldn't find network container for %s in %v", pod.ID, info)
 		}
+		return true
 	}
+	return false
 }
 
 func getInstanceIP(cloud cloudprovider.Interface, host string) string {
@@ -240,9 +290,39 @@ func getPodStatus(pod *api.Pod) api.PodStatus {
 	}
 }
 
+// getTerminations describes how the containers of pod which aren't running exited.
+func getTerminations(pod *api.Pod) map[string]api.ContainerTermination {
+	var terminations map[string]api.ContainerTermination
+	for _, container := range pod.DesiredState.Manifest.Containers {
+		info, ok := pod.CurrentState.Info[container.Name]
+		// Containers which never ran have not finished.
+		if !ok || info.State.Running || info.State.FinishedAt.IsZero() {
+			continue
+		}
+		termination := api.ContainerTermination{
+			ExitCode:   info.State.ExitCode,
+			Reason:     "Completed",
+			StartedAt:  util.Time{Time: info.State.StartedAt},
+			FinishedAt: util.Time{Time: info.State.FinishedAt},
+		}
+		if termination.ExitCode != 0 {
+			termination.Reason = "Error"
+		}
+		if terminations == nil {
+			terminations = map[string]api.ContainerTermination{}
+		}
+		terminations[container.Name] = termination
+	}
+	return terminations
+}
+
 func (rs *RegistryStorage) scheduleAndCreatePod(pod api.Pod) error {
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
+	// Pods naming another scheduler are left for it to place.
+	if !scheduler.IsResponsible(scheduler.DefaultSchedulerName, pod) {
+		return rs.registry.CreatePod("", pod)
+	}
 	// TODO(lavalamp): Separate scheduler more cleanly.
 	machine, err := rs.scheduler.Schedule(pod, rs.minionLister)
 	if err != nil {
@@ -23,11 +23,14 @@ import (
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/cloudprovider/fake"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/minion"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/registrytest"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 
 	"github.com/fsouza/go-dockerclient"
 )
@@ -185,6 +188,110 @@ func TestListPodList(t *testing.T) {
 	}
 }
 
+func TestListPodListWithFields(t *testing.T) {
+	podRegistry := registrytest.NewPodRegistry(nil)
+	podRegistry.Pods = []api.Pod{
+		{JSONBase: api.JSONBase{ID: "foo"}, DesiredState: api.PodState{Host: "machine"}},
+		{JSONBase: api.JSONBase{ID: "bar"}, DesiredState: api.PodState{Host: "other"}},
+		{JSONBase: api.JSONBase{ID: "baz"}},
+	}
+	storage := RegistryStorage{
+		registry: podRegistry,
+	}
+	podsObj, err := storage.ListWithFields(labels.Everything(), labels.Set{"DesiredState.Host": "machine"}.AsSelector())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pods := podsObj.(api.PodList)
+	if len(pods.Items) != 1 || pods.Items[0].ID != "foo" {
+		t.Errorf("Unexpected pod list: %#v", pods)
+	}
+}
+
+func TestListPodListStatus(t *testing.T) {
+	podRegistry := registrytest.NewPodRegistry(nil)
+	podRegistry.Pods = []api.Pod{
+		{
+			JSONBase: api.JSONBase{
+				ID: "foo",
+			},
+			DesiredState: api.PodState{
+				Manifest: api.ContainerManifest{
+					Containers: []api.Container{{Name: "web"}},
+				},
+			},
+			CurrentState: api.PodState{
+				Host: "machine",
+			},
+		},
+	}
+	fakeGetter := FakePodInfoGetter{
+		info: api.PodInfo{
+			"web": {State: docker.State{Running: true}},
+		},
+	}
+	storage := RegistryStorage{
+		podCache: &fakeGetter,
+		registry: podRegistry,
+	}
+	podsObj, err := storage.List(labels.Everything())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pods := podsObj.(api.PodList)
+	if len(pods.Items) != 1 || pods.Items[0].CurrentState.Status != api.PodRunning {
+		t.Errorf("Expected a running pod, got %#v", pods)
+	}
+}
+
+func TestWatchPodStatus(t *testing.T) {
+	podRegistry := registrytest.NewPodRegistry(nil)
+	fakeGetter := FakePodInfoGetter{
+		info: api.PodInfo{
+			"web": {State: docker.State{Running: true}},
+		},
+	}
+	storage := RegistryStorage{
+		podCache: &fakeGetter,
+		registry: podRegistry,
+	}
+	w, err := storage.Watch(labels.Everything(), labels.Everything(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Stop()
+	go podRegistry.UpdatePod(api.Pod{
+		JSONBase:     api.JSONBase{ID: "foo"},
+		DesiredState: api.PodState{Manifest: api.ContainerManifest{Containers: []api.Container{{Name: "web"}}}},
+		CurrentState: api.PodState{Host: "machine"},
+	})
+	event := <-w.ResultChan()
+	if pod := event.Object.(*api.Pod); pod.CurrentState.Status != api.PodRunning || pod.CurrentState.Info == nil {
+		t.Errorf("Expected a running pod, got %#v", pod)
+	}
+}
+
+func TestWatchPodStatusUnknown(t *testing.T) {
+	podRegistry := registrytest.NewPodRegistry(nil)
+	storage := RegistryStorage{
+		podCache: &FakePodInfoGetter{err: client.ErrPodInfoNotAvailable},
+		registry: podRegistry,
+	}
+	w, err := storage.Watch(labels.Everything(), labels.Everything(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer w.Stop()
+	go podRegistry.UpdatePod(api.Pod{
+		JSONBase:     api.JSONBase{ID: "foo"},
+		CurrentState: api.PodState{Host: "machine", Status: api.PodWaiting},
+	})
+	event := <-w.ResultChan()
+	if pod := event.Object.(*api.Pod); pod.CurrentState.Status != "" {
+		t.Errorf("Expected a pod without a status, got %#v", pod)
+	}
+}
+
 func TestPodDecode(t *testing.T) {
 	podRegistry := registrytest.NewPodRegistry(nil)
 	storage := RegistryStorage{
@@ -355,8 +462,8 @@ func TestPodStorageValidatesCreate(t *testing.T) {
 	if c != nil {
 		t.Errorf("Expected nil channel")
 	}
-	if err == nil {
-		t.Errorf("Expected to get an error")
+	if !apiserver.IsInvalid(err) {
+		t.Errorf("Expected to get an invalid resource error, got %v", err)
 	}
 }
 
@@ -372,8 +479,8 @@ func TestPodStorageValidatesUpdate(t *testing.T) {
 	if c != nil {
 		t.Errorf("Expected nil channel")
 	}
-	if err == nil {
-		t.Errorf("Expected to get an error")
+	if !apiserver.IsInvalid(err) {
+		t.Errorf("Expected to get an invalid resource error, got %v", err)
 	}
 }
 
@@ -413,6 +520,41 @@ func TestCreatePod(t *testing.T) {
 	}
 }
 
+func TestCreatePodClaimedByOtherScheduler(t *testing.T) {
+	podRegistry := registrytest.NewPodRegistry(nil)
+	storage := RegistryStorage{
+		registry:      podRegistry,
+		podPollPeriod: time.Millisecond * 100,
+		scheduler:     &registrytest.Scheduler{Err: fmt.Errorf("unexpected scheduling")},
+		minionLister:  minion.NewRegistry([]string{"machine"}),
+	}
+	pod := &api.Pod{
+		JSONBase:    api.JSONBase{ID: "foo"},
+		Annotations: map[string]string{scheduler.SchedulerAnnotation: "batch"},
+		DesiredState: api.PodState{
+			Manifest: api.ContainerManifest{
+				Version: "v1beta1",
+			},
+		},
+	}
+	channel, err := storage.Create(pod)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	select {
+	case obj := <-channel:
+		if status, ok := obj.(*api.Status); ok {
+			t.Errorf("Unexpected status: %#v", status)
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Error("Unexpected timeout on async channel")
+	}
+	if podRegistry.Machine != "" {
+		t.Errorf("Expected the pod to be left unassigned, got %v", podRegistry.Machine)
+	}
+}
+
 type FakePodInfoGetter struct {
 	info api.PodInfo
 	err  error
@@ -448,6 +590,111 @@ func TestFillPodInfo(t *testing.T) {
 	}
 }
 
+type FakePodCache struct {
+	FakePodInfoGetter
+	conditions []api.PodCondition
+	waiting    map[string]api.ContainerWaiting
+	podIP      string
+}
+
+func (f *FakePodCache) GetPodConditions(host, podID string) []api.PodCondition {
+	return f.conditions
+}
+
+func (f *FakePodCache) GetPodWaiting(host, podID string) map[string]api.ContainerWaiting {
+	return f.waiting
+}
+
+func (f *FakePodCache) GetPodIP(host, podID string) string {
+	return f.podIP
+}
+
+func TestFillPodInfoConditions(t *testing.T) {
+	fakeCache := FakePodCache{
+		FakePodInfoGetter: FakePodInfoGetter{info: api.PodInfo{}},
+		conditions:        []api.PodCondition{api.PodReady},
+		waiting:           map[string]api.ContainerWaiting{"foo": {Reason: "DockerUnavailable"}},
+	}
+	storage := RegistryStorage{
+		podCache: &fakeCache,
+	}
+	pod := api.Pod{}
+	storage.fillPodInfo(&pod)
+	if !reflect.DeepEqual(fakeCache.conditions, pod.CurrentState.Conditions) {
+		t.Errorf("Expected %v, Got %v", fakeCache.conditions, pod.CurrentState.Conditions)
+	}
+	if !reflect.DeepEqual(fakeCache.waiting, pod.CurrentState.Waiting) {
+		t.Errorf("Expected %v, Got %v", fakeCache.waiting, pod.CurrentState.Waiting)
+	}
+}
+
+func TestFillPodInfoReportedIP(t *testing.T) {
+	fakeCache := FakePodCache{
+		FakePodInfoGetter: FakePodInfoGetter{
+			info: api.PodInfo{
+				"net": {NetworkSettings: &docker.NetworkSettings{IPAddress: "1.2.3.4"}},
+			},
+		},
+		podIP: "10.1.2.3",
+	}
+	storage := RegistryStorage{
+		podCache: &fakeCache,
+	}
+	pod := api.Pod{}
+	storage.fillPodInfo(&pod)
+	if pod.CurrentState.PodIP != "10.1.2.3" {
+		t.Errorf("Expected the reported IP, Got %s", pod.CurrentState.PodIP)
+	}
+
+	// Without a reported IP, the network container's is used.
+	fakeCache.podIP = ""
+	pod = api.Pod{}
+	storage.fillPodInfo(&pod)
+	if pod.CurrentState.PodIP != "1.2.3.4" {
+		t.Errorf("Expected the network container's IP, Got %s", pod.CurrentState.PodIP)
+	}
+}
+
+func TestFillPodInfoTerminations(t *testing.T) {
+	started := time.Unix(100, 0).UTC()
+	finished := time.Unix(200, 0).UTC()
+	fakeGetter := FakePodInfoGetter{
+		info: api.PodInfo{
+			"running":  {State: docker.State{Running: true, StartedAt: started}},
+			"done":     {State: docker.State{StartedAt: started, FinishedAt: finished}},
+			"failed":   {State: docker.State{ExitCode: 3, StartedAt: started, FinishedAt: finished}},
+			"creating": {},
+		},
+	}
+	storage := RegistryStorage{
+		podCache: &fakeGetter,
+	}
+	pod := api.Pod{
+		DesiredState: api.PodState{
+			Manifest: api.ContainerManifest{
+				Containers: []api.Container{{Name: "running"}, {Name: "done"}, {Name: "failed"}, {Name: "creating"}},
+			},
+		},
+	}
+	storage.fillPodInfo(&pod)
+	expected := map[string]api.ContainerTermination{
+		"done": {
+			Reason:     "Completed",
+			StartedAt:  util.Time{Time: started},
+			FinishedAt: util.Time{Time: finished},
+		},
+		"failed": {
+			ExitCode:   3,
+			Reason:     "Error",
+			StartedAt:  util.Time{Time: started},
+			FinishedAt: util.Time{Time: finished},
+		},
+	}
+	if !reflect.DeepEqual(expected, pod.CurrentState.Terminations) {
+		t.Errorf("Expected %#v, Got %#v", expected, pod.CurrentState.Terminations)
+	}
+}
+
 func TestFillPodInfoNoData(t *testing.T) {
 	expectedIP := ""
 	fakeGetter := FakePodInfoGetter{
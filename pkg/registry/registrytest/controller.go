@@ -27,8 +27,8 @@ type ControllerRegistry struct {
 	Controllers []api.ReplicationController
 }
 
-func (r *ControllerRegistry) ListControllers() ([]api.ReplicationController, error) {
-	return r.Controllers, r.Err
+func (r *ControllerRegistry) ListControllers() (api.ReplicationControllerList, error) {
+	return api.ReplicationControllerList{Items: r.Controllers}, r.Err
 }
 
 func (r *ControllerRegistry) GetController(ID string) (*api.ReplicationController, error) {
@@ -0,0 +1,99 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registrytest
+
+import (
+	"sync"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+)
+
+// DaemonRegistry keeps daemon controllers in memory.
+type DaemonRegistry struct {
+	lock    sync.Mutex
+	Err     error
+	Daemons map[string]api.DaemonController
+}
+
+func NewDaemonRegistry() *DaemonRegistry {
+	return &DaemonRegistry{Daemons: map[string]api.DaemonController{}}
+}
+
+func (r *DaemonRegistry) ListDaemons() (api.DaemonControllerList, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	list := api.DaemonControllerList{}
+	for _, daemon := range r.Daemons {
+		list.Items = append(list.Items, daemon)
+	}
+	return list, r.Err
+}
+
+func (r *DaemonRegistry) WatchDaemons(resourceVersion uint64) (watch.Interface, error) {
+	return nil, r.Err
+}
+
+func (r *DaemonRegistry) GetDaemon(id string) (*api.DaemonController, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	daemon, ok := r.Daemons[id]
+	if !ok {
+		return nil, apiserver.NewNotFoundErr("daemonController", id)
+	}
+	return &daemon, nil
+}
+
+func (r *DaemonRegistry) CreateDaemon(daemon api.DaemonController) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.Err != nil {
+		return r.Err
+	}
+	if _, ok := r.Daemons[daemon.ID]; ok {
+		return apiserver.NewAlreadyExistsErr("daemonController", daemon.ID)
+	}
+	r.Daemons[daemon.ID] = daemon
+	return nil
+}
+
+func (r *DaemonRegistry) UpdateDaemon(daemon api.DaemonController) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.Err != nil {
+		return r.Err
+	}
+	if _, ok := r.Daemons[daemon.ID]; !ok {
+		return apiserver.NewNotFoundErr("daemonController", daemon.ID)
+	}
+	r.Daemons[daemon.ID] = daemon
+	return nil
+}
+
+func (r *DaemonRegistry) DeleteDaemon(id string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.Err != nil {
+		return r.Err
+	}
+	delete(r.Daemons, id)
+	return nil
+}
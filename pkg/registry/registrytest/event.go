@@ -0,0 +1,60 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registrytest
+
+import (
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+)
+
+func NewEventRegistry() *EventRegistry {
+	return &EventRegistry{}
+}
+
+type EventRegistry struct {
+	List api.EventList
+	Err  error
+	// TTL is the ttl CreateEvent was last called with.
+	TTL time.Duration
+}
+
+func (r *EventRegistry) ListEvents() (api.EventList, error) {
+	return r.List, r.Err
+}
+
+func (r *EventRegistry) GetEvent(id string) (*api.Event, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	for _, event := range r.List.Items {
+		if event.ID == id {
+			return &event, nil
+		}
+	}
+	return nil, apiserver.NewNotFoundErr("event", id)
+}
+
+func (r *EventRegistry) CreateEvent(event api.Event, ttl time.Duration) error {
+	r.TTL = ttl
+	if r.Err != nil {
+		return r.Err
+	}
+	r.List.Items = append(r.List.Items, event)
+	return nil
+}
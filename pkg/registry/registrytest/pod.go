@@ -41,11 +41,11 @@ func NewPodRegistry(pods []api.Pod) *PodRegistry {
 	}
 }
 
-func (r *PodRegistry) ListPods(selector labels.Selector) ([]api.Pod, error) {
+func (r *PodRegistry) ListPods(selector labels.Selector) (api.PodList, error) {
 	r.Lock()
 	defer r.Unlock()
 	if r.Err != nil {
-		return r.Pods, r.Err
+		return api.PodList{Items: r.Pods}, r.Err
 	}
 	var filtered []api.Pod
 	for _, pod := range r.Pods {
@@ -53,7 +53,7 @@ func (r *PodRegistry) ListPods(selector labels.Selector) ([]api.Pod, error) {
 			filtered = append(filtered, pod)
 		}
 	}
-	return filtered, nil
+	return api.PodList{Items: filtered}, nil
 }
 
 func (r *PodRegistry) WatchPods(resourceVersion uint64) (watch.Interface, error) {
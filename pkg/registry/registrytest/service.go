@@ -33,6 +33,8 @@ type ServiceRegistry struct {
 	DeletedID string
 	GottenID  string
 	UpdatedID string
+	// Updated holds every service passed to UpdateService, in order.
+	Updated []api.Service
 }
 
 func (r *ServiceRegistry) ListServices() (api.ServiceList, error) {
@@ -57,9 +59,20 @@ func (r *ServiceRegistry) DeleteService(id string) error {
 
 func (r *ServiceRegistry) UpdateService(svc api.Service) error {
 	r.UpdatedID = svc.ID
+	r.Updated = append(r.Updated, svc)
 	return r.Err
 }
 
+func (r *ServiceRegistry) ListEndpoints() (api.EndpointsList, error) {
+	return api.EndpointsList{Items: []api.Endpoints{r.Endpoints}}, r.Err
+}
+
+func (r *ServiceRegistry) GetEndpoints(id string) (*api.Endpoints, error) {
+	r.GottenID = id
+	endpoints := r.Endpoints
+	return &endpoints, r.Err
+}
+
 func (r *ServiceRegistry) UpdateEndpoints(e api.Endpoints) error {
 	r.Endpoints = e
 	return r.Err
@@ -0,0 +1,276 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/golang/glog"
+)
+
+// ErrPortalNetFull is returned when every IP in the portal network is in use.
+var ErrPortalNetFull = errors.New("no portal IPs left to allocate")
+
+// ErrPortalIPInUse is returned when allocating an IP which is already allocated.
+var ErrPortalIPInUse = errors.New("portal IP is already allocated")
+
+// maxPortalNetSize bounds the number of IPs an IPAllocator keeps track of, so
+// that huge (e.g. IPv6) networks don't overflow the offset arithmetic.
+const maxPortalNetSize = 1 << 24
+
+// IPAllocator hands out the IPs of a network, excluding its network and
+// broadcast addresses. Allocations made by NewEtcdIPAllocator are kept in etcd,
+// so that they survive restarts and every apiserver sharing the etcd key hands
+// out IPs from the same pool; NewIPAllocator only keeps them in memory. Either
+// way, a PortalRepairer checks them against the PortalIPs of the services.
+type IPAllocator struct {
+	subnet *net.IPNet
+	base   *big.Int
+	size   int64
+
+	// client and key, if set, are where the allocations are kept.
+	client tools.EtcdGetSet
+	key    string
+
+	// lock guards state, which is the last known state if the allocations are
+	// kept in etcd.
+	lock  sync.Mutex
+	state ipAllocations
+}
+
+// ipAllocations is the state of an IPAllocator.
+type ipAllocations struct {
+	// Subnet is the network the offsets are relative to.
+	Subnet string `json:"subnet"`
+	// Used holds the allocated IPs, as offsets from the network address.
+	Used map[int64]bool `json:"used"`
+	// Next is the offset AllocateNext starts looking from.
+	Next int64 `json:"next"`
+}
+
+// NewIPAllocator returns an IPAllocator for subnet with nothing allocated.
+func NewIPAllocator(subnet *net.IPNet) *IPAllocator {
+	ones, bits := subnet.Mask.Size()
+	size := int64(maxPortalNetSize)
+	if bits-ones < 24 {
+		size = 1 << uint(bits-ones)
+	}
+	a := &IPAllocator{
+		subnet: subnet,
+		base:   big.NewInt(0).SetBytes(normalizeIP(subnet.IP)),
+		size:   size,
+	}
+	a.state = a.emptyState()
+	return a
+}
+
+// NewEtcdIPAllocator returns an IPAllocator for subnet which keeps its
+// allocations in etcd under key. Every change is made with a compare and swap,
+// so concurrent allocations by several apiservers never hand out an IP twice.
+func NewEtcdIPAllocator(subnet *net.IPNet, client tools.EtcdGetSet, key string) *IPAllocator {
+	a := NewIPAllocator(subnet)
+	a.client = client
+	a.key = key
+	return a
+}
+
+// emptyState returns the state of an allocator which has allocated nothing.
+func (a *IPAllocator) emptyState() ipAllocations {
+	return ipAllocations{Subnet: a.subnet.String(), Used: map[int64]bool{}, Next: 1}
+}
+
+// update calls fn with the current allocations for it to change, and stores
+// them. If the allocations are kept in etcd, fn is called again if they were
+// changed elsewhere in the meantime; nothing is stored if fn fails.
+func (a *IPAllocator) update(fn func(state *ipAllocations) error) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.client == nil {
+		return fn(&a.state)
+	}
+	for {
+		state, index, err := a.read()
+		if err != nil {
+			return err
+		}
+		if err := fn(&state); err != nil {
+			return err
+		}
+		data, err := json.Marshal(&state)
+		if err != nil {
+			return err
+		}
+		if index == 0 {
+			_, err = a.client.Create(a.key, string(data), 0)
+		} else {
+			_, err = a.client.CompareAndSwap(a.key, string(data), 0, "", index)
+		}
+		if tools.IsEtcdNodeExist(err) || tools.IsEtcdTestFailed(err) {
+			// Changed by another apiserver; try again with its allocations.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		a.state = state
+		return nil
+	}
+}
+
+// read returns the allocations kept in etcd and the index they were stored at,
+// which is 0 if nothing is stored yet. Allocations for another network, from
+// before the portal network was changed, are ignored.
+func (a *IPAllocator) read() (ipAllocations, uint64, error) {
+	state := a.emptyState()
+	resp, err := a.client.Get(a.key, false, false)
+	if tools.IsEtcdNotFound(err) {
+		return state, 0, nil
+	}
+	if err != nil {
+		return state, 0, err
+	}
+	if err := json.Unmarshal([]byte(resp.Node.Value), &state); err != nil {
+		return state, 0, fmt.Errorf("unable to decode the portal IP allocations: %v", err)
+	}
+	if state.Subnet != a.subnet.String() {
+		glog.Warningf("Discarding the portal IP allocations for %s, the portal network is now %s", state.Subnet, a.subnet)
+		state = a.emptyState()
+	}
+	if state.Used == nil {
+		state.Used = map[int64]bool{}
+	}
+	return state, resp.Node.ModifiedIndex, nil
+}
+
+// normalizeIP returns the 4 byte form of IPv4 addresses, so that they line up
+// with 4 byte network addresses.
+func normalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// offset returns the position of ip in the network, and whether ip can be
+// allocated at all.
+func (a *IPAllocator) offset(ip net.IP) (int64, bool) {
+	if !a.subnet.Contains(ip) {
+		return 0, false
+	}
+	n := big.NewInt(0).SetBytes(normalizeIP(ip))
+	offset := n.Sub(n, a.base).Int64()
+	return offset, a.usable(offset)
+}
+
+// usable returns false for offsets outside the tracked range, and for the
+// network and broadcast addresses.
+func (a *IPAllocator) usable(offset int64) bool {
+	if a.size <= 2 {
+		return offset >= 0 && offset < a.size
+	}
+	return offset > 0 && offset < a.size-1
+}
+
+// ip returns the IP at offset in the network.
+func (a *IPAllocator) ip(offset int64) net.IP {
+	n := big.NewInt(0).Add(a.base, big.NewInt(offset))
+	b := n.Bytes()
+	ip := make(net.IP, len(normalizeIP(a.subnet.IP)))
+	copy(ip[len(ip)-len(b):], b)
+	return ip
+}
+
+// Contains returns true if ip is one the allocator can hand out.
+func (a *IPAllocator) Contains(ip net.IP) bool {
+	_, ok := a.offset(ip)
+	return ok
+}
+
+// Allocate marks ip as used. It fails if ip is outside the network or already
+// allocated.
+func (a *IPAllocator) Allocate(ip net.IP) error {
+	offset, ok := a.offset(ip)
+	if !ok {
+		return fmt.Errorf("IP %s is not in the portal network %s", ip, a.subnet)
+	}
+	return a.update(func(state *ipAllocations) error {
+		if state.Used[offset] {
+			return ErrPortalIPInUse
+		}
+		state.Used[offset] = true
+		return nil
+	})
+}
+
+// AllocateNext allocates an unused IP. IPs are handed out round robin, so a
+// released IP isn't reused straight away.
+func (a *IPAllocator) AllocateNext() (net.IP, error) {
+	var ip net.IP
+	err := a.update(func(state *ipAllocations) error {
+		for i := int64(0); i < a.size; i++ {
+			offset := (state.Next + i) % a.size
+			if !a.usable(offset) || state.Used[offset] {
+				continue
+			}
+			state.Used[offset] = true
+			state.Next = offset + 1
+			ip = a.ip(offset)
+			return nil
+		}
+		return ErrPortalNetFull
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ip, nil
+}
+
+// Release marks ip as unused. Releasing an IP which isn't allocated does nothing.
+func (a *IPAllocator) Release(ip net.IP) error {
+	offset, ok := a.offset(ip)
+	if !ok {
+		return nil
+	}
+	return a.update(func(state *ipAllocations) error {
+		delete(state.Used, offset)
+		return nil
+	})
+}
+
+// allocated returns the IPs currently in use.
+func (a *IPAllocator) allocated() ([]net.IP, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	state := a.state
+	if a.client != nil {
+		var err error
+		if state, _, err = a.read(); err != nil {
+			return nil, err
+		}
+	}
+	ips := make([]net.IP, 0, len(state.Used))
+	for offset := range state.Used {
+		ips = append(ips, a.ip(offset))
+	}
+	return ips, nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/registrytest"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+func makeIPNet(t *testing.T, cidr string) *net.IPNet {
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return subnet
+}
+
+func TestIPAllocatorAllocateNext(t *testing.T) {
+	alloc := NewIPAllocator(makeIPNet(t, "10.0.0.0/30"))
+	for _, expected := range []string{"10.0.0.1", "10.0.0.2"} {
+		ip, err := alloc.AllocateNext()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ip.String() != expected {
+			t.Errorf("expected %s, got %s", expected, ip)
+		}
+	}
+	if _, err := alloc.AllocateNext(); err != ErrPortalNetFull {
+		t.Errorf("expected ErrPortalNetFull, got %v", err)
+	}
+
+	alloc.Release(net.ParseIP("10.0.0.1"))
+	ip, err := alloc.AllocateNext()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ip.String() != "10.0.0.1" {
+		t.Errorf("expected the released IP, got %s", ip)
+	}
+}
+
+func TestIPAllocatorAllocate(t *testing.T) {
+	alloc := NewIPAllocator(makeIPNet(t, "10.0.0.0/24"))
+	table := []struct {
+		ip    string
+		valid bool
+	}{
+		{"10.0.0.5", true},
+		{"10.0.0.5", false},
+		{"10.0.0.0", false},
+		{"10.0.0.255", false},
+		{"10.0.1.5", false},
+	}
+	for _, item := range table {
+		err := alloc.Allocate(net.ParseIP(item.ip))
+		if item.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", item.ip, err)
+		}
+		if !item.valid && err == nil {
+			t.Errorf("%s: expected an error", item.ip)
+		}
+	}
+	if ip, _ := alloc.AllocateNext(); ip.String() != "10.0.0.1" {
+		t.Errorf("expected 10.0.0.1, got %s", ip)
+	}
+}
+
+func makePortalService(id, ip string, age int) api.Service {
+	return api.Service{
+		JSONBase: api.JSONBase{
+			ID:                id,
+			CreationTimestamp: util.Time{Time: time.Unix(1000-int64(age), 0)},
+		},
+		PortalIP: ip,
+	}
+}
+
+func TestPortalRepairerDoubleAllocation(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	registry.List.Items = []api.Service{
+		makePortalService("young", "10.0.0.1", 1),
+		makePortalService("old", "10.0.0.1", 2),
+		makePortalService("none", "", 3),
+		makePortalService("outside", "192.168.0.1", 4),
+		makePortalService("headless", api.PortalIPNone, 5),
+	}
+	alloc := NewIPAllocator(makeIPNet(t, "10.0.0.0/24"))
+	err := NewPortalRepairer(registry, alloc).Repair()
+	if err == nil || strings.Contains(err.Error(), "young") || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("expected only the service outside the portal network to be reported, got %v", err)
+	}
+
+	updated := map[string]string{}
+	for _, service := range registry.Updated {
+		updated[service.ID] = service.PortalIP
+	}
+	// The younger service sharing an IP gets a new one. The one outside the
+	// portal network may be in use, so it keeps its IP.
+	if len(updated) != 2 {
+		t.Errorf("expected young and none to be updated: %#v", updated)
+	}
+	for _, id := range []string{"young", "none"} {
+		if ip := updated[id]; ip == "10.0.0.1" || !alloc.Contains(net.ParseIP(ip)) {
+			t.Errorf("bad IP %s given to %s", ip, id)
+		}
+	}
+	if updated["young"] == updated["none"] {
+		t.Errorf("young and none were given the same IP %s", updated["none"])
+	}
+	if err := alloc.Allocate(net.ParseIP("10.0.0.1")); err != ErrPortalIPInUse {
+		t.Errorf("expected 10.0.0.1 to be allocated, got %v", err)
+	}
+}
+
+func TestEtcdIPAllocatorSharesAllocations(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	fakeClient.ExpectNotFoundGet("/registry/portalIPs")
+	subnet := makeIPNet(t, "10.0.0.0/29")
+	a := NewEtcdIPAllocator(subnet, fakeClient, "/registry/portalIPs")
+	b := NewEtcdIPAllocator(subnet, fakeClient, "/registry/portalIPs")
+
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		for _, alloc := range []*IPAllocator{a, b} {
+			ip, err := alloc.AllocateNext()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if seen[ip.String()] {
+				t.Errorf("%s was handed out twice", ip)
+			}
+			seen[ip.String()] = true
+		}
+	}
+	if _, err := a.AllocateNext(); err != ErrPortalNetFull {
+		t.Errorf("expected ErrPortalNetFull, got %v", err)
+	}
+
+	// A restarted apiserver knows what is allocated.
+	c := NewEtcdIPAllocator(subnet, fakeClient, "/registry/portalIPs")
+	if err := c.Allocate(net.ParseIP("10.0.0.1")); err != ErrPortalIPInUse {
+		t.Errorf("expected 10.0.0.1 to be allocated, got %v", err)
+	}
+	if err := b.Release(net.ParseIP("10.0.0.1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := c.Allocate(net.ParseIP("10.0.0.1")); err != nil {
+		t.Errorf("expected the released IP to be allocated, got %v", err)
+	}
+}
+
+func TestEtcdIPAllocatorDiscardsOtherNetwork(t *testing.T) {
+	fakeClient := tools.NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	fakeClient.ExpectNotFoundGet("/registry/portalIPs")
+	old := NewEtcdIPAllocator(makeIPNet(t, "10.0.0.0/24"), fakeClient, "/registry/portalIPs")
+	if err := old.Allocate(net.ParseIP("10.0.0.1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	alloc := NewEtcdIPAllocator(makeIPNet(t, "10.1.0.0/24"), fakeClient, "/registry/portalIPs")
+	if err := alloc.Allocate(net.ParseIP("10.1.0.1")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPortalRepairerReleasesLeaks(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	registry.List.Items = []api.Service{makePortalService("foo", "10.0.0.1", 1)}
+	alloc := NewIPAllocator(makeIPNet(t, "10.0.0.0/24"))
+	leaked := net.ParseIP("10.0.0.7")
+	alloc.Allocate(leaked)
+
+	repairer := NewPortalRepairer(registry, alloc)
+	if err := repairer.Repair(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if alloc.Allocate(leaked) == nil {
+		t.Fatalf("a leak should survive the first repair")
+	}
+	if err := repairer.Repair(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := alloc.Allocate(leaked); err != nil {
+		t.Errorf("expected the leak to be released: %v", err)
+	}
+	if alloc.Allocate(net.ParseIP("10.0.0.1")) == nil {
+		t.Errorf("the IP of foo should stay allocated")
+	}
+}
@@ -27,5 +27,7 @@ type Registry interface {
 	GetService(name string) (*api.Service, error)
 	DeleteService(name string) error
 	UpdateService(svc api.Service) error
+	ListEndpoints() (api.EndpointsList, error)
+	GetEndpoints(name string) (*api.Endpoints, error)
 	UpdateEndpoints(e api.Endpoints) error
 }
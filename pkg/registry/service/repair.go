@@ -0,0 +1,146 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/golang/glog"
+)
+
+// PortalRepairer checks the allocations of an IPAllocator against the portal IPs
+// recorded in the service registry. It allocates IPs which are in use but not
+// allocated, releases allocated IPs which are no longer in use, and gives
+// services without a portal IP one. When several services share a portal IP,
+// the oldest keeps it and the others are given fresh ones. Services whose portal
+// IP is outside the portal network are reported, but left alone: the network
+// may have been changed under them, and clients may be using their IPs.
+type PortalRepairer struct {
+	registry Registry
+	alloc    *IPAllocator
+
+	// suspects holds the IPs which were allocated but not used by any service
+	// at the last repair. They are only released once a second repair finds
+	// them unused, so that an IP allocated by a create still in flight isn't
+	// handed out twice.
+	suspects map[string]bool
+}
+
+// NewPortalRepairer returns a PortalRepairer which keeps alloc in line with registry.
+func NewPortalRepairer(registry Registry, alloc *IPAllocator) *PortalRepairer {
+	return &PortalRepairer{
+		registry: registry,
+		alloc:    alloc,
+		suspects: map[string]bool{},
+	}
+}
+
+// byCreationTimestamp sorts services oldest first.
+type byCreationTimestamp []api.Service
+
+func (s byCreationTimestamp) Len() int      { return len(s) }
+func (s byCreationTimestamp) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byCreationTimestamp) Less(i, j int) bool {
+	if s[i].CreationTimestamp.Equal(s[j].CreationTimestamp.Time) {
+		return s[i].ID < s[j].ID
+	}
+	return s[i].CreationTimestamp.Before(s[j].CreationTimestamp.Time)
+}
+
+// Repair makes one pass over the registry. It returns an error describing the
+// services with portal IPs outside the portal network, if there are any, once
+// the rest is repaired.
+func (p *PortalRepairer) Repair() error {
+	list, err := p.registry.ListServices()
+	if err != nil {
+		return err
+	}
+	services := list.Items
+	sort.Sort(byCreationTimestamp(services))
+
+	owners := map[string]string{}
+	var needIP []api.Service
+	var conflicts []string
+	for _, service := range services {
+		if service.PortalIP == api.PortalIPNone {
+			continue
+		}
+		if service.PortalIP == "" {
+			needIP = append(needIP, service)
+			continue
+		}
+		ip := net.ParseIP(service.PortalIP)
+		if ip == nil || !p.alloc.Contains(ip) {
+			conflicts = append(conflicts, fmt.Sprintf("%s has portal IP %s outside the portal network", service.ID, service.PortalIP))
+			continue
+		}
+		if owner, ok := owners[ip.String()]; ok {
+			glog.Warningf("Service %s has portal IP %s of the older %s, giving it a new one", service.ID, service.PortalIP, owner)
+			needIP = append(needIP, service)
+			continue
+		}
+		owners[ip.String()] = service.ID
+		// The IP may already be allocated; that's fine, it's ours.
+		if err := p.alloc.Allocate(ip); err != nil && err != ErrPortalIPInUse {
+			return err
+		}
+	}
+
+	allocated, err := p.alloc.allocated()
+	if err != nil {
+		return err
+	}
+	for _, ip := range allocated {
+		key := ip.String()
+		if _, ok := owners[key]; ok {
+			delete(p.suspects, key)
+			continue
+		}
+		if p.suspects[key] {
+			glog.Infof("Releasing leaked portal IP %s", key)
+			if err := p.alloc.Release(ip); err != nil {
+				return err
+			}
+			delete(p.suspects, key)
+			continue
+		}
+		p.suspects[key] = true
+	}
+
+	for _, service := range needIP {
+		ip, err := p.alloc.AllocateNext()
+		if err != nil {
+			return err
+		}
+		service.PortalIP = ip.String()
+		if err := p.registry.UpdateService(service); err != nil {
+			if err := p.alloc.Release(ip); err != nil {
+				glog.Errorf("Failed to release portal IP %s: %v", ip, err)
+			}
+			return err
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("services with conflicting portal IPs need to be fixed by hand: %s", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
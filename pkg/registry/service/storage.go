@@ -18,15 +18,16 @@ package service
 
 import (
 	"fmt"
-	"strconv"
-	"strings"
+	"net"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api/errors"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/cloudprovider"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/minion"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+	"github.com/golang/glog"
 )
 
 // RegistryStorage adapts a service registry into apiserver's RESTStorage model.
@@ -34,61 +35,148 @@ type RegistryStorage struct {
 	registry Registry
 	cloud    cloudprovider.Interface
 	machines minion.Registry
+	portals  *IPAllocator
+	// nil means services may have any port.
+	ports *util.PortRange
 }
 
-// NewRegistryStorage returns a new RegistryStorage.
+// NewRegistryStorage returns a new RegistryStorage which doesn't assign portal IPs.
 func NewRegistryStorage(registry Registry, cloud cloudprovider.Interface, machines minion.Registry) apiserver.RESTStorage {
+	return NewRegistryStorageWithPortals(registry, cloud, machines, nil, nil)
+}
+
+// NewRegistryStorageWithPortals returns a new RegistryStorage which gives each
+// service a portal IP from portals. Given ports, the port of every service
+// without a portal IP has to be in it, so that the ports proxies listen on for
+// such services stay clear of the daemons of hosts.
+func NewRegistryStorageWithPortals(registry Registry, cloud cloudprovider.Interface, machines minion.Registry, portals *IPAllocator, ports *util.PortRange) apiserver.RESTStorage {
 	return &RegistryStorage{
 		registry: registry,
 		cloud:    cloud,
 		machines: machines,
+		portals:  portals,
+		ports:    ports,
 	}
 }
 
-func (rs *RegistryStorage) Create(obj interface{}) (<-chan interface{}, error) {
-	srv := obj.(*api.Service)
-	if errs := api.ValidateService(srv); len(errs) > 0 {
-		return nil, fmt.Errorf("Validation errors: %v", errs)
+// validate checks srv, and that its port is in the service port range if any
+// and srv has no portal IP, so it is called once srv got its portal IP. Services
+// with a portal IP are reached on it, and headless services through no proxy,
+// so they may have any port. previous
+// is the stored service srv updates, or nil; a port it already had is kept even
+// if out of range, since the range may be newer than the service.
+func (rs *RegistryStorage) validate(srv, previous *api.Service) error {
+	errs := api.ValidateService(srv)
+	if rs.ports != nil && srv.PortalIP == "" && !rs.ports.Contains(srv.Port) && (previous == nil || previous.Port != srv.Port) {
+		errs = append(errs, errors.NewInvalid("Service.Port", srv.Port))
+	}
+	if len(errs) > 0 {
+		return apiserver.NewInvalidErr("service", srv.ID, errs)
+	}
+	return nil
+}
+
+// allocatePortal assigns srv the portal IP it asked for, or the next free one.
+// Headless services get none.
+func (rs *RegistryStorage) allocatePortal(srv *api.Service) error {
+	if rs.portals == nil || srv.PortalIP == api.PortalIPNone {
+		return nil
+	}
+	if srv.PortalIP == "" {
+		ip, err := rs.portals.AllocateNext()
+		if err != nil {
+			return err
+		}
+		srv.PortalIP = ip.String()
+		return nil
+	}
+	ip := net.ParseIP(srv.PortalIP)
+	if ip == nil || !rs.portals.Contains(ip) {
+		return apiserver.NewInvalidErr("service", srv.ID, errors.ErrorList{errors.NewInvalid("Service.PortalIP", srv.PortalIP)})
+	}
+	if err := rs.portals.Allocate(ip); err != nil {
+		if err == ErrPortalIPInUse {
+			return apiserver.NewInvalidErr("service", srv.ID, errors.ErrorList{errors.NewDuplicate("Service.PortalIP", srv.PortalIP)})
+		}
+		return err
 	}
+	return nil
+}
 
+// releasePortal returns the portal IP of srv to the allocator. If that fails, the
+// IP is left to a PortalRepairer to release.
+func (rs *RegistryStorage) releasePortal(srv *api.Service) {
+	if rs.portals == nil || srv.PortalIP == "" || srv.PortalIP == api.PortalIPNone {
+		return
+	}
+	if err := rs.portals.Release(net.ParseIP(srv.PortalIP)); err != nil {
+		glog.Errorf("Unable to release portal IP %s of %s: %v", srv.PortalIP, srv.ID, err)
+	}
+}
+
+func (rs *RegistryStorage) Create(obj interface{}) (<-chan interface{}, error) {
+	srv := obj.(*api.Service)
 	srv.CreationTimestamp = util.Now()
+	if err := rs.allocatePortal(srv); err != nil {
+		return nil, err
+	}
+	if err := rs.validate(srv, nil); err != nil {
+		rs.releasePortal(srv)
+		return nil, err
+	}
 
 	return apiserver.MakeAsync(func() (interface{}, error) {
-		// TODO: Consider moving this to a rectification loop, so that we make/remove external load balancers
-		// correctly no matter what http operations happen.
-		if srv.CreateExternalLoadBalancer {
-			if rs.cloud == nil {
-				return nil, fmt.Errorf("requested an external service, but no cloud provider supplied.")
-			}
-			balancer, ok := rs.cloud.TCPLoadBalancer()
-			if !ok {
-				return nil, fmt.Errorf("The cloud provider does not support external TCP load balancers.")
-			}
-			zones, ok := rs.cloud.Zones()
-			if !ok {
-				return nil, fmt.Errorf("The cloud provider does not support zone enumeration.")
-			}
-			hosts, err := rs.machines.List()
-			if err != nil {
-				return nil, err
-			}
-			zone, err := zones.GetZone()
-			if err != nil {
-				return nil, err
-			}
-			err = balancer.CreateTCPLoadBalancer(srv.ID, zone.Region, srv.Port, hosts)
-			if err != nil {
-				return nil, err
-			}
-		}
-		err := rs.registry.CreateService(*srv)
+		obj, err := rs.create(srv)
 		if err != nil {
-			return nil, err
+			rs.releasePortal(srv)
 		}
-		return rs.registry.GetService(srv.ID)
+		return obj, err
 	}), nil
 }
 
+func (rs *RegistryStorage) create(srv *api.Service) (interface{}, error) {
+	// TODO: Consider moving this to a rectification loop, so that we make/remove external load balancers
+	// correctly no matter what http operations happen.
+	if srv.CreateExternalLoadBalancer {
+		if rs.cloud == nil {
+			return nil, fmt.Errorf("requested an external service, but no cloud provider supplied.")
+		}
+		balancer, ok := rs.cloud.TCPLoadBalancer()
+		if !ok {
+			return nil, fmt.Errorf("The cloud provider does not support external TCP load balancers.")
+		}
+		zones, ok := rs.cloud.Zones()
+		if !ok {
+			return nil, fmt.Errorf("The cloud provider does not support zone enumeration.")
+		}
+		hosts, err := rs.machines.List()
+		if err != nil {
+			return nil, err
+		}
+		zone, err := zones.GetZone()
+		if err != nil {
+			return nil, err
+		}
+		// The load balancer forwards the first public IP of the service, if any.
+		var externalIP net.IP
+		if len(srv.PublicIPs) > 0 {
+			externalIP = net.ParseIP(srv.PublicIPs[0])
+		}
+		ip, err := balancer.CreateTCPLoadBalancer(srv.ID, zone.Region, externalIP, srv.Port, hosts)
+		if err != nil {
+			return nil, err
+		}
+		if len(srv.PublicIPs) == 0 && ip != nil {
+			srv.PublicIPs = []string{ip.String()}
+		}
+	}
+	err := rs.registry.CreateService(*srv)
+	if err != nil {
+		return nil, err
+	}
+	return rs.registry.GetService(srv.ID)
+}
+
 func (rs *RegistryStorage) Delete(id string) (<-chan interface{}, error) {
 	service, err := rs.registry.GetService(id)
 	if err != nil {
@@ -96,7 +184,11 @@ func (rs *RegistryStorage) Delete(id string) (<-chan interface{}, error) {
 	}
 	return apiserver.MakeAsync(func() (interface{}, error) {
 		rs.deleteExternalLoadBalancer(service)
-		return &api.Status{Status: api.StatusSuccess}, rs.registry.DeleteService(id)
+		if err := rs.registry.DeleteService(id); err != nil {
+			return nil, err
+		}
+		rs.releasePortal(service)
+		return &api.Status{Status: api.StatusSuccess}, nil
 	}), nil
 }
 
@@ -127,31 +219,25 @@ func (rs RegistryStorage) New() interface{} {
 	return &api.Service{}
 }
 
-// GetServiceEnvironmentVariables populates a list of environment variables that are use
-// in the container environment to get access to services.
-func GetServiceEnvironmentVariables(registry Registry, machine string) ([]api.EnvVar, error) {
-	var result []api.EnvVar
-	services, err := registry.ListServices()
-	if err != nil {
-		return result, err
-	}
-	for _, service := range services.Items {
-		name := makeEnvVariableName(service.ID) + "_SERVICE_PORT"
-		value := strconv.Itoa(service.Port)
-		result = append(result, api.EnvVar{Name: name, Value: value})
-		result = append(result, makeLinkVariables(service, machine)...)
-	}
-	result = append(result, api.EnvVar{Name: "SERVICE_HOST", Value: machine})
-	return result, nil
-}
-
 func (rs *RegistryStorage) Update(obj interface{}) (<-chan interface{}, error) {
 	srv := obj.(*api.Service)
 	if srv.ID == "" {
 		return nil, fmt.Errorf("ID should not be empty: %#v", srv)
 	}
-	if errs := api.ValidateService(srv); len(errs) > 0 {
-		return nil, fmt.Errorf("Validation errors: %v", errs)
+	existing, err := rs.registry.GetService(srv.ID)
+	if err != nil {
+		return nil, err
+	}
+	if rs.portals != nil {
+		if srv.PortalIP == "" {
+			srv.PortalIP = existing.PortalIP
+		}
+		if srv.PortalIP != existing.PortalIP {
+			return nil, apiserver.NewInvalidErr("service", srv.ID, errors.ErrorList{errors.NewInvalid("Service.PortalIP", srv.PortalIP)})
+		}
+	}
+	if err := rs.validate(srv, existing); err != nil {
+		return nil, err
 	}
 	return apiserver.MakeAsync(func() (interface{}, error) {
 		// TODO: check to see if external load balancer status changed
@@ -188,40 +274,3 @@ func (rs *RegistryStorage) deleteExternalLoadBalancer(service *api.Service) erro
 	}
 	return nil
 }
-
-func makeEnvVariableName(str string) string {
-	return strings.ToUpper(strings.Replace(str, "-", "_", -1))
-}
-
-func makeLinkVariables(service api.Service, machine string) []api.EnvVar {
-	prefix := makeEnvVariableName(service.ID)
-	var port string
-	if service.ContainerPort.Kind == util.IntstrString {
-		port = service.ContainerPort.StrVal
-	} else {
-		port = strconv.Itoa(service.ContainerPort.IntVal)
-	}
-	portPrefix := prefix + "_PORT_" + makeEnvVariableName(port) + "_TCP"
-	return []api.EnvVar{
-		{
-			Name:  prefix + "_PORT",
-			Value: fmt.Sprintf("tcp://%s:%d", machine, service.Port),
-		},
-		{
-			Name:  portPrefix,
-			Value: fmt.Sprintf("tcp://%s:%d", machine, service.Port),
-		},
-		{
-			Name:  portPrefix + "_PROTO",
-			Value: "tcp",
-		},
-		{
-			Name:  portPrefix + "_PORT",
-			Value: strconv.Itoa(service.Port),
-		},
-		{
-			Name:  portPrefix + "_ADDR",
-			Value: machine,
-		},
-	}
-}
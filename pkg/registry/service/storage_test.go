@@ -18,9 +18,12 @@ package service
 
 import (
 	"fmt"
+	"net"
+	"reflect"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/cloudprovider/fake"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/registry/minion"
@@ -66,9 +69,9 @@ func TestServiceStorageValidatesCreate(t *testing.T) {
 			JSONBase: api.JSONBase{ID: ""},
 			Selector: map[string]string{"bar": "baz"},
 		},
-		"empty selector": {
-			JSONBase: api.JSONBase{ID: "foo"},
-			Selector: map[string]string{},
+		"invalid ID": {
+			JSONBase: api.JSONBase{ID: "foo.bar"},
+			Selector: map[string]string{"bar": "baz"},
 		},
 	}
 	for _, failureCase := range failureCases {
@@ -76,8 +79,8 @@ func TestServiceStorageValidatesCreate(t *testing.T) {
 		if c != nil {
 			t.Errorf("Expected nil channel")
 		}
-		if err == nil {
-			t.Errorf("Expected to get an error")
+		if !apiserver.IsInvalid(err) {
+			t.Errorf("Expected to get an invalid resource error, got %v", err)
 		}
 	}
 }
@@ -121,9 +124,9 @@ func TestServiceStorageValidatesUpdate(t *testing.T) {
 			JSONBase: api.JSONBase{ID: ""},
 			Selector: map[string]string{"bar": "baz"},
 		},
-		"empty selector": {
-			JSONBase: api.JSONBase{ID: "foo"},
-			Selector: map[string]string{},
+		"invalid ID": {
+			JSONBase: api.JSONBase{ID: "foo.bar"},
+			Selector: map[string]string{"bar": "baz"},
 		},
 	}
 	for _, failureCase := range failureCases {
@@ -161,6 +164,42 @@ func TestServiceRegistryExternalService(t *testing.T) {
 	}
 }
 
+func TestServiceRegistryExternalServicePublicIPs(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	fakeCloud := &fake_cloud.FakeCloud{ExternalIP: net.ParseIP("1.2.3.4")}
+	machines := []string{"foo", "bar", "baz"}
+	storage := NewRegistryStorage(registry, fakeCloud, minion.NewRegistry(machines))
+	c, _ := storage.Create(&api.Service{
+		JSONBase:                   api.JSONBase{ID: "foo"},
+		Selector:                   map[string]string{"bar": "baz"},
+		CreateExternalLoadBalancer: true,
+	})
+	<-c
+	srv, err := registry.GetService("foo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(srv.PublicIPs, []string{"1.2.3.4"}) {
+		t.Errorf("Expected the IP of the load balancer to be recorded, got %#v", srv.PublicIPs)
+	}
+
+	// The load balancer takes the public IP the service asks for.
+	c, _ = storage.Create(&api.Service{
+		JSONBase:                   api.JSONBase{ID: "bar"},
+		Selector:                   map[string]string{"bar": "baz"},
+		CreateExternalLoadBalancer: true,
+		PublicIPs:                  []string{"5.6.7.8"},
+	})
+	<-c
+	srv, err = registry.GetService("bar")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(srv.PublicIPs, []string{"5.6.7.8"}) {
+		t.Errorf("Expected the requested public IP, got %#v", srv.PublicIPs)
+	}
+}
+
 func TestServiceRegistryExternalServiceError(t *testing.T) {
 	registry := registrytest.NewServiceRegistry()
 	fakeCloud := &fake_cloud.FakeCloud{
@@ -224,28 +263,6 @@ func TestServiceRegistryDeleteExternal(t *testing.T) {
 	}
 }
 
-func TestServiceRegistryMakeLinkVariables(t *testing.T) {
-	service := api.Service{
-		JSONBase:      api.JSONBase{ID: "foo-bar"},
-		Selector:      map[string]string{"bar": "baz"},
-		ContainerPort: util.IntOrString{Kind: util.IntstrString, StrVal: "a-b-c"},
-	}
-	registry := registrytest.NewServiceRegistry()
-	registry.List = api.ServiceList{
-		Items: []api.Service{service},
-	}
-	machine := "machine"
-	vars, err := GetServiceEnvironmentVariables(registry, machine)
-	if err != nil {
-		t.Errorf("Unexpected err: %v", err)
-	}
-	for _, v := range vars {
-		if !util.IsCIdentifier(v.Name) {
-			t.Errorf("Environment variable name is not valid: %v", v.Name)
-		}
-	}
-}
-
 func TestServiceRegistryGet(t *testing.T) {
 	registry := registrytest.NewServiceRegistry()
 	fakeCloud := &fake_cloud.FakeCloud{}
@@ -292,3 +309,124 @@ func TestServiceRegistryList(t *testing.T) {
 		t.Errorf("Expected %v, but got %v", e, a)
 	}
 }
+
+func TestServiceRegistryPortalIPs(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	alloc := NewIPAllocator(subnet)
+	storage := NewRegistryStorageWithPortals(registry, nil, minion.NewRegistry(nil), alloc, nil)
+
+	c, err := storage.Create(&api.Service{JSONBase: api.JSONBase{ID: "foo"}, Selector: map[string]string{"bar": "baz"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	created := (<-c).(*api.Service)
+	if created.PortalIP != "10.0.0.1" {
+		t.Errorf("expected 10.0.0.1, got %q", created.PortalIP)
+	}
+
+	_, err = storage.Create(&api.Service{JSONBase: api.JSONBase{ID: "bar"}, Selector: map[string]string{"bar": "baz"}, PortalIP: "10.0.0.1"})
+	if !apiserver.IsInvalid(err) {
+		t.Errorf("expected an invalid error for a taken IP, got %v", err)
+	}
+
+	_, err = storage.Update(&api.Service{JSONBase: api.JSONBase{ID: "foo"}, Selector: map[string]string{"bar": "baz"}, PortalIP: "10.0.0.2"})
+	if !apiserver.IsInvalid(err) {
+		t.Errorf("expected an invalid error for a changed IP, got %v", err)
+	}
+	c, err = storage.Update(&api.Service{JSONBase: api.JSONBase{ID: "foo"}, Selector: map[string]string{"bar": "baz"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-c
+	if ip := registry.Updated[0].PortalIP; ip != "10.0.0.1" {
+		t.Errorf("expected the update to keep 10.0.0.1, got %q", ip)
+	}
+
+	c, err = storage.Delete("foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-c
+	if err := alloc.Allocate(net.ParseIP("10.0.0.1")); err != nil {
+		t.Errorf("expected the IP to be released: %v", err)
+	}
+}
+
+func TestServiceRegistryPortRange(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	storage := NewRegistryStorageWithPortals(registry, nil, minion.NewRegistry(nil), nil, &util.PortRange{Base: 30000, Size: 100})
+
+	_, err := storage.Create(&api.Service{JSONBase: api.JSONBase{ID: "foo"}, Selector: map[string]string{"bar": "baz"}, Port: 22})
+	if !apiserver.IsInvalid(err) {
+		t.Errorf("expected an invalid error for a port out of range, got %v", err)
+	}
+	c, err := storage.Create(&api.Service{JSONBase: api.JSONBase{ID: "foo"}, Selector: map[string]string{"bar": "baz"}, Port: 30080})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-c
+	_, err = storage.Update(&api.Service{JSONBase: api.JSONBase{ID: "foo"}, Selector: map[string]string{"bar": "baz"}, Port: 80})
+	if !apiserver.IsInvalid(err) {
+		t.Errorf("expected an invalid error for a port out of range, got %v", err)
+	}
+
+	// Services from before the range keep their ports.
+	registry.CreateService(api.Service{JSONBase: api.JSONBase{ID: "old"}, Selector: map[string]string{"bar": "baz"}, Port: 80})
+	c, err = storage.Update(&api.Service{JSONBase: api.JSONBase{ID: "old"}, Selector: map[string]string{"bar": "qux"}, Port: 80})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-c
+	_, err = storage.Update(&api.Service{JSONBase: api.JSONBase{ID: "old"}, Selector: map[string]string{"bar": "qux"}, Port: 81})
+	if !apiserver.IsInvalid(err) {
+		t.Errorf("expected an invalid error for a port out of range, got %v", err)
+	}
+}
+
+func TestServiceRegistryPortRangeWithPortals(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	storage := NewRegistryStorageWithPortals(registry, nil, minion.NewRegistry(nil), NewIPAllocator(subnet), &util.PortRange{Base: 30000, Size: 100})
+
+	// Services with a portal IP are reached on it, so any port is fine.
+	c, err := storage.Create(&api.Service{JSONBase: api.JSONBase{ID: "foo"}, Selector: map[string]string{"bar": "baz"}, Port: 80})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created := (<-c).(*api.Service); created.PortalIP == "" {
+		t.Errorf("expected a portal IP, got %#v", created)
+	}
+
+	// Services from before portals have none, and are still reached on their
+	// port at the proxies.
+	registry.CreateService(api.Service{JSONBase: api.JSONBase{ID: "old"}, Selector: map[string]string{"bar": "baz"}, Port: 30080})
+	_, err = storage.Update(&api.Service{JSONBase: api.JSONBase{ID: "old"}, Selector: map[string]string{"bar": "baz"}, Port: 80})
+	if !apiserver.IsInvalid(err) {
+		t.Errorf("expected an invalid error for a port out of range, got %v", err)
+	}
+}
+
+func TestServiceRegistryHeadless(t *testing.T) {
+	registry := registrytest.NewServiceRegistry()
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	alloc := NewIPAllocator(subnet)
+	storage := NewRegistryStorageWithPortals(registry, nil, minion.NewRegistry(nil), alloc, &util.PortRange{Base: 30000, Size: 100})
+
+	c, err := storage.Create(&api.Service{JSONBase: api.JSONBase{ID: "db"}, Selector: map[string]string{"name": "db"}, Port: 5432, PortalIP: api.PortalIPNone})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	created := (<-c).(*api.Service)
+	if created.PortalIP != api.PortalIPNone {
+		t.Errorf("expected no portal IP, got %q", created.PortalIP)
+	}
+	c, err = storage.Delete("db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-c
+	if ip, err := alloc.AllocateNext(); err != nil || ip.String() != "10.0.0.1" {
+		t.Errorf("expected no IP to have been allocated, got %v, %v", ip, err)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+const (
+	// DefaultProvider is the name of the algorithm schedulers use unless told otherwise.
+	DefaultProvider = "DefaultProvider"
+	// ZoneSpreadingProvider scores minions like DefaultProvider, plus higher
+	// the fewer pods of the same service run in their zone, which is the value
+	// of their ZoneLabel label.
+	ZoneSpreadingProvider = "ZoneSpreadingProvider"
+
+	// ZoneLabel is the label of minions naming the zone they are in.
+	ZoneLabel = "zone"
+)
+
+func init() {
+	RegisterAlgorithmProvider(DefaultProvider, defaultPredicates(), defaultPriorities())
+	zonePriorities := defaultPriorities()
+	zonePriorities.Insert(RegisterPriorityFunctionFactory(
+		"ZoneSpreadingPriority",
+		func(args PluginFactoryArgs) PriorityFunction {
+			return NewServiceAntiAffinityPriority(args.ServiceLister, args.MinionInfo, ZoneLabel)
+		},
+		1,
+	))
+	RegisterAlgorithmProvider(ZoneSpreadingProvider, defaultPredicates(), zonePriorities)
+	// These are not in any algorithm, but can be chosen.
+	RegisterPriorityFunction("EqualPriority", EqualPriority, 1)
+	RegisterPriorityFunctionFactory(
+		"ServiceSpreadingPriority",
+		func(args PluginFactoryArgs) PriorityFunction {
+			return NewServiceAntiAffinityPriority(args.ServiceLister, args.MinionInfo, "")
+		},
+		1,
+	)
+}
+
+func defaultPredicates() util.StringSet {
+	return util.NewStringSet(
+		// Never place pods on minions which seem to be down.
+		RegisterFitPredicateFactory(
+			"MinionReady",
+			func(args PluginFactoryArgs) FitPredicate {
+				return NewMinionReadyPredicate(args.MinionInfo, DefaultMaxHeartbeatAge)
+			},
+		),
+		// Nor where their host ports are taken.
+		RegisterFitPredicate("PodFitsPorts", PodFitsPorts),
+		// Nor where the CPU or memory they ask for is lacking.
+		RegisterFitPredicateFactory(
+			"PodFitsResources",
+			func(args PluginFactoryArgs) FitPredicate {
+				return NewResourceFitPredicate(args.MinionInfo)
+			},
+		),
+		// Nor on minions without the labels of their nodeSelector.
+		RegisterFitPredicateFactory(
+			"MatchNodeSelector",
+			func(args PluginFactoryArgs) FitPredicate {
+				return NewSelectorMatchPredicate(args.MinionInfo)
+			},
+		),
+	)
+}
+
+func defaultPriorities() util.StringSet {
+	return util.NewStringSet(
+		// Spread the pods of a replication controller or service over minions.
+		RegisterPriorityFunction("SpreadingPriority", CalculateSpreadPriority, 1),
+		// Balance the CPU and memory requested of minions.
+		RegisterPriorityFunctionFactory(
+			"LeastRequestedPriority",
+			func(args PluginFactoryArgs) PriorityFunction {
+				return NewLeastRequestedPriority(args.MinionInfo)
+			},
+			1,
+		),
+	)
+}
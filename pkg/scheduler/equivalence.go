@@ -0,0 +1,136 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// equivalenceCacheTTL is how long predicate results are reused. Minions can
+// change in ways the cache doesn't notice, like their labels or readiness, so
+// results are only kept for about as long as it takes to place a burst of pods.
+const equivalenceCacheTTL = 5 * time.Second
+
+// equivalenceKey identifies the pods of one shape on one minion.
+type equivalenceKey struct {
+	podClass uint64
+	minion   string
+}
+
+// equivalenceEntry is which predicates ruled out a minion for the pods of a
+// shape, while the minion ran the pods listed in existingPods.
+type equivalenceEntry struct {
+	existingPods string
+	failed       util.StringSet
+	expires      time.Time
+}
+
+// equivalenceCache remembers the outcome of the predicates for pods of the same
+// shape, e.g. the replicas of a controller, so that when many of them are
+// created at once the predicates run only once per minion, and again on the
+// minions pods have been placed on meanwhile.
+type equivalenceCache struct {
+	lock    sync.Mutex
+	entries map[equivalenceKey]equivalenceEntry
+	ttl     time.Duration
+	lastGC  time.Time
+	// Defaults to time.Now.
+	now func() time.Time
+}
+
+func newEquivalenceCache(ttl time.Duration) *equivalenceCache {
+	return &equivalenceCache{
+		entries: map[equivalenceKey]equivalenceEntry{},
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// lookup returns which predicates ruled out minion for the pods of podClass, if
+// that is known for the pods minion runs now.
+func (c *equivalenceCache) lookup(podClass uint64, minion string, existingPods string) (util.StringSet, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[equivalenceKey{podClass, minion}]
+	if !ok || entry.existingPods != existingPods || c.now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.failed, true
+}
+
+// store remembers which predicates ruled out minion for the pods of podClass.
+func (c *equivalenceCache) store(podClass uint64, minion string, existingPods string, failed util.StringSet) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[equivalenceKey{podClass, minion}] = equivalenceEntry{
+		existingPods: existingPods,
+		failed:       failed,
+		expires:      c.now().Add(c.ttl),
+	}
+}
+
+// gc drops the expired entries, at most once per ttl, so that the cache doesn't
+// grow with every pod shape ever seen.
+func (c *equivalenceCache) gc() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	if now.Sub(c.lastGC) < c.ttl {
+		return
+	}
+	c.lastGC = now
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// getPodClass hashes the parts of pod predicates look at, so that pods which
+// only differ in their IDs hash the same. ok is false if pod can't be hashed.
+func getPodClass(pod api.Pod) (podClass uint64, ok bool) {
+	data, err := json.Marshal(struct {
+		Labels       map[string]string
+		NodeSelector map[string]string
+		Volumes      []api.Volume
+		Containers   []api.Container
+	}{pod.Labels, pod.NodeSelector, pod.DesiredState.Manifest.Volumes, pod.DesiredState.Manifest.Containers})
+	if err != nil {
+		return 0, false
+	}
+	hash := fnv.New64a()
+	hash.Write(data)
+	return hash.Sum64(), true
+}
+
+// getPodsKey identifies the set of pods, by their IDs.
+func getPodsKey(pods []api.Pod) string {
+	ids := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		ids = append(ids, pod.ID)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
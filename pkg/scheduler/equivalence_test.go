@@ -0,0 +1,107 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+func TestGetPodClass(t *testing.T) {
+	a := newPod("", 8080)
+	a.ID = "a"
+	a.DesiredState.Manifest.ID = "a"
+	b := newPod("", 8080)
+	b.ID = "b"
+	b.DesiredState.Manifest.ID = "b"
+	c := newPod("", 9090)
+
+	classA, ok := getPodClass(a)
+	if !ok {
+		t.Fatalf("expected a to be hashed")
+	}
+	if classB, _ := getPodClass(b); classA != classB {
+		t.Errorf("expected pods differing only in their IDs to be of the same class")
+	}
+	if classC, _ := getPodClass(c); classA == classC {
+		t.Errorf("expected pods asking for different ports to be of different classes")
+	}
+	b.NodeSelector = map[string]string{"disk": "ssd"}
+	if classB, _ := getPodClass(b); classA == classB {
+		t.Errorf("expected pods with different node selectors to be of different classes")
+	}
+}
+
+func TestEquivalenceCache(t *testing.T) {
+	now := time.Now()
+	evaluated := map[string]int{}
+	predicates := map[string]FitPredicate{
+		"counting": func(pod api.Pod, existingPods []api.Pod, node string) (bool, error) {
+			evaluated[node]++
+			return node != "2", nil
+		},
+	}
+	pods := FakePodLister{}
+	cache := newEquivalenceCache(time.Second)
+	cache.now = func() time.Time { return now }
+	schedule := func(pod api.Pod) []string {
+		filtered, _, err := findNodesThatFit(pod, pods, predicates, []string{"1", "2"}, cache)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return filtered
+	}
+
+	for i := 0; i < 3; i++ {
+		if filtered := schedule(newPod("", 8080)); len(filtered) != 1 || filtered[0] != "1" {
+			t.Errorf("unexpected minions: %v", filtered)
+		}
+	}
+	if evaluated["1"] != 1 || evaluated["2"] != 1 {
+		t.Errorf("expected the predicates to run once per minion for pods of one shape, got %v", evaluated)
+	}
+
+	// A pod of another shape is evaluated anew.
+	schedule(newPod("", 9090))
+	if evaluated["1"] != 2 || evaluated["2"] != 2 {
+		t.Errorf("expected the predicates to run for a pod of another shape, got %v", evaluated)
+	}
+
+	// So are minions whose pods changed.
+	placed := newPod("1", 8080)
+	placed.ID = "placed"
+	pods = FakePodLister{placed}
+	if filtered := schedule(newPod("", 8080)); len(filtered) != 1 {
+		t.Errorf("unexpected minions: %v", filtered)
+	}
+	if evaluated["1"] != 3 || evaluated["2"] != 2 {
+		t.Errorf("expected the predicates to run again only on the minion a pod was placed on, got %v", evaluated)
+	}
+
+	// And everything once the cache expired.
+	now = now.Add(2 * time.Second)
+	schedule(newPod("", 8080))
+	if evaluated["1"] != 4 || evaluated["2"] != 3 {
+		t.Errorf("expected the predicates to run again after the cache expired, got %v", evaluated)
+	}
+	cache.gc()
+	if len(cache.entries) != 2 {
+		t.Errorf("expected expired entries to be dropped, got %v", cache.entries)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+// DefaultExtenderTimeout is how long a call to an extender may take unless its
+// ExtenderConfig says otherwise.
+const DefaultExtenderTimeout = 5 * time.Second
+
+// SchedulerExtender is placement logic living outside of the scheduler, which
+// it consults after its own predicates have ruled out minions.
+type SchedulerExtender interface {
+	// Name identifies the extender in FitErrors.
+	Name() string
+	// Filter returns those of minions on which pod may be placed.
+	Filter(pod api.Pod, minions []string) ([]string, error)
+}
+
+// ExtenderConfig tells the scheduler how to reach an HTTP extender.
+type ExtenderConfig struct {
+	// URLPrefix is what the verbs are appended to, e.g. "http://127.0.0.1:12346/scheduler".
+	URLPrefix string `json:"urlPrefix"`
+	// FilterVerb is the path below URLPrefix minions are filtered at. Empty
+	// means the extender doesn't filter.
+	FilterVerb string `json:"filterVerb,omitempty"`
+	// PrioritizeVerb is the path below URLPrefix minions are scored at. Empty
+	// means the extender doesn't prioritize.
+	PrioritizeVerb string `json:"prioritizeVerb,omitempty"`
+	// Weight is what the scores of the extender are multiplied with before
+	// they are added to those of the priority functions. Defaults to 1.
+	Weight int `json:"weight,omitempty"`
+	// TimeoutSeconds bounds each call; zero means DefaultExtenderTimeout.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// ExtenderArgs is what an HTTP extender is POSTed as JSON.
+type ExtenderArgs struct {
+	Pod     api.Pod  `json:"pod"`
+	Minions []string `json:"minions"`
+}
+
+// ExtenderFilterResult is what an HTTP extender answers a filter call with.
+type ExtenderFilterResult struct {
+	// Minions are those of the minions asked about which fit the pod.
+	Minions []string `json:"minions"`
+	// Error, if set, fails the scheduling attempt.
+	Error string `json:"error,omitempty"`
+}
+
+// ExtenderHostPriority is how an HTTP extender scores one minion, from 0 to 10.
+type ExtenderHostPriority struct {
+	Host  string `json:"host"`
+	Score int    `json:"score"`
+}
+
+// HTTPExtender is a SchedulerExtender reached over HTTP.
+type HTTPExtender struct {
+	config ExtenderConfig
+	client *http.Client
+}
+
+// NewHTTPExtender returns the extender config describes.
+func NewHTTPExtender(config ExtenderConfig) (*HTTPExtender, error) {
+	if config.URLPrefix == "" {
+		return nil, fmt.Errorf("extender has no urlPrefix")
+	}
+	if config.FilterVerb == "" && config.PrioritizeVerb == "" {
+		return nil, fmt.Errorf("extender %s neither filters nor prioritizes", config.URLPrefix)
+	}
+	timeout := DefaultExtenderTimeout
+	if config.TimeoutSeconds > 0 {
+		timeout = time.Duration(config.TimeoutSeconds) * time.Second
+	}
+	return &HTTPExtender{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (h *HTTPExtender) Name() string {
+	return "extender " + h.config.URLPrefix
+}
+
+// Filter asks the extender which of minions fit pod.
+func (h *HTTPExtender) Filter(pod api.Pod, minions []string) ([]string, error) {
+	if h.config.FilterVerb == "" {
+		return minions, nil
+	}
+	var result ExtenderFilterResult
+	if err := h.send(h.config.FilterVerb, ExtenderArgs{Pod: pod, Minions: minions}, &result); err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("%s: %s", h.Name(), result.Error)
+	}
+	return result.Minions, nil
+}
+
+// Prioritize asks the extender to score the minions minionLister lists. It is
+// a PriorityFunction.
+func (h *HTTPExtender) Prioritize(pod api.Pod, podLister PodLister, minionLister MinionLister) (HostPriorityList, error) {
+	if h.config.PrioritizeVerb == "" {
+		return nil, nil
+	}
+	minions, err := minionLister.List()
+	if err != nil {
+		return nil, err
+	}
+	var result []ExtenderHostPriority
+	if err := h.send(h.config.PrioritizeVerb, ExtenderArgs{Pod: pod, Minions: minions}, &result); err != nil {
+		return nil, err
+	}
+	priorities := HostPriorityList{}
+	for _, priority := range result {
+		priorities = append(priorities, HostPriority{host: priority.Host, score: priority.Score})
+	}
+	return priorities, nil
+}
+
+// send POSTs args to verb and decodes the answer into result.
+func (h *HTTPExtender) send(verb string, args ExtenderArgs, result interface{}) error {
+	body, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimRight(h.config.URLPrefix, "/") + "/" + verb
+	resp, err := h.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%s: %v", h.Name(), err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %v", h.Name(), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s returned %d: %s", h.Name(), verb, resp.StatusCode, data)
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("%s: bad %s answer: %v", h.Name(), verb, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+func TestHTTPExtender(t *testing.T) {
+	var received []ExtenderArgs
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var args ExtenderArgs
+		if err := json.NewDecoder(req.Body).Decode(&args); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		received = append(received, args)
+		switch req.URL.Path {
+		case "/scheduler/filter":
+			json.NewEncoder(w).Encode(ExtenderFilterResult{Minions: args.Minions[1:]})
+		case "/scheduler/prioritize":
+			json.NewEncoder(w).Encode([]ExtenderHostPriority{{Host: args.Minions[0], Score: 7}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	extender, err := NewHTTPExtender(ExtenderConfig{
+		URLPrefix:      server.URL + "/scheduler/",
+		FilterVerb:     "filter",
+		PrioritizeVerb: "prioritize",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod := api.Pod{JSONBase: api.JSONBase{ID: "foo"}}
+
+	filtered, err := extender.Filter(pod, []string{"m1", "m2", "m3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := []string{"m2", "m3"}, filtered; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	priorities, err := extender.Prioritize(pod, FakePodLister{}, FakeMinionLister{"m2", "m3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e, a := (HostPriorityList{{host: "m2", score: 7}}), priorities; !reflect.DeepEqual(e, a) {
+		t.Errorf("expected %v, got %v", e, a)
+	}
+
+	if len(received) != 2 || received[0].Pod.ID != "foo" || !reflect.DeepEqual(received[1].Minions, []string{"m2", "m3"}) {
+		t.Errorf("unexpected requests: %#v", received)
+	}
+}
+
+func TestHTTPExtenderErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/refuse":
+			json.NewEncoder(w).Encode(ExtenderFilterResult{Error: "no license"})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	for _, verb := range []string{"refuse", "fail"} {
+		extender, err := NewHTTPExtender(ExtenderConfig{URLPrefix: server.URL, FilterVerb: verb})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := extender.Filter(api.Pod{}, []string{"m1"}); err == nil {
+			t.Errorf("%s: expected an error", verb)
+		}
+	}
+
+	for _, config := range []ExtenderConfig{{FilterVerb: "filter"}, {URLPrefix: server.URL}} {
+		if _, err := NewHTTPExtender(config); err == nil {
+			t.Errorf("expected an error for %#v", config)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// PriorityConfig is a PriorityFunction, and how much its scores count compared
+// to those of others.
+type PriorityConfig struct {
+	Function PriorityFunction
+	Weight   int
+}
+
+// genericScheduler places a pod on one of the machines which every predicate
+// and extender allows, taking turns among those the prioritizers score best.
+type genericScheduler struct {
+	predicates   map[string]FitPredicate
+	prioritizers []PriorityConfig
+	extenders    []SchedulerExtender
+	pods         PodLister
+	// equivalence, if set, keeps what the predicates found for recent pods.
+	equivalence *equivalenceCache
+	// pickHost breaks ties between the best hosts, sorted by name.
+	// Defaults to roundRobin.
+	pickHost func(best []string) string
+
+	lock sync.Mutex
+	// lastHostIndex counts the ties roundRobin broke.
+	lastHostIndex int
+}
+
+// NewGenericScheduler returns a Scheduler which filters machines with predicates,
+// keyed by their names, then with extenders, and ranks the remaining ones by the
+// weighted sum of the scores prioritizers give them. Without prioritizers every
+// machine ranks the same. Machines ranking the same take turns, in the order of
+// their names, so that the same pods on the same machines land the same way.
+// What the predicates find for a pod is reused for a few seconds for pods of
+// the same shape on machines whose pods haven't changed.
+// How long filtering and ranking take is recorded in metrics.Default as
+// "scheduler.predicates.latency" and "scheduler.priorities.latency".
+func NewGenericScheduler(predicates map[string]FitPredicate, prioritizers []PriorityConfig, extenders []SchedulerExtender, pods PodLister) Scheduler {
+	return &genericScheduler{
+		predicates:   predicates,
+		prioritizers: prioritizers,
+		extenders:    extenders,
+		pods:         pods,
+		equivalence:  newEquivalenceCache(equivalenceCacheTTL),
+	}
+}
+
+func (g *genericScheduler) Schedule(pod api.Pod, minionLister MinionLister) (string, error) {
+	minions, err := minionLister.List()
+	if err != nil {
+		return "", err
+	}
+	if len(minions) == 0 {
+		return "", fmt.Errorf("no minions available to schedule pods")
+	}
+	start := time.Now()
+	filtered, failedPredicates, err := findNodesThatFit(pod, g.pods, g.predicates, minions, g.equivalence)
+	if err != nil {
+		return "", err
+	}
+	filtered, err = filterWithExtenders(pod, g.extenders, filtered, failedPredicates)
+	if err != nil {
+		return "", err
+	}
+	metrics.Default.Histogram("scheduler.predicates.latency").Since(start)
+	if len(filtered) == 0 {
+		return "", &FitError{Pod: pod, FailedPredicates: failedPredicates}
+	}
+	start = time.Now()
+	priorities, err := prioritizeNodes(pod, g.pods, g.prioritizers, FakeMinionLister(filtered))
+	if err != nil {
+		return "", err
+	}
+	metrics.Default.Histogram("scheduler.priorities.latency").Since(start)
+	return g.selectHost(priorities)
+}
+
+// prioritizeNodes scores each of the minions minionLister lists with the
+// weighted sum of the scores of prioritizers.
+func prioritizeNodes(pod api.Pod, podLister PodLister, prioritizers []PriorityConfig, minionLister MinionLister) (HostPriorityList, error) {
+	if len(prioritizers) == 0 {
+		return EqualPriority(pod, podLister, minionLister)
+	}
+	minions, err := minionLister.List()
+	if err != nil {
+		return nil, err
+	}
+	combined := map[string]int{}
+	for _, config := range prioritizers {
+		priorities, err := config.Function(pod, podLister, minionLister)
+		if err != nil {
+			return nil, err
+		}
+		for _, priority := range priorities {
+			combined[priority.host] += priority.score * config.Weight
+		}
+	}
+	result := HostPriorityList{}
+	for _, minion := range minions {
+		result = append(result, HostPriority{host: minion, score: combined[minion]})
+	}
+	return result, nil
+}
+
+// selectHost picks one of the hosts with the highest score.
+func (g *genericScheduler) selectHost(priorities HostPriorityList) (string, error) {
+	if len(priorities) == 0 {
+		return "", fmt.Errorf("empty priority list")
+	}
+	best := []string{}
+	maxScore := priorities[0].score
+	for _, priority := range priorities {
+		switch {
+		case priority.score > maxScore:
+			maxScore = priority.score
+			best = []string{priority.host}
+		case priority.score == maxScore:
+			best = append(best, priority.host)
+		}
+	}
+	sort.Strings(best)
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.pickHost != nil {
+		return g.pickHost(best), nil
+	}
+	return g.roundRobin(best), nil
+}
+
+// roundRobin picks the host after the one it picked the last time, so that
+// equally good hosts get pods in turn. g.lock must be held.
+func (g *genericScheduler) roundRobin(best []string) string {
+	host := best[g.lastHostIndex%len(best)]
+	g.lastHostIndex++
+	return host
+}
+
+// FitError is returned when no minion fits a pod.
+type FitError struct {
+	Pod api.Pod
+	// FailedPredicates are the names of the predicates which ruled out each minion.
+	FailedPredicates map[string]util.StringSet
+}
+
+func (f *FitError) Error() string {
+	minions := []string{}
+	for minion := range f.FailedPredicates {
+		minions = append(minions, minion)
+	}
+	sort.Strings(minions)
+	reasons := []string{}
+	for _, minion := range minions {
+		reasons = append(reasons, fmt.Sprintf("%s (%s)", minion, strings.Join(f.FailedPredicates[minion].List(), ", ")))
+	}
+	return fmt.Sprintf("pod %s fits no minion: %s", f.Pod.ID, strings.Join(reasons, "; "))
+}
+
+// findNodesThatFit returns the minions on which every predicate allows pod,
+// given the pods already assigned to them, and which predicates ruled out each
+// of the others. If equivalence is not nil, the outcome for pods of the same
+// shape on minions which still run the same pods is reused.
+func findNodesThatFit(pod api.Pod, podLister PodLister, predicates map[string]FitPredicate, minions []string, equivalence *equivalenceCache) ([]string, map[string]util.StringSet, error) {
+	podsOnMachine, err := newMachinePodsFunc(podLister)
+	if err != nil {
+		return nil, nil, err
+	}
+	podClass, cacheable := uint64(0), false
+	if equivalence != nil {
+		equivalence.gc()
+		podClass, cacheable = getPodClass(pod)
+	}
+	filtered := []string{}
+	failedPredicates := map[string]util.StringSet{}
+	for _, minion := range minions {
+		existingPods, err := podsOnMachine(minion)
+		if err != nil {
+			return nil, nil, err
+		}
+		podsKey := ""
+		failed, cached := util.StringSet(nil), false
+		if cacheable {
+			podsKey = getPodsKey(existingPods)
+			failed, cached = equivalence.lookup(podClass, minion, podsKey)
+		}
+		if !cached {
+			failed = util.StringSet{}
+			for name, predicate := range predicates {
+				fit, err := predicate(pod, existingPods, minion)
+				if err != nil {
+					return nil, nil, err
+				}
+				if !fit {
+					failed.Insert(name)
+				}
+			}
+			if cacheable {
+				equivalence.store(podClass, minion, podsKey, failed)
+			}
+		}
+		if len(failed) == 0 {
+			filtered = append(filtered, minion)
+		} else {
+			failedPredicates[minion] = failed
+		}
+	}
+	return filtered, failedPredicates, nil
+}
+
+// filterWithExtenders returns those of minions which every extender allows, and
+// records which extender ruled out each of the others in failedPredicates.
+func filterWithExtenders(pod api.Pod, extenders []SchedulerExtender, minions []string, failedPredicates map[string]util.StringSet) ([]string, error) {
+	for _, extender := range extenders {
+		if len(minions) == 0 {
+			break
+		}
+		fits, err := extender.Filter(pod, minions)
+		if err != nil {
+			return nil, err
+		}
+		fitting := util.NewStringSet(fits...)
+		filtered := []string{}
+		for _, minion := range minions {
+			if fitting.Has(minion) {
+				filtered = append(filtered, minion)
+			} else {
+				failedPredicates[minion] = util.NewStringSet(extender.Name())
+			}
+		}
+		minions = filtered
+	}
+	return minions, nil
+}
+
+// newMachinePodsFunc returns a function listing the pods on a machine. Pod
+// listers which keep the pods of each host at hand, like the watch-fed cache of
+// the scheduler, are asked for the pods of just that machine. The pods of other
+// listers are all listed once and grouped.
+func newMachinePodsFunc(podLister PodLister) (func(machine string) ([]api.Pod, error), error) {
+	if hostPodLister, ok := podLister.(HostPodLister); ok {
+		return hostPodLister.ListPodsOnHost, nil
+	}
+	machineToPods, err := mapPodsToMachines(podLister)
+	if err != nil {
+		return nil, err
+	}
+	return func(machine string) ([]api.Pod, error) {
+		return machineToPods[machine], nil
+	}, nil
+}
+
+// mapPodsToMachines groups the pods podLister finds by the machine they are on.
+func mapPodsToMachines(podLister PodLister) (map[string][]api.Pod, error) {
+	machineToPods := map[string][]api.Pod{}
+	pods, err := podLister.ListPods(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, scheduledPod := range pods {
+		host := podHost(scheduledPod)
+		machineToPods[host] = append(machineToPods[host], scheduledPod)
+	}
+	return machineToPods, nil
+}
+
+// podHost returns the machine pod is on. Pods take their ports and resources
+// from the moment they are assigned, not only once their minion reports them
+// running.
+func podHost(pod api.Pod) string {
+	if pod.DesiredState.Host != "" {
+		return pod.DesiredState.Host
+	}
+	return pod.CurrentState.Host
+}
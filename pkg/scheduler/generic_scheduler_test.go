@@ -0,0 +1,279 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+func falsePredicate(pod api.Pod, existingPods []api.Pod, node string) (bool, error) {
+	return false, nil
+}
+
+// numericPriority scores minions named after numbers by their number.
+func numericPriority(pod api.Pod, podLister PodLister, minionLister MinionLister) (HostPriorityList, error) {
+	minions, err := minionLister.List()
+	if err != nil {
+		return nil, err
+	}
+	result := HostPriorityList{}
+	for _, minion := range minions {
+		score := 0
+		for _, c := range minion {
+			score = score*10 + int(c-'0')
+		}
+		result = append(result, HostPriority{host: minion, score: score})
+	}
+	return result, nil
+}
+
+// reverseNumericPriority scores minions named after numbers by how much smaller
+// than the largest number their number is.
+func reverseNumericPriority(pod api.Pod, podLister PodLister, minionLister MinionLister) (HostPriorityList, error) {
+	result, err := numericPriority(pod, podLister, minionLister)
+	if err != nil {
+		return nil, err
+	}
+	maxScore := 0
+	for _, priority := range result {
+		if priority.score > maxScore {
+			maxScore = priority.score
+		}
+	}
+	for i := range result {
+		result[i].score = maxScore - result[i].score
+	}
+	return result, nil
+}
+
+func TestSelectHost(t *testing.T) {
+	tests := []struct {
+		list       HostPriorityList
+		expected   []string
+		expectsErr bool
+	}{
+		{
+			list:     HostPriorityList{{"machine1.1", 1}, {"machine2.1", 2}},
+			expected: []string{"machine2.1", "machine2.1", "machine2.1"},
+		},
+		{
+			// Ties are broken in turn, in the order of the host names.
+			list:     HostPriorityList{{"machine1.2", 2}, {"machine1.1", 2}, {"machine2.1", 1}},
+			expected: []string{"machine1.1", "machine1.2", "machine1.1"},
+		},
+		{
+			list:       HostPriorityList{},
+			expectsErr: true,
+		},
+	}
+	for _, test := range tests {
+		scheduler := genericScheduler{}
+		if test.expectsErr {
+			if _, err := scheduler.selectHost(test.list); err == nil {
+				t.Error("Unexpected non-error")
+			}
+			continue
+		}
+		for _, expected := range test.expected {
+			got, err := scheduler.selectHost(test.list)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if got != expected {
+				t.Errorf("expected %s, got %s", expected, got)
+			}
+		}
+	}
+}
+
+func TestGenericScheduler(t *testing.T) {
+	tests := []struct {
+		name         string
+		predicates   map[string]FitPredicate
+		prioritizers []PriorityConfig
+		minions      []string
+		pods         []api.Pod
+		pod          api.Pod
+		expectsErr   bool
+		expected     string
+	}{
+		{
+			name:       "no minion fits",
+			predicates: map[string]FitPredicate{"false": falsePredicate},
+			minions:    []string{"1", "2"},
+			expectsErr: true,
+		},
+		{
+			name:       "no minions",
+			expectsErr: true,
+		},
+		{
+			name:         "highest score wins",
+			prioritizers: []PriorityConfig{{numericPriority, 1}},
+			minions:      []string{"3", "2", "1"},
+			expected:     "3",
+		},
+		{
+			name:         "highest score among the minions which fit wins",
+			predicates:   map[string]FitPredicate{"PodFitsPorts": PodFitsPorts},
+			prioritizers: []PriorityConfig{{numericPriority, 1}},
+			minions:      []string{"3", "2", "1"},
+			pods:         []api.Pod{newPod("3", 8080)},
+			pod:          newPod("", 8080),
+			expected:     "2",
+		},
+		{
+			name:         "weights combine the scores of prioritizers",
+			prioritizers: []PriorityConfig{{numericPriority, 1}, {reverseNumericPriority, 2}},
+			minions:      []string{"3", "2", "1"},
+			expected:     "1",
+		},
+		{
+			name:         "spreads pods with the same labels",
+			prioritizers: []PriorityConfig{{CalculateSpreadPriority, 1}},
+			minions:      []string{"1", "2"},
+			pods:         []api.Pod{{Labels: map[string]string{"name": "foo"}, DesiredState: api.PodState{Host: "1"}}},
+			pod:          api.Pod{Labels: map[string]string{"name": "foo"}},
+			expected:     "2",
+		},
+	}
+	for _, test := range tests {
+		scheduler := NewGenericScheduler(test.predicates, test.prioritizers, nil, FakePodLister(test.pods))
+		machine, err := scheduler.Schedule(test.pod, FakeMinionLister(test.minions))
+		if test.expectsErr {
+			if err == nil {
+				t.Errorf("%s: unexpected non-error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if machine != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, machine)
+		}
+	}
+}
+
+// fakeHostPodLister only lists the pods of one host at a time.
+type fakeHostPodLister map[string][]api.Pod
+
+func (f fakeHostPodLister) ListPods(selector labels.Selector) ([]api.Pod, error) {
+	if selector.Empty() {
+		return nil, fmt.Errorf("unexpected listing of every pod")
+	}
+	pods := []api.Pod{}
+	for _, hostPods := range f {
+		pods = append(pods, FakePodLister(hostPods)...)
+	}
+	return FakePodLister(pods).ListPods(selector)
+}
+
+func (f fakeHostPodLister) ListPodsOnHost(host string) ([]api.Pod, error) {
+	return f[host], nil
+}
+
+func TestGenericSchedulerListsPodsOnHosts(t *testing.T) {
+	pods := fakeHostPodLister{
+		"1": {newPod("1", 8080)},
+		"2": {newPod("2", 8080)},
+	}
+	scheduler := NewGenericScheduler(map[string]FitPredicate{"PodFitsPorts": PodFitsPorts}, nil, nil, pods)
+	machine, err := scheduler.Schedule(newPod("", 8080), FakeMinionLister{"1", "2", "3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if machine != "3" {
+		t.Errorf("expected 3, got %s", machine)
+	}
+}
+
+func TestFitError(t *testing.T) {
+	pods := FakePodLister{newPod("1", 8080)}
+	predicates := map[string]FitPredicate{"PodFitsPorts": PodFitsPorts, "false": falsePredicate}
+	scheduler := NewGenericScheduler(predicates, nil, nil, pods)
+	pod := newPod("", 8080)
+	pod.ID = "foo"
+	_, err := scheduler.Schedule(pod, FakeMinionLister{"2", "1"})
+	fitErr, ok := err.(*FitError)
+	if !ok {
+		t.Fatalf("expected a FitError, got %v", err)
+	}
+	expected := map[string]util.StringSet{
+		"1": util.NewStringSet("PodFitsPorts", "false"),
+		"2": util.NewStringSet("false"),
+	}
+	if !reflect.DeepEqual(expected, fitErr.FailedPredicates) {
+		t.Errorf("expected %v, got %v", expected, fitErr.FailedPredicates)
+	}
+	if e, a := "pod foo fits no minion: 1 (PodFitsPorts, false); 2 (false)", err.Error(); e != a {
+		t.Errorf("expected %q, got %q", e, a)
+	}
+}
+
+// fakeExtender allows only the minions it lists.
+type fakeExtender []string
+
+func (f fakeExtender) Name() string {
+	return "fakeExtender"
+}
+
+func (f fakeExtender) Filter(pod api.Pod, minions []string) ([]string, error) {
+	allowed := util.NewStringSet(f...)
+	filtered := []string{}
+	for _, minion := range minions {
+		if allowed.Has(minion) {
+			filtered = append(filtered, minion)
+		}
+	}
+	return filtered, nil
+}
+
+func TestGenericSchedulerExtenders(t *testing.T) {
+	predicates := map[string]FitPredicate{"PodFitsPorts": PodFitsPorts}
+	prioritizers := []PriorityConfig{{Function: numericPriority, Weight: 1}}
+	extenders := []SchedulerExtender{fakeExtender{"1", "2", "3"}, fakeExtender{"1", "2"}}
+	scheduler := NewGenericScheduler(predicates, prioritizers, extenders, FakePodLister{})
+	machine, err := scheduler.Schedule(newPod(""), FakeMinionLister{"1", "2", "3", "4"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if machine != "2" {
+		t.Errorf("expected 2, got %s", machine)
+	}
+
+	extenders = []SchedulerExtender{fakeExtender{}}
+	scheduler = NewGenericScheduler(predicates, prioritizers, extenders, FakePodLister{newPod("1", 8080)})
+	_, err = scheduler.Schedule(newPod("", 8080), FakeMinionLister{"1", "2"})
+	fitErr, ok := err.(*FitError)
+	if !ok {
+		t.Fatalf("expected a FitError, got %v", err)
+	}
+	expected := map[string]util.StringSet{
+		"1": util.NewStringSet("PodFitsPorts"),
+		"2": util.NewStringSet("fakeExtender"),
+	}
+	if !reflect.DeepEqual(expected, fitErr.FailedPredicates) {
+		t.Errorf("expected %v, got %v", expected, fitErr.FailedPredicates)
+	}
+}
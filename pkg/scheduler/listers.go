@@ -17,6 +17,8 @@ limitations under the License.
 package scheduler
 
 import (
+	"fmt"
+
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 )
@@ -40,6 +42,12 @@ type PodLister interface {
 	ListPods(labels.Selector) ([]api.Pod, error)
 }
 
+// HostPodLister is implemented by PodListers which can list the pods assigned to
+// one host without listing every pod.
+type HostPodLister interface {
+	ListPodsOnHost(host string) ([]api.Pod, error)
+}
+
 // FakePodLister implements PodLister on an []api.Pods for test purposes.
 type FakePodLister []api.Pod
 
@@ -52,3 +60,35 @@ func (f FakePodLister) ListPods(s labels.Selector) (selected []api.Pod, err erro
 	}
 	return selected, nil
 }
+
+// ServiceLister interface represents anything that can list services for a scheduler.
+type ServiceLister interface {
+	ListServices() (api.ServiceList, error)
+}
+
+// FakeServiceLister implements ServiceLister on an []api.Service for test purposes.
+type FakeServiceLister []api.Service
+
+// ListServices returns the services as an api.ServiceList.
+func (f FakeServiceLister) ListServices() (api.ServiceList, error) {
+	return api.ServiceList{Items: f}, nil
+}
+
+// MinionInfo interface represents anything that can get the details, such as
+// the capacity, of a minion for a scheduler.
+type MinionInfo interface {
+	GetMinionInfo(minionID string) (*api.Minion, error)
+}
+
+// FakeMinionInfo implements MinionInfo on an []api.Minion for test purposes.
+type FakeMinionInfo []api.Minion
+
+// GetMinionInfo returns the minion with the given ID.
+func (f FakeMinionInfo) GetMinionInfo(minionID string) (*api.Minion, error) {
+	for i := range f {
+		if f[i].ID == minionID {
+			return &f[i], nil
+		}
+	}
+	return nil, fmt.Errorf("minion %s not found", minionID)
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// PluginFactoryArgs are what the factories of predicates and priorities can build on.
+type PluginFactoryArgs struct {
+	PodLister     PodLister
+	ServiceLister ServiceLister
+	MinionInfo    MinionInfo
+}
+
+// FitPredicateFactory builds a FitPredicate from args.
+type FitPredicateFactory func(args PluginFactoryArgs) FitPredicate
+
+// PriorityFunctionFactory builds a PriorityFunction from args.
+type PriorityFunctionFactory func(args PluginFactoryArgs) PriorityFunction
+
+// priorityConfigFactory is a registered priority.
+type priorityConfigFactory struct {
+	function PriorityFunctionFactory
+	weight   int
+}
+
+// AlgorithmProviderConfig names the predicates and priorities a scheduling
+// algorithm is made of.
+type AlgorithmProviderConfig struct {
+	FitPredicateKeys     util.StringSet
+	PriorityFunctionKeys util.StringSet
+}
+
+var (
+	// pluginLock guards the maps below.
+	pluginLock sync.Mutex
+
+	fitPredicates      = map[string]FitPredicateFactory{}
+	priorityFunctions  = map[string]priorityConfigFactory{}
+	algorithmProviders = map[string]AlgorithmProviderConfig{}
+)
+
+// RegisterFitPredicate registers predicate under name, replacing any predicate
+// registered under it before. It returns name, so that it can be kept in a var.
+func RegisterFitPredicate(name string, predicate FitPredicate) string {
+	return RegisterFitPredicateFactory(name, func(PluginFactoryArgs) FitPredicate { return predicate })
+}
+
+// RegisterFitPredicateFactory registers a predicate built by factory under name,
+// for predicates which need to look up minions or pods.
+func RegisterFitPredicateFactory(name string, factory FitPredicateFactory) string {
+	pluginLock.Lock()
+	defer pluginLock.Unlock()
+	fitPredicates[name] = factory
+	return name
+}
+
+// RegisterPriorityFunction registers function under name, with the weight its
+// scores get when combined with those of other priorities.
+func RegisterPriorityFunction(name string, function PriorityFunction, weight int) string {
+	return RegisterPriorityFunctionFactory(name, func(PluginFactoryArgs) PriorityFunction { return function }, weight)
+}
+
+// RegisterPriorityFunctionFactory registers a priority built by factory under
+// name, for priorities which need to look up minions.
+func RegisterPriorityFunctionFactory(name string, factory PriorityFunctionFactory, weight int) string {
+	pluginLock.Lock()
+	defer pluginLock.Unlock()
+	priorityFunctions[name] = priorityConfigFactory{function: factory, weight: weight}
+	return name
+}
+
+// RegisterAlgorithmProvider registers the scheduling algorithm made of the
+// predicates and priorities with the given names under name.
+func RegisterAlgorithmProvider(name string, predicateKeys, priorityKeys util.StringSet) string {
+	pluginLock.Lock()
+	defer pluginLock.Unlock()
+	algorithmProviders[name] = AlgorithmProviderConfig{
+		FitPredicateKeys:     predicateKeys,
+		PriorityFunctionKeys: priorityKeys,
+	}
+	return name
+}
+
+// GetAlgorithmProvider returns the algorithm registered under name.
+func GetAlgorithmProvider(name string) (*AlgorithmProviderConfig, error) {
+	pluginLock.Lock()
+	defer pluginLock.Unlock()
+	provider, ok := algorithmProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("algorithm provider %q is not registered", name)
+	}
+	return &provider, nil
+}
+
+// ListAlgorithmProviders returns the names of the registered algorithms, sorted.
+func ListAlgorithmProviders() []string {
+	pluginLock.Lock()
+	defer pluginLock.Unlock()
+	names := []string{}
+	for name := range algorithmProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewAlgorithm returns a generic Scheduler made of the registered predicates
+// and priorities with the given names.
+func NewAlgorithm(predicateKeys, priorityKeys util.StringSet, args PluginFactoryArgs) (Scheduler, error) {
+	predicates, err := getFitPredicates(predicateKeys, args)
+	if err != nil {
+		return nil, err
+	}
+	priorities := []PriorityConfig{}
+	for _, name := range priorityKeys.List() {
+		priority, err := getPriorityConfig(name, 0, args)
+		if err != nil {
+			return nil, err
+		}
+		priorities = append(priorities, priority)
+	}
+	return NewGenericScheduler(predicates, priorities, nil, args.PodLister), nil
+}
+
+// getFitPredicates builds the registered predicates with the given names.
+func getFitPredicates(names util.StringSet, args PluginFactoryArgs) (map[string]FitPredicate, error) {
+	pluginLock.Lock()
+	defer pluginLock.Unlock()
+	predicates := map[string]FitPredicate{}
+	for name := range names {
+		factory, ok := fitPredicates[name]
+		if !ok {
+			return nil, fmt.Errorf("fit predicate %q is not registered", name)
+		}
+		predicates[name] = factory(args)
+	}
+	return predicates, nil
+}
+
+// getPriorityConfig builds the registered priority called name, weighted with
+// weight or, if that is zero, the weight it was registered with.
+func getPriorityConfig(name string, weight int, args PluginFactoryArgs) (PriorityConfig, error) {
+	pluginLock.Lock()
+	defer pluginLock.Unlock()
+	factory, ok := priorityFunctions[name]
+	if !ok {
+		return PriorityConfig{}, fmt.Errorf("priority function %q is not registered", name)
+	}
+	if weight == 0 {
+		weight = factory.weight
+	}
+	return PriorityConfig{Function: factory.function(args), Weight: weight}, nil
+}
+
+// NewAlgorithmFromProvider returns the Scheduler the algorithm registered under
+// name describes.
+func NewAlgorithmFromProvider(name string, args PluginFactoryArgs) (Scheduler, error) {
+	provider, err := GetAlgorithmProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewAlgorithm(provider.FitPredicateKeys, provider.PriorityFunctionKeys, args)
+}
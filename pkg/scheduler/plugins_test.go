@@ -0,0 +1,83 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+func TestDefaultProvider(t *testing.T) {
+	provider, err := GetAlgorithmProvider(DefaultProvider)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !provider.FitPredicateKeys.HasAll("MinionReady", "PodFitsPorts", "PodFitsResources", "MatchNodeSelector") {
+		t.Errorf("unexpected predicates: %v", provider.FitPredicateKeys)
+	}
+	if !provider.PriorityFunctionKeys.HasAll("SpreadingPriority", "LeastRequestedPriority") {
+		t.Errorf("unexpected priorities: %v", provider.PriorityFunctionKeys)
+	}
+	args := PluginFactoryArgs{PodLister: FakePodLister{}, ServiceLister: FakeServiceLister{}, MinionInfo: FakeMinionInfo{}}
+	for _, name := range []string{DefaultProvider, ZoneSpreadingProvider} {
+		if _, err := NewAlgorithmFromProvider(name, args); err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+	}
+	if _, err := NewAlgorithmFromProvider("unknown", args); err == nil {
+		t.Errorf("expected an error for an unknown provider")
+	}
+}
+
+func TestNewAlgorithm(t *testing.T) {
+	RegisterFitPredicate("TestFalse", falsePredicate)
+	RegisterPriorityFunction("TestNumeric", numericPriority, 1)
+	RegisterAlgorithmProvider("TestProvider", util.NewStringSet(), util.NewStringSet("TestNumeric"))
+	if names := ListAlgorithmProviders(); !util.NewStringSet(names...).HasAll(DefaultProvider, "TestProvider") {
+		t.Errorf("unexpected providers: %v", names)
+	}
+	args := PluginFactoryArgs{PodLister: FakePodLister{}}
+
+	algorithm, err := NewAlgorithmFromProvider("TestProvider", args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	machine, err := algorithm.Schedule(api.Pod{}, FakeMinionLister{"1", "3", "2"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if machine != "3" {
+		t.Errorf("expected 3, got %s", machine)
+	}
+
+	algorithm, err = NewAlgorithm(util.NewStringSet("TestFalse"), util.NewStringSet(), args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := algorithm.Schedule(api.Pod{}, FakeMinionLister{"1"}); err == nil {
+		t.Errorf("expected no minion to fit")
+	}
+
+	if _, err := NewAlgorithm(util.NewStringSet("Unknown"), util.NewStringSet(), args); err == nil {
+		t.Errorf("expected an error for an unknown predicate")
+	}
+	if _, err := NewAlgorithm(util.NewStringSet(), util.NewStringSet("Unknown"), args); err == nil {
+		t.Errorf("expected an error for an unknown priority")
+	}
+}
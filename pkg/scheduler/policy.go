@@ -0,0 +1,123 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+// Policy describes a scheduling algorithm in a file, e.g.
+//
+//	{
+//		"predicates": [{"name": "PodFitsPorts"}, {"name": "PodFitsResources"}],
+//		"priorities": [{"name": "LeastRequestedPriority", "weight": 2}],
+//		"extenders": [{"urlPrefix": "http://127.0.0.1:12346/scheduler", "filterVerb": "filter"}]
+//	}
+type Policy struct {
+	// Predicates name the registered predicates to filter minions with. If
+	// unset, those of the default provider are used.
+	Predicates []PredicatePolicy `json:"predicates,omitempty"`
+	// Priorities name the registered priorities to rank minions with. If
+	// unset, those of the default provider are used.
+	Priorities []PriorityPolicy `json:"priorities,omitempty"`
+	// Extenders are consulted after the predicates, in order.
+	Extenders []ExtenderConfig `json:"extenders,omitempty"`
+}
+
+// PredicatePolicy names a registered predicate.
+type PredicatePolicy struct {
+	Name string `json:"name"`
+}
+
+// PriorityPolicy names a registered priority, and how much its scores count.
+type PriorityPolicy struct {
+	Name string `json:"name"`
+	// Weight overrides the weight the priority was registered with, unless zero.
+	Weight int `json:"weight,omitempty"`
+}
+
+// ParsePolicy decodes a JSON policy.
+func ParsePolicy(data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid scheduler policy: %v", err)
+	}
+	return policy, nil
+}
+
+// NewAlgorithmFromPolicy returns the generic Scheduler policy describes.
+func NewAlgorithmFromPolicy(policy Policy, args PluginFactoryArgs) (Scheduler, error) {
+	provider, err := GetAlgorithmProvider(DefaultProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	predicateKeys := provider.FitPredicateKeys
+	if policy.Predicates != nil {
+		predicateKeys = util.StringSet{}
+		for _, predicate := range policy.Predicates {
+			predicateKeys.Insert(predicate.Name)
+		}
+	}
+	predicates, err := getFitPredicates(predicateKeys, args)
+	if err != nil {
+		return nil, err
+	}
+
+	priorities := []PriorityConfig{}
+	if policy.Priorities == nil {
+		for _, name := range provider.PriorityFunctionKeys.List() {
+			policy.Priorities = append(policy.Priorities, PriorityPolicy{Name: name})
+		}
+	}
+	for _, priority := range policy.Priorities {
+		if priority.Weight < 0 {
+			return nil, fmt.Errorf("priority %q has negative weight %d", priority.Name, priority.Weight)
+		}
+		config, err := getPriorityConfig(priority.Name, priority.Weight, args)
+		if err != nil {
+			return nil, err
+		}
+		priorities = append(priorities, config)
+	}
+
+	extenders := []SchedulerExtender{}
+	for _, config := range policy.Extenders {
+		if config.Weight < 0 {
+			return nil, fmt.Errorf("extender %s has negative weight %d", config.URLPrefix, config.Weight)
+		}
+		extender, err := NewHTTPExtender(config)
+		if err != nil {
+			return nil, err
+		}
+		if config.FilterVerb != "" {
+			extenders = append(extenders, extender)
+		}
+		if config.PrioritizeVerb != "" {
+			weight := config.Weight
+			if weight == 0 {
+				weight = 1
+			}
+			priorities = append(priorities, PriorityConfig{Function: extender.Prioritize, Weight: weight})
+		}
+	}
+
+	return NewGenericScheduler(predicates, priorities, extenders, args.PodLister), nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`{
+		"predicates": [{"name": "PodFitsPorts"}],
+		"priorities": [{"name": "LeastRequestedPriority", "weight": 2}],
+		"extenders": [{"urlPrefix": "http://127.0.0.1:12346/scheduler", "filterVerb": "filter", "weight": 5}]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(policy.Predicates) != 1 || policy.Predicates[0].Name != "PodFitsPorts" {
+		t.Errorf("unexpected predicates: %#v", policy.Predicates)
+	}
+	if len(policy.Priorities) != 1 || policy.Priorities[0] != (PriorityPolicy{Name: "LeastRequestedPriority", Weight: 2}) {
+		t.Errorf("unexpected priorities: %#v", policy.Priorities)
+	}
+	if len(policy.Extenders) != 1 || policy.Extenders[0].FilterVerb != "filter" || policy.Extenders[0].Weight != 5 {
+		t.Errorf("unexpected extenders: %#v", policy.Extenders)
+	}
+
+	if _, err := ParsePolicy([]byte(`{"predicates": "PodFitsPorts"}`)); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestNewAlgorithmFromPolicy(t *testing.T) {
+	RegisterPriorityFunction("TestPolicyNumeric", numericPriority, 1)
+	RegisterPriorityFunction("TestPolicyReverseNumeric", reverseNumericPriority, 1)
+	args := PluginFactoryArgs{PodLister: FakePodLister{}, ServiceLister: FakeServiceLister{}, MinionInfo: FakeMinionInfo{}}
+
+	if _, err := NewAlgorithmFromPolicy(Policy{}, args); err != nil {
+		t.Errorf("unexpected error for the default policy: %v", err)
+	}
+	if _, err := NewAlgorithmFromPolicy(Policy{Predicates: []PredicatePolicy{{Name: "unknown"}}}, args); err == nil {
+		t.Errorf("expected an error for an unknown predicate")
+	}
+	if _, err := NewAlgorithmFromPolicy(Policy{Priorities: []PriorityPolicy{{Name: "unknown"}}}, args); err == nil {
+		t.Errorf("expected an error for an unknown priority")
+	}
+	if _, err := NewAlgorithmFromPolicy(Policy{Priorities: []PriorityPolicy{{Name: "TestPolicyNumeric", Weight: -1}}}, args); err == nil {
+		t.Errorf("expected an error for a negative weight")
+	}
+
+	// The weight of the policy overrides the registered one.
+	algorithm, err := NewAlgorithmFromPolicy(Policy{
+		Predicates: []PredicatePolicy{},
+		Priorities: []PriorityPolicy{{Name: "TestPolicyNumeric"}, {Name: "TestPolicyReverseNumeric", Weight: 2}},
+	}, args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	machine, err := algorithm.Schedule(newPod(""), FakeMinionLister{"1", "2", "3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if machine != "1" {
+		t.Errorf("expected 1, got %s", machine)
+	}
+
+	// Extenders filter and their scores count by their weight.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var args ExtenderArgs
+		json.NewDecoder(req.Body).Decode(&args)
+		switch req.URL.Path {
+		case "/filter":
+			json.NewEncoder(w).Encode(ExtenderFilterResult{Minions: args.Minions[1:]})
+		case "/prioritize":
+			priorities := []ExtenderHostPriority{}
+			for _, minion := range args.Minions {
+				if minion == "2" {
+					priorities = append(priorities, ExtenderHostPriority{Host: minion, Score: 10})
+				}
+			}
+			json.NewEncoder(w).Encode(priorities)
+		}
+	}))
+	defer server.Close()
+	algorithm, err = NewAlgorithmFromPolicy(Policy{
+		Predicates: []PredicatePolicy{},
+		Priorities: []PriorityPolicy{{Name: "TestPolicyNumeric"}},
+		Extenders:  []ExtenderConfig{{URLPrefix: server.URL, FilterVerb: "filter", PrioritizeVerb: "prioritize", Weight: 2}},
+	}, args)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	machine, err = algorithm.Schedule(newPod(""), FakeMinionLister{"1", "2", "3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if machine != "2" {
+		t.Errorf("expected 2, got %s", machine)
+	}
+}
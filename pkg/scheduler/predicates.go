@@ -0,0 +1,169 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+)
+
+// FitPredicate reports whether pod may be placed on node, which already runs existingPods.
+type FitPredicate func(pod api.Pod, existingPods []api.Pod, node string) (bool, error)
+
+// PodFitsPorts rules out nodes where one of the existing pods already uses a
+// host port which pod asks for, since docker would fail to bind it.
+func PodFitsPorts(pod api.Pod, existingPods []api.Pod, node string) (bool, error) {
+	usedPorts := getUsedPorts(existingPods...)
+	for port := range getUsedPorts(pod) {
+		if usedPorts[port] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// getUsedPorts returns the host ports the containers of pods take.
+func getUsedPorts(pods ...api.Pod) map[int]bool {
+	ports := map[int]bool{}
+	for _, pod := range pods {
+		for _, container := range pod.DesiredState.Manifest.Containers {
+			for _, port := range container.Ports {
+				if port.HostPort != 0 {
+					ports[port.HostPort] = true
+				}
+			}
+		}
+	}
+	return ports
+}
+
+// resourceRequest is the sum of what the containers of some pods ask for.
+type resourceRequest struct {
+	milliCPU int64
+	memory   int64
+}
+
+func (r *resourceRequest) add(pod api.Pod) {
+	for _, container := range pod.DesiredState.Manifest.Containers {
+		r.milliCPU += int64(container.CPU)
+		r.memory += int64(container.Memory)
+	}
+}
+
+// ResourceFit rules out minions which lack the CPU or memory for a pod, on top
+// of what the pods already placed there ask for.
+type ResourceFit struct {
+	info MinionInfo
+}
+
+// NewResourceFitPredicate returns a FitPredicate checking minions' capacity, as
+// info finds it. A minion which hasn't reported a capacity for a resource is
+// not limited in it, and pods which ask for no resources fit anywhere.
+func NewResourceFitPredicate(info MinionInfo) FitPredicate {
+	fit := &ResourceFit{info: info}
+	return fit.PodFitsResources
+}
+
+// PodFitsResources is a FitPredicate.
+func (r *ResourceFit) PodFitsResources(pod api.Pod, existingPods []api.Pod, node string) (bool, error) {
+	request := resourceRequest{}
+	request.add(pod)
+	if request.milliCPU == 0 && request.memory == 0 {
+		return true, nil
+	}
+	minion, err := r.info.GetMinionInfo(node)
+	if err != nil {
+		return false, fmt.Errorf("failed to get capacity of minion %s: %v", node, err)
+	}
+	used := resourceRequest{}
+	for _, existingPod := range existingPods {
+		used.add(existingPod)
+	}
+	capacity := minion.NodeResources.Capacity
+	if cpu := capacity[api.ResourceCPU]; cpu > 0 && used.milliCPU+request.milliCPU > cpu {
+		return false, nil
+	}
+	if memory := capacity[api.ResourceMemory]; memory > 0 && used.memory+request.memory > memory {
+		return false, nil
+	}
+	return true, nil
+}
+
+// NodeSelector rules out minions without all the labels a pod's NodeSelector asks for.
+type NodeSelector struct {
+	info MinionInfo
+}
+
+// NewSelectorMatchPredicate returns a FitPredicate checking the labels of minions,
+// as info finds them, against pods' NodeSelector.
+func NewSelectorMatchPredicate(info MinionInfo) FitPredicate {
+	selector := &NodeSelector{info: info}
+	return selector.PodSelectorMatches
+}
+
+// PodSelectorMatches is a FitPredicate.
+func (n *NodeSelector) PodSelectorMatches(pod api.Pod, existingPods []api.Pod, node string) (bool, error) {
+	if len(pod.NodeSelector) == 0 {
+		return true, nil
+	}
+	minion, err := n.info.GetMinionInfo(node)
+	if err != nil {
+		return false, fmt.Errorf("failed to get labels of minion %s: %v", node, err)
+	}
+	return labels.SelectorFromSet(pod.NodeSelector).Matches(labels.Set(minion.Labels)), nil
+}
+
+// DefaultMaxHeartbeatAge is how long after its last heartbeat the default
+// algorithms still give a minion pods: a few of the kubelet's default
+// heartbeat periods.
+const DefaultMaxHeartbeatAge = time.Minute
+
+// MinionReadiness rules out minions which are NotReady or whose last heartbeat
+// is too old, since pods bound to them would likely never start.
+type MinionReadiness struct {
+	info            MinionInfo
+	maxHeartbeatAge time.Duration
+	// Defaults to time.Now.
+	now func() time.Time
+}
+
+// NewMinionReadyPredicate returns a FitPredicate checking the status of
+// minions, as info finds it. Minions whose heartbeats aren't tracked only need
+// not to be NotReady; if maxHeartbeatAge is zero, the age isn't checked at all.
+func NewMinionReadyPredicate(info MinionInfo, maxHeartbeatAge time.Duration) FitPredicate {
+	readiness := &MinionReadiness{info: info, maxHeartbeatAge: maxHeartbeatAge, now: time.Now}
+	return readiness.MinionIsReady
+}
+
+// MinionIsReady is a FitPredicate.
+func (r *MinionReadiness) MinionIsReady(pod api.Pod, existingPods []api.Pod, node string) (bool, error) {
+	minion, err := r.info.GetMinionInfo(node)
+	if err != nil {
+		return false, fmt.Errorf("failed to get status of minion %s: %v", node, err)
+	}
+	if minion.Status.Condition == api.MinionNotReady {
+		return false, nil
+	}
+	lastHeartbeat := minion.Status.LastHeartbeatTime
+	if r.maxHeartbeatAge > 0 && !lastHeartbeat.IsZero() && r.now().Sub(lastHeartbeat.Time) > r.maxHeartbeatAge {
+		return false, nil
+	}
+	return true, nil
+}
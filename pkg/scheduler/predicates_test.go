@@ -0,0 +1,281 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+func newResourcePod(host string, usage ...resourceRequest) api.Pod {
+	containers := []api.Container{}
+	for _, req := range usage {
+		containers = append(containers, api.Container{
+			CPU:    int(req.milliCPU),
+			Memory: int(req.memory),
+		})
+	}
+	return api.Pod{
+		CurrentState: api.PodState{
+			Host: host,
+		},
+		DesiredState: api.PodState{
+			Manifest: api.ContainerManifest{
+				Containers: containers,
+			},
+		},
+	}
+}
+
+func newMinion(id string, milliCPU, memory int64) api.Minion {
+	return api.Minion{
+		JSONBase: api.JSONBase{ID: id},
+		NodeResources: api.NodeResources{
+			Capacity: api.ResourceList{
+				api.ResourceCPU:    milliCPU,
+				api.ResourceMemory: memory,
+			},
+		},
+	}
+}
+
+func TestPodFitsPorts(t *testing.T) {
+	tests := []struct {
+		pod          api.Pod
+		existingPods []api.Pod
+		fits         bool
+		test         string
+	}{
+		{
+			pod:          api.Pod{},
+			existingPods: []api.Pod{newPod("m1", 8080)},
+			fits:         true,
+			test:         "no ports requested always fits",
+		},
+		{
+			pod:          newPod("", 8080),
+			existingPods: []api.Pod{newPod("m1", 9090)},
+			fits:         true,
+			test:         "other ports fit",
+		},
+		{
+			pod:          newPod("", 8080, 9090),
+			existingPods: []api.Pod{newPod("m1", 80), newPod("m1", 9090)},
+			fits:         false,
+			test:         "one port taken fails",
+		},
+		{
+			pod:          newPod("", 0),
+			existingPods: []api.Pod{newPod("m1", 0)},
+			fits:         true,
+			test:         "ports not exposed on the host never conflict",
+		},
+	}
+	for _, test := range tests {
+		fits, err := PodFitsPorts(test.pod, test.existingPods, "m1")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.test, err)
+		}
+		if fits != test.fits {
+			t.Errorf("%s: expected fits %v, got %v", test.test, test.fits, fits)
+		}
+	}
+}
+
+func TestPodFitsResources(t *testing.T) {
+	tests := []struct {
+		pod          api.Pod
+		existingPods []api.Pod
+		fits         bool
+		test         string
+	}{
+		{
+			pod:          api.Pod{},
+			existingPods: []api.Pod{newResourcePod("m1", resourceRequest{milliCPU: 10, memory: 20})},
+			fits:         true,
+			test:         "no resources requested always fits",
+		},
+		{
+			pod:          newResourcePod("", resourceRequest{milliCPU: 1, memory: 1}),
+			existingPods: []api.Pod{newResourcePod("m1", resourceRequest{milliCPU: 10, memory: 20})},
+			fits:         false,
+			test:         "too many resources fails",
+		},
+		{
+			pod:          newResourcePod("", resourceRequest{milliCPU: 1, memory: 1}),
+			existingPods: []api.Pod{newResourcePod("m1", resourceRequest{milliCPU: 5, memory: 5})},
+			fits:         true,
+			test:         "both resources fit",
+		},
+		{
+			pod:          newResourcePod("", resourceRequest{milliCPU: 1, memory: 2}, resourceRequest{milliCPU: 1, memory: 1}),
+			existingPods: []api.Pod{newResourcePod("m1", resourceRequest{milliCPU: 5, memory: 18})},
+			fits:         false,
+			test:         "memory of all containers is counted",
+		},
+		{
+			pod:          newResourcePod("", resourceRequest{milliCPU: 2, memory: 1}),
+			existingPods: []api.Pod{newResourcePod("m1", resourceRequest{milliCPU: 9, memory: 5})},
+			fits:         false,
+			test:         "one resource does not fit",
+		},
+		{
+			pod:          newResourcePod("", resourceRequest{milliCPU: 1, memory: 1}),
+			existingPods: []api.Pod{newResourcePod("m1", resourceRequest{milliCPU: 9, memory: 19})},
+			fits:         true,
+			test:         "exactly fills the minion",
+		},
+	}
+	for _, test := range tests {
+		fit := ResourceFit{FakeMinionInfo{newMinion("m1", 10, 20)}}
+		fits, err := fit.PodFitsResources(test.pod, test.existingPods, "m1")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.test, err)
+		}
+		if fits != test.fits {
+			t.Errorf("%s: expected fits %v, got %v", test.test, test.fits, fits)
+		}
+	}
+}
+
+func TestPodFitsResourcesUnknownCapacity(t *testing.T) {
+	fit := ResourceFit{FakeMinionInfo{{JSONBase: api.JSONBase{ID: "m1"}}}}
+	pod := newResourcePod("", resourceRequest{milliCPU: 1000, memory: 1000})
+	fits, err := fit.PodFitsResources(pod, []api.Pod{pod}, "m1")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !fits {
+		t.Errorf("expected a minion without capacity to fit any pod")
+	}
+	if _, err := fit.PodFitsResources(pod, nil, "unknown"); err == nil {
+		t.Errorf("expected an error for an unknown minion")
+	}
+}
+
+func TestPodSelectorMatches(t *testing.T) {
+	tests := []struct {
+		pod    api.Pod
+		labels map[string]string
+		fits   bool
+		test   string
+	}{
+		{
+			pod:  api.Pod{},
+			fits: true,
+			test: "no selector",
+		},
+		{
+			pod:  api.Pod{NodeSelector: map[string]string{"disk": "ssd"}},
+			fits: false,
+			test: "minion without labels",
+		},
+		{
+			pod:    api.Pod{NodeSelector: map[string]string{"disk": "ssd"}},
+			labels: map[string]string{"disk": "ssd", "zone": "a"},
+			fits:   true,
+			test:   "matching labels",
+		},
+		{
+			pod:    api.Pod{NodeSelector: map[string]string{"disk": "ssd", "zone": "b"}},
+			labels: map[string]string{"disk": "ssd", "zone": "a"},
+			fits:   false,
+			test:   "one label differs",
+		},
+	}
+	for _, test := range tests {
+		minion := api.Minion{JSONBase: api.JSONBase{ID: "m1"}, Labels: test.labels}
+		selector := NodeSelector{FakeMinionInfo{minion}}
+		fits, err := selector.PodSelectorMatches(test.pod, []api.Pod{}, "m1")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.test, err)
+		}
+		if fits != test.fits {
+			t.Errorf("%s: expected fits %v, got %v", test.test, test.fits, fits)
+		}
+	}
+}
+
+func TestMinionIsReady(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		status api.MinionStatus
+		fits   bool
+		test   string
+	}{
+		{
+			fits: true,
+			test: "untracked minion",
+		},
+		{
+			status: api.MinionStatus{Condition: api.MinionReady, LastHeartbeatTime: util.Time{Time: now.Add(-10 * time.Second)}},
+			fits:   true,
+			test:   "recent heartbeat",
+		},
+		{
+			status: api.MinionStatus{Condition: api.MinionNotReady},
+			fits:   false,
+			test:   "not ready",
+		},
+		{
+			status: api.MinionStatus{Condition: api.MinionReady, LastHeartbeatTime: util.Time{Time: now.Add(-2 * time.Minute)}},
+			fits:   false,
+			test:   "stale heartbeat",
+		},
+	}
+	for _, test := range tests {
+		minion := api.Minion{JSONBase: api.JSONBase{ID: "m1"}, Status: test.status}
+		readiness := MinionReadiness{info: FakeMinionInfo{minion}, maxHeartbeatAge: time.Minute, now: func() time.Time { return now }}
+		fits, err := readiness.MinionIsReady(api.Pod{}, []api.Pod{}, "m1")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.test, err)
+		}
+		if fits != test.fits {
+			t.Errorf("%s: expected fits %v, got %v", test.test, test.fits, fits)
+		}
+	}
+
+	// Without a maximum age, only the condition counts.
+	minion := api.Minion{JSONBase: api.JSONBase{ID: "m1"}, Status: tests[3].status}
+	if fits, _ := NewMinionReadyPredicate(FakeMinionInfo{minion}, 0)(api.Pod{}, []api.Pod{}, "m1"); !fits {
+		t.Errorf("expected a stale minion to fit when heartbeat ages aren't checked")
+	}
+}
+
+func TestRandomFitSchedulerResourceFit(t *testing.T) {
+	fakeRegistry := FakePodLister{
+		newResourcePod("m1", resourceRequest{milliCPU: 900, memory: 1000}),
+		newResourcePod("m3", resourceRequest{milliCPU: 100, memory: 1000}),
+	}
+	minions := FakeMinionInfo{
+		newMinion("m1", 1000, 2000),
+		newMinion("m2", 100, 2000),
+		newMinion("m3", 1000, 2000),
+	}
+	r := rand.New(rand.NewSource(0))
+	st := schedulerTester{
+		t:            t,
+		scheduler:    NewRandomFitScheduler(fakeRegistry, r, NewResourceFitPredicate(minions)),
+		minionLister: FakeMinionLister{"m1", "m2", "m3"},
+	}
+	st.expectSchedule(newResourcePod("", resourceRequest{milliCPU: 500, memory: 500}), "m3")
+	st.expectFailure(newResourcePod("", resourceRequest{milliCPU: 500, memory: 1500}))
+}
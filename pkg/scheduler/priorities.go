@@ -0,0 +1,230 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+)
+
+// HostPriority is how much a scheduler prefers to place a pod on host. Higher
+// scores are better.
+type HostPriority struct {
+	host  string
+	score int
+}
+
+// HostPriorityList is a list of HostPriority, in no particular order.
+type HostPriorityList []HostPriority
+
+// PriorityFunction scores each of the minions minionLister lists for pod, given
+// the pods podLister finds.
+type PriorityFunction func(pod api.Pod, podLister PodLister, minionLister MinionLister) (HostPriorityList, error)
+
+// EqualPriority scores every minion the same.
+func EqualPriority(pod api.Pod, podLister PodLister, minionLister MinionLister) (HostPriorityList, error) {
+	minions, err := minionLister.List()
+	if err != nil {
+		return nil, err
+	}
+	result := HostPriorityList{}
+	for _, minion := range minions {
+		result = append(result, HostPriority{host: minion, score: 1})
+	}
+	return result, nil
+}
+
+// CalculateSpreadPriority prefers the minions running the fewest pods with all
+// the labels of pod, such as the other replicas of its replication controller
+// or the other pods of its service. The minions running none of them score 10,
+// the ones running the most score 0.
+func CalculateSpreadPriority(pod api.Pod, podLister PodLister, minionLister MinionLister) (HostPriorityList, error) {
+	pods, err := podLister.ListPods(labels.SelectorFromSet(pod.Labels))
+	if err != nil {
+		return nil, err
+	}
+	minions, err := minionLister.List()
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	maxCount := 0
+	for _, pod := range pods {
+		host := podHost(pod)
+		counts[host]++
+		if counts[host] > maxCount {
+			maxCount = counts[host]
+		}
+	}
+	result := HostPriorityList{}
+	for _, minion := range minions {
+		score := 10
+		if maxCount > 0 {
+			score = 10 * (maxCount - counts[minion]) / maxCount
+		}
+		result = append(result, HostPriority{host: minion, score: score})
+	}
+	return result, nil
+}
+
+// LeastRequested prefers the minions with the most CPU and memory left over once
+// pods are placed there.
+type LeastRequested struct {
+	info MinionInfo
+}
+
+// NewLeastRequestedPriority returns a PriorityFunction scoring minions, whose
+// capacity info finds, by the share of their CPU and memory which neither the
+// pods already there nor the pod to place ask for. Minions score 10 for each
+// resource they have entirely free and 0 for each which is fully requested or
+// whose capacity is unknown; the two scores are averaged.
+func NewLeastRequestedPriority(info MinionInfo) PriorityFunction {
+	leastRequested := &LeastRequested{info: info}
+	return leastRequested.CalculatePriority
+}
+
+// CalculatePriority is a PriorityFunction.
+func (l *LeastRequested) CalculatePriority(pod api.Pod, podLister PodLister, minionLister MinionLister) (HostPriorityList, error) {
+	minions, err := minionLister.List()
+	if err != nil {
+		return nil, err
+	}
+	podsOnMachine, err := newMachinePodsFunc(podLister)
+	if err != nil {
+		return nil, err
+	}
+	result := HostPriorityList{}
+	for _, minion := range minions {
+		info, err := l.info.GetMinionInfo(minion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get capacity of minion %s: %v", minion, err)
+		}
+		existingPods, err := podsOnMachine(minion)
+		if err != nil {
+			return nil, err
+		}
+		requested := resourceRequest{}
+		requested.add(pod)
+		for _, existingPod := range existingPods {
+			requested.add(existingPod)
+		}
+		capacity := info.NodeResources.Capacity
+		cpuScore := unrequestedScore(requested.milliCPU, capacity[api.ResourceCPU])
+		memoryScore := unrequestedScore(requested.memory, capacity[api.ResourceMemory])
+		result = append(result, HostPriority{host: minion, score: (cpuScore + memoryScore) / 2})
+	}
+	return result, nil
+}
+
+// unrequestedScore scores how much of capacity is left over once requested is
+// taken from it, from 0 to 10.
+func unrequestedScore(requested, capacity int64) int {
+	if capacity <= 0 || requested > capacity {
+		return 0
+	}
+	return int((capacity - requested) * 10 / capacity)
+}
+
+// ServiceAntiAffinity prefers the minions running the fewest pods of the services
+// a pod backs, so that a service survives the failure of a minion, or of a zone.
+type ServiceAntiAffinity struct {
+	services ServiceLister
+	info     MinionInfo
+	label    string
+}
+
+// NewServiceAntiAffinityPriority returns a PriorityFunction spreading the pods of
+// each service over minions, if label is empty, or else over the groups of
+// minions sharing the value of label, such as the zone they are in, as info
+// finds their labels. Minions without label score 0.
+func NewServiceAntiAffinityPriority(services ServiceLister, info MinionInfo, label string) PriorityFunction {
+	antiAffinity := &ServiceAntiAffinity{
+		services: services,
+		info:     info,
+		label:    label,
+	}
+	return antiAffinity.CalculateAntiAffinityPriority
+}
+
+// group returns which group minion is in, if any.
+func (s *ServiceAntiAffinity) group(minion string) (string, bool, error) {
+	if s.label == "" {
+		return minion, true, nil
+	}
+	info, err := s.info.GetMinionInfo(minion)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := info.Labels[s.label]
+	return value, ok, nil
+}
+
+// CalculateAntiAffinityPriority is a PriorityFunction.
+func (s *ServiceAntiAffinity) CalculateAntiAffinityPriority(pod api.Pod, podLister PodLister, minionLister MinionLister) (HostPriorityList, error) {
+	services, err := s.services.ListServices()
+	if err != nil {
+		return nil, err
+	}
+	// The pods of every service pod would back, each counted once.
+	servicePods := map[string]api.Pod{}
+	for _, service := range services.Items {
+		if len(service.Selector) == 0 {
+			continue
+		}
+		selector := labels.SelectorFromSet(service.Selector)
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		pods, err := podLister.ListPods(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, servicePod := range pods {
+			servicePods[servicePod.ID] = servicePod
+		}
+	}
+	counts := map[string]int{}
+	for _, servicePod := range servicePods {
+		// The minions of pods may be gone, or not be considered for pod.
+		group, ok, err := s.group(podHost(servicePod))
+		if err == nil && ok {
+			counts[group]++
+		}
+	}
+	minions, err := minionLister.List()
+	if err != nil {
+		return nil, err
+	}
+	result := HostPriorityList{}
+	for _, minion := range minions {
+		group, ok, err := s.group(minion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get labels of minion %s: %v", minion, err)
+		}
+		score := 0
+		if ok {
+			score = 10
+			if len(servicePods) > 0 {
+				score = 10 * (len(servicePods) - counts[group]) / len(servicePods)
+			}
+		}
+		result = append(result, HostPriority{host: minion, score: score})
+	}
+	return result, nil
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+func TestSpreadPriority(t *testing.T) {
+	labels1 := map[string]string{
+		"foo": "bar",
+		"baz": "blah",
+	}
+	labels2 := map[string]string{
+		"bar": "foo",
+		"baz": "blah",
+	}
+	onMachine := func(host string, labels map[string]string) api.Pod {
+		return api.Pod{
+			Labels:       labels,
+			DesiredState: api.PodState{Host: host},
+		}
+	}
+	tests := []struct {
+		pod          api.Pod
+		pods         []api.Pod
+		minions      []string
+		expectedList HostPriorityList
+		test         string
+	}{
+		{
+			pod:          api.Pod{Labels: labels1},
+			minions:      []string{"machine1", "machine2"},
+			expectedList: HostPriorityList{{"machine1", 10}, {"machine2", 10}},
+			test:         "nothing scheduled",
+		},
+		{
+			pod:          api.Pod{Labels: labels1},
+			pods:         []api.Pod{onMachine("machine1", labels2)},
+			minions:      []string{"machine1", "machine2"},
+			expectedList: HostPriorityList{{"machine1", 10}, {"machine2", 10}},
+			test:         "no pods with the same labels",
+		},
+		{
+			pod:          api.Pod{Labels: labels1},
+			pods:         []api.Pod{onMachine("machine1", labels1)},
+			minions:      []string{"machine1", "machine2"},
+			expectedList: HostPriorityList{{"machine1", 0}, {"machine2", 10}},
+			test:         "one pod with the same labels",
+		},
+		{
+			pod: api.Pod{Labels: labels1},
+			pods: []api.Pod{
+				onMachine("machine1", labels2),
+				onMachine("machine1", labels1),
+				onMachine("machine2", labels1),
+				onMachine("machine2", labels1),
+				onMachine("machine2", labels1),
+			},
+			minions:      []string{"machine1", "machine2", "machine3"},
+			expectedList: HostPriorityList{{"machine1", 6}, {"machine2", 0}, {"machine3", 10}},
+			test:         "fewer pods with the same labels score higher",
+		},
+	}
+	for _, test := range tests {
+		list, err := CalculateSpreadPriority(test.pod, FakePodLister(test.pods), FakeMinionLister(test.minions))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.test, err)
+		}
+		if !reflect.DeepEqual(test.expectedList, list) {
+			t.Errorf("%s: expected %#v, got %#v", test.test, test.expectedList, list)
+		}
+	}
+}
+
+func TestLeastRequested(t *testing.T) {
+	minions := FakeMinionInfo{
+		newMinion("machine1", 4000, 10000),
+		newMinion("machine2", 4000, 10000),
+		{JSONBase: api.JSONBase{ID: "machine3"}},
+	}
+	tests := []struct {
+		pod          api.Pod
+		pods         []api.Pod
+		expectedList HostPriorityList
+		test         string
+	}{
+		{
+			pod:          api.Pod{},
+			expectedList: HostPriorityList{{"machine1", 10}, {"machine2", 10}, {"machine3", 0}},
+			test:         "nothing requested",
+		},
+		{
+			pod: newResourcePod("", resourceRequest{milliCPU: 1000, memory: 2000}),
+			pods: []api.Pod{
+				newResourcePod("machine1", resourceRequest{milliCPU: 1000, memory: 3000}),
+				newResourcePod("machine2", resourceRequest{milliCPU: 2000, memory: 5000}),
+			},
+			// machine1: CPU (4000-2000)*10/4000 = 5, memory (10000-5000)*10/10000 = 5.
+			// machine2: CPU (4000-3000)*10/4000 = 2, memory (10000-7000)*10/10000 = 3.
+			expectedList: HostPriorityList{{"machine1", 5}, {"machine2", 2}, {"machine3", 0}},
+			test:         "pods requesting resources",
+		},
+		{
+			pod:          newResourcePod("", resourceRequest{milliCPU: 5000, memory: 2000}),
+			expectedList: HostPriorityList{{"machine1", 4}, {"machine2", 4}, {"machine3", 0}},
+			test:         "more requested than the capacity",
+		},
+	}
+	for _, test := range tests {
+		prioritizer := NewLeastRequestedPriority(minions)
+		list, err := prioritizer(test.pod, FakePodLister(test.pods), FakeMinionLister{"machine1", "machine2", "machine3"})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.test, err)
+		}
+		if !reflect.DeepEqual(test.expectedList, list) {
+			t.Errorf("%s: expected %#v, got %#v", test.test, test.expectedList, list)
+		}
+	}
+}
+
+func TestServiceAntiAffinityPriority(t *testing.T) {
+	web := map[string]string{"name": "web"}
+	db := map[string]string{"name": "db"}
+	onMachine := func(id, host string, labels map[string]string) api.Pod {
+		return api.Pod{
+			JSONBase:     api.JSONBase{ID: id},
+			Labels:       labels,
+			DesiredState: api.PodState{Host: host},
+		}
+	}
+	zoned := func(id, zone string) api.Minion {
+		minion := api.Minion{JSONBase: api.JSONBase{ID: id}}
+		if zone != "" {
+			minion.Labels = map[string]string{"zone": zone}
+		}
+		return minion
+	}
+	minions := FakeMinionInfo{zoned("m1", "a"), zoned("m2", "a"), zoned("m3", "b"), zoned("m4", "")}
+	services := FakeServiceLister{{JSONBase: api.JSONBase{ID: "web"}, Selector: web}}
+	tests := []struct {
+		pod          api.Pod
+		pods         []api.Pod
+		label        string
+		expectedList HostPriorityList
+		test         string
+	}{
+		{
+			pod:          api.Pod{Labels: web},
+			label:        "zone",
+			expectedList: HostPriorityList{{"m1", 10}, {"m2", 10}, {"m3", 10}, {"m4", 0}},
+			test:         "no pods of the service yet",
+		},
+		{
+			pod:          api.Pod{Labels: db},
+			pods:         []api.Pod{onMachine("w1", "m1", web)},
+			label:        "zone",
+			expectedList: HostPriorityList{{"m1", 10}, {"m2", 10}, {"m3", 10}, {"m4", 0}},
+			test:         "pod of no service",
+		},
+		{
+			pod: api.Pod{Labels: web},
+			pods: []api.Pod{
+				onMachine("w1", "m1", web),
+				onMachine("w2", "m2", web),
+				onMachine("w3", "m3", web),
+				onMachine("w4", "m4", web),
+				onMachine("d1", "m3", db),
+			},
+			label: "zone",
+			// Zone a runs 2 of 4, zone b 1 of 4.
+			expectedList: HostPriorityList{{"m1", 5}, {"m2", 5}, {"m3", 7}, {"m4", 0}},
+			test:         "spread over zones",
+		},
+		{
+			pod: api.Pod{Labels: web},
+			pods: []api.Pod{
+				onMachine("w1", "m1", web),
+				onMachine("w2", "m1", web),
+				onMachine("w3", "m3", web),
+				onMachine("d1", "m2", db),
+			},
+			expectedList: HostPriorityList{{"m1", 3}, {"m2", 10}, {"m3", 6}, {"m4", 10}},
+			test:         "spread over minions",
+		},
+	}
+	for _, test := range tests {
+		prioritizer := NewServiceAntiAffinityPriority(services, minions, test.label)
+		list, err := prioritizer(test.pod, FakePodLister(test.pods), FakeMinionLister{"m1", "m2", "m3", "m4"})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.test, err)
+		}
+		if !reflect.DeepEqual(test.expectedList, list) {
+			t.Errorf("%s: expected %#v, got %#v", test.test, test.expectedList, list)
+		}
+	}
+}
@@ -19,76 +19,21 @@ package scheduler
 import (
 	"fmt"
 	"math/rand"
-	"sync"
-
-	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
-	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
 )
 
-// RandomFitScheduler is a Scheduler which schedules a Pod on a random machine which matches its requirement.
-type RandomFitScheduler struct {
-	podLister  PodLister
-	random     *rand.Rand
-	randomLock sync.Mutex
-}
-
-func NewRandomFitScheduler(podLister PodLister, random *rand.Rand) Scheduler {
-	return &RandomFitScheduler{
-		podLister: podLister,
-		random:    random,
-	}
-}
-
-func (s *RandomFitScheduler) containsPort(pod api.Pod, port api.Port) bool {
-	for _, container := range pod.DesiredState.Manifest.Containers {
-		for _, podPort := range container.Ports {
-			if podPort.HostPort == port.HostPort {
-				return true
-			}
-		}
-	}
-	return false
-}
-
-// Schedule schedules a pod on a random machine which matches its requirement.
-func (s *RandomFitScheduler) Schedule(pod api.Pod, minionLister MinionLister) (string, error) {
-	machines, err := minionLister.List()
-	if err != nil {
-		return "", err
-	}
-	machineToPods := map[string][]api.Pod{}
-	// TODO: perform more targeted query...
-	pods, err := s.podLister.ListPods(labels.Everything())
-	if err != nil {
-		return "", err
-	}
-	for _, scheduledPod := range pods {
-		host := scheduledPod.CurrentState.Host
-		machineToPods[host] = append(machineToPods[host], scheduledPod)
-	}
-	var machineOptions []string
-	for _, machine := range machines {
-		podFits := true
-		for _, scheduledPod := range machineToPods[machine] {
-			for _, container := range pod.DesiredState.Manifest.Containers {
-				for _, port := range container.Ports {
-					if port.HostPort == 0 {
-						continue
-					}
-					if s.containsPort(scheduledPod, port) {
-						podFits = false
-					}
-				}
-			}
-		}
-		if podFits {
-			machineOptions = append(machineOptions, machine)
-		}
+// NewRandomFitScheduler returns a Scheduler which places a pod on a random machine
+// where its host ports are free and all of predicates hold.
+// The predicates are named after their position in FitErrors, e.g. "Predicate0".
+func NewRandomFitScheduler(podLister PodLister, random *rand.Rand, predicates ...FitPredicate) Scheduler {
+	named := map[string]FitPredicate{"PodFitsPorts": PodFitsPorts}
+	for i, predicate := range predicates {
+		named[fmt.Sprintf("Predicate%d", i)] = predicate
 	}
-	if len(machineOptions) == 0 {
-		return "", fmt.Errorf("failed to find fit for %#v", pod)
+	return &genericScheduler{
+		predicates: named,
+		pods:       podLister,
+		pickHost: func(best []string) string {
+			return best[random.Int()%len(best)]
+		},
 	}
-	s.randomLock.Lock()
-	defer s.randomLock.Unlock()
-	return machineOptions[s.random.Int()%len(machineOptions)], nil
 }
@@ -76,3 +76,19 @@ func TestRandomFitSchedulerFirstScheduledImpossible(t *testing.T) {
 	}
 	st.expectFailure(newPod("", 8080, 8081))
 }
+
+func TestRandomFitSchedulerCountsAssignedPods(t *testing.T) {
+	assigned := newPod("", 8080)
+	assigned.DesiredState.Host = "m1"
+	fakeRegistry := FakePodLister{
+		assigned,
+		newPod("m2", 8080),
+	}
+	r := rand.New(rand.NewSource(0))
+	st := schedulerTester{
+		t:            t,
+		scheduler:    NewRandomFitScheduler(fakeRegistry, r),
+		minionLister: FakeMinionLister{"m1", "m2", "m3"},
+	}
+	st.expectSchedule(newPod("", 8080), "m3")
+}
@@ -25,3 +25,21 @@ import (
 type Scheduler interface {
 	Schedule(api.Pod, MinionLister) (selectedMachine string, err error)
 }
+
+const (
+	// SchedulerAnnotation is the annotation of pods naming the scheduler which
+	// is to place them.
+	SchedulerAnnotation = "scheduler"
+	// DefaultSchedulerName is the name of the scheduler which places the pods
+	// naming none.
+	DefaultSchedulerName = "default"
+)
+
+// IsResponsible returns whether the scheduler called name is to place pod.
+func IsResponsible(name string, pod api.Pod) bool {
+	podScheduler, ok := pod.Annotations[SchedulerAnnotation]
+	if !ok || podScheduler == "" {
+		podScheduler = DefaultSchedulerName
+	}
+	return podScheduler == name
+}
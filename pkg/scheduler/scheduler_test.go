@@ -79,3 +79,24 @@ func newPod(host string, hostPorts ...int) api.Pod {
 		},
 	}
 }
+
+func TestIsResponsible(t *testing.T) {
+	table := []struct {
+		name        string
+		annotations map[string]string
+		expected    bool
+	}{
+		{DefaultSchedulerName, nil, true},
+		{DefaultSchedulerName, map[string]string{SchedulerAnnotation: ""}, true},
+		{DefaultSchedulerName, map[string]string{SchedulerAnnotation: DefaultSchedulerName}, true},
+		{DefaultSchedulerName, map[string]string{SchedulerAnnotation: "batch"}, false},
+		{"batch", nil, false},
+		{"batch", map[string]string{SchedulerAnnotation: "batch"}, true},
+	}
+	for _, item := range table {
+		pod := api.Pod{Annotations: item.annotations}
+		if e, a := item.expected, IsResponsible(item.name, pod); e != a {
+			t.Errorf("Expected %v for %v and %v, got %v", e, item.name, item.annotations, a)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+)
+
+// instrumented is an Interface recording metrics about the calls made to the
+// Interface it wraps.
+type instrumented struct {
+	Interface
+	kind    string
+	metrics *metrics.Registry
+}
+
+// Instrument returns an Interface calling s, which records the latency and
+// errors of every call to s in registry as "registry.<kind>.<method>.latency"
+// and ".errors". Conflicts are counted in "registry.<kind>.conflicts", and the
+// tries AtomicUpdate had to repeat in "registry.<kind>.AtomicUpdate.retries".
+func Instrument(kind string, s Interface, registry *metrics.Registry) Interface {
+	return &instrumented{s, kind, registry}
+}
+
+// RecordCall records in registry a call to method of the registry of kind,
+// which started at start and returned err, under the names Instrument uses.
+// It is for registries which aren't an Interface.
+func RecordCall(registry *metrics.Registry, kind, method string, start time.Time, err error) {
+	prefix := "registry." + kind + "."
+	registry.Histogram(prefix + method + ".latency").Since(start)
+	if err != nil {
+		registry.Counter(prefix + method + ".errors").Inc()
+	}
+	if apiserver.IsConflict(err) {
+		registry.Counter(prefix + "conflicts").Inc()
+	}
+}
+
+// record records a call to method which started at start and returned err.
+func (i *instrumented) record(method string, start time.Time, err error) {
+	RecordCall(i.metrics, i.kind, method, start, err)
+}
+
+func (i *instrumented) Get(id string, objPtr interface{}) error {
+	start := time.Now()
+	err := i.Interface.Get(id, objPtr)
+	i.record("Get", start, err)
+	return err
+}
+
+func (i *instrumented) List(slicePtr interface{}, resourceVersion *uint64) error {
+	start := time.Now()
+	err := i.Interface.List(slicePtr, resourceVersion)
+	i.record("List", start, err)
+	return err
+}
+
+func (i *instrumented) Watch(resourceVersion uint64, filter tools.FilterFunc) (watch.Interface, error) {
+	start := time.Now()
+	w, err := i.Interface.Watch(resourceVersion, filter)
+	i.record("Watch", start, err)
+	return w, err
+}
+
+func (i *instrumented) Create(id string, obj interface{}) error {
+	start := time.Now()
+	err := i.Interface.Create(id, obj)
+	i.record("Create", start, err)
+	return err
+}
+
+func (i *instrumented) Update(id string, obj interface{}) error {
+	start := time.Now()
+	err := i.Interface.Update(id, obj)
+	i.record("Update", start, err)
+	return err
+}
+
+func (i *instrumented) AtomicUpdate(id string, ptrToType interface{}, tryUpdate UpdateFunc) error {
+	start := time.Now()
+	tries := uint64(0)
+	err := i.Interface.AtomicUpdate(id, ptrToType, func(obj interface{}) (interface{}, error) {
+		tries++
+		return tryUpdate(obj)
+	})
+	if tries > 1 {
+		i.metrics.Counter("registry." + i.kind + ".AtomicUpdate.retries").Add(tries - 1)
+	}
+	i.record("AtomicUpdate", start, err)
+	return err
+}
+
+func (i *instrumented) Delete(id string, recursive bool) error {
+	start := time.Now()
+	err := i.Interface.Delete(id, recursive)
+	i.record("Delete", start, err)
+	return err
+}
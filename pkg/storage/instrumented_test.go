@@ -0,0 +1,53 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
+)
+
+func TestInstrument(t *testing.T) {
+	m := newTestMemory()
+	r := metrics.NewRegistry()
+	s := Instrument("service", m, r)
+
+	s.Create("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo"}})
+	s.Create("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo"}})
+	s.Update("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo", ResourceVersion: 7}})
+	m.InjectConflicts(2)
+	s.AtomicUpdate("foo", &api.Service{}, func(obj interface{}) (interface{}, error) {
+		return obj, nil
+	})
+
+	snapshot := r.Snapshot()
+	for name, expected := range map[string]uint64{
+		"registry.service.Create.errors":        1,
+		"registry.service.Update.errors":        1,
+		"registry.service.conflicts":            1,
+		"registry.service.AtomicUpdate.retries": 2,
+	} {
+		if snapshot.Counters[name] != expected {
+			t.Errorf("expected %s to be %d, got %d", name, expected, snapshot.Counters[name])
+		}
+	}
+	if n := snapshot.Histograms["registry.service.Create.latency"].Count; n != 2 {
+		t.Errorf("expected 2 creates timed, got %d", n)
+	}
+}
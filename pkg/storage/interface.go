@@ -0,0 +1,55 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+)
+
+// UpdateFunc is passed the current object (or a new, empty one if none exists)
+// and returns the object to store in its place, or an error to abort the update.
+type UpdateFunc func(input interface{}) (output interface{}, err error)
+
+// Interface stores one kind of object, keyed by ID. Objects read from storage
+// have their resourceVersion set to the version they were stored at.
+//
+// Implementations report failures with the apiserver errors: not found, already
+// exists and conflict.
+type Interface interface {
+	// Get reads the object with the given ID into objPtr.
+	Get(id string, objPtr interface{}) error
+	// List reads every object into the slice slicePtr points to. If
+	// resourceVersion is not nil, it is set to the version the list reflects:
+	// watching from just after it shows every later change.
+	List(slicePtr interface{}, resourceVersion *uint64) error
+	// Watch sends every change after resourceVersion to objects passing filter.
+	// A resourceVersion of 0 starts with the objects currently stored.
+	Watch(resourceVersion uint64, filter tools.FilterFunc) (watch.Interface, error)
+	// Create stores obj under the given ID, which must not be in use yet.
+	Create(id string, obj interface{}) error
+	// Update replaces the object with the given ID with obj. Whether obj's
+	// resourceVersion must match the stored one is up to the implementation.
+	Update(id string, obj interface{}) error
+	// AtomicUpdate reads the object with the given ID into a new object of
+	// ptrToType's type, and stores what tryUpdate makes of it. If the object
+	// changes in the meantime, tryUpdate is called again with the new one.
+	AtomicUpdate(id string, ptrToType interface{}, tryUpdate UpdateFunc) error
+	// Delete removes the object with the given ID. If recursive is set, anything
+	// stored beneath it goes with it.
+	Delete(id string, recursive bool) error
+}
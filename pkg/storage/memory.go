@@ -0,0 +1,431 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+	"github.com/golang/glog"
+)
+
+// memoryHistoryLimit is how many changes a Memory keeps for watches at least,
+// like the event history of etcd.
+var memoryHistoryLimit = 1000
+
+// memoryEntry is an object as stored by Memory.
+type memoryEntry struct {
+	data    []byte
+	version uint64
+}
+
+// memoryEvent is a change recorded by Memory. For deletions, data is the last
+// stored state of the object.
+type memoryEvent struct {
+	action  watch.EventType
+	data    []byte
+	version uint64
+}
+
+// MemoryClock hands out versions to the Memory backends sharing it. Like the
+// index of an etcd cluster, it orders the writes to all of them.
+type MemoryClock struct {
+	version uint64
+}
+
+// NewMemoryClock returns a MemoryClock which hasn't handed out any version.
+func NewMemoryClock() *MemoryClock {
+	return &MemoryClock{}
+}
+
+func (c *MemoryClock) next() uint64 {
+	return atomic.AddUint64(&c.version, 1)
+}
+
+func (c *MemoryClock) current() uint64 {
+	return atomic.LoadUint64(&c.version)
+}
+
+// Memory is an Interface keeping objects in memory, meant for tests. Objects
+// are stored encoded, so callers never share them. Like etcd, every write is
+// given the next version number, and Update does a compare-and-swap on the
+// object's resourceVersion.
+//
+// The last memoryHistoryLimit changes are kept so that watches can start from
+// a recent resourceVersion. As with etcd, watching from an older one fails, and
+// a watch falling further behind is ended.
+type Memory struct {
+	kind      string
+	codec     tools.Codec
+	versioner tools.ResourceVersioner
+	clock     *MemoryClock
+
+	// lock guards everything below; changed is signalled on every write.
+	lock    sync.Mutex
+	changed *sync.Cond
+	items   map[string]memoryEntry
+	history []memoryEvent
+	// dropped is the number of changes dropped from the front of history, and
+	// droppedVersion the version of the last of them. Watchers count their
+	// position in history from the first change ever made.
+	dropped        int
+	droppedVersion uint64
+	// conflicts is the number of writes still to be beaten by a fake
+	// concurrent writer; see InjectConflicts.
+	conflicts int
+}
+
+// NewMemory returns an empty Memory with a clock of its own. kind names the
+// objects in errors.
+func NewMemory(kind string, codec tools.Codec, versioner tools.ResourceVersioner) *Memory {
+	return NewMemoryWithClock(kind, codec, versioner, NewMemoryClock())
+}
+
+// NewMemoryWithClock returns an empty Memory taking its versions from clock.
+func NewMemoryWithClock(kind string, codec tools.Codec, versioner tools.ResourceVersioner, clock *MemoryClock) *Memory {
+	m := &Memory{
+		kind:      kind,
+		codec:     codec,
+		versioner: versioner,
+		clock:     clock,
+		items:     map[string]memoryEntry{},
+	}
+	m.changed = sync.NewCond(&m.lock)
+	return m
+}
+
+// InjectConflicts makes the next n calls to Update or AtomicUpdate of a stored
+// object find it rewritten by someone else just before they write, so that
+// they fail or retry the way they would when racing another client of etcd.
+func (m *Memory) InjectConflicts(n int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.conflicts = n
+}
+
+// injectConflict rewrites the object stored under id unchanged, if a conflict
+// is due, and reports whether it did. Must be called with the lock held.
+func (m *Memory) injectConflict(id string) bool {
+	entry, exists := m.items[id]
+	if !exists || m.conflicts == 0 {
+		return false
+	}
+	m.conflicts--
+	m.write(id, entry.data)
+	return true
+}
+
+// decodeInto decodes entry into objPtr and sets its resourceVersion.
+func (m *Memory) decodeInto(entry memoryEntry, objPtr interface{}) error {
+	if err := m.codec.DecodeInto(entry.data, objPtr); err != nil {
+		return err
+	}
+	return m.versioner.SetResourceVersion(objPtr, entry.version)
+}
+
+// write stores data under id, or removes id if data is nil, and records the
+// change. Must be called with the lock held.
+func (m *Memory) write(id string, data []byte) {
+	version := m.clock.next()
+	event := memoryEvent{data: data, version: version}
+	old, exists := m.items[id]
+	switch {
+	case data == nil:
+		event.action = watch.Deleted
+		event.data = old.data
+		delete(m.items, id)
+	case exists:
+		event.action = watch.Modified
+		m.items[id] = memoryEntry{data, version}
+	default:
+		event.action = watch.Added
+		m.items[id] = memoryEntry{data, version}
+	}
+	m.history = append(m.history, event)
+	if excess := len(m.history) - memoryHistoryLimit; excess >= memoryHistoryLimit {
+		// Dropping changes in batches keeps writes cheap.
+		m.droppedVersion = m.history[excess-1].version
+		m.dropped += excess
+		m.history = append([]memoryEvent(nil), m.history[excess:]...)
+	}
+	m.changed.Broadcast()
+}
+
+// Get implements Interface.
+func (m *Memory) Get(id string, objPtr interface{}) error {
+	m.lock.Lock()
+	entry, ok := m.items[id]
+	m.lock.Unlock()
+	if !ok {
+		return apiserver.NewNotFoundErr(m.kind, id)
+	}
+	return m.decodeInto(entry, objPtr)
+}
+
+// List implements Interface. Objects are listed in order of their IDs.
+func (m *Memory) List(slicePtr interface{}, resourceVersion *uint64) error {
+	pv := reflect.ValueOf(slicePtr)
+	if pv.Type().Kind() != reflect.Ptr || pv.Type().Elem().Kind() != reflect.Slice {
+		// This should not happen at runtime.
+		panic("need ptr to slice")
+	}
+	m.lock.Lock()
+	ids := make([]string, 0, len(m.items))
+	for id := range m.items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	entries := make([]memoryEntry, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, m.items[id])
+	}
+	if resourceVersion != nil {
+		*resourceVersion = m.clock.current()
+	}
+	m.lock.Unlock()
+
+	v := pv.Elem()
+	for _, entry := range entries {
+		obj := reflect.New(v.Type().Elem())
+		if err := m.decodeInto(entry, obj.Interface()); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, obj.Elem()))
+	}
+	return nil
+}
+
+// Create implements Interface.
+func (m *Memory) Create(id string, obj interface{}) error {
+	data, err := m.codec.Encode(obj)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exists := m.items[id]; exists {
+		return apiserver.NewAlreadyExistsErr(m.kind, id)
+	}
+	m.write(id, data)
+	return nil
+}
+
+// Update implements Interface. obj replaces the stored object only if it has
+// the stored object's resourceVersion. An object without a resourceVersion is
+// created, and only if nothing is stored yet.
+func (m *Memory) Update(id string, obj interface{}) error {
+	version, err := m.versioner.ResourceVersion(obj)
+	if err != nil {
+		return err
+	}
+	data, err := m.codec.Encode(obj)
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.injectConflict(id)
+	entry, exists := m.items[id]
+	switch {
+	case version == 0 && exists:
+		return apiserver.NewConflictErr(m.kind, id, fmt.Errorf("%s %q already exists", m.kind, id))
+	case version != 0 && !exists:
+		return apiserver.NewNotFoundErr(m.kind, id)
+	case version != entry.version:
+		return apiserver.NewConflictErr(m.kind, id, fmt.Errorf("resourceVersion %d is out of date", version))
+	}
+	m.write(id, data)
+	return nil
+}
+
+// AtomicUpdate implements Interface.
+func (m *Memory) AtomicUpdate(id string, ptrToType interface{}, tryUpdate UpdateFunc) error {
+	pt := reflect.TypeOf(ptrToType)
+	if pt.Kind() != reflect.Ptr {
+		// Panic is appropriate, because this is a programming error.
+		panic("need ptr to type")
+	}
+	for {
+		obj := reflect.New(pt.Elem()).Interface()
+		m.lock.Lock()
+		entry, exists := m.items[id]
+		m.lock.Unlock()
+		if exists {
+			if err := m.decodeInto(entry, obj); err != nil {
+				return err
+			}
+		}
+
+		ret, err := tryUpdate(obj)
+		if err != nil {
+			return err
+		}
+		data, err := m.codec.Encode(ret)
+		if err != nil {
+			return err
+		}
+
+		m.lock.Lock()
+		m.injectConflict(id)
+		current, stillExists := m.items[id]
+		if exists != stillExists || current.version != entry.version {
+			// Lost a race; try again with the new object.
+			m.lock.Unlock()
+			continue
+		}
+		if !exists || string(data) != string(entry.data) {
+			m.write(id, data)
+		}
+		m.lock.Unlock()
+		return nil
+	}
+}
+
+// Delete implements Interface. Objects have nothing beneath them, so recursive
+// makes no difference.
+func (m *Memory) Delete(id string, recursive bool) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exists := m.items[id]; !exists {
+		return apiserver.NewNotFoundErr(m.kind, id)
+	}
+	m.write(id, nil)
+	return nil
+}
+
+// Watch implements Interface. It fails if changes after resourceVersion were
+// dropped from the history already.
+func (m *Memory) Watch(resourceVersion uint64, filter tools.FilterFunc) (watch.Interface, error) {
+	w := &memoryWatcher{
+		m:      m,
+		filter: filter,
+		result: make(chan watch.Event),
+		stop:   make(chan struct{}),
+	}
+	m.lock.Lock()
+	if resourceVersion != 0 && resourceVersion <= m.droppedVersion {
+		m.lock.Unlock()
+		return nil, fmt.Errorf("%s history starts after resourceVersion %d", m.kind, m.droppedVersion)
+	}
+	if resourceVersion == 0 {
+		// Start with what's stored now, like etcd does.
+		ids := []string{}
+		for id := range m.items {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			entry := m.items[id]
+			w.initial = append(w.initial, memoryEvent{watch.Added, entry.data, entry.version})
+		}
+		w.next = m.dropped + len(m.history)
+	} else {
+		w.next = m.dropped + sort.Search(len(m.history), func(i int) bool {
+			return m.history[i].version >= resourceVersion
+		})
+	}
+	m.lock.Unlock()
+	go w.loop()
+	return w, nil
+}
+
+// memoryWatcher sends a Memory's changes, starting from the next-th change made.
+type memoryWatcher struct {
+	m       *Memory
+	filter  tools.FilterFunc
+	initial []memoryEvent
+	// next is guarded by m.lock.
+	next    int
+	stopped bool
+
+	result   chan watch.Event
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// ResultChan implements watch.Interface.
+func (w *memoryWatcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+// Stop implements watch.Interface.
+func (w *memoryWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		w.m.lock.Lock()
+		w.stopped = true
+		w.m.changed.Broadcast()
+		w.m.lock.Unlock()
+	})
+}
+
+// nextEvent waits for the next change, returning false once the watcher is
+// stopped, or has fallen so far behind that the change was dropped.
+func (w *memoryWatcher) nextEvent() (memoryEvent, bool) {
+	if len(w.initial) > 0 {
+		event := w.initial[0]
+		w.initial = w.initial[1:]
+		return event, true
+	}
+	w.m.lock.Lock()
+	defer w.m.lock.Unlock()
+	for !w.stopped && w.next >= w.m.dropped+len(w.m.history) {
+		w.m.changed.Wait()
+	}
+	if w.stopped {
+		return memoryEvent{}, false
+	}
+	if w.next < w.m.dropped {
+		glog.Warningf("Ending a watch of %s which fell behind the history", w.m.kind)
+		return memoryEvent{}, false
+	}
+	event := w.m.history[w.next-w.m.dropped]
+	w.next++
+	return event, true
+}
+
+func (w *memoryWatcher) loop() {
+	defer close(w.result)
+	for {
+		event, ok := w.nextEvent()
+		if !ok {
+			return
+		}
+		obj, err := w.m.codec.Decode(event.data)
+		if err != nil {
+			glog.Errorf("failure to decode api object: '%v': %v", string(event.data), err)
+			continue
+		}
+		if err := w.m.versioner.SetResourceVersion(obj, event.version); err != nil {
+			glog.Errorf("failure to version api object (%d) %#v: %v", event.version, obj, err)
+		}
+		if w.filter != nil && !w.filter(obj) {
+			continue
+		}
+		select {
+		case w.result <- watch.Event{Type: event.action, Object: obj}:
+		case <-w.stop:
+			return
+		}
+	}
+}
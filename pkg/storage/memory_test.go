@@ -0,0 +1,261 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/apiserver"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/tools"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
+)
+
+func newTestMemory() *Memory {
+	return NewMemory("service", api.Codec, api.ResourceVersioner)
+}
+
+func TestMemoryCreateGetDelete(t *testing.T) {
+	m := newTestMemory()
+	var svc api.Service
+	if err := m.Get("foo", &svc); !apiserver.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := m.Create("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo"}, Port: 80}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Create("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo"}}); !apiserver.IsAlreadyExists(err) {
+		t.Errorf("expected already exists error, got %v", err)
+	}
+	if err := m.Get("foo", &svc); err != nil || svc.Port != 80 || svc.ResourceVersion != 1 {
+		t.Errorf("unexpected service %#v (%v)", svc, err)
+	}
+	if err := m.Delete("foo", false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := m.Delete("foo", false); !apiserver.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestMemoryList(t *testing.T) {
+	m := newTestMemory()
+	m.Create("b", &api.Service{JSONBase: api.JSONBase{ID: "b"}})
+	m.Create("a", &api.Service{JSONBase: api.JSONBase{ID: "a"}})
+	var list []api.Service
+	var resourceVersion uint64
+	if err := m.List(&list, &resourceVersion); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resourceVersion != 2 {
+		t.Errorf("expected resourceVersion 2, got %v", resourceVersion)
+	}
+	if len(list) != 2 || list[0].ID != "a" || list[1].ID != "b" || list[0].ResourceVersion != 2 {
+		t.Errorf("unexpected list %#v", list)
+	}
+}
+
+func TestMemoryUpdate(t *testing.T) {
+	m := newTestMemory()
+	if err := m.Update("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo"}, Port: 80}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Update("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo"}}); !apiserver.IsConflict(err) {
+		t.Errorf("expected conflict creating over an existing object, got %v", err)
+	}
+	if err := m.Update("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo", ResourceVersion: 1}, Port: 81}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Update("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo", ResourceVersion: 1}, Port: 82}); !apiserver.IsConflict(err) {
+		t.Errorf("expected conflict for a stale resourceVersion, got %v", err)
+	}
+	if err := m.Update("bar", &api.Service{JSONBase: api.JSONBase{ID: "bar", ResourceVersion: 1}}); !apiserver.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	var svc api.Service
+	if err := m.Get("foo", &svc); err != nil || svc.Port != 81 || svc.ResourceVersion != 2 {
+		t.Errorf("unexpected service %#v (%v)", svc, err)
+	}
+}
+
+func TestMemoryAtomicUpdate(t *testing.T) {
+	m := newTestMemory()
+	increment := func(obj interface{}) (interface{}, error) {
+		svc := obj.(*api.Service)
+		svc.ID = "foo"
+		svc.Port++
+		return svc, nil
+	}
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() {
+			if err := m.AtomicUpdate("foo", &api.Service{}, increment); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+	var svc api.Service
+	if err := m.Get("foo", &svc); err != nil || svc.Port != 10 {
+		t.Errorf("unexpected service %#v (%v)", svc, err)
+	}
+}
+
+func expectEvent(t *testing.T, w watch.Interface, action watch.EventType, id string) {
+	select {
+	case event, ok := <-w.ResultChan():
+		if !ok {
+			t.Fatalf("watch closed, expected %v %v", action, id)
+		}
+		if svc := event.Object.(*api.Service); event.Type != action || svc.ID != id {
+			t.Errorf("expected %v %v, got %v %#v", action, id, event.Type, svc)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %v %v", action, id)
+	}
+}
+
+func TestMemoryWatch(t *testing.T) {
+	m := newTestMemory()
+	m.Create("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo"}})
+	m.Create("bar", &api.Service{JSONBase: api.JSONBase{ID: "bar"}})
+
+	w, err := m.Watch(0, func(obj interface{}) bool { return obj.(*api.Service).ID != "baz" })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectEvent(t, w, watch.Added, "bar")
+	expectEvent(t, w, watch.Added, "foo")
+	m.Create("baz", &api.Service{JSONBase: api.JSONBase{ID: "baz"}})
+	m.Delete("foo", false)
+	expectEvent(t, w, watch.Deleted, "foo")
+	w.Stop()
+	if _, ok := <-w.ResultChan(); ok {
+		t.Errorf("expected the watch to be closed")
+	}
+
+	// Resume from just after the first create.
+	w, err = m.Watch(2, tools.Everything)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectEvent(t, w, watch.Added, "bar")
+	expectEvent(t, w, watch.Added, "baz")
+	expectEvent(t, w, watch.Deleted, "foo")
+	w.Stop()
+}
+
+func TestMemoryHistoryIsCapped(t *testing.T) {
+	defer func(limit int) { memoryHistoryLimit = limit }(memoryHistoryLimit)
+	memoryHistoryLimit = 2
+	m := newTestMemory()
+	w, err := m.Watch(1, tools.Everything)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		m.Create(id, &api.Service{JSONBase: api.JSONBase{ID: id}})
+	}
+	if len(m.history) > 2*memoryHistoryLimit {
+		t.Errorf("expected the history to be capped, got %d changes", len(m.history))
+	}
+	// The watch fell behind the dropped changes, though it may have taken the
+	// first one before they were dropped.
+	received := 0
+	for ended := false; !ended; {
+		select {
+		case _, ok := <-w.ResultChan():
+			if ok {
+				received++
+			}
+			ended = !ok
+		case <-time.After(time.Second):
+			t.Fatalf("expected the watch to be ended")
+		}
+	}
+	if received > 1 {
+		t.Errorf("expected the dropped changes to be missed, got %d changes", received)
+	}
+
+	if _, err := m.Watch(1, tools.Everything); err == nil {
+		t.Errorf("expected watching dropped changes to fail")
+	}
+	w, err = m.Watch(4, tools.Everything)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectEvent(t, w, watch.Added, "d")
+	expectEvent(t, w, watch.Added, "e")
+	w.Stop()
+}
+
+func TestMemoryInjectConflicts(t *testing.T) {
+	m := newTestMemory()
+	m.Create("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo"}})
+	var svc api.Service
+	m.Get("foo", &svc)
+
+	m.InjectConflicts(1)
+	svc.Port = 80
+	if err := m.Update("foo", &svc); !apiserver.IsConflict(err) {
+		t.Errorf("expected conflict, got %v", err)
+	}
+	m.Get("foo", &svc)
+	svc.Port = 80
+	if err := m.Update("foo", &svc); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	m.InjectConflicts(2)
+	tries := 0
+	err := m.AtomicUpdate("foo", &api.Service{}, func(obj interface{}) (interface{}, error) {
+		tries++
+		svc := obj.(*api.Service)
+		svc.Port++
+		return svc, nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if tries != 3 {
+		t.Errorf("expected 3 tries, got %d", tries)
+	}
+	if err := m.Get("foo", &svc); err != nil || svc.Port != 81 {
+		t.Errorf("unexpected service %#v (%v)", svc, err)
+	}
+}
+
+func TestMemorySharedClock(t *testing.T) {
+	clock := NewMemoryClock()
+	a := NewMemoryWithClock("service", api.Codec, api.ResourceVersioner, clock)
+	b := NewMemoryWithClock("service", api.Codec, api.ResourceVersioner, clock)
+	a.Create("foo", &api.Service{JSONBase: api.JSONBase{ID: "foo"}})
+	b.Create("bar", &api.Service{JSONBase: api.JSONBase{ID: "bar"}})
+	var svc api.Service
+	if err := b.Get("bar", &svc); err != nil || svc.ResourceVersion != 2 {
+		t.Errorf("unexpected service %#v (%v)", svc, err)
+	}
+	var list []api.Service
+	var resourceVersion uint64
+	if err := a.List(&list, &resourceVersion); err != nil || resourceVersion != 2 {
+		t.Errorf("expected resourceVersion 2, got %d (%v)", resourceVersion, err)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// encryptedPrefix marks data written by an EncryptingCodec, so that it can
+// still read objects stored before encryption was turned on.
+var encryptedPrefix = []byte("k8s:enc:aesgcm:v1:")
+
+// envelope is the stored form of an encrypted object. Each object is sealed
+// with a key of its own, which is stored sealed with the master key.
+type envelope struct {
+	DataKey []byte `json:"dataKey"`
+	Data    []byte `json:"data"`
+}
+
+// EncryptingCodec is a Codec which encrypts what another Codec encodes,
+// using AES-GCM envelope encryption, so that sensitive objects aren't kept in
+// etcd as plain text. It is a KeyedCodec: the key an object is stored under is
+// authenticated along with it, so that an encrypted object can't be copied to
+// another key and read from there.
+type EncryptingCodec struct {
+	codec  Codec
+	master cipher.AEAD
+}
+
+// NewEncryptingCodec returns a Codec encrypting the output of codec with key,
+// which must be 16, 24 or 32 bytes long.
+func NewEncryptingCodec(codec Codec, key []byte) (*EncryptingCodec, error) {
+	master, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptingCodec{codec: codec, master: master}, nil
+}
+
+// LoadEncryptionKey reads a base64 encoded AES key from path.
+func LoadEncryptionKey(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s does not hold a base64 encoded key: %v", path, err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with aead, authenticating additionalData along with
+// it, and prepends the random nonce used.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts what seal returned.
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// Encode implements Codec. Objects it encodes are only decoded by Decode and
+// DecodeInto, or with the empty key.
+func (c *EncryptingCodec) Encode(obj interface{}) ([]byte, error) {
+	return c.EncodeForKey("", obj)
+}
+
+// EncodeForKey implements KeyedCodec.
+func (c *EncryptingCodec) EncodeForKey(key string, obj interface{}) ([]byte, error) {
+	plaintext, err := c.codec.Encode(obj)
+	if err != nil {
+		return nil, err
+	}
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	var e envelope
+	if e.Data, err = seal(aead, plaintext, []byte(key)); err != nil {
+		return nil, err
+	}
+	if e.DataKey, err = seal(c.master, dataKey, []byte(key)); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, encryptedPrefix...), data...), nil
+}
+
+// decrypt returns the plain text of data, stored under key, which is returned
+// as is if it wasn't encrypted.
+func (c *EncryptingCodec) decrypt(key string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedPrefix) {
+		return data, nil
+	}
+	var e envelope
+	if err := json.Unmarshal(data[len(encryptedPrefix):], &e); err != nil {
+		return nil, err
+	}
+	dataKey, err := open(c.master, e.DataKey, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt data key: %v", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, e.Data, []byte(key))
+}
+
+// Decode implements Codec.
+func (c *EncryptingCodec) Decode(data []byte) (interface{}, error) {
+	return c.DecodeForKey("", data)
+}
+
+// DecodeForKey implements KeyedCodec.
+func (c *EncryptingCodec) DecodeForKey(key string, data []byte) (interface{}, error) {
+	plaintext, err := c.decrypt(key, data)
+	if err != nil {
+		return nil, err
+	}
+	return c.codec.Decode(plaintext)
+}
+
+// DecodeInto implements Codec.
+func (c *EncryptingCodec) DecodeInto(data []byte, obj interface{}) error {
+	return c.DecodeIntoForKey("", data, obj)
+}
+
+// DecodeIntoForKey implements KeyedCodec.
+func (c *EncryptingCodec) DecodeIntoForKey(key string, data []byte, obj interface{}) error {
+	plaintext, err := c.decrypt(key, data)
+	if err != nil {
+		return err
+	}
+	return c.codec.DecodeInto(plaintext, obj)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+var testEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestEncryptingCodec(t *testing.T) {
+	codec, err := NewEncryptingCodec(api.Codec, testEncryptionKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod := &api.Pod{JSONBase: api.JSONBase{ID: "foo"}, Labels: map[string]string{"password": "hunter2"}}
+	data, err := codec.Encode(pod)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bytes.Contains(data, []byte("hunter2")) {
+		t.Errorf("expected the object to be encrypted: %s", data)
+	}
+
+	obj, err := codec.Decode(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(obj, pod) {
+		t.Errorf("expected %#v, got %#v", pod, obj)
+	}
+	var into api.Pod
+	if err := codec.DecodeInto(data, &into); err != nil || !reflect.DeepEqual(&into, pod) {
+		t.Errorf("expected %#v, got %#v (%v)", pod, into, err)
+	}
+
+	// Objects stored before encryption was turned on can still be read.
+	if obj, err := codec.Decode([]byte(api.EncodeOrDie(pod))); err != nil || !reflect.DeepEqual(obj, pod) {
+		t.Errorf("expected %#v, got %#v (%v)", pod, obj, err)
+	}
+
+	other, _ := NewEncryptingCodec(api.Codec, bytes.Repeat([]byte("x"), 32))
+	if _, err := other.Decode(data); err == nil {
+		t.Errorf("expected decrypting with the wrong key to fail")
+	}
+}
+
+func TestEncryptingCodecAuthenticatesKey(t *testing.T) {
+	codec, err := NewEncryptingCodec(api.Codec, testEncryptionKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod := &api.Pod{JSONBase: api.JSONBase{ID: "foo"}}
+	data, err := codec.EncodeForKey("/registry/pods/foo", pod)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if obj, err := codec.DecodeForKey("/registry/pods/foo", data); err != nil || !reflect.DeepEqual(obj, pod) {
+		t.Errorf("expected %#v, got %#v (%v)", pod, obj, err)
+	}
+	if _, err := codec.DecodeForKey("/registry/pods/bar", data); err == nil {
+		t.Errorf("expected decoding under another key to fail")
+	}
+	var into api.Pod
+	if err := codec.DecodeInto(data, &into); err == nil {
+		t.Errorf("expected decoding without the key to fail")
+	}
+}
+
+func TestLoadEncryptionKey(t *testing.T) {
+	f, err := ioutil.TempFile("", "key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(base64.StdEncoding.EncodeToString(testEncryptionKey) + "\n")
+	f.Close()
+
+	key, err := LoadEncryptionKey(f.Name())
+	if err != nil || !bytes.Equal(key, testEncryptionKey) {
+		t.Errorf("unexpected key %q (%v)", key, err)
+	}
+	if _, err := NewEncryptingCodec(api.Codec, key[:5]); err == nil {
+		t.Errorf("expected a short key to be rejected")
+	}
+}
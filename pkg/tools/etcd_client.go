@@ -0,0 +1,192 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/golang/glog"
+)
+
+// EtcdClientConfig holds the optional transport security settings used to talk to etcd.
+// The zero value talks plain, unauthenticated HTTP.
+type EtcdClientConfig struct {
+	// CertFile and KeyFile, which must be set together, are the client certificate
+	// presented to etcd.
+	CertFile string
+	KeyFile  string
+	// CAFile is used to verify the certificate served by etcd. If empty, the
+	// system roots are used.
+	CAFile string
+	// Username and the password read from PasswordFile, if set, are sent to etcd
+	// with HTTP basic auth.
+	Username     string
+	PasswordFile string
+}
+
+// usesBasicAuth returns true if any of the basic auth settings is present.
+func (c EtcdClientConfig) usesBasicAuth() bool {
+	return c.Username != "" || c.PasswordFile != ""
+}
+
+// basicAuth loads the credentials named by c.
+func (c EtcdClientConfig) basicAuth() (*basicAuth, error) {
+	auth := &basicAuth{username: c.Username}
+	if c.PasswordFile != "" {
+		data, err := ioutil.ReadFile(c.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read etcd password file: %v", err)
+		}
+		auth.password = strings.TrimRight(string(data), "\r\n")
+	}
+	return auth, nil
+}
+
+// usesTLS returns true if any of the certificate settings is present.
+func (c EtcdClientConfig) usesTLS() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.CAFile != ""
+}
+
+// tlsConfig loads the certificates named by c.
+func (c EtcdClientConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{}
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, errors.New("etcd client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load etcd client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if c.CAFile != "" {
+		data, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read etcd CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in etcd CA file %s", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// basicAuth adds an Authorization header to the requests of the transport it is
+// registered with. The go-etcd we vendor has no way to set credentials, and only
+// accepts an *http.Transport, which can't be wrapped, so basicAuth is registered
+// as the protocol handler for http and https: it sets the header, then hands the
+// request back to the transport. This keeps the credentials out of the server
+// URLs, which show up in errors and logs. It can go once go-etcd takes
+// credentials itself.
+type basicAuth struct {
+	username, password string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (b *basicAuth) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.SetBasicAuth(b.username, b.password)
+	return nil, http.ErrSkipAltProtocol
+}
+
+// registerWith makes transport send the credentials of b.
+func (b *basicAuth) registerWith(transport *http.Transport) {
+	transport.RegisterProtocol("http", b)
+	transport.RegisterProtocol("https", b)
+}
+
+// checkServer returns an error if server can't be used with c.
+func (c EtcdClientConfig) checkServer(server string) error {
+	if !c.usesBasicAuth() {
+		return nil
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("etcd server %q must be a URL to use basic auth", server)
+	}
+	return nil
+}
+
+// NewEtcdClientFactory returns an EtcdClientFactory producing go-etcd clients set up
+// according to config. Certificates are loaded up front, so a bad config is reported
+// here; server addresses should be checked with ValidateEtcdServers.
+//
+// Each client only knows about its own endpoint, so that connection failures are
+// reported back quickly instead of being retried against the whole cluster.
+func NewEtcdClientFactory(config EtcdClientConfig) (EtcdClientFactory, error) {
+	var transport *http.Transport
+	if config.usesTLS() || config.usesBasicAuth() {
+		dialer := &net.Dialer{Timeout: time.Second, KeepAlive: time.Second}
+		transport = &http.Transport{Dial: dialer.Dial}
+	}
+	if config.usesTLS() {
+		tlsConfig, err := config.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	if config.usesBasicAuth() {
+		auth, err := config.basicAuth()
+		if err != nil {
+			return nil, err
+		}
+		auth.registerWith(transport)
+	}
+	return func(server string) EtcdClient {
+		if config.usesTLS() || config.usesBasicAuth() {
+			if u, err := url.Parse(server); err == nil && u.Scheme != "https" {
+				glog.Warningf("etcd TLS or basic auth settings are configured but server %s is not https", server)
+			}
+		}
+		if err := config.checkServer(server); err != nil {
+			// Checked by ValidateEtcdServers.
+			glog.Errorf("Invalid etcd server %s: %v", server, err)
+		}
+		client := etcd.NewClient([]string{server})
+		client.CheckRetry = reportTransportError
+		if transport != nil {
+			client.SetTransport(transport)
+		}
+		return client
+	}, nil
+}
+
+// ValidateEtcdServers returns an error if any of servers can't be used with config.
+func ValidateEtcdServers(servers []string, config EtcdClientConfig) error {
+	for _, server := range servers {
+		if err := config.checkServer(server); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEtcdClientBasicAuth(t *testing.T) {
+	var user, password string
+	var ok bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, password, ok = req.BasicAuth()
+		w.Header().Set("X-Etcd-Index", "1")
+		fmt.Fprint(w, `{"action":"get","node":{"key":"/foo","value":"bar"}}`)
+	}))
+	defer server.Close()
+
+	file, err := ioutil.TempFile("", "etcd_password")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.Remove(file.Name())
+	fmt.Fprintln(file, "secret")
+	file.Close()
+
+	factory, err := NewEtcdClientFactory(EtcdClientConfig{Username: "kube", PasswordFile: file.Name()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := factory(server.URL).Get("/foo", false, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok || user != "kube" || password != "secret" {
+		t.Errorf("expected basic auth kube:secret, got %v %q:%q", ok, user, password)
+	}
+
+	// The credentials aren't part of the errors of unreachable servers.
+	server.Close()
+	_, err = factory(server.URL).Get("/foo", false, false)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected an error without the password, got %v", err)
+	}
+}
+
+func TestNewEtcdClientFactoryBadPasswordFile(t *testing.T) {
+	if _, err := NewEtcdClientFactory(EtcdClientConfig{Username: "kube", PasswordFile: "/does/not/exist"}); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestValidateEtcdServers(t *testing.T) {
+	config := EtcdClientConfig{Username: "kube"}
+	if err := ValidateEtcdServers([]string{"http://a:4001", "https://b:4001"}, config); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateEtcdServers([]string{"http://a:4001", "b:4001"}, config); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestNewEtcdClientFactoryBadTLS(t *testing.T) {
+	table := []EtcdClientConfig{
+		{CertFile: "/some/cert"},
+		{KeyFile: "/some/key"},
+		{CertFile: "/does/not/exist", KeyFile: "/does/not/exist"},
+		{CAFile: "/does/not/exist"},
+	}
+	for _, config := range table {
+		if _, err := NewEtcdClientFactory(config); err == nil {
+			t.Errorf("%#v: expected an error", config)
+		}
+	}
+}
+
+func TestNewEtcdClientFactoryPlain(t *testing.T) {
+	factory, err := NewEtcdClientFactory(EtcdClientConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if factory("http://127.0.0.1:4001") == nil {
+		t.Errorf("expected a client")
+	}
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/coreos/go-etcd/etcd"
+	"github.com/golang/glog"
+)
+
+// ErrNoEtcdEndpoints is returned when a FailoverEtcdClient has no endpoint left to try.
+var ErrNoEtcdEndpoints = errors.New("no etcd endpoints are reachable")
+
+// EtcdClientFactory creates a client talking to a single etcd endpoint.
+type EtcdClientFactory func(server string) EtcdClient
+
+// etcdEndpoint is one member of the set of servers a FailoverEtcdClient talks to.
+type etcdEndpoint struct {
+	server  string
+	client  EtcdClient
+	healthy bool
+}
+
+// FailoverEtcdClient is an EtcdClient which sends each request to a preferred
+// etcd endpoint, and moves on to the next healthy endpoint when the preferred
+// one can't be reached. Endpoints that fail are taken out of rotation until
+// CheckHealth sees them answer again.
+type FailoverEtcdClient struct {
+	// lock guards endpoints[*].healthy and current.
+	lock      sync.Mutex
+	endpoints []*etcdEndpoint
+	current   int
+}
+
+// NewFailoverEtcdClient returns a FailoverEtcdClient over servers, using newClient
+// to build the client for each individual endpoint. All endpoints start out healthy.
+func NewFailoverEtcdClient(servers []string, newClient EtcdClientFactory) *FailoverEtcdClient {
+	f := &FailoverEtcdClient{}
+	for _, server := range servers {
+		f.endpoints = append(f.endpoints, &etcdEndpoint{
+			server:  server,
+			client:  newClient(server),
+			healthy: true,
+		})
+	}
+	return f
+}
+
+// EtcdTransportError is returned by the clients of NewEtcdClientFactory when a
+// request got no usable answer from etcd. Err is what went wrong: a transport
+// error, or an unexpected HTTP status of StatusCode.
+type EtcdTransportError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *EtcdTransportError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("etcd answered with HTTP status %d: %v", e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("etcd request failed: %v", e.Err)
+}
+
+// reportTransportError is the go-etcd CheckRetry of the clients of
+// NewEtcdClientFactory. It gives up on the first failure, keeping its cause: each
+// client talks to a single endpoint, and whether to try another is up to
+// FailoverEtcdClient.
+func reportTransportError(cluster *etcd.Cluster, numReqs int, lastResp http.Response, err error) error {
+	return &EtcdTransportError{StatusCode: lastResp.StatusCode, Err: err}
+}
+
+// IsEtcdNotConnected returns true iff err shows that a request never reached the
+// etcd server, i.e. the connection to it couldn't be made. Only such requests can
+// safely be sent to another server if they change data.
+func IsEtcdNotConnected(err error) bool {
+	if transportErr, ok := err.(*EtcdTransportError); ok {
+		err = transportErr.Err
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
+
+// IsEtcdUnreachable returns true iff err indicates that the etcd server could not
+// be talked to at all, as opposed to the server answering with an error.
+func IsEtcdUnreachable(err error) bool {
+	if err == nil || IsEtcdWatchStoppedByUser(err) {
+		return false
+	}
+	if etcdError, ok := err.(*etcd.EtcdError); ok {
+		return etcdError != nil && etcdError.ErrorCode == etcd.ErrCodeEtcdNotReachable
+	}
+	if _, ok := err.(*EtcdTransportError); ok {
+		return true
+	}
+	// go-etcd hands back other transport errors (read, decode) untyped. They may
+	// have come after the server acted on the request, which is why writes are
+	// only repeated elsewhere for IsEtcdNotConnected errors.
+	return true
+}
+
+// Servers returns the endpoints known to the client, in order.
+func (f *FailoverEtcdClient) Servers() []string {
+	servers := make([]string, 0, len(f.endpoints))
+	for _, e := range f.endpoints {
+		servers = append(servers, e.server)
+	}
+	return servers
+}
+
+// Healthy returns the endpoints currently believed to be reachable.
+func (f *FailoverEtcdClient) Healthy() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	servers := []string{}
+	for _, e := range f.endpoints {
+		if e.healthy {
+			servers = append(servers, e.server)
+		}
+	}
+	return servers
+}
+
+// CheckHealth probes every endpoint and updates its health. An endpoint is healthy
+// if a read of the root key gets an answer, error or not, from the server. Meant to
+// be called periodically, e.g. via util.Forever.
+func (f *FailoverEtcdClient) CheckHealth() {
+	for _, e := range f.endpoints {
+		_, err := e.client.Get("/", false, false)
+		healthy := !IsEtcdUnreachable(err)
+		f.lock.Lock()
+		if e.healthy != healthy {
+			if healthy {
+				glog.Infof("etcd endpoint %s is reachable again", e.server)
+			} else {
+				glog.Errorf("etcd endpoint %s failed health check: %v", e.server, err)
+			}
+		}
+		e.healthy = healthy
+		f.lock.Unlock()
+	}
+}
+
+// candidates returns the endpoints to try for a request: the current one first,
+// followed by the other healthy ones in order. If nothing is believed healthy,
+// every endpoint is tried, since the health information may just be stale.
+func (f *FailoverEtcdClient) candidates() []*etcdEndpoint {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	n := len(f.endpoints)
+	healthy := []*etcdEndpoint{}
+	all := []*etcdEndpoint{}
+	for i := 0; i < n; i++ {
+		e := f.endpoints[(f.current+i)%n]
+		all = append(all, e)
+		if e.healthy {
+			healthy = append(healthy, e)
+		}
+	}
+	if len(healthy) == 0 {
+		return all
+	}
+	return healthy
+}
+
+// markFailed takes e out of rotation and, if it was the preferred endpoint,
+// moves the preference on to the next endpoint.
+func (f *FailoverEtcdClient) markFailed(e *etcdEndpoint, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if e.healthy {
+		glog.Errorf("etcd endpoint %s is unreachable, failing over: %v", e.server, err)
+	}
+	e.healthy = false
+	if f.endpoints[f.current] == e {
+		f.current = (f.current + 1) % len(f.endpoints)
+	}
+}
+
+// markSucceeded records that e answered, and makes it the preferred endpoint.
+func (f *FailoverEtcdClient) markSucceeded(e *etcdEndpoint) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	e.healthy = true
+	for i := range f.endpoints {
+		if f.endpoints[i] == e {
+			f.current = i
+			return
+		}
+	}
+}
+
+// do runs fn against each candidate endpoint until one of them answers. Reads
+// move on to the next endpoint whenever one is unreachable. A write which may
+// have reached the server, but whose answer got lost, could be applied twice if
+// it were sent again, so writes only move on while the connection can't even be
+// made; other errors are returned.
+func (f *FailoverEtcdClient) do(write bool, fn func(EtcdClient) (*etcd.Response, error)) (*etcd.Response, error) {
+	err := ErrNoEtcdEndpoints
+	for _, e := range f.candidates() {
+		var resp *etcd.Response
+		resp, err = fn(e.client)
+		if IsEtcdUnreachable(err) {
+			f.markFailed(e, err)
+			if write && !IsEtcdNotConnected(err) {
+				return nil, err
+			}
+			continue
+		}
+		f.markSucceeded(e)
+		return resp, err
+	}
+	return nil, err
+}
+
+// AddChild implements EtcdClient.
+func (f *FailoverEtcdClient) AddChild(key, data string, ttl uint64) (*etcd.Response, error) {
+	return f.do(true, func(c EtcdClient) (*etcd.Response, error) { return c.AddChild(key, data, ttl) })
+}
+
+// Get implements EtcdClient.
+func (f *FailoverEtcdClient) Get(key string, sort, recursive bool) (*etcd.Response, error) {
+	return f.do(false, func(c EtcdClient) (*etcd.Response, error) { return c.Get(key, sort, recursive) })
+}
+
+// Set implements EtcdClient.
+func (f *FailoverEtcdClient) Set(key, value string, ttl uint64) (*etcd.Response, error) {
+	return f.do(true, func(c EtcdClient) (*etcd.Response, error) { return c.Set(key, value, ttl) })
+}
+
+// Create implements EtcdClient.
+func (f *FailoverEtcdClient) Create(key, value string, ttl uint64) (*etcd.Response, error) {
+	return f.do(true, func(c EtcdClient) (*etcd.Response, error) { return c.Create(key, value, ttl) })
+}
+
+// CompareAndSwap implements EtcdClient.
+func (f *FailoverEtcdClient) CompareAndSwap(key, value string, ttl uint64, prevValue string, prevIndex uint64) (*etcd.Response, error) {
+	return f.do(true, func(c EtcdClient) (*etcd.Response, error) {
+		return c.CompareAndSwap(key, value, ttl, prevValue, prevIndex)
+	})
+}
+
+// Delete implements EtcdClient.
+func (f *FailoverEtcdClient) Delete(key string, recursive bool) (*etcd.Response, error) {
+	return f.do(true, func(c EtcdClient) (*etcd.Response, error) { return c.Delete(key, recursive) })
+}
+
+// Watch implements EtcdClient. A long-running watch (non-nil receiver) which loses
+// its endpoint is resumed on the next one from just after the last index it
+// delivered, so callers don't see the failover.
+func (f *FailoverEtcdClient) Watch(prefix string, waitIndex uint64, recursive bool, receiver chan *etcd.Response, stop chan bool) (*etcd.Response, error) {
+	if receiver == nil {
+		return f.do(false, func(c EtcdClient) (*etcd.Response, error) {
+			return c.Watch(prefix, waitIndex, recursive, nil, stop)
+		})
+	}
+	defer close(receiver)
+
+	for {
+		var lastErr error = ErrNoEtcdEndpoints
+		resumed := false
+		for _, e := range f.candidates() {
+			incoming := make(chan *etcd.Response)
+			done := make(chan error, 1)
+			go func(c EtcdClient) {
+				_, err := c.Watch(prefix, waitIndex, recursive, incoming, stop)
+				done <- err
+			}(e.client)
+
+			delivered := false
+			for resp := range incoming {
+				if !delivered {
+					f.markSucceeded(e)
+					delivered = true
+				}
+				if resp.Node != nil {
+					waitIndex = resp.Node.ModifiedIndex + 1
+				}
+				receiver <- resp
+			}
+			lastErr = <-done
+			if !IsEtcdUnreachable(lastErr) {
+				return nil, lastErr
+			}
+			f.markFailed(e, lastErr)
+			if delivered {
+				// Start over from the (new) preferred endpoint.
+				resumed = true
+				break
+			}
+		}
+		if !resumed {
+			return nil, lastErr
+		}
+	}
+}
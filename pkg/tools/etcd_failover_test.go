@@ -0,0 +1,237 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tools
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/go-etcd/etcd"
+)
+
+var errUnreachable = &etcd.EtcdError{ErrorCode: etcd.ErrCodeEtcdNotReachable}
+
+// errNotConnected is what the clients of NewEtcdClientFactory return when they
+// can't connect to etcd at all.
+var errNotConnected = &EtcdTransportError{Err: &url.Error{Op: "Put", URL: "http://a", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}}
+
+func newTestFailoverClient(t *testing.T, servers ...string) (*FailoverEtcdClient, map[string]*FakeEtcdClient) {
+	fakes := map[string]*FakeEtcdClient{}
+	client := NewFailoverEtcdClient(servers, func(server string) EtcdClient {
+		fake := NewFakeEtcdClient(t)
+		fakes[server] = fake
+		return fake
+	})
+	return client, fakes
+}
+
+func TestIsEtcdUnreachable(t *testing.T) {
+	table := []struct {
+		err         error
+		unreachable bool
+	}{
+		{nil, false},
+		{EtcdErrorNotFound, false},
+		{EtcdErrorTestFailed, false},
+		{etcd.ErrWatchStoppedByUser, false},
+		{errUnreachable, true},
+		{errors.New("dial tcp: connection refused"), true},
+		{errNotConnected, true},
+	}
+	for _, item := range table {
+		if e, a := item.unreachable, IsEtcdUnreachable(item.err); e != a {
+			t.Errorf("%v: expected %v, got %v", item.err, e, a)
+		}
+	}
+}
+
+func TestIsEtcdNotReachable(t *testing.T) {
+	table := []struct {
+		err          error
+		notReachable bool
+	}{
+		{nil, false},
+		{EtcdErrorNotFound, false},
+		{errors.New("dial tcp: connection refused"), false},
+		{errUnreachable, true},
+		{errNotConnected, true},
+	}
+	for _, item := range table {
+		if e, a := item.notReachable, IsEtcdNotReachable(item.err); e != a {
+			t.Errorf("%v: expected %v, got %v", item.err, e, a)
+		}
+	}
+}
+
+func TestIsEtcdNotConnected(t *testing.T) {
+	table := []struct {
+		err          error
+		notConnected bool
+	}{
+		{nil, false},
+		{EtcdErrorNotFound, false},
+		{errUnreachable, false},
+		{&EtcdTransportError{Err: &url.Error{Op: "Put", URL: "http://a", Err: &net.OpError{Op: "read", Err: errors.New("connection reset")}}}, false},
+		{&EtcdTransportError{StatusCode: 500, Err: errors.New("Unexpected HTTP status code")}, false},
+		{errNotConnected, true},
+	}
+	for _, item := range table {
+		if e, a := item.notConnected, IsEtcdNotConnected(item.err); e != a {
+			t.Errorf("%v: expected %v, got %v", item.err, e, a)
+		}
+	}
+}
+
+func TestFailoverEtcdClientFailsOver(t *testing.T) {
+	client, fakes := newTestFailoverClient(t, "a", "b")
+	fakes["a"].Err = errNotConnected
+
+	if _, err := client.Set("/foo", "bar", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := fakes["b"].Data["/foo"]; !ok {
+		t.Errorf("expected write to land on the second endpoint")
+	}
+	if e, a := []string{"b"}, client.Healthy(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected healthy %v, got %v", e, a)
+	}
+
+	// The failed endpoint stays out of rotation even once it recovers, until a health check.
+	fakes["a"].Err = nil
+	if _, err := client.Set("/baz", "bar", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := fakes["a"].Data["/baz"]; ok {
+		t.Errorf("unexpected write to the failed endpoint")
+	}
+}
+
+func TestFailoverEtcdClientDoesntRepeatWrites(t *testing.T) {
+	client, fakes := newTestFailoverClient(t, "a", "b")
+	// The write may have been applied, but its answer was lost.
+	fakes["a"].Err = errUnreachable
+
+	if _, err := client.AddChild("/foo", "bar", 0); err != errUnreachable {
+		t.Errorf("expected the error of the first endpoint, got %v", err)
+	}
+	if len(fakes["b"].Data) != 0 {
+		t.Errorf("unexpected write to the second endpoint: %#v", fakes["b"].Data)
+	}
+	if e, a := []string{"b"}, client.Healthy(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected healthy %v, got %v", e, a)
+	}
+
+	// Reads are safe to repeat.
+	fakes["a"].Data["/foo"] = EtcdResponseWithError{R: &etcd.Response{}, E: errUnreachable}
+	fakes["b"].Data["/foo"] = EtcdResponseWithError{R: &etcd.Response{Node: &etcd.Node{Value: "x"}}}
+	client.markSucceeded(client.endpoints[0])
+	if resp, err := client.Get("/foo", false, false); err != nil || resp.Node.Value != "x" {
+		t.Errorf("expected the read to fail over, got %#v, %v", resp, err)
+	}
+}
+
+func TestFailoverEtcdClientPassesServerErrors(t *testing.T) {
+	client, fakes := newTestFailoverClient(t, "a", "b")
+	fakes["a"].Data["/foo"] = EtcdResponseWithError{
+		R: &etcd.Response{Node: &etcd.Node{Value: "x"}},
+	}
+
+	_, err := client.Create("/foo", "bar", 0)
+	if !IsEtcdNodeExist(err) {
+		t.Errorf("expected node exists error, got %v", err)
+	}
+	if _, ok := fakes["b"].Data["/foo"]; ok {
+		t.Errorf("server errors should not cause a failover")
+	}
+}
+
+func TestFailoverEtcdClientAllUnreachable(t *testing.T) {
+	client, fakes := newTestFailoverClient(t, "a", "b")
+	fakes["a"].Err = errNotConnected
+	fakes["b"].Err = errNotConnected
+
+	if _, err := client.Set("/foo", "bar", 0); !IsEtcdUnreachable(err) {
+		t.Errorf("expected unreachable error, got %v", err)
+	}
+	if len(client.Healthy()) != 0 {
+		t.Errorf("expected no healthy endpoints, got %v", client.Healthy())
+	}
+
+	// With nothing known to be healthy, every endpoint is tried again.
+	fakes["b"].Err = nil
+	if _, err := client.Set("/foo", "bar", 0); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFailoverEtcdClientCheckHealth(t *testing.T) {
+	client, fakes := newTestFailoverClient(t, "a", "b")
+	fakes["a"].ExpectNotFoundGet("/")
+	fakes["b"].Data["/"] = EtcdResponseWithError{R: &etcd.Response{}, E: errUnreachable}
+
+	client.CheckHealth()
+	if e, a := []string{"a"}, client.Healthy(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected healthy %v, got %v", e, a)
+	}
+
+	fakes["b"].Data["/"] = EtcdResponseWithError{R: &etcd.Response{Node: &etcd.Node{Dir: true}}}
+	client.CheckHealth()
+	if e, a := []string{"a", "b"}, client.Healthy(); !reflect.DeepEqual(e, a) {
+		t.Errorf("expected healthy %v, got %v", e, a)
+	}
+}
+
+func TestFailoverEtcdClientWatchResumes(t *testing.T) {
+	client, fakes := newTestFailoverClient(t, "a", "b")
+	receiver := make(chan *etcd.Response)
+	stop := make(chan bool)
+	done := make(chan error)
+	go func() {
+		_, err := client.Watch("/foo", 5, true, receiver, stop)
+		done <- err
+	}()
+
+	fakes["a"].WaitForWatchCompletion()
+	if fakes["a"].WatchIndex != 5 {
+		t.Errorf("expected watch from 5, got %v", fakes["a"].WatchIndex)
+	}
+	fakes["a"].WatchResponse <- &etcd.Response{Node: &etcd.Node{ModifiedIndex: 7}}
+	if resp := <-receiver; resp.Node.ModifiedIndex != 7 {
+		t.Errorf("unexpected response: %#v", resp)
+	}
+	fakes["a"].WatchInjectError <- errUnreachable
+
+	fakes["b"].WaitForWatchCompletion()
+	if fakes["b"].WatchIndex != 8 {
+		t.Errorf("expected watch to resume from 8, got %v", fakes["b"].WatchIndex)
+	}
+	fakes["b"].WatchResponse <- &etcd.Response{Node: &etcd.Node{ModifiedIndex: 9}}
+	if resp := <-receiver; resp.Node.ModifiedIndex != 9 {
+		t.Errorf("unexpected response: %#v", resp)
+	}
+
+	fakes["b"].WatchStop <- true
+	if err := <-done; err != etcd.ErrWatchStoppedByUser {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, open := <-receiver; open {
+		t.Errorf("expected receiver to be closed")
+	}
+}
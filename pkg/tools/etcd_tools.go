@@ -49,6 +49,37 @@ type Codec interface {
 	DecodeInto(data []byte, obj interface{}) error
 }
 
+// KeyedCodec is a Codec whose encoding depends on the key an object is stored
+// under, e.g. because the key is authenticated along with the object. EtcdHelper
+// passes keys to the Codecs which implement it.
+type KeyedCodec interface {
+	Codec
+	EncodeForKey(key string, obj interface{}) ([]byte, error)
+	DecodeForKey(key string, data []byte) (interface{}, error)
+	DecodeIntoForKey(key string, data []byte, obj interface{}) error
+}
+
+func encodeForKey(codec Codec, key string, obj interface{}) ([]byte, error) {
+	if keyed, ok := codec.(KeyedCodec); ok {
+		return keyed.EncodeForKey(key, obj)
+	}
+	return codec.Encode(obj)
+}
+
+func decodeForKey(codec Codec, key string, data []byte) (interface{}, error) {
+	if keyed, ok := codec.(KeyedCodec); ok {
+		return keyed.DecodeForKey(key, data)
+	}
+	return codec.Decode(data)
+}
+
+func decodeIntoForKey(codec Codec, key string, data []byte, obj interface{}) error {
+	if keyed, ok := codec.(KeyedCodec); ok {
+		return keyed.DecodeIntoForKey(key, data, obj)
+	}
+	return codec.DecodeInto(data, obj)
+}
+
 // ResourceVersioner provides methods for managing object modification tracking
 type ResourceVersioner interface {
 	SetResourceVersion(obj interface{}, version uint64) error
@@ -101,6 +132,17 @@ func IsEtcdTestFailed(err error) bool {
 	return isEtcdErrorNum(err, EtcdErrorCodeTestFailed)
 }
 
+// IsEtcdNotReachable returns true iff err is the error go-etcd returns when none of
+// its servers answered, or the EtcdTransportError a FailoverEtcdClient returns when
+// none of its servers could be talked to. Unlike IsEtcdUnreachable, it is false for
+// untyped errors.
+func IsEtcdNotReachable(err error) bool {
+	if _, ok := err.(*EtcdTransportError); ok {
+		return true
+	}
+	return isEtcdErrorNum(err, etcd.ErrCodeEtcdNotReachable)
+}
+
 // IsEtcdWatchStoppedByUser returns true iff err is a client triggered stop.
 func IsEtcdWatchStoppedByUser(err error) bool {
 	return etcd.ErrWatchStoppedByUser == err
@@ -121,25 +163,32 @@ func etcdErrorIndex(err error) (uint64, bool) {
 	return 0, false
 }
 
-func (h *EtcdHelper) listEtcdNode(key string) ([]*etcd.Node, error) {
+// listEtcdNode returns the nodes beneath key, and the etcd index they were read at.
+func (h *EtcdHelper) listEtcdNode(key string) ([]*etcd.Node, uint64, error) {
 	result, err := h.Client.Get(key, false, true)
 	if err != nil {
 		nodes := make([]*etcd.Node, 0)
 		if IsEtcdNotFound(err) {
-			return nodes, nil
+			index, _ := etcdErrorIndex(err)
+			return nodes, index, nil
 		} else {
-			return nodes, err
+			return nodes, 0, err
 		}
 	}
-	return result.Node.Nodes, nil
+	return result.Node.Nodes, result.EtcdIndex, nil
 }
 
-// Extract a go object per etcd node into a slice.
-func (h *EtcdHelper) ExtractList(key string, slicePtr interface{}) error {
-	nodes, err := h.listEtcdNode(key)
+// Extract a go object per etcd node into a slice. If resourceVersion is not nil,
+// it is set to the etcd index the list was read at; a watch from just after that
+// index sees every change the list doesn't include.
+func (h *EtcdHelper) ExtractList(key string, slicePtr interface{}, resourceVersion *uint64) error {
+	nodes, index, err := h.listEtcdNode(key)
 	if err != nil {
 		return err
 	}
+	if resourceVersion != nil {
+		*resourceVersion = index
+	}
 	pv := reflect.ValueOf(slicePtr)
 	if pv.Type().Kind() != reflect.Ptr || pv.Type().Elem().Kind() != reflect.Slice {
 		// This should not happen at runtime.
@@ -148,7 +197,7 @@ func (h *EtcdHelper) ExtractList(key string, slicePtr interface{}) error {
 	v := pv.Elem()
 	for _, node := range nodes {
 		obj := reflect.New(v.Type().Elem())
-		err = h.Codec.DecodeInto([]byte(node.Value), obj.Interface())
+		err = decodeIntoForKey(h.Codec, node.Key, []byte(node.Value), obj.Interface())
 		if h.ResourceVersioner != nil {
 			_ = h.ResourceVersioner.SetResourceVersion(obj.Interface(), node.ModifiedIndex)
 			// being unable to set the version does not prevent the object from being extracted
@@ -186,7 +235,7 @@ func (h *EtcdHelper) bodyAndExtractObj(key string, objPtr interface{}, ignoreNot
 		return "", 0, fmt.Errorf("key '%v' found no nodes field: %#v", key, response)
 	}
 	body = response.Node.Value
-	err = h.Codec.DecodeInto([]byte(body), objPtr)
+	err = decodeIntoForKey(h.Codec, key, []byte(body), objPtr)
 	if h.ResourceVersioner != nil {
 		_ = h.ResourceVersioner.SetResourceVersion(objPtr, response.Node.ModifiedIndex)
 		// being unable to set the version does not prevent the object from being extracted
@@ -196,7 +245,13 @@ func (h *EtcdHelper) bodyAndExtractObj(key string, objPtr interface{}, ignoreNot
 
 // Create adds a new object at a key unless it already exists
 func (h *EtcdHelper) CreateObj(key string, obj interface{}) error {
-	data, err := h.Codec.Encode(obj)
+	return h.CreateObjWithTTL(key, obj, 0)
+}
+
+// CreateObjWithTTL is like CreateObj, but if ttl is nonzero, etcd removes the key
+// again after ttl seconds.
+func (h *EtcdHelper) CreateObjWithTTL(key string, obj interface{}, ttl uint64) error {
+	data, err := encodeForKey(h.Codec, key, obj)
 	if err != nil {
 		return err
 	}
@@ -206,7 +261,7 @@ func (h *EtcdHelper) CreateObj(key string, obj interface{}) error {
 		}
 	}
 
-	_, err = h.Client.Create(key, string(data), 0)
+	_, err = h.Client.Create(key, string(data), ttl)
 	return err
 }
 
@@ -219,7 +274,7 @@ func (h *EtcdHelper) Delete(key string, recursive bool) error {
 // SetObj marshals obj via json, and stores under key. Will do an
 // atomic update if obj's ResourceVersion field is set.
 func (h *EtcdHelper) SetObj(key string, obj interface{}) error {
-	data, err := h.Codec.Encode(obj)
+	data, err := encodeForKey(h.Codec, key, obj)
 	if err != nil {
 		return err
 	}
@@ -235,6 +290,17 @@ func (h *EtcdHelper) SetObj(key string, obj interface{}) error {
 	return err
 }
 
+// OverwriteObj marshals obj via json, and stores it under key, replacing whatever
+// was there before. If ttl is nonzero, etcd removes the key after ttl seconds.
+func (h *EtcdHelper) OverwriteObj(key string, obj interface{}, ttl uint64) error {
+	data, err := encodeForKey(h.Codec, key, obj)
+	if err != nil {
+		return err
+	}
+	_, err = h.Client.Set(key, string(data), ttl)
+	return err
+}
+
 // Pass an EtcdUpdateFunc to EtcdHelper.AtomicUpdate to make an atomic etcd update.
 // See the comment for AtomicUpdate for more detail.
 type EtcdUpdateFunc func(input interface{}) (output interface{}, err error)
@@ -276,7 +342,7 @@ func (h *EtcdHelper) AtomicUpdate(key string, ptrToType interface{}, tryUpdate E
 			return err
 		}
 
-		data, err := h.Codec.Encode(ret)
+		data, err := encodeForKey(h.Codec, key, ret)
 		if err != nil {
 			return err
 		}
@@ -290,7 +356,7 @@ func (h *EtcdHelper) AtomicUpdate(key string, ptrToType interface{}, tryUpdate E
 			return err
 		}
 
-		if string(data) == origBody {
+		if h.unchanged(key, origBody, data, ret) {
 			return nil
 		}
 
@@ -302,6 +368,30 @@ func (h *EtcdHelper) AtomicUpdate(key string, ptrToType interface{}, tryUpdate E
 	}
 }
 
+// unchanged returns true if data, the encoding of obj, holds the same object as
+// origBody, which is stored under key.
+func (h *EtcdHelper) unchanged(key, origBody string, data []byte, obj interface{}) bool {
+	if _, ok := h.Codec.(KeyedCodec); !ok {
+		return string(data) == origBody
+	}
+	// Keyed codecs, e.g. encrypting ones, may encode the same object differently
+	// every time, so compare the objects instead.
+	t := reflect.TypeOf(obj)
+	if t.Kind() != reflect.Ptr {
+		return false
+	}
+	orig := reflect.New(t.Elem()).Interface()
+	if err := decodeIntoForKey(h.Codec, key, []byte(origBody), orig); err != nil {
+		return false
+	}
+	if h.ResourceVersioner != nil {
+		if version, err := h.ResourceVersioner.ResourceVersion(obj); err == nil {
+			h.ResourceVersioner.SetResourceVersion(orig, version)
+		}
+	}
+	return reflect.DeepEqual(orig, obj)
+}
+
 // FilterFunc is a predicate which takes an API object and returns true
 // iff the object should remain in the set.
 type FilterFunc func(obj interface{}) bool
@@ -507,7 +597,7 @@ func (w *etcdWatcher) sendResult(res *etcd.Response) {
 		return
 	}
 
-	obj, err := w.encoding.Decode(data)
+	obj, err := decodeForKey(w.encoding, res.Node.Key, data)
 	if err != nil {
 		glog.Errorf("failure to decode api object: '%v' from %#v %#v", string(data), res, res.Node)
 		// TODO: expose an error through watch.Interface?
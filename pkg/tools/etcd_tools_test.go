@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -68,6 +69,7 @@ func TestExtractList(t *testing.T) {
 	fakeClient := NewFakeEtcdClient(t)
 	fakeClient.Data["/some/key"] = EtcdResponseWithError{
 		R: &etcd.Response{
+			EtcdIndex: 10,
 			Node: &etcd.Node{
 				Nodes: []*etcd.Node{
 					{
@@ -93,11 +95,15 @@ func TestExtractList(t *testing.T) {
 	}
 
 	var got []api.Pod
+	var resourceVersion uint64
 	helper := EtcdHelper{fakeClient, codec, versioner}
-	err := helper.ExtractList("/some/key", &got)
+	err := helper.ExtractList("/some/key", &got, &resourceVersion)
 	if err != nil {
 		t.Errorf("Unexpected error %#v", err)
 	}
+	if resourceVersion != 10 {
+		t.Errorf("Expected resourceVersion 10, got %v", resourceVersion)
+	}
 
 	for i := 0; i < len(expect); i++ {
 		if !reflect.DeepEqual(got[i], expect[i]) {
@@ -106,6 +112,23 @@ func TestExtractList(t *testing.T) {
 	}
 }
 
+func TestExtractListNotFound(t *testing.T) {
+	fakeClient := NewFakeEtcdClient(t)
+	fakeClient.Data["/some/key"] = EtcdResponseWithError{
+		R: &etcd.Response{},
+		E: &etcd.EtcdError{ErrorCode: EtcdErrorCodeNotFound, Index: 7},
+	}
+	got := []api.Pod{}
+	var resourceVersion uint64
+	helper := EtcdHelper{fakeClient, codec, versioner}
+	if err := helper.ExtractList("/some/key", &got, &resourceVersion); err != nil {
+		t.Errorf("Unexpected error %#v", err)
+	}
+	if len(got) != 0 || resourceVersion != 7 {
+		t.Errorf("Unexpected list %#v at %v", got, resourceVersion)
+	}
+}
+
 func TestExtractObj(t *testing.T) {
 	fakeClient := NewFakeEtcdClient(t)
 	expect := api.Pod{JSONBase: api.JSONBase{ID: "foo"}}
@@ -209,6 +232,27 @@ func TestSetObjWithVersion(t *testing.T) {
 	}
 }
 
+func TestOverwriteObj(t *testing.T) {
+	obj := api.Pod{JSONBase: api.JSONBase{ID: "foo"}}
+	fakeClient := NewFakeEtcdClient(t)
+	fakeClient.Data["/some/key"] = EtcdResponseWithError{
+		R: &etcd.Response{
+			Node: &etcd.Node{
+				Value: api.EncodeOrDie(api.Pod{JSONBase: api.JSONBase{ID: "bar"}}),
+			},
+		},
+	}
+	helper := EtcdHelper{fakeClient, codec, versioner}
+	if err := helper.OverwriteObj("/some/key", obj, 5); err != nil {
+		t.Fatalf("Unexpected error %#v", err)
+	}
+	expect := api.EncodeOrDie(obj)
+	got := fakeClient.Data["/some/key"].R.Node.Value
+	if expect != got {
+		t.Errorf("Wanted %v, got %v", expect, got)
+	}
+}
+
 func TestSetObjWithoutResourceVersioner(t *testing.T) {
 	obj := api.Pod{JSONBase: api.JSONBase{ID: "foo"}}
 	fakeClient := NewFakeEtcdClient(t)
@@ -314,6 +358,49 @@ func TestAtomicUpdateNoChange(t *testing.T) {
 	}
 }
 
+func TestEtcdHelperEncryptingCodec(t *testing.T) {
+	fakeClient := NewFakeEtcdClient(t)
+	fakeClient.TestIndex = true
+	codec, err := NewEncryptingCodec(scheme, testEncryptionKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	helper := EtcdHelper{fakeClient, codec, api.NewJSONBaseResourceVersioner()}
+
+	fakeClient.ExpectNotFoundGet("/some/key")
+	obj := &TestResource{JSONBase: api.JSONBase{ID: "foo"}, Value: 42}
+	err = helper.AtomicUpdate("/some/key", &TestResource{}, func(in interface{}) (interface{}, error) {
+		return obj, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stored := fakeClient.Data["/some/key"].R.Node.Value
+	if strings.Contains(stored, "42") {
+		t.Errorf("expected the stored object to be encrypted: %s", stored)
+	}
+
+	var got TestResource
+	if err := helper.ExtractObj("/some/key", &got, false); err != nil || got.Value != 42 {
+		t.Errorf("unexpected object %#v (%v)", got, err)
+	}
+
+	// Encrypted objects can't be read from another key.
+	fakeClient.Set("/other/key", stored, 0)
+	if err := helper.ExtractObj("/other/key", &got, false); err == nil {
+		t.Errorf("expected an object copied to another key not to decode")
+	}
+
+	// Updates which don't change the object aren't written.
+	fakeClient.Err = errors.New("should not be called")
+	err = helper.AtomicUpdate("/some/key", &TestResource{}, func(in interface{}) (interface{}, error) {
+		return &TestResource{JSONBase: api.JSONBase{ID: "foo"}, Value: 42}, nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestAtomicUpdate_CreateCollision(t *testing.T) {
 	fakeClient := NewFakeEtcdClient(t)
 	fakeClient.TestIndex = true
@@ -0,0 +1,139 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ui
+
+// assets holds the dashboard files, keyed by their path beneath the UI prefix.
+// The dashboard talks to the v1beta1 API, which NewHandler tells it where to find
+// by replacing apiPrefixToken.
+var assets = map[string]string{
+	"index.html":    indexHTML,
+	"dashboard.js":  dashboardJS,
+	"dashboard.css": dashboardCSS,
+}
+
+// apiPrefixToken stands for the quoted API prefix, ending in a slash, in the assets.
+const apiPrefixToken = "API_PREFIX"
+
+const indexHTML = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Kubernetes</title>
+  <link rel="stylesheet" href="dashboard.css">
+</head>
+<body>
+  <h1>Kubernetes</h1>
+  <p id="error"></p>
+  <h2>Pods</h2>
+  <table id="pods"></table>
+  <h2>Services</h2>
+  <table id="services"></table>
+  <h2>Minions</h2>
+  <table id="minions"></table>
+  <script src="dashboard.js"></script>
+</body>
+</html>
+`
+
+const dashboardJS = `(function() {
+  var API = API_PREFIX;
+
+  function text(value) {
+    if (value === undefined || value === null) {
+      return "";
+    }
+    if (typeof value === "object") {
+      var parts = [];
+      for (var key in value) {
+        parts.push(key + "=" + value[key]);
+      }
+      return parts.join(", ");
+    }
+    return String(value);
+  }
+
+  function render(id, columns, items) {
+    var table = document.getElementById(id);
+    var rows = ["<tr>"];
+    for (var i = 0; i < columns.length; i++) {
+      rows.push("<th>" + columns[i][0] + "</th>");
+    }
+    rows.push("</tr>");
+    for (var j = 0; j < items.length; j++) {
+      rows.push("<tr>");
+      for (var k = 0; k < columns.length; k++) {
+        var cell = document.createElement("td");
+        cell.textContent = text(columns[k][1](items[j]));
+        rows.push(cell.outerHTML);
+      }
+      rows.push("</tr>");
+    }
+    table.innerHTML = rows.join("");
+  }
+
+  function load(resource, columns) {
+    var req = new XMLHttpRequest();
+    req.open("GET", API + resource);
+    req.onload = function() {
+      if (req.status != 200) {
+        document.getElementById("error").textContent = resource + ": " + req.status + " " + req.statusText;
+        return;
+      }
+      render(resource, columns, JSON.parse(req.responseText).items || []);
+    };
+    req.send();
+  }
+
+  function refresh() {
+    load("pods", [
+      ["Name", function(p) { return p.id; }],
+      ["Host", function(p) { return p.currentState.host; }],
+      ["Status", function(p) { return p.currentState.status; }],
+      ["Labels", function(p) { return p.labels; }]
+    ]);
+    load("services", [
+      ["Name", function(s) { return s.id; }],
+      ["Port", function(s) { return s.port; }],
+      ["Selector", function(s) { return s.selector; }]
+    ]);
+    load("minions", [
+      ["Name", function(m) { return m.id; }],
+      ["Condition", function(m) { return m.status && m.status.condition; }]
+    ]);
+  }
+
+  refresh();
+  setInterval(refresh, 10000);
+})();
+`
+
+const dashboardCSS = `body {
+  font-family: sans-serif;
+  margin: 2em;
+}
+table {
+  border-collapse: collapse;
+}
+th, td {
+  border: 1px solid #ccc;
+  padding: 0.3em 0.8em;
+  text-align: left;
+}
+#error {
+  color: #c00;
+}
+`
@@ -0,0 +1,78 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ui serves a basic cluster dashboard which is compiled into the
+// binary, so it ships with the master without any files to install.
+package ui
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Handler serves the dashboard assets. Requests for a directory get its index.html.
+type Handler struct {
+	assets map[string]string
+}
+
+// NewHandler returns a Handler serving the built-in dashboard, which reads the
+// v1beta1 API beneath apiPrefix, e.g. "/api/v1beta1".
+func NewHandler(apiPrefix string) *Handler {
+	quoted := strconv.Quote(strings.TrimRight(apiPrefix, "/") + "/")
+	h := &Handler{map[string]string{}}
+	for name, data := range assets {
+		h.assets[name] = strings.Replace(data, apiPrefixToken, quoted, -1)
+	}
+	return h
+}
+
+// InstallHandler registers the dashboard on mux beneath prefix, e.g. "/ui/",
+// reading the v1beta1 API beneath apiPrefix.
+func InstallHandler(mux *http.ServeMux, prefix, apiPrefix string) {
+	prefix = strings.TrimRight(prefix, "/")
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, NewHandler(apiPrefix)))
+	mux.Handle(prefix, http.RedirectHandler(prefix+"/", http.StatusMovedPermanently))
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "only GET and HEAD are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := path.Clean("/" + req.URL.Path)
+	if strings.HasSuffix(req.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	data, ok := h.assets[strings.TrimPrefix(name, "/")]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if req.Method == "GET" {
+		w.Write([]byte(data))
+	}
+}
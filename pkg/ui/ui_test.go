@@ -0,0 +1,68 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInstallHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	InstallHandler(mux, "/ui/", "/custom/api/")
+	js := strings.Replace(dashboardJS, apiPrefixToken, `"/custom/api/"`, 1)
+	if !strings.Contains(js, `var API = "/custom/api/";`) {
+		t.Fatalf("API prefix was not injected: %s", js)
+	}
+
+	table := []struct {
+		method      string
+		path        string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"GET", "/ui/", http.StatusOK, "text/html", indexHTML},
+		{"GET", "/ui/dashboard.js", http.StatusOK, "javascript", js},
+		{"GET", "/ui/dashboard.css", http.StatusOK, "text/css", dashboardCSS},
+		{"HEAD", "/ui/dashboard.css", http.StatusOK, "text/css", ""},
+		{"GET", "/ui", http.StatusMovedPermanently, "", ""},
+		{"GET", "/ui/missing.html", http.StatusNotFound, "", ""},
+		{"GET", "/ui/index.html", http.StatusOK, "text/html", indexHTML},
+		{"POST", "/ui/", http.StatusMethodNotAllowed, "", ""},
+	}
+	for _, item := range table {
+		req, _ := http.NewRequest(item.method, item.path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != item.code {
+			t.Errorf("%s %s: expected %d, got %d", item.method, item.path, item.code, w.Code)
+			continue
+		}
+		if item.code != http.StatusOK {
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.Contains(ct, item.contentType) {
+			t.Errorf("%s %s: unexpected content type %q", item.method, item.path, ct)
+		}
+		if w.Body.String() != item.body {
+			t.Errorf("%s %s: unexpected body %q", item.method, item.path, w.Body.String())
+		}
+	}
+}
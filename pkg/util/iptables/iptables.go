@@ -0,0 +1,142 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// Interface is an injectable interface for running iptables commands.
+type Interface interface {
+	// EnsureChain creates chain in table unless it exists, and returns true if it existed.
+	EnsureChain(table Table, chain Chain) (bool, error)
+	// FlushChain deletes every rule of chain in table.
+	FlushChain(table Table, chain Chain) error
+	// EnsureRule appends the rule args to chain in table unless it is there,
+	// and returns true if it was.
+	EnsureRule(table Table, chain Chain, args ...string) (bool, error)
+	// DeleteRule deletes the rule args from chain in table, if it is there.
+	DeleteRule(table Table, chain Chain, args ...string) error
+}
+
+// Table is an iptables table.
+type Table string
+
+// TableNAT is the table of the rules which rewrite addresses.
+const TableNAT Table = "nat"
+
+// Chain is an iptables chain.
+type Chain string
+
+// These are built-in chains of TableNAT.
+const (
+	// ChainPrerouting sees the packets which arrive at the host.
+	ChainPrerouting Chain = "PREROUTING"
+	// ChainOutput sees the packets which processes of the host send.
+	ChainOutput Chain = "OUTPUT"
+)
+
+// runner runs iptables commands, one at a time.
+type runner struct {
+	mu sync.Mutex
+	// Defaults to running the iptables command.
+	exec func(args ...string) ([]byte, error)
+}
+
+// New returns an Interface which runs the iptables command of the host.
+func New() Interface {
+	return &runner{exec: runIPTables}
+}
+
+// exitError is the exit status of a failed iptables command.
+type exitError int
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+func runIPTables(args ...string) ([]byte, error) {
+	output, err := exec.Command("iptables", args...).CombinedOutput()
+	if ee, ok := err.(*exec.ExitError); ok {
+		if status, ok := ee.Sys().(syscall.WaitStatus); ok {
+			return output, exitError(status.ExitStatus())
+		}
+	}
+	return output, err
+}
+
+func (r *runner) run(op string, table Table, chain Chain, args ...string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.exec(append([]string{"-t", string(table), op, string(chain)}, args...)...)
+}
+
+func (r *runner) EnsureChain(table Table, chain Chain) (bool, error) {
+	output, err := r.run("-N", table, chain)
+	if err == nil {
+		return false, nil
+	}
+	// Creating a chain which exists fails with status 1.
+	if err == exitError(1) {
+		return true, nil
+	}
+	return false, fmt.Errorf("error creating chain %q: %v: %s", chain, err, output)
+}
+
+func (r *runner) FlushChain(table Table, chain Chain) error {
+	if output, err := r.run("-F", table, chain); err != nil {
+		return fmt.Errorf("error flushing chain %q: %v: %s", chain, err, output)
+	}
+	return nil
+}
+
+func (r *runner) checkRule(table Table, chain Chain, args ...string) (bool, error) {
+	output, err := r.run("-C", table, chain, args...)
+	if err == nil {
+		return true, nil
+	}
+	// A missing rule fails the check with status 1.
+	if err == exitError(1) {
+		return false, nil
+	}
+	return false, fmt.Errorf("error checking rule: %v: %s", err, output)
+}
+
+func (r *runner) EnsureRule(table Table, chain Chain, args ...string) (bool, error) {
+	exists, err := r.checkRule(table, chain, args...)
+	if err != nil || exists {
+		return exists, err
+	}
+	if output, err := r.run("-A", table, chain, args...); err != nil {
+		return false, fmt.Errorf("error appending rule: %v: %s", err, output)
+	}
+	return false, nil
+}
+
+func (r *runner) DeleteRule(table Table, chain Chain, args ...string) error {
+	exists, err := r.checkRule(table, chain, args...)
+	if err != nil || !exists {
+		return err
+	}
+	if output, err := r.run("-D", table, chain, args...); err != nil {
+		return fmt.Errorf("error deleting rule: %v: %s", err, output)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iptables
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeExec returns the scripted results of the commands it runs, in order.
+type fakeExec struct {
+	results []error
+	calls   []string
+}
+
+func (f *fakeExec) exec(args ...string) ([]byte, error) {
+	f.calls = append(f.calls, strings.Join(args, " "))
+	if len(f.results) == 0 {
+		return nil, nil
+	}
+	err := f.results[0]
+	f.results = f.results[1:]
+	return nil, err
+}
+
+func TestEnsureChain(t *testing.T) {
+	table := []struct {
+		result  error
+		existed bool
+		failed  bool
+	}{
+		{result: nil},
+		{result: exitError(1), existed: true},
+		{result: exitError(2), failed: true},
+		{result: errors.New("not found"), failed: true},
+	}
+	for i, item := range table {
+		fake := &fakeExec{results: []error{item.result}}
+		existed, err := (&runner{exec: fake.exec}).EnsureChain(TableNAT, "FOO")
+		if existed != item.existed || (err != nil) != item.failed {
+			t.Errorf("%d: expected %v, %v, got %v, %v", i, item.existed, item.failed, existed, err)
+		}
+		if expected := []string{"-t nat -N FOO"}; !reflect.DeepEqual(fake.calls, expected) {
+			t.Errorf("%d: expected %v, got %v", i, expected, fake.calls)
+		}
+	}
+}
+
+func TestEnsureRule(t *testing.T) {
+	fake := &fakeExec{results: []error{exitError(1), nil}}
+	r := &runner{exec: fake.exec}
+	existed, err := r.EnsureRule(TableNAT, ChainOutput, "-j", "FOO")
+	if existed || err != nil {
+		t.Errorf("expected a new rule, got %v, %v", existed, err)
+	}
+	expected := []string{"-t nat -C OUTPUT -j FOO", "-t nat -A OUTPUT -j FOO"}
+	if !reflect.DeepEqual(fake.calls, expected) {
+		t.Errorf("expected %v, got %v", expected, fake.calls)
+	}
+
+	fake = &fakeExec{}
+	r = &runner{exec: fake.exec}
+	existed, err = r.EnsureRule(TableNAT, ChainOutput, "-j", "FOO")
+	if !existed || err != nil {
+		t.Errorf("expected an existing rule, got %v, %v", existed, err)
+	}
+	if expected := []string{"-t nat -C OUTPUT -j FOO"}; !reflect.DeepEqual(fake.calls, expected) {
+		t.Errorf("expected %v, got %v", expected, fake.calls)
+	}
+}
+
+func TestDeleteRule(t *testing.T) {
+	fake := &fakeExec{}
+	r := &runner{exec: fake.exec}
+	if err := r.DeleteRule(TableNAT, ChainOutput, "-j", "FOO"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	expected := []string{"-t nat -C OUTPUT -j FOO", "-t nat -D OUTPUT -j FOO"}
+	if !reflect.DeepEqual(fake.calls, expected) {
+		t.Errorf("expected %v, got %v", expected, fake.calls)
+	}
+
+	// Deleting a missing rule does nothing.
+	fake = &fakeExec{results: []error{exitError(1)}}
+	r = &runner{exec: fake.exec}
+	if err := r.DeleteRule(TableNAT, ChainOutput, "-j", "FOO"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if expected := []string{"-t nat -C OUTPUT -j FOO"}; !reflect.DeepEqual(fake.calls, expected) {
+		t.Errorf("expected %v, got %v", expected, fake.calls)
+	}
+}
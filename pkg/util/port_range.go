@@ -0,0 +1,78 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PortRange is a range of ports, written as "base-last", e.g. "30000-32767",
+// or as a single port. The zero PortRange is empty. It can be used as a flag.
+type PortRange struct {
+	Base int
+	Size int
+}
+
+// Contains returns true if port is in the range.
+func (pr *PortRange) Contains(port int) bool {
+	return pr.Base <= port && port < pr.Base+pr.Size
+}
+
+// String returns the range as "base-last", the port of a range of one, or ""
+// if it is empty.
+func (pr *PortRange) String() string {
+	switch pr.Size {
+	case 0:
+		return ""
+	case 1:
+		return strconv.Itoa(pr.Base)
+	}
+	return fmt.Sprintf("%d-%d", pr.Base, pr.Base+pr.Size-1)
+}
+
+// Set parses value into the range. An empty value empties the range.
+func (pr *PortRange) Set(value string) error {
+	if value == "" {
+		*pr = PortRange{}
+		return nil
+	}
+	bounds := strings.SplitN(value, "-", 2)
+	base, err := strconv.Atoi(bounds[0])
+	if err != nil || !IsValidPortNum(base) {
+		return fmt.Errorf("invalid port range %q: bad base port", value)
+	}
+	last := base
+	if len(bounds) == 2 {
+		last, err = strconv.Atoi(bounds[1])
+		if err != nil || !IsValidPortNum(last) || last < base {
+			return fmt.Errorf("invalid port range %q: bad last port", value)
+		}
+	}
+	*pr = PortRange{Base: base, Size: last - base + 1}
+	return nil
+}
+
+// ParsePortRange parses value, e.g. "30000-32767", into a PortRange.
+func ParsePortRange(value string) (*PortRange, error) {
+	pr := &PortRange{}
+	if err := pr.Set(value); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+)
+
+func TestPortRangeSet(t *testing.T) {
+	testCases := map[string]PortRange{
+		"":            {},
+		"80":          {Base: 80, Size: 1},
+		"30000-32767": {Base: 30000, Size: 2768},
+	}
+	for value, expected := range testCases {
+		pr, err := ParsePortRange(value)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", value, err)
+			continue
+		}
+		if *pr != expected {
+			t.Errorf("%q: expected %#v, got %#v", value, expected, *pr)
+		}
+		if pr.String() != value {
+			t.Errorf("%q: unexpected string %q", value, pr.String())
+		}
+	}
+}
+
+func TestPortRangeSetErr(t *testing.T) {
+	for _, value := range []string{"foo", "0", "80-", "-80", "90-80", "80-70000", "80-90-100"} {
+		if _, err := ParsePortRange(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
+
+func TestPortRangeContains(t *testing.T) {
+	pr := PortRange{Base: 30000, Size: 10}
+	for port, expected := range map[int]bool{29999: false, 30000: true, 30009: true, 30010: false} {
+		if pr.Contains(port) != expected {
+			t.Errorf("%d: expected %v", port, expected)
+		}
+	}
+	empty := PortRange{}
+	if empty.Contains(0) {
+		t.Errorf("expected the empty range to contain no port")
+	}
+}
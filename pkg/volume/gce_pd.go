@@ -0,0 +1,205 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+)
+
+// partitionSuffix matches the suffix globalPDPath adds for partitions.
+var partitionSuffix = regexp.MustCompile(`-part[0-9]+$`)
+
+// pdManager attaches persistent disks to the host and detaches them again.
+type pdManager interface {
+	// AttachDisk attaches the disk to the host and mounts it at its global
+	// path, formatting it first if it has no filesystem.
+	AttachDisk(pd *GCEPersistentDisk) error
+	// DetachDisk unmounts the disk from its global path and detaches it
+	// from the host.
+	DetachDisk(pd *GCEPersistentDisk, globalPDPath string) error
+}
+
+// GCEPersistentDisk volumes are disk resources provided by Google Compute Engine
+// that are attached to the kubelet's host machine and exposed to the pod.
+//
+// A disk is attached and mounted once per host under
+// (ROOT_DIR)/plugins/gce-pd/mounts/(PD_NAME) and bind mounted into
+// each pod that uses it, so pods sharing a read-only disk share one attachment.
+type GCEPersistentDisk struct {
+	Name    string
+	PodID   string
+	RootDir string
+	// Unique identifier of the PD, used to find the disk resource in the provider.
+	PDName string
+	// Filesystem type, used to format the disk if it is unformatted.
+	FSType string
+	// Specifies the partition to mount
+	Partition string
+	// Specifies whether the disk will be attached as ReadOnly.
+	ReadOnly bool
+	// Utility interface that provides API calls to the provider to attach/detach disks.
+	util pdManager
+	// Mounter interface that provides system calls to mount the disks.
+	mounter mounter
+}
+
+func (pd *GCEPersistentDisk) GetPath() string {
+	return podVolumePath(pd.RootDir, pd.PodID, "gce-pd", pd.Name)
+}
+
+// globalPDPath returns the path the disk is mounted at for all pods on the host.
+func (pd *GCEPersistentDisk) globalPDPath() string {
+	name := pd.PDName
+	if pd.Partition != "" {
+		name = name + "-part" + pd.Partition
+	}
+	return makeGlobalPDPath(pd.RootDir, name)
+}
+
+func makeGlobalPDPath(rootDir, devName string) string {
+	return path.Join(rootDir, "plugins", "gce-pd", "mounts", devName)
+}
+
+// SetUp attaches the disk and bind mounts it to the volume path.
+func (pd *GCEPersistentDisk) SetUp() error {
+	volPath := pd.GetPath()
+	mounted, err := isMountPoint(pd.mounter, volPath)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return nil
+	}
+	if err := pd.util.AttachDisk(pd); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(volPath, 0750); err != nil {
+		return err
+	}
+	// Perform a bind mount to the full path to allow duplicate mounts of the same PD.
+	globalPDPath := pd.globalPDPath()
+	if err := pd.mounter.Mount(globalPDPath, volPath, "", flagBind, ""); err != nil {
+		os.RemoveAll(volPath)
+		return err
+	}
+	if pd.ReadOnly {
+		// Linux ignores MS_RDONLY when it creates a bind mount, so the bind
+		// mount is made read-only by remounting it.
+		if err := pd.mounter.Mount(globalPDPath, volPath, "", flagBind|flagRemount|flagReadOnly, ""); err != nil {
+			pd.mounter.Unmount(volPath, 0)
+			os.RemoveAll(volPath)
+			return err
+		}
+	}
+	return nil
+}
+
+// TearDown unmounts the bind mount, and detaches the disk only if the PD
+// resource was the last reference to that disk on the kubelet. A partition
+// no pod uses any more is unmounted from its global path, but the disk stays
+// attached while another of its partitions is mounted.
+func (pd *GCEPersistentDisk) TearDown() error {
+	volPath := pd.GetPath()
+	mounted, err := isMountPoint(pd.mounter, volPath)
+	if err != nil {
+		return err
+	}
+	if !mounted {
+		return os.RemoveAll(volPath)
+	}
+	refs, err := getMountRefs(pd.mounter, volPath)
+	if err != nil {
+		return err
+	}
+	if err := pd.mounter.Unmount(volPath, 0); err != nil {
+		return err
+	}
+	// The global mount is one of the references; it is the only one left
+	// when no other pod uses the disk.
+	globalPrefix := makeGlobalPDPath(pd.RootDir, "")
+	var globalPDPath string
+	others := 0
+	for _, ref := range refs {
+		if path.Dir(ref) == globalPrefix {
+			globalPDPath = ref
+		} else {
+			others++
+		}
+	}
+	if globalPDPath != "" && others == 0 {
+		pd.PDName = partitionSuffix.ReplaceAllString(path.Base(globalPDPath), "")
+		inUse, err := pd.otherPartitionsMounted(globalPDPath)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			if err := pd.mounter.Unmount(globalPDPath, 0); err != nil {
+				return err
+			}
+			if err := os.RemoveAll(globalPDPath); err != nil {
+				return err
+			}
+		} else if err := pd.util.DetachDisk(pd, globalPDPath); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(volPath)
+}
+
+// otherPartitionsMounted reports whether the disk is mounted at a global path
+// other than globalPDPath, i.e. through another partition.
+func (pd *GCEPersistentDisk) otherPartitionsMounted(globalPDPath string) (bool, error) {
+	mps, err := pd.mounter.List()
+	if err != nil {
+		return false, err
+	}
+	globalPrefix := makeGlobalPDPath(pd.RootDir, "")
+	for _, mp := range mps {
+		if mp.Path == globalPDPath || path.Dir(mp.Path) != globalPrefix {
+			continue
+		}
+		if partitionSuffix.ReplaceAllString(path.Base(mp.Path), "") == pd.PDName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// createGCEPersistentDisk interprets an API volume as a GCEPersistentDisk.
+func createGCEPersistentDisk(volume *api.Volume, podID string, rootDir string) *GCEPersistentDisk {
+	source := volume.Source.GCEPersistentDisk
+	partition := ""
+	if source.Partition != 0 {
+		partition = strconv.Itoa(source.Partition)
+	}
+	return &GCEPersistentDisk{
+		Name:      volume.Name,
+		PodID:     podID,
+		RootDir:   rootDir,
+		PDName:    source.PDName,
+		FSType:    source.FSType,
+		Partition: partition,
+		ReadOnly:  source.ReadOnly,
+		util:      &gcePersistentDiskUtil{},
+		mounter:   &DiskMounter{},
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/cloudprovider"
+	gce_cloud "github.com/GoogleCloudPlatform/kubernetes/pkg/cloudprovider/gce"
+	"github.com/golang/glog"
+)
+
+const (
+	// How long to wait for an attached disk to show up on the host.
+	diskAttachTimeout = 10 * time.Second
+	diskByIDPath      = "/dev/disk/by-id/"
+	diskGooglePrefix  = "google-"
+)
+
+// gcePersistentDiskUtil attaches disks through the GCE cloud provider.
+type gcePersistentDiskUtil struct{}
+
+func getGCECloud() (*gce_cloud.GCECloud, error) {
+	cloud, err := cloudprovider.GetCloudProvider("gce")
+	if err != nil {
+		return nil, err
+	}
+	gce, ok := cloud.(*gce_cloud.GCECloud)
+	if !ok {
+		return nil, errors.New("the GCE cloud provider is not available")
+	}
+	return gce, nil
+}
+
+// AttachDisk attaches a disk specified by a volume.GCEPersistentDisk to the current
+// kubelet and mounts the disk to its global path.
+func (util *gcePersistentDiskUtil) AttachDisk(pd *GCEPersistentDisk) error {
+	gce, err := getGCECloud()
+	if err != nil {
+		return err
+	}
+	if err := gce.AttachDisk(pd.PDName, pd.ReadOnly); err != nil {
+		return err
+	}
+	devicePath := path.Join(diskByIDPath, diskGooglePrefix+pd.PDName)
+	if pd.Partition != "" {
+		devicePath = devicePath + "-part" + pd.Partition
+	}
+	if err := waitForDevice(devicePath, diskAttachTimeout); err != nil {
+		return err
+	}
+	globalPDPath := pd.globalPDPath()
+	mounted, err := isMountPoint(pd.mounter, globalPDPath)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return nil
+	}
+	if err := os.MkdirAll(globalPDPath, 0750); err != nil {
+		return err
+	}
+	if err := formatAndMount(pd.mounter, devicePath, globalPDPath, pd.FSType, pd.ReadOnly); err != nil {
+		os.RemoveAll(globalPDPath)
+		return err
+	}
+	return nil
+}
+
+// DetachDisk unmounts the disk from its global path and detaches it from the
+// current kubelet.
+func (util *gcePersistentDiskUtil) DetachDisk(pd *GCEPersistentDisk, globalPDPath string) error {
+	if err := pd.mounter.Unmount(globalPDPath, 0); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(globalPDPath); err != nil {
+		return err
+	}
+	gce, err := getGCECloud()
+	if err != nil {
+		return err
+	}
+	return gce.DetachDisk(pd.PDName)
+}
+
+// waitForDevice polls for devicePath until it exists or the timeout passes.
+func waitForDevice(devicePath string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := os.Stat(devicePath)
+		if err == nil {
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for device %s", devicePath)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// formatAndMount mounts devicePath at target. If the mount fails because the
+// device has no filesystem yet, the device is formatted with fstype and
+// mounted again. Read-only devices are never formatted.
+func formatAndMount(mounter mounter, devicePath, target, fstype string, readOnly bool) error {
+	flags := uintptr(0)
+	if readOnly {
+		flags = flagReadOnly
+	}
+	err := mounter.Mount(devicePath, target, fstype, flags, "")
+	if err == nil || readOnly {
+		return err
+	}
+	// blkid exits non-zero when it finds no filesystem on the device.
+	existing, blkidErr := exec.Command("blkid", "-o", "value", "-s", "TYPE", devicePath).Output()
+	if _, ok := blkidErr.(*exec.ExitError); blkidErr != nil && !ok {
+		return blkidErr
+	}
+	if len(strings.TrimSpace(string(existing))) != 0 {
+		// The device has a filesystem, so formatting would destroy data.
+		return err
+	}
+	glog.Infof("Formatting %s as %s", devicePath, fstype)
+	if out, err := exec.Command("mkfs", "-t", fstype, devicePath).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to format %s: %v (%s)", devicePath, err, out)
+	}
+	return mounter.Mount(devicePath, target, fstype, flags, "")
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+// mounter provides the mount operations volumes need from the host.
+type mounter interface {
+	// Mount wraps syscall.Mount().
+	Mount(source string, target string, fstype string, flags uintptr, data string) error
+	// Unmount wraps syscall.Unmount().
+	Unmount(target string, flags int) error
+	mountLister
+}
+
+// mountLister lists the host's mount table.
+type mountLister interface {
+	// List returns a list of all mounted filesystems.
+	List() ([]mountPoint, error)
+}
+
+// mountPoint is a single entry of the host's mount table.
+type mountPoint struct {
+	Device string
+	Path   string
+	Type   string
+	Opts   []string
+	Freq   int
+	Pass   int
+}
+
+// getMountRefs finds all mount points that share the device mounted at
+// mountPath, not counting mountPath itself.
+func getMountRefs(mounter mountLister, mountPath string) ([]string, error) {
+	mps, err := mounter.List()
+	if err != nil {
+		return nil, err
+	}
+	deviceName := ""
+	for i := range mps {
+		if mps[i].Path == mountPath {
+			deviceName = mps[i].Device
+			break
+		}
+	}
+	if deviceName == "" {
+		return nil, nil
+	}
+	refs := []string{}
+	for i := range mps {
+		if mps[i].Device == deviceName && mps[i].Path != mountPath {
+			refs = append(refs, mps[i].Path)
+		}
+	}
+	return refs, nil
+}
+
+// isMountPoint returns true if path is in the host's mount table.
+func isMountPoint(mounter mountLister, path string) (bool, error) {
+	mps, err := mounter.List()
+	if err != nil {
+		return false, err
+	}
+	for i := range mps {
+		if mps[i].Path == path {
+			return true, nil
+		}
+	}
+	return false, nil
+}
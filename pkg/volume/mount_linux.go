@@ -0,0 +1,85 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// The flags Linux uses for bind mounts, read-only mounts and remounts.
+	flagBind     = syscall.MS_BIND
+	flagReadOnly = syscall.MS_RDONLY
+	flagRemount  = syscall.MS_REMOUNT
+)
+
+// DiskMounter mounts filesystems through the Linux mount syscalls.
+type DiskMounter struct{}
+
+// Mount wraps syscall.Mount().
+func (DiskMounter) Mount(source string, target string, fstype string, flags uintptr, data string) error {
+	return syscall.Mount(source, target, fstype, flags, data)
+}
+
+// Unmount wraps syscall.Unmount().
+func (DiskMounter) Unmount(target string, flags int) error {
+	return syscall.Unmount(target, flags)
+}
+
+// List returns the entries of /proc/mounts.
+func (DiskMounter) List() ([]mountPoint, error) {
+	file, err := os.Open("/proc/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseMounts(bufio.NewScanner(file))
+}
+
+// parseMounts parses the lines of a mount table in the format of /proc/mounts.
+func parseMounts(scanner *bufio.Scanner) ([]mountPoint, error) {
+	mounts := []mountPoint{}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("wrong number of fields (expected 6, got %d): %s", len(fields), scanner.Text())
+		}
+		mp := mountPoint{
+			Device: fields[0],
+			Path:   fields[1],
+			Type:   fields[2],
+			Opts:   strings.Split(fields[3], ","),
+		}
+		var err error
+		if mp.Freq, err = strconv.Atoi(fields[4]); err != nil {
+			return nil, err
+		}
+		if mp.Pass, err = strconv.Atoi(fields[5]); err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, mp)
+	}
+	return mounts, scanner.Err()
+}
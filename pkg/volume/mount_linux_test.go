@@ -0,0 +1,48 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMounts(t *testing.T) {
+	table := `rootfs / rootfs rw 0 0
+/dev/sda1 / ext4 rw,relatime,errors=remount-ro 0 1
+
+/dev/sdb /var/lib/kubelet/plugins/gce-pd/mounts/my-pd ext4 ro 0 2
+`
+	mounts, err := parseMounts(bufio.NewScanner(strings.NewReader(table)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []mountPoint{
+		{Device: "rootfs", Path: "/", Type: "rootfs", Opts: []string{"rw"}},
+		{Device: "/dev/sda1", Path: "/", Type: "ext4", Opts: []string{"rw", "relatime", "errors=remount-ro"}, Pass: 1},
+		{Device: "/dev/sdb", Path: "/var/lib/kubelet/plugins/gce-pd/mounts/my-pd", Type: "ext4", Opts: []string{"ro"}, Pass: 2},
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, mounts)
+	}
+
+	if _, err := parseMounts(bufio.NewScanner(strings.NewReader("/dev/sda1 / ext4\n"))); err == nil {
+		t.Errorf("Expected an error for a short line")
+	}
+}
@@ -0,0 +1,46 @@
+// +build !linux
+
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"errors"
+)
+
+const (
+	flagBind     = 0
+	flagReadOnly = 0
+	flagRemount  = 0
+)
+
+var errMountUnsupported = errors.New("mounting is only supported on linux")
+
+// DiskMounter is unsupported on this platform.
+type DiskMounter struct{}
+
+func (DiskMounter) Mount(source string, target string, fstype string, flags uintptr, data string) error {
+	return errMountUnsupported
+}
+
+func (DiskMounter) Unmount(target string, flags int) error {
+	return errMountUnsupported
+}
+
+func (DiskMounter) List() ([]mountPoint, error) {
+	return nil, errMountUnsupported
+}
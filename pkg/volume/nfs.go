@@ -0,0 +1,144 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/golang/glog"
+)
+
+// nfsMountInterface mounts and unmounts NFS exports using the host's mount tools.
+type nfsMountInterface interface {
+	// Mount mounts server:exportDir at mountDir, with the mount options given
+	// besides ro or rw.
+	Mount(server string, exportDir string, mountDir string, readOnly bool, options []string) error
+	// Unmount unmounts the export mounted at target.
+	Unmount(target string) error
+	mountLister
+}
+
+// NFS volumes represent an NFS export mounted on the host for the pod.
+type NFS struct {
+	Name    string
+	PodID   string
+	RootDir string
+	// Server is the hostname or IP address of the NFS server.
+	Server string
+	// ExportPath is the path exported by the server.
+	ExportPath string
+	// ReadOnly mounts the export read-only.
+	ReadOnly bool
+	// Options are further mount options.
+	Options []string
+	mounter nfsMountInterface
+}
+
+func (nfs *NFS) GetPath() string {
+	return podVolumePath(nfs.RootDir, nfs.PodID, "nfs", nfs.Name)
+}
+
+// SetUp mounts the export, unless it is already mounted.
+func (nfs *NFS) SetUp() error {
+	mountDir := nfs.GetPath()
+	mounted, err := isMountPoint(nfs.mounter, mountDir)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		return nil
+	}
+	if err := os.MkdirAll(mountDir, 0750); err != nil {
+		return err
+	}
+	if err := nfs.mounter.Mount(nfs.Server, nfs.ExportPath, mountDir, nfs.ReadOnly, nfs.Options); err != nil {
+		os.Remove(mountDir)
+		return err
+	}
+	return nil
+}
+
+// TearDown unmounts the export and removes the mount directory.
+func (nfs *NFS) TearDown() error {
+	mountDir := nfs.GetPath()
+	mounted, err := isMountPoint(nfs.mounter, mountDir)
+	if err != nil {
+		return err
+	}
+	if mounted {
+		if err := nfs.mounter.Unmount(mountDir); err != nil {
+			return err
+		}
+	}
+	// Remove rather than RemoveAll, so a failed unmount never deletes data on the server.
+	if err := os.Remove(mountDir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// createNFS interprets an API volume as an NFS volume.
+func createNFS(volume *api.Volume, podID string, rootDir string) *NFS {
+	source := volume.Source.NFS
+	return &NFS{
+		Name:       volume.Name,
+		PodID:      podID,
+		RootDir:    rootDir,
+		Server:     source.Server,
+		ExportPath: source.Path,
+		ReadOnly:   source.ReadOnly,
+		Options:    source.Options,
+		mounter:    &nfsMounter{},
+	}
+}
+
+// nfsMounter mounts exports by running mount(8) and umount(8), which
+// resolve the server and negotiate the protocol for us.
+type nfsMounter struct{}
+
+func (nfsMounter) Mount(server string, exportDir string, mountDir string, readOnly bool, extra []string) error {
+	source := fmt.Sprintf("%s:%s", server, exportDir)
+	options := "rw"
+	if readOnly {
+		options = "ro"
+	}
+	if len(extra) > 0 {
+		options += "," + strings.Join(extra, ",")
+	}
+	glog.Infof("Mounting NFS export %s at %s (%s)", source, mountDir, options)
+	out, err := exec.Command("mount", "-t", "nfs", "-o", options, source, mountDir).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to mount %s: %v (%s)", source, err, out)
+	}
+	return nil
+}
+
+func (nfsMounter) Unmount(target string) error {
+	out, err := exec.Command("umount", target).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to unmount %s: %v (%s)", target, err, out)
+	}
+	return nil
+}
+
+func (nfsMounter) List() ([]mountPoint, error) {
+	return DiskMounter{}.List()
+}
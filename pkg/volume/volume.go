@@ -28,6 +28,12 @@ import (
 
 var ErrUnsupportedVolumeType = errors.New("unsupported volume type")
 
+// podVolumePath returns where a volume of a pod lives under the root directory
+// of the kubelet: (ROOT_DIR)/pods/(POD_ID)/volumes/(VOLUME_KIND)/(VOLUME_NAME).
+func podVolumePath(rootDir, podID, kind, name string) string {
+	return path.Join(rootDir, "pods", podID, "volumes", kind, name)
+}
+
 // Interface is a directory used by pods or hosts.
 // All method implementations of methods in the volume interface must be idempotent
 type Interface interface {
@@ -84,7 +90,7 @@ func (emptyDir *EmptyDirectory) SetUp() error {
 }
 
 func (emptyDir *EmptyDirectory) GetPath() string {
-	return path.Join(emptyDir.RootDir, emptyDir.PodID, "volumes", "empty", emptyDir.Name)
+	return podVolumePath(emptyDir.RootDir, emptyDir.PodID, "empty", emptyDir.Name)
 }
 
 func (emptyDir *EmptyDirectory) renameDirectory() (string, error) {
@@ -93,6 +99,10 @@ func (emptyDir *EmptyDirectory) renameDirectory() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	// os.Rename doesn't replace directories, so only the name is kept.
+	if err := os.Remove(newPath); err != nil {
+		return "", err
+	}
 	err = os.Rename(oldPath, newPath)
 	if err != nil {
 		return "", err
@@ -139,6 +149,10 @@ func CreateVolumeBuilder(volume *api.Volume, podID string, rootDir string) (Buil
 		vol = createHostDirectory(volume)
 	} else if source.EmptyDirectory != nil {
 		vol = createEmptyDirectory(volume, podID, rootDir)
+	} else if source.GCEPersistentDisk != nil {
+		vol = createGCEPersistentDisk(volume, podID, rootDir)
+	} else if source.NFS != nil {
+		vol = createNFS(volume, podID, rootDir)
 	} else {
 		return nil, ErrUnsupportedVolumeType
 	}
@@ -150,6 +164,16 @@ func CreateVolumeCleaner(kind string, name string, podID string, rootDir string)
 	switch kind {
 	case "empty":
 		return &EmptyDirectory{name, podID, rootDir}, nil
+	case "gce-pd":
+		return &GCEPersistentDisk{
+			Name:    name,
+			PodID:   podID,
+			RootDir: rootDir,
+			util:    &gcePersistentDiskUtil{},
+			mounter: &DiskMounter{},
+		}, nil
+	case "nfs":
+		return &NFS{Name: name, PodID: podID, RootDir: rootDir, mounter: &nfsMounter{}}, nil
 	default:
 		return nil, ErrUnsupportedVolumeType
 	}
@@ -159,13 +183,13 @@ func CreateVolumeCleaner(kind string, name string, podID string, rootDir string)
 // active and mounted. Returns a map of Cleaner types.
 func GetCurrentVolumes(rootDirectory string) map[string]Cleaner {
 	currentVolumes := make(map[string]Cleaner)
-	mountPath := rootDirectory
+	mountPath := path.Join(rootDirectory, "pods")
 	podIDDirs, err := ioutil.ReadDir(mountPath)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		glog.Errorf("Could not read directory: %s, (%s)", mountPath, err)
 	}
 	// Volume information is extracted from the directory structure:
-	// (ROOT_DIR)/(POD_ID)/volumes/(VOLUME_KIND)/(VOLUME_NAME)
+	// (ROOT_DIR)/pods/(POD_ID)/volumes/(VOLUME_KIND)/(VOLUME_NAME)
 	for _, podIDDir := range podIDDirs {
 		if !podIDDir.IsDir() {
 			continue
@@ -173,7 +197,7 @@ func GetCurrentVolumes(rootDirectory string) map[string]Cleaner {
 		podID := podIDDir.Name()
 		podIDPath := path.Join(mountPath, podID, "volumes")
 		volumeKindDirs, err := ioutil.ReadDir(podIDPath)
-		if err != nil {
+		if err != nil && !os.IsNotExist(err) {
 			glog.Errorf("Could not read directory: %s, (%s)", podIDPath, err)
 		}
 		for _, volumeKindDir := range volumeKindDirs {
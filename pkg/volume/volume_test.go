@@ -17,9 +17,11 @@ limitations under the License.
 package volume
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"reflect"
 	"testing"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
@@ -55,7 +57,7 @@ func TestCreateVolumeBuilders(t *testing.T) {
 					EmptyDirectory: &api.EmptyDirectory{},
 				},
 			},
-			path.Join(tempDir, "/my-id/volumes/empty/empty-dir"),
+			path.Join(tempDir, "pods/my-id/volumes/empty/empty-dir"),
 			"my-id",
 			"empty",
 		},
@@ -130,7 +132,7 @@ func TestGetActiveVolumes(t *testing.T) {
 	}
 	expectedIdentifiers := []string{}
 	for _, test := range getActiveVolumesTests {
-		volumeDir := path.Join(tempDir, test.podID, "volumes", test.kind, test.name)
+		volumeDir := path.Join(tempDir, "pods", test.podID, "volumes", test.kind, test.name)
 		os.MkdirAll(volumeDir, 0750)
 		expectedIdentifiers = append(expectedIdentifiers, test.identifier)
 	}
@@ -141,3 +143,260 @@ func TestGetActiveVolumes(t *testing.T) {
 		}
 	}
 }
+
+type fakeMounter struct {
+	mounts []mountPoint
+	// readOnlyRemounts holds the targets remounted read-only.
+	readOnlyRemounts []string
+}
+
+func (f *fakeMounter) Mount(source string, target string, fstype string, flags uintptr, data string) error {
+	if flags&flagRemount != 0 {
+		if flags&flagReadOnly != 0 {
+			f.readOnlyRemounts = append(f.readOnlyRemounts, target)
+		}
+		return nil
+	}
+	device := source
+	if flags&flagBind != 0 {
+		for _, mp := range f.mounts {
+			if mp.Path == source {
+				device = mp.Device
+			}
+		}
+	}
+	f.mounts = append(f.mounts, mountPoint{Device: device, Path: target, Type: fstype})
+	return nil
+}
+
+func (f *fakeMounter) Unmount(target string, flags int) error {
+	for i, mp := range f.mounts {
+		if mp.Path == target {
+			f.mounts = append(f.mounts[:i], f.mounts[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not mounted", target)
+}
+
+func (f *fakeMounter) List() ([]mountPoint, error) {
+	return f.mounts, nil
+}
+
+type fakePDManager struct {
+	attached []string
+	detached []string
+}
+
+func (f *fakePDManager) AttachDisk(pd *GCEPersistentDisk) error {
+	f.attached = append(f.attached, pd.PDName)
+	globalPath := pd.globalPDPath()
+	if mounted, _ := isMountPoint(pd.mounter, globalPath); mounted {
+		return nil
+	}
+	device := "/dev/disk/by-id/google-" + pd.PDName
+	if pd.Partition != "" {
+		device += "-part" + pd.Partition
+	}
+	return pd.mounter.Mount(device, globalPath, pd.FSType, 0, "")
+}
+
+func (f *fakePDManager) DetachDisk(pd *GCEPersistentDisk, globalPDPath string) error {
+	f.detached = append(f.detached, pd.PDName)
+	return pd.mounter.Unmount(globalPDPath, 0)
+}
+
+func TestGCEPersistentDiskSharedReadOnly(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "GCEPersistentDisk")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	mounter := &fakeMounter{}
+	manager := &fakePDManager{}
+	volume := &api.Volume{
+		Name: "data",
+		Source: &api.VolumeSource{
+			GCEPersistentDisk: &api.GCEPersistentDisk{PDName: "my-pd", FSType: "ext4", Partition: 1, ReadOnly: true},
+		},
+	}
+	var paths []string
+	for _, podID := range []string{"pod1", "pod2"} {
+		pd := createGCEPersistentDisk(volume, podID, tempDir)
+		pd.util = manager
+		pd.mounter = mounter
+		if err := pd.SetUp(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err := pd.SetUp(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		expected := path.Join(tempDir, "pods", podID, "volumes/gce-pd/data")
+		if pd.GetPath() != expected {
+			t.Errorf("Expected path %s, got %s", expected, pd.GetPath())
+		}
+		paths = append(paths, pd.GetPath())
+	}
+	if !reflect.DeepEqual(manager.attached, []string{"my-pd", "my-pd"}) {
+		t.Errorf("Unexpected attaches: %v", manager.attached)
+	}
+	if !reflect.DeepEqual(mounter.readOnlyRemounts, paths) {
+		t.Errorf("Expected %v to be remounted read-only, got %v", paths, mounter.readOnlyRemounts)
+	}
+	globalPath := path.Join(tempDir, "plugins/gce-pd/mounts/my-pd-part1")
+	if len(mounter.mounts) != 3 || mounter.mounts[0].Path != globalPath {
+		t.Errorf("Unexpected mounts: %+v", mounter.mounts)
+	}
+
+	volumes := GetCurrentVolumes(tempDir)
+	cleaner, ok := volumes["pod1/data"]
+	if !ok {
+		t.Fatalf("Expected a cleaner for pod1/data, got %v", volumes)
+	}
+	cleaner.(*GCEPersistentDisk).util = manager
+	cleaner.(*GCEPersistentDisk).mounter = mounter
+	if err := cleaner.TearDown(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(manager.detached) != 0 {
+		t.Errorf("Disk detached while still in use: %v", manager.detached)
+	}
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("TearDown() failed, volume path not removed: %v", paths[0])
+	}
+
+	cleaner, err = CreateVolumeCleaner("gce-pd", "data", "pod2", tempDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cleaner.(*GCEPersistentDisk).util = manager
+	cleaner.(*GCEPersistentDisk).mounter = mounter
+	if err := cleaner.TearDown(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(manager.detached, []string{"my-pd"}) {
+		t.Errorf("Expected the disk to be detached, got %v", manager.detached)
+	}
+	if len(mounter.mounts) != 0 {
+		t.Errorf("Unexpected mounts: %+v", mounter.mounts)
+	}
+}
+
+func TestGCEPersistentDiskPartitions(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "GCEPersistentDisk")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	mounter := &fakeMounter{}
+	manager := &fakePDManager{}
+	for i, podID := range []string{"pod1", "pod2"} {
+		volume := &api.Volume{
+			Name: "data",
+			Source: &api.VolumeSource{
+				GCEPersistentDisk: &api.GCEPersistentDisk{PDName: "my-pd", FSType: "ext4", Partition: i + 1},
+			},
+		}
+		pd := createGCEPersistentDisk(volume, podID, tempDir)
+		pd.util = manager
+		pd.mounter = mounter
+		if err := pd.SetUp(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if len(mounter.readOnlyRemounts) != 0 {
+		t.Errorf("Unexpected read-only remounts: %v", mounter.readOnlyRemounts)
+	}
+
+	for _, podID := range []string{"pod1", "pod2"} {
+		cleaner, err := CreateVolumeCleaner("gce-pd", "data", podID, tempDir)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		cleaner.(*GCEPersistentDisk).util = manager
+		cleaner.(*GCEPersistentDisk).mounter = mounter
+		if err := cleaner.TearDown(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if podID == "pod1" && len(manager.detached) != 0 {
+			t.Errorf("Disk detached while another partition is mounted: %v", manager.detached)
+		}
+	}
+	if !reflect.DeepEqual(manager.detached, []string{"my-pd"}) {
+		t.Errorf("Expected the disk to be detached, got %v", manager.detached)
+	}
+	if len(mounter.mounts) != 0 {
+		t.Errorf("Unexpected mounts: %+v", mounter.mounts)
+	}
+}
+
+type fakeNFSMounter struct {
+	fakeMounter
+	mountCalls int
+}
+
+func (f *fakeNFSMounter) Mount(server string, exportDir string, mountDir string, readOnly bool, options []string) error {
+	f.mountCalls++
+	mode := "rw"
+	if readOnly {
+		mode = "ro"
+	}
+	opts := append([]string{mode}, options...)
+	f.mounts = append(f.mounts, mountPoint{Device: server + ":" + exportDir, Path: mountDir, Type: "nfs", Opts: opts})
+	return nil
+}
+
+func (f *fakeNFSMounter) Unmount(target string) error {
+	return f.fakeMounter.Unmount(target, 0)
+}
+
+func TestNFS(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "NFS")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	volume := &api.Volume{
+		Name: "shared",
+		Source: &api.VolumeSource{
+			NFS: &api.NFSMount{Server: "nfs.example.com", Path: "/exports/shared", ReadOnly: true, Options: []string{"vers=3"}},
+		},
+	}
+	mounter := &fakeNFSMounter{}
+	nfs := createNFS(volume, "my-id", tempDir)
+	nfs.mounter = mounter
+	for i := 0; i < 2; i++ {
+		if err := nfs.SetUp(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if mounter.mountCalls != 1 {
+		t.Errorf("Expected SetUp to mount once, got %d mounts", mounter.mountCalls)
+	}
+	expected := []mountPoint{{
+		Device: "nfs.example.com:/exports/shared",
+		Path:   path.Join(tempDir, "pods/my-id/volumes/nfs/shared"),
+		Type:   "nfs",
+		Opts:   []string{"ro", "vers=3"},
+	}}
+	if !reflect.DeepEqual(mounter.mounts, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, mounter.mounts)
+	}
+
+	cleaner, err := CreateVolumeCleaner("nfs", "shared", "my-id", tempDir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cleaner.(*NFS).mounter = mounter
+	for i := 0; i < 2; i++ {
+		if err := cleaner.TearDown(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if len(mounter.mounts) != 0 {
+		t.Errorf("Unexpected mounts: %+v", mounter.mounts)
+	}
+	if _, err := os.Stat(nfs.GetPath()); !os.IsNotExist(err) {
+		t.Errorf("TearDown() failed, volume path not removed: %v", nfs.GetPath())
+	}
+}
@@ -92,6 +92,17 @@ func (m *Mux) Action(action EventType, obj interface{}) {
 	m.incoming <- Event{action, obj}
 }
 
+// TryAction is like Action, but returns false instead of waiting when the queue
+// is full. The event is dropped then.
+func (m *Mux) TryAction(action EventType, obj interface{}) bool {
+	select {
+	case m.incoming <- Event{action, obj}:
+		return true
+	default:
+		return false
+	}
+}
+
 // Shutdown disconnects all watchers (but any queued events will still be distributed).
 // You must not call Action after calling Shutdown.
 func (m *Mux) Shutdown() {
@@ -88,3 +88,24 @@ func TestMuxWatcherClose(t *testing.T) {
 	w.Stop()
 	w2.Stop()
 }
+
+func TestMuxTryAction(t *testing.T) {
+	m := NewMux(1)
+	w := m.Watch()
+	defer m.Shutdown()
+	// The loop takes the first event and waits on w with it; the second one fills
+	// the queue.
+	if !m.TryAction(Added, "a") {
+		t.Errorf("expected the first event to be queued")
+	}
+	for !m.TryAction(Added, "b") {
+	}
+	if m.TryAction(Added, "c") {
+		t.Errorf("expected the event to be dropped")
+	}
+	for _, e := range []string{"a", "b"} {
+		if event := <-w.ResultChan(); event.Object != e {
+			t.Errorf("expected %v, got %#v", e, event)
+		}
+	}
+}
@@ -18,16 +18,35 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/healthz"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
+	algorithm "github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	verflag "github.com/GoogleCloudPlatform/kubernetes/pkg/version/flag"
 	"github.com/GoogleCloudPlatform/kubernetes/plugin/pkg/scheduler"
 	"github.com/GoogleCloudPlatform/kubernetes/plugin/pkg/scheduler/factory"
+
+	"github.com/golang/glog"
 )
 
 var (
-	master = flag.String("master", "", "The address of the Kubernetes API server")
+	master            = flag.String("master", "", "The address of the Kubernetes API server")
+	address           = flag.String("address", "127.0.0.1", "The address to serve /metrics and /healthz on (set to 0.0.0.0 or \"\" for all interfaces)")
+	port              = flag.Uint("port", 10251, "The port to serve /metrics and /healthz on (set to 0 to disable)")
+	algorithmProvider = flag.String("algorithm_provider", algorithm.DefaultProvider, "The scheduling algorithm to use, one of: "+strings.Join(algorithm.ListAlgorithmProviders(), ", "))
+	policyConfigFile  = flag.String("policy_config_file", "", "A JSON file describing the scheduling algorithm, overriding algorithm_provider, and extenders to consult")
+	simulate          = flag.String("simulate", "", "If set, a JSON file with a list of hypothetical pods; where they would be placed, or why they wouldn't fit, is printed and the scheduler exits without binding anything")
+	schedulerName     = flag.String("scheduler_name", algorithm.DefaultSchedulerName, "The name of this scheduler; it only places the pods whose \""+algorithm.SchedulerAnnotation+"\" annotation names it")
 )
 
 func main() {
@@ -40,10 +59,69 @@ func main() {
 	// TODO: security story for plugins!
 	kubeClient := client.New("http://"+*master, nil)
 
-	configFactory := &factory.ConfigFactory{Client: kubeClient}
-	config := configFactory.Create()
+	configFactory := &factory.ConfigFactory{
+		Client:            kubeClient,
+		AlgorithmProvider: *algorithmProvider,
+		SchedulerName:     *schedulerName,
+	}
+	if *policyConfigFile != "" {
+		data, err := ioutil.ReadFile(*policyConfigFile)
+		if err != nil {
+			glog.Fatalf("Failed to read scheduler policy: %v", err)
+		}
+		configFactory.Policy, err = algorithm.ParsePolicy(data)
+		if err != nil {
+			glog.Fatalf("Failed to parse %s: %v", *policyConfigFile, err)
+		}
+	}
+	if *simulate != "" {
+		runSimulation(configFactory, *simulate)
+		return
+	}
+
+	// Events are always logged, and also sent to the apiserver.
+	record.StartLogging(glog.Infof)
+	record.StartRecording(kubeClient, "scheduler")
+
+	config, err := configFactory.Create()
+	if err != nil {
+		glog.Fatalf("Failed to create scheduler configuration: %v", err)
+	}
 	s := scheduler.New(config)
 	s.Run()
 
+	if *port != 0 {
+		mux := http.NewServeMux()
+		healthz.InstallHandler(mux)
+		metrics.InstallHandler(mux)
+		go func() {
+			addr := net.JoinHostPort(*address, strconv.Itoa(int(*port)))
+			glog.Errorf("Unable to serve metrics: %v", http.ListenAndServe(addr, mux))
+		}()
+	}
+
 	select {}
 }
+
+// runSimulation prints where the pods in the file at path would be placed.
+func runSimulation(configFactory *factory.ConfigFactory, path string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		glog.Fatalf("Failed to read pods to simulate: %v", err)
+	}
+	pods := api.PodList{}
+	if err := api.DecodeInto(data, &pods); err != nil {
+		glog.Fatalf("Failed to parse %s: %v", path, err)
+	}
+	placements, err := configFactory.Simulate(pods.Items)
+	if err != nil {
+		glog.Fatalf("Failed to simulate scheduling: %v", err)
+	}
+	for _, placement := range placements {
+		if placement.Err != nil {
+			fmt.Printf("%s: unschedulable: %v\n", placement.PodID, placement.Err)
+		} else {
+			fmt.Printf("%s: %s\n", placement.PodID, placement.Host)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
+	algorithm "github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
+)
+
+const (
+	// How long a pod waits after failing to schedule for the first time.
+	initialSchedulingBackoff = 1 * time.Second
+	// The backoff doubles every time the pod fails again, up to this.
+	maxSchedulingBackoff = 60 * time.Second
+)
+
+type backoffEntry struct {
+	backoff    time.Duration
+	lastUpdate time.Time
+}
+
+// podBackoff keeps how long each pod which failed to schedule waits before the
+// next attempt.
+type podBackoff struct {
+	lock            sync.Mutex
+	perPodBackoff   map[string]*backoffEntry
+	defaultDuration time.Duration
+	maxDuration     time.Duration
+	// Defaults to time.Now.
+	now func() time.Time
+}
+
+func newPodBackoff(defaultDuration, maxDuration time.Duration) *podBackoff {
+	return &podBackoff{
+		perPodBackoff:   map[string]*backoffEntry{},
+		defaultDuration: defaultDuration,
+		maxDuration:     maxDuration,
+	}
+}
+
+func (p *podBackoff) getNow() time.Time {
+	if p.now == nil {
+		return time.Now()
+	}
+	return p.now()
+}
+
+// getBackoff returns how long the pod waits now, and doubles how long it waits
+// the next time.
+func (p *podBackoff) getBackoff(podID string) time.Duration {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	entry, ok := p.perPodBackoff[podID]
+	if !ok {
+		entry = &backoffEntry{backoff: p.defaultDuration}
+		p.perPodBackoff[podID] = entry
+	}
+	entry.lastUpdate = p.getNow()
+	duration := entry.backoff
+	entry.backoff *= 2
+	if entry.backoff > p.maxDuration {
+		entry.backoff = p.maxDuration
+	}
+	return duration
+}
+
+// gc forgets the pods which haven't failed for longer than the maximum backoff,
+// which start over from the default duration if they fail again.
+func (p *podBackoff) gc() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := p.getNow()
+	for podID, entry := range p.perPodBackoff {
+		if now.Sub(entry.lastUpdate) > p.maxDuration {
+			delete(p.perPodBackoff, podID)
+		}
+	}
+}
+
+// unschedulablePods holds the pods which failed to schedule until their backoff
+// is over, or until the cluster changes in a way which may let them fit.
+type unschedulablePods struct {
+	lock    sync.Mutex
+	waiting map[string]*api.Pod
+	// retry is called, from its own goroutine, with every pod once it is done waiting.
+	retry func(pod *api.Pod)
+}
+
+func newUnschedulablePods(retry func(pod *api.Pod)) *unschedulablePods {
+	return &unschedulablePods{
+		waiting: map[string]*api.Pod{},
+		retry:   retry,
+	}
+}
+
+// add makes pod wait for backoff before it is retried.
+func (u *unschedulablePods) add(pod *api.Pod, backoff time.Duration) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.waiting[pod.ID] = pod
+	time.AfterFunc(backoff, func() {
+		defer util.HandleCrash()
+		u.lock.Lock()
+		// The pod may have been retried already, and may even be waiting again.
+		if u.waiting[pod.ID] != pod {
+			u.lock.Unlock()
+			return
+		}
+		delete(u.waiting, pod.ID)
+		u.lock.Unlock()
+		u.retry(pod)
+	})
+}
+
+// len returns how many pods are waiting.
+func (u *unschedulablePods) len() int {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return len(u.waiting)
+}
+
+// retryAll retries every waiting pod right away.
+func (u *unschedulablePods) retryAll() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	for _, pod := range u.waiting {
+		go func(pod *api.Pod) {
+			defer util.HandleCrash()
+			u.retry(pod)
+		}(pod)
+	}
+	u.waiting = map[string]*api.Pod{}
+}
+
+// podDeletionNotifier calls notify whenever a pod leaves the indexer, freeing the
+// ports and resources it took on its minion.
+type podDeletionNotifier struct {
+	cache.Indexer
+	notify func()
+}
+
+func (n podDeletionNotifier) Delete(id string) {
+	n.Indexer.Delete(id)
+	n.notify()
+}
+
+// minionAdditionNotifier calls notify whenever a minion it didn't have before is
+// stored.
+type minionAdditionNotifier struct {
+	cache.Store
+	notify func()
+}
+
+func (n minionAdditionNotifier) Add(id string, obj interface{}) {
+	_, exists := n.Store.Get(id)
+	n.Store.Add(id, obj)
+	if !exists {
+		n.notify()
+	}
+}
+
+func (n minionAdditionNotifier) Update(id string, obj interface{}) {
+	_, exists := n.Store.Get(id)
+	n.Store.Update(id, obj)
+	if !exists {
+		n.notify()
+	}
+}
+
+// responsiblePodFilter keeps only the pods the scheduler called name is to place
+// in the store, so that pods claimed by other schedulers are left to them.
+type responsiblePodFilter struct {
+	cache.Store
+	name string
+}
+
+func (f responsiblePodFilter) Add(id string, obj interface{}) {
+	if algorithm.IsResponsible(f.name, *obj.(*api.Pod)) {
+		f.Store.Add(id, obj)
+	} else {
+		f.Store.Delete(id)
+	}
+}
+
+func (f responsiblePodFilter) Update(id string, obj interface{}) {
+	if algorithm.IsResponsible(f.name, *obj.(*api.Pod)) {
+		f.Store.Update(id, obj)
+	} else {
+		f.Store.Delete(id)
+	}
+}
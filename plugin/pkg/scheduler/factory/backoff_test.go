@@ -0,0 +1,140 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
+	algorithm "github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
+)
+
+func TestPodBackoff(t *testing.T) {
+	now := time.Now()
+	backoff := newPodBackoff(1*time.Second, 60*time.Second)
+	backoff.now = func() time.Time { return now }
+
+	for _, expected := range []time.Duration{1, 2, 4, 8, 16, 32, 60, 60} {
+		if e, a := expected*time.Second, backoff.getBackoff("foo"); e != a {
+			t.Errorf("Expected %v, got %v", e, a)
+		}
+	}
+	if e, a := 1*time.Second, backoff.getBackoff("bar"); e != a {
+		t.Errorf("Expected %v for another pod, got %v", e, a)
+	}
+
+	now = now.Add(61 * time.Second)
+	backoff.getBackoff("bar")
+	backoff.gc()
+	if e, a := 1*time.Second, backoff.getBackoff("foo"); e != a {
+		t.Errorf("Expected the backoff of foo to start over, got %v", a)
+	}
+	if e, a := 4*time.Second, backoff.getBackoff("bar"); e != a {
+		t.Errorf("Expected the backoff of bar to be kept, got %v", a)
+	}
+}
+
+func TestUnschedulablePods(t *testing.T) {
+	retried := make(chan string, 10)
+	unschedulable := newUnschedulablePods(func(pod *api.Pod) { retried <- pod.ID })
+
+	unschedulable.add(&api.Pod{JSONBase: api.JSONBase{ID: "foo"}}, time.Millisecond)
+	select {
+	case id := <-retried:
+		if id != "foo" {
+			t.Errorf("Expected foo, got %v", id)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("foo was not retried after its backoff")
+	}
+
+	unschedulable.add(&api.Pod{JSONBase: api.JSONBase{ID: "bar"}}, time.Hour)
+	unschedulable.retryAll()
+	select {
+	case id := <-retried:
+		if id != "bar" {
+			t.Errorf("Expected bar, got %v", id)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("bar was not retried when every pod was")
+	}
+	if len(unschedulable.waiting) != 0 {
+		t.Errorf("Expected no waiting pods, got %v", unschedulable.waiting)
+	}
+}
+
+func TestUnschedulablePodsRetriesOnce(t *testing.T) {
+	retried := make(chan string, 10)
+	unschedulable := newUnschedulablePods(func(pod *api.Pod) { retried <- pod.ID })
+
+	unschedulable.add(&api.Pod{JSONBase: api.JSONBase{ID: "foo"}}, 10*time.Millisecond)
+	unschedulable.retryAll()
+	<-retried
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case id := <-retried:
+		t.Errorf("Expected %v to be retried only once", id)
+	default:
+	}
+}
+
+func TestChangeNotifiers(t *testing.T) {
+	notified := 0
+	notify := func() { notified++ }
+
+	minions := minionAdditionNotifier{cache.NewStore(), notify}
+	minions.Update("m1", &api.Minion{JSONBase: api.JSONBase{ID: "m1"}})
+	minions.Update("m1", &api.Minion{JSONBase: api.JSONBase{ID: "m1"}})
+	minions.Add("m2", &api.Minion{JSONBase: api.JSONBase{ID: "m2"}})
+	if notified != 2 {
+		t.Errorf("Expected a notification per new minion, got %v", notified)
+	}
+
+	notified = 0
+	pods := podDeletionNotifier{cache.NewPodIndexer(), notify}
+	pods.Add("foo", &api.Pod{JSONBase: api.JSONBase{ID: "foo"}})
+	pods.Update("foo", &api.Pod{JSONBase: api.JSONBase{ID: "foo"}})
+	pods.Delete("foo")
+	if notified != 1 {
+		t.Errorf("Expected a notification per deleted pod, got %v", notified)
+	}
+}
+
+func TestResponsiblePodFilter(t *testing.T) {
+	queue := responsiblePodFilter{cache.NewStore(), "batch"}
+	queue.Add("foo", &api.Pod{
+		JSONBase:    api.JSONBase{ID: "foo"},
+		Annotations: map[string]string{algorithm.SchedulerAnnotation: "batch"},
+	})
+	queue.Add("bar", &api.Pod{JSONBase: api.JSONBase{ID: "bar"}})
+	if _, ok := queue.Get("foo"); !ok {
+		t.Errorf("Expected foo, which names this scheduler, to be queued")
+	}
+	if _, ok := queue.Get("bar"); ok {
+		t.Errorf("Expected bar, which names no scheduler, not to be queued")
+	}
+
+	queue.Update("foo", &api.Pod{
+		JSONBase:    api.JSONBase{ID: "foo"},
+		Annotations: map[string]string{algorithm.SchedulerAnnotation: "other"},
+	})
+	if _, ok := queue.Get("foo"); ok {
+		t.Errorf("Expected foo to leave the queue once claimed by another scheduler")
+	}
+}
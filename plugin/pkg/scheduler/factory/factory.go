@@ -19,13 +19,14 @@ limitations under the License.
 package factory
 
 import (
-	"math/rand"
+	"fmt"
 	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
 	algorithm "github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
@@ -37,22 +38,56 @@ import (
 // ConfigFactory knows how to fill out a scheduler config with its support functions.
 type ConfigFactory struct {
 	Client *client.Client
+	// AlgorithmProvider is the name of the registered scheduling algorithm to
+	// use. Defaults to the default provider of pkg/scheduler.
+	AlgorithmProvider string
+	// Policy, if set, describes the scheduling algorithm instead of AlgorithmProvider.
+	Policy *algorithm.Policy
+	// SchedulerName is the name pods give in their scheduler annotation to be
+	// placed by this scheduler. Defaults to the default scheduler of
+	// pkg/scheduler, which also places the pods naming none.
+	SchedulerName string
 }
 
 // Create creates a scheduler and all support functions.
-func (factory *ConfigFactory) Create() *scheduler.Config {
-	// Watch and queue pods that need scheduling.
+func (factory *ConfigFactory) Create() (*scheduler.Config, error) {
+	// Pods that need scheduling, and those which failed to schedule and wait
+	// for their backoff or for pods to go away or minions to come.
+	// Pods claimed by other schedulers never enter the queue.
+	name := factory.SchedulerName
+	if name == "" {
+		name = algorithm.DefaultSchedulerName
+	}
 	podQueue := cache.NewFIFO()
-	cache.NewReflector(factory.createUnassignedPodWatch, &api.Pod{}, podQueue).Run()
+	queue := responsiblePodFilter{podQueue, name}
+	backoff := newPodBackoff(initialSchedulingBackoff, maxSchedulingBackoff)
+	unschedulable := newUnschedulablePods(factory.makeRequeueFunc(queue))
+
+	// Scheduler needs to find all pods so it knows where it's safe to place
+	// a pod, and minions may be listed frequently. Cache both locally.
+	podCache := podDeletionNotifier{cache.NewPodIndexer(), unschedulable.retryAll}
+	minionCache := minionAdditionNotifier{cache.NewStore(), unschedulable.retryAll}
+	minionLister := &storeToMinionLister{minionCache}
+	// Services are only needed to tell which pods back the same service.
+	serviceCache := cache.NewStore()
+
+	args := algorithm.PluginFactoryArgs{
+		PodLister:     &cache.StoreToPodLister{Indexer: podCache},
+		ServiceLister: &storeToServiceLister{serviceCache},
+		MinionInfo:    minionLister,
+	}
+	algo, err := factory.createAlgorithm(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Watch and queue pods that need scheduling.
+	cache.NewReflector(factory.createUnassignedPodWatch, &api.Pod{}, queue).Run()
 
-	// Watch and cache all running pods. Scheduler needs to find all pods
-	// so it knows where it's safe to place a pod. Cache this locally.
-	podCache := cache.NewStore()
+	// Watch and cache all running pods.
 	cache.NewReflector(factory.createAssignedPodWatch, &api.Pod{}, podCache).Run()
 
 	// Watch minions.
-	// Minions may be listed frequently, so provide a local up-to-date cache.
-	minionCache := cache.NewStore()
 	if false {
 		// Disable this code until minions support watches.
 		cache.NewReflector(factory.createMinionWatch, &api.Minion{}, minionCache).Run()
@@ -60,19 +95,46 @@ func (factory *ConfigFactory) Create() *scheduler.Config {
 		cache.NewPoller(factory.pollMinions, 10*time.Second, minionCache).Run()
 	}
 
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	algo := algorithm.NewRandomFitScheduler(
-		&storeToPodLister{podCache}, r)
+	// Poll services, which don't support watches either.
+	cache.NewPoller(factory.pollServices, 10*time.Second, serviceCache).Run()
+
+	go util.Forever(backoff.gc, maxSchedulingBackoff)
+
+	// Pods piling up in either queue mean the scheduler can't keep up, or the
+	// cluster is full.
+	metrics.Default.Gauge("scheduler.queue.pending", func() int64 {
+		return int64(len(podQueue.List()))
+	})
+	metrics.Default.Gauge("scheduler.queue.unschedulable", func() int64 {
+		return int64(unschedulable.len())
+	})
 
 	return &scheduler.Config{
-		MinionLister: &storeToMinionLister{minionCache},
+		MinionLister: minionLister,
 		Algorithm:    algo,
 		Binder:       &binder{factory.Client},
 		NextPod: func() *api.Pod {
 			return podQueue.Pop().(*api.Pod)
 		},
-		Error: factory.makeDefaultErrorFunc(podQueue),
+		Error: factory.makeDefaultErrorFunc(backoff, unschedulable),
+		Assume: func(pod *api.Pod) {
+			// The watch of assigned pods replaces it once it catches up.
+			podCache.Add(pod.ID, pod)
+		},
+	}, nil
+}
+
+// createAlgorithm makes the scheduling algorithm the policy or, without one, the
+// algorithm provider names.
+func (factory *ConfigFactory) createAlgorithm(args algorithm.PluginFactoryArgs) (algorithm.Scheduler, error) {
+	if factory.Policy != nil {
+		return algorithm.NewAlgorithmFromPolicy(*factory.Policy, args)
 	}
+	provider := factory.AlgorithmProvider
+	if provider == "" {
+		provider = algorithm.DefaultProvider
+	}
+	return algorithm.NewAlgorithmFromProvider(provider, args)
 }
 
 // createUnassignedPodWatch starts a watch that finds all pods that need to be
@@ -119,29 +181,62 @@ func (factory *ConfigFactory) pollMinions() (cache.Enumerator, error) {
 	return &minionEnumerator{list}, nil
 }
 
-func (factory *ConfigFactory) makeDefaultErrorFunc(podQueue *cache.FIFO) func(pod *api.Pod, err error) {
+// pollServices lists all services and returns an enumerator for cache.Poller.
+func (factory *ConfigFactory) pollServices() (cache.Enumerator, error) {
+	list := &api.ServiceList{}
+	err := factory.Client.Get().Path("services").Do().Into(list)
+	if err != nil {
+		return nil, err
+	}
+	return &serviceEnumerator{list}, nil
+}
+
+// makeDefaultErrorFunc returns an Error func which retries pods after a backoff
+// which doubles every time they fail again. Pods whose binding conflicted because
+// they were bound meanwhile, by another scheduler or by this one before it
+// restarted, are dropped instead.
+func (factory *ConfigFactory) makeDefaultErrorFunc(backoff *podBackoff, unschedulable *unschedulablePods) func(pod *api.Pod, err error) {
 	return func(pod *api.Pod, err error) {
-		glog.Errorf("Error scheduling %v: %v; retrying", pod.ID, err)
-
-		// Retry asynchronously.
-		// Note that this is extremely rudimentary and we need a more real error handling path.
-		go func() {
-			defer util.HandleCrash()
-			podID := pod.ID
-			// Get the pod again; it may have changed/been scheduled already.
-			pod = &api.Pod{}
-			err := factory.Client.Get().Path("pods").Path(podID).Do().Into(pod)
-			if err != nil {
-				glog.Errorf("Error getting pod %v for retry: %v; abandoning", podID, err)
+		if statusReason(err) == api.ReasonTypeConflict {
+			current := &api.Pod{}
+			getErr := factory.Client.Get().Path("pods").Path(pod.ID).Do().Into(current)
+			if getErr == nil && current.DesiredState.Host != "" {
+				glog.Infof("Pod %v was bound to %v meanwhile; not retrying", pod.ID, current.DesiredState.Host)
 				return
 			}
-			if pod.DesiredState.Host == "" {
-				podQueue.Add(pod.ID, pod)
-			}
-		}()
+		}
+		duration := backoff.getBackoff(pod.ID)
+		glog.Errorf("Error scheduling %v: %v; retrying in %v", pod.ID, err, duration)
+		unschedulable.add(pod, duration)
 	}
 }
 
+// makeRequeueFunc returns a function putting pods back into podQueue, unless
+// they were deleted or scheduled meanwhile.
+func (factory *ConfigFactory) makeRequeueFunc(podQueue cache.Store) func(pod *api.Pod) {
+	return func(pod *api.Pod) {
+		podID := pod.ID
+		// Get the pod again; it may have changed/been scheduled already.
+		pod = &api.Pod{}
+		err := factory.Client.Get().Path("pods").Path(podID).Do().Into(pod)
+		if err != nil {
+			glog.Errorf("Error getting pod %v for retry: %v; abandoning", podID, err)
+			return
+		}
+		if pod.DesiredState.Host == "" {
+			podQueue.Add(pod.ID, pod)
+		}
+	}
+}
+
+// statusReason returns why the apiserver refused a request, if it said.
+func statusReason(err error) api.ReasonType {
+	if statusErr, ok := err.(*client.StatusErr); ok {
+		return statusErr.Status.Reason
+	}
+	return api.ReasonTypeUnknown
+}
+
 // storeToMinionLister turns a store into a minion lister. The store must contain (only) minions.
 type storeToMinionLister struct {
 	cache.Store
@@ -154,19 +249,24 @@ func (s *storeToMinionLister) List() (machines []string, err error) {
 	return machines, nil
 }
 
-// storeToPodLister turns a store into a pod lister. The store must contain (only) pods.
-type storeToPodLister struct {
+// GetMinionInfo returns the cached minion with the given ID.
+func (s *storeToMinionLister) GetMinionInfo(id string) (*api.Minion, error) {
+	if minion, ok := s.Get(id); ok {
+		return minion.(*api.Minion), nil
+	}
+	return nil, fmt.Errorf("minion %s not found", id)
+}
+
+// storeToServiceLister turns a store into a service lister. The store must contain (only) services.
+type storeToServiceLister struct {
 	cache.Store
 }
 
-func (s *storeToPodLister) ListPods(selector labels.Selector) (pods []api.Pod, err error) {
-	for _, m := range s.List() {
-		pod := m.(*api.Pod)
-		if selector.Matches(labels.Set(pod.Labels)) {
-			pods = append(pods, *pod)
-		}
+func (s *storeToServiceLister) ListServices() (services api.ServiceList, err error) {
+	for _, m := range s.Store.List() {
+		services.Items = append(services.Items, *m.(*api.Service))
 	}
-	return pods, nil
+	return services, nil
 }
 
 // minionEnumerator allows a cache.Poller to enumerate items in an api.PodList
@@ -187,6 +287,24 @@ func (me *minionEnumerator) Get(index int) (string, interface{}) {
 	return me.Items[index].ID, &me.Items[index]
 }
 
+// serviceEnumerator allows a cache.Poller to enumerate items in an api.ServiceList
+type serviceEnumerator struct {
+	*api.ServiceList
+}
+
+// Returns the number of items in the service list.
+func (se *serviceEnumerator) Len() int {
+	if se.ServiceList == nil {
+		return 0
+	}
+	return len(se.Items)
+}
+
+// Returns the item (and ID) with the particular index.
+func (se *serviceEnumerator) Get(index int) (string, interface{}) {
+	return se.Items[index].ID, &se.Items[index]
+}
+
 type binder struct {
 	*client.Client
 }
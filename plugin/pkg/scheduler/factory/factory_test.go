@@ -25,7 +25,8 @@ import (
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
-	"github.com/GoogleCloudPlatform/kubernetes/pkg/labels"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
+	algorithm "github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/watch"
 )
@@ -37,12 +38,35 @@ func TestCreate(t *testing.T) {
 		T:            t,
 	}
 	server := httptest.NewServer(&handler)
-	factory := ConfigFactory{client.New(server.URL, nil)}
-	factory.Create()
+	factory := ConfigFactory{Client: client.New(server.URL, nil)}
+	if _, err := factory.Create(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	gauges := metrics.Default.Snapshot().Gauges
+	for _, name := range []string{"scheduler.queue.pending", "scheduler.queue.unschedulable"} {
+		if value, ok := gauges[name]; !ok || value != 0 {
+			t.Errorf("Expected gauge %s to be 0, got %v (%v)", name, value, ok)
+		}
+	}
+
+	factory.AlgorithmProvider = "unknown"
+	if _, err := factory.Create(); err == nil {
+		t.Errorf("Expected an error for an unknown algorithm provider")
+	}
+
+	// A policy replaces the algorithm provider.
+	factory.Policy = &algorithm.Policy{}
+	if _, err := factory.Create(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	factory.Policy.Predicates = []algorithm.PredicatePolicy{{Name: "unknown"}}
+	if _, err := factory.Create(); err == nil {
+		t.Errorf("Expected an error for a policy naming an unknown predicate")
+	}
 }
 
 func TestCreateWatches(t *testing.T) {
-	factory := ConfigFactory{nil}
+	factory := ConfigFactory{Client: nil}
 	table := []struct {
 		rv           uint64
 		location     string
@@ -114,7 +138,7 @@ func TestPollMinions(t *testing.T) {
 			T:            t,
 		}
 		server := httptest.NewServer(&handler)
-		cf := ConfigFactory{client.New(server.URL, nil)}
+		cf := ConfigFactory{Client: client.New(server.URL, nil)}
 
 		ce, err := cf.pollMinions()
 		if err != nil {
@@ -129,6 +153,39 @@ func TestPollMinions(t *testing.T) {
 	}
 }
 
+func TestPollServices(t *testing.T) {
+	sl := &api.ServiceList{Items: []api.Service{
+		{JSONBase: api.JSONBase{ID: "foo"}, Selector: map[string]string{"name": "foo"}},
+	}}
+	handler := util.FakeHandler{
+		StatusCode:   200,
+		ResponseBody: api.EncodeOrDie(sl),
+		T:            t,
+	}
+	server := httptest.NewServer(&handler)
+	cf := ConfigFactory{Client: client.New(server.URL, nil)}
+
+	se, err := cf.pollServices()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	handler.ValidateRequest(t, "/api/v1beta1/services", "GET", nil)
+	if e, a := 1, se.Len(); e != a {
+		t.Fatalf("Expected %v, got %v", e, a)
+	}
+
+	store := cache.NewStore()
+	id, obj := se.Get(0)
+	store.Add(id, obj)
+	services, err := (&storeToServiceLister{store}).ListServices()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(services.Items) != 1 || services.Items[0].ID != "foo" {
+		t.Errorf("Expected service foo, got %#v", services.Items)
+	}
+}
+
 func TestDefaultErrorFunc(t *testing.T) {
 	testPod := &api.Pod{JSONBase: api.JSONBase{ID: "foo"}}
 	handler := util.FakeHandler{
@@ -137,9 +194,10 @@ func TestDefaultErrorFunc(t *testing.T) {
 		T:            t,
 	}
 	server := httptest.NewServer(&handler)
-	factory := ConfigFactory{client.New(server.URL, nil)}
+	factory := ConfigFactory{Client: client.New(server.URL, nil)}
 	queue := cache.NewFIFO()
-	errFunc := factory.makeDefaultErrorFunc(queue)
+	backoff := newPodBackoff(time.Millisecond, time.Second)
+	errFunc := factory.makeDefaultErrorFunc(backoff, newUnschedulablePods(factory.makeRequeueFunc(queue)))
 
 	errFunc(testPod, nil)
 	for {
@@ -157,6 +215,47 @@ func TestDefaultErrorFunc(t *testing.T) {
 		}
 		break
 	}
+	if e, a := 2*time.Millisecond, backoff.getBackoff("foo"); e != a {
+		t.Errorf("Expected the backoff of foo to double to %v, got %v", e, a)
+	}
+}
+
+func TestDefaultErrorFuncAlreadyBound(t *testing.T) {
+	boundPod := &api.Pod{JSONBase: api.JSONBase{ID: "foo"}, DesiredState: api.PodState{Host: "machine1"}}
+	handler := util.FakeHandler{
+		StatusCode:   200,
+		ResponseBody: api.EncodeOrDie(boundPod),
+		T:            t,
+	}
+	server := httptest.NewServer(&handler)
+	factory := ConfigFactory{Client: client.New(server.URL, nil)}
+	retried := make(chan string, 1)
+	backoff := newPodBackoff(time.Millisecond, time.Second)
+	errFunc := factory.makeDefaultErrorFunc(backoff, newUnschedulablePods(func(pod *api.Pod) { retried <- pod.ID }))
+
+	conflict := &client.StatusErr{Status: api.Status{Status: api.StatusFailure, Reason: api.ReasonTypeConflict}}
+	errFunc(&api.Pod{JSONBase: api.JSONBase{ID: "foo"}}, conflict)
+	handler.ValidateRequest(t, "/api/v1beta1/pods/foo", "GET", nil)
+	select {
+	case id := <-retried:
+		t.Errorf("Expected %v, which is bound already, not to be retried", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if e, a := time.Millisecond, backoff.getBackoff("foo"); e != a {
+		t.Errorf("Expected no backoff for foo, got %v", a)
+	}
+
+	// Other conflicts, e.g. over host ports, are retried.
+	handler.ResponseBody = api.EncodeOrDie(&api.Pod{JSONBase: api.JSONBase{ID: "bar"}})
+	errFunc(&api.Pod{JSONBase: api.JSONBase{ID: "bar"}}, conflict)
+	select {
+	case id := <-retried:
+		if id != "bar" {
+			t.Errorf("Expected bar to be retried, got %v", id)
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("Expected bar, which is still unbound, to be retried")
+	}
 }
 
 func TestStoreToMinionLister(t *testing.T) {
@@ -174,33 +273,23 @@ func TestStoreToMinionLister(t *testing.T) {
 	if !ids.HasAll(got...) || len(got) != len(ids) {
 		t.Errorf("Expected %v, got %v", ids, got)
 	}
-}
 
-func TestStoreToPodLister(t *testing.T) {
-	store := cache.NewStore()
-	ids := []string{"foo", "bar", "baz"}
-	for _, id := range ids {
-		store.Add(id, &api.Pod{
-			JSONBase: api.JSONBase{ID: id},
-			Labels:   map[string]string{"name": id},
-		})
+	minion, err := sml.GetMinionInfo("foo")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if minion.ID != "foo" {
+		t.Errorf("Expected foo, got %v", minion.ID)
 	}
-	spl := storeToPodLister{store}
+	if _, err := sml.GetMinionInfo("qux"); err == nil {
+		t.Errorf("Expected an error for an unknown minion")
+	}
+}
 
-	for _, id := range ids {
-		got, err := spl.ListPods(labels.Set{"name": id}.AsSelector())
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
-			continue
-		}
-		if e, a := 1, len(got); e != a {
-			t.Errorf("Expected %v, got %v", e, a)
-			continue
-		}
-		if e, a := id, got[0].ID; e != a {
-			t.Errorf("Expected %v, got %v", e, a)
-			continue
-		}
+func TestStoreToPodListerListsPodsOnHost(t *testing.T) {
+	// The scheduling algorithms only list the pods on each minion they consider.
+	if _, ok := interface{}(&cache.StoreToPodLister{}).(algorithm.HostPodLister); !ok {
+		t.Errorf("Expected StoreToPodLister to list the pods on a host")
 	}
 }
 
@@ -0,0 +1,95 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/cache"
+	algorithm "github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
+)
+
+// Placement is where a simulation placed a pod, or why it couldn't.
+type Placement struct {
+	PodID string
+	// Host is empty if the pod fits no minion.
+	Host string
+	// Err says why the pod fits no minion; a *algorithm.FitError lists the
+	// predicates which ruled out each.
+	Err error
+}
+
+// Simulate places pods, one after the other, onto a snapshot of the minions,
+// pods and services of the cluster, as the scheduler would, without binding
+// them. Each pod placed takes its ports and resources from those placed after
+// it, so that operators can see whether a batch of pods would fit.
+func (factory *ConfigFactory) Simulate(pods []api.Pod) ([]Placement, error) {
+	minionCache := cache.NewStore()
+	minions, err := factory.pollMinions()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < minions.Len(); i++ {
+		minionCache.Add(minions.Get(i))
+	}
+
+	podCache := cache.NewPodIndexer()
+	existing := &api.PodList{}
+	if err := factory.Client.Get().Path("pods").Do().Into(existing); err != nil {
+		return nil, err
+	}
+	for i := range existing.Items {
+		if existing.Items[i].DesiredState.Host != "" {
+			podCache.Add(existing.Items[i].ID, &existing.Items[i])
+		}
+	}
+
+	serviceCache := cache.NewStore()
+	services, err := factory.pollServices()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < services.Len(); i++ {
+		serviceCache.Add(services.Get(i))
+	}
+
+	minionLister := &storeToMinionLister{minionCache}
+	algo, err := factory.createAlgorithm(algorithm.PluginFactoryArgs{
+		PodLister:     &cache.StoreToPodLister{Indexer: podCache},
+		ServiceLister: &storeToServiceLister{serviceCache},
+		MinionInfo:    minionLister,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	placements := []Placement{}
+	for i, pod := range pods {
+		host, err := algo.Schedule(pod, minionLister)
+		placements = append(placements, Placement{PodID: pod.ID, Host: host, Err: err})
+		if err == nil {
+			placed := pod
+			placed.DesiredState.Host = host
+			// Pods to simulate are often copies sharing an ID, which may be
+			// that of an existing pod too. Each needs its own key to take its
+			// share of the minion; no pod ID contains a "/".
+			podCache.Add(fmt.Sprintf("simulated/%d", i), &placed)
+		}
+	}
+	return placements, nil
+}
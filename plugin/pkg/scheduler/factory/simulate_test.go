@@ -0,0 +1,129 @@
+/*
+Copyright 2014 Google Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package factory
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client"
+	algorithm "github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
+)
+
+func podWithPort(id, host string, port int) api.Pod {
+	return api.Pod{
+		JSONBase: api.JSONBase{ID: id},
+		DesiredState: api.PodState{
+			Host: host,
+			Manifest: api.ContainerManifest{
+				Containers: []api.Container{{Ports: []api.Port{{HostPort: port}}}},
+			},
+		},
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	mux := http.NewServeMux()
+	serve := func(path string, obj interface{}) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+			if req.Method != "GET" {
+				t.Errorf("Unexpected %s %s", req.Method, req.URL.Path)
+			}
+			w.Write([]byte(api.EncodeOrDie(obj)))
+		})
+	}
+	serve("/api/v1beta1/minions", &api.MinionList{Items: []api.Minion{
+		{JSONBase: api.JSONBase{ID: "m1"}},
+		{JSONBase: api.JSONBase{ID: "m2"}},
+	}})
+	serve("/api/v1beta1/pods", &api.PodList{Items: []api.Pod{
+		podWithPort("running", "m1", 8080),
+		podWithPort("pending", "", 9090),
+	}})
+	serve("/api/v1beta1/services", &api.ServiceList{})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	factory := ConfigFactory{Client: client.New(server.URL, nil)}
+	placements, err := factory.Simulate([]api.Pod{
+		podWithPort("a", "", 8080),
+		podWithPort("b", "", 8080),
+		podWithPort("c", "", 9090),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(placements) != 3 {
+		t.Fatalf("Expected 3 placements, got %#v", placements)
+	}
+	// a only fits on m2, taking the port b needs.
+	if p := placements[0]; p.PodID != "a" || p.Host != "m2" || p.Err != nil {
+		t.Errorf("Unexpected placement of a: %#v", p)
+	}
+	if p := placements[1]; p.PodID != "b" || p.Host != "" {
+		t.Errorf("Unexpected placement of b: %#v", p)
+	} else if _, ok := p.Err.(*algorithm.FitError); !ok {
+		t.Errorf("Expected a FitError for b, got %v", p.Err)
+	}
+	// Unassigned pods take no ports.
+	if p := placements[2]; p.PodID != "c" || p.Host == "" || p.Err != nil {
+		t.Errorf("Unexpected placement of c: %#v", p)
+	}
+}
+
+func TestSimulateCopiesOfAPod(t *testing.T) {
+	mux := http.NewServeMux()
+	serve := func(path string, obj interface{}) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(api.EncodeOrDie(obj)))
+		})
+	}
+	serve("/api/v1beta1/minions", &api.MinionList{Items: []api.Minion{
+		{JSONBase: api.JSONBase{ID: "m1"}},
+		{JSONBase: api.JSONBase{ID: "m2"}},
+	}})
+	serve("/api/v1beta1/pods", &api.PodList{Items: []api.Pod{
+		podWithPort("web", "m1", 9090),
+	}})
+	serve("/api/v1beta1/services", &api.ServiceList{})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Each copy takes the port on one minion, so the third doesn't fit. The
+	// existing pod of the same ID stays on its minion too.
+	factory := ConfigFactory{Client: client.New(server.URL, nil)}
+	placements, err := factory.Simulate([]api.Pod{
+		podWithPort("web", "", 8080),
+		podWithPort("web", "", 8080),
+		podWithPort("web", "", 8080),
+		podWithPort("other", "", 9090),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(placements) != 4 || placements[0].Host == "" || placements[1].Host == "" || placements[0].Host == placements[1].Host {
+		t.Fatalf("Expected the first two pods on different minions, got %#v", placements)
+	}
+	if _, ok := placements[2].Err.(*algorithm.FitError); !ok {
+		t.Errorf("Expected a FitError for the third pod, got %#v", placements[2])
+	}
+	if p := placements[3]; p.Host != "m2" {
+		t.Errorf("Expected the last pod on m2, got %#v", p)
+	}
+}
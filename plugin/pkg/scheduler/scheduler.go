@@ -17,7 +17,11 @@ limitations under the License.
 package scheduler
 
 import (
+	"time"
+
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
 	// TODO: move everything from pkg/scheduler into this package. Remove references from registry.
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/util"
@@ -28,10 +32,23 @@ type Binder interface {
 	Bind(binding *api.Binding) error
 }
 
+// failedSchedulingEventInterval is how often at most a failedScheduling event
+// is recorded for a pod which keeps failing to schedule.
+const failedSchedulingEventInterval = time.Minute
+
 // Scheduler watches for new unscheduled pods. It attempts to find
 // minions that they fit on and writes bindings back to the api server.
 type Scheduler struct {
 	config *Config
+	clock  func() time.Time
+
+	// failureEvents holds when a failedScheduling event was last recorded for
+	// each pod which failed to schedule since it was last scheduled. Only
+	// scheduleOne, which runs in a single goroutine, uses it.
+	failureEvents map[string]time.Time
+	// lastPrune is when the pods which weren't retried for a while, e.g.
+	// because they were deleted, were last dropped from failureEvents.
+	lastPrune time.Time
 }
 
 type Config struct {
@@ -48,12 +65,19 @@ type Config struct {
 	// Error is called if there is an error. It is passed the pod in
 	// question, and the error
 	Error func(*api.Pod, error)
+
+	// Optional: Assume is called with every pod once it is bound, its
+	// DesiredState.Host set, so that the Algorithm counts the ports and
+	// resources it takes before the pod shows up in any watch.
+	Assume func(*api.Pod)
 }
 
 // New returns a new scheduler.
 func New(c *Config) *Scheduler {
 	s := &Scheduler{
-		config: c,
+		config:        c,
+		clock:         time.Now,
+		failureEvents: map[string]time.Time{},
 	}
 	return s
 }
@@ -63,10 +87,22 @@ func (s *Scheduler) Run() {
 	go util.Forever(s.scheduleOne, 0)
 }
 
+// scheduleOne places the next pod. How long choosing its minion, binding it,
+// and both together took is recorded in metrics.Default as
+// "scheduler.algorithm.latency", "scheduler.binding.latency" and
+// "scheduler.scheduling.latency"; failures are counted in "scheduler.failures".
+// The time the pod waited in the queue is not included.
+// Events are only queued here, to be written by the goroutine of
+// record.StartRecording, so a slow apiserver doesn't hold up scheduling.
 func (s *Scheduler) scheduleOne() {
 	pod := s.config.NextPod()
+	start := time.Now()
+	ref := api.ObjectReference{Kind: "Pod", ID: pod.ID}
 	dest, err := s.config.Algorithm.Schedule(*pod, s.config.MinionLister)
+	metrics.Default.Histogram("scheduler.algorithm.latency").Since(start)
 	if err != nil {
+		metrics.Default.Counter("scheduler.failures").Inc()
+		s.recordFailure(ref, "Error scheduling: %v", err)
 		s.config.Error(pod, err)
 		return
 	}
@@ -74,7 +110,41 @@ func (s *Scheduler) scheduleOne() {
 		PodID: pod.ID,
 		Host:  dest,
 	}
-	if err := s.config.Binder.Bind(b); err != nil {
+	bindingStart := time.Now()
+	err = s.config.Binder.Bind(b)
+	metrics.Default.Histogram("scheduler.binding.latency").Since(bindingStart)
+	if err != nil {
+		metrics.Default.Counter("scheduler.failures").Inc()
+		s.recordFailure(ref, "Binding rejected: %v", err)
 		s.config.Error(pod, err)
+		return
+	}
+	metrics.Default.Histogram("scheduler.scheduling.latency").Since(start)
+	delete(s.failureEvents, pod.ID)
+	record.Eventf(ref, "scheduled", "Successfully assigned %v to %v", pod.ID, dest)
+	if s.config.Assume != nil {
+		assumed := *pod
+		assumed.DesiredState.Host = dest
+		s.config.Assume(&assumed)
+	}
+}
+
+// recordFailure records a failedScheduling event for the pod ref refers to,
+// unless one was recorded for it within failedSchedulingEventInterval. A pod
+// retried over and over would flood the events otherwise.
+func (s *Scheduler) recordFailure(ref api.ObjectReference, messageFmt string, args ...interface{}) {
+	now := s.clock()
+	if now.Sub(s.lastPrune) >= failedSchedulingEventInterval {
+		for id, last := range s.failureEvents {
+			if now.Sub(last) >= failedSchedulingEventInterval {
+				delete(s.failureEvents, id)
+			}
+		}
+		s.lastPrune = now
+	}
+	if last, ok := s.failureEvents[ref.ID]; ok && now.Sub(last) < failedSchedulingEventInterval {
+		return
 	}
+	s.failureEvents[ref.ID] = now
+	record.Eventf(ref, "failedScheduling", messageFmt, args...)
 }
@@ -20,8 +20,11 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/api"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/client/record"
+	"github.com/GoogleCloudPlatform/kubernetes/pkg/metrics"
 	"github.com/GoogleCloudPlatform/kubernetes/pkg/scheduler"
 )
 
@@ -56,16 +59,21 @@ func TestScheduler(t *testing.T) {
 		expectErrorPod  *api.Pod
 		expectError     error
 		expectBind      *api.Binding
+		expectAssumed   *api.Pod
+		eventReason     string
 	}{
 		{
-			sendPod:    podWithID("foo"),
-			algo:       mockScheduler{"machine1", nil},
-			expectBind: &api.Binding{PodID: "foo", Host: "machine1"},
+			sendPod:       podWithID("foo"),
+			algo:          mockScheduler{"machine1", nil},
+			expectBind:    &api.Binding{PodID: "foo", Host: "machine1"},
+			expectAssumed: &api.Pod{JSONBase: api.JSONBase{ID: "foo"}, DesiredState: api.PodState{Host: "machine1"}},
+			eventReason:   "scheduled",
 		}, {
 			sendPod:        podWithID("foo"),
 			algo:           mockScheduler{"machine1", errS},
 			expectError:    errS,
 			expectErrorPod: podWithID("foo"),
+			eventReason:    "failedScheduling",
 		}, {
 			sendPod:         podWithID("foo"),
 			algo:            mockScheduler{"machine1", nil},
@@ -73,13 +81,17 @@ func TestScheduler(t *testing.T) {
 			injectBindError: errB,
 			expectError:     errB,
 			expectErrorPod:  podWithID("foo"),
+			eventReason:     "failedScheduling",
 		},
 	}
 
 	for i, item := range table {
+		events := make(chan api.Event, 10)
+		w := record.GetEvents(func(event api.Event) { events <- event })
 		var gotError error
 		var gotPod *api.Pod
 		var gotBinding *api.Binding
+		var gotAssumed *api.Pod
 		c := &Config{
 			MinionLister: scheduler.FakeMinionLister{"machine1"},
 			Algorithm:    item.algo,
@@ -94,9 +106,25 @@ func TestScheduler(t *testing.T) {
 			NextPod: func() *api.Pod {
 				return item.sendPod
 			},
+			Assume: func(p *api.Pod) {
+				gotAssumed = p
+			},
 		}
+		scheduled := metrics.Default.Histogram("scheduler.scheduling.latency").Snapshot().Count
+		failures := metrics.Default.Counter("scheduler.failures").Value()
 		s := New(c)
 		s.scheduleOne()
+		if item.expectError == nil {
+			scheduled++
+		} else {
+			failures++
+		}
+		if e, a := scheduled, metrics.Default.Histogram("scheduler.scheduling.latency").Snapshot().Count; e != a {
+			t.Errorf("%v: scheduled pods: wanted %v, got %v", i, e, a)
+		}
+		if e, a := failures, metrics.Default.Counter("scheduler.failures").Value(); e != a {
+			t.Errorf("%v: failures: wanted %v, got %v", i, e, a)
+		}
 		if e, a := item.expectErrorPod, gotPod; !reflect.DeepEqual(e, a) {
 			t.Errorf("%v: error pod: wanted %v, got %v", i, e, a)
 		}
@@ -106,5 +134,72 @@ func TestScheduler(t *testing.T) {
 		if e, a := item.expectBind, gotBinding; !reflect.DeepEqual(e, a) {
 			t.Errorf("%v: error: wanted %v, got %v", i, e, a)
 		}
+		if e, a := item.expectAssumed, gotAssumed; !reflect.DeepEqual(e, a) {
+			t.Errorf("%v: assumed pod: wanted %v, got %v", i, e, a)
+		}
+		select {
+		case event := <-events:
+			if e, a := item.eventReason, event.Reason; e != a {
+				t.Errorf("%v: event reason: wanted %v, got %v", i, e, a)
+			}
+			if e, a := (api.ObjectReference{Kind: "Pod", ID: "foo"}), event.InvolvedObject; e != a {
+				t.Errorf("%v: event object: wanted %v, got %v", i, e, a)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%v: no event recorded", i)
+		}
+		w.Stop()
 	}
 }
+
+func TestFailedSchedulingEventsAreRateLimited(t *testing.T) {
+	events := make(chan api.Event, 10)
+	w := record.GetEvents(func(event api.Event) { events <- event })
+	defer w.Stop()
+	algo := &mockScheduler{"machine1", errors.New("scheduler")}
+	c := &Config{
+		MinionLister: scheduler.FakeMinionLister{"machine1"},
+		Algorithm:    algo,
+		Binder:       fakeBinder{func(b *api.Binding) error { return nil }},
+		Error:        func(p *api.Pod, err error) {},
+		NextPod:      func() *api.Pod { return podWithID("foo") },
+	}
+	s := New(c)
+	now := time.Unix(0, 0)
+	s.clock = func() time.Time { return now }
+
+	expectEvents := func(expected ...string) {
+		for _, reason := range expected {
+			select {
+			case event := <-events:
+				if event.Reason != reason {
+					t.Errorf("expected a %s event, got %#v", reason, event)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("expected a %s event", reason)
+			}
+		}
+		select {
+		case event := <-events:
+			t.Errorf("unexpected event %#v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	s.scheduleOne()
+	expectEvents("failedScheduling")
+	// Retries within the interval don't repeat the event.
+	now = now.Add(30 * time.Second)
+	s.scheduleOne()
+	expectEvents()
+	now = now.Add(30 * time.Second)
+	s.scheduleOne()
+	expectEvents("failedScheduling")
+
+	// Once scheduled, the next failure is recorded right away.
+	algo.err = nil
+	s.scheduleOne()
+	algo.err = errors.New("scheduler")
+	s.scheduleOne()
+	expectEvents("scheduled", "failedScheduling")
+}